@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/dedupe"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/eventbus"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/logger"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/telegram"
+)
+
+// messenger is the subset of the Telegram client used by the alerter.
+type messenger interface {
+	SendMessage(ctx context.Context, chatID int64, text string) error
+}
+
+// publisher is the subset of eventbus.Publisher used by the alerter.
+type publisher interface {
+	Publish(ctx context.Context, events ...eventbus.Event) error
+}
+
+// searchStore loads saved searches; 0 lists every chat's searches.
+type searchStore interface {
+	SavedSearches(ctx context.Context, chatID int64) ([]models.SavedSearch, error)
+}
+
+type alerter struct {
+	log   *slog.Logger
+	tg    messenger
+	store searchStore
+	// seen drops repeats of a document: keyed fan-out publishes it once per
+	// destination and again when a repost updates it. It also holds the
+	// offer clusters each chat was alerted to.
+	seen *dedupe.Cache
+	// events, when set, receives an AlertMatched event per sent alert.
+	events publisher
+
+	mu       sync.RWMutex
+	searches []models.SavedSearch
+}
+
+func main() {
+	log := logger.New("alerter")
+	cfg, err := config.LoadAlerter()
+	if err != nil {
+		log.Error("load config", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	esClient, err := elasticsearch.New(cfg.ElasticsearchAddr, cfg.ElasticsearchIndex, log,
+		elasticsearch.SignAWS(cfg.ElasticsearchAWSRegion, cfg.ElasticsearchAWSService),
+		elasticsearch.LogSlowQueries(cfg.ElasticsearchSlowThreshold, log))
+	if err != nil {
+		log.Error("init elasticsearch", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if cfg.ElasticsearchDailyIndices {
+		esClient.EnableDailyIndices()
+	}
+
+	a := &alerter{
+		log:   log,
+		tg:    telegram.New(cfg.TelegramToken),
+		store: esClient,
+		seen:  dedupe.NewCache(cfg.DedupeCapacity, cfg.DedupeTTL),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	go a.runRefresh(ctx, cfg.RefreshInterval)
+
+	reader := eventbus.NewReader(cfg.KafkaBrokers, cfg.KafkaConsumer, cfg.KafkaTopic)
+	defer reader.Close()
+
+	if cfg.EventsTopic != "" {
+		writer := eventbus.NewWriter(cfg.KafkaBrokers)
+		defer writer.Close()
+		a.events = eventbus.NewPublisher(writer, cfg.EventsTopic)
+	}
+
+	log.Info("alerter started",
+		slog.String("topic", cfg.KafkaTopic),
+		slog.String("group", cfg.KafkaConsumer),
+		slog.Duration("refresh_interval", cfg.RefreshInterval),
+	)
+
+	eventbus.Subscribe(ctx, log, reader, a.handleEvent)
+	log.Info("shutdown signal received")
+}
+
+// runRefresh reloads saved searches immediately and then on every interval.
+// A failed reload keeps the previous set.
+func (a *alerter) runRefresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.refresh(ctx); err != nil && ctx.Err() == nil {
+			a.log.Warn("reload saved searches", slog.Any("err", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *alerter) refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	searches, err := a.store.SavedSearches(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.searches = searches
+	a.mu.Unlock()
+	return nil
+}
+
+// handleEvent notifies every chat with a saved search matching an indexed
+// document and publishes an AlertMatched event for each alert sent; other
+// events are ignored. Delivery failures are logged and skipped; alerts are
+// best effort and never block the stream.
+func (a *alerter) handleEvent(ctx context.Context, e eventbus.Event, _ kafka.Message) error {
+	indexed, ok := e.(eventbus.DocumentIndexed)
+	if !ok {
+		return nil
+	}
+	doc := indexed.Document
+	if doc.ID == "" || a.seen.IsSeen(doc.ID) {
+		return nil
+	}
+	a.seen.MarkSeen(doc.ID)
+
+	text := formatAlert(doc)
+	var sent []eventbus.Event
+	for _, chatID := range a.matchingChats(doc) {
+		// Copies of one offer from other channels share its cluster, and a
+		// chat hears of the offer once.
+		key := alertKey(chatID, doc)
+		if a.seen.IsSeen(key) {
+			continue
+		}
+		a.seen.MarkSeen(key)
+		if err := a.tg.SendMessage(ctx, chatID, text); err != nil {
+			a.log.Warn("send alert",
+				slog.Int64("chat_id", chatID),
+				slog.String("id", doc.ID),
+				slog.Any("err", err),
+			)
+			continue
+		}
+		sent = append(sent, eventbus.AlertMatched{DocumentID: doc.ID, ChatID: chatID, At: time.Now().UTC()})
+	}
+	if a.events != nil && len(sent) > 0 {
+		if err := a.events.Publish(ctx, sent...); err != nil {
+			a.log.Warn("publish alert events", slog.String("id", doc.ID), slog.Any("err", err))
+		}
+	}
+	return nil
+}
+
+// matchingChats returns each chat with at least one saved search matching
+// doc, once, so overlapping searches do not produce duplicate alerts.
+func (a *alerter) matchingChats(doc models.NewsDocument) []int64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var chats []int64
+	for _, s := range a.searches {
+		if matches(s, doc) && !slices.Contains(chats, s.ChatID) {
+			chats = append(chats, s.ChatID)
+		}
+	}
+	return chats
+}
+
+// alertKey names the offer of doc for a chat: its cluster, or the document
+// itself when it has none.
+func alertKey(chatID int64, doc models.NewsDocument) string {
+	if doc.ClusterID != "" {
+		return fmt.Sprintf("chat:%d:cluster:%s", chatID, doc.ClusterID)
+	}
+	return fmt.Sprintf("chat:%d:doc:%s", chatID, doc.ID)
+}
+
+// matches reports whether doc satisfies every criterion set on s. Keywords
+// match like the /news keywords filter: any one of them is enough. Documents
+// indexed before s was created never match it.
+func matches(s models.SavedSearch, doc models.NewsDocument) bool {
+	if !doc.IndexedAt.IsZero() && doc.IndexedAt.Before(s.CreatedAt) {
+		return false
+	}
+	if len(s.Keywords) > 0 && !slices.ContainsFunc(s.Keywords, func(k string) bool {
+		return slices.Contains(doc.Keywords, k)
+	}) {
+		return false
+	}
+	if s.Destination != "" && !slices.Contains(doc.Destinations, s.Destination) {
+		return false
+	}
+	if s.MaxPrice > 0 && (doc.Price <= 0 || doc.Price > s.MaxPrice) {
+		return false
+	}
+	return true
+}
+
+func formatAlert(doc models.NewsDocument) string {
+	var sb strings.Builder
+	sb.WriteString("Новое предложение по сохранённому поиску:\n\n")
+	sb.WriteString("<b>" + html.EscapeString(doc.Title) + "</b>\n")
+	sb.WriteString(html.EscapeString(doc.Source))
+	if doc.Price > 0 {
+		sb.WriteString(fmt.Sprintf(" · %d ₽", doc.Price))
+	}
+	if len(doc.URLs) > 0 {
+		sb.WriteString("\n" + html.EscapeString(doc.URLs[0]))
+	}
+	return sb.String()
+}
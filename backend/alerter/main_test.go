@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/dedupe"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/eventbus"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+type sentAlert struct {
+	chatID int64
+	text   string
+}
+
+type stubMessenger struct {
+	sent []sentAlert
+}
+
+func (s *stubMessenger) SendMessage(_ context.Context, chatID int64, text string) error {
+	s.sent = append(s.sent, sentAlert{chatID: chatID, text: text})
+	return nil
+}
+
+type stubStore struct {
+	searches []models.SavedSearch
+}
+
+func (s *stubStore) SavedSearches(context.Context, int64) ([]models.SavedSearch, error) {
+	return s.searches, nil
+}
+
+func TestMatches(t *testing.T) {
+	doc := models.NewsDocument{
+		Keywords:     []string{"пляж", "всё включено"},
+		Destinations: []string{"кемер", "турция"},
+		Price:        45000,
+	}
+
+	require.True(t, matches(models.SavedSearch{Keywords: []string{"горы", "пляж"}}, doc))
+	require.False(t, matches(models.SavedSearch{Keywords: []string{"горы"}}, doc))
+	require.True(t, matches(models.SavedSearch{Destination: "турция", MaxPrice: 50000}, doc))
+	require.False(t, matches(models.SavedSearch{Destination: "египет"}, doc))
+	require.False(t, matches(models.SavedSearch{MaxPrice: 40000}, doc))
+
+	doc.Price = 0
+	require.False(t, matches(models.SavedSearch{MaxPrice: 40000}, doc), "unknown price never satisfies max_price")
+
+	doc.IndexedAt = time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	require.True(t, matches(models.SavedSearch{Destination: "турция", CreatedAt: doc.IndexedAt}, doc))
+	require.False(t, matches(models.SavedSearch{Destination: "турция", CreatedAt: doc.IndexedAt.Add(time.Second)}, doc), "indexed before the search was created")
+}
+
+func TestHandleMessageAlertsEachClusterOnce(t *testing.T) {
+	tg := &stubMessenger{}
+	a := &alerter{
+		log:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		tg:    tg,
+		store: &stubStore{searches: []models.SavedSearch{{ChatID: 1, Destination: "турция"}}},
+		seen:  dedupe.NewCache(100, time.Hour),
+	}
+	require.NoError(t, a.refresh(context.Background()))
+
+	offer := func(id, cluster string) eventbus.DocumentIndexed {
+		return eventbus.DocumentIndexed{Document: models.NewsDocument{ID: id, ClusterID: cluster, Destinations: []string{"турция"}}}
+	}
+	// The same offer cross-posted by another channel is a new document of the same cluster.
+	require.NoError(t, a.handleEvent(context.Background(), offer("doc-1", "cluster-1"), kafka.Message{}))
+	require.NoError(t, a.handleEvent(context.Background(), offer("doc-2", "cluster-1"), kafka.Message{}))
+	require.NoError(t, a.handleEvent(context.Background(), offer("doc-3", "cluster-2"), kafka.Message{}))
+	require.NoError(t, a.handleEvent(context.Background(), offer("doc-4", ""), kafka.Message{}))
+	require.Len(t, tg.sent, 3)
+}
+
+func TestHandleMessageAlertsEachChatOnce(t *testing.T) {
+	tg := &stubMessenger{}
+	a := &alerter{
+		log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		tg:  tg,
+		store: &stubStore{searches: []models.SavedSearch{
+			{ChatID: 1, Destination: "турция"},
+			{ChatID: 1, Keywords: []string{"пляж"}},
+			{ChatID: 2, Destination: "египет"},
+			{ChatID: 3, MaxPrice: 60000},
+		}},
+		seen: dedupe.NewCache(100, time.Hour),
+	}
+	require.NoError(t, a.refresh(context.Background()))
+
+	indexed := eventbus.DocumentIndexed{Document: models.NewsDocument{
+		ID:           "doc-1",
+		Title:        "Кемер <5*>",
+		Source:       "telegram",
+		Keywords:     []string{"пляж"},
+		Destinations: []string{"кемер", "турция"},
+		Price:        52000,
+		URLs:         []string{"https://example.com/tour"},
+	}}
+
+	// Keyed fan-out delivers the document once per destination.
+	require.NoError(t, a.handleEvent(context.Background(), indexed, kafka.Message{Key: []byte("кемер")}))
+	require.NoError(t, a.handleEvent(context.Background(), indexed, kafka.Message{Key: []byte("турция")}))
+
+	require.Len(t, tg.sent, 2)
+	require.Equal(t, int64(1), tg.sent[0].chatID)
+	require.Equal(t, int64(3), tg.sent[1].chatID)
+	require.Contains(t, tg.sent[0].text, "<b>Кемер &lt;5*&gt;</b>")
+	require.Contains(t, tg.sent[0].text, "52000 ₽")
+	require.Contains(t, tg.sent[0].text, "https://example.com/tour")
+
+	require.NoError(t, a.handleEvent(context.Background(), eventbus.AlertMatched{DocumentID: "doc-2", ChatID: 1}, kafka.Message{}))
+	require.Len(t, tg.sent, 2)
+}
+
+type stubPublisher struct {
+	events []eventbus.Event
+}
+
+func (s *stubPublisher) Publish(_ context.Context, events ...eventbus.Event) error {
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func TestHandleMessagePublishesSentAlerts(t *testing.T) {
+	events := &stubPublisher{}
+	a := &alerter{
+		log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		tg:  &stubMessenger{},
+		store: &stubStore{searches: []models.SavedSearch{
+			{ChatID: 1, Destination: "турция"},
+			{ChatID: 2, Destination: "турция"},
+		}},
+		seen:   dedupe.NewCache(100, time.Hour),
+		events: events,
+	}
+	require.NoError(t, a.refresh(context.Background()))
+
+	indexed := eventbus.DocumentIndexed{Document: models.NewsDocument{ID: "doc-1", Destinations: []string{"турция"}}}
+	require.NoError(t, a.handleEvent(context.Background(), indexed, kafka.Message{}))
+	require.NoError(t, a.handleEvent(context.Background(), indexed, kafka.Message{}))
+
+	require.Len(t, events.events, 2)
+	for i, e := range events.events {
+		matched := e.(eventbus.AlertMatched)
+		require.Equal(t, "doc-1", matched.DocumentID)
+		require.Equal(t, int64(i+1), matched.ChatID)
+		require.False(t, matched.At.IsZero())
+	}
+}
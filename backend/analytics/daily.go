@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+// dailyStore is the subset of the Elasticsearch client used by the daily
+// aggregates job.
+type dailyStore interface {
+	LatestDailyCount(ctx context.Context) (time.Time, error)
+	DailyTermCounts(ctx context.Context, day time.Time, size int) ([]elasticsearch.DailyCount, error)
+	ReplaceDailyCounts(ctx context.Context, day time.Time, counts []elasticsearch.DailyCount) error
+}
+
+// runDailyAggregates materializes per-day keyword and destination counts
+// for every complete UTC day not stored yet, recounting the last
+// ANALYTICS_AGGREGATES_LOOKBACK days for late documents. The first run
+// backfills ANALYTICS_AGGREGATES_BACKFILL_DAYS days. Days are written
+// oldest first, so a run cut short resumes where it stopped.
+func runDailyAggregates(ctx context.Context, log *slog.Logger, store dailyStore, cfg *config.Analytics, now time.Time) error {
+	today := now.UTC().Truncate(24 * time.Hour)
+	earliest := today.AddDate(0, 0, -cfg.AggregatesBackfillDays)
+
+	latest, err := store.LatestDailyCount(ctx)
+	if err != nil {
+		return err
+	}
+	first := earliest
+	if resume := latest.AddDate(0, 0, 1-cfg.AggregatesLookbackDays); !latest.IsZero() && resume.After(earliest) {
+		first = resume
+	}
+
+	var days, terms int
+	for d := first; d.Before(today); d = d.AddDate(0, 0, 1) {
+		counts, err := store.DailyTermCounts(ctx, d, cfg.AggregatesTerms)
+		if err != nil {
+			return err
+		}
+		// Retention may have deleted the documents of a day already
+		// counted; its stored counts are kept.
+		if len(counts) == 0 {
+			continue
+		}
+		if err := store.ReplaceDailyCounts(ctx, d, counts); err != nil {
+			return err
+		}
+		days++
+		terms += len(counts)
+	}
+
+	log.Info("daily aggregates updated",
+		slog.Time("from", first),
+		slog.Time("until", today),
+		slog.Int("days", days),
+		slog.Int("terms", terms),
+	)
+	return nil
+}
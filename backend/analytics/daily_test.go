@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+type stubDailyStore struct {
+	latest time.Time
+	// empty lists days without documents.
+	empty    map[time.Time]bool
+	replaced []time.Time
+}
+
+func (s *stubDailyStore) LatestDailyCount(context.Context) (time.Time, error) {
+	return s.latest, nil
+}
+
+func (s *stubDailyStore) DailyTermCounts(_ context.Context, day time.Time, _ int) ([]elasticsearch.DailyCount, error) {
+	if s.empty[day] {
+		return nil, nil
+	}
+	return []elasticsearch.DailyCount{{Day: day, Field: "keywords", Term: "турция", Count: 3}}, nil
+}
+
+func (s *stubDailyStore) ReplaceDailyCounts(_ context.Context, day time.Time, _ []elasticsearch.DailyCount) error {
+	s.replaced = append(s.replaced, day)
+	return nil
+}
+
+func TestRunDailyAggregates(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Analytics{AggregatesLookbackDays: 2, AggregatesBackfillDays: 4, AggregatesTerms: 10}
+	now := time.Date(2024, 6, 10, 3, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2024, 6, d, 0, 0, 0, 0, time.UTC) }
+
+	// The first run backfills complete days, skipping those without documents.
+	store := &stubDailyStore{empty: map[time.Time]bool{day(7): true}}
+	require.NoError(t, runDailyAggregates(context.Background(), log, store, cfg, now))
+	require.Equal(t, []time.Time{day(6), day(8), day(9)}, store.replaced)
+
+	// Later runs recount the lookback days and add the new ones.
+	store = &stubDailyStore{latest: day(9)}
+	require.NoError(t, runDailyAggregates(context.Background(), log, store, cfg, now.AddDate(0, 0, 1)))
+	require.Equal(t, []time.Time{day(8), day(9), day(10)}, store.replaced)
+
+	// A long pause never reaches back further than the backfill.
+	store = &stubDailyStore{latest: day(1)}
+	require.NoError(t, runDailyAggregates(context.Background(), log, store, cfg, now))
+	require.Equal(t, []time.Time{day(6), day(7), day(8), day(9)}, store.replaced)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/logger"
+)
+
+// job is a periodic analytics task.
+type job struct {
+	name     string
+	interval time.Duration
+	timeout  time.Duration
+	run      func(ctx context.Context) error
+}
+
+func main() {
+	log := logger.New("analytics")
+	cfg, err := config.LoadAnalytics()
+	if err != nil {
+		log.Error("load config", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	esClient, err := connect(ctx, log, cfg)
+	if err != nil {
+		log.Error("failed to connect to elasticsearch", slog.Any("err", err))
+		os.Exit(1)
+	}
+	log.Info("connected to elasticsearch")
+
+	if err := esClient.EnsureDailyAggregatesIndex(ctx); err != nil {
+		log.Error("ensure daily aggregates index", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	jobs := []job{
+		{
+			name:     "stopwords",
+			interval: cfg.StopwordInterval,
+			timeout:  5 * time.Minute,
+			run: func(ctx context.Context) error {
+				return runStopwords(ctx, log, esClient, cfg, time.Now().UTC())
+			},
+		},
+		{
+			name:     "daily_aggregates",
+			interval: cfg.AggregatesInterval,
+			timeout:  30 * time.Minute,
+			run: func(ctx context.Context) error {
+				return runDailyAggregates(ctx, log, esClient, cfg, time.Now().UTC())
+			},
+		},
+	}
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			schedule(ctx, log, j)
+		}()
+	}
+	wg.Wait()
+	log.Info("shutdown signal received")
+}
+
+// connect waits for Elasticsearch to answer pings, backing off between attempts.
+func connect(ctx context.Context, log *slog.Logger, cfg *config.Analytics) (*elasticsearch.Client, error) {
+	esClient, err := elasticsearch.New(cfg.ElasticsearchAddr, cfg.ElasticsearchIndex, log,
+		elasticsearch.SignAWS(cfg.ElasticsearchAWSRegion, cfg.ElasticsearchAWSService),
+		elasticsearch.LogSlowQueries(cfg.ElasticsearchSlowThreshold, log))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ElasticsearchDailyIndices {
+		esClient.EnableDailyIndices()
+	}
+
+	retryDelay := 2 * time.Second
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err = esClient.Ping(pingCtx)
+		cancel()
+		if err == nil {
+			return esClient, nil
+		}
+		if attempt == 10 {
+			return nil, err
+		}
+
+		log.Warn("elasticsearch ping failed, retrying",
+			slog.Any("err", err),
+			slog.Int("attempt", attempt),
+			slog.Duration("retry_in", retryDelay),
+		)
+		select {
+		case <-time.After(retryDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		retryDelay = min(retryDelay*2, 30*time.Second)
+	}
+}
+
+// schedule runs j immediately and then on every interval until ctx is done.
+func schedule(ctx context.Context, log *slog.Logger, j job) {
+	log = log.With(slog.String("job", j.name))
+	log.Info("job scheduled", slog.Duration("interval", j.interval))
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		runCtx, cancel := context.WithTimeout(ctx, j.timeout)
+		if err := j.run(runCtx); err != nil {
+			log.Warn("job failed (will retry on next interval)", slog.Any("err", err))
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
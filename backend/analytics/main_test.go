@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+func TestSuggestStopwords(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	since := now.Add(-30 * 24 * time.Hour)
+
+	counts := []elasticsearch.TermCount{
+		{Term: "тур", Count: 800},
+		{Term: "вылет", Count: 300},
+		{Term: "турция", Count: 200},
+	}
+
+	got := suggestStopwords(1000, counts, 0.3, since, now)
+	require.Len(t, got, 1)
+	require.Equal(t, "тур", got[0].Token)
+	require.Equal(t, int64(800), got[0].DocCount)
+	require.InDelta(t, 0.8, got[0].Ratio, 1e-9)
+	require.Equal(t, since, got[0].WindowStart)
+
+	require.Empty(t, suggestStopwords(0, counts, 0.3, since, now))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// runStopwords proposes keywords that occur in so many documents that they
+// no longer help to tell offers apart.
+func runStopwords(ctx context.Context, log *slog.Logger, esClient *elasticsearch.Client, cfg *config.Analytics, now time.Time) error {
+	since := now.Add(-cfg.StopwordWindow)
+	total, counts, err := esClient.KeywordDocFrequencies(ctx, since, cfg.StopwordCandidates)
+	if err != nil {
+		return err
+	}
+
+	if total < int64(cfg.StopwordMinDocs) {
+		log.Info("not enough documents for stopword suggestions",
+			slog.Int64("documents", total),
+			slog.Int("min_documents", cfg.StopwordMinDocs),
+		)
+		return nil
+	}
+
+	suggestions := suggestStopwords(total, counts, cfg.StopwordThreshold, since, now)
+	if err := esClient.ReplaceStopwordSuggestions(ctx, suggestions); err != nil {
+		return err
+	}
+
+	log.Info("stopword suggestions updated",
+		slog.Int64("documents", total),
+		slog.Int("suggestions", len(suggestions)),
+	)
+	return nil
+}
+
+// suggestStopwords keeps the terms present in more than threshold of all documents.
+func suggestStopwords(total int64, counts []elasticsearch.TermCount, threshold float64, since, now time.Time) []models.StopwordSuggestion {
+	if total == 0 {
+		return nil
+	}
+
+	var out []models.StopwordSuggestion
+	for _, tc := range counts {
+		ratio := float64(tc.Count) / float64(total)
+		if ratio <= threshold {
+			continue
+		}
+		out = append(out, models.StopwordSuggestion{
+			Token:       tc.Term,
+			DocCount:    tc.Count,
+			Ratio:       ratio,
+			WindowStart: since,
+			ComputedAt:  now,
+		})
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/fieldmask"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/reltime"
+)
+
+// accessWriter carries the field mask of the request to the response
+// serializers: writeJSON, feeds, calendars and the live stream. It also
+// carries the locale that JSON responses format document ages in, empty
+// when the request asked for none.
+type accessWriter struct {
+	http.ResponseWriter
+	mask   *fieldmask.Mask
+	locale string
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w accessWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// fieldAccess picks the field mask for the caller: requests with an
+// X-API-Key listed in API_PARTNER_KEYS get documents as stored, every
+// other request the public mask. It also reads the locale parameter.
+func (s *server) fieldAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("locale")))
+		if locale != "" && !reltime.Supported(locale) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "locale must be one of " + strings.Join(reltime.Locales, ", ")})
+			return
+		}
+
+		var mask *fieldmask.Mask
+		if !s.isPartner(r) {
+			base := strings.TrimRight(s.cfg.PublicURL, "/")
+			if base == "" {
+				base = requestBaseURL(r)
+			}
+			mask = fieldmask.Public(base)
+		}
+		next.ServeHTTP(accessWriter{ResponseWriter: w, mask: mask, locale: locale}, r)
+	})
+}
+
+// requirePartner refuses requests without a partner key. It guards the
+// endpoints that act for any chat, which only trusted services such as the
+// bot may call.
+func (s *server) requirePartner(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.isPartner(r) {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "partner API key required"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) isPartner(r *http.Request) bool {
+	key := strings.TrimSpace(r.Header.Get(apiKeyHeader))
+	if key == "" {
+		return false
+	}
+	for _, partner := range s.cfg.PartnerKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(partner)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// maskFor returns the field mask fieldAccess attached to w. Writers it did
+// not wrap get the public mask, so a route outside the middleware cannot
+// leak partner fields.
+func maskFor(w http.ResponseWriter) *fieldmask.Mask {
+	for {
+		switch v := w.(type) {
+		case accessWriter:
+			return v.mask
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return fieldmask.Public("")
+		}
+	}
+}
+
+// localeFor returns the locale fieldAccess attached to w, or "" when ages
+// are not wanted.
+func localeFor(w http.ResponseWriter) string {
+	for {
+		switch v := w.(type) {
+		case accessWriter:
+			return v.locale
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return ""
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func TestWriteJSONMasksDocuments(t *testing.T) {
+	srv := &server{cfg: &config.API{PartnerKeys: []string{"partner"}, PublicURL: "https://radar.example.com/api/"}}
+	doc := models.NewsDocument{
+		ID:     "a",
+		Title:  "Турция, звоните +7 (999) 123-45-67",
+		Text:   "Кемер",
+		URLs:   []string{"https://partner.example/tour?aff=42"},
+		Clicks: 12,
+	}
+	handler := srv.fieldAccess(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"items": []models.NewsDocument{doc}})
+	}))
+	decode := func(rec *httptest.ResponseRecorder) models.NewsDocument {
+		var body struct {
+			Items []models.NewsDocument `json:"items"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Items, 1)
+		return body.Items[0]
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/news", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	public := decode(rec)
+	require.Equal(t, "Турция, звоните [phone]", public.Title)
+	require.Equal(t, []string{"https://radar.example.com/api/r/a/0"}, public.URLs)
+	require.Zero(t, public.Clicks)
+
+	req := httptest.NewRequest(http.MethodGet, "/news", nil)
+	req.Header.Set(apiKeyHeader, "partner")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, doc, decode(rec))
+
+	// Writers fieldAccess did not wrap get the public mask.
+	rec = httptest.NewRecorder()
+	writeJSON(rec, http.StatusOK, map[string]any{"items": []models.NewsDocument{doc}})
+	require.Equal(t, "Турция, звоните [phone]", decode(rec).Title)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/news?locale=xx", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+const maxAdminBodyBytes = 1 << 20
+
+// requireAdmin rejects requests that do not carry the configured admin bearer token.
+func (s *server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.AdminToken)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "admin token required"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type retagFilter struct {
+	Query    string     `json:"q"`
+	Keywords []string   `json:"keywords"`
+	Source   string     `json:"source"`
+	Start    *time.Time `json:"start"`
+	End      *time.Time `json:"end"`
+}
+
+type retagRequest struct {
+	Filter retagFilter `json:"filter"`
+	Add    []string    `json:"add"`
+	Remove []string    `json:"remove"`
+}
+
+func (s *server) handleRetag(w http.ResponseWriter, r *http.Request) {
+	var req retagRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	query, err := s.normalizeQuery(req.Filter.Query)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	filter := elasticsearch.SearchParams{
+		Query:    query,
+		Keywords: s.keywordFilter(normalizeKeywords(req.Filter.Keywords)),
+		Source:   strings.TrimSpace(req.Filter.Source),
+		Start:    req.Filter.Start,
+		End:      req.Filter.End,
+	}
+	if filter.Query == "" && len(filter.Keywords) == 0 && filter.Source == "" && filter.Start == nil && filter.End == nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "filter must contain at least one condition"})
+		return
+	}
+
+	add := normalizeKeywords(req.Add)
+	remove := normalizeKeywords(req.Remove)
+	if len(add) == 0 && len(remove) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "add or remove must list at least one keyword"})
+		return
+	}
+
+	// update_by_query over a large index can take a while, so extend the
+	// server-wide write deadline for this request only.
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(2*time.Minute + 5*time.Second))
+
+	result, err := s.es.RetagNews(ctx, filter, add, remove)
+	if err != nil {
+		s.log.ErrorContext(r.Context(), "retag", slog.Any("err", err))
+		writeError(w, err)
+		return
+	}
+	s.searchCache.invalidate()
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// normalizeKeywords lowercases and trims keywords the same way the worker stores them.
+func normalizeKeywords(raw []string) []string {
+	out := make([]string, 0, len(raw))
+	seen := make(map[string]struct{}, len(raw))
+	for _, kw := range raw {
+		kw = strings.ToLower(strings.TrimSpace(kw))
+		if kw == "" {
+			continue
+		}
+		if _, ok := seen[kw]; ok {
+			continue
+		}
+		seen[kw] = struct{}{}
+		out = append(out, kw)
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/concepts"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+// conceptOverfetch widens the keyword aggregation so that variants merged
+// into one concept do not leave the reported top list short.
+const conceptOverfetch = 3
+
+// handleAggregations reports the most frequent keywords, sources and
+// destinations among documents matching the /news filters, for trend widgets.
+// Keywords are merged into cross-language concepts ("egypt" counts toward
+// "египет").
+func (s *server) handleAggregations(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	params, err := s.parseSearchParams(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	fields := parseCSV(r.URL.Query().Get("fields"))
+	size := clampInt(r.URL.Query().Get("size"), 10, elasticsearch.MaxAggregationSize)
+
+	result, err := s.es.AggregateNews(ctx, params, fields, size*conceptOverfetch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	result.Buckets = s.trimBuckets(result.Buckets, size)
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// mergeConcepts sums the counts of keywords belonging to the same concept
+// under its canonical name, most frequent first. A document tagged with two
+// variants of a concept counts twice.
+func mergeConcepts(counts []elasticsearch.TermCount, table *concepts.Table) []elasticsearch.TermCount {
+	merged := make([]elasticsearch.TermCount, 0, len(counts))
+	index := make(map[string]int, len(counts))
+	for _, c := range counts {
+		name := table.Canonical(c.Term)
+		if i, ok := index[name]; ok {
+			merged[i].Count += c.Count
+			continue
+		}
+		index[name] = len(merged)
+		merged = append(merged, elasticsearch.TermCount{Term: name, Count: c.Count})
+	}
+	slices.SortStableFunc(merged, func(a, b elasticsearch.TermCount) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	return merged
+}
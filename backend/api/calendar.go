@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// handleNewsCalendar exports a single document's travel dates as an iCalendar file.
+func (s *server) handleNewsCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	doc, err := s.es.GetNewsByID(ctx, chi.URLParam(r, "docID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if doc.TravelStart.IsZero() {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "document has no travel dates"})
+		return
+	}
+
+	writeCalendar(w, doc.ID+".ics", []models.NewsDocument{*doc})
+}
+
+// handleSearchCalendar exports the travel dates of the newest documents
+// matching the /news filters as an iCalendar file.
+func (s *server) handleSearchCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	params, err := s.parseSearchParams(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	params.From = 0
+	params.Size = feedSize
+	params.Sort = ""
+	params.Relax = false
+
+	s.writeSearchCalendar(ctx, w, params, "search.ics")
+}
+
+// writeSearchCalendar renders the matches of params that have travel dates.
+func (s *server) writeSearchCalendar(ctx context.Context, w http.ResponseWriter, params elasticsearch.SearchParams, filename string) {
+	result, err := s.es.SearchNews(ctx, params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	docs := make([]models.NewsDocument, 0, len(result.Items))
+	for _, doc := range result.Items {
+		if !doc.TravelStart.IsZero() {
+			docs = append(docs, doc)
+		}
+	}
+	writeCalendar(w, filename, docs)
+}
+
+func writeCalendar(w http.ResponseWriter, filename string, docs []models.NewsDocument) {
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	mask := maskFor(w)
+
+	var sb strings.Builder
+	writeICSLine(&sb, "BEGIN:VCALENDAR")
+	writeICSLine(&sb, "VERSION:2.0")
+	writeICSLine(&sb, "PRODID:-//hot-tour-radar//news//RU")
+	writeICSLine(&sb, "CALSCALE:GREGORIAN")
+	for _, doc := range docs {
+		doc = mask.Document(doc)
+		start := doc.TravelStart.UTC()
+		end := doc.TravelEnd.UTC()
+		if end.Before(start) {
+			end = start
+		}
+
+		writeICSLine(&sb, "BEGIN:VEVENT")
+		writeICSLine(&sb, "UID:"+doc.ID+"@hot-tour-radar")
+		writeICSLine(&sb, "DTSTAMP:"+stamp)
+		writeICSLine(&sb, "DTSTART;VALUE=DATE:"+start.Format("20060102"))
+		// All-day events end exclusively on the following day.
+		writeICSLine(&sb, "DTEND;VALUE=DATE:"+end.AddDate(0, 0, 1).Format("20060102"))
+		writeICSLine(&sb, "SUMMARY:"+escapeICSText(doc.Title))
+		writeICSLine(&sb, "DESCRIPTION:"+escapeICSText(doc.Text))
+		if len(doc.URLs) > 0 {
+			writeICSLine(&sb, "URL:"+doc.URLs[0])
+		}
+		writeICSLine(&sb, "END:VEVENT")
+	}
+	writeICSLine(&sb, "END:VCALENDAR")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(sb.String()))
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeICSText(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// writeICSLine writes a content line folded at 75 octets as RFC 5545 requires,
+// never splitting a multi-byte character.
+func writeICSLine(sb *strings.Builder, line string) {
+	const limit = 75
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > limit {
+			sb.WriteString("\r\n ")
+			width = 1
+		}
+		sb.WriteRune(r)
+		width += size
+	}
+	sb.WriteString("\r\n")
+}
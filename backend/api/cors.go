@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+)
+
+// corsExposedHeaders are the response headers besides the CORS-safelisted
+// ones that browser pages may read.
+var corsExposedHeaders = strings.Join([]string{"ETag", "Retry-After", "X-Cache", "Content-Disposition"}, ", ")
+
+// cors lets browser pages on other origins call the API, as configured by
+// API_CORS_*. Requests from other origins get no CORS headers, so browsers
+// keep their pages from reading the response; the request itself is served
+// as usual, as CORS protects readers rather than the API.
+type cors struct {
+	origins     []string
+	anyOrigin   bool
+	methods     string
+	headers     string
+	credentials bool
+	maxAge      string
+}
+
+// newCORS returns nil when no origin is allowed.
+func newCORS(cfg *config.API) *cors {
+	if len(cfg.CORSOrigins) == 0 {
+		return nil
+	}
+	c := &cors{
+		origins:     cfg.CORSOrigins,
+		anyOrigin:   slices.Contains(cfg.CORSOrigins, "*"),
+		methods:     strings.Join(cfg.CORSMethods, ", "),
+		headers:     strings.Join(cfg.CORSHeaders, ", "),
+		credentials: cfg.CORSCredentials,
+	}
+	if cfg.CORSMaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(cfg.CORSMaxAge.Seconds()))
+	}
+	return c
+}
+
+// middleware adds the CORS headers and answers preflight requests itself,
+// before rate limiting and routing, so preflights cost no tokens and
+// rejections such as 429 stay readable to the page.
+func (c *cors) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		// Shared caches must not hand a response made for one origin, or
+		// for none, to another.
+		h.Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if origin == "" || !c.anyOrigin && !slices.Contains(c.origins, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Credentials require the origin to be echoed rather than *.
+		if c.anyOrigin && !c.credentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if c.credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", c.methods)
+			if c.headers != "" {
+				h.Set("Access-Control-Allow-Headers", c.headers)
+			}
+			if c.maxAge != "" {
+				h.Set("Access-Control-Max-Age", c.maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
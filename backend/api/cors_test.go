@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+)
+
+func serveCORS(cfg *config.API, req *http.Request) *httptest.ResponseRecorder {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	rec := httptest.NewRecorder()
+	newCORS(cfg).middleware(next).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORS(t *testing.T) {
+	require.Nil(t, newCORS(&config.API{}))
+
+	cfg := &config.API{
+		CORSOrigins: []string{"https://radar.example.com"},
+		CORSMethods: []string{"GET", "POST"},
+		CORSHeaders: []string{"Content-Type", "X-API-Key"},
+		CORSMaxAge:  10 * time.Minute,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/news", nil)
+	req.Header.Set("Origin", "https://radar.example.com")
+	rec := serveCORS(cfg, req)
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Equal(t, "https://radar.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, corsExposedHeaders, rec.Header().Get("Access-Control-Expose-Headers"))
+	require.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+	require.Equal(t, []string{"Origin"}, rec.Header().Values("Vary"))
+
+	// Other origins are served without CORS headers.
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec = serveCORS(cfg, req)
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, []string{"Origin"}, rec.Header().Values("Vary"))
+
+	req = httptest.NewRequest(http.MethodOptions, "/news", nil)
+	req.Header.Set("Origin", "https://radar.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec = serveCORS(cfg, req)
+	require.Equal(t, http.StatusNoContent, rec.Code, "preflights are answered before routing")
+	require.Equal(t, "GET, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "Content-Type, X-API-Key", rec.Header().Get("Access-Control-Allow-Headers"))
+	require.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+	require.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, rec.Header().Values("Vary"))
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/news", nil)
+	req.Header.Set("Origin", "https://radar.example.com")
+
+	rec := serveCORS(&config.API{CORSOrigins: []string{"*"}}, req)
+	require.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+
+	// Credentials require the origin to be echoed.
+	rec = serveCORS(&config.API{CORSOrigins: []string{"https://radar.example.com"}, CORSCredentials: true}, req)
+	require.Equal(t, "https://radar.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+}
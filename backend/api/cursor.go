@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+// cursorVersion is bumped when the token layout changes, so tokens minted
+// by an older release read as invalid rather than as a different query.
+const cursorVersion = 1
+
+// cursorToken is what a /news?cursor=true page hands out as Cursor. It
+// carries the search itself, so a token alone is a working deep link, and
+// the sort values of the page's last hit rather than an offset: documents
+// deleted by retention between pages shift nothing, and the walk resumes
+// after the last hit served even if that hit is gone. Tokens are not
+// signed; they only select what the caller could search for anyway.
+type cursorToken struct {
+	Version int             `json:"v"`
+	Query   string          `json:"q"`
+	After   json.RawMessage `json:"a"`
+	Expires int64           `json:"e"`
+}
+
+// cursorIgnoredParams are left out of a token: paging is the token's job,
+// and field selection and locale are chosen on every request.
+var cursorIgnoredParams = []string{"cursor", "pit", "after", "from", "relax", "fields", "locale"}
+
+func encodeCursorToken(query url.Values, after json.RawMessage, expires time.Time) string {
+	query = cloneValues(query)
+	for _, name := range cursorIgnoredParams {
+		query.Del(name)
+	}
+	data, _ := json.Marshal(cursorToken{
+		Version: cursorVersion,
+		Query:   query.Encode(),
+		After:   after,
+		Expires: expires.Unix(),
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursorToken(raw string) (cursorToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursorToken{}, err
+	}
+	var token cursorToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return cursorToken{}, err
+	}
+	if token.Version != cursorVersion {
+		return cursorToken{}, errors.New("unsupported cursor version")
+	}
+	var values []any
+	if err := json.Unmarshal(token.After, &values); err != nil || len(values) == 0 {
+		return cursorToken{}, errors.New("not a sort value list")
+	}
+	return token, nil
+}
+
+func cloneValues(values url.Values) url.Values {
+	clone := make(url.Values, len(values))
+	for name, list := range values {
+		clone[name] = append([]string(nil), list...)
+	}
+	return clone
+}
+
+// resolveCursor turns /news?cursor=<token> into the request the token was
+// minted from, so the search parameters are parsed as usual, and returns
+// the sort values to continue after. Other requests are returned as they
+// are. It writes the error response itself and reports false when the
+// token is unusable: 400 cursor_invalid when it cannot be read and 410
+// cursor_expired, with a link to start over, once API_CURSOR_TTL has
+// passed.
+func (s *server) resolveCursor(w http.ResponseWriter, r *http.Request, now time.Time) (*http.Request, json.RawMessage, bool) {
+	raw := r.URL.Query().Get("cursor")
+	if raw == "" || raw == "false" {
+		return r, nil, true
+	}
+	if r.URL.Query().Has("pit") {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "cursor cannot be combined with pit"})
+		return nil, nil, false
+	}
+	if raw == "true" {
+		return r, nil, true
+	}
+
+	token, err := decodeCursorToken(raw)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{
+			Error: "invalid cursor",
+			Code:  "cursor_invalid",
+			Hint:  "pass the Cursor of the previous page unchanged, or start again with cursor=true",
+		})
+		return nil, nil, false
+	}
+	query, err := url.ParseQuery(token.Query)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid cursor", Code: "cursor_invalid"})
+		return nil, nil, false
+	}
+	query.Set("cursor", "true")
+	if now.Unix() > token.Expires {
+		writeJSON(w, http.StatusGone, errorResponse{
+			Error: "cursor expired",
+			Code:  "cursor_expired",
+			Hint: "start again from /news?" + query.Encode() + "; to skip what you already have, " +
+				"add start set to the timestamp of the last document you received",
+		})
+		return nil, nil, false
+	}
+
+	query.Set("cursor", raw)
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = query.Encode()
+	return r, token.After, true
+}
+
+// searchCursor serves a /news page walked by sort values: cursor=true
+// returns the first page, and the Cursor of a page fetches the next.
+// Unlike pit it keeps no state and holds no snapshot, so pages see
+// documents indexed or deleted in the meantime. Every page with hits has a
+// Cursor and an empty page ends the walk; with sort=timestamp:asc a kept
+// Cursor thus also picks up documents indexed later, until it expires.
+func (s *server) searchCursor(w http.ResponseWriter, r *http.Request, params elasticsearch.SearchParams, facets facetRequest, after json.RawMessage) {
+	if len(facets.Fields) > 0 || facets.Histogram != "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "cursor cannot be combined with facets or histogram"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// As with pit, relaxing the query midway would change what the walk
+	// returns.
+	params.From, params.Relax = 0, false
+	params.Cursor = &elasticsearch.Cursor{After: after}
+	result, err := s.es.SearchNews(ctx, params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := searchResponse{SearchResult: result}
+	if len(result.Items) > 0 && len(result.SearchAfter) > 0 {
+		resp.Cursor = encodeCursorToken(r.URL.Query(), result.SearchAfter, time.Now().Add(s.cfg.CursorTTL))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
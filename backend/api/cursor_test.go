@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorTokenRoundTrip(t *testing.T) {
+	expires := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	query := url.Values{
+		"q":      {"турция"},
+		"sort":   {"timestamp:asc"},
+		"cursor": {"true"},
+		"fields": {"title"},
+		"from":   {"20"},
+		"locale": {"ru"},
+	}
+	after := json.RawMessage(`[1717243200000,"doc-1"]`)
+
+	token, err := decodeCursorToken(encodeCursorToken(query, after, expires))
+	require.NoError(t, err)
+	require.Equal(t, cursorVersion, token.Version)
+	require.Equal(t, "q=%D1%82%D1%83%D1%80%D1%86%D0%B8%D1%8F&sort=timestamp%3Aasc", token.Query, "paging, fields and locale are left out")
+	require.JSONEq(t, string(after), string(token.After))
+	require.Equal(t, expires.Unix(), token.Expires)
+	require.Equal(t, []string{"true"}, query["cursor"], "the caller's query is not modified")
+}
+
+func TestDecodeCursorTokenRejectsGarbage(t *testing.T) {
+	encode := func(token string) string { return base64.RawURLEncoding.EncodeToString([]byte(token)) }
+	for name, raw := range map[string]string{
+		"not base64":      "!!!",
+		"not json":        encode("hello"),
+		"other version":   encode(`{"v":2,"q":"q=a","a":[1],"e":1}`),
+		"no sort values":  encode(`{"v":1,"q":"q=a","a":[],"e":1}`),
+		"sort values map": encode(`{"v":1,"q":"q=a","a":{"x":1},"e":1}`),
+		"missing after":   encode(`{"v":1,"q":"q=a","e":1}`),
+	} {
+		_, err := decodeCursorToken(raw)
+		require.Error(t, err, name)
+	}
+}
+
+func TestResolveCursor(t *testing.T) {
+	srv := &server{}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	after := json.RawMessage(`[1717243200000,"doc-1"]`)
+	valid := encodeCursorToken(url.Values{"q": {"турция"}}, after, now.Add(time.Minute))
+	expired := encodeCursorToken(url.Values{"q": {"турция"}}, after, now.Add(-time.Second))
+	// Tokens are not signed, so only tampering that breaks them is caught.
+	truncated := valid[:len(valid)/2]
+	edited := base64.RawURLEncoding.EncodeToString([]byte(`{"v":1,"q":"q=a","a":"doc-1","e":9999999999}`))
+	badQuery := base64.RawURLEncoding.EncodeToString([]byte(`{"v":1,"q":"q=%zz","a":[1],"e":9999999999}`))
+
+	tests := []struct {
+		name     string
+		query    string
+		wantOK   bool
+		wantCode int
+		wantErr  string
+		// wantQuery is the query of the resolved request.
+		wantQuery string
+	}{
+		{name: "no cursor", query: "q=a", wantOK: true, wantQuery: "q=a"},
+		{name: "first page", query: "q=a&cursor=true", wantOK: true, wantQuery: "q=a&cursor=true"},
+		{name: "next page", query: "cursor=" + valid, wantOK: true, wantQuery: url.Values{"cursor": {valid}, "q": {"турция"}}.Encode()},
+		{name: "with pit", query: "cursor=true&pit=abc", wantCode: http.StatusBadRequest},
+		{name: "expired", query: "cursor=" + expired, wantCode: http.StatusGone, wantErr: "cursor_expired"},
+		{name: "truncated", query: "cursor=" + truncated, wantCode: http.StatusBadRequest, wantErr: "cursor_invalid"},
+		{name: "edited sort values", query: "cursor=" + edited, wantCode: http.StatusBadRequest, wantErr: "cursor_invalid"},
+		{name: "garbage", query: "cursor=garbage", wantCode: http.StatusBadRequest, wantErr: "cursor_invalid"},
+		{name: "unparsable query", query: "cursor=" + badQuery, wantCode: http.StatusBadRequest, wantErr: "cursor_invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/news?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			got, gotAfter, ok := srv.resolveCursor(rec, req, now)
+			require.Equal(t, tt.wantOK, ok)
+			if ok {
+				require.Equal(t, tt.wantQuery, got.URL.RawQuery)
+				if tt.name == "next page" {
+					require.JSONEq(t, string(after), string(gotAfter))
+				}
+				return
+			}
+			require.Equal(t, tt.wantCode, rec.Code)
+			var resp errorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			require.Equal(t, tt.wantErr, resp.Code)
+			require.NotEmpty(t, resp.Error)
+		})
+	}
+
+	// An expired cursor says how to start over.
+	rec := httptest.NewRecorder()
+	_, _, ok := srv.resolveCursor(rec, httptest.NewRequest(http.MethodGet, "/news?cursor="+expired, nil), now)
+	require.False(t, ok)
+	var resp errorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Contains(t, resp.Hint, "/news?cursor=true&q=%D1%82%D1%83%D1%80%D1%86%D0%B8%D1%8F")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/datafiles"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/destinations"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+)
+
+// referenceData is the worker data the API applies too: the taxonomy
+// destination filters are canonicalized with and the stages that decide
+// document IDs for /tools/fingerprint. It is replaced as a whole on reload.
+type referenceData struct {
+	taxonomy *destinations.Taxonomy
+	identity *processing.Pipeline
+}
+
+// loadReferenceData reads the taxonomy and title rules from
+// DESTINATIONS_FILE and WORKER_TITLE_RULES_FILE.
+func loadReferenceData(cfg *config.API) (*referenceData, error) {
+	taxonomy, err := destinations.Load(cfg.DestinationsFile)
+	if err != nil {
+		return nil, fmt.Errorf("load destination taxonomy: %w", err)
+	}
+	titleRules, err := processing.LoadTitleRules(cfg.TitleRulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("load title rules: %w", err)
+	}
+	identity, err := newIdentityPipeline(cfg, titleRules)
+	if err != nil {
+		return nil, fmt.Errorf("build identity pipeline: %w", err)
+	}
+	return &referenceData{taxonomy: taxonomy, identity: identity}, nil
+}
+
+// dataReloader reads the destinations and title rules from the worker's
+// data bucket. Ingestion rules only matter to the worker and are not read.
+type dataReloader struct {
+	log    *slog.Logger
+	cfg    *config.API
+	loader *datafiles.Loader
+}
+
+// newDataReloader reads from WORKER_DATA_BUCKET with the WORKER_DATA_*
+// credentials, or the standard AWS ones when those are unset.
+func newDataReloader(log *slog.Logger, cfg *config.API) (*dataReloader, error) {
+	store, err := datafiles.NewStore(cfg.DataEndpoint, cfg.DataRegion, cfg.DataBucket, cfg.DataAccessKeyID, cfg.DataSecretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	return &dataReloader{log: log, cfg: cfg, loader: newAPILoader(store, cfg.DataPrefix)}, nil
+}
+
+func newAPILoader(store datafiles.Getter, prefix string) *datafiles.Loader {
+	return datafiles.NewLoader(store, prefix, datafiles.Destinations, datafiles.TitleRules)
+}
+
+// reload fetches and verifies the data objects and stores the data built
+// from them in data unless nothing changed. A missing object falls back
+// to the built-in taxonomy or no title rules, as on the worker.
+func (d *dataReloader) reload(ctx context.Context, store func(*referenceData)) (datafiles.Result, error) {
+	res, err := d.loader.Load(ctx, func(bodies map[string][]byte) error {
+		data := &referenceData{taxonomy: destinations.Default()}
+		var (
+			titleRules *processing.TitleRules
+			err        error
+		)
+		if body := bodies[datafiles.Destinations]; body != nil {
+			if data.taxonomy, err = destinations.Parse(body); err != nil {
+				return fmt.Errorf("%w: %s: %v", datafiles.ErrInvalid, datafiles.Destinations, err)
+			}
+		}
+		if body := bodies[datafiles.TitleRules]; body != nil {
+			if titleRules, err = processing.ParseTitleRules(body); err != nil {
+				return fmt.Errorf("%w: %s: %v", datafiles.ErrInvalid, datafiles.TitleRules, err)
+			}
+		}
+		if data.identity, err = newIdentityPipeline(d.cfg, titleRules); err != nil {
+			return fmt.Errorf("%w: build identity pipeline: %v", datafiles.ErrInvalid, err)
+		}
+		store(data)
+		return nil
+	})
+	if err == nil && res.Changed {
+		d.log.InfoContext(ctx, "data loaded", slog.Any("objects", res.Objects))
+	}
+	return res, err
+}
+
+func (s *server) taxonomy() *destinations.Taxonomy {
+	return s.data.Load().taxonomy
+}
+
+func (s *server) identity() *processing.Pipeline {
+	return s.data.Load().identity
+}
+
+// handleReloadData re-reads the data objects from the bucket, like the
+// worker's endpoint of the same name. The current data stays when an
+// object cannot be fetched (502) or fails its checksum or parsing (422).
+func (s *server) handleReloadData(w http.ResponseWriter, r *http.Request) {
+	res, err := s.reloader.reload(r.Context(), s.data.Store)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, datafiles.ErrInvalid) {
+			status = http.StatusUnprocessableEntity
+		}
+		s.log.WarnContext(r.Context(), "data reload failed, keeping the current data", slog.Any("err", err))
+		writeJSON(w, status, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
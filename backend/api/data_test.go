@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/archive"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+)
+
+// stubBucket serves objects from a map; err, when set, fails every Get.
+type stubBucket struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (b *stubBucket) Get(_ context.Context, key string) ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	body, ok := b.objects[key]
+	if !ok {
+		return nil, archive.ErrNotFound
+	}
+	return body, nil
+}
+
+// put stores body under key together with its checksum object.
+func (b *stubBucket) put(key, body string) {
+	sum := sha256.Sum256([]byte(body))
+	b.objects[key] = []byte(body)
+	b.objects[key+".sha256"] = []byte(hex.EncodeToString(sum[:]) + "  " + key + "\n")
+}
+
+func cleanTitle(t *testing.T, srv *server, title string) string {
+	t.Helper()
+	item := &processing.Item{Doc: models.NewsDocument{Title: title, Text: "Кемер от 45 000", Source: "tg", Timestamp: time.Now()}}
+	require.NoError(t, srv.identity().Run(item))
+	return item.Doc.Title
+}
+
+func TestReloadData(t *testing.T) {
+	bucket := &stubBucket{objects: map[string][]byte{}}
+	bucket.put("data/destinations.json", `[{"name":"Кемер","aliases":["kemer"]}]`)
+	bucket.put("data/title_rules.json", `[{"source":"tg","suffixes":["| Горящие туры"]}]`)
+
+	log := slog.New(slog.DiscardHandler)
+	cfg := &config.API{}
+	cfg.Pipeline = []string{"title", "id"}
+	srv := &server{log: log, cfg: cfg, reloader: &dataReloader{log: log, cfg: cfg, loader: newAPILoader(bucket, "data/")}}
+
+	call := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.handleReloadData(rec, httptest.NewRequest(http.MethodPost, "/admin/reload-data", nil))
+		return rec
+	}
+
+	rec := call()
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"changed":true`)
+	require.Equal(t, "кемер", srv.taxonomy().Canonical("kemer"))
+	require.Equal(t, "Туры в Кемер", cleanTitle(t, srv, "Туры в Кемер | Горящие туры"))
+	loaded := srv.data.Load()
+
+	rec = call()
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"changed":false`)
+	require.Same(t, loaded, srv.data.Load())
+
+	// A taxonomy uploaded without its new checksum keeps the current data.
+	bucket.objects["data/destinations.json"] = []byte(`[]`)
+	rec = call()
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	require.Contains(t, rec.Body.String(), "checksum says")
+	require.Same(t, loaded, srv.data.Load())
+
+	bucket.put("data/destinations.json", `[{"name":"Кемер","aliases":["kemer"]}]`)
+	bucket.put("data/title_rules.json", "{")
+	rec = call()
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	require.Contains(t, rec.Body.String(), "title_rules.json")
+	require.Same(t, loaded, srv.data.Load())
+
+	bucket.err = errors.New("connection refused")
+	require.Equal(t, http.StatusBadGateway, call().Code)
+	require.Same(t, loaded, srv.data.Load())
+
+	// Without the objects the built-in taxonomy and no title rules apply.
+	bucket.err = nil
+	bucket.objects = map[string][]byte{}
+	require.Equal(t, http.StatusOK, call().Code)
+	require.Equal(t, "Туры в Кемер | Горящие туры", cleanTitle(t, srv, "Туры в Кемер | Горящие туры"))
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/destinations"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+type destinationResponse struct {
+	ID       string                `json:"id"`
+	Name     string                `json:"name"`
+	Aliases  []string              `json:"aliases,omitempty"`
+	Location *models.GeoPoint      `json:"location,omitempty"`
+	Children []destinationResponse `json:"children,omitempty"`
+}
+
+// handleDestinations returns the destination taxonomy used for tagging and
+// for the destination filter of /news.
+func (s *server) handleDestinations(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"destinations": destinationTree(s.taxonomy().Roots())})
+}
+
+func destinationTree(nodes []*destinations.Node) []destinationResponse {
+	out := make([]destinationResponse, 0, len(nodes))
+	for _, node := range nodes {
+		out = append(out, destinationResponse{
+			ID:       node.ID(),
+			Name:     node.Name,
+			Aliases:  node.Aliases,
+			Location: node.Location,
+			Children: destinationTree(node.Children),
+		})
+	}
+	return out
+}
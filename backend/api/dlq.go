@@ -0,0 +1,460 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// dlqReadTimeout bounds reading one partition, so a peek or replay
+	// answers within the server's write timeout.
+	dlqReadTimeout = 5 * time.Second
+	// maxDLQReplay is how many messages one replay request may requeue.
+	maxDLQReplay = 100
+	// dlqReplayGap is how many unrequested messages a replay reads through
+	// rather than seeking past them.
+	dlqReplayGap = 20
+	// dlqReplayTimeout bounds a whole replay request; the write deadline is
+	// extended past it so the outcome always reaches the caller.
+	dlqReplayTimeout = time.Minute
+	// replayedFromHeader marks a requeued message with the dead-letter
+	// position it was copied from, as "<topic>/<partition>/<offset>".
+	replayedFromHeader = "replayed_from"
+)
+
+// dlqHeaders are the headers the worker adds when it dead-letters a
+// message; they are reported separately and dropped on replay.
+var dlqHeaders = []string{"original_partition", "original_offset", "error", "error_class", "timestamp"}
+
+// deadLetters reads the configured dead-letter topics without joining a
+// consumer group and writes replayed messages back to their source topics.
+type deadLetters struct {
+	client  *kafka.Client
+	brokers []string
+	topics  []string
+	writer  *kafka.Writer
+}
+
+func newDeadLetters(brokers, topics []string) *deadLetters {
+	return &deadLetters{
+		client:  &kafka.Client{Addr: kafka.TCP(brokers...), Timeout: dlqReadTimeout},
+		brokers: brokers,
+		topics:  topics,
+		// No fixed topic: each replayed message names its source topic.
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			MaxAttempts:  3,
+			RequiredAcks: kafka.RequireAll,
+		},
+	}
+}
+
+func (d *deadLetters) close() error {
+	return d.writer.Close()
+}
+
+// topic resolves the topic query parameter; it may be omitted when a
+// single dead-letter topic is configured.
+func (d *deadLetters) topic(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" && len(d.topics) == 1 {
+		return d.topics[0], nil
+	}
+	if !slices.Contains(d.topics, raw) {
+		return "", fmt.Errorf("topic must be one of %s", strings.Join(d.topics, ", "))
+	}
+	return raw, nil
+}
+
+// bounds returns the first and the next offset of every partition of topic.
+func (d *deadLetters) bounds(ctx context.Context, topic string) ([]kafka.PartitionOffsets, error) {
+	meta, err := d.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, err
+	}
+	if len(meta.Topics) == 0 {
+		return nil, fmt.Errorf("topic %q not found", topic)
+	}
+	if err := meta.Topics[0].Error; err != nil {
+		return nil, err
+	}
+
+	requests := make([]kafka.OffsetRequest, 0, 2*len(meta.Topics[0].Partitions))
+	for _, p := range meta.Topics[0].Partitions {
+		requests = append(requests, kafka.FirstOffsetOf(p.ID), kafka.LastOffsetOf(p.ID))
+	}
+	offsets, err := d.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: requests}})
+	if err != nil {
+		return nil, err
+	}
+	var bounds []kafka.PartitionOffsets
+	for _, p := range offsets.Topics[topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("partition %d: %w", p.Partition, p.Error)
+		}
+		bounds = append(bounds, p)
+	}
+	return bounds, nil
+}
+
+// read returns the messages of a partition from offset from up to, not
+// including, offset to.
+func (d *deadLetters) read(ctx context.Context, topic string, partition int, from, to int64) ([]kafka.Message, error) {
+	reader := d.reader(topic, partition)
+	defer reader.Close()
+	return readRange(ctx, reader, from, to)
+}
+
+// reader opens a partition without a consumer group; readRange seeks it.
+func (d *deadLetters) reader(topic string, partition int) *kafka.Reader {
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   d.brokers,
+		Topic:     topic,
+		Partition: partition,
+		MaxBytes:  10e6,
+		MaxWait:   time.Second,
+	})
+}
+
+// readRange reads the messages from offset from up to, not including,
+// offset to with reader, which may have read another range before.
+func readRange(ctx context.Context, reader *kafka.Reader, from, to int64) ([]kafka.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, dlqReadTimeout)
+	defer cancel()
+
+	if err := reader.SetOffset(from); err != nil {
+		return nil, err
+	}
+
+	msgs := make([]kafka.Message, 0, to-from)
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && len(msgs) > 0 {
+				// Compacted or transactional topics may have gaps up to the end.
+				return msgs, nil
+			}
+			return nil, err
+		}
+		if msg.Offset >= to {
+			return msgs, nil
+		}
+		msgs = append(msgs, msg)
+		if msg.Offset == to-1 {
+			return msgs, nil
+		}
+	}
+}
+
+// dlqMessage is a dead-lettered message with the worker's error headers
+// pulled out of the rest.
+type dlqMessage struct {
+	Partition         int               `json:"partition"`
+	Offset            int64             `json:"offset"`
+	Time              time.Time         `json:"time"`
+	Key               string            `json:"key,omitempty"`
+	Error             string            `json:"error,omitempty"`
+	ErrorClass        string            `json:"error_class,omitempty"`
+	OriginalPartition *int              `json:"original_partition,omitempty"`
+	OriginalOffset    *int64            `json:"original_offset,omitempty"`
+	Headers           map[string]string `json:"headers,omitempty"`
+	Value             string            `json:"value"`
+}
+
+func newDLQMessage(msg kafka.Message) dlqMessage {
+	m := dlqMessage{Partition: msg.Partition, Offset: msg.Offset, Time: msg.Time.UTC(), Key: string(msg.Key), Value: string(msg.Value)}
+	for _, h := range msg.Headers {
+		value := string(h.Value)
+		switch h.Key {
+		case "error":
+			m.Error = value
+		case "error_class":
+			m.ErrorClass = value
+		case "original_partition":
+			if p, err := strconv.Atoi(value); err == nil {
+				m.OriginalPartition = &p
+			}
+		case "original_offset":
+			if o, err := strconv.ParseInt(value, 10, 64); err == nil {
+				m.OriginalOffset = &o
+			}
+		case "timestamp":
+			// msg.Time is when the message was dead-lettered already.
+		default:
+			if m.Headers == nil {
+				m.Headers = make(map[string]string)
+			}
+			m.Headers[h.Key] = value
+		}
+	}
+	return m
+}
+
+type dlqPeekResponse struct {
+	Topic string `json:"topic"`
+	// Total counts the messages retained in the topic.
+	Total int64        `json:"total"`
+	Items []dlqMessage `json:"items"`
+}
+
+// handleDLQPeek returns the newest limit messages of a dead-letter topic
+// across its partitions, newest first. Nothing is consumed or committed.
+func (s *server) handleDLQPeek(w http.ResponseWriter, r *http.Request) {
+	topic, err := s.dlq.topic(r.URL.Query().Get("topic"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	limit := clampInt(r.URL.Query().Get("limit"), 20, 100)
+
+	bounds, err := s.dlq.bounds(r.Context(), topic)
+	if err != nil {
+		s.log.ErrorContext(r.Context(), "dlq offsets", slog.String("topic", topic), slog.Any("err", err))
+		writeError(w, err)
+		return
+	}
+
+	resp := dlqPeekResponse{Topic: topic, Items: []dlqMessage{}}
+	for _, b := range bounds {
+		resp.Total += b.LastOffset - b.FirstOffset
+		from := max(b.FirstOffset, b.LastOffset-int64(limit))
+		if from >= b.LastOffset {
+			continue
+		}
+		msgs, err := s.dlq.read(r.Context(), topic, b.Partition, from, b.LastOffset)
+		if err != nil {
+			s.log.ErrorContext(r.Context(), "dlq read", slog.String("topic", topic), slog.Int("partition", b.Partition), slog.Any("err", err))
+			writeError(w, fmt.Errorf("read partition %d: %w", b.Partition, err))
+			return
+		}
+		for _, msg := range msgs {
+			resp.Items = append(resp.Items, newDLQMessage(msg))
+		}
+	}
+	slices.SortFunc(resp.Items, func(a, b dlqMessage) int {
+		if c := b.Time.Compare(a.Time); c != 0 {
+			return c
+		}
+		return cmp.Or(cmp.Compare(a.Partition, b.Partition), cmp.Compare(b.Offset, a.Offset))
+	})
+	if len(resp.Items) > limit {
+		resp.Items = resp.Items[:limit]
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type dlqPosition struct {
+	Partition int   `json:"partition"`
+	Offset    int64 `json:"offset"`
+}
+
+type dlqReplayRequest struct {
+	Topic    string        `json:"topic"`
+	Messages []dlqPosition `json:"messages"`
+}
+
+type dlqReplayResult struct {
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Replayed  bool   `json:"replayed"`
+	Error     string `json:"error,omitempty"`
+}
+
+type dlqReplayResponse struct {
+	Topic string `json:"topic"`
+	// Target is the source topic the messages were written back to.
+	Target   string            `json:"target"`
+	Replayed int               `json:"replayed"`
+	Items    []dlqReplayResult `json:"items"`
+}
+
+// handleDLQReplay writes the selected dead-lettered messages back to their
+// source topic, with their key and original headers, so the worker
+// processes them again. The dead-letter copies stay where they are.
+func (s *server) handleDLQReplay(w http.ResponseWriter, r *http.Request) {
+	var req dlqReplayRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: " + err.Error()})
+		return
+	}
+	topic, err := s.dlq.topic(req.Topic)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if len(req.Messages) == 0 || len(req.Messages) > maxDLQReplay {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("messages must list between 1 and %d positions", maxDLQReplay)})
+		return
+	}
+
+	// Reading and writing up to maxDLQReplay messages can outlast the
+	// server's write timeout, and a replay whose outcome is lost would be
+	// retried and requeue the messages twice.
+	ctx, cancel := context.WithTimeout(r.Context(), dlqReplayTimeout)
+	defer cancel()
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(dlqReplayTimeout + 5*time.Second))
+
+	bounds, err := s.dlq.bounds(ctx, topic)
+	if err != nil {
+		s.log.ErrorContext(ctx, "dlq offsets", slog.String("topic", topic), slog.Any("err", err))
+		writeError(w, err)
+		return
+	}
+	partitions := make(map[int]kafka.PartitionOffsets, len(bounds))
+	for _, b := range bounds {
+		partitions[b.Partition] = b
+	}
+
+	target := strings.TrimSuffix(topic, "_dlq")
+	resp := dlqReplayResponse{Topic: topic, Target: target, Items: make([]dlqReplayResult, len(req.Messages))}
+	wanted := make(map[int][]int64)
+	listed := make(map[dlqPosition]bool, len(req.Messages))
+	for i, pos := range req.Messages {
+		resp.Items[i] = dlqReplayResult{Partition: pos.Partition, Offset: pos.Offset}
+		b, ok := partitions[pos.Partition]
+		switch {
+		case !ok:
+			resp.Items[i].Error = "no such partition"
+		case pos.Offset < b.FirstOffset || pos.Offset >= b.LastOffset:
+			resp.Items[i].Error = fmt.Sprintf("offset out of range [%d, %d)", b.FirstOffset, b.LastOffset)
+		case listed[pos]:
+			resp.Items[i].Error = "position listed twice"
+		default:
+			listed[pos] = true
+			wanted[pos.Partition] = append(wanted[pos.Partition], pos.Offset)
+		}
+	}
+
+	read, readErrs := s.dlq.readPositions(ctx, topic, wanted)
+	var (
+		msgs    []kafka.Message
+		indexes []int
+	)
+	for i, pos := range req.Messages {
+		if resp.Items[i].Error != "" {
+			continue
+		}
+		msg, ok := read[pos]
+		if !ok {
+			resp.Items[i].Error = "message not found"
+			if err := readErrs[pos]; err != nil {
+				resp.Items[i].Error = err.Error()
+			}
+			continue
+		}
+		msgs = append(msgs, replayMessage(target, msg))
+		indexes = append(indexes, i)
+	}
+
+	if len(msgs) > 0 {
+		err := s.dlq.writer.WriteMessages(ctx, msgs...)
+		var writeErrs kafka.WriteErrors
+		switch {
+		case errors.As(err, &writeErrs):
+			for j, i := range indexes {
+				if writeErrs[j] != nil {
+					resp.Items[i].Error = writeErrs[j].Error()
+				}
+			}
+		case err != nil:
+			for _, i := range indexes {
+				resp.Items[i].Error = err.Error()
+			}
+		}
+		for _, i := range indexes {
+			if resp.Items[i].Error == "" {
+				resp.Items[i].Replayed = true
+				resp.Replayed++
+			}
+		}
+	}
+
+	s.log.InfoContext(r.Context(), "dlq replay",
+		slog.String("topic", topic),
+		slog.String("target", target),
+		slog.Int("requested", len(req.Messages)),
+		slog.Int("replayed", resp.Replayed),
+	)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// readPositions reads the wanted offsets of each partition with one reader
+// per partition, in ranges of nearby offsets. Positions of a range that
+// could not be read get its error.
+func (d *deadLetters) readPositions(ctx context.Context, topic string, wanted map[int][]int64) (map[dlqPosition]kafka.Message, map[dlqPosition]error) {
+	read := make(map[dlqPosition]kafka.Message)
+	errs := make(map[dlqPosition]error)
+	for partition, offsets := range wanted {
+		reader := d.reader(topic, partition)
+		for _, run := range offsetRanges(offsets, dlqReplayGap) {
+			msgs, err := readRange(ctx, reader, run.from, run.to)
+			if err != nil {
+				for _, offset := range run.offsets {
+					errs[dlqPosition{Partition: partition, Offset: offset}] = err
+				}
+				continue
+			}
+			for _, msg := range msgs {
+				if slices.Contains(run.offsets, msg.Offset) {
+					read[dlqPosition{Partition: partition, Offset: msg.Offset}] = msg
+				}
+			}
+		}
+		reader.Close()
+	}
+	return read, errs
+}
+
+// offsetRange is a span [from, to) of a partition read in one pass for the
+// requested offsets in it.
+type offsetRange struct {
+	from, to int64
+	offsets  []int64
+}
+
+// offsetRanges groups distinct offsets into ranges, starting a new range
+// where more than gap offsets separate two requested ones.
+func offsetRanges(offsets []int64, gap int64) []offsetRange {
+	offsets = slices.Clone(offsets)
+	slices.Sort(offsets)
+	var ranges []offsetRange
+	for _, offset := range offsets {
+		if n := len(ranges); n > 0 && offset-ranges[n-1].to <= gap {
+			ranges[n-1].to = offset + 1
+			ranges[n-1].offsets = append(ranges[n-1].offsets, offset)
+			continue
+		}
+		ranges = append(ranges, offsetRange{from: offset, to: offset + 1, offsets: []int64{offset}})
+	}
+	return ranges
+}
+
+// replayMessage copies a dead-lettered message for its source topic: the
+// worker's error headers are dropped and replayed_from is set instead, so
+// a message that fails again is dead-lettered with fresh ones.
+func replayMessage(target string, msg kafka.Message) kafka.Message {
+	out := kafka.Message{Topic: target, Key: msg.Key, Value: msg.Value}
+	for _, h := range msg.Headers {
+		if !slices.Contains(dlqHeaders, h.Key) && h.Key != replayedFromHeader {
+			out.Headers = append(out.Headers, h)
+		}
+	}
+	out.Headers = append(out.Headers, kafka.Header{
+		Key:   replayedFromHeader,
+		Value: []byte(fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset)),
+	})
+	return out
+}
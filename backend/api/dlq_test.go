@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffsetRanges(t *testing.T) {
+	require.Empty(t, offsetRanges(nil, 20))
+
+	require.Equal(t, []offsetRange{
+		{from: 3, to: 8, offsets: []int64{3, 4, 7}},
+		{from: 40, to: 41, offsets: []int64{40}},
+		{from: 1000, to: 1021, offsets: []int64{1000, 1020}},
+	}, offsetRanges([]int64{1020, 7, 40, 3, 1000, 4}, 20))
+
+	// Without a gap every run of consecutive offsets is its own range.
+	require.Equal(t, []offsetRange{
+		{from: 1, to: 3, offsets: []int64{1, 2}},
+		{from: 4, to: 5, offsets: []int64{4}},
+	}, offsetRanges([]int64{1, 2, 4}, 0))
+}
+
+func TestReplayMessage(t *testing.T) {
+	msg := kafka.Message{
+		Topic:     "news_raw_dlq",
+		Partition: 2,
+		Offset:    17,
+		Key:       []byte("k"),
+		Value:     []byte(`{"text":"Кемер"}`),
+		Headers: []kafka.Header{
+			{Key: "error", Value: []byte("boom")},
+			{Key: "error_class", Value: []byte("processing")},
+			{Key: "idempotency_key", Value: []byte("k")},
+			{Key: replayedFromHeader, Value: []byte("news_raw_dlq/0/1")},
+		},
+	}
+	out := replayMessage("news_raw", msg)
+	require.Equal(t, "news_raw", out.Topic)
+	require.Equal(t, msg.Key, out.Key)
+	require.Equal(t, msg.Value, out.Value)
+	require.Equal(t, []kafka.Header{
+		{Key: "idempotency_key", Value: []byte("k")},
+		{Key: replayedFromHeader, Value: []byte("news_raw_dlq/2/17")},
+	}, out.Headers)
+}
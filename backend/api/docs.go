@@ -0,0 +1,25 @@
+package main
+
+import (
+	"embed"
+	"net/http"
+)
+
+// docsFiles holds the hand-maintained OpenAPI description of every route
+// registered in main and a Swagger UI page rendering it. Update
+// docs/openapi.json together with any change to routes, parameters or
+// response shapes.
+//
+//go:embed docs
+var docsFiles embed.FS
+
+// handleOpenAPI serves the OpenAPI 3 document.
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, docsFiles, "docs/openapi.json")
+}
+
+// handleDocs serves the Swagger UI page. Its scripts load from a CDN, so
+// the page needs internet access in the browser.
+func handleDocs(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, docsFiles, "docs/index.html")
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+const (
+	armControl   = "control"
+	armTreatment = "treatment"
+	// experimentHeader tells clients which arm served them, as
+	// "<experiment>=<arm>".
+	experimentHeader = "X-Experiment"
+)
+
+// experiment is a ranking A/B test: a stable share of clients, the
+// treatment arm, gets alternate popularity weights in relevance sort while
+// everyone else keeps API_RANK_SEEN_WEIGHT and API_RANK_CLICK_WEIGHT.
+// /news searches and redirect clicks of both arms are recorded with their
+// arm, so the weights can be compared before they are rolled out.
+type experiment struct {
+	name      string
+	percent   int
+	treatment elasticsearch.PopularityBoost
+
+	mu       sync.Mutex
+	searches map[string]int64
+}
+
+type experimentState struct {
+	Name     string           `json:"name"`
+	Percent  int              `json:"percent"`
+	Searches map[string]int64 `json:"searches"`
+}
+
+// newExperiment returns nil when no experiment is configured.
+func newExperiment(cfg *config.API) *experiment {
+	if cfg.Experiment == "" {
+		return nil
+	}
+	return &experiment{
+		name:      cfg.Experiment,
+		percent:   cfg.ExperimentPercent,
+		treatment: elasticsearch.PopularityBoost{SeenWeight: cfg.ExperimentRankSeenWeight, ClickWeight: cfg.ExperimentRankClickWeight},
+		searches:  map[string]int64{armControl: 0, armTreatment: 0},
+	}
+}
+
+// arm buckets the caller of r, or returns "" without an experiment. The
+// bucket is a hash of the experiment name and the X-API-Key, or of the
+// client address and User-Agent for requests without one, so a client
+// stays in its arm across requests while a new experiment reshuffles
+// clients.
+func (e *experiment) arm(r *http.Request) string {
+	if e == nil {
+		return ""
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(e.name))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(experimentClient(r)))
+	if int(h.Sum32()%100) < e.percent {
+		return armTreatment
+	}
+	return armControl
+}
+
+func experimentClient(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get(apiKeyHeader)); key != "" {
+		return "key:" + key
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// realIP sets RemoteAddr to the bare forwarded address.
+		host = r.RemoteAddr
+	}
+	return "ip:" + host + "|" + r.UserAgent()
+}
+
+// vars is the expvar.Func behind experiment.
+func (e *experiment) vars() any {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	searches := make(map[string]int64, len(e.searches))
+	for arm, n := range e.searches {
+		searches[arm] = n
+	}
+	return experimentState{Name: e.name, Percent: e.percent, Searches: searches}
+}
+
+// popularity returns the ranking weights for the caller of r.
+func (s *server) popularity(r *http.Request) elasticsearch.PopularityBoost {
+	if s.experiment.arm(r) == armTreatment {
+		return s.experiment.treatment
+	}
+	return elasticsearch.PopularityBoost{SeenWeight: s.cfg.RankSeenWeight, ClickWeight: s.cfg.RankClickWeight}
+}
+
+// recordSearch stores a /news search with the arm that served it. Like
+// clicks, it is written in the background so the response never waits for
+// it. It does nothing without an experiment.
+func (s *server) recordSearch(r *http.Request, params elasticsearch.SearchParams, result *searchResponse) {
+	arm := s.experiment.arm(r)
+	if arm == "" {
+		return
+	}
+	s.experiment.mu.Lock()
+	s.experiment.searches[arm]++
+	s.experiment.mu.Unlock()
+
+	event := models.QueryEvent{
+		Query:       params.Query,
+		Keywords:    params.Keywords,
+		Source:      params.Source,
+		Destination: params.Destination,
+		Sort:        params.Sort,
+		From:        params.From,
+		Total:       result.Total,
+		Relaxed:     result.Relaxed,
+		DocumentIDs: make([]string, 0, len(result.Items)),
+		Experiment:  s.experiment.name,
+		Arm:         arm,
+		Timestamp:   time.Now().UTC(),
+	}
+	for _, doc := range result.Items {
+		event.DocumentIDs = append(event.DocumentIDs, doc.ID)
+	}
+	go func() {
+		recordCtx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+		defer cancel()
+		if err := s.es.RecordQuery(recordCtx, event); err != nil {
+			s.log.WarnContext(recordCtx, "record query event", slog.String("arm", arm), slog.Any("err", err))
+		}
+	}()
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func newTestExperiment(percent int) *experiment {
+	return newExperiment(&config.API{Experiment: "rank-v2", ExperimentPercent: percent, ExperimentRankSeenWeight: 1, ExperimentRankClickWeight: 2})
+}
+
+func experimentRequest(key, remoteAddr, userAgent string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/news", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("User-Agent", userAgent)
+	if key != "" {
+		req.Header.Set(apiKeyHeader, key)
+	}
+	return req
+}
+
+func TestExperimentArmIsStable(t *testing.T) {
+	require.Nil(t, newExperiment(&config.API{}))
+	var none *experiment
+	require.Empty(t, none.arm(experimentRequest("", "192.0.2.1:4000", "curl")))
+
+	e := newTestExperiment(50)
+	for i := range 50 {
+		key := fmt.Sprintf("reader-%d", i)
+		arm := e.arm(experimentRequest(key, "192.0.2.1:4000", "curl"))
+		// A key keeps its arm from any address and client.
+		require.Equal(t, arm, e.arm(experimentRequest(key, "198.51.100.7:5000", "firefox")), key)
+		require.Equal(t, arm, newTestExperiment(50).arm(experimentRequest(key, "192.0.2.1:4000", "curl")), key)
+
+		// Without a key the address and User-Agent decide, not the port.
+		ip := fmt.Sprintf("192.0.2.%d", i)
+		arm = e.arm(experimentRequest("", ip+":4000", "curl"))
+		require.Equal(t, arm, e.arm(experimentRequest("", ip+":5000", "curl")), ip)
+		require.Equal(t, arm, e.arm(experimentRequest("", ip, "curl")), ip)
+	}
+
+	// A new experiment reshuffles clients.
+	other := newExperiment(&config.API{Experiment: "rank-v3", ExperimentPercent: 50})
+	moved := 0
+	for i := range 100 {
+		req := experimentRequest(fmt.Sprintf("reader-%d", i), "192.0.2.1:4000", "curl")
+		if e.arm(req) != other.arm(req) {
+			moved++
+		}
+	}
+	require.NotZero(t, moved)
+}
+
+func TestExperimentArmRespectsPercent(t *testing.T) {
+	for _, percent := range []int{0, 10, 50, 100} {
+		e := newTestExperiment(percent)
+		treated := 0
+		const clients = 2000
+		for i := range clients {
+			if e.arm(experimentRequest(fmt.Sprintf("reader-%d", i), "192.0.2.1:4000", "curl")) == armTreatment {
+				treated++
+			}
+		}
+		switch percent {
+		case 0:
+			require.Zero(t, treated)
+		case 100:
+			require.Equal(t, clients, treated)
+		default:
+			require.InDelta(t, percent*clients/100, treated, clients*0.03, "percent %d", percent)
+		}
+	}
+}
+
+// experimentES answers every search with one hit and records query events.
+type experimentES struct {
+	mu     sync.Mutex
+	events []models.QueryEvent
+}
+
+func (f *experimentES) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasSuffix(r.URL.Path, "/_search") {
+		_, _ = io.WriteString(w, `{"hits":{"total":{"value":1},"hits":[{"_id":"doc-1","_source":{"id":"doc-1","title":"Анталья"}}]}}`)
+		return
+	}
+	var event models.QueryEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err == nil {
+		f.mu.Lock()
+		f.events = append(f.events, event)
+		f.mu.Unlock()
+	}
+	w.WriteHeader(http.StatusCreated)
+	_, _ = io.WriteString(w, `{"result":"created"}`)
+}
+
+func (f *experimentES) recorded() []models.QueryEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]models.QueryEvent(nil), f.events...)
+}
+
+func TestSearchRecordsExperimentArm(t *testing.T) {
+	es := &experimentES{}
+	httpSrv := httptest.NewServer(es)
+	t.Cleanup(httpSrv.Close)
+	client, err := elasticsearch.New(httpSrv.URL, "news", nil)
+	require.NoError(t, err)
+
+	e := newTestExperiment(50)
+	srv := &server{log: slog.New(slog.DiscardHandler), cfg: &config.API{DefaultPage: 20, MaxPage: 100}, es: client, experiment: e}
+	r := chi.NewRouter()
+	r.Get("/news", srv.handleSearch)
+
+	// Find a reader in each arm.
+	readers := map[string]string{}
+	for i := 0; len(readers) < 2; i++ {
+		key := fmt.Sprintf("reader-%d", i)
+		readers[e.arm(experimentRequest(key, "192.0.2.1:4000", "curl"))] = key
+	}
+
+	want := map[string]int64{armControl: 0, armTreatment: 0}
+	for _, arm := range []string{armControl, armTreatment, armTreatment} {
+		req := experimentRequest(readers[arm], "192.0.2.1:4000", "curl")
+		req.URL.RawQuery = "q=турция"
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.Equal(t, "rank-v2="+arm, rec.Header().Get(experimentHeader))
+		want[arm]++
+	}
+	require.Equal(t, experimentState{Name: "rank-v2", Percent: 50, Searches: want}, e.vars())
+
+	require.Eventually(t, func() bool { return len(es.recorded()) == 3 }, time.Second, time.Millisecond)
+	arms := map[string]int64{}
+	for _, event := range es.recorded() {
+		require.Equal(t, "rank-v2", event.Experiment)
+		require.Equal(t, []string{"doc-1"}, event.DocumentIDs)
+		arms[event.Arm]++
+	}
+	require.Equal(t, want, arms)
+
+	// Without an experiment nothing is marked or recorded.
+	srv.experiment = nil
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, experimentRequest(readers[armTreatment], "192.0.2.1:4000", "curl"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get(experimentHeader))
+	time.Sleep(20 * time.Millisecond)
+	require.Len(t, es.recorded(), 3)
+}
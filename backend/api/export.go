@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+const (
+	// exportBatchSize is the number of documents fetched per scroll page.
+	exportBatchSize = 500
+	// exportTimeout bounds a whole export; each page extends the write
+	// deadline, which the server otherwise sets for small responses.
+	exportTimeout = 10 * time.Minute
+	// exportPageDeadline is the write deadline granted per page.
+	exportPageDeadline = time.Minute
+)
+
+// exportColumns are the CSV columns; list values are joined by exportListSeparator.
+var exportColumns = []string{
+	"id", "timestamp", "source", "title", "text", "urls", "keywords", "destinations",
+	"mentions", "price", "travel_start", "travel_end", "expires_at", "status", "cluster_id",
+}
+
+const exportListSeparator = " | "
+
+// handleExport streams every document matching the /news filters as CSV or
+// NDJSON. It scrolls through the index instead of paging, so it is not
+// limited to the first 10 000 hits; sort and paging parameters are ignored
+// and documents come in index order.
+func (s *server) handleExport(w http.ResponseWriter, r *http.Request) {
+	format := strings.TrimSpace(r.URL.Query().Get("format"))
+	if format != "csv" && format != "ndjson" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "format must be csv or ndjson"})
+		return
+	}
+	params, err := s.parseSearchParams(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if r.URL.Query().Get("unseen_only") == "true" {
+		reader, ok := readerID(r)
+		if !ok {
+			writeMissingAPIKey(w)
+			return
+		}
+		params.UnseenBy = reader
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+	defer cancel()
+
+	mask := maskFor(w)
+	rc := http.NewResponseController(w)
+	var enc exportEncoder
+	exported := 0
+	err = s.es.ExportNews(ctx, params, exportBatchSize, func(docs []models.NewsDocument) error {
+		if enc == nil {
+			// Headers go out with the first page, so a failing query still
+			// gets an error status.
+			enc = newExportEncoder(w, format)
+		}
+		_ = rc.SetWriteDeadline(time.Now().Add(exportPageDeadline))
+		for _, doc := range docs {
+			if err := enc.write(mask.Document(doc)); err != nil {
+				return err
+			}
+		}
+		exported += len(docs)
+		if err := enc.flush(); err != nil {
+			return err
+		}
+		_ = rc.Flush()
+		return nil
+	})
+	if enc == nil && err == nil {
+		enc = newExportEncoder(w, format)
+		err = enc.flush()
+	}
+	if err != nil {
+		if enc == nil {
+			writeError(w, err)
+			return
+		}
+		// The status is already sent; the client sees a truncated body.
+		s.log.WarnContext(r.Context(), "export interrupted", slog.Int("exported", exported), slog.Any("err", err))
+	}
+}
+
+// exportEncoder writes documents in one export format.
+type exportEncoder interface {
+	write(doc models.NewsDocument) error
+	flush() error
+}
+
+// newExportEncoder sends the response headers and, for CSV, the header row.
+func newExportEncoder(w http.ResponseWriter, format string) exportEncoder {
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="news.csv"`)
+		w.WriteHeader(http.StatusOK)
+		// The byte order mark makes spreadsheets read the file as UTF-8.
+		_, _ = io.WriteString(w, "\ufeff")
+		enc := csvExport{w: csv.NewWriter(w)}
+		_ = enc.w.Write(exportColumns)
+		return enc
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="news.ndjson"`)
+	w.WriteHeader(http.StatusOK)
+	return ndjsonExport{enc: json.NewEncoder(w)}
+}
+
+type ndjsonExport struct {
+	enc *json.Encoder
+}
+
+func (e ndjsonExport) write(doc models.NewsDocument) error { return e.enc.Encode(doc) }
+
+func (ndjsonExport) flush() error { return nil }
+
+type csvExport struct {
+	w *csv.Writer
+}
+
+func (e csvExport) write(doc models.NewsDocument) error {
+	price := ""
+	if doc.Price > 0 {
+		price = strconv.Itoa(doc.Price)
+	}
+	return e.w.Write([]string{
+		doc.ID,
+		formatExportTime(doc.Timestamp),
+		doc.Source,
+		doc.Title,
+		doc.Text,
+		strings.Join(doc.URLs, exportListSeparator),
+		strings.Join(doc.Keywords, exportListSeparator),
+		strings.Join(doc.Destinations, exportListSeparator),
+		strings.Join(doc.Mentions, exportListSeparator),
+		price,
+		formatExportTime(doc.TravelStart),
+		formatExportTime(doc.TravelEnd),
+		formatExportTime(doc.ExpiresAt),
+		doc.Status,
+		doc.ClusterID,
+	})
+}
+
+func (e csvExport) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
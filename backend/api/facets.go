@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+// searchResponse is a /news result with the facets and histogram the
+// request asked for, so a dashboard gets all three in one round trip.
+// Point-in-time pages carry the snapshot handle and the cursor of the next
+// page instead.
+type searchResponse struct {
+	*elasticsearch.SearchResult
+	Facets    map[string][]elasticsearch.TermCount `json:",omitempty"`
+	Histogram []elasticsearch.TrendBucket          `json:",omitempty"`
+	PIT       string                               `json:",omitempty"`
+	Next      string                               `json:",omitempty"`
+	Cursor    string                               `json:",omitempty"`
+}
+
+// facetRequest holds the /news facets, facet_size and histogram parameters.
+type facetRequest struct {
+	Fields    []string
+	Size      int
+	Histogram string
+}
+
+func parseFacetRequest(r *http.Request) (facetRequest, error) {
+	req := facetRequest{
+		Fields:    parseCSV(r.URL.Query().Get("facets")),
+		Size:      clampInt(r.URL.Query().Get("facet_size"), 10, elasticsearch.MaxAggregationSize),
+		Histogram: strings.TrimSpace(r.URL.Query().Get("histogram")),
+	}
+	for _, field := range req.Fields {
+		if !slices.Contains(elasticsearch.AggregationFields, field) {
+			return facetRequest{}, fmt.Errorf("facets must be among %s", strings.Join(elasticsearch.AggregationFields, ", "))
+		}
+	}
+	if _, ok := trendIntervals[req.Histogram]; req.Histogram != "" && !ok {
+		return facetRequest{}, errors.New("histogram must be hour or day")
+	}
+	return req, nil
+}
+
+// requested reports whether the request asked for facets or a histogram.
+func (f facetRequest) requested() bool {
+	return len(f.Fields) > 0 || f.Histogram != ""
+}
+
+// search runs the hits query together with the requested facets and
+// histogram as parallel Elasticsearch requests sharing ctx; the first
+// failure cancels the others. Facets and histogram count the documents
+// matching params as given, even when the hits had to be relaxed.
+func (s *server) search(ctx context.Context, params elasticsearch.SearchParams, facets facetRequest) (*searchResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		resp     searchResponse
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	run := func(fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+
+	run(func() (err error) {
+		resp.SearchResult, err = s.es.SearchNews(ctx, params)
+		return err
+	})
+	if len(facets.Fields) > 0 {
+		run(func() error {
+			result, err := s.es.AggregateNews(ctx, params, facets.Fields, facets.Size*conceptOverfetch)
+			if err != nil {
+				return err
+			}
+			resp.Facets = s.trimBuckets(result.Buckets, facets.Size)
+			return nil
+		})
+	}
+	if facets.Histogram != "" {
+		run(func() (err error) {
+			resp.Histogram, err = s.es.NewsHistogram(ctx, params, facets.Histogram)
+			return err
+		})
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return &resp, nil
+}
+
+// trimBuckets merges keyword buckets into concepts and cuts every field to
+// size values.
+func (s *server) trimBuckets(buckets map[string][]elasticsearch.TermCount, size int) map[string][]elasticsearch.TermCount {
+	for field, counts := range buckets {
+		if field == "keywords" {
+			counts = mergeConcepts(counts, s.concepts)
+		}
+		buckets[field] = counts[:min(len(counts), size)]
+	}
+	return buckets
+}
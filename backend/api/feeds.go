@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+const (
+	// feedSize is the number of items rendered into a feed.
+	feedSize = 50
+	// trendingWindow is how far back the trending feed looks.
+	trendingWindow = 24 * time.Hour
+)
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link,omitempty"`
+	Description string   `xml:"description"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// handleTrendingFeed renders the most popular documents of the last day as RSS.
+func (s *server) handleTrendingFeed(w http.ResponseWriter, r *http.Request) {
+	start := time.Now().UTC().Add(-trendingWindow)
+	params := elasticsearch.SearchParams{
+		Size:       feedSize,
+		Sort:       "relevance",
+		Start:      &start,
+		Popularity: s.popularity(r),
+	}
+	s.writeFeed(w, r, params, "Hot Tour Radar: trending", "Most popular tour offers of the last 24 hours")
+}
+
+// handleSearchFeed renders the newest documents matching the /news filters as
+// RSS, so any radar query can be followed from a feed reader.
+func (s *server) handleSearchFeed(w http.ResponseWriter, r *http.Request) {
+	params, err := s.parseSearchParams(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	params.From = 0
+	params.Size = feedSize
+	params.Sort = ""
+	// Feed readers poll, so a relaxed result would surface stale offers.
+	params.Relax = false
+
+	title := "Hot Tour Radar"
+	if params.Query != "" {
+		title += ": " + params.Query
+	}
+	s.writeFeed(w, r, params, title, "Newest tour offers matching the search filters")
+}
+
+func (s *server) writeFeed(w http.ResponseWriter, r *http.Request, params elasticsearch.SearchParams, title, description string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	result, err := s.es.SearchNews(ctx, params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         title,
+			Link:          requestBaseURL(r) + r.URL.RequestURI(),
+			Description:   description,
+			LastBuildDate: time.Now().UTC().Format(time.RFC1123Z),
+			Items:         make([]rssItem, 0, len(result.Items)),
+		},
+	}
+	mask := maskFor(w)
+	for _, doc := range result.Items {
+		feed.Channel.Items = append(feed.Channel.Items, feedItem(mask.Document(doc)))
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	_ = enc.Encode(feed)
+}
+
+func feedItem(doc models.NewsDocument) rssItem {
+	item := rssItem{
+		Title:       doc.Title,
+		Description: doc.Text,
+		GUID:        rssGUID{Value: doc.ID},
+		PubDate:     doc.Timestamp.UTC().Format(time.RFC1123Z),
+		Categories:  doc.Keywords,
+	}
+	if len(doc.URLs) > 0 {
+		item.Link = doc.URLs[0]
+	}
+	return item
+}
+
+// requestBaseURL reconstructs the public scheme and host of the request.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := strings.TrimSpace(r.Header.Get("X-Forwarded-Proto")); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
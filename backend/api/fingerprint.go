@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+)
+
+type fingerprintRequest struct {
+	Title          string `json:"title"`
+	Text           string `json:"text"`
+	Timestamp      string `json:"timestamp"`
+	Source         string `json:"source"`
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+type fingerprintResponse struct {
+	// ID is empty when the worker would assign a random one.
+	ID          string `json:"id"`
+	Fingerprint string `json:"fingerprint"`
+	// Title is the title the ID is computed from, generated from the text
+	// when the request has none.
+	Title   string `json:"title"`
+	Repost  bool   `json:"repost"`
+	Indexed bool   `json:"indexed"`
+}
+
+// newIdentityPipeline builds the worker stages that decide document IDs
+// from the worker settings the API shares and titleRules.
+func newIdentityPipeline(cfg *config.API, titleRules *processing.TitleRules) (*processing.Pipeline, error) {
+	sourceGroups, err := processing.ParseSourceGroups(cfg.SourceGroups, cfg.SourceGroupWindow)
+	if err != nil {
+		return nil, err
+	}
+	return processing.BuildIdentityPipeline(cfg.Pipeline, processing.Options{
+		RepostSources: cfg.RepostSources,
+		RepostWindow:  cfg.RepostWindow,
+		PIIKinds:      cfg.PIIKinds,
+		TitleRules:    titleRules,
+		SourceGroups:  sourceGroups,
+	})
+}
+
+// handleFingerprint tells producers which document ID and fingerprint the
+// worker would give a post, and whether a document with that ID exists,
+// so they can skip content that is already indexed.
+func (s *server) handleFingerprint(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var req fingerprintRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	// The worker trims and defaults these fields the same way.
+	title, text := strings.TrimSpace(req.Title), strings.TrimSpace(req.Text)
+	if title == "" && text == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "title or text is required"})
+		return
+	}
+	ts, err := time.Parse(time.RFC3339, strings.TrimSpace(req.Timestamp))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "timestamp must be RFC 3339", Hint: "the worker stamps posts without a timestamp with their arrival time, so their ID cannot be known in advance"})
+		return
+	}
+	source := strings.TrimSpace(req.Source)
+	if source == "" {
+		source = "unknown"
+	}
+
+	item := &processing.Item{Doc: models.NewsDocument{Title: title, Text: text, Timestamp: ts, Source: source}}
+	if err := s.identity().Run(item); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	resp := fingerprintResponse{
+		ID:          item.Doc.ID,
+		Fingerprint: item.Doc.ClusterID,
+		Title:       item.Doc.Title,
+		Repost:      item.Repost,
+	}
+	if resp.Fingerprint == "" {
+		resp.Fingerprint = processing.BuildFingerprint(item.Doc.Title, item.Doc.Text)
+	}
+	// A producer-supplied key replaces the computed ID.
+	if key := strings.TrimSpace(req.IdempotencyKey); key != "" {
+		resp.ID = key
+		resp.Repost = false
+	}
+
+	if resp.ID != "" {
+		_, err := s.es.GetNewsByID(ctx, resp.ID)
+		switch {
+		case err == nil:
+			resp.Indexed = true
+		case !errors.Is(err, elasticsearch.ErrNotFound):
+			writeError(w, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
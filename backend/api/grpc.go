@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/newspb"
+)
+
+// grpcSearch serves the NewsSearch gRPC service on API_GRPC_ADDR for
+// internal services. It shares the Elasticsearch client and the parameter
+// normalization of the HTTP handlers, and returns documents as stored:
+// the port is meant for the internal network only.
+type grpcSearch struct {
+	newspb.UnimplementedNewsSearchServer
+	s *server
+}
+
+func (g grpcSearch) Search(ctx context.Context, req *newspb.SearchRequest) (*newspb.SearchResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	params, err := g.s.searchParamsFromProto(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	result, err := g.s.es.SearchNews(ctx, params)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &newspb.SearchResponse{
+		Total:   result.Total,
+		Items:   make([]*newspb.NewsDocument, 0, len(result.Items)),
+		Relaxed: result.Relaxed,
+		Dropped: result.Dropped,
+	}
+	for _, doc := range result.Items {
+		resp.Items = append(resp.Items, documentToProto(doc))
+	}
+	return resp, nil
+}
+
+func (g grpcSearch) GetNews(ctx context.Context, req *newspb.GetNewsRequest) (*newspb.NewsDocument, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	id := strings.TrimSpace(req.GetId())
+	if id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	doc, err := g.s.es.GetNewsByID(ctx, id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return documentToProto(*doc), nil
+}
+
+// searchParamsFromProto normalizes a gRPC search request the way
+// parseSearchParams normalizes /news query parameters.
+func (s *server) searchParamsFromProto(req *newspb.SearchRequest) (elasticsearch.SearchParams, error) {
+	query, err := s.normalizeQuery(req.GetQuery())
+	if err != nil {
+		return elasticsearch.SearchParams{}, err
+	}
+	var destination string
+	if raw := strings.TrimSpace(req.GetDestination()); raw != "" {
+		destination = s.taxonomy().Canonical(raw)
+	}
+
+	size := int(req.GetSize())
+	if size <= 0 {
+		size = s.cfg.DefaultPage
+	}
+	params := elasticsearch.SearchParams{
+		Query:          query,
+		Keywords:       s.keywordFilter(parseKeywords(strings.Join(req.GetKeywords(), ","))),
+		Source:         strings.TrimSpace(req.GetSource()),
+		Destination:    destination,
+		Mention:        parseMention(req.GetMention()),
+		From:           min(max(int(req.GetFrom()), 0), 10_000),
+		Size:           min(size, s.cfg.MaxPage),
+		Sort:           strings.TrimSpace(req.GetSort()),
+		Fuzzy:          true,
+		Relax:          req.Relax == nil || req.GetRelax(),
+		ActiveOnly:     req.GetActiveOnly(),
+		HasPrice:       req.GetHasPrice(),
+		HasURL:         req.GetHasUrl(),
+		HasTravelDates: req.GetHasTravelDates(),
+		MinQuality:     min(max(int(req.GetMinQuality()), 0), 100),
+		Popularity: elasticsearch.PopularityBoost{
+			SeenWeight:  s.cfg.RankSeenWeight,
+			ClickWeight: s.cfg.RankClickWeight,
+		},
+	}
+	if req.GetStart() != nil {
+		start := req.GetStart().AsTime()
+		params.Start = &start
+	}
+	if req.GetEnd() != nil {
+		end := req.GetEnd().AsTime()
+		params.End = &end
+	}
+	return params, nil
+}
+
+func documentToProto(doc models.NewsDocument) *newspb.NewsDocument {
+	return &newspb.NewsDocument{
+		Id:           doc.ID,
+		Title:        doc.Title,
+		Text:         doc.Text,
+		Timestamp:    protoTime(doc.Timestamp),
+		Keywords:     doc.Keywords,
+		Source:       doc.Source,
+		Urls:         doc.URLs,
+		Destinations: doc.Destinations,
+		Mentions:     doc.Mentions,
+		SeenCount:    int64(doc.SeenCount),
+		LastSeen:     protoTime(doc.LastSeen),
+		Clicks:       int64(doc.Clicks),
+		TravelStart:  protoTime(doc.TravelStart),
+		TravelEnd:    protoTime(doc.TravelEnd),
+		ExpiresAt:    protoTime(doc.ExpiresAt),
+		Status:       doc.Status,
+		ExpiredBy:    doc.ExpiredBy,
+		Price:        int64(doc.Price),
+		ClusterId:    doc.ClusterID,
+		IndexedAt:    protoTime(doc.IndexedAt),
+	}
+}
+
+// protoTime leaves unset times unset rather than sending year 1.
+func protoTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// grpcError maps Elasticsearch failures to status codes the way
+// writeError maps them to HTTP statuses.
+func grpcError(err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, elasticsearch.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, elasticsearch.ErrBadRequest):
+		code = codes.InvalidArgument
+	case errors.Is(err, elasticsearch.ErrConflict):
+		code = codes.Aborted
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, elasticsearch.ErrUnavailable):
+		code = codes.Unavailable
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+const (
+	// ingestWriteTimeout bounds waiting for Kafka to confirm a submission
+	// before it is spooled or refused.
+	ingestWriteTimeout = 5 * time.Second
+	// maxIngestKeyBytes matches the worker's limit on idempotency keys.
+	maxIngestKeyBytes = 512
+)
+
+// messageWriter is the subset of kafka.Writer used for submissions.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type ingestRequest struct {
+	Title     string `json:"title"`
+	Text      string `json:"text"`
+	Timestamp string `json:"timestamp"`
+	Source    string `json:"source"`
+	// Key becomes the document ID, so resubmitting it replaces the
+	// document; a random one is assigned when it is empty.
+	Key            string `json:"key"`
+	WaitForRefresh bool   `json:"wait_for_refresh"`
+}
+
+type ingestResponse struct {
+	Key string `json:"key"`
+	// Status is delivered once Kafka confirmed the message and spooled
+	// when it was kept on disk to be retried.
+	Status string `json:"status"`
+}
+
+// ingester writes manual submissions to the raw topic and waits for
+// Kafka's confirmation of each. A submission Kafka does not confirm is
+// written to the spool, when one is configured, and retried from there.
+type ingester struct {
+	log    *slog.Logger
+	writer messageWriter
+	spool  *ingestSpool
+
+	delivered atomic.Int64
+	spooled   atomic.Int64
+	replayed  atomic.Int64
+	refused   atomic.Int64
+}
+
+// ingestState is published under ingest at /debug/vars.
+type ingestState struct {
+	Delivered int64 `json:"delivered"`
+	Spooled   int64 `json:"spooled"`
+	Replayed  int64 `json:"replayed"`
+	Refused   int64 `json:"refused"`
+	Pending   int   `json:"pending"`
+}
+
+// newKafkaWriter builds the writer for topic; acks is "all" or "one".
+func newKafkaWriter(brokers []string, topic, acks string) *kafka.Writer {
+	required := kafka.RequireAll
+	if acks == "one" {
+		required = kafka.RequireOne
+	}
+	return &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		MaxAttempts:  3,
+		RequiredAcks: required,
+		WriteTimeout: ingestWriteTimeout,
+	}
+}
+
+// submit writes msg and reports whether it was delivered or spooled.
+func (i *ingester) submit(ctx context.Context, msg kafka.Message) (string, error) {
+	writeCtx, cancel := context.WithTimeout(ctx, ingestWriteTimeout)
+	err := i.writer.WriteMessages(writeCtx, msg)
+	cancel()
+	if err == nil {
+		i.delivered.Add(1)
+		return "delivered", nil
+	}
+
+	if i.spool == nil {
+		i.refused.Add(1)
+		return "", fmt.Errorf("kafka did not confirm the submission: %w", err)
+	}
+	i.log.WarnContext(ctx, "kafka did not confirm submission, spooling it", slog.String("key", string(msg.Key)), slog.Any("err", err))
+	if spoolErr := i.spool.put(msg); spoolErr != nil {
+		i.refused.Add(1)
+		return "", fmt.Errorf("kafka did not confirm the submission and spooling failed: %w", errors.Join(err, spoolErr))
+	}
+	i.spooled.Add(1)
+	return "spooled", nil
+}
+
+// run replays the spool every interval until ctx is done, starting with
+// whatever an earlier run left behind.
+func (i *ingester) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := i.replay(ctx); err != nil {
+			i.log.Warn("replay spooled submissions (will retry)", slog.Int("replayed", n), slog.Any("err", err))
+		} else if n > 0 {
+			i.log.Info("replayed spooled submissions", slog.Int("replayed", n))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// replay writes the spooled messages oldest first and removes each one
+// Kafka confirmed. It stops at the first failure, leaving the rest for
+// the next run. A file that cannot be decoded is renamed to *.bad and
+// skipped so it does not hold up the others.
+func (i *ingester) replay(ctx context.Context) (int, error) {
+	files, err := i.spool.pending()
+	if err != nil {
+		return 0, err
+	}
+	replayed := 0
+	for _, path := range files {
+		msg, err := i.spool.load(path)
+		if err != nil {
+			i.log.Error("skip unreadable spooled submission", slog.String("file", path), slog.Any("err", err))
+			if err := os.Rename(path, path+".bad"); err != nil {
+				return replayed, err
+			}
+			continue
+		}
+
+		writeCtx, cancel := context.WithTimeout(ctx, ingestWriteTimeout)
+		err = i.writer.WriteMessages(writeCtx, msg)
+		cancel()
+		if err != nil {
+			return replayed, err
+		}
+		if err := os.Remove(path); err != nil {
+			// The message would be written again on the next run; its
+			// idempotency key keeps that from duplicating the document.
+			return replayed, fmt.Errorf("remove replayed submission: %w", err)
+		}
+		replayed++
+		i.replayed.Add(1)
+	}
+	return replayed, nil
+}
+
+func (i *ingester) close() error {
+	if c, ok := i.writer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// vars is the expvar.Func behind ingest.
+func (i *ingester) vars() any {
+	st := ingestState{
+		Delivered: i.delivered.Load(),
+		Spooled:   i.spooled.Load(),
+		Replayed:  i.replayed.Load(),
+		Refused:   i.refused.Load(),
+	}
+	if i.spool != nil {
+		if files, err := i.spool.pending(); err == nil {
+			st.Pending = len(files)
+		}
+	}
+	return st
+}
+
+// handleIngest publishes a manually submitted post to the raw topic the
+// scrapers write to, answering only once Kafka confirmed it or it was
+// spooled.
+func (s *server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	var req ingestRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: " + err.Error()})
+		return
+	}
+	msg, err := ingestMessage(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	status, err := s.ingest.submit(r.Context(), msg)
+	if err != nil {
+		s.log.ErrorContext(r.Context(), "ingest submission", slog.String("key", string(msg.Key)), slog.Any("err", err))
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, ingestResponse{Key: string(msg.Key), Status: status})
+}
+
+// ingestMessage validates a submission and converts it into the message
+// a scraper would have published, keyed by its idempotency key.
+func ingestMessage(req ingestRequest) (kafka.Message, error) {
+	news := models.RawNews{
+		Title:  strings.TrimSpace(req.Title),
+		Text:   strings.TrimSpace(req.Text),
+		Source: strings.TrimSpace(req.Source),
+	}
+	if news.Title == "" && news.Text == "" {
+		return kafka.Message{}, errors.New("title or text is required")
+	}
+	if news.Source == "" {
+		return kafka.Message{}, errors.New("source is required")
+	}
+	ts := time.Now().UTC()
+	if raw := strings.TrimSpace(req.Timestamp); raw != "" {
+		var err error
+		if ts, err = time.Parse(time.RFC3339, raw); err != nil {
+			return kafka.Message{}, errors.New("timestamp must be RFC 3339, e.g. 2024-06-01T10:00:00Z")
+		}
+	}
+	news.Timestamp = ts.Format(time.RFC3339)
+
+	key := strings.TrimSpace(req.Key)
+	if key == "" {
+		key = "manual:" + uuid.NewString()
+	}
+	if len(key) > maxIngestKeyBytes {
+		return kafka.Message{}, fmt.Errorf("key exceeds %d bytes", maxIngestKeyBytes)
+	}
+
+	value, err := json.Marshal(news)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("marshal submission: %w", err)
+	}
+	msg := kafka.Message{
+		Key:     []byte(key),
+		Value:   value,
+		Headers: []kafka.Header{{Key: "idempotency_key", Value: []byte(key)}},
+	}
+	if req.WaitForRefresh {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: "wait_for_refresh", Value: []byte("true")})
+	}
+	return msg, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+type fakeWriter struct {
+	err  error
+	msgs []kafka.Message
+}
+
+func (f *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.msgs = append(f.msgs, msgs...)
+	return nil
+}
+
+func postIngest(t *testing.T, srv *server, body string) (*httptest.ResponseRecorder, ingestResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.handleIngest(rec, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+	var resp ingestResponse
+	if rec.Code == http.StatusAccepted {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	}
+	return rec, resp
+}
+
+func headerMap(msg kafka.Message) map[string]string {
+	headers := map[string]string{}
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	return headers
+}
+
+func TestIngestDelivers(t *testing.T) {
+	writer := &fakeWriter{}
+	log := slog.New(slog.DiscardHandler)
+	srv := &server{log: log, ingest: &ingester{log: log, writer: writer}}
+
+	rec, resp := postIngest(t, srv, `{"title":"Турция","text":"Кемер от 45 000","source":"manual","timestamp":"2024-06-01T10:00:00+03:00","wait_for_refresh":true}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.Equal(t, "delivered", resp.Status)
+	require.True(t, strings.HasPrefix(resp.Key, "manual:"))
+
+	require.Len(t, writer.msgs, 1)
+	msg := writer.msgs[0]
+	require.Equal(t, resp.Key, string(msg.Key))
+	require.Equal(t, map[string]string{"idempotency_key": resp.Key, "wait_for_refresh": "true"}, headerMap(msg))
+	require.JSONEq(t, `{"title":"Турция","text":"Кемер от 45 000","source":"manual","timestamp":"2024-06-01T10:00:00+03:00"}`, string(msg.Value))
+}
+
+func TestIngestRejectsInvalidSubmissions(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+	srv := &server{log: log, ingest: &ingester{log: log, writer: &fakeWriter{}}}
+
+	for _, body := range []string{
+		`{"title":"Турция"}`,
+		`{"source":"manual"}`,
+		`{"text":"Кемер","source":"manual","timestamp":"вчера"}`,
+		`{"text":"Кемер","source":"manual","key":"` + strings.Repeat("k", maxIngestKeyBytes+1) + `"}`,
+		`{"text":"Кемер","source":"manual","channel":"x"}`,
+	} {
+		rec, _ := postIngest(t, srv, body)
+		require.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func TestIngestRefusedWithoutSpool(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+	srv := &server{log: log, ingest: &ingester{log: log, writer: &fakeWriter{err: errors.New("leader not available")}}}
+
+	rec, _ := postIngest(t, srv, `{"text":"Кемер","source":"manual"}`)
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), "kafka did not confirm the submission")
+	require.Equal(t, int64(1), srv.ingest.vars().(ingestState).Refused)
+}
+
+func TestIngestSpoolsAndReplays(t *testing.T) {
+	writer := &fakeWriter{err: errors.New("leader not available")}
+	spool, err := newIngestSpool(filepath.Join(t.TempDir(), "spool"))
+	require.NoError(t, err)
+	log := slog.New(slog.DiscardHandler)
+	srv := &server{log: log, ingest: &ingester{log: log, writer: writer, spool: spool}}
+
+	rec, first := postIngest(t, srv, `{"text":"Кемер","source":"manual","key":"manual:1","wait_for_refresh":true}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.Equal(t, ingestResponse{Key: "manual:1", Status: "spooled"}, first)
+	rec, _ = postIngest(t, srv, `{"text":"Анталья","source":"manual","key":"manual:2"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.Equal(t, 2, srv.ingest.vars().(ingestState).Pending)
+
+	// Still down: nothing is lost.
+	n, err := srv.ingest.replay(context.Background())
+	require.Error(t, err)
+	require.Zero(t, n)
+	require.Equal(t, 2, srv.ingest.vars().(ingestState).Pending)
+
+	writer.err = nil
+	n, err = srv.ingest.replay(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, ingestState{Spooled: 2, Replayed: 2}, srv.ingest.vars())
+
+	require.Len(t, writer.msgs, 2)
+	require.Equal(t, "manual:1", string(writer.msgs[0].Key))
+	require.Equal(t, map[string]string{"idempotency_key": "manual:1", "wait_for_refresh": "true"}, headerMap(writer.msgs[0]))
+	var news models.RawNews
+	require.NoError(t, json.Unmarshal(writer.msgs[0].Value, &news))
+	require.Equal(t, "Кемер", news.Text)
+	_, err = time.Parse(time.RFC3339, news.Timestamp)
+	require.NoError(t, err, "the time of submission is kept through the spool")
+	require.Equal(t, "manual:2", string(writer.msgs[1].Key))
+}
+
+func TestIngestReplaySkipsUnreadableFiles(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := newIngestSpool(dir)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000001-broken.json"), []byte("{"), 0o600))
+	require.NoError(t, spool.put(kafka.Message{Key: []byte("manual:1"), Value: []byte(`{"text":"Кемер","source":"manual"}`)}))
+
+	writer := &fakeWriter{}
+	log := slog.New(slog.DiscardHandler)
+	ing := &ingester{log: log, writer: writer, spool: spool}
+	n, err := ing.replay(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Len(t, writer.msgs, 1)
+
+	files, err := spool.pending()
+	require.NoError(t, err)
+	require.Empty(t, files)
+	require.FileExists(t, filepath.Join(dir, "00000000000000000001-broken.json.bad"))
+}
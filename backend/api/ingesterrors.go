@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+func (s *server) handleIngestErrors(w http.ResponseWriter, r *http.Request) {
+	filter := elasticsearch.IngestErrorFilter{
+		ErrorClass: strings.TrimSpace(r.URL.Query().Get("error_class")),
+		Source:     strings.TrimSpace(r.URL.Query().Get("source")),
+		Topic:      strings.TrimSpace(r.URL.Query().Get("topic")),
+		Query:      strings.TrimSpace(r.URL.Query().Get("q")),
+		Start:      parseTime(r.URL.Query().Get("start")),
+		End:        parseTime(r.URL.Query().Get("end")),
+		Size:       clampInt(r.URL.Query().Get("size"), 50, 500),
+	}
+
+	result, err := s.es.IngestErrors(r.Context(), filter)
+	if err != nil {
+		s.log.ErrorContext(r.Context(), "ingest errors", slog.Any("err", err))
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
@@ -4,21 +4,34 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"expvar"
+	"fmt"
 	"log/slog"
+	"math"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc"
 
+	"github.com/DeafMist/hot-tour-radar/backend/internal/chaos"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/concepts"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/logger"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/newspb"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/querylang"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/reltime"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/stemmer"
 )
 
 func main() {
@@ -29,20 +42,205 @@ func main() {
 		os.Exit(1)
 	}
 
-	esClient, err := elasticsearch.New(cfg.ElasticsearchAddr, cfg.ElasticsearchIndex, log)
+	faults, err := chaos.FromEnv()
+	if err != nil {
+		log.Error("load chaos config", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if faults.Active() {
+		log.Warn("chaos mode active, injecting faults", slog.Any("config", faults))
+	}
+
+	shedder := newLoadShedder(log, cfg.ShedP99, cfg.ShedErrorRate, cfg.ShedWindow)
+	expvar.Publish("load_shedding", expvar.Func(shedder.vars))
+
+	esClient, err := elasticsearch.New(cfg.ElasticsearchAddr, cfg.ElasticsearchIndex, log,
+		elasticsearch.SignAWS(cfg.ElasticsearchAWSRegion, cfg.ElasticsearchAWSService),
+		elasticsearch.LogSlowQueries(cfg.ElasticsearchSlowThreshold, log),
+		elasticsearch.WithTransport(faults.WrapTransport),
+		elasticsearch.WithTransport(shedder.WrapTransport))
 	if err != nil {
 		log.Error("init elasticsearch", slog.Any("err", err))
 		os.Exit(1)
 	}
+	if cfg.ElasticsearchDailyIndices {
+		esClient.EnableDailyIndices()
+	}
+	if cfg.KeywordStemming {
+		esClient.EnableKeywordStemming()
+	}
 
-	srv := &server{log: log, cfg: cfg, es: esClient}
+	keywordConcepts, err := concepts.Load(cfg.ConceptsFile)
+	if err != nil {
+		log.Error("load keyword concepts", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if cfg.KeywordStemming {
+		keywordConcepts = keywordConcepts.WithStems(stemmer.Russian)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	if err := esClient.WaitForIndex(ctx); err != nil {
+		log.Error("ensure elasticsearch index", slog.Any("err", err))
+		os.Exit(1)
+	}
+	templateCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	if err := esClient.EnsureTemplates(templateCtx); err != nil {
+		log.Warn("store search templates, using inline queries", slog.Any("err", err))
+	}
+	cancel()
+	stateCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	if err := esClient.EnsureReadStateIndex(stateCtx); err != nil {
+		log.Warn("create read state index, unseen_only searches will fail", slog.Any("err", err))
+	}
+	cancel()
+
+	srv := &server{log: log, cfg: cfg, es: esClient, shedder: shedder, concepts: keywordConcepts}
+	if cfg.DataBucket != "" {
+		srv.reloader, err = newDataReloader(log, cfg)
+		if err != nil {
+			log.Error("init data store", slog.Any("err", err))
+			os.Exit(1)
+		}
+		loadCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		_, err = srv.reloader.reload(loadCtx, srv.data.Store)
+		cancel()
+		if err != nil {
+			log.Error("load data from object storage", slog.String("bucket", cfg.DataBucket), slog.Any("err", err))
+			os.Exit(1)
+		}
+	} else {
+		data, err := loadReferenceData(cfg)
+		if err != nil {
+			log.Error("load reference data", slog.Any("err", err))
+			os.Exit(1)
+		}
+		srv.data.Store(data)
+	}
+	if cfg.SearchCacheTTL > 0 {
+		srv.searchCache = newSearchCache(cfg.SearchCacheTTL, cfg.SearchCacheEntries)
+		expvar.Publish("search_cache", expvar.Func(srv.searchCache.vars))
+	}
+	if srv.experiment = newExperiment(cfg); srv.experiment != nil {
+		expvar.Publish("experiment", expvar.Func(srv.experiment.vars))
+		log.Info("ranking experiment running", slog.String("experiment", cfg.Experiment), slog.Int("percent", cfg.ExperimentPercent))
+	}
+	if cfg.PITMaxOpen > 0 {
+		srv.pits = newPITSessions(esClient, log, cfg.PITMaxOpen, cfg.PITKeepAlive, cfg.PITMaxAge)
+		expvar.Publish("point_in_time", expvar.Func(srv.pits.vars))
+		go srv.pits.run(ctx)
+	}
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(traceRequests)
+	r.Use(newRealIP(cfg.TrustedProxies).middleware)
 	r.Use(middleware.Recoverer)
+	if cors := newCORS(cfg); cors != nil {
+		r.Use(cors.middleware)
+	}
+	if cfg.RateLimit > 0 {
+		limiter := newRateLimiter(cfg.RateLimit, cfg.RateBurst, srv.isPartner)
+		expvar.Publish("rate_limiting", expvar.Func(limiter.vars))
+		r.Use(limiter.middleware)
+	}
+	r.Use(srv.fieldAccess)
 
-	r.Get("/health", srv.handleHealth)
+	r.Get("/", handleUI)
+	r.Handle("/ui/*", uiAssetHandler)
+	r.Get("/openapi.json", handleOpenAPI)
+	r.Get("/docs", handleDocs)
+	r.Get("/livez", handleLive)
+	r.Get("/readyz", srv.handleReady)
+	// /health predates the split and answers like /readyz.
+	r.Get("/health", srv.handleReady)
+	r.Get("/destinations", srv.handleDestinations)
+	r.Get("/stats/overview", srv.handleStatsOverview)
 	r.Get("/news", srv.handleSearch)
+	r.With(shedder.lowPriority).Get("/news/sample", srv.handleSample)
+	r.With(shedder.lowPriority).Get("/news/aggregations", srv.handleAggregations)
+	r.With(shedder.lowPriority).Get("/trends", srv.handleTrends)
+	r.With(shedder.lowPriority).Get("/keywords/{keyword}/timeline", srv.handleKeywordTimeline)
+	r.With(shedder.lowPriority).Get("/map/clusters", srv.handleMapClusters)
+	r.With(shedder.lowPriority).Get("/news/export", srv.handleExport)
+	r.Post("/news/mget", srv.handleMultiGetBody)
+	r.Post("/tools/fingerprint", srv.handleFingerprint)
+	r.Delete("/news/pit/{handle}", srv.handleClosePIT)
+	r.Get("/news/{docID}.ics", srv.handleNewsCalendar)
+	r.With(shedder.lowPriority).Get("/news/{docID}/similar", srv.handleSimilarNews)
+	r.Get("/news/{docID}", srv.handleGetNews)
+	r.Get("/r/{docID}/{urlIndex}", srv.handleRedirect)
+	r.Get("/feeds/trending.xml", srv.handleTrendingFeed)
+	r.Get("/feeds/search.xml", srv.handleSearchFeed)
+	r.Get("/feeds/radar/{searchID}.xml", srv.handleRadarFeed)
+	r.With(shedder.lowPriority).Get("/feeds/search.ics", srv.handleSearchCalendar)
+	r.With(shedder.lowPriority).Get("/radars/{searchID}/calendar.ics", srv.handleRadarCalendar)
+	r.Route("/saved-searches", func(r chi.Router) {
+		r.Use(srv.requirePartner)
+		r.Post("/", srv.handleCreateSavedSearch)
+		r.Get("/", srv.handleListSavedSearches)
+		r.Delete("/{searchID}", srv.handleDeleteSavedSearch)
+	})
+	r.Post("/seen", srv.handleMarkSeen)
+	r.Delete("/seen", srv.handleClearSeen)
+
+	if cfg.StreamTopic != "" {
+		srv.stream = newNewsHub(log, cfg.StreamMaxClients)
+		srv.stream.onPublish = srv.searchCache.invalidate
+		go srv.stream.consume(ctx, cfg.KafkaBrokers, cfg.StreamTopic)
+		r.Get("/news/stream", srv.handleStream)
+	} else {
+		log.Info("live stream disabled, set API_STREAM_TOPIC to enable")
+	}
+
+	if cfg.ShareSecret != "" {
+		r.Get("/share", srv.handleShare)
+		r.Get("/s/{token}", srv.handleResolveShare)
+	} else {
+		log.Info("share links disabled, set API_SHARE_SECRET to enable")
+	}
+
+	if cfg.AdminToken != "" {
+		// Runtime and traffic metrics are for operators only.
+		r.With(srv.requireAdmin).Handle("/debug/vars", expvar.Handler())
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(srv.requireAdmin)
+			r.Post("/retag", srv.handleRetag)
+			r.Get("/stopwords/suggestions", srv.handleStopwordSuggestions)
+			r.Get("/errors", srv.handleIngestErrors)
+			if srv.reloader != nil {
+				r.Post("/reload-data", srv.handleReloadData)
+			}
+			if len(cfg.DLQTopics) > 0 {
+				srv.dlq = newDeadLetters(cfg.KafkaBrokers, cfg.DLQTopics)
+				r.Get("/dlq", srv.handleDLQPeek)
+				r.Post("/dlq/replay", srv.handleDLQReplay)
+			} else {
+				log.Info("dlq endpoints disabled, set API_DLQ_TOPICS to enable")
+			}
+		})
+		if cfg.IngestTopic != "" {
+			srv.ingest = &ingester{log: log, writer: newKafkaWriter(cfg.KafkaBrokers, cfg.IngestTopic, cfg.IngestAcks)}
+			if cfg.IngestSpoolDir != "" {
+				spool, err := newIngestSpool(cfg.IngestSpoolDir)
+				if err != nil {
+					log.Error("open ingest spool", slog.Any("err", err))
+					os.Exit(1)
+				}
+				srv.ingest.spool = spool
+				go srv.ingest.run(ctx, cfg.IngestReplayInterval)
+			} else {
+				log.Info("ingest spool disabled, submissions kafka does not confirm are refused")
+			}
+			expvar.Publish("ingest", expvar.Func(srv.ingest.vars))
+			r.With(srv.requireAdmin).Post("/ingest", srv.handleIngest)
+		} else {
+			log.Info("ingest endpoint disabled, set API_INGEST_TOPIC to enable")
+		}
+	} else {
+		log.Info("admin endpoints disabled, set API_ADMIN_TOKEN to enable")
+	}
 
 	httpServer := &http.Server{
 		Addr:              cfg.BindAddr,
@@ -52,9 +250,6 @@ func main() {
 		WriteTimeout:      15 * time.Second,
 	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
-	defer stop()
-
 	go func() {
 		log.Info("api server starting", slog.String("addr", cfg.BindAddr))
 		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -63,30 +258,88 @@ func main() {
 		}
 	}()
 
+	var grpcServer *grpc.Server
+	if cfg.GRPCAddr != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			log.Error("listen for grpc", slog.Any("err", err))
+			os.Exit(1)
+		}
+		grpcServer = grpc.NewServer()
+		newspb.RegisterNewsSearchServer(grpcServer, grpcSearch{s: srv})
+		go func() {
+			log.Info("grpc server starting", slog.String("addr", cfg.GRPCAddr))
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Error("grpc server stopped", slog.Any("err", err))
+				os.Exit(1)
+			}
+		}()
+	}
+
 	<-ctx.Done()
 	log.Info("shutdown signal received")
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+	if srv.stream != nil {
+		srv.stream.close()
+	}
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
 		log.Error("server shutdown", slog.Any("err", err))
 	}
+	if srv.dlq != nil {
+		if err := srv.dlq.close(); err != nil {
+			log.Error("close dlq writer", slog.Any("err", err))
+		}
+	}
+	if srv.ingest != nil {
+		if err := srv.ingest.close(); err != nil {
+			log.Error("close ingest writer", slog.Any("err", err))
+		}
+	}
 }
 
 type server struct {
-	log *slog.Logger
-	cfg *config.API
-	es  *elasticsearch.Client
+	log      *slog.Logger
+	cfg      *config.API
+	es       *elasticsearch.Client
+	shedder  *loadShedder
+	concepts *concepts.Table
+	// data is replaced by POST /admin/reload-data when reloader is set.
+	data        atomic.Pointer[referenceData]
+	reloader    *dataReloader
+	overview    overviewCache
+	searchCache *searchCache
+	pits        *pitSessions
+	stream      *newsHub
+	dlq         *deadLetters
+	ingest      *ingester
+	experiment  *experiment
 }
 
 type errorResponse struct {
 	Error string `json:"error"`
+	// Code is a stable identifier for errors clients handle specially.
+	Code string `json:"code,omitempty"`
+	// Hint tells the client how to recover.
+	Hint string `json:"hint,omitempty"`
+}
+
+// handleLive answers as long as the process serves HTTP. It checks nothing
+// else, so an Elasticsearch outage does not get the API restarted.
+func handleLive(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
-func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+// handleReady reports whether searches can be served: Elasticsearch is
+// reachable and the news index exists.
+func (s *server) handleReady(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 	defer cancel()
 
-	if err := s.es.Health(ctx); err != nil {
+	if err := s.es.IndexReady(ctx); err != nil {
 		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
 		return
 	}
@@ -95,12 +348,117 @@ func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("ids") {
+		s.multiGet(w, r, parseCSV(r.URL.Query().Get("ids")))
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	query := strings.TrimSpace(r.URL.Query().Get("q"))
-	keywords := parseCSV(r.URL.Query().Get("keywords"))
+	r, after, ok := s.resolveCursor(w, r, time.Now())
+	if !ok {
+		return
+	}
+	params, err := s.parseSearchParams(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	facets, err := parseFacetRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	// Facets and histograms are aggregations like /news/aggregations and
+	// are shed with it; plain searches are still answered.
+	if facets.requested() && s.shedder.state(r.Context(), time.Now()).Overloaded {
+		s.shedder.reject(w, r)
+		return
+	}
+	if r.URL.Query().Get("unseen_only") == "true" {
+		reader, ok := readerID(r)
+		if !ok {
+			writeMissingAPIKey(w)
+			return
+		}
+		params.UnseenBy = reader
+	}
+	if arm := s.experiment.arm(r); arm != "" {
+		w.Header().Set(experimentHeader, s.experiment.name+"="+arm)
+	}
+	if pit := r.URL.Query().Get("pit"); pit != "" && pit != "false" {
+		s.searchPIT(w, r, params, facets)
+		return
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" && cursor != "false" {
+		s.searchCursor(w, r, params, facets, after)
+		return
+	}
+
+	// Per-reader results are not cached.
+	key, cacheable := "", false
+	if s.searchCache != nil && params.UnseenBy == "" {
+		key, cacheable = searchCacheKey(params, facets)
+	}
+	if cacheable {
+		if result, ok := s.searchCache.get(key, time.Now()); ok {
+			s.recordSearch(r, params, result)
+			w.Header().Set("X-Cache", "HIT")
+			s.writeCachedJSON(w, r, result)
+			return
+		}
+	}
+
+	result, err := s.search(ctx, params, facets)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.recordSearch(r, params, result)
+
+	if cacheable {
+		s.searchCache.put(key, result, time.Now())
+		w.Header().Set("X-Cache", "MISS")
+		s.writeCachedJSON(w, r, result)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleGetNews returns a single document so clients can deep-link to it.
+func (s *server) handleGetNews(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	doc, err := s.es.GetNewsByID(ctx, chi.URLParam(r, "docID"))
+	if errors.Is(err, elasticsearch.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "document not found"})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// parseSearchParams reads the filter, paging and sort parameters shared by
+// the /news endpoints. Only a malformed q and a currency other than rubles
+// are reported; other unparsable values fall back to their defaults.
+func (s *server) parseSearchParams(r *http.Request) (elasticsearch.SearchParams, error) {
+	query, err := s.normalizeQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		return elasticsearch.SearchParams{}, err
+	}
+	keywords := s.keywordFilter(parseKeywords(r.URL.Query().Get("keywords")))
 	source := strings.TrimSpace(r.URL.Query().Get("source"))
+	mention := parseMention(r.URL.Query().Get("mention"))
+	var destination string
+	if raw := strings.TrimSpace(r.URL.Query().Get("destination")); raw != "" {
+		destination = s.taxonomy().Canonical(raw)
+	}
 
 	from := clampInt(r.URL.Query().Get("from"), 0, 10_000)
 	size := clampInt(r.URL.Query().Get("size"), s.cfg.DefaultPage, s.cfg.MaxPage)
@@ -109,13 +467,31 @@ func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	start := parseTime(r.URL.Query().Get("start"))
 	end := parseTime(r.URL.Query().Get("end"))
 
+	if currency := r.URL.Query().Get("currency"); !isRubles(currency) {
+		return elasticsearch.SearchParams{}, fmt.Errorf("unsupported currency %q: prices are extracted in rubles only, pass RUB or leave currency out", currency)
+	}
+
 	params := elasticsearch.SearchParams{
-		Query:    query,
-		Keywords: keywords,
-		Source:   source,
-		From:     from,
-		Size:     size,
-		Sort:     sort,
+		Query:          query,
+		Keywords:       keywords,
+		Source:         source,
+		Destination:    destination,
+		Mention:        mention,
+		From:           from,
+		Size:           size,
+		Sort:           sort,
+		Fuzzy:          r.URL.Query().Get("fuzzy") != "false",
+		Relax:          r.URL.Query().Get("relax") != "false",
+		ActiveOnly:     r.URL.Query().Get("active_only") == "true",
+		HasPrice:       r.URL.Query().Get("has_price") == "true",
+		HasURL:         r.URL.Query().Get("has_url") == "true",
+		HasTravelDates: r.URL.Query().Get("has_travel_dates") == "true",
+		MinPrice:       clampInt(r.URL.Query().Get("min_price"), 0, math.MaxInt32),
+		MaxPrice:       clampInt(r.URL.Query().Get("max_price"), 0, math.MaxInt32),
+		MinQuality:     clampInt(r.URL.Query().Get("min_quality"), 0, 100),
+		BoostKeywords:  s.keywordBoosts(parseKeywordBoosts(r.URL.Query().Get("boost_keywords"))),
+		Popularity:     s.popularity(r),
+		MaxPerSource:   clampInt(r.URL.Query().Get("max_per_source"), 0, s.cfg.MaxPage),
 	}
 	if start != nil {
 		params.Start = start
@@ -123,14 +499,38 @@ func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	if end != nil {
 		params.End = end
 	}
+	return params, nil
+}
 
-	result, err := s.es.SearchNews(ctx, params)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
-		return
+// isRubles reports whether currency, as given to /news, names the ruble,
+// the only currency prices are extracted in. Empty means rubles.
+func isRubles(currency string) bool {
+	switch strings.ToLower(strings.TrimSpace(currency)) {
+	case "", "rub", "rur", "руб", "₽":
+		return true
 	}
+	return false
+}
 
-	writeJSON(w, http.StatusOK, result)
+// normalizeQuery validates q and rewrites its field values the way the
+// matching /news parameters are: destinations by the taxonomy, keywords
+// and mentions as parseKeywords and parseMention do.
+func (s *server) normalizeQuery(raw string) (string, error) {
+	parsed, err := querylang.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid q: %w", err)
+	}
+	for i, term := range parsed.Terms {
+		switch term.Field {
+		case "destination":
+			parsed.Terms[i].Value = s.taxonomy().Canonical(term.Value)
+		case "keyword":
+			parsed.Terms[i].Value = strings.ToLower(strings.TrimPrefix(term.Value, "#"))
+		case "mention":
+			parsed.Terms[i].Value = parseMention(term.Value)
+		}
+	}
+	return parsed.String(), nil
 }
 
 func parseTime(raw string) *time.Time {
@@ -144,6 +544,21 @@ func parseTime(raw string) *time.Time {
 	return nil
 }
 
+// parseKeywords reads a comma-separated keyword list. Hashtags are stored
+// as keywords without their #, so "#горящиетуры" finds them too.
+func parseKeywords(raw string) []string {
+	keywords := parseCSV(raw)
+	for i, keyword := range keywords {
+		keywords[i] = strings.TrimPrefix(keyword, "#")
+	}
+	return slices.DeleteFunc(keywords, func(k string) bool { return k == "" })
+}
+
+// parseMention normalizes an @username the way the tags stage stores it.
+func parseMention(raw string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(raw), "@"))
+}
+
 func parseCSV(raw string) []string {
 	if raw == "" {
 		return nil
@@ -159,6 +574,68 @@ func parseCSV(raw string) []string {
 	return out
 }
 
+// keywordFilter adds the stem of every keyword when the worker stems
+// keywords (KEYWORD_STEMMING), so "туры" finds documents stored under "тур"
+// as well as hashtags and documents indexed before stemming was enabled.
+func (s *server) keywordFilter(keywords []string) []string {
+	if !s.cfg.KeywordStemming {
+		return keywords
+	}
+	out := slices.Clip(keywords)
+	for _, keyword := range keywords {
+		if stem := stemmer.Russian(strings.ToLower(keyword)); !slices.Contains(out, stem) {
+			out = append(out, stem)
+		}
+	}
+	return out
+}
+
+// keywordBoosts gives the stems of boosted keywords the same weight, as
+// keywordFilter does for filters.
+func (s *server) keywordBoosts(boosts []elasticsearch.KeywordBoost) []elasticsearch.KeywordBoost {
+	if !s.cfg.KeywordStemming {
+		return boosts
+	}
+	for _, boost := range slices.Clip(boosts) {
+		if stem := stemmer.Russian(boost.Keyword); stem != boost.Keyword {
+			boosts = append(boosts, elasticsearch.KeywordBoost{Keyword: stem, Weight: boost.Weight})
+		}
+	}
+	return boosts
+}
+
+// Limits for boost_keywords, keeping a personalized query cheap to score.
+const (
+	maxKeywordBoosts = 20
+	maxKeywordWeight = 10.0
+)
+
+// parseKeywordBoosts reads "турция:2,египет:0.5". A keyword without a weight
+// gets 1; malformed or non-positive weights are skipped and large ones capped.
+func parseKeywordBoosts(raw string) []elasticsearch.KeywordBoost {
+	var boosts []elasticsearch.KeywordBoost
+	for _, part := range parseCSV(raw) {
+		keyword, weightRaw, hasWeight := strings.Cut(part, ":")
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword == "" {
+			continue
+		}
+		weight := 1.0
+		if hasWeight {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(weightRaw), 64)
+			if err != nil || parsed <= 0 || math.IsInf(parsed, 0) {
+				continue
+			}
+			weight = min(parsed, maxKeywordWeight)
+		}
+		boosts = append(boosts, elasticsearch.KeywordBoost{Keyword: keyword, Weight: weight})
+		if len(boosts) == maxKeywordBoosts {
+			break
+		}
+	}
+	return boosts
+}
+
 func clampInt(raw string, fallback, max int) int {
 	if raw == "" {
 		return fallback
@@ -176,10 +653,46 @@ func clampInt(raw string, fallback, max int) int {
 	return value
 }
 
+// writeError maps Elasticsearch error kinds onto HTTP status codes.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, elasticsearch.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, elasticsearch.ErrBadRequest):
+		status = http.StatusBadRequest
+	case errors.Is(err, elasticsearch.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, elasticsearch.ErrUnavailable):
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// writeJSON encodes payload with the caller's field mask applied (see
+// fieldAccess), so handlers cannot leak masked fields by forgetting it.
 func writeJSON(w http.ResponseWriter, status int, payload any) {
+	data, err := encodeJSON(w, payload)
+	if err != nil {
+		status = http.StatusInternalServerError
+		data, _ = json.Marshal(errorResponse{Error: err.Error()})
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(payload); err != nil {
-		// nothing better to do
+	_, _ = w.Write(append(data, '\n'))
+}
+
+// encodeJSON marshals a response payload through the request's field mask
+// and, when a locale was requested, adds document ages.
+func encodeJSON(w http.ResponseWriter, payload any) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err == nil {
+		data, err = maskFor(w).JSON(data)
+	}
+	if err == nil && localeFor(w) != "" {
+		data, err = reltime.AddAges(data, time.Now(), localeFor(w))
 	}
+	return data, err
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+// zoomPrecisions maps web map zoom levels, from 0 for the whole world, to
+// the geohash precision whose cells are a few dozen pixels wide on screen,
+// so a view shows tens of clusters rather than one or thousands. Deeper
+// zooms use the last entry: documents sit at destinations, not addresses.
+var zoomPrecisions = []int{1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 5, 6, 6, 7}
+
+// defaultMapZoom is a country-level view.
+const defaultMapZoom = 4
+
+// handleMapClusters counts the documents matching the /news filters per
+// geohash cell of the bbox, for a map heat view of where hot tours go.
+func (s *server) handleMapClusters(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	box, err := parseBBox(r.URL.Query().Get("bbox"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{
+			Error: err.Error(),
+			Hint:  "pass bbox=<west>,<south>,<east>,<north> in degrees, e.g. bbox=25,20,40,45",
+		})
+		return
+	}
+	params, err := s.parseSearchParams(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	zoom := defaultMapZoom
+	if raw := r.URL.Query().Get("zoom"); raw != "" {
+		if zoom, err = strconv.Atoi(raw); err != nil || zoom < 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "zoom must be a non-negative integer"})
+			return
+		}
+	}
+
+	result, err := s.es.ClusterNewsOnMap(ctx, params, box, zoomPrecisions[min(zoom, len(zoomPrecisions)-1)])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// parseBBox reads a bounding box in the west,south,east,north order of
+// GeoJSON. A west edge east of the east edge crosses the antimeridian.
+func parseBBox(raw string) (elasticsearch.BoundingBox, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return elasticsearch.BoundingBox{}, errors.New("bbox must have four comma-separated values")
+	}
+	var edges [4]float64
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return elasticsearch.BoundingBox{}, errors.New("bbox values must be numbers")
+		}
+		edges[i] = value
+	}
+	box := elasticsearch.BoundingBox{Left: edges[0], Bottom: edges[1], Right: edges[2], Top: edges[3]}
+	switch {
+	case box.Left < -180 || box.Left > 180 || box.Right < -180 || box.Right > 180:
+		return elasticsearch.BoundingBox{}, errors.New("bbox longitudes must be within -180 and 180")
+	case box.Bottom < -90 || box.Top > 90:
+		return elasticsearch.BoundingBox{}, errors.New("bbox latitudes must be within -90 and 90")
+	case box.Bottom > box.Top:
+		return elasticsearch.BoundingBox{}, errors.New("bbox south edge must not lie north of its north edge")
+	}
+	return box, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// maxMultiGetIDs bounds a single batch fetch.
+const maxMultiGetIDs = 100
+
+type multiGetRequest struct {
+	IDs []string `json:"ids"`
+}
+
+type multiGetItem struct {
+	ID       string               `json:"id"`
+	Found    bool                 `json:"found"`
+	Document *models.NewsDocument `json:"document,omitempty"`
+}
+
+type multiGetResponse struct {
+	Items []multiGetItem `json:"items"`
+}
+
+// handleMultiGetBody serves POST /news/mget for ID lists too long for a query string.
+func (s *server) handleMultiGetBody(w http.ResponseWriter, r *http.Request) {
+	var req multiGetRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: " + err.Error()})
+		return
+	}
+	s.multiGet(w, r, req.IDs)
+}
+
+// multiGet returns the documents with the given IDs in request order;
+// IDs without a document are reported with found=false.
+func (s *server) multiGet(w http.ResponseWriter, r *http.Request, raw []string) {
+	ids := make([]string, 0, len(raw))
+	for _, id := range raw {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "ids must not be empty"})
+		return
+	}
+	if len(ids) > maxMultiGetIDs {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "at most " + strconv.Itoa(maxMultiGetIDs) + " ids per request"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	docs, err := s.es.MultiGetNews(ctx, ids)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	items := make([]multiGetItem, len(ids))
+	for i, id := range ids {
+		items[i] = multiGetItem{ID: id, Found: docs[i] != nil, Document: docs[i]}
+	}
+	writeJSON(w, http.StatusOK, multiGetResponse{Items: items})
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+// errTooManyPITs is returned when API_PIT_MAX_OPEN snapshots are open.
+var errTooManyPITs = errors.New("too many open points in time, retry later")
+
+// pitSessions tracks the point-in-time snapshots opened by /news?pit=true.
+// Clients page with an opaque handle rather than the Elasticsearch ID,
+// which may change between pages and is too long for comfortable URLs.
+// The search parameters are fixed when the snapshot is opened, so every
+// page of a walk runs the same query.
+type pitSessions struct {
+	es        *elasticsearch.Client
+	log       *slog.Logger
+	maxOpen   int
+	keepAlive time.Duration
+	maxAge    time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*pitSession
+	pending  int
+	opened   int64
+	rejected int64
+	expired  int64
+}
+
+type pitSession struct {
+	id       string
+	params   elasticsearch.SearchParams
+	created  time.Time
+	lastUsed time.Time
+}
+
+// pitState is published under point_in_time at /debug/vars.
+type pitState struct {
+	Open     int   `json:"open"`
+	Opened   int64 `json:"opened"`
+	Rejected int64 `json:"rejected"`
+	Expired  int64 `json:"expired"`
+}
+
+func newPITSessions(es *elasticsearch.Client, log *slog.Logger, maxOpen int, keepAlive, maxAge time.Duration) *pitSessions {
+	return &pitSessions{
+		es:        es,
+		log:       log,
+		maxOpen:   maxOpen,
+		keepAlive: keepAlive,
+		maxAge:    maxAge,
+		sessions:  map[string]*pitSession{},
+	}
+}
+
+// open opens a snapshot for params and returns its handle.
+func (p *pitSessions) open(ctx context.Context, params elasticsearch.SearchParams, now time.Time) (string, pitSession, error) {
+	p.mu.Lock()
+	p.sweepLocked(ctx, now)
+	if len(p.sessions)+p.pending >= p.maxOpen {
+		p.rejected++
+		p.mu.Unlock()
+		return "", pitSession{}, errTooManyPITs
+	}
+	p.pending++
+	p.mu.Unlock()
+
+	id, err := p.es.OpenPointInTime(ctx, p.keepAlive)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending--
+	if err != nil {
+		return "", pitSession{}, err
+	}
+	var raw [16]byte
+	_, _ = rand.Read(raw[:])
+	handle := base64.RawURLEncoding.EncodeToString(raw[:])
+	session := &pitSession{id: id, params: params, created: now, lastUsed: now}
+	p.sessions[handle] = session
+	p.opened++
+	return handle, *session, nil
+}
+
+// get returns the live session behind handle.
+func (p *pitSessions) get(ctx context.Context, handle string, now time.Time) (pitSession, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sweepLocked(ctx, now)
+	session, ok := p.sessions[handle]
+	if !ok {
+		return pitSession{}, false
+	}
+	return *session, true
+}
+
+// touch records a served page: Elasticsearch extended the snapshot by the
+// keep-alive and may have handed out a new ID for it.
+func (p *pitSessions) touch(handle, id string, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if session, ok := p.sessions[handle]; ok {
+		session.lastUsed = now
+		if id != "" {
+			session.id = id
+		}
+	}
+}
+
+// close frees the snapshot behind handle and reports whether it was open.
+func (p *pitSessions) close(ctx context.Context, handle string) bool {
+	p.mu.Lock()
+	session, ok := p.sessions[handle]
+	delete(p.sessions, handle)
+	p.mu.Unlock()
+	if ok {
+		p.release(ctx, session.id)
+	}
+	return ok
+}
+
+// sweepLocked forgets snapshots idle for longer than the keep-alive, which
+// Elasticsearch has already freed, and closes those older than maxAge.
+func (p *pitSessions) sweepLocked(ctx context.Context, now time.Time) {
+	for handle, session := range p.sessions {
+		idle := now.Sub(session.lastUsed) > p.keepAlive
+		if !idle && now.Sub(session.created) <= p.maxAge {
+			continue
+		}
+		delete(p.sessions, handle)
+		p.expired++
+		if !idle {
+			go p.release(ctx, session.id)
+		}
+	}
+}
+
+// release closes a snapshot in Elasticsearch. It runs after the request
+// that ended the walk may be gone, so it keeps only the values of ctx, for
+// the log line, and has its own timeout.
+func (p *pitSessions) release(ctx context.Context, id string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := p.es.ClosePointInTime(ctx, id); err != nil && !errors.Is(err, elasticsearch.ErrNotFound) {
+		p.log.WarnContext(ctx, "close point in time", slog.Any("err", err))
+	}
+}
+
+// run sweeps expired snapshots until ctx ends and then closes the rest.
+func (p *pitSessions) run(ctx context.Context) {
+	ticker := time.NewTicker(p.keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			p.mu.Lock()
+			p.sweepLocked(ctx, now)
+			p.mu.Unlock()
+		case <-ctx.Done():
+			p.mu.Lock()
+			sessions := p.sessions
+			p.sessions = map[string]*pitSession{}
+			p.mu.Unlock()
+			for _, session := range sessions {
+				p.release(ctx, session.id)
+			}
+			return
+		}
+	}
+}
+
+// vars is the expvar.Func behind point_in_time.
+func (p *pitSessions) vars() any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return pitState{Open: len(p.sessions), Opened: p.opened, Rejected: p.rejected, Expired: p.expired}
+}
+
+// searchPIT serves a /news page from a point-in-time snapshot: pit=true
+// opens one for the request's filters and returns its first page, and
+// pit=<handle>&after=<Next> continues it. A page shorter than size ends
+// the walk and closes the snapshot.
+func (s *server) searchPIT(w http.ResponseWriter, r *http.Request, params elasticsearch.SearchParams, facets facetRequest) {
+	if s.pits == nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "point-in-time pagination is disabled"})
+		return
+	}
+	if len(facets.Fields) > 0 || facets.Histogram != "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "pit cannot be combined with facets or histogram"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var (
+		handle  = r.URL.Query().Get("pit")
+		session pitSession
+		after   json.RawMessage
+		err     error
+	)
+	if handle == "true" {
+		// Hits are walked with search_after; relaxing the query midway
+		// would change what the walk returns.
+		params.From, params.Relax = 0, false
+		handle, session, err = s.pits.open(ctx, params, time.Now())
+		if errors.Is(err, errTooManyPITs) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(s.cfg.PITKeepAlive.Seconds())))
+			writeJSON(w, http.StatusTooManyRequests, errorResponse{Error: err.Error()})
+			return
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	} else {
+		var ok bool
+		if session, ok = s.pits.get(ctx, handle, time.Now()); !ok {
+			writeJSON(w, http.StatusGone, errorResponse{Error: "point in time expired or unknown, start again with pit=true"})
+			return
+		}
+		if raw := r.URL.Query().Get("after"); raw != "" {
+			if after, err = decodeCursor(raw); err != nil {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid after cursor"})
+				return
+			}
+		}
+	}
+
+	params = session.params
+	params.PIT = &elasticsearch.PointInTime{ID: session.id, KeepAlive: s.cfg.PITKeepAlive, SearchAfter: after}
+	result, err := s.es.SearchNews(ctx, params)
+	if errors.Is(err, elasticsearch.ErrNotFound) {
+		s.pits.close(ctx, handle)
+		writeJSON(w, http.StatusGone, errorResponse{Error: "point in time expired or unknown, start again with pit=true"})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := searchResponse{SearchResult: result, PIT: handle}
+	if len(result.Items) >= params.Size && len(result.SearchAfter) > 0 {
+		resp.Next = base64.RawURLEncoding.EncodeToString(result.SearchAfter)
+		s.pits.touch(handle, result.PIT, time.Now())
+	} else {
+		s.pits.close(ctx, handle)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// decodeCursor reads an after cursor: the base64url sort values of the
+// previous page's last hit.
+func decodeCursor(raw string) (json.RawMessage, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var values []any
+	if err := json.Unmarshal(data, &values); err != nil || len(values) == 0 {
+		return nil, errors.New("not a sort value list")
+	}
+	return data, nil
+}
+
+// handleClosePIT ends a walk early and frees its snapshot.
+func (s *server) handleClosePIT(w http.ResponseWriter, r *http.Request) {
+	if s.pits == nil || !s.pits.close(r.Context(), chi.URLParam(r, "handle")) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "point in time expired or unknown"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
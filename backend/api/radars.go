@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// savedRadar loads the saved search named by the searchID route parameter
+// and turns it into search parameters for its newest matches. Its ID is
+// random, so knowing it is what grants access to the radar.
+func (s *server) savedRadar(w http.ResponseWriter, r *http.Request) (*models.SavedSearch, elasticsearch.SearchParams, bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	search, err := s.es.SavedSearch(ctx, chi.URLParam(r, "searchID"))
+	if err != nil {
+		writeError(w, err)
+		return nil, elasticsearch.SearchParams{}, false
+	}
+	params := elasticsearch.SearchParams{
+		Keywords:    search.Keywords,
+		Destination: search.Destination,
+		MaxPrice:    search.MaxPrice,
+		Size:        feedSize,
+	}
+	return search, params, true
+}
+
+// handleRadarFeed renders the newest documents matching a saved search as
+// RSS, so a radar set up in the bot can be followed from a feed reader.
+func (s *server) handleRadarFeed(w http.ResponseWriter, r *http.Request) {
+	search, params, ok := s.savedRadar(w, r)
+	if !ok {
+		return
+	}
+	s.writeFeed(w, r, params, "Hot Tour Radar: "+radarTitle(search), "Newest tour offers matching a saved search")
+}
+
+// handleRadarCalendar exports the travel dates of the newest documents
+// matching a saved search as an iCalendar file.
+func (s *server) handleRadarCalendar(w http.ResponseWriter, r *http.Request) {
+	search, params, ok := s.savedRadar(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	s.writeSearchCalendar(ctx, w, params, "radar-"+search.ID+".ics")
+}
+
+// radarTitle describes the criteria of a saved search, e.g.
+// "турция, пляж, до 60000 ₽".
+func radarTitle(search *models.SavedSearch) string {
+	var parts []string
+	if search.Destination != "" {
+		parts = append(parts, search.Destination)
+	}
+	parts = append(parts, search.Keywords...)
+	if search.MaxPrice > 0 {
+		parts = append(parts, fmt.Sprintf("до %d ₽", search.MaxPrice))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateSweepInterval is how often buckets of clients that stopped calling
+// are dropped.
+const rateSweepInterval = time.Minute
+
+// rateLimiter gives every client a token bucket refilled at rate tokens per
+// second up to burst; a request takes one token and is rejected with 429
+// when none is left. Partners are told apart by their API key, everyone
+// else by client IP, so inventing keys does not buy a fresh bucket.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	// isPartner reports whether the request carries a partner key.
+	isPartner func(*http.Request) bool
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	limited   int64
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateState is published under rate_limiting at /debug/vars.
+type rateState struct {
+	Clients int   `json:"clients"`
+	Limited int64 `json:"limited_requests"`
+}
+
+func newRateLimiter(rate float64, burst int, isPartner func(*http.Request) bool) *rateLimiter {
+	return &rateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		isPartner: isPartner,
+		buckets:   map[string]*tokenBucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// take spends a token of client's bucket. When the bucket is empty it
+// returns false and how long until the next token.
+func (l *rateLimiter) take(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	l.limited++
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely: a new bucket starts
+// full, so forgetting them changes nothing.
+func (l *rateLimiter) sweep(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// vars is the expvar.Func behind rate_limiting.
+func (l *rateLimiter) vars() any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return rateState{Clients: len(l.buckets), Limited: l.limited}
+}
+
+// clientKey identifies the caller: a hash of the partner key, so keys are
+// not held in memory in the clear, or the client IP, which realIP only
+// takes from forwarding headers set by trusted proxies.
+func (l *rateLimiter) clientKey(r *http.Request) string {
+	if l.isPartner(r) {
+		sum := sha256.Sum256([]byte(strings.TrimSpace(r.Header.Get(apiKeyHeader))))
+		return "key:" + hex.EncodeToString(sum[:8])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// realIP sets RemoteAddr to the bare forwarded address.
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// middleware rejects requests over the client's rate with 429. The probe
+// endpoints are exempt so probes behind a shared address keep working.
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/livez", "/readyz", "/health":
+			next.ServeHTTP(w, r)
+			return
+		}
+		ok, wait := l.take(l.clientKey(r), time.Now())
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeJSON(w, http.StatusTooManyRequests, errorResponse{Error: "rate limit exceeded, retry later"})
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func isTestPartner(r *http.Request) bool { return r.Header.Get(apiKeyHeader) == "partner" }
+
+func TestRateLimiterRefills(t *testing.T) {
+	l := newRateLimiter(2, 3, isTestPartner)
+	now := time.Now()
+
+	for range 3 {
+		ok, _ := l.take("ip:192.0.2.1", now)
+		require.True(t, ok)
+	}
+	ok, wait := l.take("ip:192.0.2.1", now)
+	require.False(t, ok)
+	require.Equal(t, 500*time.Millisecond, wait)
+
+	// Other clients have buckets of their own.
+	ok, _ = l.take("ip:192.0.2.2", now)
+	require.True(t, ok)
+
+	// Half a second at two tokens per second buys one more request.
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = l.take("ip:192.0.2.1", now)
+	require.True(t, ok)
+	ok, _ = l.take("ip:192.0.2.1", now)
+	require.False(t, ok)
+
+	// The bucket never holds more than burst.
+	now = now.Add(time.Hour)
+	for range 3 {
+		ok, _ = l.take("ip:192.0.2.1", now)
+		require.True(t, ok)
+	}
+	ok, _ = l.take("ip:192.0.2.1", now)
+	require.False(t, ok)
+	require.Equal(t, int64(3), l.vars().(rateState).Limited)
+}
+
+func TestRateLimiterSweepsIdleBuckets(t *testing.T) {
+	l := newRateLimiter(1, 2, isTestPartner)
+	now := time.Now()
+
+	l.take("ip:192.0.2.1", now)
+	l.take("ip:192.0.2.2", now)
+	require.Equal(t, 2, l.vars().(rateState).Clients)
+
+	// After the sweep interval the first client's bucket is full again and
+	// dropped, while the second, still spending, keeps its bucket.
+	now = now.Add(rateSweepInterval - time.Second)
+	l.take("ip:192.0.2.2", now)
+	l.take("ip:192.0.2.2", now)
+	now = now.Add(time.Second)
+	l.take("ip:192.0.2.3", now)
+	require.Equal(t, 2, l.vars().(rateState).Clients)
+	_, kept := l.buckets["ip:192.0.2.2"]
+	require.True(t, kept)
+	_, kept = l.buckets["ip:192.0.2.1"]
+	require.False(t, kept)
+}
+
+func TestRateLimiterClientKey(t *testing.T) {
+	l := newRateLimiter(1, 1, isTestPartner)
+
+	req := httptest.NewRequest(http.MethodGet, "/news", nil)
+	req.RemoteAddr = "192.0.2.1:5555"
+	require.Equal(t, "ip:192.0.2.1", l.clientKey(req))
+
+	// Inventing keys does not buy a fresh bucket: only partner keys count.
+	req.Header.Set(apiKeyHeader, "made-up")
+	require.Equal(t, "ip:192.0.2.1", l.clientKey(req))
+
+	req.Header.Set(apiKeyHeader, "partner")
+	key := l.clientKey(req)
+	require.Regexp(t, `^key:[0-9a-f]{16}$`, key)
+	req.RemoteAddr = "192.0.2.2:5555"
+	require.Equal(t, key, l.clientKey(req))
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	l := newRateLimiter(0.5, 1, isTestPartner)
+	handler := l.middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "192.0.2.1:5555"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusNoContent, serve("/news").Code)
+	rec := serve("/news")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "2", rec.Header().Get("Retry-After"))
+	require.JSONEq(t, `{"error":"rate limit exceeded, retry later"}`, rec.Body.String())
+
+	// Probes are never limited.
+	for _, path := range []string{"/livez", "/readyz", "/health"} {
+		require.Equal(t, http.StatusNoContent, serve(path).Code, path)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// apiKeyHeader identifies the reader whose seen markers are used. Keys
+	// are opaque strings chosen by the client; only their hash is stored.
+	apiKeyHeader   = "X-API-Key"
+	maxAPIKeyBytes = 256
+	// maxSeenPerRequest bounds the markers one POST /seen may add.
+	maxSeenPerRequest = 1000
+)
+
+type seenRequest struct {
+	IDs        []string `json:"ids"`
+	ClusterIDs []string `json:"cluster_ids"`
+}
+
+// readerID derives the read state key from the X-API-Key header.
+func readerID(r *http.Request) (string, bool) {
+	key := strings.TrimSpace(r.Header.Get(apiKeyHeader))
+	if key == "" || len(key) > maxAPIKeyBytes {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]), true
+}
+
+func writeMissingAPIKey(w http.ResponseWriter) {
+	writeJSON(w, http.StatusBadRequest, errorResponse{Error: apiKeyHeader + " header of at most " + strconv.Itoa(maxAPIKeyBytes) + " bytes is required"})
+}
+
+// handleMarkSeen records documents and clusters the reader has reviewed,
+// so /news?unseen_only=true skips them.
+func (s *server) handleMarkSeen(w http.ResponseWriter, r *http.Request) {
+	reader, ok := readerID(r)
+	if !ok {
+		writeMissingAPIKey(w)
+		return
+	}
+
+	var req seenRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 256<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: " + err.Error()})
+		return
+	}
+	ids, clusterIDs := normalizeIDs(req.IDs), normalizeIDs(req.ClusterIDs)
+	switch {
+	case len(ids) == 0 && len(clusterIDs) == 0:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "set at least one of ids, cluster_ids"})
+		return
+	case len(ids)+len(clusterIDs) > maxSeenPerRequest:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "at most " + strconv.Itoa(maxSeenPerRequest) + " markers per request"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := s.es.MarkSeen(ctx, reader, ids, clusterIDs); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleClearSeen forgets every marker of the reader.
+func (s *server) handleClearSeen(w http.ResponseWriter, r *http.Request) {
+	reader, ok := readerID(r)
+	if !ok {
+		writeMissingAPIKey(w)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := s.es.ClearSeen(ctx, reader); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// normalizeIDs trims IDs and drops empty ones and duplicates.
+func normalizeIDs(raw []string) []string {
+	seen := make(map[string]struct{}, len(raw))
+	out := make([]string, 0, len(raw))
+	for _, id := range raw {
+		id = strings.TrimSpace(id)
+		if _, dup := seen[id]; id == "" || dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// realIP replaces RemoteAddr with the client address that trusted proxies
+// forwarded, so rate limiting, experiment bucketing and logs see the
+// client rather than the proxy. Forwarding headers are only believed on
+// connections from a proxy in API_TRUSTED_PROXIES; anyone else could set
+// them to whatever they like.
+type realIP struct {
+	trusted []netip.Prefix
+}
+
+// newRealIP parses API_TRUSTED_PROXIES, which LoadAPI has validated: each
+// entry is an address or a CIDR prefix.
+func newRealIP(proxies []string) *realIP {
+	ip := &realIP{}
+	for _, raw := range proxies {
+		if prefix, err := netip.ParsePrefix(raw); err == nil {
+			ip.trusted = append(ip.trusted, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(raw); err == nil {
+			ip.trusted = append(ip.trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return ip
+}
+
+func (ip *realIP) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if client, ok := ip.client(r); ok {
+			r.RemoteAddr = client
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// client returns the forwarded client address when the peer is a trusted
+// proxy. X-Forwarded-For is read from the right, skipping trusted proxies,
+// since only the entries they appended can be believed; X-Real-IP is used
+// when there is none.
+func (ip *realIP) client(r *http.Request) (string, bool) {
+	peer, ok := remoteAddr(r)
+	if !ok || !ip.isTrusted(peer) {
+		return "", false
+	}
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	client := netip.Addr{}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !ip.isTrusted(client) {
+			break
+		}
+	}
+	if !client.IsValid() {
+		addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+		if err != nil {
+			return "", false
+		}
+		client = addr.Unmap()
+	}
+	return client.String(), true
+}
+
+func (ip *realIP) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range ip.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteAddr parses the peer address of the connection.
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	return addr.Unmap(), err == nil
+}
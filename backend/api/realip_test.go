@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRealIP(t *testing.T) {
+	ip := newRealIP([]string{"10.0.0.0/8", "192.168.1.10"})
+	tests := []struct {
+		name   string
+		peer   string
+		header http.Header
+		want   string
+	}{
+		{
+			name: "untrusted peer cannot spoof",
+			peer: "198.51.100.7:4000",
+			header: http.Header{
+				"X-Forwarded-For": {"203.0.113.1"},
+				"X-Real-Ip":       {"203.0.113.2"},
+			},
+			want: "198.51.100.7:4000",
+		},
+		{
+			name:   "trusted proxy forwards the client",
+			peer:   "10.1.2.3:4000",
+			header: http.Header{"X-Forwarded-For": {"203.0.113.1"}},
+			want:   "203.0.113.1",
+		},
+		{
+			name:   "client-supplied entries left of the proxy are ignored",
+			peer:   "10.1.2.3:4000",
+			header: http.Header{"X-Forwarded-For": {"1.1.1.1, 203.0.113.1, 192.168.1.10"}},
+			want:   "203.0.113.1",
+		},
+		{
+			name:   "repeated headers are read as one list",
+			peer:   "10.1.2.3:4000",
+			header: http.Header{"X-Forwarded-For": {"1.1.1.1", "203.0.113.1"}},
+			want:   "203.0.113.1",
+		},
+		{
+			name:   "only proxies in the chain",
+			peer:   "10.1.2.3:4000",
+			header: http.Header{"X-Forwarded-For": {"10.0.0.1, 10.0.0.2"}},
+			want:   "10.0.0.1",
+		},
+		{
+			name:   "X-Real-IP without X-Forwarded-For",
+			peer:   "192.168.1.10:4000",
+			header: http.Header{"X-Real-Ip": {"2001:db8::1"}},
+			want:   "2001:db8::1",
+		},
+		{
+			name:   "garbage is ignored",
+			peer:   "10.1.2.3:4000",
+			header: http.Header{"X-Real-Ip": {"not-an-ip"}},
+			want:   "10.1.2.3:4000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := ip.middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) { got = r.RemoteAddr }))
+			req := httptest.NewRequest(http.MethodGet, "/news", nil)
+			req.RemoteAddr = tt.peer
+			req.Header = tt.header
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	// Without trusted proxies forwarding headers are never believed.
+	req := httptest.NewRequest(http.MethodGet, "/news", nil)
+	req.RemoteAddr = "10.1.2.3:4000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+	_, ok := newRealIP(nil).client(req)
+	require.False(t, ok)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// handleRedirect sends the client to one of a document's stored URLs and
+// records the click. Recording happens in the background so a slow or
+// failing stats write never delays the redirect.
+func (s *server) handleRedirect(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	docID := chi.URLParam(r, "docID")
+	urlIndex, err := strconv.Atoi(chi.URLParam(r, "urlIndex"))
+	if err != nil || urlIndex < 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "url index must be a non-negative integer"})
+		return
+	}
+
+	doc, err := s.es.GetNewsByID(ctx, docID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if urlIndex >= len(doc.URLs) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "document has no url with this index"})
+		return
+	}
+
+	target := doc.URLs[urlIndex]
+	click := models.ClickEvent{
+		DocumentID: doc.ID,
+		URL:        target,
+		URLIndex:   urlIndex,
+		Source:     doc.Source,
+		Referer:    r.Referer(),
+		Timestamp:  time.Now().UTC(),
+	}
+	if arm := s.experiment.arm(r); arm != "" {
+		click.Experiment, click.Arm = s.experiment.name, arm
+	}
+	go func() {
+		recordCtx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+		defer cancel()
+		if err := s.es.RecordClick(recordCtx, click); err != nil {
+			s.log.WarnContext(r.Context(), "record click", slog.String("doc_id", click.DocumentID), slog.Any("err", err))
+		}
+	}()
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target, http.StatusFound)
+}
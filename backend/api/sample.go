@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+// sampleTimeout bounds the Elasticsearch side of a sample request.
+const sampleTimeout = 30 * time.Second
+
+type sampleResponse struct {
+	*elasticsearch.SearchResult
+	Seed int64
+}
+
+// handleSample returns a deterministic random sample of documents matching
+// the /news filters. Repeating a request with the same seed yields the same
+// sample as long as the index is unchanged.
+func (s *server) handleSample(w http.ResponseWriter, r *http.Request) {
+	// Sampling up to MaxSampleSize documents can outlast the server-wide
+	// write deadline, so extend it for this request only.
+	ctx, cancel := context.WithTimeout(r.Context(), sampleTimeout)
+	defer cancel()
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(sampleTimeout + 5*time.Second))
+
+	params, err := s.parseSearchParams(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	n := clampInt(r.URL.Query().Get("n"), 100, elasticsearch.MaxSampleSize)
+
+	seed := time.Now().UnixNano()
+	if raw := strings.TrimSpace(r.URL.Query().Get("seed")); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "seed must be an integer"})
+			return
+		}
+		seed = parsed
+	}
+
+	result, err := s.es.SampleNews(ctx, params, n, seed)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sampleResponse{SearchResult: result, Seed: seed})
+}
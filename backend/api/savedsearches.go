@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// maxSavedSearchesPerChat keeps one chat from flooding the alerter.
+const maxSavedSearchesPerChat = 20
+
+type savedSearchRequest struct {
+	ChatID      int64    `json:"chat_id"`
+	Keywords    []string `json:"keywords"`
+	Destination string   `json:"destination"`
+	MaxPrice    int      `json:"max_price"`
+}
+
+type savedSearchesResponse struct {
+	Items []models.SavedSearch `json:"items"`
+}
+
+func (s *server) handleCreateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	var req savedSearchRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	search := models.SavedSearch{
+		ID:        uuid.NewString(),
+		ChatID:    req.ChatID,
+		Keywords:  s.keywordFilter(normalizeKeywords(req.Keywords)),
+		MaxPrice:  req.MaxPrice,
+		CreatedAt: time.Now().UTC(),
+	}
+	if raw := strings.TrimSpace(req.Destination); raw != "" {
+		search.Destination = s.taxonomy().Canonical(raw)
+	}
+
+	switch {
+	case search.ChatID == 0:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "chat_id is required"})
+		return
+	case search.MaxPrice < 0:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "max_price cannot be negative"})
+		return
+	case len(search.Keywords) == 0 && search.Destination == "" && search.MaxPrice == 0:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "set at least one of keywords, destination, max_price"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	existing, err := s.es.SavedSearches(ctx, search.ChatID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(existing) >= maxSavedSearchesPerChat {
+		writeJSON(w, http.StatusConflict, errorResponse{Error: "at most " + strconv.Itoa(maxSavedSearchesPerChat) + " saved searches per chat"})
+		return
+	}
+
+	if err := s.es.CreateSavedSearch(ctx, search); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, search)
+}
+
+func (s *server) handleListSavedSearches(w http.ResponseWriter, r *http.Request) {
+	chatID, err := strconv.ParseInt(r.URL.Query().Get("chat_id"), 10, 64)
+	if err != nil || chatID == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "chat_id is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	searches, err := s.es.SavedSearches(ctx, chatID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, savedSearchesResponse{Items: searches})
+}
+
+func (s *server) handleDeleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := s.es.DeleteSavedSearch(ctx, chi.URLParam(r, "searchID")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/reltime"
+)
+
+// searchCache keeps /news results for API_SEARCH_CACHE_TTL, keyed on the
+// parsed search parameters, so dashboards polling the same query every few
+// seconds do not each reach Elasticsearch. Results are stored unmasked;
+// masking happens when they are written. A nil *searchCache caches nothing.
+type searchCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu            sync.Mutex
+	entries       map[string]cachedSearch
+	hits          int64
+	misses        int64
+	invalidations int64
+}
+
+type cachedSearch struct {
+	result  *searchResponse
+	expires time.Time
+}
+
+// searchCacheState is published under search_cache at /debug/vars.
+type searchCacheState struct {
+	Entries       int   `json:"entries"`
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Invalidations int64 `json:"invalidations"`
+}
+
+func newSearchCache(ttl time.Duration, maxEntries int) *searchCache {
+	return &searchCache{ttl: ttl, maxEntries: maxEntries, entries: map[string]cachedSearch{}}
+}
+
+// searchCacheKey hashes the parameters, which already hold the normalized
+// query, keywords and canonical destination, so equivalent spellings of a
+// query share an entry.
+func searchCacheKey(params elasticsearch.SearchParams, facets facetRequest) (string, bool) {
+	data, err := json.Marshal(struct {
+		Params elasticsearch.SearchParams
+		Facets facetRequest
+	}{params, facets})
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), true
+}
+
+func (c *searchCache) get(key string, now time.Time) (*searchResponse, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || now.After(entry.expires) {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	return entry.result, true
+}
+
+// put stores result. When the cache is full, expired entries are dropped
+// first and then arbitrary ones.
+func (c *searchCache) put(key string, result *searchResponse, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedSearch{result: result, expires: now.Add(c.ttl)}
+}
+
+// invalidate drops every entry. It is called when the index is known to
+// have changed: a document arrived on the live stream topic or an admin
+// retagged documents.
+func (c *searchCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) > 0 {
+		clear(c.entries)
+		c.invalidations++
+	}
+}
+
+// vars is the expvar.Func behind search_cache.
+func (c *searchCache) vars() any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return searchCacheState{Entries: len(c.entries), Hits: c.hits, Misses: c.misses, Invalidations: c.invalidations}
+}
+
+// writeCachedJSON writes a cacheable 200 response with an ETag computed
+// over the masked body, answering 304 when the client already holds it.
+// Partner responses are private, so shared caches do not hand them to
+// other callers.
+func (s *server) writeCachedJSON(w http.ResponseWriter, r *http.Request, payload any) {
+	data, err := json.Marshal(payload)
+	if err == nil {
+		data, err = maskFor(w).JSON(data)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:12]) + `"`
+	// Ages change every second; a body differing only in them is
+	// equivalent, so the tag is computed before they are added and weak.
+	if locale := localeFor(w); locale != "" {
+		etag = "W/" + etag
+		data, err = reltime.AddAges(data, time.Now(), locale)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+			return
+		}
+	}
+	scope := "public"
+	if s.isPartner(r) {
+		scope = "private"
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", scope+", max-age="+strconv.Itoa(int(s.cfg.SearchCacheTTL.Seconds())))
+	w.Header().Add("Vary", apiKeyHeader)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(append(data, '\n'))
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. The
+// comparison is weak, as If-None-Match requires.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
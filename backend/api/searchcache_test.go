@@ -0,0 +1,197 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+func TestSearchCache(t *testing.T) {
+	const ttl = 5 * time.Second
+	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	result := func(total int64) *searchResponse {
+		return &searchResponse{SearchResult: &elasticsearch.SearchResult{Total: total}}
+	}
+
+	tests := []struct {
+		name       string
+		maxEntries int
+		run        func(c *searchCache)
+		// lookups are made after run, at the given time.
+		lookups []lookup
+		want    searchCacheState
+	}{
+		{
+			name:       "hit",
+			maxEntries: 10,
+			run:        func(c *searchCache) { c.put("a", result(1), start) },
+			lookups:    []lookup{{key: "a", at: start.Add(time.Second), total: 1}},
+			want:       searchCacheState{Entries: 1, Hits: 1},
+		},
+		{
+			name:       "miss",
+			maxEntries: 10,
+			run:        func(c *searchCache) { c.put("a", result(1), start) },
+			lookups:    []lookup{{key: "b", at: start}},
+			want:       searchCacheState{Entries: 1, Misses: 1},
+		},
+		{
+			name:       "replacing an entry renews it",
+			maxEntries: 10,
+			run: func(c *searchCache) {
+				c.put("a", result(1), start)
+				c.put("a", result(2), start.Add(ttl))
+			},
+			lookups: []lookup{{key: "a", at: start.Add(ttl + time.Second), total: 2}},
+			want:    searchCacheState{Entries: 1, Hits: 1},
+		},
+		{
+			name:       "expires after the TTL",
+			maxEntries: 10,
+			run:        func(c *searchCache) { c.put("a", result(1), start) },
+			lookups: []lookup{
+				{key: "a", at: start.Add(ttl), total: 1},
+				{key: "a", at: start.Add(ttl + time.Nanosecond)},
+			},
+			want: searchCacheState{Entries: 1, Hits: 1, Misses: 1},
+		},
+		{
+			name:       "invalidation drops every entry",
+			maxEntries: 10,
+			run: func(c *searchCache) {
+				c.put("a", result(1), start)
+				c.put("b", result(2), start)
+				c.invalidate()
+				// An empty cache has nothing to invalidate.
+				c.invalidate()
+			},
+			lookups: []lookup{{key: "a", at: start}, {key: "b", at: start}},
+			want:    searchCacheState{Misses: 2, Invalidations: 1},
+		},
+		{
+			name:       "eviction keeps the bound",
+			maxEntries: 2,
+			run: func(c *searchCache) {
+				c.put("a", result(1), start)
+				c.put("b", result(2), start)
+				c.put("c", result(3), start)
+			},
+			lookups: []lookup{{key: "c", at: start, total: 3}},
+			want:    searchCacheState{Entries: 2, Hits: 1},
+		},
+		{
+			name:       "eviction drops expired entries first",
+			maxEntries: 2,
+			run: func(c *searchCache) {
+				c.put("a", result(1), start)
+				c.put("b", result(2), start.Add(ttl))
+				c.put("c", result(3), start.Add(ttl+time.Second))
+			},
+			lookups: []lookup{
+				{key: "b", at: start.Add(ttl + time.Second), total: 2},
+				{key: "c", at: start.Add(ttl + time.Second), total: 3},
+			},
+			want: searchCacheState{Entries: 2, Hits: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newSearchCache(ttl, tt.maxEntries)
+			tt.run(c)
+			for _, l := range tt.lookups {
+				got, ok := c.get(l.key, l.at)
+				if l.total == 0 {
+					require.False(t, ok, l.key)
+					continue
+				}
+				require.True(t, ok, l.key)
+				require.Equal(t, l.total, got.Total)
+			}
+			require.Equal(t, tt.want, c.vars())
+		})
+	}
+
+	// A nil cache caches nothing.
+	var c *searchCache
+	c.put("a", result(1), start)
+	_, ok := c.get("a", start)
+	require.False(t, ok)
+	c.invalidate()
+}
+
+type lookup struct {
+	key string
+	at  time.Time
+	// total is the cached result's Total, or zero for a miss.
+	total int64
+}
+
+func TestSearchCacheKey(t *testing.T) {
+	params := elasticsearch.SearchParams{Query: "турция", Keywords: []string{"горящий"}}
+	key, ok := searchCacheKey(params, facetRequest{})
+	require.True(t, ok)
+
+	same, _ := searchCacheKey(elasticsearch.SearchParams{Query: "турция", Keywords: []string{"горящий"}}, facetRequest{})
+	require.Equal(t, key, same)
+
+	other, _ := searchCacheKey(params, facetRequest{Fields: []string{"source"}})
+	require.NotEqual(t, key, other)
+	params.Source = "telegram"
+	other, _ = searchCacheKey(params, facetRequest{})
+	require.NotEqual(t, key, other)
+}
+
+func TestWriteCachedJSON(t *testing.T) {
+	srv := &server{cfg: &config.API{SearchCacheTTL: 5 * time.Second, PartnerKeys: []string{"partner"}}}
+	payload := map[string]int{"total": 3}
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/news", nil)
+		req.Header = header
+		rec := httptest.NewRecorder()
+		srv.writeCachedJSON(rec, req, payload)
+		return rec
+	}
+
+	first := serve(http.Header{})
+	require.Equal(t, http.StatusOK, first.Code)
+	require.JSONEq(t, `{"total":3}`, first.Body.String())
+	require.Equal(t, "public, max-age=5", first.Header().Get("Cache-Control"))
+	require.Equal(t, []string{apiKeyHeader}, first.Header().Values("Vary"))
+	etag := first.Header().Get("ETag")
+	require.Regexp(t, `^"[0-9a-f]{24}"$`, etag)
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        int
+	}{
+		{name: "same tag", ifNoneMatch: etag, want: http.StatusNotModified},
+		{name: "weak tag", ifNoneMatch: "W/" + etag, want: http.StatusNotModified},
+		{name: "one of several", ifNoneMatch: `"stale", ` + etag, want: http.StatusNotModified},
+		{name: "any", ifNoneMatch: "*", want: http.StatusNotModified},
+		{name: "other tag", ifNoneMatch: `"stale"`, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(http.Header{"If-None-Match": {tt.ifNoneMatch}})
+			require.Equal(t, tt.want, rec.Code)
+			require.Equal(t, etag, rec.Header().Get("ETag"))
+			if tt.want == http.StatusNotModified {
+				require.Empty(t, rec.Body.String())
+			}
+		})
+	}
+
+	// Partner responses must not be kept by shared caches.
+	header := http.Header{}
+	header.Set(apiKeyHeader, "partner")
+	rec := serve(header)
+	require.Equal(t, "private, max-age=5", rec.Header().Get("Cache-Control"))
+	require.Equal(t, etag, rec.Header().Get("ETag"))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// shareParams are the /news parameters a share link carries. Paging is left
+// out so a shared view always opens at its first page.
+var shareParams = []string{
+	"q", "keywords", "source", "destination", "mention", "start", "end", "sort",
+	"active_only", "has_price", "has_url", "has_travel_dates", "min_price", "max_price",
+	"min_quality", "currency", "boost_keywords",
+}
+
+// shareSignatureBytes truncates the HMAC to keep links short; 96 bits are
+// still far beyond guessing range.
+const shareSignatureBytes = 12
+
+type shareResponse struct {
+	Token       string `json:"token"`
+	URL         string `json:"url"`
+	TelegramURL string `json:"telegram_url,omitempty"`
+}
+
+type sharedViewResponse struct {
+	Query  string            `json:"query"`
+	Params map[string]string `json:"params"`
+}
+
+// handleShare signs the search parameters of the request into a token that
+// /s/{token} resolves back to the same filter set.
+func (s *server) handleShare(w http.ResponseWriter, r *http.Request) {
+	values := url.Values{}
+	for _, name := range shareParams {
+		if v := strings.TrimSpace(r.URL.Query().Get(name)); v != "" {
+			values.Set(name, v)
+		}
+	}
+	if len(values) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "nothing to share: set at least one search parameter"})
+		return
+	}
+
+	token := s.signShare(values, time.Now())
+	resp := shareResponse{Token: token, URL: "/s/" + token}
+	if base := strings.TrimRight(s.cfg.PublicURL, "/"); base != "" {
+		resp.URL = base + resp.URL
+		resp.TelegramURL = "https://t.me/share/url?url=" + url.QueryEscape(resp.URL)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleResolveShare verifies a share token and returns the filter set it
+// encodes, both as a /news query string and as individual parameters.
+func (s *server) handleResolveShare(w http.ResponseWriter, r *http.Request) {
+	values, err := s.verifyShare(chi.URLParam(r, "token"), time.Now())
+	switch {
+	case errors.Is(err, errShareExpired):
+		writeJSON(w, http.StatusGone, errorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "unknown or tampered share link"})
+		return
+	}
+
+	params := make(map[string]string, len(values))
+	for name := range values {
+		params[name] = values.Get(name)
+	}
+	writeJSON(w, http.StatusOK, sharedViewResponse{Query: values.Encode(), Params: params})
+}
+
+// shareExpiresParam carries the expiry of a link, in Unix seconds, inside
+// the signed payload when API_SHARE_TTL is set.
+const shareExpiresParam = "exp"
+
+var (
+	errShareInvalid = errors.New("invalid share link")
+	errShareExpired = errors.New("share link expired")
+)
+
+// signShare encodes values as <payload>.<signature>, both base64url.
+func (s *server) signShare(values url.Values, now time.Time) string {
+	if s.cfg.ShareTTL > 0 {
+		values = cloneValues(values)
+		values.Set(shareExpiresParam, strconv.FormatInt(now.Add(s.cfg.ShareTTL).Unix(), 10))
+	}
+	query := values.Encode()
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(query)) + "." + enc.EncodeToString(s.shareMAC(query))
+}
+
+// verifyShare returns the parameters a token was signed for, without its
+// expiry.
+func (s *server) verifyShare(token string, now time.Time) (url.Values, error) {
+	enc := base64.RawURLEncoding
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errShareInvalid
+	}
+	query, err := enc.DecodeString(payload)
+	if err != nil {
+		return nil, errShareInvalid
+	}
+	mac, err := enc.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, s.shareMAC(string(query))) {
+		return nil, errShareInvalid
+	}
+	values, err := url.ParseQuery(string(query))
+	if err != nil {
+		return nil, errShareInvalid
+	}
+	if exp := values.Get(shareExpiresParam); exp != "" {
+		expires, err := strconv.ParseInt(exp, 10, 64)
+		if err != nil {
+			return nil, errShareInvalid
+		}
+		if !now.Before(time.Unix(expires, 0)) {
+			return nil, errShareExpired
+		}
+		values.Del(shareExpiresParam)
+	}
+	return values, nil
+}
+
+func (s *server) shareMAC(query string) []byte {
+	h := hmac.New(sha256.New, []byte(s.cfg.ShareSecret))
+	h.Write([]byte(query))
+	return h.Sum(nil)[:shareSignatureBytes]
+}
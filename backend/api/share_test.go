@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+)
+
+func TestShareTokens(t *testing.T) {
+	srv := &server{cfg: &config.API{ShareSecret: "secret"}}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	values := url.Values{"q": {"турция"}, "keywords": {"пляж"}}
+
+	token := srv.signShare(values, now)
+	got, err := srv.verifyShare(token, now.Add(365*24*time.Hour))
+	require.NoError(t, err, "links without a TTL never expire")
+	require.Equal(t, values, got)
+
+	payload, sig, _ := strings.Cut(token, ".")
+	enc := base64.RawURLEncoding
+	tampered := enc.EncodeToString([]byte("keywords=%D0%BF%D0%BB%D1%8F%D0%B6&q=egypt")) + "." + sig
+	for name, bad := range map[string]string{
+		"tampered params":  tampered,
+		"no signature":     payload,
+		"truncated mac":    payload + "." + sig[:len(sig)-2],
+		"payload base64":   "%%%." + sig,
+		"signature base64": payload + ".!!!",
+		"empty":            "",
+	} {
+		_, err := srv.verifyShare(bad, now)
+		require.ErrorIs(t, err, errShareInvalid, name)
+	}
+
+	other := &server{cfg: &config.API{ShareSecret: "other"}}
+	_, err = other.verifyShare(token, now)
+	require.ErrorIs(t, err, errShareInvalid, "wrong secret")
+}
+
+func TestShareTokensExpire(t *testing.T) {
+	srv := &server{cfg: &config.API{ShareSecret: "secret", ShareTTL: time.Hour}}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	values := url.Values{"q": {"турция"}}
+
+	token := srv.signShare(values, now)
+	got, err := srv.verifyShare(token, now.Add(59*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, values, got, "the expiry is not part of the filter set")
+
+	_, err = srv.verifyShare(token, now.Add(time.Hour))
+	require.ErrorIs(t, err, errShareExpired)
+
+	// The expiry is signed, so it cannot be pushed back.
+	query, _ := url.ParseQuery(mustDecodeSharePayload(t, token))
+	query.Set(shareExpiresParam, "9999999999")
+	_, sig, _ := strings.Cut(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(query.Encode())) + "." + sig
+	_, err = srv.verifyShare(forged, now.Add(time.Hour))
+	require.ErrorIs(t, err, errShareInvalid)
+}
+
+func mustDecodeSharePayload(t *testing.T, token string) string {
+	t.Helper()
+	payload, _, _ := strings.Cut(token, ".")
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	require.NoError(t, err)
+	return string(data)
+}
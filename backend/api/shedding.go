@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// shedSamples bounds how many recent Elasticsearch calls are kept.
+	shedSamples = 512
+	// shedMinSamples keeps a few slow calls on a quiet instance from
+	// triggering shedding.
+	shedMinSamples = 20
+	// shedRetryAfter is the Retry-After sent with a shed request.
+	shedRetryAfter = 10 * time.Second
+)
+
+// loadShedder watches the Elasticsearch calls made by the API and, while
+// the cluster is slow or failing, rejects low-priority requests (exports
+// and aggregations) so the remaining capacity goes to /news.
+type loadShedder struct {
+	log          *slog.Logger
+	maxP99       time.Duration
+	maxErrorRate float64
+	window       time.Duration
+
+	mu         sync.Mutex
+	samples    [shedSamples]esCall
+	next       int
+	overloaded bool
+	shed       map[string]int64
+}
+
+type esCall struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// shedState is the load shedding decision and the signals behind it,
+// published under load_shedding at /debug/vars.
+type shedState struct {
+	Overloaded  bool             `json:"overloaded"`
+	ESP99Millis int64            `json:"es_p99_ms"`
+	ESErrorRate float64          `json:"es_error_rate"`
+	ESCalls     int              `json:"es_calls"`
+	Shed        map[string]int64 `json:"shed_requests"`
+}
+
+func newLoadShedder(log *slog.Logger, maxP99 time.Duration, maxErrorRate float64, window time.Duration) *loadShedder {
+	return &loadShedder{
+		log:          log,
+		maxP99:       maxP99,
+		maxErrorRate: maxErrorRate,
+		window:       window,
+		shed:         map[string]int64{},
+	}
+}
+
+// WrapTransport records the latency and outcome of every Elasticsearch call.
+func (s *loadShedder) WrapTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		res, err := base.RoundTrip(req)
+		failed := err != nil || res.StatusCode >= http.StatusInternalServerError || res.StatusCode == http.StatusTooManyRequests
+		s.observe(start, time.Since(start), failed)
+		return res, err
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func (s *loadShedder) observe(at time.Time, latency time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples[s.next%shedSamples] = esCall{at: at, latency: latency, failed: failed}
+	s.next++
+}
+
+// state evaluates the calls within the window. Transitions are logged, with
+// ctx, so shedding shows up next to the errors that caused it.
+func (s *loadShedder) state(ctx context.Context, now time.Time) shedState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latencies := make([]time.Duration, 0, min(s.next, shedSamples))
+	failures := 0
+	for _, call := range s.samples[:min(s.next, shedSamples)] {
+		if now.Sub(call.at) > s.window {
+			continue
+		}
+		latencies = append(latencies, call.latency)
+		if call.failed {
+			failures++
+		}
+	}
+
+	st := shedState{ESCalls: len(latencies), Shed: maps.Clone(s.shed)}
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		p99 := latencies[(len(latencies)*99-1)/100]
+		st.ESP99Millis = p99.Milliseconds()
+		st.ESErrorRate = float64(failures) / float64(len(latencies))
+		st.Overloaded = len(latencies) >= shedMinSamples &&
+			(s.maxP99 > 0 && p99 > s.maxP99 || s.maxErrorRate > 0 && st.ESErrorRate > s.maxErrorRate)
+	}
+
+	if st.Overloaded != s.overloaded {
+		s.overloaded = st.Overloaded
+		attrs := []any{slog.Int64("es_p99_ms", st.ESP99Millis), slog.Float64("es_error_rate", st.ESErrorRate)}
+		if st.Overloaded {
+			s.log.WarnContext(ctx, "elasticsearch overloaded, shedding low-priority requests", attrs...)
+		} else {
+			s.log.InfoContext(ctx, "elasticsearch recovered, stopped shedding", attrs...)
+		}
+	}
+	return st
+}
+
+// vars is the expvar.Func behind load_shedding.
+func (s *loadShedder) vars() any {
+	return s.state(context.Background(), time.Now())
+}
+
+// lowPriority rejects requests with 503 while Elasticsearch is overloaded.
+func (s *loadShedder) lowPriority(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.state(r.Context(), time.Now()).Overloaded {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.reject(w, r)
+	})
+}
+
+// reject answers a shed request with 503 and counts it under its route.
+func (s *loadShedder) reject(w http.ResponseWriter, r *http.Request) {
+	route := chi.RouteContext(r.Context()).RoutePattern()
+	s.mu.Lock()
+	s.shed[route]++
+	s.mu.Unlock()
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(shedRetryAfter.Seconds())))
+	writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: "search backend is overloaded, try again later"})
+}
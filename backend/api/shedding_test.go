@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestShedder() *loadShedder {
+	return newLoadShedder(slog.New(slog.DiscardHandler), time.Second, 0.5, time.Minute)
+}
+
+func TestShedderP99(t *testing.T) {
+	s := newTestShedder()
+	now := time.Now()
+	for range 98 {
+		s.observe(now, 10*time.Millisecond, false)
+	}
+	s.observe(now, 3*time.Second, false)
+	s.observe(now, 3*time.Second, false)
+
+	st := s.state(context.Background(), now)
+	require.Equal(t, 100, st.ESCalls)
+	require.Equal(t, int64(3000), st.ESP99Millis)
+	require.True(t, st.Overloaded)
+
+	// One slow call in a hundred stays below the p99.
+	s = newTestShedder()
+	for range 99 {
+		s.observe(now, 10*time.Millisecond, false)
+	}
+	s.observe(now, 3*time.Second, false)
+	st = s.state(context.Background(), now)
+	require.Equal(t, int64(10), st.ESP99Millis)
+	require.False(t, st.Overloaded)
+}
+
+func TestShedderNeedsMinimumSamples(t *testing.T) {
+	s := newTestShedder()
+	now := time.Now()
+	for range shedMinSamples - 1 {
+		s.observe(now, 5*time.Second, true)
+	}
+	st := s.state(context.Background(), now)
+	require.Equal(t, 1.0, st.ESErrorRate)
+	require.False(t, st.Overloaded)
+
+	s.observe(now, 5*time.Second, true)
+	require.True(t, s.state(context.Background(), now).Overloaded)
+}
+
+func TestShedderWindow(t *testing.T) {
+	s := newTestShedder()
+	start := time.Now()
+	for range 30 {
+		s.observe(start, 5*time.Second, false)
+	}
+	require.True(t, s.state(context.Background(), start).Overloaded)
+	require.True(t, s.overloaded)
+
+	// Fast calls arrive while the slow ones age out of the window.
+	later := start.Add(30 * time.Second)
+	for range 30 {
+		s.observe(later, 10*time.Millisecond, false)
+	}
+	st := s.state(context.Background(), later)
+	require.Equal(t, 60, st.ESCalls)
+	require.True(t, st.Overloaded)
+
+	st = s.state(context.Background(), start.Add(time.Minute+time.Second))
+	require.Equal(t, 30, st.ESCalls)
+	require.Equal(t, int64(10), st.ESP99Millis)
+	require.False(t, st.Overloaded)
+	require.False(t, s.overloaded)
+}
+
+func TestShedderErrorRate(t *testing.T) {
+	s := newTestShedder()
+	now := time.Now()
+	for i := range 40 {
+		s.observe(now, 10*time.Millisecond, i%2 == 0)
+	}
+	st := s.state(context.Background(), now)
+	require.Equal(t, 0.5, st.ESErrorRate)
+	require.False(t, st.Overloaded, "the threshold is exclusive")
+
+	s.observe(now, 10*time.Millisecond, true)
+	require.True(t, s.state(context.Background(), now).Overloaded)
+}
+
+func TestShedderKeepsOnlyRecentSamples(t *testing.T) {
+	s := newTestShedder()
+	now := time.Now()
+	for range shedSamples {
+		s.observe(now, 5*time.Second, false)
+	}
+	for range shedSamples {
+		s.observe(now, 10*time.Millisecond, false)
+	}
+	st := s.state(context.Background(), now)
+	require.Equal(t, shedSamples, st.ESCalls)
+	require.False(t, st.Overloaded)
+}
+
+func TestLowPriorityShedsWhileOverloaded(t *testing.T) {
+	s := newTestShedder()
+	r := chi.NewRouter()
+	r.With(s.lowPriority).Get("/trends", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/trends", nil))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	for range shedMinSamples {
+		s.observe(time.Now(), 10*time.Millisecond, true)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/trends", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "10", rec.Header().Get("Retry-After"))
+	require.Equal(t, map[string]int64{"/trends": 1}, s.state(context.Background(), time.Now()).Shed)
+}
+
+func TestSearchShedsFacetsWhileOverloaded(t *testing.T) {
+	s := newTestShedder()
+	for range shedMinSamples {
+		s.observe(time.Now(), 10*time.Millisecond, true)
+	}
+	srv := &server{log: slog.New(slog.DiscardHandler), cfg: &config.API{DefaultPage: 20, MaxPage: 100}, shedder: s}
+	r := chi.NewRouter()
+	r.Get("/news", srv.handleSearch)
+
+	for _, query := range []string{"facets=source", "histogram=day"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/news?"+query, nil))
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code, query)
+		require.Equal(t, "10", rec.Header().Get("Retry-After"))
+	}
+	require.Equal(t, map[string]int64{"/news": 2}, s.state(context.Background(), time.Now()).Shed)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+// handleSimilarNews lists documents about the same deal or destination as
+// the one in the path, so users can compare other agencies' offers for the
+// same tour. size is clamped like on /news; active_only=true leaves out
+// expired offers.
+func (s *server) handleSimilarNews(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	size := clampInt(r.URL.Query().Get("size"), s.cfg.DefaultPage, s.cfg.MaxPage)
+	activeOnly := r.URL.Query().Get("active_only") == "true"
+
+	result, err := s.es.SimilarNews(ctx, chi.URLParam(r, "docID"), size, activeOnly)
+	if errors.Is(err, elasticsearch.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "document not found"})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
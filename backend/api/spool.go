@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+)
+
+// ingestSpool keeps submissions Kafka did not confirm on local disk, one
+// file per message named so that listing them returns the oldest first.
+type ingestSpool struct {
+	dir string
+}
+
+// spooledMessage is the on-disk form of a submission.
+type spooledMessage struct {
+	Key     string            `json:"key"`
+	Value   json.RawMessage   `json:"value"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+func newIngestSpool(dir string) (*ingestSpool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create spool dir: %w", err)
+	}
+	return &ingestSpool{dir: dir}, nil
+}
+
+// put stores msg durably: the file is synced under a temporary name and
+// renamed into place, so a crash never leaves a partial message behind.
+func (s *ingestSpool) put(msg kafka.Message) error {
+	spooled := spooledMessage{Key: string(msg.Key), Value: msg.Value}
+	for _, h := range msg.Headers {
+		if spooled.Headers == nil {
+			spooled.Headers = make(map[string]string, len(msg.Headers))
+		}
+		spooled.Headers[h.Key] = string(h.Value)
+	}
+	data, err := json.Marshal(spooled)
+	if err != nil {
+		return fmt.Errorf("encode spooled message: %w", err)
+	}
+
+	f, err := os.CreateTemp(s.dir, "*.tmp")
+	if err != nil {
+		return fmt.Errorf("create spool file: %w", err)
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("write spool file: %w", err)
+	}
+
+	name := fmt.Sprintf("%020d-%s.json", time.Now().UnixNano(), uuid.NewString())
+	if err := os.Rename(f.Name(), filepath.Join(s.dir, name)); err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("store spool file: %w", err)
+	}
+	return nil
+}
+
+// pending lists the spooled files, oldest first.
+func (s *ingestSpool) pending() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("list spool dir: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".json") {
+			files = append(files, filepath.Join(s.dir, entry.Name()))
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// load reads a spooled file back into a Kafka message.
+func (s *ingestSpool) load(path string) (kafka.Message, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("read spool file: %w", err)
+	}
+	var spooled spooledMessage
+	if err := json.Unmarshal(data, &spooled); err != nil {
+		return kafka.Message{}, fmt.Errorf("decode spool file %s: %w", filepath.Base(path), err)
+	}
+	if len(spooled.Value) == 0 {
+		return kafka.Message{}, fmt.Errorf("decode spool file %s: no value", filepath.Base(path))
+	}
+
+	msg := kafka.Message{Key: []byte(spooled.Key), Value: spooled.Value}
+	for _, key := range slices.Sorted(maps.Keys(spooled.Headers)) {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: key, Value: []byte(spooled.Headers[key])})
+	}
+	return msg, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+const (
+	// overviewWindow is the period "recent" counters and trends cover.
+	overviewWindow = 24 * time.Hour
+	// overviewDestinations is how many trending destinations are reported.
+	overviewDestinations = 3
+)
+
+// overviewCache keeps the last overview for API_STATS_CACHE_TTL so landing
+// page traffic does not turn into aggregation load.
+type overviewCache struct {
+	mu      sync.Mutex
+	value   *elasticsearch.Overview
+	expires time.Time
+	// refresh is the refresh in flight, shared by every request that finds
+	// the value expired meanwhile.
+	refresh *overviewRefresh
+}
+
+// overviewRefresh is one Elasticsearch call refreshing the overview; err
+// is set before done is closed.
+type overviewRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+// handleStatsOverview serves the landing-page counters. A failed refresh
+// falls back to the previous value when there is one.
+func (s *server) handleStatsOverview(w http.ResponseWriter, r *http.Request) {
+	overview, err := s.currentOverview(r.Context())
+	if err != nil {
+		if r.Context().Err() != nil {
+			return // The client is gone; the refresh carries on for others.
+		}
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.cfg.StatsCacheTTL.Seconds())))
+	writeJSON(w, http.StatusOK, overview)
+}
+
+// currentOverview returns the cached overview, refreshing it first once it
+// expired. The lock is only held to read and swap the cache: at most one
+// refresh runs at a time, and it is detached from the requests waiting for
+// it, so a client that disconnects does not cancel it for the others.
+func (s *server) currentOverview(ctx context.Context) (*elasticsearch.Overview, error) {
+	c := &s.overview
+	c.mu.Lock()
+	if c.value != nil && time.Now().Before(c.expires) {
+		defer c.mu.Unlock()
+		return c.value, nil
+	}
+	refresh := c.refresh
+	if refresh == nil {
+		refresh = &overviewRefresh{done: make(chan struct{})}
+		c.refresh = refresh
+		go s.refreshOverview(context.WithoutCancel(ctx), refresh)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-refresh.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if refresh.err != nil && c.value == nil {
+		return nil, refresh.err
+	}
+	return c.value, nil
+}
+
+func (s *server) refreshOverview(ctx context.Context, refresh *overviewRefresh) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	overview, err := s.es.NewsOverview(ctx, now.Add(-overviewWindow), overviewDestinations)
+
+	c := &s.overview
+	c.mu.Lock()
+	switch {
+	case err == nil:
+		c.value = overview
+		c.expires = now.Add(s.cfg.StatsCacheTTL)
+	case c.value != nil:
+		s.log.WarnContext(ctx, "refresh stats overview, serving stale value", slog.Any("err", err))
+	}
+	c.refresh = nil
+	c.mu.Unlock()
+
+	refresh.err = err
+	close(refresh.done)
+}
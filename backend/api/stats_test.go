@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+// overviewES answers overview searches once release is closed, counting them.
+type overviewES struct {
+	calls   atomic.Int32
+	release chan struct{}
+	fail    atomic.Bool
+}
+
+func (f *overviewES) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	f.calls.Add(1)
+	<-f.release
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+	if f.fail.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, `{"error":{"type":"unavailable"}}`)
+		return
+	}
+	_, _ = io.WriteString(w, `{"hits":{"total":{"value":42}},"aggregations":{"recent":{"doc_count":7,"sources":{"value":3},"destinations":{"buckets":[]}}}}`)
+}
+
+func newOverviewServer(t *testing.T, es *overviewES) *server {
+	t.Helper()
+	httpSrv := httptest.NewServer(es)
+	t.Cleanup(httpSrv.Close)
+	client, err := elasticsearch.New(httpSrv.URL, "news", nil)
+	require.NoError(t, err)
+	return &server{log: slog.New(slog.DiscardHandler), cfg: &config.API{StatsCacheTTL: time.Minute}, es: client}
+}
+
+func TestStatsOverviewSharesOneRefresh(t *testing.T) {
+	es := &overviewES{release: make(chan struct{})}
+	srv := newOverviewServer(t, es)
+
+	// A caller that gives up does not cancel the refresh for the others.
+	gone, cancel := context.WithCancel(context.Background())
+	goneErr := make(chan error, 1)
+	go func() {
+		_, err := srv.currentOverview(gone)
+		goneErr <- err
+	}()
+	require.Eventually(t, func() bool { return es.calls.Load() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-goneErr, context.Canceled)
+
+	var wg sync.WaitGroup
+	results := make([]int64, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			overview, err := srv.currentOverview(context.Background())
+			if err == nil {
+				results[i] = overview.TotalDocuments
+			}
+		}()
+	}
+	// Let the waiters queue up behind the refresh before it completes.
+	time.Sleep(20 * time.Millisecond)
+	close(es.release)
+	wg.Wait()
+
+	require.Equal(t, []int64{42, 42, 42, 42, 42}, results)
+	require.Equal(t, int32(1), es.calls.Load())
+
+	// Cached until the TTL passes.
+	_, err := srv.currentOverview(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int32(1), es.calls.Load())
+}
+
+func TestStatsOverviewServesStaleValueOnFailure(t *testing.T) {
+	es := &overviewES{release: make(chan struct{})}
+	close(es.release)
+	srv := newOverviewServer(t, es)
+
+	es.fail.Store(true)
+	rec := httptest.NewRecorder()
+	srv.handleStatsOverview(rec, httptest.NewRequest(http.MethodGet, "/stats/overview", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	es.fail.Store(false)
+	rec = httptest.NewRecorder()
+	srv.handleStatsOverview(rec, httptest.NewRequest(http.MethodGet, "/stats/overview", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	srv.overview.expires = time.Time{}
+	es.fail.Store(true)
+	rec = httptest.NewRecorder()
+	srv.handleStatsOverview(rec, httptest.NewRequest(http.MethodGet, "/stats/overview", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"total_documents":42`)
+}
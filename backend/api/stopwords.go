@@ -0,0 +1,25 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+type stopwordSuggestionsResponse struct {
+	Suggestions []models.StopwordSuggestion `json:"suggestions"`
+}
+
+func (s *server) handleStopwordSuggestions(w http.ResponseWriter, r *http.Request) {
+	limit := clampInt(r.URL.Query().Get("size"), 100, 1000)
+
+	suggestions, err := s.es.StopwordSuggestions(r.Context(), limit)
+	if err != nil {
+		s.log.ErrorContext(r.Context(), "stopword suggestions", slog.Any("err", err))
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stopwordSuggestionsResponse{Suggestions: suggestions})
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/dedupe"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/eventbus"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+const (
+	// streamBuffer is how many documents may wait for a slow client before
+	// it is disconnected.
+	streamBuffer = 64
+	// streamHeartbeat keeps idle connections open through proxies.
+	streamHeartbeat = 15 * time.Second
+	// streamRetry is the reconnect delay suggested to EventSource clients.
+	streamRetry = 5 * time.Second
+)
+
+// streamFilter selects the documents a /news/stream client receives. The
+// criteria mean the same as the /news parameters of the same name.
+type streamFilter struct {
+	Keywords    []string
+	Source      string
+	Destination string
+	Mention     string
+	HasPrice    bool
+	HasURL      bool
+}
+
+func (f streamFilter) matches(doc models.NewsDocument) bool {
+	if len(f.Keywords) > 0 && !slices.ContainsFunc(f.Keywords, func(k string) bool {
+		return slices.Contains(doc.Keywords, k)
+	}) {
+		return false
+	}
+	if f.Source != "" && doc.Source != f.Source {
+		return false
+	}
+	if f.Destination != "" && !slices.Contains(doc.Destinations, f.Destination) {
+		return false
+	}
+	if f.Mention != "" && !slices.Contains(doc.Mentions, f.Mention) {
+		return false
+	}
+	if f.HasPrice && doc.Price <= 0 {
+		return false
+	}
+	if f.HasURL && len(doc.URLs) == 0 {
+		return false
+	}
+	return true
+}
+
+type streamClient struct {
+	// ctx is the context of the client's request, for log lines about it.
+	ctx    context.Context
+	filter streamFilter
+	events chan models.NewsDocument
+}
+
+// newsHub relays newly indexed documents to the connected stream clients.
+type newsHub struct {
+	log        *slog.Logger
+	maxClients int
+	// seen drops repeats of a document: keyed fan-out publishes it once per
+	// destination and again when a repost updates it.
+	seen *dedupe.Cache
+	// onPublish, when set, is called for every new document.
+	onPublish func()
+
+	mu      sync.Mutex
+	clients map[*streamClient]struct{}
+	closed  bool
+}
+
+func newNewsHub(log *slog.Logger, maxClients int) *newsHub {
+	return &newsHub{
+		log:        log,
+		maxClients: maxClients,
+		seen:       dedupe.NewCache(20_000, time.Hour),
+		clients:    make(map[*streamClient]struct{}),
+	}
+}
+
+// subscribe registers a client, or reports false when the hub is full or
+// shutting down.
+func (h *newsHub) subscribe(ctx context.Context, filter streamFilter) (*streamClient, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed || len(h.clients) >= h.maxClients {
+		return nil, false
+	}
+	c := &streamClient{ctx: ctx, filter: filter, events: make(chan models.NewsDocument, streamBuffer)}
+	h.clients[c] = struct{}{}
+	return c, true
+}
+
+func (h *newsHub) unsubscribe(c *streamClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.events)
+	}
+}
+
+// publish hands doc to every matching client. A client whose buffer is
+// full is disconnected rather than allowed to hold up the others.
+func (h *newsHub) publish(doc models.NewsDocument) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if doc.ID == "" || h.seen.IsSeen(doc.ID) {
+		return
+	}
+	h.seen.MarkSeen(doc.ID)
+	if h.onPublish != nil {
+		h.onPublish()
+	}
+
+	for c := range h.clients {
+		if !c.filter.matches(doc) {
+			continue
+		}
+		select {
+		case c.events <- doc:
+		default:
+			h.log.WarnContext(c.ctx, "stream client too slow, disconnecting")
+			delete(h.clients, c)
+			close(c.events)
+		}
+	}
+}
+
+// close ends every stream, so server shutdown does not wait for them.
+func (h *newsHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for c := range h.clients {
+		delete(h.clients, c)
+		close(c.events)
+	}
+}
+
+// consume reads the fan-out topic from its newest offsets until ctx ends.
+// Every API instance reads all partitions itself instead of joining a
+// consumer group, because each one serves its own clients.
+func (h *newsHub) consume(ctx context.Context, brokers []string, topic string) {
+	retryDelay := 2 * time.Second
+	for {
+		partitions, err := topicPartitions(ctx, brokers, topic)
+		if err == nil {
+			h.log.InfoContext(ctx, "live stream started", slog.String("topic", topic), slog.Int("partitions", len(partitions)))
+			var wg sync.WaitGroup
+			for _, p := range partitions {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					h.consumePartition(ctx, brokers, topic, p)
+				}()
+			}
+			wg.Wait()
+			return
+		}
+
+		h.log.WarnContext(ctx, "live stream topic unavailable, retrying",
+			slog.String("topic", topic),
+			slog.Any("err", err),
+			slog.Duration("retry_in", retryDelay),
+		)
+		select {
+		case <-time.After(retryDelay):
+		case <-ctx.Done():
+			return
+		}
+		retryDelay = min(retryDelay*2, time.Minute)
+	}
+}
+
+func topicPartitions(ctx context.Context, brokers []string, topic string) ([]int, error) {
+	var errs []error
+	for _, broker := range brokers {
+		conn, err := (&kafka.Dialer{Timeout: 10 * time.Second}).DialContext(ctx, "tcp", broker)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		partitions, err := conn.ReadPartitions(topic)
+		conn.Close()
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]int, 0, len(partitions))
+		for _, p := range partitions {
+			ids = append(ids, p.ID)
+		}
+		return ids, nil
+	}
+	return nil, fmt.Errorf("cannot reach Kafka brokers %s: %w", strings.Join(brokers, ","), errors.Join(errs...))
+}
+
+func (h *newsHub) consumePartition(ctx context.Context, brokers []string, topic string, partition int) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   brokers,
+		Topic:     topic,
+		Partition: partition,
+		MaxBytes:  10e6,
+		MaxWait:   time.Second,
+	})
+	defer reader.Close()
+	if err := reader.SetOffset(kafka.LastOffset); err != nil {
+		h.log.ErrorContext(ctx, "seek live stream partition", slog.Int("partition", partition), slog.Any("err", err))
+		return
+	}
+
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			h.log.WarnContext(ctx, "read live stream", slog.Int("partition", partition), slog.Any("err", err))
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		e, err := eventbus.Decode(msg)
+		if err != nil {
+			h.log.WarnContext(ctx, "decode event", slog.Any("err", err), slog.Int64("offset", msg.Offset))
+			continue
+		}
+		if indexed, ok := e.(eventbus.DocumentIndexed); ok {
+			h.publish(indexed.Document)
+		}
+	}
+}
+
+// handleStream pushes newly indexed documents matching the filter
+// parameters as Server-Sent Events. Documents indexed while a client is
+// disconnected are not replayed; clients catch up with /news?start=.
+func (s *server) handleStream(w http.ResponseWriter, r *http.Request) {
+	filter := streamFilter{
+		Keywords: s.keywordFilter(parseKeywords(r.URL.Query().Get("keywords"))),
+		Source:   strings.TrimSpace(r.URL.Query().Get("source")),
+		Mention:  parseMention(r.URL.Query().Get("mention")),
+		HasPrice: r.URL.Query().Get("has_price") == "true",
+		HasURL:   r.URL.Query().Get("has_url") == "true",
+	}
+	if raw := strings.TrimSpace(r.URL.Query().Get("destination")); raw != "" {
+		filter.Destination = s.taxonomy().Canonical(raw)
+	}
+
+	client, ok := s.stream.subscribe(r.Context(), filter)
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(streamRetry.Seconds())))
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: "too many stream clients, retry later"})
+		return
+	}
+	defer s.stream.unsubscribe(client)
+
+	// The server's write timeout would cut every stream after 15 seconds.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		s.log.WarnContext(r.Context(), "disable write deadline for stream", slog.Any("err", err))
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	if rc.Flush() != nil {
+		return
+	}
+
+	mask := maskFor(w)
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case doc, ok := <-client.events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(mask.Document(doc))
+			if err != nil {
+				s.log.WarnContext(r.Context(), "encode stream event", slog.String("id", doc.ID), slog.Any("err", err))
+				continue
+			}
+			fmt.Fprintf(w, "event: news\nid: %s\ndata: %s\n\n", doc.ID, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+// aggregatesCutoff is how far back trend windows count documents when
+// API_DAILY_AGGREGATES is set; older days come from the daily aggregates.
+const aggregatesCutoff = 48 * time.Hour
+
+// aggregatedWindow makes opts read the daily aggregates when they are
+// enabled and a day-interval window of buckets days reaches back past
+// aggregatesCutoff. Aggregates hold whole UTC days, so the window is
+// extended to end at the next midnight.
+func (s *server) aggregatedWindow(opts *elasticsearch.TrendOptions, buckets int, now time.Time) {
+	if !s.cfg.DailyAggregates || opts.Interval != "day" || !opts.Start.Before(now.Add(-aggregatesCutoff)) {
+		return
+	}
+	const day = 24 * time.Hour
+	end := opts.End.UTC()
+	if midnight := end.Truncate(day); !midnight.Equal(end) {
+		end = midnight.Add(day)
+	}
+	opts.End = end
+	opts.Start = end.Add(-time.Duration(buckets) * day)
+	opts.AggregatedBefore = now.Add(-aggregatesCutoff).UTC().Truncate(day)
+}
+
+type timelineResponse struct {
+	Keyword  string                      `json:"keyword"`
+	Interval string                      `json:"interval"`
+	Start    time.Time                   `json:"start"`
+	End      time.Time                   `json:"end"`
+	Buckets  []elasticsearch.TrendBucket `json:"buckets"`
+}
+
+// handleKeywordTimeline counts the documents carrying a keyword per hour
+// or day, over the last 30 days by default.
+func (s *server) handleKeywordTimeline(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	keyword := strings.TrimPrefix(strings.TrimSpace(chi.URLParam(r, "keyword")), "#")
+	if keyword == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "keyword is required"})
+		return
+	}
+	name := strings.TrimSpace(r.URL.Query().Get("interval"))
+	if name == "" {
+		name = "day"
+	}
+	interval, ok := trendIntervals[name]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "interval must be hour or day"})
+		return
+	}
+	buckets := clampInt(r.URL.Query().Get("buckets"), 30, maxTrendBuckets)
+
+	end := time.Now()
+	if ts := parseTime(r.URL.Query().Get("end")); ts != nil {
+		end = *ts
+	}
+	opts := elasticsearch.TrendOptions{
+		Interval: name,
+		Start:    end.Add(-time.Duration(buckets) * interval.length),
+		End:      end,
+	}
+	s.aggregatedWindow(&opts, buckets, time.Now())
+
+	result, err := s.es.KeywordTimeline(ctx, s.keywordFilter([]string{keyword}), opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, timelineResponse{
+		Keyword:  keyword,
+		Interval: name,
+		Start:    opts.Start.UTC(),
+		End:      opts.End.UTC(),
+		Buckets:  result,
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/logger"
+)
+
+// traceRequests tags the context of a request with a trace ID for the
+// handlers' log lines: the trace ID of a W3C traceparent header when the
+// caller sent a valid one, so the lines join the caller's trace, otherwise
+// the request ID.
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := traceParentID(r.Header.Get("traceparent"))
+		if !ok {
+			id = middleware.GetReqID(r.Context())
+		}
+		if id != "" {
+			r = r.WithContext(logger.WithTraceID(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// traceParentID returns the trace ID of a traceparent header of the form
+// version-traceid-parentid-flags.
+func traceParentID(header string) (string, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", false
+	}
+	id := parts[1]
+	if strings.Trim(id, "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return "", false
+	}
+	for _, c := range parts[0] + id + parts[2] {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return "", false
+		}
+	}
+	return id, true
+}
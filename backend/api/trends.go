@@ -0,0 +1,118 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/concepts"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+)
+
+// trendIntervals gives each accepted interval its length and the number of
+// buckets a window has by default.
+var trendIntervals = map[string]struct {
+	length         time.Duration
+	defaultBuckets int
+}{
+	"hour": {time.Hour, 24},
+	"day":  {24 * time.Hour, 7},
+}
+
+// maxTrendBuckets bounds the window to a week of hours or about half a year of days.
+const maxTrendBuckets = 180
+
+// handleTrends reports how often the most frequent keywords of a window
+// occurred per hour or day and how much they rose against the window
+// before it. Keywords are merged into concepts like in /news/aggregations.
+func (s *server) handleTrends(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	params, err := s.parseSearchParams(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("interval"))
+	if name == "" {
+		name = "hour"
+	}
+	interval, ok := trendIntervals[name]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "interval must be hour or day"})
+		return
+	}
+	sortBy := strings.TrimSpace(r.URL.Query().Get("sort"))
+	if sortBy != "" && sortBy != "count" && sortBy != "rising" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "sort must be count or rising"})
+		return
+	}
+	buckets := clampInt(r.URL.Query().Get("buckets"), interval.defaultBuckets, maxTrendBuckets)
+	size := clampInt(r.URL.Query().Get("size"), 10, elasticsearch.MaxAggregationSize)
+
+	end := time.Now()
+	if params.End != nil {
+		end = *params.End
+	}
+	opts := elasticsearch.TrendOptions{
+		Interval: name,
+		Start:    end.Add(-time.Duration(buckets) * interval.length),
+		End:      end,
+		Size:     size * conceptOverfetch,
+	}
+	if !params.Filtered() {
+		s.aggregatedWindow(&opts, buckets, time.Now())
+	}
+
+	result, err := s.es.KeywordTrends(ctx, params, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	trends := mergeTrendConcepts(result.Keywords, s.concepts)
+	if sortBy == "rising" {
+		slices.SortStableFunc(trends, func(a, b elasticsearch.KeywordTrend) int {
+			return cmp.Compare(b.Rising, a.Rising)
+		})
+	}
+	result.Keywords = trends[:min(len(trends), size)]
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// mergeTrendConcepts sums the counts and buckets of keywords belonging to
+// the same concept under its canonical name, most frequent first. All
+// trends of a result share the same buckets.
+func mergeTrendConcepts(trends []elasticsearch.KeywordTrend, table *concepts.Table) []elasticsearch.KeywordTrend {
+	merged := make([]elasticsearch.KeywordTrend, 0, len(trends))
+	index := make(map[string]int, len(trends))
+	for _, t := range trends {
+		name := table.Canonical(t.Keyword)
+		i, ok := index[name]
+		if !ok {
+			index[name] = len(merged)
+			t.Keyword = name
+			t.Buckets = slices.Clone(t.Buckets)
+			merged = append(merged, t)
+			continue
+		}
+		merged[i].Count += t.Count
+		merged[i].Previous += t.Previous
+		for j := range min(len(merged[i].Buckets), len(t.Buckets)) {
+			merged[i].Buckets[j].Count += t.Buckets[j].Count
+		}
+	}
+	for i := range merged {
+		merged[i].Rising = elasticsearch.RisingScore(merged[i].Count, merged[i].Previous)
+	}
+	slices.SortStableFunc(merged, func(a, b elasticsearch.KeywordTrend) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	return merged
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiFiles is a small single-page UI for demos and self-hosters who do not
+// deploy the separate frontend. It only calls the public endpoints with
+// relative URLs, so it also works behind a path prefix.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// uiAssets is the ui directory; fs.Sub cannot fail for an embedded directory.
+var uiAssets, _ = fs.Sub(uiFiles, "ui")
+
+// handleUI serves the UI's index page at /.
+func handleUI(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, uiAssets, "index.html")
+}
+
+// uiAssetHandler serves the scripts and styles under /ui/.
+var uiAssetHandler = http.StripPrefix("/ui/", http.FileServerFS(uiAssets))
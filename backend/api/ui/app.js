@@ -0,0 +1,219 @@
+'use strict';
+
+// All requests are relative, so the UI also works behind a path prefix
+// such as /api/.
+const FEED_INTERVAL_MS = 30000;
+const TREND_DAYS = 7;
+
+const form = document.getElementById('search');
+const feedSeen = new Set();
+let searchController = null;
+
+async function getJSON(path, params, signal) {
+    const query = params ? '?' + params.toString() : '';
+    const res = await fetch(path + query, {signal});
+    if (!res.ok) {
+        const body = await res.json().catch(() => ({}));
+        throw new Error(body.error || `HTTP ${res.status}`);
+    }
+    return res.json();
+}
+
+// searchParams turns the filter form into query parameters, dropping empty fields.
+function searchParams() {
+    const params = new URLSearchParams();
+    for (const [key, value] of new FormData(form)) {
+        if (value.trim() !== '') {
+            params.set(key, value.trim());
+        }
+    }
+    return params;
+}
+
+function formatDate(value) {
+    return new Date(value).toLocaleString(undefined, {dateStyle: 'medium', timeStyle: 'short'});
+}
+
+function renderNews(doc, fresh) {
+    const item = document.getElementById('news-item').content.firstElementChild.cloneNode(true);
+    item.querySelector('h3').textContent = doc.title || doc.text.slice(0, 80);
+
+    const meta = [formatDate(doc.timestamp), doc.source];
+    if (doc.price) {
+        meta.push(`from ${doc.price.toLocaleString()} ₽`);
+    }
+    if (doc.destinations && doc.destinations.length) {
+        meta.push(doc.destinations.join(', '));
+    }
+    item.querySelector('.meta').textContent = meta.join(' · ');
+    item.querySelector('.text').textContent = doc.text;
+
+    const links = item.querySelector('.links');
+    (doc.urls || []).forEach((url, i) => {
+        const a = document.createElement('a');
+        // Links go through the click-counting redirect.
+        a.href = `r/${encodeURIComponent(doc.id)}/${i}`;
+        // Public callers get redirect links instead of the raw URLs.
+        const target = new URL(url, location.href);
+        a.textContent = target.host === location.host ? `link ${i + 1}` : target.hostname;
+        a.target = '_blank';
+        a.rel = 'noopener';
+        links.append(a);
+    });
+    if (fresh) {
+        item.classList.add('fresh');
+    }
+    return item;
+}
+
+async function runSearch() {
+    searchController?.abort();
+    searchController = new AbortController();
+
+    const status = document.getElementById('status');
+    const results = document.getElementById('results');
+    status.textContent = 'Loading...';
+    try {
+        const data = await getJSON('news', searchParams(), searchController.signal);
+        results.replaceChildren(...data.Items.map(doc => renderNews(doc, false)));
+        document.getElementById('total').textContent = `(${data.Total})`;
+        status.textContent = data.Relaxed ? `No exact matches, relaxed: ${data.Dropped.join(', ')}` : '';
+        if (data.Items.length === 0) {
+            status.textContent = 'No news found.';
+        }
+    } catch (e) {
+        if (e.name !== 'AbortError') {
+            status.textContent = `Search failed: ${e.message}`;
+        }
+    }
+}
+
+function renderChart(id, counts) {
+    const top = counts.length ? counts[0].count : 1;
+    document.getElementById(id).replaceChildren(...counts.map(({term, count}) => {
+        const row = document.createElement('li');
+        const label = document.createElement('span');
+        label.textContent = term;
+        const bar = document.createElement('span');
+        bar.className = 'bar';
+        bar.style.width = `${(100 * count) / top}%`;
+        const value = document.createElement('span');
+        value.className = 'count';
+        value.textContent = count;
+        row.append(label, bar, value);
+        return row;
+    }));
+}
+
+async function loadTrends() {
+    const params = searchParams();
+    params.delete('sort');
+    params.set('fields', 'destination,keywords');
+    params.set('size', '10');
+    params.set('start', new Date(Date.now() - TREND_DAYS * 864e5).toISOString().replace(/\.\d+Z$/, 'Z'));
+    try {
+        const data = await getJSON('news/aggregations', params);
+        renderChart('trend-destination', data.buckets.destination || []);
+        renderChart('trend-keywords', data.buckets.keywords || []);
+    } catch (e) {
+        console.error('load trends', e);
+    }
+}
+
+async function loadOverview() {
+    try {
+        const data = await getJSON('stats/overview');
+        const entries = [
+            ['Offers', data.total_documents],
+            ['Last 24h', data.recent_documents],
+            ['Active sources', data.active_sources],
+        ];
+        document.getElementById('overview').replaceChildren(...entries.flatMap(([name, value]) => {
+            const dt = document.createElement('dt');
+            dt.textContent = name;
+            const dd = document.createElement('dd');
+            dd.textContent = value.toLocaleString();
+            return [dt, dd];
+        }));
+    } catch (e) {
+        console.error('load overview', e);
+    }
+}
+
+async function loadDestinations() {
+    const select = form.elements.destination;
+    const add = (nodes, depth) => {
+        for (const node of nodes) {
+            select.add(new Option('  '.repeat(depth) + node.name, node.id));
+            add(node.children || [], depth + 1);
+        }
+    };
+    try {
+        add((await getJSON('destinations')).destinations, 0);
+    } catch (e) {
+        console.error('load destinations', e);
+    }
+}
+
+// addToFeed prepends documents not shown yet, highlighting them until the
+// next update when highlight is set.
+function addToFeed(docs, highlight) {
+    const feed = document.getElementById('feed');
+    feed.querySelectorAll('.fresh').forEach(el => el.classList.remove('fresh'));
+    const fresh = docs.filter(doc => !feedSeen.has(doc.id));
+    fresh.forEach(doc => feedSeen.add(doc.id));
+    feed.prepend(...fresh.map(doc => renderNews(doc, highlight)));
+    while (feed.children.length > 30) {
+        feed.lastElementChild.remove();
+    }
+}
+
+// pollFeed adds documents published since the last poll.
+async function pollFeed() {
+    if (!document.getElementById('live').checked) {
+        return;
+    }
+    const params = new URLSearchParams({sort: 'timestamp:desc', size: '10', relax: 'false'});
+    try {
+        const data = await getJSON('news', params);
+        addToFeed(data.Items, feedSeen.size > 0);
+    } catch (e) {
+        console.error('poll feed', e);
+    }
+}
+
+// startFeed fills the feed once, then follows news/stream. When the API
+// has the live stream disabled or full, it polls instead.
+function startFeed() {
+    pollFeed();
+    const poll = () => setInterval(pollFeed, FEED_INTERVAL_MS);
+    if (!window.EventSource) {
+        poll();
+        return;
+    }
+    const stream = new EventSource('news/stream');
+    stream.addEventListener('news', event => {
+        if (document.getElementById('live').checked) {
+            addToFeed([JSON.parse(event.data)], true);
+        }
+    });
+    stream.addEventListener('error', () => {
+        // Dropped connections reconnect by themselves; an error response
+        // closes the stream for good.
+        if (stream.readyState === EventSource.CLOSED) {
+            poll();
+        }
+    });
+}
+
+form.addEventListener('submit', event => {
+    event.preventDefault();
+    runSearch();
+    loadTrends();
+});
+
+loadOverview();
+loadDestinations();
+runSearch();
+loadTrends();
+startFeed();
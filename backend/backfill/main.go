@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/logger"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// backfillItem is one imported post. Key is sent as the idempotency_key
+// header, so the worker stores reimported posts under the same document.
+type backfillItem struct {
+	Key  string
+	News models.RawNews
+}
+
+// adapter reads posts in one export format and emits them in order.
+// channel is the -channel flag and may be empty.
+type adapter func(r io.Reader, channel string, emit func(backfillItem) error) error
+
+// adapters are the formats selectable with -format.
+var adapters = map[string]adapter{
+	"telegram-export": parseTelegramExport,
+}
+
+// messageWriter is the subset of kafka.Writer used by the importer.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+func main() {
+	log := logger.New("backfill")
+
+	format := flag.String("format", "telegram-export", "export format: "+strings.Join(slices.Sorted(maps.Keys(adapters)), ", "))
+	input := flag.String("input", "", "export file to import, e.g. result.json")
+	channel := flag.String("channel", "", "public username of the exported channel, used for post links and idempotency keys")
+	dryRun := flag.Bool("dry-run", false, "print the Kafka messages as NDJSON instead of publishing them")
+	flag.Parse()
+
+	parse, ok := adapters[*format]
+	if !ok || *input == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadBackfill()
+	if err != nil {
+		log.Error("load config", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	f, err := os.Open(*input)
+	if err != nil {
+		log.Error("open export", slog.Any("err", err))
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	var writer messageWriter
+	if *dryRun {
+		out := bufio.NewWriter(os.Stdout)
+		defer out.Flush()
+		writer = &ndjsonWriter{w: out}
+	} else {
+		kw := &kafka.Writer{
+			Addr:         kafka.TCP(cfg.KafkaBrokers...),
+			Topic:        cfg.KafkaTopic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchSize:    cfg.BatchSize,
+		}
+		defer kw.Close()
+		writer = kw
+	}
+
+	published, err := publish(ctx, writer, cfg.BatchSize, func(emit func(backfillItem) error) error {
+		return parse(bufio.NewReader(f), strings.TrimPrefix(*channel, "@"), emit)
+	})
+	if err != nil {
+		log.Error("backfill failed", slog.Int("published", published), slog.Any("err", err))
+		os.Exit(1)
+	}
+	log.Info("backfill finished",
+		slog.String("input", *input),
+		slog.String("topic", cfg.KafkaTopic),
+		slog.Int("published", published),
+		slog.Bool("dry_run", *dryRun),
+	)
+}
+
+// publish writes the items produced by run to w in batches of batchSize
+// and returns how many were written. Messages are keyed by their
+// idempotency key, so every import of a post lands on the same partition.
+func publish(ctx context.Context, w messageWriter, batchSize int, run func(emit func(backfillItem) error) error) (int, error) {
+	published := 0
+	batch := make([]kafka.Message, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := w.WriteMessages(ctx, batch...); err != nil {
+			return fmt.Errorf("publish batch: %w", err)
+		}
+		published += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	err := run(func(item backfillItem) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		value, err := json.Marshal(item.News)
+		if err != nil {
+			return fmt.Errorf("marshal %s: %w", item.Key, err)
+		}
+		batch = append(batch, kafka.Message{
+			Key:     []byte(item.Key),
+			Value:   value,
+			Headers: []kafka.Header{{Key: "idempotency_key", Value: []byte(item.Key)}},
+		})
+		if len(batch) == batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return published, err
+	}
+	return published, flush()
+}
+
+// ndjsonWriter prints messages for -dry-run.
+type ndjsonWriter struct {
+	w io.Writer
+}
+
+func (n *ndjsonWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	enc := json.NewEncoder(n.w)
+	for _, msg := range msgs {
+		line := struct {
+			Key   string          `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}{string(msg.Key), msg.Value}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+const telegramExport = `{
+ "name": "Горящие туры",
+ "type": "public_channel",
+ "id": 1234567890,
+ "messages": [
+  {
+   "id": 1,
+   "type": "service",
+   "date": "2024-05-31T09:00:00",
+   "date_unixtime": "1717146000",
+   "action": "create_channel",
+   "text": ""
+  },
+  {
+   "id": 2,
+   "type": "message",
+   "date": "2024-06-01T10:00:00",
+   "date_unixtime": "1717236000",
+   "text": "Турция 7 ночей от 45 000 ₽"
+  },
+  {
+   "id": 3,
+   "type": "message",
+   "date": "2024-06-01T11:00:00",
+   "date_unixtime": "1717239600",
+   "photo": "photos/photo_1.jpg",
+   "text": [
+    {"type": "bold", "text": "Египет"},
+    " до 15 июня, ",
+    {"type": "text_link", "text": "подробнее", "href": "https://example.com/egypt"}
+   ]
+  },
+  {
+   "id": 4,
+   "type": "message",
+   "date": "2024-06-01T12:00:00",
+   "sticker_emoji": "🔥",
+   "text": ""
+  }
+ ]
+}`
+
+func parseAll(t *testing.T, export, channel string) []backfillItem {
+	t.Helper()
+	var items []backfillItem
+	err := parseTelegramExport(strings.NewReader(export), channel, func(item backfillItem) error {
+		items = append(items, item)
+		return nil
+	})
+	require.NoError(t, err)
+	return items
+}
+
+func TestParseTelegramExport(t *testing.T) {
+	items := parseAll(t, telegramExport, "hottours")
+
+	require.Equal(t, []backfillItem{
+		{
+			Key: "telegram:hottours:2",
+			News: models.RawNews{
+				Text:      "Турция 7 ночей от 45 000 ₽\nhttps://t.me/hottours/2",
+				Timestamp: "2024-06-01T10:00:00Z",
+				Source:    "telegram",
+			},
+		},
+		{
+			Key: "telegram:hottours:3",
+			News: models.RawNews{
+				Text:      "Египет до 15 июня, подробнее (https://example.com/egypt)\nhttps://t.me/hottours/3",
+				Timestamp: "2024-06-01T11:00:00Z",
+				Source:    "telegram",
+			},
+		},
+	}, items)
+}
+
+func TestParseTelegramExportWithoutChannel(t *testing.T) {
+	items := parseAll(t, telegramExport, "")
+	require.Len(t, items, 2)
+	require.Equal(t, "telegram:1234567890:2", items[0].Key)
+	require.Equal(t, "Турция 7 ночей от 45 000 ₽", items[0].News.Text)
+}
+
+func TestParseTelegramExportLocalDate(t *testing.T) {
+	items := parseAll(t, `{"id": 1, "messages": [{"id": 7, "type": "message", "date": "2021-03-01T08:30:00", "text": "Сочи"}]}`, "")
+	want := time.Date(2021, 3, 1, 8, 30, 0, 0, time.Local).Format(time.RFC3339)
+	require.Equal(t, want, items[0].News.Timestamp)
+}
+
+func TestParseTelegramExportRejectsAccountExport(t *testing.T) {
+	err := parseTelegramExport(strings.NewReader(`{"about": "", "chats": {"list": []}}`), "", func(backfillItem) error { return nil })
+	require.ErrorContains(t, err, "no messages array")
+
+	err = parseTelegramExport(strings.NewReader(`{"messages": []}`), "", func(backfillItem) error { return nil })
+	require.ErrorContains(t, err, "set -channel")
+}
+
+type recordingWriter struct {
+	batches [][]kafka.Message
+}
+
+func (w *recordingWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.batches = append(w.batches, append([]kafka.Message(nil), msgs...))
+	return nil
+}
+
+func TestPublishBatches(t *testing.T) {
+	w := &recordingWriter{}
+	published, err := publish(context.Background(), w, 1, func(emit func(backfillItem) error) error {
+		return parseTelegramExport(strings.NewReader(telegramExport), "hottours", emit)
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, published)
+	require.Len(t, w.batches, 2)
+
+	msg := w.batches[1][0]
+	require.Equal(t, "telegram:hottours:3", string(msg.Key))
+	require.Equal(t, []kafka.Header{{Key: "idempotency_key", Value: []byte("telegram:hottours:3")}}, msg.Headers)
+	var payload models.RawNews
+	require.NoError(t, json.Unmarshal(msg.Value, &payload))
+	require.Equal(t, "telegram", payload.Source)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// telegramExportMessage is one entry of the messages array in a Telegram
+// Desktop chat export (result.json, "Machine-readable JSON" format).
+type telegramExportMessage struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+	// Date is the local time of the exporting machine without a zone;
+	// DateUnix is only written by Telegram Desktop 3.x and later.
+	Date     string             `json:"date"`
+	DateUnix string             `json:"date_unixtime"`
+	Text     telegramExportText `json:"text"`
+}
+
+// telegramExportText is a message text, which the export writes as a plain
+// string or, for formatted messages, as an array of strings and entity
+// objects. Captions of photos, videos and documents are exported as text.
+type telegramExportText string
+
+func (t *telegramExportText) UnmarshalJSON(data []byte) error {
+	var plain string
+	if err := json.Unmarshal(data, &plain); err == nil {
+		*t = telegramExportText(plain)
+		return nil
+	}
+
+	var parts []json.RawMessage
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("text is neither a string nor an array: %w", err)
+	}
+	var b strings.Builder
+	for _, part := range parts {
+		if err := json.Unmarshal(part, &plain); err == nil {
+			b.WriteString(plain)
+			continue
+		}
+		var entity struct {
+			Text string `json:"text"`
+			Href string `json:"href"`
+		}
+		if err := json.Unmarshal(part, &entity); err != nil {
+			return fmt.Errorf("text entity: %w", err)
+		}
+		b.WriteString(entity.Text)
+		// Hidden links ("text_link") carry the target in href only; spell it
+		// out so the urls stage sees it.
+		if entity.Href != "" && entity.Href != entity.Text {
+			b.WriteString(" (" + entity.Href + ")")
+		}
+	}
+	*t = telegramExportText(b.String())
+	return nil
+}
+
+// parseTelegramExport streams the messages of a single-chat Telegram
+// Desktop export. Service messages (pins, joins, title changes) and
+// messages without text, such as bare photos or stickers, are skipped.
+//
+// Posts are keyed telegram:<channel>:<message id>, with the chat ID of the
+// export standing in for an unset channel, so importing the same export
+// twice updates the documents instead of duplicating them.
+func parseTelegramExport(r io.Reader, channel string, emit func(backfillItem) error) error {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	var chatID int64
+	sawMessages := false
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("read export: %w", err)
+		}
+		switch tok {
+		case "id":
+			if err := dec.Decode(&chatID); err != nil {
+				return fmt.Errorf("read chat id: %w", err)
+			}
+		case "messages":
+			key := channel
+			if key == "" {
+				if chatID == 0 {
+					return errors.New("export has no chat id before its messages, set -channel")
+				}
+				key = strconv.FormatInt(chatID, 10)
+			}
+			if err := decodeTelegramMessages(dec, key, channel, emit); err != nil {
+				return err
+			}
+			sawMessages = true
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return fmt.Errorf("read export field %v: %w", tok, err)
+			}
+		}
+	}
+	if !sawMessages {
+		return errors.New("export has no messages array; export a single chat, not the whole account")
+	}
+	return nil
+}
+
+func decodeTelegramMessages(dec *json.Decoder, key, channel string, emit func(backfillItem) error) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for dec.More() {
+		var msg telegramExportMessage
+		if err := dec.Decode(&msg); err != nil {
+			return fmt.Errorf("read message: %w", err)
+		}
+		text := strings.TrimSpace(string(msg.Text))
+		if msg.Type != "message" || text == "" {
+			continue
+		}
+		if channel != "" {
+			text += fmt.Sprintf("\nhttps://t.me/%s/%d", channel, msg.ID)
+		}
+		item := backfillItem{
+			Key:  fmt.Sprintf("telegram:%s:%d", key, msg.ID),
+			News: models.RawNews{Text: text, Timestamp: exportTimestamp(msg), Source: "telegram"},
+		}
+		if err := emit(item); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// exportTimestamp returns the message date in RFC 3339, reading dates
+// without a zone in the local time zone, or "" when it has none.
+func exportTimestamp(msg telegramExportMessage) string {
+	if sec, err := strconv.ParseInt(msg.DateUnix, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC().Format(time.RFC3339)
+	}
+	if ts, err := time.ParseInLocation("2006-01-02T15:04:05", msg.Date, time.Local); err == nil {
+		return ts.Format(time.RFC3339)
+	}
+	return ""
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("read export: %w", err)
+	}
+	if tok != want {
+		return fmt.Errorf("read export: expected %v, got %v", want, tok)
+	}
+	return nil
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/logger"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/telegram"
+)
+
+const helpText = `Горящие туры прямо в Telegram.
+
+/search <запрос> – найти свежие предложения
+/subscribe <запрос> – присылать новые предложения по запросу
+/unsubscribe <запрос> – отписаться (без запроса – от всех)
+/spike <направление> [порог] – сообщать о всплеске предложений и снижении цен по направлению
+/unspike <направление> – не сообщать о всплесках (без направления – ни о каких)
+/subscriptions – список подписок`
+
+// messenger is the subset of the Telegram client used by the bot.
+type messenger interface {
+	SendMessage(ctx context.Context, chatID int64, text string) error
+}
+
+type bot struct {
+	log   *slog.Logger
+	cfg   *config.Bot
+	tg    messenger
+	radar *radarClient
+	state *stateStore
+}
+
+func main() {
+	log := logger.New("bot")
+	cfg, err := config.LoadBot()
+	if err != nil {
+		log.Error("load config", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	state, err := loadState(cfg.StateFile)
+	if err != nil {
+		log.Error("load state", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	tg := telegram.New(cfg.TelegramToken)
+	b := &bot{
+		log:   log,
+		cfg:   cfg,
+		tg:    tg,
+		radar: newRadarClient(cfg.APIBaseURL, cfg.APIKey),
+		state: state,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	go b.runSubscriptions(ctx)
+
+	log.Info("bot started",
+		slog.String("api", cfg.APIBaseURL),
+		slog.Duration("poll_interval", cfg.PollInterval),
+	)
+
+	for {
+		updates, err := tg.GetUpdates(ctx, state.offset(), 30*time.Second)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				log.Info("context canceled, stopping")
+				return
+			}
+			log.Warn("get updates", slog.Any("err", err))
+			select {
+			case <-time.After(5 * time.Second):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			if update.Message != nil {
+				b.handleMessage(ctx, update.Message)
+			}
+			if err := state.setOffset(update.UpdateID + 1); err != nil {
+				log.Error("save offset", slog.Any("err", err))
+			}
+		}
+	}
+}
+
+// parseCommand splits "/cmd@botname args" into the command and its argument string.
+func parseCommand(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", text
+	}
+	cmd, args, _ := strings.Cut(text, " ")
+	cmd, _, _ = strings.Cut(cmd, "@")
+	return strings.ToLower(cmd), strings.TrimSpace(args)
+}
+
+func (b *bot) handleMessage(ctx context.Context, msg *telegram.Message) {
+	cmd, args := parseCommand(msg.Text)
+	chatID := msg.Chat.ID
+
+	var reply string
+	switch cmd {
+	case "/start", "/help":
+		reply = helpText
+	case "/search", "":
+		reply = b.search(ctx, args)
+	case "/subscribe":
+		reply = b.subscribe(chatID, args)
+	case "/unsubscribe":
+		reply = b.unsubscribe(chatID, args)
+	case "/spike":
+		reply = b.watchSpikes(chatID, args)
+	case "/unspike":
+		reply = b.unwatchSpikes(chatID, args)
+	case "/subscriptions":
+		reply = b.listSubscriptions(chatID)
+	default:
+		reply = "Неизвестная команда.\n\n" + helpText
+	}
+
+	if err := b.tg.SendMessage(ctx, chatID, reply); err != nil {
+		b.log.Warn("send reply", slog.Int64("chat_id", chatID), slog.Any("err", err))
+	}
+}
+
+func (b *bot) search(ctx context.Context, query string) string {
+	if query == "" {
+		return "Укажите запрос: /search турция"
+	}
+
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("size", strconv.Itoa(b.cfg.ResultLimit))
+
+	res, err := b.radar.search(ctx, params)
+	if err != nil {
+		b.log.Warn("search", slog.String("query", query), slog.Any("err", err))
+		return "Поиск временно недоступен, попробуйте позже."
+	}
+	if len(res.Items) == 0 {
+		return "Ничего не найдено по запросу «" + html.EscapeString(query) + "»."
+	}
+	return fmt.Sprintf("Найдено: %d\n\n%s", res.Total, formatDocs(res.Items))
+}
+
+func (b *bot) subscribe(chatID int64, query string) string {
+	if query == "" {
+		return "Укажите запрос: /subscribe египет"
+	}
+	added, err := b.state.subscribe(chatID, query, time.Now().UTC().Truncate(time.Second))
+	if err != nil {
+		b.log.Error("save subscription", slog.Any("err", err))
+		return "Не удалось сохранить подписку, попробуйте позже."
+	}
+	if !added {
+		return "Вы уже подписаны на «" + html.EscapeString(query) + "»."
+	}
+	return "Готово! Новые предложения по «" + html.EscapeString(query) + "» будут приходить сюда."
+}
+
+func (b *bot) unsubscribe(chatID int64, query string) string {
+	removed, err := b.state.unsubscribe(chatID, query)
+	if err != nil {
+		b.log.Error("save subscription", slog.Any("err", err))
+		return "Не удалось отписаться, попробуйте позже."
+	}
+	if removed == 0 {
+		return "Подписка не найдена."
+	}
+	return fmt.Sprintf("Удалено подписок: %d", removed)
+}
+
+func (b *bot) listSubscriptions(chatID int64) string {
+	queries := b.state.queries(chatID)
+	watches := b.state.spikeWatches()[chatID]
+	if len(queries) == 0 && len(watches) == 0 {
+		return "Подписок нет. Добавьте: /subscribe турция"
+	}
+	var sb strings.Builder
+	sb.WriteString("Ваши подписки:\n")
+	for _, q := range queries {
+		sb.WriteString("• " + html.EscapeString(q) + "\n")
+	}
+	for _, watch := range watches {
+		sb.WriteString("• всплески «" + html.EscapeString(watch.Destination) + "», " + formatThreshold(b.threshold(watch.Threshold)) + "\n")
+	}
+	return sb.String()
+}
+
+// runSubscriptions periodically pushes new matches of every subscription
+// and destination spikes.
+func (b *bot) runSubscriptions(ctx context.Context) {
+	ticker := time.NewTicker(b.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.checkSubscriptions(ctx)
+			b.checkSpikes(ctx, time.Now())
+		}
+	}
+}
+
+func (b *bot) checkSubscriptions(ctx context.Context) {
+	for chatID, subs := range b.state.snapshot() {
+		for _, sub := range subs {
+			if err := b.deliver(ctx, chatID, sub); err != nil {
+				b.log.Warn("deliver subscription",
+					slog.Int64("chat_id", chatID),
+					slog.String("query", sub.Query),
+					slog.Any("err", err),
+				)
+			}
+		}
+	}
+}
+
+func (b *bot) deliver(ctx context.Context, chatID int64, sub subscription) error {
+	params := url.Values{}
+	if sub.Cursor != "" {
+		params.Set("cursor", sub.Cursor)
+	} else {
+		params.Set("q", sub.Query)
+		params.Set("start", sub.Since.UTC().Format(time.RFC3339))
+		params.Set("sort", "timestamp:asc")
+		params.Set("size", strconv.Itoa(b.cfg.ResultLimit))
+		// Relaxation would drop the start filter and resend old offers.
+		params.Set("relax", "false")
+		params.Set("cursor", "true")
+	}
+
+	res, err := b.radar.search(ctx, params)
+	if sub.Cursor != "" && errors.Is(err, errCursorExpired) {
+		// Since and Sent still record what was delivered, so starting over
+		// from Since resends nothing.
+		b.log.Info("subscription cursor expired, restarting from since",
+			slog.Int64("chat_id", chatID),
+			slog.String("query", sub.Query),
+		)
+		sub.Cursor = ""
+		return b.deliver(ctx, chatID, sub)
+	}
+	if err != nil {
+		return err
+	}
+	// A page without hits has no cursor; the current one stays usable.
+	cursor := res.Cursor
+	if cursor == "" {
+		cursor = sub.Cursor
+	}
+
+	// The cursor moves past every returned document, including copies of
+	// offers that were already announced from another source.
+	fresh := make([]models.NewsDocument, 0, len(res.Items))
+	latest := sub.Since
+	for _, doc := range res.Items {
+		if doc.Timestamp.After(latest) {
+			latest = doc.Timestamp.UTC().Truncate(time.Second)
+		}
+		if !slices.Contains(sub.Sent, doc.ID) && !slices.Contains(sub.Sent, clusterKey(doc)) {
+			fresh = append(fresh, doc)
+		}
+	}
+	if len(fresh) == 0 {
+		if latest.After(sub.Since) || cursor != sub.Cursor {
+			return b.state.markDelivered(chatID, sub.Query, latest, nil, cursor)
+		}
+		return nil
+	}
+
+	clusters := groupByCluster(fresh)
+	text := "Новое по «" + html.EscapeString(sub.Query) + "»:\n\n" + formatClusters(clusters)
+	if err := b.tg.SendMessage(ctx, chatID, text); err != nil {
+		return err
+	}
+
+	// Cluster keys are remembered too, so later copies of an offer that was
+	// already announced from another source stay silent.
+	ids := make([]string, 0, len(fresh)+len(clusters))
+	for _, cluster := range clusters {
+		ids = append(ids, cluster.key)
+		for _, doc := range cluster.docs {
+			ids = append(ids, doc.ID)
+		}
+	}
+	return b.state.markDelivered(chatID, sub.Query, latest, ids, cursor)
+}
+
+// offerCluster is a set of documents describing the same offer.
+type offerCluster struct {
+	key  string
+	docs []models.NewsDocument
+}
+
+// clusterKey identifies the offer a document belongs to; documents indexed
+// without a cluster form their own.
+func clusterKey(doc models.NewsDocument) string {
+	if doc.ClusterID != "" {
+		return "cluster:" + doc.ClusterID
+	}
+	return "cluster:" + doc.ID
+}
+
+// groupByCluster groups documents by cluster, keeping first-seen order.
+func groupByCluster(docs []models.NewsDocument) []offerCluster {
+	var clusters []offerCluster
+	index := make(map[string]int, len(docs))
+	for _, doc := range docs {
+		key := clusterKey(doc)
+		i, ok := index[key]
+		if !ok {
+			i = len(clusters)
+			index[key] = i
+			clusters = append(clusters, offerCluster{key: key})
+		}
+		clusters[i].docs = append(clusters[i].docs, doc)
+	}
+	return clusters
+}
+
+// formatClusters renders one entry per offer, listing every source that
+// posted it and leading with the cheapest copy.
+func formatClusters(clusters []offerCluster) string {
+	var sb strings.Builder
+	for i, cluster := range clusters {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		if len(cluster.docs) == 1 {
+			sb.WriteString(formatDocs(cluster.docs))
+			continue
+		}
+
+		cheapest := cluster.docs[0]
+		sources := make([]string, 0, len(cluster.docs))
+		for _, doc := range cluster.docs {
+			if doc.Price > 0 && (cheapest.Price == 0 || doc.Price < cheapest.Price) {
+				cheapest = doc
+			}
+			if !slices.Contains(sources, doc.Source) {
+				sources = append(sources, doc.Source)
+			}
+		}
+
+		sb.WriteString("<b>" + html.EscapeString(cheapest.Title) + "</b>\n")
+		sb.WriteString(fmt.Sprintf("Источников: %d (%s)", len(sources), html.EscapeString(strings.Join(sources, ", "))))
+		if cheapest.Price > 0 {
+			sb.WriteString(fmt.Sprintf("\nЛучшая цена: %d ₽ · %s", cheapest.Price, html.EscapeString(cheapest.Source)))
+		}
+		if len(cheapest.URLs) > 0 {
+			sb.WriteString("\n" + html.EscapeString(cheapest.URLs[0]))
+		}
+	}
+	return sb.String()
+}
+
+// formatDocs renders documents as an HTML Telegram message body.
+func formatDocs(docs []models.NewsDocument) string {
+	var sb strings.Builder
+	for i, doc := range docs {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		sb.WriteString("<b>" + html.EscapeString(doc.Title) + "</b>\n")
+		sb.WriteString(html.EscapeString(doc.Source) + " · " + doc.Timestamp.Format("02.01 15:04"))
+		if len(doc.URLs) > 0 {
+			sb.WriteString("\n" + html.EscapeString(doc.URLs[0]))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+type stubMessenger struct {
+	sent []string
+}
+
+func (s *stubMessenger) SendMessage(_ context.Context, _ int64, text string) error {
+	s.sent = append(s.sent, text)
+	return nil
+}
+
+func TestParseCommand(t *testing.T) {
+	cmd, args := parseCommand("/search@hot_tour_bot  турция пляж ")
+	require.Equal(t, "/search", cmd)
+	require.Equal(t, "турция пляж", args)
+
+	cmd, args = parseCommand("египет")
+	require.Equal(t, "", cmd)
+	require.Equal(t, "египет", args)
+}
+
+func TestStateSubscriptionsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	state, err := loadState(path)
+	require.NoError(t, err)
+
+	now := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	added, err := state.subscribe(1, "турция", now)
+	require.NoError(t, err)
+	require.True(t, added)
+	added, err = state.subscribe(1, "турция", now)
+	require.NoError(t, err)
+	require.False(t, added)
+	_, err = state.subscribe(1, "египет", now)
+	require.NoError(t, err)
+
+	reloaded, err := loadState(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"турция", "египет"}, reloaded.queries(1))
+
+	removed, err := reloaded.unsubscribe(1, "")
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+	require.Empty(t, reloaded.queries(1))
+}
+
+func TestDeliverSendsOnlyNewDocuments(t *testing.T) {
+	ts := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "турция", r.URL.Query().Get("q"))
+		require.Equal(t, "timestamp:asc", r.URL.Query().Get("sort"))
+		require.Equal(t, "false", r.URL.Query().Get("relax"))
+		require.Equal(t, "partner-key", r.Header.Get("X-API-Key"))
+		_ = json.NewEncoder(w).Encode(searchResponse{Total: 2, Items: []models.NewsDocument{
+			{ID: "a", Title: "Анталья <всё включено>", Source: "telegram", Timestamp: ts},
+			{ID: "b", Title: "Кемер", Source: "telegram", Timestamp: ts.Add(time.Minute)},
+		}})
+	}))
+	defer srv.Close()
+
+	state, err := loadState(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	_, err = state.subscribe(7, "турция", ts)
+	require.NoError(t, err)
+
+	tg := &stubMessenger{}
+	b := &bot{
+		log:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		cfg:   &config.Bot{ResultLimit: 5},
+		tg:    tg,
+		radar: newRadarClient(srv.URL, "partner-key"),
+		state: state,
+	}
+
+	b.checkSubscriptions(context.Background())
+	require.Len(t, tg.sent, 1)
+	require.Contains(t, tg.sent[0], "Анталья &lt;всё включено&gt;")
+
+	// The same documents come back on the next poll and must not be resent.
+	b.checkSubscriptions(context.Background())
+	require.Len(t, tg.sent, 1)
+
+	subs := state.snapshot()[7]
+	require.Equal(t, ts.Add(time.Minute), subs[0].Since)
+}
+
+func TestDeliverRestartsExpiredCursor(t *testing.T) {
+	ts := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	var cursors []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cursor := r.URL.Query().Get("cursor")
+		cursors = append(cursors, cursor)
+		switch cursor {
+		case "stale":
+			w.WriteHeader(http.StatusGone)
+			_, _ = w.Write([]byte(`{"error":"cursor expired","code":"cursor_expired","hint":"start again"}`))
+		case "true":
+			require.Equal(t, ts.Format(time.RFC3339), r.URL.Query().Get("start"))
+			_ = json.NewEncoder(w).Encode(searchResponse{Total: 1, Cursor: "next", Items: []models.NewsDocument{
+				{ID: "a", Title: "Анталья", Source: "telegram", Timestamp: ts.Add(time.Minute)},
+			}})
+		default:
+			_ = json.NewEncoder(w).Encode(searchResponse{})
+		}
+	}))
+	defer srv.Close()
+
+	state, err := loadState(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	_, err = state.subscribe(7, "турция", ts)
+	require.NoError(t, err)
+	require.NoError(t, state.markDelivered(7, "турция", ts, nil, "stale"))
+
+	tg := &stubMessenger{}
+	b := &bot{
+		log:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		cfg:   &config.Bot{ResultLimit: 5},
+		tg:    tg,
+		radar: newRadarClient(srv.URL, ""),
+		state: state,
+	}
+
+	b.checkSubscriptions(context.Background())
+	require.Len(t, tg.sent, 1)
+	require.Equal(t, "next", state.snapshot()[7][0].Cursor)
+
+	// An empty page keeps the cursor.
+	b.checkSubscriptions(context.Background())
+	require.Len(t, tg.sent, 1)
+	require.Equal(t, []string{"stale", "true", "next"}, cursors)
+	require.Equal(t, "next", state.snapshot()[7][0].Cursor)
+}
+
+func TestDeliverGroupsClusters(t *testing.T) {
+	ts := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	batches := [][]models.NewsDocument{
+		{
+			{ID: "a", ClusterID: "c1", Title: "Анталья", Source: "tg_one", Price: 52000, Timestamp: ts},
+			{ID: "b", ClusterID: "c1", Title: "Анталья", Source: "tg_two", Price: 48000, URLs: []string{"https://two.example/tur"}, Timestamp: ts.Add(time.Minute)},
+			{ID: "c", ClusterID: "c2", Title: "Кемер", Source: "tg_one", Timestamp: ts.Add(2 * time.Minute)},
+		},
+		{
+			// A late copy of an already announced offer.
+			{ID: "d", ClusterID: "c1", Title: "Анталья", Source: "tg_three", Price: 45000, Timestamp: ts.Add(3 * time.Minute)},
+		},
+	}
+	call := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(searchResponse{Items: batches[call]})
+		call++
+	}))
+	defer srv.Close()
+
+	state, err := loadState(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	_, err = state.subscribe(7, "турция", ts)
+	require.NoError(t, err)
+
+	tg := &stubMessenger{}
+	b := &bot{
+		log:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		cfg:   &config.Bot{ResultLimit: 5},
+		tg:    tg,
+		radar: newRadarClient(srv.URL, ""),
+		state: state,
+	}
+
+	b.checkSubscriptions(context.Background())
+	require.Len(t, tg.sent, 1)
+	require.Contains(t, tg.sent[0], "Источников: 2 (tg_one, tg_two)")
+	require.Contains(t, tg.sent[0], "Лучшая цена: 48000 ₽ · tg_two")
+	require.Contains(t, tg.sent[0], "https://two.example/tur")
+	require.Contains(t, tg.sent[0], "Кемер")
+
+	b.checkSubscriptions(context.Background())
+	require.Len(t, tg.sent, 1)
+	require.Equal(t, ts.Add(3*time.Minute), state.snapshot()[7][0].Since)
+}
+
+func TestParseSpikeArgs(t *testing.T) {
+	destination, threshold, ok := parseSpikeArgs(" Доминиканская  Республика 1,5 ")
+	require.True(t, ok)
+	require.Equal(t, "доминиканская республика", destination)
+	require.Equal(t, 1.5, threshold)
+
+	destination, threshold, ok = parseSpikeArgs("Турция")
+	require.True(t, ok)
+	require.Equal(t, "турция", destination)
+	require.Zero(t, threshold)
+
+	_, _, ok = parseSpikeArgs("турция 0")
+	require.False(t, ok)
+}
+
+func TestFormatThreshold(t *testing.T) {
+	for threshold, want := range map[float64]string{
+		1:   "рост в 2 раза",
+		3:   "рост в 4 раза",
+		4:   "рост в 5 раз",
+		0.5: "рост в 1,5 раза",
+		10:  "рост в 11 раз",
+		20:  "рост в 21 раз",
+		21:  "рост в 22 раза",
+		111: "рост в 112 раз",
+	} {
+		require.Equal(t, want, formatThreshold(threshold))
+	}
+}
+
+func TestCheckSpikes(t *testing.T) {
+	now := time.Date(2024, 6, 2, 12, 0, 0, 0, time.UTC)
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.Equal(t, "турция", r.URL.Query().Get("destination"))
+		require.Equal(t, "false", r.URL.Query().Get("relax"))
+		total := int64(30)
+		if r.URL.Query().Get("end") != now.Format(time.RFC3339) {
+			total = 9
+		}
+		_ = json.NewEncoder(w).Encode(searchResponse{Total: total})
+	}))
+	defer srv.Close()
+
+	state, err := loadState(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	_, err = state.watchSpikes(7, "турция", 0)
+	require.NoError(t, err)
+	// (30-9)/(9+1) = 2.1 stays below this chat's threshold.
+	_, err = state.watchSpikes(8, "турция", 3)
+	require.NoError(t, err)
+
+	tg := &stubMessenger{}
+	b := &bot{
+		log:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		cfg:   &config.Bot{SpikeWindow: 24 * time.Hour, SpikeThreshold: 1, SpikeMinCount: 5},
+		tg:    tg,
+		radar: newRadarClient(srv.URL, ""),
+		state: state,
+	}
+
+	b.checkSpikes(context.Background(), now)
+	require.Len(t, tg.sent, 1)
+	require.Contains(t, tg.sent[0], "«турция»: 30 за последние 24 ч против 9")
+	// Both chats watch the same destination, which is counted once.
+	require.Equal(t, 2, requests)
+	require.Equal(t, now, state.spikeWatches()[7][0].NotifiedAt)
+	require.True(t, state.spikeWatches()[8][0].NotifiedAt.IsZero())
+
+	// The spike goes on, but chat 7 has heard of it already.
+	b.checkSpikes(context.Background(), now.Add(time.Hour))
+	require.Len(t, tg.sent, 1)
+}
+
+func TestCheckSpikesReportsPriceDrops(t *testing.T) {
+	now := time.Date(2024, 6, 2, 12, 0, 0, 0, time.UTC)
+	// The last window has 5 priced offers, the one before 4.
+	prices := map[bool][]int{true: {30000, 35000, 40000, 52000, 90000}, false: {50000, 55000, 65000, 80000}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		current := q.Get("end") == now.Format(time.RFC3339)
+		if q.Get("has_price") != "true" {
+			_ = json.NewEncoder(w).Encode(searchResponse{Total: 10})
+			return
+		}
+		require.Equal(t, "price:asc", q.Get("sort"))
+		list := prices[current]
+		from, _ := strconv.Atoi(q.Get("from"))
+		size, _ := strconv.Atoi(q.Get("size"))
+		var items []models.NewsDocument
+		for _, price := range list[from:min(from+size, len(list))] {
+			items = append(items, models.NewsDocument{Price: price})
+		}
+		_ = json.NewEncoder(w).Encode(searchResponse{Total: int64(len(list)), Items: items})
+	}))
+	defer srv.Close()
+
+	state, err := loadState(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	_, err = state.watchSpikes(7, "турция", 0)
+	require.NoError(t, err)
+
+	tg := &stubMessenger{}
+	b := &bot{
+		log:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		cfg:   &config.Bot{SpikeWindow: 24 * time.Hour, SpikeThreshold: 1, SpikeMinCount: 4, PriceDropThreshold: 0.15},
+		tg:    tg,
+		radar: newRadarClient(srv.URL, ""),
+		state: state,
+	}
+
+	// Medians 40000 against (55000+65000)/2; the volume is flat.
+	b.checkSpikes(context.Background(), now)
+	require.Len(t, tg.sent, 1)
+	require.Contains(t, tg.sent[0], "медиана 40000 ₽ за последние 24 ч против 60000 ₽ за такой же период до этого (−33%)")
+	require.Equal(t, now, state.spikeWatches()[7][0].PriceNotifiedAt)
+	require.True(t, state.spikeWatches()[7][0].NotifiedAt.IsZero())
+
+	b.checkSpikes(context.Background(), now.Add(time.Hour))
+	require.Len(t, tg.sent, 1)
+
+	// Too few priced offers in a window tell nothing about prices.
+	b.cfg.SpikeMinCount = 5
+	s, err := b.countSpike(context.Background(), "турция", now)
+	require.NoError(t, err)
+	require.Equal(t, 40000, s.median)
+	require.Zero(t, s.previousMedian)
+	require.Zero(t, s.dropping())
+}
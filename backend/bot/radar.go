@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// radarClient queries the hot-tour-radar HTTP API.
+type radarClient struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+type searchResponse struct {
+	Total int64
+	Items []models.NewsDocument
+	// Cursor continues a cursor=true walk after Items.
+	Cursor string
+}
+
+// errCursorExpired matches the apiError for a cursor past its validity
+// window; the walk has to start again without it.
+var errCursorExpired = errors.New("cursor expired")
+
+// apiError is an error response from the API.
+type apiError struct {
+	Status  string
+	Message string `json:"error"`
+	Code    string `json:"code"`
+	Hint    string `json:"hint"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("search failed: %s %s", e.Status, e.Message)
+}
+
+func (e *apiError) Is(target error) bool {
+	return target == errCursorExpired && e.Code == "cursor_expired"
+}
+
+func newRadarClient(baseURL, apiKey string) *radarClient {
+	return &radarClient{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// search calls GET /news with the given query parameters.
+func (c *radarClient) search(ctx context.Context, params url.Values) (*searchResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/news?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("build search request: %w", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		apiErr := &apiError{}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr = &apiError{Message: strings.TrimSpace(string(data))}
+		}
+		apiErr.Status = res.Status
+		return nil, apiErr
+	}
+
+	var parsed searchResponse
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode search response: %w", err)
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxResultWindow mirrors the deepest page GET /news serves; medians of
+// windows with more priced offers than that are not computed.
+const maxResultWindow = 10_000
+
+// spike compares a destination's documents of the last window with those
+// of the window before it.
+type spike struct {
+	count, previous int64
+	// median and previousMedian are the median prices of the windows'
+	// priced offers, 0 when a window has too few of them to tell.
+	median, previousMedian int
+}
+
+// rising is the rise as /trends reports it: 0 is unchanged, 1 twice as many.
+func (s spike) rising() float64 {
+	return float64(s.count-s.previous) / float64(s.previous+1)
+}
+
+// dropping is the share the median price fell by: 0.2 is 20% cheaper. It
+// is 0 when either median is unknown.
+func (s spike) dropping() float64 {
+	if s.median == 0 || s.previousMedian == 0 {
+		return 0
+	}
+	return float64(s.previousMedian-s.median) / float64(s.previousMedian)
+}
+
+// watchSpikes handles /spike <destination> [threshold].
+func (b *bot) watchSpikes(chatID int64, args string) string {
+	destination, threshold, ok := parseSpikeArgs(args)
+	if !ok {
+		return "Порог должен быть больше нуля."
+	}
+	if destination == "" {
+		return "Укажите направление: /spike турция, с порогом роста – /spike турция 2 (1 – вдвое больше предложений)"
+	}
+
+	added, err := b.state.watchSpikes(chatID, destination, threshold)
+	if err != nil {
+		b.log.Error("save spike watch", slog.Any("err", err))
+		return "Не удалось сохранить подписку, попробуйте позже."
+	}
+	if !added {
+		return "Порог для «" + html.EscapeString(destination) + "» обновлён: " + formatThreshold(b.threshold(threshold)) + "."
+	}
+	reply := "Готово! Сообщу, когда предложений по «" + html.EscapeString(destination) + "» станет больше: " + formatThreshold(b.threshold(threshold))
+	if b.cfg.PriceDropThreshold > 0 {
+		reply += ", или цены снизятся на " + formatDrop(b.cfg.PriceDropThreshold)
+	}
+	return reply + "."
+}
+
+// parseSpikeArgs splits the destination from an optional trailing
+// threshold, accepting a decimal comma. It reports false for a threshold
+// that is not positive.
+func parseSpikeArgs(args string) (string, float64, bool) {
+	fields := strings.Fields(strings.ToLower(args))
+	if len(fields) < 2 {
+		return strings.Join(fields, " "), 0, true
+	}
+	threshold, err := strconv.ParseFloat(strings.Replace(fields[len(fields)-1], ",", ".", 1), 64)
+	if err != nil {
+		return strings.Join(fields, " "), 0, true
+	}
+	return strings.Join(fields[:len(fields)-1], " "), threshold, threshold > 0
+}
+
+func (b *bot) unwatchSpikes(chatID int64, args string) string {
+	removed, err := b.state.unwatchSpikes(chatID, strings.Join(strings.Fields(strings.ToLower(args)), " "))
+	if err != nil {
+		b.log.Error("save spike watch", slog.Any("err", err))
+		return "Не удалось отписаться, попробуйте позже."
+	}
+	if removed == 0 {
+		return "Подписка не найдена."
+	}
+	return fmt.Sprintf("Удалено подписок: %d", removed)
+}
+
+// threshold returns the rise a watch fires at.
+func (b *bot) threshold(own float64) float64 {
+	if own > 0 {
+		return own
+	}
+	return b.cfg.SpikeThreshold
+}
+
+func formatThreshold(threshold float64) string {
+	factor := threshold + 1
+	return "рост в " + strings.Replace(strconv.FormatFloat(factor, 'f', -1, 64), ".", ",", 1) + " " + timesWord(factor)
+}
+
+// timesWord agrees "раз" with factor: "в 2 раза", "в 5 раз", "в 21 раз",
+// and "в 1,5 раза" for fractions.
+func timesWord(factor float64) string {
+	if factor != math.Trunc(factor) {
+		return "раза"
+	}
+	n := int64(factor) % 100
+	if n%10 >= 2 && n%10 <= 4 && (n < 12 || n > 14) {
+		return "раза"
+	}
+	return "раз"
+}
+
+func formatDrop(drop float64) string {
+	return strconv.Itoa(int(math.Round(drop*100))) + "%"
+}
+
+// checkSpikes counts the documents of every watched destination once and
+// notifies each chat whose threshold the rise reaches, and each chat
+// watching a destination whose median price fell by
+// BOT_PRICE_DROP_THRESHOLD. A chat hears about a spike, and about a price
+// drop, of a destination at most once per BOT_SPIKE_WINDOW, so a lasting
+// one is reported once.
+func (b *bot) checkSpikes(ctx context.Context, now time.Time) {
+	watches := b.state.spikeWatches()
+	spikes := make(map[string]spike)
+	for chatID, list := range watches {
+		for _, watch := range list {
+			volumeDue := now.Sub(watch.NotifiedAt) >= b.cfg.SpikeWindow
+			priceDue := b.cfg.PriceDropThreshold > 0 && now.Sub(watch.PriceNotifiedAt) >= b.cfg.SpikeWindow
+			if !volumeDue && !priceDue {
+				continue
+			}
+			s, ok := spikes[watch.Destination]
+			if !ok {
+				var err error
+				if s, err = b.countSpike(ctx, watch.Destination, now); err != nil {
+					b.log.Warn("count destination documents", slog.String("destination", watch.Destination), slog.Any("err", err))
+					continue
+				}
+				spikes[watch.Destination] = s
+			}
+
+			if volumeDue && s.count >= int64(b.cfg.SpikeMinCount) && s.rising() >= b.threshold(watch.Threshold) {
+				text := fmt.Sprintf("📈 Всплеск предложений по направлению «%s»: %d за последние %s против %d за такой же период до этого.\n\n/search %s",
+					html.EscapeString(watch.Destination), s.count, formatWindow(b.cfg.SpikeWindow), s.previous, html.EscapeString(watch.Destination))
+				if err := b.tg.SendMessage(ctx, chatID, text); err != nil {
+					b.log.Warn("send spike", slog.Int64("chat_id", chatID), slog.Any("err", err))
+				} else if err := b.state.markSpikeNotified(chatID, watch.Destination, now); err != nil {
+					b.log.Error("save spike watch", slog.Any("err", err))
+				}
+			}
+
+			if priceDue && s.dropping() >= b.cfg.PriceDropThreshold {
+				text := fmt.Sprintf("📉 Цены по направлению «%s» снижаются: медиана %d ₽ за последние %s против %d ₽ за такой же период до этого (−%s).\n\n/search %s",
+					html.EscapeString(watch.Destination), s.median, formatWindow(b.cfg.SpikeWindow), s.previousMedian, formatDrop(s.dropping()), html.EscapeString(watch.Destination))
+				if err := b.tg.SendMessage(ctx, chatID, text); err != nil {
+					b.log.Warn("send price drop", slog.Int64("chat_id", chatID), slog.Any("err", err))
+				} else if err := b.state.markPriceDropNotified(chatID, watch.Destination, now); err != nil {
+					b.log.Error("save spike watch", slog.Any("err", err))
+				}
+			}
+		}
+	}
+}
+
+// countSpike counts the destination's documents in the last window and
+// the one before it and, unless price drops are disabled, finds the
+// median price of each window's priced offers.
+func (b *bot) countSpike(ctx context.Context, destination string, now time.Time) (spike, error) {
+	window := b.cfg.SpikeWindow
+	search := func(start, end time.Time, extra url.Values) (*searchResponse, error) {
+		params := url.Values{}
+		params.Set("destination", destination)
+		params.Set("start", start.UTC().Format(time.RFC3339))
+		params.Set("end", end.UTC().Format(time.RFC3339))
+		params.Set("size", "1")
+		params.Set("relax", "false")
+		for name := range extra {
+			params.Set(name, extra.Get(name))
+		}
+		return b.radar.search(ctx, params)
+	}
+	count := func(start, end time.Time) (int64, error) {
+		res, err := search(start, end, nil)
+		if err != nil {
+			return 0, err
+		}
+		return res.Total, nil
+	}
+	// median reads the middle of the priced offers sorted by price: one
+	// search for their number, one for the page holding the middle.
+	median := func(start, end time.Time) (int, error) {
+		priced := url.Values{"has_price": {"true"}, "sort": {"price:asc"}}
+		res, err := search(start, end, priced)
+		if err != nil {
+			return 0, err
+		}
+		n := res.Total
+		if n < int64(b.cfg.SpikeMinCount) || n > maxResultWindow {
+			return 0, nil
+		}
+		priced.Set("from", strconv.FormatInt((n-1)/2, 10))
+		priced.Set("size", strconv.FormatInt(2-n%2, 10))
+		if res, err = search(start, end, priced); err != nil {
+			return 0, err
+		}
+		if int64(len(res.Items)) != 2-n%2 {
+			// Offers came or went between the two searches.
+			return 0, nil
+		}
+		if n%2 == 1 {
+			return res.Items[0].Price, nil
+		}
+		return (res.Items[0].Price + res.Items[1].Price) / 2, nil
+	}
+
+	var s spike
+	var err error
+	if s.count, err = count(now.Add(-window), now); err != nil {
+		return spike{}, err
+	}
+	if s.previous, err = count(now.Add(-2*window), now.Add(-window)); err != nil {
+		return spike{}, err
+	}
+	if b.cfg.PriceDropThreshold == 0 {
+		return s, nil
+	}
+	if s.median, err = median(now.Add(-window), now); err != nil {
+		return spike{}, err
+	}
+	if s.previousMedian, err = median(now.Add(-2*window), now.Add(-window)); err != nil {
+		return spike{}, err
+	}
+	return s, nil
+}
+
+func formatWindow(window time.Duration) string {
+	if window > 24*time.Hour && window%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d дн.", int(window/(24*time.Hour)))
+	}
+	return fmt.Sprintf("%d ч", int(window/time.Hour))
+}
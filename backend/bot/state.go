@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/statefile"
+)
+
+// maxRememberedIDs bounds the per-subscription memory of already delivered documents.
+const maxRememberedIDs = 200
+
+// subscription is a saved query whose new matches are pushed to a chat.
+type subscription struct {
+	Query string    `json:"query"`
+	Since time.Time `json:"since"`
+	// Sent holds recently delivered document IDs, so documents sharing the
+	// Since timestamp are not delivered twice.
+	Sent []string `json:"sent,omitempty"`
+	// Cursor continues the API walk where the last poll stopped. Since and
+	// Sent stay authoritative: when the cursor expires, polling restarts
+	// from Since.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// spikeWatch asks for a message when a destination's document count rises
+// by Threshold or more, or its median price drops by
+// BOT_PRICE_DROP_THRESHOLD or more.
+type spikeWatch struct {
+	Destination string `json:"destination"`
+	// Threshold is the chat's own rise; zero uses BOT_SPIKE_THRESHOLD.
+	Threshold float64 `json:"threshold,omitempty"`
+	// NotifiedAt and PriceNotifiedAt keep one spike or price drop from
+	// being reported on every poll.
+	NotifiedAt      time.Time `json:"notified_at,omitzero"`
+	PriceNotifiedAt time.Time `json:"price_notified_at,omitzero"`
+}
+
+// botState is persisted as JSON so subscriptions and the update offset survive restarts.
+type botState struct {
+	Offset        int64                     `json:"offset"`
+	Subscriptions map[int64][]*subscription `json:"subscriptions"`
+	Spikes        map[int64][]*spikeWatch   `json:"spikes,omitempty"`
+}
+
+type stateStore struct {
+	mu    sync.Mutex
+	path  string
+	state botState
+}
+
+func loadState(path string) (*stateStore, error) {
+	s := &stateStore{path: path, state: botState{Subscriptions: map[int64][]*subscription{}, Spikes: map[int64][]*spikeWatch{}}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, fmt.Errorf("decode state: %w", err)
+	}
+	if s.state.Subscriptions == nil {
+		s.state.Subscriptions = map[int64][]*subscription{}
+	}
+	if s.state.Spikes == nil {
+		s.state.Spikes = map[int64][]*spikeWatch{}
+	}
+	return s, nil
+}
+
+func (s *stateStore) offset() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Offset
+}
+
+func (s *stateStore) setOffset(offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Offset = offset
+	return s.saveLocked()
+}
+
+// subscribe adds query for chatID and reports false if it already existed.
+func (s *stateStore) subscribe(chatID int64, query string, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sub := range s.state.Subscriptions[chatID] {
+		if sub.Query == query {
+			return false, nil
+		}
+	}
+	s.state.Subscriptions[chatID] = append(s.state.Subscriptions[chatID], &subscription{Query: query, Since: now})
+	return true, s.saveLocked()
+}
+
+// unsubscribe removes query, or every subscription of the chat when query is empty.
+func (s *stateStore) unsubscribe(chatID int64, query string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs := s.state.Subscriptions[chatID]
+	kept := slices.DeleteFunc(slices.Clone(subs), func(sub *subscription) bool {
+		return query == "" || sub.Query == query
+	})
+	removed := len(subs) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	if len(kept) == 0 {
+		delete(s.state.Subscriptions, chatID)
+	} else {
+		s.state.Subscriptions[chatID] = kept
+	}
+	return removed, s.saveLocked()
+}
+
+func (s *stateStore) queries(chatID int64) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, 0, len(s.state.Subscriptions[chatID]))
+	for _, sub := range s.state.Subscriptions[chatID] {
+		out = append(out, sub.Query)
+	}
+	return out
+}
+
+// snapshot returns a copy of all subscriptions keyed by chat.
+func (s *stateStore) snapshot() map[int64][]subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[int64][]subscription, len(s.state.Subscriptions))
+	for chatID, subs := range s.state.Subscriptions {
+		for _, sub := range subs {
+			out[chatID] = append(out[chatID], subscription{Query: sub.Query, Since: sub.Since, Sent: slices.Clone(sub.Sent), Cursor: sub.Cursor})
+		}
+	}
+	return out
+}
+
+// markDelivered advances the subscription past the delivered documents and
+// stores the API cursor to continue from.
+func (s *stateStore) markDelivered(chatID int64, query string, since time.Time, ids []string, cursor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sub := range s.state.Subscriptions[chatID] {
+		if sub.Query != query {
+			continue
+		}
+		if since.After(sub.Since) {
+			sub.Since = since
+		}
+		sub.Sent = append(sub.Sent, ids...)
+		sub.Cursor = cursor
+		if len(sub.Sent) > maxRememberedIDs {
+			sub.Sent = sub.Sent[len(sub.Sent)-maxRememberedIDs:]
+		}
+		return s.saveLocked()
+	}
+	return nil
+}
+
+// watchSpikes adds or updates the watch of destination for chatID and
+// reports false if it already existed.
+func (s *stateStore) watchSpikes(chatID int64, destination string, threshold float64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, watch := range s.state.Spikes[chatID] {
+		if watch.Destination == destination {
+			watch.Threshold = threshold
+			return false, s.saveLocked()
+		}
+	}
+	s.state.Spikes[chatID] = append(s.state.Spikes[chatID], &spikeWatch{Destination: destination, Threshold: threshold})
+	return true, s.saveLocked()
+}
+
+// unwatchSpikes removes the watch of destination, or every watch of the
+// chat when destination is empty.
+func (s *stateStore) unwatchSpikes(chatID int64, destination string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	watches := s.state.Spikes[chatID]
+	kept := slices.DeleteFunc(slices.Clone(watches), func(watch *spikeWatch) bool {
+		return destination == "" || watch.Destination == destination
+	})
+	removed := len(watches) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	if len(kept) == 0 {
+		delete(s.state.Spikes, chatID)
+	} else {
+		s.state.Spikes[chatID] = kept
+	}
+	return removed, s.saveLocked()
+}
+
+// spikeWatches returns a copy of all spike watches keyed by chat.
+func (s *stateStore) spikeWatches() map[int64][]spikeWatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[int64][]spikeWatch, len(s.state.Spikes))
+	for chatID, watches := range s.state.Spikes {
+		for _, watch := range watches {
+			out[chatID] = append(out[chatID], *watch)
+		}
+	}
+	return out
+}
+
+func (s *stateStore) markSpikeNotified(chatID int64, destination string, at time.Time) error {
+	return s.updateSpikeWatch(chatID, destination, func(watch *spikeWatch) { watch.NotifiedAt = at })
+}
+
+func (s *stateStore) markPriceDropNotified(chatID int64, destination string, at time.Time) error {
+	return s.updateSpikeWatch(chatID, destination, func(watch *spikeWatch) { watch.PriceNotifiedAt = at })
+}
+
+func (s *stateStore) updateSpikeWatch(chatID int64, destination string, update func(*spikeWatch)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, watch := range s.state.Spikes[chatID] {
+		if watch.Destination == destination {
+			update(watch)
+			return s.saveLocked()
+		}
+	}
+	return nil
+}
+
+// saveLocked writes the state atomically.
+func (s *stateStore) saveLocked() error {
+	return statefile.Save(s.path, s.state)
+}
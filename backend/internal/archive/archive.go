@@ -0,0 +1,117 @@
+// Package archive stores documents as gzip-compressed NDJSON objects in
+// S3-compatible storage (Amazon S3, MinIO), so retention can keep a copy of
+// what it deletes for later analysis.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Store uploads finished objects.
+type Store interface {
+	Put(ctx context.Context, key string, body []byte) error
+}
+
+// Archive names objects and uploads them to a Store.
+type Archive struct {
+	store      Store
+	prefix     string
+	objectDocs int
+	now        func() time.Time
+}
+
+// New returns an Archive that writes objects under prefix (for example
+// "archive/news/") with at most objectDocs documents each.
+func New(store Store, prefix string, objectDocs int) *Archive {
+	return &Archive{store: store, prefix: prefix, objectDocs: max(objectDocs, 1), now: time.Now}
+}
+
+// NewWriter starts an export. Its objects are named
+// <prefix><yyyy>/<mm>/<dd>/<name>-<hhmmss>-<part>.ndjson.gz after the UTC
+// time the export started.
+func (a *Archive) NewWriter(name string) *Writer {
+	return &Writer{archive: a, name: name, started: a.now().UTC()}
+}
+
+// Writer buffers documents into compressed NDJSON and uploads an object
+// every objectDocs documents and on Close. It is not safe for concurrent use.
+type Writer struct {
+	archive *Archive
+	name    string
+	started time.Time
+
+	buf  bytes.Buffer
+	gz   *gzip.Writer
+	docs int
+	part int
+
+	written int64
+	keys    []string
+}
+
+// Write appends one JSON document. It uploads the current object once it
+// is full, so it may block on the network.
+func (w *Writer) Write(ctx context.Context, doc json.RawMessage) error {
+	if w.gz == nil {
+		w.buf.Reset()
+		w.gz = gzip.NewWriter(&w.buf)
+	}
+
+	// NDJSON needs every document on one line.
+	var line bytes.Buffer
+	if err := json.Compact(&line, doc); err != nil {
+		return fmt.Errorf("archive document: %w", err)
+	}
+	line.WriteByte('\n')
+	if _, err := w.gz.Write(line.Bytes()); err != nil {
+		return fmt.Errorf("compress document: %w", err)
+	}
+
+	w.docs++
+	if w.docs == w.archive.objectDocs {
+		return w.flush(ctx)
+	}
+	return nil
+}
+
+// Close uploads the documents written since the last object, if any.
+func (w *Writer) Close(ctx context.Context) error {
+	if w.docs == 0 {
+		return nil
+	}
+	return w.flush(ctx)
+}
+
+// Written reports how many documents have been uploaded.
+func (w *Writer) Written() int64 {
+	return w.written
+}
+
+// Keys lists the uploaded objects in order.
+func (w *Writer) Keys() []string {
+	return w.keys
+}
+
+func (w *Writer) flush(ctx context.Context) error {
+	if err := w.gz.Close(); err != nil {
+		return fmt.Errorf("compress archive: %w", err)
+	}
+	w.gz = nil
+
+	w.part++
+	key := fmt.Sprintf("%s%s/%s-%s-%04d.ndjson.gz",
+		w.archive.prefix, w.started.Format("2006/01/02"), w.name, w.started.Format("150405"), w.part)
+	if err := w.archive.store.Put(ctx, key, w.buf.Bytes()); err != nil {
+		return err
+	}
+
+	w.written += int64(w.docs)
+	w.docs = 0
+	w.keys = append(w.keys, key)
+	return nil
+}
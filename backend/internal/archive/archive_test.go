@@ -0,0 +1,137 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/awsauth"
+)
+
+// fakeS3 records uploaded objects by path.
+func fakeS3(t *testing.T) (*S3, map[string][]byte) {
+	t.Helper()
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPut, r.Method)
+		require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		sum := sha256.Sum256(body)
+		require.Equal(t, hex.EncodeToString(sum[:]), r.Header.Get("X-Amz-Content-Sha256"))
+
+		mu.Lock()
+		objects[r.URL.Path] = body
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+
+	store, err := NewS3(srv.URL, "us-east-1", "tours", awsauth.Static{AccessKeyID: "minio", SecretAccessKey: "minio123"})
+	require.NoError(t, err)
+	return store, objects
+}
+
+func readNDJSON(t *testing.T, object []byte) []string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(object))
+	require.NoError(t, err)
+	var lines []string
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
+
+func TestWriterSplitsObjects(t *testing.T) {
+	store, objects := fakeS3(t)
+	a := New(store, "archive/news/", 2)
+	a.now = func() time.Time { return time.Date(2024, 6, 1, 10, 30, 0, 0, time.UTC) }
+
+	w := a.NewWriter("aged")
+	ctx := context.Background()
+	for _, doc := range []string{`{"id":"a"}`, "{\n  \"id\": \"b\"\n}", `{"id":"c"}`} {
+		require.NoError(t, w.Write(ctx, json.RawMessage(doc)))
+	}
+	require.NoError(t, w.Close(ctx))
+
+	require.Equal(t, int64(3), w.Written())
+	require.Equal(t, []string{
+		"archive/news/2024/06/01/aged-103000-0001.ndjson.gz",
+		"archive/news/2024/06/01/aged-103000-0002.ndjson.gz",
+	}, w.Keys())
+	require.Equal(t, []string{`{"id":"a"}`, `{"id":"b"}`}, readNDJSON(t, objects["/tours/archive/news/2024/06/01/aged-103000-0001.ndjson.gz"]))
+	require.Equal(t, []string{`{"id":"c"}`}, readNDJSON(t, objects["/tours/archive/news/2024/06/01/aged-103000-0002.ndjson.gz"]))
+
+	empty := a.NewWriter("expired")
+	require.NoError(t, empty.Close(ctx))
+	require.Empty(t, empty.Keys())
+}
+
+type failingStore struct{}
+
+func (failingStore) Put(context.Context, string, []byte) error {
+	return errors.New("bucket unavailable")
+}
+
+func TestWriterReportsUploadFailure(t *testing.T) {
+	w := New(failingStore{}, "", 10).NewWriter("aged")
+	require.NoError(t, w.Write(context.Background(), json.RawMessage(`{"id":"a"}`)))
+	require.ErrorContains(t, w.Close(context.Background()), "bucket unavailable")
+	require.Zero(t, w.Written())
+}
+
+func TestNewS3(t *testing.T) {
+	store, err := NewS3("", "eu-central-1", "tours", awsauth.Static{})
+	require.NoError(t, err)
+	require.Equal(t, "s3.eu-central-1.amazonaws.com", store.endpoint.Host)
+
+	_, err = NewS3("minio:9000", "us-east-1", "tours", awsauth.Static{})
+	require.ErrorContains(t, err, "invalid s3 endpoint")
+}
+
+func TestS3Get(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Contains(t, r.Header.Get("Authorization"), "Credential=key/")
+		switch r.URL.Path {
+		case "/data/rules.yaml":
+			_, _ = w.Write([]byte("- name: drop ads\n"))
+		case "/data/broken.yaml":
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+		default:
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	store, err := NewS3(srv.URL, "us-east-1", "data", awsauth.Static{AccessKeyID: "key", SecretAccessKey: "secret"})
+	require.NoError(t, err)
+
+	body, err := store.Get(context.Background(), "rules.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "- name: drop ads\n", string(body))
+
+	_, err = store.Get(context.Background(), "missing.yaml")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = store.Get(context.Background(), "broken.yaml")
+	require.ErrorContains(t, err, "status 403: AccessDenied")
+	require.NotErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,132 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/awsauth"
+)
+
+// ErrNotFound is returned by Get for an object the bucket does not hold.
+var ErrNotFound = errors.New("s3 object not found")
+
+// S3 uploads and downloads objects of a bucket of Amazon S3 or an S3-compatible service
+// such as MinIO. Requests use path-style URLs (<endpoint>/<bucket>/<key>),
+// which every S3-compatible service accepts, and are signed with AWS
+// Signature Version 4.
+type S3 struct {
+	endpoint *url.URL
+	region   string
+	bucket   string
+	creds    awsauth.Source
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3 returns a Store for bucket, signing requests with creds. An empty
+// endpoint selects Amazon S3 in region; MinIO and other services need
+// their URL, e.g. http://minio:9000.
+func NewS3(endpoint, region, bucket string, creds awsauth.Source) (*S3, error) {
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", endpoint)
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+	return &S3{
+		endpoint: u,
+		region:   region,
+		bucket:   bucket,
+		creds:    creds,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		now:      time.Now,
+	}, nil
+}
+
+// Put uploads body as the object key.
+func (s *S3) Put(ctx context.Context, key string, body []byte) error {
+	u := *s.endpoint
+	u.Path += "/" + s.bucket + "/" + key
+	// Sign and send the same, fully escaped path.
+	u.RawPath = awsauth.EscapePath(u.Path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build s3 request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	sum := sha256.Sum256(body)
+	req.Header.Set(awsauth.ContentHashHeader, hex.EncodeToString(sum[:]))
+	if err := s.sign(req); err != nil {
+		return err
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("put s3 object %s: %w", key, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("put s3 object %s: status %d: %s", key, res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Get downloads the object key, or returns ErrNotFound when there is none.
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	u := *s.endpoint
+	u.Path += "/" + s.bucket + "/" + key
+	u.RawPath = awsauth.EscapePath(u.Path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("build s3 request: %w", err)
+	}
+	req.Header.Set(awsauth.ContentHashHeader, awsauth.EmptyPayloadHash)
+	if err := s.sign(req); err != nil {
+		return nil, err
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get s3 object %s: %w", key, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("get s3 object %s: %w", key, ErrNotFound)
+	}
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("get s3 object %s: status %d: %s", key, res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read s3 object %s: %w", key, err)
+	}
+	return body, nil
+}
+
+func (s *S3) sign(req *http.Request) error {
+	creds, err := s.creds.Credentials(req.Context())
+	if err != nil {
+		return fmt.Errorf("load aws credentials: %w", err)
+	}
+	awsauth.Sign(req, creds, s.region, "s3", s.now())
+	return nil
+}
@@ -0,0 +1,155 @@
+package awsauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIMDSEndpoint = "http://169.254.169.254"
+	// credentialRefreshMargin renews instance credentials before they expire.
+	credentialRefreshMargin = 5 * time.Minute
+)
+
+// Credentials sign requests. SessionToken is only set for temporary
+// credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Expires is zero for static credentials.
+	Expires time.Time
+}
+
+// Source supplies the credentials for each request.
+type Source interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// Static is a Source of fixed credentials.
+type Static Credentials
+
+func (s Static) Credentials(context.Context) (Credentials, error) {
+	return Credentials(s), nil
+}
+
+// NewSource returns the given keys when accessKeyID is set and otherwise
+// a Provider of the standard AWS credentials.
+func NewSource(accessKeyID, secretAccessKey string) Source {
+	if accessKeyID != "" {
+		return Static{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey}
+	}
+	return NewProvider(os.Getenv)
+}
+
+// Provider resolves credentials from the standard AWS_* variables or, when
+// they are unset, from the EC2 instance metadata service, and keeps them
+// until shortly before they expire.
+type Provider struct {
+	getenv func(string) string
+	client *http.Client
+
+	mu     sync.Mutex
+	cached Credentials
+}
+
+// NewProvider reads the environment with getenv, normally os.Getenv.
+func NewProvider(getenv func(string) string) *Provider {
+	return &Provider{getenv: getenv, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (c *Provider) Credentials(ctx context.Context) (Credentials, error) {
+	if id, secret := c.getenv("AWS_ACCESS_KEY_ID"), c.getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return Credentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: c.getenv("AWS_SESSION_TOKEN")}, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cached.AccessKeyID != "" && time.Until(c.cached.Expires) > credentialRefreshMargin {
+		return c.cached, nil
+	}
+
+	creds, err := c.fromIMDS(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	c.cached = creds
+	return creds, nil
+}
+
+// fromIMDS fetches the instance role's credentials using IMDSv2.
+func (c *Provider) fromIMDS(ctx context.Context) (Credentials, error) {
+	endpoint := strings.TrimRight(c.getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"), "/")
+	if endpoint == "" {
+		endpoint = defaultIMDSEndpoint
+	}
+
+	token, err := c.imdsCall(ctx, http.MethodPut, endpoint+"/latest/api/token", map[string]string{
+		"X-aws-ec2-metadata-token-ttl-seconds": "21600",
+	})
+	if err != nil {
+		return Credentials{}, fmt.Errorf("imds token: %w", err)
+	}
+	auth := map[string]string{"X-aws-ec2-metadata-token": token}
+
+	roles, err := c.imdsCall(ctx, http.MethodGet, endpoint+"/latest/meta-data/iam/security-credentials/", auth)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("imds role: %w", err)
+	}
+	role, _, _ := strings.Cut(strings.TrimSpace(roles), "\n")
+	if role == "" {
+		return Credentials{}, errors.New("imds role: instance has no IAM role")
+	}
+
+	raw, err := c.imdsCall(ctx, http.MethodGet, endpoint+"/latest/meta-data/iam/security-credentials/"+role, auth)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("imds credentials: %w", err)
+	}
+	var parsed struct {
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return Credentials{}, fmt.Errorf("decode imds credentials: %w", err)
+	}
+	return Credentials{
+		AccessKeyID:     parsed.AccessKeyID,
+		SecretAccessKey: parsed.SecretAccessKey,
+		SessionToken:    parsed.Token,
+		Expires:         parsed.Expiration,
+	}, nil
+}
+
+func (c *Provider) imdsCall(ctx context.Context, method, url string, headers map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s %s: status %d", method, url, res.StatusCode)
+	}
+	return string(body), nil
+}
@@ -0,0 +1,21 @@
+package awsauth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSource(t *testing.T) {
+	creds, err := NewSource("key", "secret").Credentials(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}, creds)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	t.Setenv("AWS_SESSION_TOKEN", "env-token")
+	creds, err = NewSource("", "").Credentials(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, Credentials{AccessKeyID: "env-key", SecretAccessKey: "env-secret", SessionToken: "env-token"}, creds)
+}
@@ -0,0 +1,142 @@
+// Package chaos injects failures and latency into Elasticsearch and Kafka
+// calls so integration suites can exercise retries and the dead-letter
+// path. It is only configurable in binaries built with -tags chaos; see
+// FromEnv.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Config sets the share of calls that fail and the delay added to every
+// call, per dependency. Rates are probabilities in [0, 1].
+type Config struct {
+	ESFailureRate    float64
+	ESLatency        time.Duration
+	KafkaFailureRate float64
+	KafkaLatency     time.Duration
+	// Seed makes the injected failures reproducible; 0 picks a random one.
+	Seed uint64
+}
+
+// Active reports whether c injects anything.
+func (c Config) Active() bool {
+	return c.ESFailureRate > 0 || c.ESLatency > 0 || c.KafkaFailureRate > 0 || c.KafkaLatency > 0
+}
+
+// injector decides, from a shared seeded source, which calls fail.
+type injector struct {
+	rate    float64
+	latency time.Duration
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newInjector(rate float64, latency time.Duration, seed uint64) *injector {
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &injector{rate: rate, latency: latency, rng: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// apply waits for the configured latency and reports whether the call
+// should fail.
+func (in *injector) apply(ctx context.Context) (bool, error) {
+	if in.latency > 0 {
+		select {
+		case <-time.After(in.latency):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+	if in.rate <= 0 {
+		return false, nil
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.rng.Float64() < in.rate, nil
+}
+
+// WrapTransport returns base wrapped so that Elasticsearch requests are
+// delayed and, at ESFailureRate, answered with a synthetic 503.
+func (c Config) WrapTransport(base http.RoundTripper) http.RoundTripper {
+	if c.ESFailureRate <= 0 && c.ESLatency <= 0 {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, in: newInjector(c.ESFailureRate, c.ESLatency, c.Seed)}
+}
+
+type transport struct {
+	base http.RoundTripper
+	in   *injector
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fail, err := t.in.apply(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	if !fail {
+		return t.base.RoundTrip(req)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Elastic-Product", "Elasticsearch")
+	return &http.Response{
+		Status:     "503 Service Unavailable",
+		StatusCode: http.StatusServiceUnavailable,
+		Proto:      req.Proto,
+		ProtoMajor: req.ProtoMajor,
+		ProtoMinor: req.ProtoMinor,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(`{"error":{"type":"chaos_injected_failure","reason":"injected by chaos mode"},"status":503}`)),
+		Request:    req,
+	}, nil
+}
+
+// MessageWriter is the Kafka producer interface the wrapper decorates.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// WrapWriter returns w wrapped so that writes are delayed and, at
+// KafkaFailureRate, fail with a retriable Kafka error without being sent.
+func (c Config) WrapWriter(w MessageWriter) MessageWriter {
+	if c.KafkaFailureRate <= 0 && c.KafkaLatency <= 0 {
+		return w
+	}
+	return &writer{next: w, in: newInjector(c.KafkaFailureRate, c.KafkaLatency, c.Seed)}
+}
+
+type writer struct {
+	next MessageWriter
+	in   *injector
+}
+
+func (w *writer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	fail, err := w.in.apply(ctx)
+	if err != nil {
+		return err
+	}
+	if fail {
+		return fmt.Errorf("chaos: injected write failure: %w", kafka.LeaderNotAvailable)
+	}
+	return w.next.WriteMessages(ctx, msgs...)
+}
@@ -0,0 +1,77 @@
+package chaos_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/chaos"
+)
+
+type countingWriter struct {
+	writes int
+}
+
+func (w *countingWriter) WriteMessages(context.Context, ...kafka.Message) error {
+	w.writes++
+	return nil
+}
+
+func TestInactiveConfigLeavesDependenciesAlone(t *testing.T) {
+	var cfg chaos.Config
+	require.False(t, cfg.Active())
+
+	w := &countingWriter{}
+	require.Same(t, w, cfg.WrapWriter(w))
+	require.Equal(t, http.DefaultTransport, cfg.WrapTransport(http.DefaultTransport))
+}
+
+func TestWrapTransportFailsRequests(t *testing.T) {
+	var served int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		served++
+	}))
+	t.Cleanup(srv.Close)
+
+	client := &http.Client{Transport: chaos.Config{ESFailureRate: 1}.WrapTransport(nil)}
+	res, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	require.Zero(t, served)
+
+	client = &http.Client{Transport: chaos.Config{ESLatency: 20 * time.Millisecond}.WrapTransport(nil)}
+	start := time.Now()
+	res, err = client.Get(srv.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	require.Equal(t, 1, served)
+}
+
+func TestWrapWriterIsReproducible(t *testing.T) {
+	outcomes := func() []bool {
+		w := chaos.Config{KafkaFailureRate: 0.5, Seed: 42}.WrapWriter(&countingWriter{})
+		out := make([]bool, 20)
+		for i := range out {
+			err := w.WriteMessages(context.Background(), kafka.Message{})
+			if err != nil {
+				require.True(t, errors.Is(err, kafka.LeaderNotAvailable))
+			}
+			out[i] = err != nil
+		}
+		return out
+	}
+
+	first := outcomes()
+	require.Equal(t, first, outcomes())
+	require.Contains(t, first, true)
+	require.Contains(t, first, false)
+}
@@ -0,0 +1,25 @@
+//go:build !chaos
+
+package chaos
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Enabled reports whether the binary was built with -tags chaos.
+const Enabled = false
+
+// FromEnv returns a Config that injects nothing. Setting any CHAOS_*
+// variable is an error, so a suite never silently runs without faults
+// against a production build.
+func FromEnv() (Config, error) {
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "CHAOS_") && value != "" {
+			return Config{}, fmt.Errorf("%s is set but chaos mode requires a binary built with -tags chaos", name)
+		}
+	}
+	return Config{}, nil
+}
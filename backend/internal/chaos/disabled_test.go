@@ -0,0 +1,22 @@
+//go:build !chaos
+
+package chaos_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/chaos"
+)
+
+func TestFromEnvRequiresBuildTag(t *testing.T) {
+	t.Setenv("CHAOS_ES_FAILURE_RATE", "")
+	cfg, err := chaos.FromEnv()
+	require.NoError(t, err)
+	require.False(t, cfg.Active())
+
+	t.Setenv("CHAOS_ES_FAILURE_RATE", "0.5")
+	_, err = chaos.FromEnv()
+	require.ErrorContains(t, err, "-tags chaos")
+}
@@ -0,0 +1,55 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Enabled reports whether the binary was built with -tags chaos.
+const Enabled = true
+
+// FromEnv reads CHAOS_ES_FAILURE_RATE, CHAOS_ES_LATENCY,
+// CHAOS_KAFKA_FAILURE_RATE, CHAOS_KAFKA_LATENCY and CHAOS_SEED.
+func FromEnv() (Config, error) {
+	var c Config
+	var errs []error
+	rate := func(name string, dst *float64) {
+		if raw := os.Getenv(name); raw != "" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil || v < 0 || v > 1 {
+				errs = append(errs, fmt.Errorf("%s must be a number in [0, 1]", name))
+				return
+			}
+			*dst = v
+		}
+	}
+	latency := func(name string, dst *time.Duration) {
+		if raw := os.Getenv(name); raw != "" {
+			v, err := time.ParseDuration(raw)
+			if err != nil || v < 0 {
+				errs = append(errs, fmt.Errorf("%s must be a non-negative duration", name))
+				return
+			}
+			*dst = v
+		}
+	}
+
+	rate("CHAOS_ES_FAILURE_RATE", &c.ESFailureRate)
+	latency("CHAOS_ES_LATENCY", &c.ESLatency)
+	rate("CHAOS_KAFKA_FAILURE_RATE", &c.KafkaFailureRate)
+	latency("CHAOS_KAFKA_LATENCY", &c.KafkaLatency)
+	if raw := os.Getenv("CHAOS_SEED"); raw != "" {
+		seed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("CHAOS_SEED must be a non-negative integer"))
+		}
+		c.Seed = seed
+	}
+
+	return c, errors.Join(errs...)
+}
@@ -0,0 +1,26 @@
+//go:build chaos
+
+package chaos_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/chaos"
+)
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("CHAOS_ES_FAILURE_RATE", "0.25")
+	t.Setenv("CHAOS_KAFKA_LATENCY", "150ms")
+	t.Setenv("CHAOS_SEED", "7")
+
+	cfg, err := chaos.FromEnv()
+	require.NoError(t, err)
+	require.Equal(t, chaos.Config{ESFailureRate: 0.25, KafkaLatency: 150 * time.Millisecond, Seed: 7}, cfg)
+
+	t.Setenv("CHAOS_KAFKA_FAILURE_RATE", "2")
+	_, err = chaos.FromEnv()
+	require.ErrorContains(t, err, "CHAOS_KAFKA_FAILURE_RATE")
+}
@@ -0,0 +1,119 @@
+// Package concepts maps keywords written in different languages or
+// spellings ("egypt", "египет") to one canonical concept, so trends are not
+// split between them.
+package concepts
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+)
+
+//go:embed concepts.json
+var defaultTable []byte
+
+// Concept is a canonical keyword and the variants that count toward it.
+type Concept struct {
+	Name     string   `json:"name"`
+	Variants []string `json:"variants"`
+}
+
+// Table resolves keywords to concepts.
+type Table struct {
+	canonical map[string]string
+}
+
+// Default returns the mapping table shipped with the service. It is parsed
+// once and shared; a Table is never modified after Parse.
+func Default() *Table {
+	return parseDefault()
+}
+
+var parseDefault = sync.OnceValue(func() *Table {
+	t, err := Parse(defaultTable)
+	if err != nil {
+		panic(fmt.Sprintf("embedded keyword concepts: %v", err))
+	}
+	return t
+})
+
+// Load reads a mapping table from a JSON file, or returns Default when path is empty.
+func Load(path string) (*Table, error) {
+	if path == "" {
+		return Default(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read keyword concepts: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse builds a table from its JSON form: a list of concepts.
+func Parse(data []byte) (*Table, error) {
+	var list []Concept
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("decode keyword concepts: %w", err)
+	}
+
+	t := &Table{canonical: make(map[string]string)}
+	for _, c := range list {
+		name := normalize(c.Name)
+		if name == "" {
+			return nil, fmt.Errorf("concept without a name")
+		}
+		for _, v := range append([]string{c.Name}, c.Variants...) {
+			key := normalize(v)
+			if key == "" {
+				continue
+			}
+			if other, ok := t.canonical[key]; ok && other != name {
+				return nil, fmt.Errorf("variant %q used by both %q and %q", v, other, name)
+			}
+			t.canonical[key] = name
+		}
+	}
+	return t, nil
+}
+
+// Canonical returns the concept keyword belongs to, or the normalized
+// keyword itself when the table does not know it.
+func (t *Table) Canonical(keyword string) string {
+	key := normalize(keyword)
+	if name, ok := t.canonical[key]; ok {
+		return name
+	}
+	return key
+}
+
+// WithStems returns a copy of t that also maps the stem of every variant
+// to its concept, for keywords stored as stems: "турц" is reported as
+// "турция". A stem shared by two concepts resolves to neither.
+func (t *Table) WithStems(stem func(string) string) *Table {
+	out := &Table{canonical: make(map[string]string, 2*len(t.canonical))}
+	maps.Copy(out.canonical, t.canonical)
+	ambiguous := make(map[string]struct{})
+	for variant, name := range t.canonical {
+		key := stem(variant)
+		if _, known := t.canonical[key]; known {
+			continue
+		}
+		if other, ok := out.canonical[key]; ok && other != name {
+			ambiguous[key] = struct{}{}
+			continue
+		}
+		out.canonical[key] = name
+	}
+	for key := range ambiguous {
+		delete(out.canonical, key)
+	}
+	return out
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
@@ -0,0 +1,35 @@
+package concepts_test
+
+import (
+	"testing"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/concepts"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/stemmer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonical(t *testing.T) {
+	table := concepts.Default()
+	require.Equal(t, "египет", table.Canonical("Egypt"))
+	require.Equal(t, "египет", table.Canonical("египет"))
+	require.Equal(t, "таиланд", table.Canonical(" тайланд "))
+	require.Equal(t, "горы", table.Canonical("Горы"))
+}
+
+func TestWithStems(t *testing.T) {
+	table, err := concepts.Parse([]byte(`[{"name": "турция", "variants": ["turkey"]}, {"name": "горы", "variants": ["гора"]}]`))
+	require.NoError(t, err)
+	stemmed := table.WithStems(stemmer.Russian)
+	require.Equal(t, "турция", stemmed.Canonical("турц"))
+	require.Equal(t, "горы", stemmed.Canonical("гор"))
+	require.Equal(t, "турция", stemmed.Canonical("turkey"))
+	require.Equal(t, "турц", table.Canonical("турц"))
+}
+
+func TestParseRejectsSharedVariants(t *testing.T) {
+	_, err := concepts.Parse([]byte(`[{"name": "кипр", "variants": ["cyprus"]}, {"name": "Кипр-Север", "variants": ["Cyprus"]}]`))
+	require.ErrorContains(t, err, "used by both")
+
+	_, err = concepts.Parse([]byte(`[{"variants": ["x"]}]`))
+	require.ErrorContains(t, err, "without a name")
+}
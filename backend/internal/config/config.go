@@ -1,8 +1,14 @@
+// Package config loads service configuration from environment variables.
+// Each Load function reports every missing, malformed or invalid setting in
+// one error instead of stopping at the first.
 package config
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"net/netip"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -10,171 +16,594 @@ import (
 
 // Common contains Elasticsearch parameters shared by every service.
 type Common struct {
-	ElasticsearchAddr  string
-	ElasticsearchIndex string
+	ElasticsearchAddr  string `env:"ELASTICSEARCH_ADDR" default:"http://elasticsearch:9200"`
+	ElasticsearchIndex string `env:"ELASTICSEARCH_INDEX" default:"news"`
+	// ElasticsearchAWSRegion enables SigV4 request signing for Amazon
+	// OpenSearch Service when set.
+	ElasticsearchAWSRegion  string `env:"ELASTICSEARCH_AWS_REGION"`
+	ElasticsearchAWSService string `env:"ELASTICSEARCH_AWS_SERVICE" default:"es"`
+	// ElasticsearchDailyIndices keeps news documents in one index per day
+	// behind an alias named ElasticsearchIndex.
+	ElasticsearchDailyIndices bool `env:"ELASTICSEARCH_DAILY_INDICES" default:"false"`
+	// ElasticsearchSlowThreshold logs requests taking at least this long;
+	// zero disables the log.
+	ElasticsearchSlowThreshold time.Duration `env:"ELASTICSEARCH_SLOW_THRESHOLD" default:"1s"`
+}
+
+// DataSource locates the data files in object storage. When DataBucket is
+// set, the destinations, title rules and ingestion rules are read from it
+// under DataPrefix in place of the *_FILE settings, at start and again on
+// POST /admin/reload-data. The worker and the API read it under the same
+// names so they apply the same data.
+type DataSource struct {
+	DataBucket string `env:"WORKER_DATA_BUCKET"`
+	// DataEndpoint points at an S3-compatible service such as MinIO; empty
+	// means Amazon S3 in DataRegion.
+	DataEndpoint        string `env:"WORKER_DATA_ENDPOINT"`
+	DataRegion          string `env:"WORKER_DATA_REGION" default:"us-east-1"`
+	DataPrefix          string `env:"WORKER_DATA_PREFIX" default:"data/"`
+	DataAccessKeyID     string `env:"WORKER_DATA_ACCESS_KEY_ID"`
+	DataSecretAccessKey string `env:"WORKER_DATA_SECRET_ACCESS_KEY"`
+}
+
+func (d DataSource) check(errs *checks) {
+	errs.require(d.DataEndpoint == "" || strings.HasPrefix(d.DataEndpoint, "http://") || strings.HasPrefix(d.DataEndpoint, "https://"),
+		"WORKER_DATA_ENDPOINT must be an http:// or https:// URL")
 }
 
 // Worker holds configuration for the Kafka -> Elasticsearch worker.
 type Worker struct {
 	Common
-	KafkaBrokers     []string
-	KafkaTopic       string
-	KafkaConsumer    string
-	KeywordLimit     int
-	KeywordMinLength int
-	DedupeCapacity   int
-	DedupeTTL        time.Duration
-	BatchSize        int
-	CommitInterval   time.Duration
+	KafkaBrokers     []string `env:"KAFKA_BROKERS" default:"kafka:9092"`
+	KafkaTopic       string   `env:"KAFKA_TOPIC" default:"news_raw"`
+	KafkaTopics      []string `env:"KAFKA_TOPICS"`        // defaults to KafkaTopic
+	TopicFormats     []string `env:"KAFKA_TOPIC_FORMATS"` // topic=format pairs
+	KafkaConsumer    string   `env:"KAFKA_CONSUMER_GROUP" default:"news-worker"`
+	KeywordLimit     int      `env:"WORKER_KEYWORD_LIMIT" default:"8"`
+	KeywordMinLength int      `env:"WORKER_KEYWORD_MIN_LEN" default:"4"`
+	// KeywordTokensPerKeyword scales the keyword limit with the text length
+	// between KeywordMinLimit and KeywordMaxLimit; 0 uses KeywordLimit.
+	KeywordTokensPerKeyword int `env:"WORKER_KEYWORD_TOKENS_PER_KEYWORD" default:"20"`
+	KeywordMinLimit         int `env:"WORKER_KEYWORD_MIN_LIMIT" default:"3"`
+	KeywordMaxLimit         int `env:"WORKER_KEYWORD_MAX_LIMIT" default:"20"`
+	// KeywordStemming stores Russian keywords as their stems; the API must
+	// be configured alike to stem keyword filters.
+	KeywordStemming bool          `env:"KEYWORD_STEMMING" default:"false"`
+	DedupeCapacity  int           `env:"WORKER_DEDUPE_CAPACITY" default:"20000"`
+	DedupeTTL       time.Duration `env:"WORKER_DEDUPE_TTL" default:"24h"`
+	BatchSize       int           `env:"WORKER_BATCH_SIZE" default:"10"`
+	CommitInterval  time.Duration `env:"WORKER_COMMIT_INTERVAL" default:"2s"`
+	Concurrency     int           `env:"WORKER_CONCURRENCY" default:"1"`
+	DrainTimeout    time.Duration `env:"WORKER_DRAIN_TIMEOUT" default:"20s"`
+	Pipeline        []string      `env:"WORKER_PIPELINE" default:"urls,clean,title,keywords,tags,destinations,expiry,tourdates,soldout,price,quality,rules,id,cluster,repost,sourcegroups"`
+	RepostSources   []string      `env:"WORKER_REPOST_SOURCES"`
+	RepostWindow    time.Duration `env:"WORKER_REPOST_WINDOW" default:"24h"`
+	// SourceGroups are name=source|source[@window] sets of sources whose
+	// cross-posted copies collapse into one document attributed to name.
+	SourceGroups      []string      `env:"WORKER_SOURCE_GROUPS"`
+	SourceGroupWindow time.Duration `env:"WORKER_SOURCE_GROUP_WINDOW" default:"1h"`
+	SoldOutWindow     time.Duration `env:"WORKER_SOLDOUT_WINDOW" default:"168h"`
+	// Mode shadow consumes and processes messages like a live worker but
+	// writes documents to ShadowIndex, or only logs them when it is empty,
+	// and leaves every other output alone. It reads under its own consumer
+	// group, KafkaConsumer with a -shadow suffix.
+	Mode        string `env:"WORKER_MODE" default:"live"`
+	ShadowIndex string `env:"WORKER_SHADOW_INDEX"`
+	FanoutMode  string `env:"WORKER_FANOUT_MODE" default:"off"`
+	FanoutTopic string `env:"WORKER_FANOUT_TOPIC" default:"news_indexed"`
+	// EventsTopic receives a DocumentFlagged event for every offer a
+	// sold-out notice expired; empty publishes none.
+	EventsTopic     string `env:"EVENTS_TOPIC"`
+	MaxMessageBytes int    `env:"WORKER_MAX_MESSAGE_BYTES" default:"1048576"`
+	// ErrorExcerptBytes bounds the payload excerpt kept in ingest error records.
+	ErrorExcerptBytes int      `env:"WORKER_ERROR_EXCERPT_BYTES" default:"512"`
+	PIIKinds          []string `env:"WORKER_PII_KINDS" default:"email,phone,card"`
+	DestinationsFile  string   `env:"DESTINATIONS_FILE"`
+	TitleRulesFile    string   `env:"WORKER_TITLE_RULES_FILE"`
+	RulesFile         string   `env:"WORKER_RULES_FILE"`
+	ControlAddr       string   `env:"WORKER_CONTROL_ADDR" default:"0.0.0.0:8081"`
+	ControlToken      string   `env:"WORKER_CONTROL_TOKEN"`
+	DataSource
+	// HealthAddr serves the unauthenticated /livez and /readyz probes;
+	// empty disables them.
+	HealthAddr string `env:"WORKER_HEALTH_ADDR" default:"0.0.0.0:8082"`
+	// ProgressInterval is how often the consumer lag and processing rate
+	// are logged and published; 0 disables the reports.
+	ProgressInterval time.Duration `env:"WORKER_PROGRESS_INTERVAL" default:"1m"`
+	// The consistency audit re-reads AuditSample already committed messages
+	// per partition every AuditInterval, skipping those older than
+	// AuditMaxAge, and checks that their documents exist.
+	AuditMode     string        `env:"WORKER_AUDIT_MODE" default:"off"`
+	AuditInterval time.Duration `env:"WORKER_AUDIT_INTERVAL" default:"1h"`
+	AuditSample   int           `env:"WORKER_AUDIT_SAMPLE" default:"200"`
+	AuditMaxAge   time.Duration `env:"WORKER_AUDIT_MAX_AGE" default:"24h"`
+	// Operator alerts fire while more than AlertDLQRate messages per minute
+	// were dead-lettered over AlertWindow, no document was indexed for
+	// AlertIdle, or the consumer group lags more than AlertLag messages.
+	// A zero threshold disables its rule. Rules are evaluated every
+	// AlertInterval and notifications repeat every AlertRepeat while firing.
+	AlertDLQRate       float64       `env:"WORKER_ALERT_DLQ_RATE"`
+	AlertWindow        time.Duration `env:"WORKER_ALERT_WINDOW" default:"5m"`
+	AlertIdle          time.Duration `env:"WORKER_ALERT_IDLE"`
+	AlertLag           int           `env:"WORKER_ALERT_LAG"`
+	AlertInterval      time.Duration `env:"WORKER_ALERT_INTERVAL" default:"1m"`
+	AlertRepeat        time.Duration `env:"WORKER_ALERT_REPEAT" default:"1h"`
+	AlertTelegramChats []string      `env:"WORKER_ALERT_TELEGRAM_CHATS"`
+	AlertWebhookURL    string        `env:"WORKER_ALERT_WEBHOOK_URL"`
+	TelegramToken      string        `env:"TELEGRAM_BOT_TOKEN"`
 }
 
 // API describes HTTP-layer configuration.
 type API struct {
 	Common
-	BindAddr    string
-	DefaultPage int
-	MaxPage     int
+	BindAddr string `env:"API_BIND_ADDR" default:"0.0.0.0:8080"`
+	// GRPCAddr serves the NewsSearch gRPC service for internal callers;
+	// empty disables it.
+	GRPCAddr    string `env:"API_GRPC_ADDR"`
+	DefaultPage int    `env:"API_PAGE_SIZE" default:"20"`
+	MaxPage     int    `env:"API_MAX_PAGE_SIZE" default:"100"`
+	AdminToken  string `env:"API_ADMIN_TOKEN"`
+	// PartnerKeys are X-API-Key values that receive documents unmasked.
+	PartnerKeys []string `env:"API_PARTNER_KEYS"`
+	// RankSeenWeight and RankClickWeight tune popularity boosting in relevance sort.
+	RankSeenWeight   float64 `env:"API_RANK_SEEN_WEIGHT" default:"1"`
+	RankClickWeight  float64 `env:"API_RANK_CLICK_WEIGHT" default:"1"`
+	DestinationsFile string  `env:"DESTINATIONS_FILE"`
+	ConceptsFile     string  `env:"KEYWORD_CONCEPTS_FILE"`
+	// Experiment names a ranking experiment that gives ExperimentPercent of
+	// clients the alternate popularity weights; empty runs none.
+	Experiment                string  `env:"API_EXPERIMENT"`
+	ExperimentPercent         int     `env:"API_EXPERIMENT_PERCENT" default:"10"`
+	ExperimentRankSeenWeight  float64 `env:"API_EXPERIMENT_RANK_SEEN_WEIGHT" default:"1"`
+	ExperimentRankClickWeight float64 `env:"API_EXPERIMENT_RANK_CLICK_WEIGHT" default:"1"`
+	// DailyAggregates counts the days of /trends and keyword timeline
+	// windows reaching back more than 48 hours from the daily aggregates the
+	// analytics service materializes.
+	DailyAggregates bool `env:"API_DAILY_AGGREGATES" default:"false"`
+	// The worker settings that decide document IDs, read under the same
+	// names so /tools/fingerprint computes the IDs the worker assigns.
+	Pipeline          []string      `env:"WORKER_PIPELINE" default:"urls,clean,title,keywords,tags,destinations,expiry,tourdates,soldout,price,quality,rules,id,cluster,repost,sourcegroups"`
+	RepostSources     []string      `env:"WORKER_REPOST_SOURCES"`
+	RepostWindow      time.Duration `env:"WORKER_REPOST_WINDOW" default:"24h"`
+	SourceGroups      []string      `env:"WORKER_SOURCE_GROUPS"`
+	SourceGroupWindow time.Duration `env:"WORKER_SOURCE_GROUP_WINDOW" default:"1h"`
+	PIIKinds          []string      `env:"WORKER_PII_KINDS" default:"email,phone,card"`
+	TitleRulesFile    string        `env:"WORKER_TITLE_RULES_FILE"`
+	DataSource
+	// KeywordStemming stems keyword filters the way the worker stems
+	// keywords.
+	KeywordStemming bool          `env:"KEYWORD_STEMMING" default:"false"`
+	StatsCacheTTL   time.Duration `env:"API_STATS_CACHE_TTL" default:"1m"`
+	// SearchCacheTTL keeps /news results in memory, up to SearchCacheEntries
+	// of them; 0 disables the cache.
+	SearchCacheTTL     time.Duration `env:"API_SEARCH_CACHE_TTL" default:"5s"`
+	SearchCacheEntries int           `env:"API_SEARCH_CACHE_ENTRIES" default:"1000"`
+	// /news?pit=true pages through point-in-time snapshots. At most
+	// PITMaxOpen are open at once, each kept PITKeepAlive past its last page
+	// and closed PITMaxAge after it was opened; PITMaxOpen 0 disables them.
+	PITMaxOpen   int           `env:"API_PIT_MAX_OPEN" default:"50"`
+	PITKeepAlive time.Duration `env:"API_PIT_KEEP_ALIVE" default:"1m"`
+	PITMaxAge    time.Duration `env:"API_PIT_MAX_AGE" default:"30m"`
+	// CursorTTL is how long a /news?cursor=true page token stays valid.
+	CursorTTL time.Duration `env:"API_CURSOR_TTL" default:"1h"`
+	// ShareSecret signs share links; PublicURL makes them absolute. Links
+	// expire ShareTTL after they were issued; 0 keeps them valid forever.
+	ShareSecret string        `env:"API_SHARE_SECRET"`
+	ShareTTL    time.Duration `env:"API_SHARE_TTL"`
+	PublicURL   string        `env:"API_PUBLIC_URL"`
+	// Low-priority endpoints answer 503 while, over ShedWindow, the p99 of
+	// Elasticsearch calls exceeds ShedP99 or their error rate exceeds
+	// ShedErrorRate. A zero threshold disables that signal.
+	ShedP99       time.Duration `env:"API_SHED_P99" default:"2s"`
+	ShedErrorRate float64       `env:"API_SHED_ERROR_RATE" default:"0.5"`
+	ShedWindow    time.Duration `env:"API_SHED_WINDOW" default:"30s"`
+	// RateLimit is the sustained requests per second allowed per client,
+	// partner key or IP, with bursts of up to RateBurst; 0 disables limiting.
+	RateLimit float64 `env:"API_RATE_LIMIT" default:"0"`
+	RateBurst int     `env:"API_RATE_BURST" default:"20"`
+	// TrustedProxies are the addresses or CIDR prefixes of the proxies
+	// whose X-Forwarded-For and X-Real-IP headers name the client. Other
+	// peers are taken to be the client themselves.
+	TrustedProxies []string `env:"API_TRUSTED_PROXIES"`
+	// CORSOrigins are the origins, such as https://radar.example.com, whose
+	// browser pages may call the API directly; "*" allows any and empty
+	// disables CORS. Preflights allow CORSMethods and CORSHeaders and are
+	// cached for CORSMaxAge; CORSCredentials lets pages send cookies and
+	// HTTP auth.
+	CORSOrigins     []string      `env:"API_CORS_ORIGINS"`
+	CORSMethods     []string      `env:"API_CORS_METHODS" default:"GET,POST,DELETE"`
+	CORSHeaders     []string      `env:"API_CORS_HEADERS" default:"Content-Type,X-API-Key,If-None-Match"`
+	CORSCredentials bool          `env:"API_CORS_CREDENTIALS" default:"false"`
+	CORSMaxAge      time.Duration `env:"API_CORS_MAX_AGE" default:"10m"`
+	// GET /news/stream relays the worker's keyed fan-out topic StreamTopic
+	// from KafkaBrokers; it is disabled while StreamTopic is empty.
+	KafkaBrokers     []string `env:"KAFKA_BROKERS" default:"kafka:9092"`
+	StreamTopic      string   `env:"API_STREAM_TOPIC"`
+	StreamMaxClients int      `env:"API_STREAM_MAX_CLIENTS" default:"200"`
+	// DLQTopics are the dead-letter topics /admin/dlq peeks into and
+	// replays from; a replay goes to the topic without the _dlq suffix.
+	DLQTopics []string `env:"API_DLQ_TOPICS"`
+	// POST /ingest writes manual submissions to IngestTopic, waiting for
+	// IngestAcks (all or one) from KafkaBrokers. Submissions Kafka does not
+	// confirm are kept in IngestSpoolDir and retried every
+	// IngestReplayInterval; without a spool they are refused. The endpoint
+	// is disabled while IngestTopic is empty.
+	IngestTopic          string        `env:"API_INGEST_TOPIC"`
+	IngestAcks           string        `env:"API_INGEST_ACKS" default:"all"`
+	IngestSpoolDir       string        `env:"API_INGEST_SPOOL_DIR"`
+	IngestReplayInterval time.Duration `env:"API_INGEST_REPLAY_INTERVAL" default:"30s"`
 }
 
 // Retention configures the cleanup loop.
 type Retention struct {
 	Common
-	Interval  time.Duration
-	MaxAge    time.Duration
-	BatchSize int
+	Interval  time.Duration `env:"RETENTION_CRON" default:"24h"`
+	MaxAge    time.Duration `env:"RETENTION_MAX_AGE" default:"168h"`
+	BatchSize int           `env:"RETENTION_BATCH_SIZE" default:"500"`
+	// Field is the date MaxAge is measured on: timestamp (publication) or
+	// indexed_at (ingestion), which survives wrongly parsed timestamps.
+	Field string `env:"RETENTION_FIELD" default:"indexed_at"`
+	// ExpiredGrace is how long documents are kept after their expires_at.
+	ExpiredGrace time.Duration `env:"RETENTION_EXPIRED_GRACE" default:"24h"`
+	RunTimeout   time.Duration `env:"RETENTION_RUN_TIMEOUT" default:"30m"`
+	// ArchiveBucket enables exporting documents to S3 before deleting them.
+	ArchiveBucket string `env:"RETENTION_ARCHIVE_BUCKET"`
+	// ArchiveEndpoint points at an S3-compatible service such as MinIO;
+	// empty means Amazon S3 in ArchiveRegion.
+	ArchiveEndpoint        string `env:"RETENTION_ARCHIVE_ENDPOINT"`
+	ArchiveRegion          string `env:"RETENTION_ARCHIVE_REGION" default:"us-east-1"`
+	ArchivePrefix          string `env:"RETENTION_ARCHIVE_PREFIX" default:"archive/"`
+	ArchiveAccessKeyID     string `env:"RETENTION_ARCHIVE_ACCESS_KEY_ID"`
+	ArchiveSecretAccessKey string `env:"RETENTION_ARCHIVE_SECRET_ACCESS_KEY"`
+	ArchiveObjectDocs      int    `env:"RETENTION_ARCHIVE_OBJECT_DOCS" default:"100000"`
+}
+
+// Bot configures the Telegram bot front-end.
+type Bot struct {
+	TelegramToken string        `env:"TELEGRAM_BOT_TOKEN,required"`
+	APIBaseURL    string        `env:"BOT_API_URL" default:"http://api:8080"`
+	APIKey        string        `env:"BOT_API_KEY"` // a partner key, for raw links
+	PollInterval  time.Duration `env:"BOT_POLL_INTERVAL" default:"5m"`
+	StateFile     string        `env:"BOT_STATE_FILE" default:"/var/lib/bot/state.json"`
+	ResultLimit   int           `env:"BOT_RESULT_LIMIT" default:"5"`
+	// SpikeWindow is the period whose document count /spike compares with
+	// the period of the same length before it.
+	SpikeWindow time.Duration `env:"BOT_SPIKE_WINDOW" default:"24h"`
+	// SpikeThreshold is the rise, as in /trends, that notifies chats not
+	// giving their own: 1 means twice as many documents.
+	SpikeThreshold float64 `env:"BOT_SPIKE_THRESHOLD" default:"1"`
+	// SpikeMinCount keeps destinations with a handful of documents from
+	// spiking on every new one.
+	SpikeMinCount int `env:"BOT_SPIKE_MIN_COUNT" default:"5"`
+	// PriceDropThreshold is how far the median price of a /spike
+	// destination must fall from one window to the next to notify the
+	// chat: 0.15 is 15% cheaper. 0 disables price drop messages.
+	PriceDropThreshold float64 `env:"BOT_PRICE_DROP_THRESHOLD" default:"0.15"`
+}
+
+// Backfill configures the one-shot backfill command. What to import is
+// given on its command line.
+type Backfill struct {
+	KafkaBrokers []string `env:"KAFKA_BROKERS" default:"kafka:9092"`
+	KafkaTopic   string   `env:"KAFKA_TOPIC" default:"news_raw"`
+	BatchSize    int      `env:"BACKFILL_BATCH_SIZE" default:"500"`
+}
+
+// TelegramScraper configures the service publishing Telegram channel posts
+// to Kafka. Its bot must not be the one of the bot service, as a bot's
+// updates go to a single consumer.
+type TelegramScraper struct {
+	TelegramToken string   `env:"SCRAPER_TELEGRAM_BOT_TOKEN,required"`
+	Channels      []string `env:"SCRAPER_TELEGRAM_CHANNELS,required"` // public usernames
+	KafkaBrokers  []string `env:"KAFKA_BROKERS" default:"kafka:9092"`
+	KafkaTopic    string   `env:"KAFKA_TOPIC" default:"news_raw"`
+	StateFile     string   `env:"SCRAPER_TELEGRAM_STATE_FILE" default:"/var/lib/scraper-telegram/state.json"`
+	// PollTimeout is how long a getUpdates call waits for new posts.
+	PollTimeout time.Duration `env:"SCRAPER_TELEGRAM_POLL_TIMEOUT" default:"30s"`
+}
+
+// RSSScraper configures the service publishing RSS and Atom feed items to
+// Kafka.
+type RSSScraper struct {
+	Feeds        []string      `env:"SCRAPER_RSS_FEEDS,required"` // [source=]url entries
+	KafkaBrokers []string      `env:"KAFKA_BROKERS" default:"kafka:9092"`
+	KafkaTopic   string        `env:"KAFKA_TOPIC" default:"news_raw"`
+	StateFile    string        `env:"SCRAPER_RSS_STATE_FILE" default:"/var/lib/scraper-rss/state.json"`
+	Interval     time.Duration `env:"SCRAPER_RSS_INTERVAL" default:"10m"`
+	Timeout      time.Duration `env:"SCRAPER_RSS_TIMEOUT" default:"30s"`
+	// MaxAge skips items published longer ago, so adding a feed does not
+	// publish its whole archive; 0 publishes every item.
+	MaxAge time.Duration `env:"SCRAPER_RSS_MAX_AGE" default:"72h"`
 }
 
 // LoadWorker builds a Worker config from environment variables.
 func LoadWorker() (*Worker, error) {
-	c := &Worker{
-		Common: Common{
-			ElasticsearchAddr:  getEnv("ELASTICSEARCH_ADDR", "http://elasticsearch:9200"),
-			ElasticsearchIndex: getEnv("ELASTICSEARCH_INDEX", "news"),
-		},
-		KafkaBrokers:     splitAndTrim(getEnv("KAFKA_BROKERS", "kafka:9092")),
-		KafkaTopic:       getEnv("KAFKA_TOPIC", "news_raw"),
-		KafkaConsumer:    getEnv("KAFKA_CONSUMER_GROUP", "news-worker"),
-		KeywordLimit:     getInt("WORKER_KEYWORD_LIMIT", 8),
-		KeywordMinLength: getInt("WORKER_KEYWORD_MIN_LEN", 4),
-		DedupeCapacity:   getInt("WORKER_DEDUPE_CAPACITY", 20000),
-		DedupeTTL:        getDuration("WORKER_DEDUPE_TTL", "24h"),
-		BatchSize:        getInt("WORKER_BATCH_SIZE", 10),
-		CommitInterval:   getDuration("WORKER_COMMIT_INTERVAL", "2s"),
+	c := &Worker{}
+	errs := load(c)
+	c.Mode = strings.ToLower(c.Mode)
+	c.FanoutMode = strings.ToLower(c.FanoutMode)
+	c.AuditMode = strings.ToLower(c.AuditMode)
+	if c.Mode == "shadow" && !strings.HasSuffix(c.KafkaConsumer, "-shadow") {
+		c.KafkaConsumer += "-shadow"
 	}
-
-	if len(c.KafkaBrokers) == 0 {
-		return nil, fmt.Errorf("KAFKA_BROKERS must contain at least one broker")
+	if len(c.KafkaTopics) == 0 && c.KafkaTopic != "" {
+		c.KafkaTopics = []string{c.KafkaTopic}
 	}
 
-	if c.BatchSize <= 0 {
-		return nil, fmt.Errorf("WORKER_BATCH_SIZE must be positive")
-	}
-	if c.DedupeCapacity <= 0 {
-		return nil, fmt.Errorf("WORKER_DEDUPE_CAPACITY must be positive")
+	errs.require(len(c.KafkaBrokers) > 0, "KAFKA_BROKERS must contain at least one broker")
+	errs.require(len(c.KafkaTopics) > 0, "KAFKA_TOPICS must contain at least one topic")
+	errs.require(c.BatchSize > 0, "WORKER_BATCH_SIZE must be positive")
+	errs.require(c.CommitInterval > 0, "WORKER_COMMIT_INTERVAL must be positive")
+	errs.require(c.Concurrency > 0, "WORKER_CONCURRENCY must be positive")
+	errs.require(c.DrainTimeout > 0, "WORKER_DRAIN_TIMEOUT must be positive")
+	errs.require(c.DedupeCapacity > 0, "WORKER_DEDUPE_CAPACITY must be positive")
+	errs.require(c.KeywordLimit > 0, "WORKER_KEYWORD_LIMIT must be positive")
+	errs.require(c.KeywordMinLength >= 0, "WORKER_KEYWORD_MIN_LEN cannot be negative")
+	errs.require(c.KeywordTokensPerKeyword >= 0, "WORKER_KEYWORD_TOKENS_PER_KEYWORD cannot be negative")
+	if c.KeywordTokensPerKeyword > 0 {
+		errs.require(c.KeywordMinLimit > 0, "WORKER_KEYWORD_MIN_LIMIT must be positive")
+		errs.require(c.KeywordMaxLimit >= c.KeywordMinLimit, "WORKER_KEYWORD_MAX_LIMIT cannot be below WORKER_KEYWORD_MIN_LIMIT")
 	}
-	if c.KeywordLimit <= 0 {
-		return nil, fmt.Errorf("WORKER_KEYWORD_LIMIT must be positive")
+	errs.require(c.MaxMessageBytes > 0, "WORKER_MAX_MESSAGE_BYTES must be positive")
+	errs.require(c.ErrorExcerptBytes >= 0, "WORKER_ERROR_EXCERPT_BYTES cannot be negative")
+	errs.require(c.RepostWindow > 0, "WORKER_REPOST_WINDOW must be positive")
+	errs.require(c.ProgressInterval >= 0, "WORKER_PROGRESS_INTERVAL cannot be negative")
+	errs.require(c.SourceGroupWindow > 0, "WORKER_SOURCE_GROUP_WINDOW must be positive")
+	errs.require(slices.Contains([]string{"live", "shadow"}, c.Mode), "WORKER_MODE must be one of live, shadow")
+	errs.require(c.ShadowIndex == "" || c.ShadowIndex != c.ElasticsearchIndex, "WORKER_SHADOW_INDEX must differ from ELASTICSEARCH_INDEX")
+	errs.require(slices.Contains([]string{"off", "topics", "keyed"}, c.FanoutMode), "WORKER_FANOUT_MODE must be one of off, topics, keyed")
+	errs.require(slices.Contains([]string{"off", "report", "reemit"}, c.AuditMode), "WORKER_AUDIT_MODE must be one of off, report, reemit")
+	if c.AuditMode != "off" {
+		errs.require(c.AuditInterval > 0, "WORKER_AUDIT_INTERVAL must be positive")
+		errs.require(c.AuditSample > 0, "WORKER_AUDIT_SAMPLE must be positive")
+		errs.require(c.AuditMaxAge > 0, "WORKER_AUDIT_MAX_AGE must be positive")
+		// Without the id stage documents get random IDs that cannot be recomputed.
+		errs.require(slices.Contains(c.Pipeline, "id"), "WORKER_AUDIT_MODE requires the id stage in WORKER_PIPELINE")
 	}
-	if c.KeywordMinLength < 0 {
-		return nil, fmt.Errorf("WORKER_KEYWORD_MIN_LEN cannot be negative")
+	c.DataSource.check(&errs)
+	errs.require(c.AlertDLQRate >= 0 && c.AlertIdle >= 0 && c.AlertLag >= 0, "WORKER_ALERT_DLQ_RATE, WORKER_ALERT_IDLE and WORKER_ALERT_LAG cannot be negative")
+	if c.AlertDLQRate > 0 || c.AlertIdle > 0 || c.AlertLag > 0 {
+		errs.require(c.AlertWindow > 0, "WORKER_ALERT_WINDOW must be positive")
+		errs.require(c.AlertInterval > 0, "WORKER_ALERT_INTERVAL must be positive")
+		errs.require(c.AlertRepeat > 0, "WORKER_ALERT_REPEAT must be positive")
+		errs.require(len(c.AlertTelegramChats) > 0 || c.AlertWebhookURL != "", "worker alerts need WORKER_ALERT_TELEGRAM_CHATS or WORKER_ALERT_WEBHOOK_URL")
+		errs.require(len(c.AlertTelegramChats) == 0 || c.TelegramToken != "", "WORKER_ALERT_TELEGRAM_CHATS requires TELEGRAM_BOT_TOKEN")
+		for _, chat := range c.AlertTelegramChats {
+			_, err := strconv.ParseInt(chat, 10, 64)
+			errs.require(err == nil, fmt.Sprintf("WORKER_ALERT_TELEGRAM_CHATS: invalid chat ID %q", chat))
+		}
 	}
 
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
 // LoadAPI builds an API config from environment variables.
 func LoadAPI() (*API, error) {
-	c := &API{
-		Common: Common{
-			ElasticsearchAddr:  getEnv("ELASTICSEARCH_ADDR", "http://elasticsearch:9200"),
-			ElasticsearchIndex: getEnv("ELASTICSEARCH_INDEX", "news"),
-		},
-		BindAddr:    getEnv("API_BIND_ADDR", "0.0.0.0:8080"),
-		DefaultPage: getInt("API_PAGE_SIZE", 20),
-		MaxPage:     getInt("API_MAX_PAGE_SIZE", 100),
-	}
+	c := &API{}
+	errs := load(c)
 
-	if c.DefaultPage <= 0 {
-		return nil, fmt.Errorf("API_PAGE_SIZE must be positive")
+	errs.require(c.DefaultPage > 0, "API_PAGE_SIZE must be positive")
+	errs.require(c.MaxPage > 0, "API_MAX_PAGE_SIZE must be positive")
+	errs.require(c.DefaultPage <= c.MaxPage, "API_PAGE_SIZE cannot exceed API_MAX_PAGE_SIZE")
+	errs.require(c.RankSeenWeight >= 0 && c.RankClickWeight >= 0, "API_RANK_SEEN_WEIGHT and API_RANK_CLICK_WEIGHT cannot be negative")
+	errs.require(c.RepostWindow > 0, "WORKER_REPOST_WINDOW must be positive")
+	errs.require(c.SourceGroupWindow > 0, "WORKER_SOURCE_GROUP_WINDOW must be positive")
+	if c.Experiment != "" {
+		errs.require(c.ExperimentPercent > 0 && c.ExperimentPercent <= 100, "API_EXPERIMENT_PERCENT must be in [1, 100]")
+		errs.require(c.ExperimentRankSeenWeight >= 0 && c.ExperimentRankClickWeight >= 0, "API_EXPERIMENT_RANK_SEEN_WEIGHT and API_EXPERIMENT_RANK_CLICK_WEIGHT cannot be negative")
+	}
+	errs.require(c.StatsCacheTTL > 0, "API_STATS_CACHE_TTL must be positive")
+	errs.require(c.SearchCacheTTL >= 0, "API_SEARCH_CACHE_TTL cannot be negative")
+	errs.require(c.SearchCacheTTL == 0 || c.SearchCacheEntries > 0, "API_SEARCH_CACHE_ENTRIES must be positive when API_SEARCH_CACHE_TTL is set")
+	errs.require(c.PITMaxOpen >= 0, "API_PIT_MAX_OPEN cannot be negative")
+	errs.require(c.PITMaxOpen == 0 || c.PITKeepAlive >= time.Second, "API_PIT_KEEP_ALIVE must be at least 1s when API_PIT_MAX_OPEN is set")
+	errs.require(c.PITMaxOpen == 0 || c.PITMaxAge >= c.PITKeepAlive, "API_PIT_MAX_AGE cannot be shorter than API_PIT_KEEP_ALIVE")
+	errs.require(c.CursorTTL >= time.Minute, "API_CURSOR_TTL must be at least 1m")
+	errs.require(c.ShedP99 >= 0, "API_SHED_P99 cannot be negative")
+	errs.require(c.ShedErrorRate >= 0 && c.ShedErrorRate <= 1, "API_SHED_ERROR_RATE must be in [0, 1]")
+	errs.require(c.ShedWindow > 0, "API_SHED_WINDOW must be positive")
+	errs.require(c.RateLimit >= 0, "API_RATE_LIMIT cannot be negative")
+	errs.require(c.RateLimit == 0 || c.RateBurst > 0, "API_RATE_BURST must be positive when API_RATE_LIMIT is set")
+	for _, proxy := range c.TrustedProxies {
+		_, prefixErr := netip.ParsePrefix(proxy)
+		_, addrErr := netip.ParseAddr(proxy)
+		errs.require(prefixErr == nil || addrErr == nil, fmt.Sprintf("API_TRUSTED_PROXIES: %q is neither an address nor a CIDR prefix", proxy))
 	}
-	if c.MaxPage <= 0 {
-		return nil, fmt.Errorf("API_MAX_PAGE_SIZE must be positive")
+	for _, origin := range c.CORSOrigins {
+		errs.require(origin == "*" || isOrigin(origin), fmt.Sprintf("API_CORS_ORIGINS: %q is not an origin, want scheme://host[:port]", origin))
 	}
-	if c.DefaultPage > c.MaxPage {
-		return nil, fmt.Errorf("API_PAGE_SIZE cannot exceed API_MAX_PAGE_SIZE")
+	errs.require(!c.CORSCredentials || !slices.Contains(c.CORSOrigins, "*"), "API_CORS_CREDENTIALS cannot be combined with the * origin")
+	errs.require(len(c.CORSOrigins) == 0 || len(c.CORSMethods) > 0, "API_CORS_METHODS must not be empty when API_CORS_ORIGINS is set")
+	errs.require(c.CORSMaxAge >= 0, "API_CORS_MAX_AGE cannot be negative")
+	errs.require(c.ShareTTL >= 0, "API_SHARE_TTL cannot be negative")
+	errs.require(c.StreamTopic == "" || len(c.KafkaBrokers) > 0, "KAFKA_BROKERS must contain at least one broker when API_STREAM_TOPIC is set")
+	errs.require(c.StreamMaxClients > 0, "API_STREAM_MAX_CLIENTS must be positive")
+	errs.require(len(c.DLQTopics) == 0 || len(c.KafkaBrokers) > 0, "KAFKA_BROKERS must contain at least one broker when API_DLQ_TOPICS is set")
+	for _, topic := range c.DLQTopics {
+		errs.require(strings.HasSuffix(topic, "_dlq") && topic != "_dlq", fmt.Sprintf("API_DLQ_TOPICS: %q is not a dead-letter topic, want <topic>_dlq", topic))
 	}
+	c.DataSource.check(&errs)
+	c.IngestAcks = strings.ToLower(c.IngestAcks)
+	errs.require(c.IngestTopic == "" || len(c.KafkaBrokers) > 0, "KAFKA_BROKERS must contain at least one broker when API_INGEST_TOPIC is set")
+	errs.require(c.IngestTopic == "" || c.AdminToken != "", "API_ADMIN_TOKEN must be set when API_INGEST_TOPIC is set")
+	errs.require(slices.Contains([]string{"all", "one"}, c.IngestAcks), "API_INGEST_ACKS must be one of all, one")
+	errs.require(c.IngestReplayInterval > 0, "API_INGEST_REPLAY_INTERVAL must be positive")
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Analytics configures the periodic analytics jobs.
+type Analytics struct {
+	Common
+	StopwordInterval   time.Duration `env:"ANALYTICS_STOPWORD_INTERVAL" default:"24h"`
+	StopwordWindow     time.Duration `env:"ANALYTICS_STOPWORD_WINDOW" default:"720h"`
+	StopwordThreshold  float64       `env:"ANALYTICS_STOPWORD_THRESHOLD" default:"0.2"`
+	StopwordMinDocs    int           `env:"ANALYTICS_STOPWORD_MIN_DOCS" default:"100"`
+	StopwordCandidates int           `env:"ANALYTICS_STOPWORD_CANDIDATES" default:"200"`
+	// The daily aggregates job counts the AggregatesTerms most frequent
+	// keywords and destinations of every complete day, recounting the last
+	// AggregatesLookbackDays days and going back AggregatesBackfillDays
+	// days on its first run.
+	AggregatesInterval     time.Duration `env:"ANALYTICS_AGGREGATES_INTERVAL" default:"24h"`
+	AggregatesLookbackDays int           `env:"ANALYTICS_AGGREGATES_LOOKBACK_DAYS" default:"2"`
+	AggregatesBackfillDays int           `env:"ANALYTICS_AGGREGATES_BACKFILL_DAYS" default:"180"`
+	AggregatesTerms        int           `env:"ANALYTICS_AGGREGATES_TERMS" default:"1000"`
+}
+
+// isOrigin reports whether s is an origin as browsers send it: an http or
+// https scheme and a host with an optional port, without a path.
+func isOrigin(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
+
+// LoadAnalytics builds an Analytics config from environment variables.
+func LoadAnalytics() (*Analytics, error) {
+	c := &Analytics{}
+	errs := load(c)
 
+	errs.require(c.StopwordInterval > 0, "ANALYTICS_STOPWORD_INTERVAL must be positive")
+	errs.require(c.StopwordWindow > 0, "ANALYTICS_STOPWORD_WINDOW must be positive")
+	errs.require(c.StopwordThreshold > 0 && c.StopwordThreshold <= 1, "ANALYTICS_STOPWORD_THRESHOLD must be in (0, 1]")
+	errs.require(c.StopwordMinDocs >= 1, "ANALYTICS_STOPWORD_MIN_DOCS must be positive")
+	errs.require(c.StopwordCandidates >= 1, "ANALYTICS_STOPWORD_CANDIDATES must be positive")
+	errs.require(c.AggregatesInterval > 0, "ANALYTICS_AGGREGATES_INTERVAL must be positive")
+	errs.require(c.AggregatesLookbackDays >= 1, "ANALYTICS_AGGREGATES_LOOKBACK_DAYS must be positive")
+	errs.require(c.AggregatesBackfillDays >= c.AggregatesLookbackDays, "ANALYTICS_AGGREGATES_BACKFILL_DAYS cannot be below ANALYTICS_AGGREGATES_LOOKBACK_DAYS")
+	errs.require(c.AggregatesTerms >= 1 && c.AggregatesTerms <= 10_000, "ANALYTICS_AGGREGATES_TERMS must be in [1, 10000]")
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
 // LoadRetention builds a Retention config from environment variables.
 func LoadRetention() (*Retention, error) {
-	c := &Retention{
-		Common: Common{
-			ElasticsearchAddr:  getEnv("ELASTICSEARCH_ADDR", "http://elasticsearch:9200"),
-			ElasticsearchIndex: getEnv("ELASTICSEARCH_INDEX", "news"),
-		},
-		Interval:  getDuration("RETENTION_CRON", "24h"),
-		MaxAge:    getDuration("RETENTION_MAX_AGE", "168h"),
-		BatchSize: getInt("RETENTION_BATCH_SIZE", 500),
-	}
+	c := &Retention{}
+	errs := load(c)
+	c.Field = strings.ToLower(c.Field)
 
-	if c.MaxAge <= 0 {
-		return nil, fmt.Errorf("RETENTION_MAX_AGE must be positive")
-	}
+	errs.require(c.MaxAge > 0, "RETENTION_MAX_AGE must be positive")
+	errs.require(c.Interval > 0, "RETENTION_CRON must be positive")
+	errs.require(c.BatchSize > 0, "RETENTION_BATCH_SIZE must be positive")
+	errs.require(c.RunTimeout > 0, "RETENTION_RUN_TIMEOUT must be positive")
+	errs.require(c.ExpiredGrace >= 0, "RETENTION_EXPIRED_GRACE cannot be negative")
+	errs.require(slices.Contains([]string{"timestamp", "indexed_at"}, c.Field), "RETENTION_FIELD must be one of timestamp, indexed_at")
+	errs.require(!c.ElasticsearchDailyIndices || c.Field == "indexed_at", "RETENTION_FIELD must be indexed_at with ELASTICSEARCH_DAILY_INDICES, which are dropped by indexed_at day")
+	errs.require(c.ArchiveObjectDocs > 0, "RETENTION_ARCHIVE_OBJECT_DOCS must be positive")
+	errs.require(c.ArchiveEndpoint == "" || strings.HasPrefix(c.ArchiveEndpoint, "http://") || strings.HasPrefix(c.ArchiveEndpoint, "https://"),
+		"RETENTION_ARCHIVE_ENDPOINT must be an http:// or https:// URL")
 
-	if c.Interval <= 0 {
-		return nil, fmt.Errorf("RETENTION_CRON must be positive")
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
+	return c, nil
+}
 
-	if c.BatchSize <= 0 {
-		return nil, fmt.Errorf("RETENTION_BATCH_SIZE must be positive")
-	}
+// Alerter configures the saved-search alerting service.
+type Alerter struct {
+	Common
+	KafkaBrokers  []string `env:"KAFKA_BROKERS" default:"kafka:9092"`
+	KafkaTopic    string   `env:"ALERTER_TOPIC" default:"news_indexed"`
+	KafkaConsumer string   `env:"ALERTER_CONSUMER_GROUP" default:"news-alerter"`
+	TelegramToken string   `env:"TELEGRAM_BOT_TOKEN,required"`
+	// RefreshInterval is how often saved searches are reloaded from Elasticsearch.
+	RefreshInterval time.Duration `env:"ALERTER_REFRESH_INTERVAL" default:"1m"`
+	DedupeCapacity  int           `env:"ALERTER_DEDUPE_CAPACITY" default:"20000"`
+	DedupeTTL       time.Duration `env:"ALERTER_DEDUPE_TTL" default:"24h"`
+	// EventsTopic receives an AlertMatched event for every alert sent;
+	// empty publishes none.
+	EventsTopic string `env:"EVENTS_TOPIC"`
+}
+
+// LoadAlerter builds an Alerter config from environment variables.
+func LoadAlerter() (*Alerter, error) {
+	c := &Alerter{}
+	errs := load(c)
+
+	errs.require(len(c.KafkaBrokers) > 0, "KAFKA_BROKERS must contain at least one broker")
+	errs.require(c.RefreshInterval > 0, "ALERTER_REFRESH_INTERVAL must be positive")
+	errs.require(c.DedupeCapacity > 0, "ALERTER_DEDUPE_CAPACITY must be positive")
 
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
-func getEnv(key, fallback string) string {
-	if v, ok := os.LookupEnv(key); ok && v != "" {
-		return v
+// LoadBot builds a Bot config from environment variables.
+func LoadBot() (*Bot, error) {
+	c := &Bot{}
+	errs := load(c)
+	c.APIBaseURL = strings.TrimRight(c.APIBaseURL, "/")
+
+	errs.require(c.PollInterval > 0, "BOT_POLL_INTERVAL must be positive")
+	errs.require(c.ResultLimit > 0, "BOT_RESULT_LIMIT must be positive")
+	errs.require(c.SpikeWindow >= time.Hour, "BOT_SPIKE_WINDOW must be at least 1h")
+	errs.require(c.SpikeThreshold > 0, "BOT_SPIKE_THRESHOLD must be positive")
+	errs.require(c.SpikeMinCount > 0, "BOT_SPIKE_MIN_COUNT must be positive")
+	errs.require(c.PriceDropThreshold >= 0 && c.PriceDropThreshold < 1, "BOT_PRICE_DROP_THRESHOLD must be in [0, 1)")
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
-	return fallback
+	return c, nil
 }
 
-func getInt(key string, fallback int) int {
-	if v, ok := os.LookupEnv(key); ok && v != "" {
-		if parsed, err := strconv.Atoi(v); err == nil {
-			return parsed
-		}
+// LoadBackfill builds a Backfill config from environment variables.
+func LoadBackfill() (*Backfill, error) {
+	c := &Backfill{}
+	errs := load(c)
+
+	errs.require(len(c.KafkaBrokers) > 0, "KAFKA_BROKERS must contain at least one broker")
+	errs.require(c.BatchSize > 0, "BACKFILL_BATCH_SIZE must be positive")
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
-	return fallback
+	return c, nil
 }
 
-func getDuration(key, fallback string) time.Duration {
-	raw := getEnv(key, fallback)
-	d, err := time.ParseDuration(raw)
-	if err != nil {
-		fd, ferr := parseDuration(fallback)
-		if ferr != nil {
-			panic(fmt.Sprintf("invalid fallback duration %q: %v", fallback, ferr))
-		}
-		return fd
+// LoadTelegramScraper builds a TelegramScraper config from environment
+// variables. Channel usernames are read without @ and in lower case.
+func LoadTelegramScraper() (*TelegramScraper, error) {
+	c := &TelegramScraper{}
+	errs := load(c)
+	for i, channel := range c.Channels {
+		c.Channels[i] = strings.ToLower(strings.TrimPrefix(channel, "@"))
 	}
-	return d
-}
 
-func splitAndTrim(raw string) []string {
-	parts := strings.Split(raw, ",")
-	out := make([]string, 0, len(parts))
-	for _, part := range parts {
-		trimmed := strings.TrimSpace(part)
-		if trimmed != "" {
-			out = append(out, trimmed)
-		}
+	errs.require(len(c.KafkaBrokers) > 0, "KAFKA_BROKERS must contain at least one broker")
+	errs.require(c.KafkaTopic != "", "KAFKA_TOPIC is required")
+	errs.require(c.PollTimeout >= time.Second && c.PollTimeout <= 50*time.Second, "SCRAPER_TELEGRAM_POLL_TIMEOUT must be between 1s and 50s")
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
-	return out
+	return c, nil
 }
 
-func parseDuration(raw string) (time.Duration, error) {
-	return time.ParseDuration(raw)
+// LoadRSSScraper builds an RSSScraper config from environment variables.
+func LoadRSSScraper() (*RSSScraper, error) {
+	c := &RSSScraper{}
+	errs := load(c)
+
+	errs.require(len(c.KafkaBrokers) > 0, "KAFKA_BROKERS must contain at least one broker")
+	errs.require(c.KafkaTopic != "", "KAFKA_TOPIC is required")
+	errs.require(c.Interval > 0, "SCRAPER_RSS_INTERVAL must be positive")
+	errs.require(c.Timeout > 0, "SCRAPER_RSS_TIMEOUT must be positive")
+	errs.require(c.MaxAge >= 0, "SCRAPER_RSS_MAX_AGE cannot be negative")
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
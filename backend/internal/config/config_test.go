@@ -1,6 +1,8 @@
 package config_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -23,7 +25,70 @@ func TestLoadWorkerDefaults(t *testing.T) {
 	require.Len(t, cfg.KafkaBrokers, 1)
 	require.Equal(t, "kafka:9092", cfg.KafkaBrokers[0])
 	require.Equal(t, "news_raw", cfg.KafkaTopic)
+	require.Equal(t, []string{"news_raw"}, cfg.KafkaTopics)
 	require.Equal(t, "news-worker", cfg.KafkaConsumer)
+	require.Equal(t, 20*time.Second, cfg.DrainTimeout)
+	require.Equal(t, 1, cfg.Concurrency)
+	require.Equal(t, "0.0.0.0:8082", cfg.HealthAddr)
+	require.Equal(t, time.Minute, cfg.ProgressInterval)
+	require.False(t, cfg.KeywordStemming)
+	require.Equal(t, "live", cfg.Mode)
+	require.Empty(t, cfg.DataBucket)
+	require.Equal(t, "us-east-1", cfg.DataRegion)
+	require.Equal(t, "data/", cfg.DataPrefix)
+}
+
+func TestLoadWorkerDataEndpoint(t *testing.T) {
+	t.Setenv("WORKER_DATA_BUCKET", "radar-data")
+	t.Setenv("WORKER_DATA_ENDPOINT", "http://minio:9000")
+	cfg, err := config.LoadWorker()
+	require.NoError(t, err)
+	require.Equal(t, "radar-data", cfg.DataBucket)
+
+	t.Setenv("WORKER_DATA_ENDPOINT", "minio:9000")
+	_, err = config.LoadWorker()
+	require.ErrorContains(t, err, "WORKER_DATA_ENDPOINT")
+}
+
+func TestLoadAPIDataSource(t *testing.T) {
+	t.Setenv("WORKER_DATA_BUCKET", "radar-data")
+	t.Setenv("WORKER_DATA_ENDPOINT", "http://minio:9000")
+	cfg, err := config.LoadAPI()
+	require.NoError(t, err)
+	require.Equal(t, "radar-data", cfg.DataBucket)
+	require.Equal(t, "data/", cfg.DataPrefix)
+
+	t.Setenv("WORKER_DATA_ENDPOINT", "minio:9000")
+	_, err = config.LoadAPI()
+	require.ErrorContains(t, err, "WORKER_DATA_ENDPOINT")
+}
+
+func TestLoadWorkerShadowMode(t *testing.T) {
+	t.Setenv("WORKER_MODE", "Shadow")
+	t.Setenv("WORKER_SHADOW_INDEX", "news_shadow")
+
+	cfg, err := config.LoadWorker()
+	require.NoError(t, err)
+	require.Equal(t, "shadow", cfg.Mode)
+	require.Equal(t, "news-worker-shadow", cfg.KafkaConsumer)
+
+	t.Setenv("WORKER_SHADOW_INDEX", "news")
+	_, err = config.LoadWorker()
+	require.ErrorContains(t, err, "WORKER_SHADOW_INDEX")
+
+	t.Setenv("WORKER_MODE", "dry")
+	_, err = config.LoadWorker()
+	require.ErrorContains(t, err, "WORKER_MODE")
+}
+
+func TestLoadWorkerTopics(t *testing.T) {
+	t.Setenv("KAFKA_TOPICS", "news_raw, rss_items,vk_posts")
+	t.Setenv("KAFKA_TOPIC_FORMATS", "rss_items=rss,vk_posts=vk")
+
+	cfg, err := config.LoadWorker()
+	require.NoError(t, err)
+	require.Equal(t, []string{"news_raw", "rss_items", "vk_posts"}, cfg.KafkaTopics)
+	require.Equal(t, []string{"rss_items=rss", "vk_posts=vk"}, cfg.TopicFormats)
 }
 
 func TestLoadWorkerOverrides(t *testing.T) {
@@ -38,6 +103,8 @@ func TestLoadWorkerOverrides(t *testing.T) {
 	t.Setenv("WORKER_DEDUPE_TTL", "48h")
 	t.Setenv("WORKER_BATCH_SIZE", "3")
 	t.Setenv("WORKER_COMMIT_INTERVAL", "5s")
+	t.Setenv("WORKER_CONCURRENCY", "6")
+	t.Setenv("KEYWORD_STEMMING", "true")
 
 	cfg, err := config.LoadWorker()
 	require.NoError(t, err)
@@ -50,10 +117,24 @@ func TestLoadWorkerOverrides(t *testing.T) {
 	require.Equal(t, "custom-group", cfg.KafkaConsumer)
 	require.Equal(t, 12, cfg.KeywordLimit)
 	require.Equal(t, 5, cfg.KeywordMinLength)
+	require.Equal(t, 20, cfg.KeywordTokensPerKeyword)
+	require.Equal(t, 3, cfg.KeywordMinLimit)
+	require.Equal(t, 20, cfg.KeywordMaxLimit)
 	require.Equal(t, 5, cfg.DedupeCapacity)
 	require.Equal(t, 48*time.Hour, cfg.DedupeTTL)
 	require.Equal(t, 3, cfg.BatchSize)
 	require.Equal(t, 5*time.Second, cfg.CommitInterval)
+	require.Equal(t, 6, cfg.Concurrency)
+	require.True(t, cfg.KeywordStemming)
+
+	t.Setenv("WORKER_KEYWORD_MAX_LIMIT", "2")
+	_, err = config.LoadWorker()
+	require.ErrorContains(t, err, "WORKER_KEYWORD_MAX_LIMIT")
+
+	t.Setenv("WORKER_KEYWORD_MAX_LIMIT", "")
+	t.Setenv("KEYWORD_STEMMING", "yes")
+	_, err = config.LoadWorker()
+	require.ErrorContains(t, err, "KEYWORD_STEMMING")
 }
 
 func TestLoadAPI(t *testing.T) {
@@ -62,6 +143,13 @@ func TestLoadAPI(t *testing.T) {
 	t.Setenv("API_MAX_PAGE_SIZE", "200")
 	t.Setenv("ELASTICSEARCH_ADDR", "http://api-es:9200")
 	t.Setenv("ELASTICSEARCH_INDEX", "api-index")
+	t.Setenv("API_ADMIN_TOKEN", "secret")
+	t.Setenv("API_RANK_SEEN_WEIGHT", "0.5")
+	t.Setenv("API_RANK_CLICK_WEIGHT", "0")
+	t.Setenv("ELASTICSEARCH_AWS_REGION", "eu-central-1")
+	t.Setenv("API_SHARE_SECRET", "share-key")
+	t.Setenv("API_SHARE_TTL", "720h")
+	t.Setenv("API_PARTNER_KEYS", "partner-a, partner-b")
 
 	cfg, err := config.LoadAPI()
 	require.NoError(t, err)
@@ -70,6 +158,114 @@ func TestLoadAPI(t *testing.T) {
 	require.Equal(t, 200, cfg.MaxPage)
 	require.Equal(t, "http://api-es:9200", cfg.ElasticsearchAddr)
 	require.Equal(t, "api-index", cfg.ElasticsearchIndex)
+	require.Equal(t, "secret", cfg.AdminToken)
+	require.Equal(t, 0.5, cfg.RankSeenWeight)
+	require.Equal(t, 0.0, cfg.RankClickWeight)
+	require.Equal(t, "eu-central-1", cfg.ElasticsearchAWSRegion)
+	require.Equal(t, "es", cfg.ElasticsearchAWSService)
+	require.Equal(t, time.Second, cfg.ElasticsearchSlowThreshold)
+	require.Equal(t, "share-key", cfg.ShareSecret)
+	require.Equal(t, 720*time.Hour, cfg.ShareTTL)
+	require.Equal(t, []string{"partner-a", "partner-b"}, cfg.PartnerKeys)
+	require.Empty(t, cfg.PublicURL)
+	require.Equal(t, 2*time.Second, cfg.ShedP99)
+	require.False(t, cfg.DailyAggregates)
+	require.Contains(t, cfg.Pipeline, "id")
+	require.Equal(t, 24*time.Hour, cfg.RepostWindow)
+	require.Empty(t, cfg.DLQTopics)
+	require.Equal(t, 0.5, cfg.ShedErrorRate)
+	require.Empty(t, cfg.StreamTopic)
+	require.Equal(t, 200, cfg.StreamMaxClients)
+	require.Zero(t, cfg.RateLimit)
+	require.Equal(t, 20, cfg.RateBurst)
+	require.Empty(t, cfg.CORSOrigins)
+	require.Equal(t, []string{"GET", "POST", "DELETE"}, cfg.CORSMethods)
+	require.Equal(t, []string{"Content-Type", "X-API-Key", "If-None-Match"}, cfg.CORSHeaders)
+	require.False(t, cfg.CORSCredentials)
+	require.Equal(t, 10*time.Minute, cfg.CORSMaxAge)
+	require.Equal(t, 5*time.Second, cfg.SearchCacheTTL)
+	require.Equal(t, 1000, cfg.SearchCacheEntries)
+	require.Equal(t, 50, cfg.PITMaxOpen)
+	require.Equal(t, time.Minute, cfg.PITKeepAlive)
+	require.Equal(t, 30*time.Minute, cfg.PITMaxAge)
+	require.Equal(t, time.Hour, cfg.CursorTTL)
+	require.Empty(t, cfg.GRPCAddr)
+	require.Empty(t, cfg.Experiment)
+	require.Equal(t, 10, cfg.ExperimentPercent)
+	require.Empty(t, cfg.IngestTopic)
+	require.Equal(t, "all", cfg.IngestAcks)
+	require.Empty(t, cfg.IngestSpoolDir)
+	require.Equal(t, 30*time.Second, cfg.IngestReplayInterval)
+
+	t.Setenv("API_INGEST_TOPIC", "news_raw")
+	t.Setenv("API_INGEST_ACKS", "One")
+	t.Setenv("API_INGEST_SPOOL_DIR", "/var/lib/api/spool")
+	cfg, err = config.LoadAPI()
+	require.NoError(t, err)
+	require.Equal(t, "one", cfg.IngestAcks)
+	require.Equal(t, "/var/lib/api/spool", cfg.IngestSpoolDir)
+
+	t.Setenv("API_INGEST_ACKS", "none")
+	_, err = config.LoadAPI()
+	require.ErrorContains(t, err, "API_INGEST_ACKS must be one of all, one")
+
+	t.Setenv("API_INGEST_ACKS", "")
+	t.Setenv("API_ADMIN_TOKEN", "")
+	_, err = config.LoadAPI()
+	require.ErrorContains(t, err, "API_ADMIN_TOKEN must be set when API_INGEST_TOPIC is set")
+
+	t.Setenv("API_ADMIN_TOKEN", "secret")
+	t.Setenv("API_INGEST_TOPIC", "")
+	t.Setenv("API_SHED_ERROR_RATE", "1.5")
+	_, err = config.LoadAPI()
+	require.ErrorContains(t, err, "API_SHED_ERROR_RATE")
+
+	t.Setenv("API_SHED_ERROR_RATE", "0.5")
+	t.Setenv("API_RATE_LIMIT", "5")
+	t.Setenv("API_RATE_BURST", "0")
+	_, err = config.LoadAPI()
+	require.ErrorContains(t, err, "API_RATE_BURST")
+
+	t.Setenv("API_RATE_LIMIT", "0")
+	t.Setenv("API_EXPERIMENT", "clicks-x2")
+	t.Setenv("API_EXPERIMENT_PERCENT", "0")
+	_, err = config.LoadAPI()
+	require.ErrorContains(t, err, "API_EXPERIMENT_PERCENT")
+
+	t.Setenv("API_EXPERIMENT_PERCENT", "25")
+	t.Setenv("API_EXPERIMENT_RANK_CLICK_WEIGHT", "2")
+	cfg, err = config.LoadAPI()
+	require.NoError(t, err)
+	require.Equal(t, "clicks-x2", cfg.Experiment)
+	require.Equal(t, 25, cfg.ExperimentPercent)
+	require.Equal(t, 1.0, cfg.ExperimentRankSeenWeight)
+	require.Equal(t, 2.0, cfg.ExperimentRankClickWeight)
+
+	t.Setenv("KAFKA_BROKERS", "kafka:9092")
+	t.Setenv("API_DLQ_TOPICS", "news_raw_dlq,news_raw")
+	_, err = config.LoadAPI()
+	require.ErrorContains(t, err, `API_DLQ_TOPICS: "news_raw" is not a dead-letter topic`)
+
+	t.Setenv("API_DLQ_TOPICS", "")
+	t.Setenv("API_CORS_ORIGINS", "https://radar.example.com, https://radar.example.com/app")
+	_, err = config.LoadAPI()
+	require.ErrorContains(t, err, `API_CORS_ORIGINS: "https://radar.example.com/app" is not an origin`)
+
+	t.Setenv("API_CORS_ORIGINS", "*")
+	t.Setenv("API_CORS_CREDENTIALS", "true")
+	_, err = config.LoadAPI()
+	require.ErrorContains(t, err, "API_CORS_CREDENTIALS")
+
+	t.Setenv("API_CORS_ORIGINS", "")
+	t.Setenv("API_CORS_CREDENTIALS", "false")
+	t.Setenv("API_TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.10")
+	cfg, err = config.LoadAPI()
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.0/8", "192.168.1.10"}, cfg.TrustedProxies)
+
+	t.Setenv("API_TRUSTED_PROXIES", "10.0.0.0/8,proxy.local")
+	_, err = config.LoadAPI()
+	require.ErrorContains(t, err, `API_TRUSTED_PROXIES: "proxy.local" is neither an address nor a CIDR prefix`)
 }
 
 func TestLoadRetention(t *testing.T) {
@@ -85,6 +281,220 @@ func TestLoadRetention(t *testing.T) {
 	require.Equal(t, 12*time.Hour, cfg.Interval)
 	require.Equal(t, 36*time.Hour, cfg.MaxAge)
 	require.Equal(t, 123, cfg.BatchSize)
+	require.Equal(t, 24*time.Hour, cfg.ExpiredGrace)
+	require.Equal(t, 30*time.Minute, cfg.RunTimeout)
 	require.Equal(t, "http://ret-es:9200", cfg.ElasticsearchAddr)
 	require.Equal(t, "ret-index", cfg.ElasticsearchIndex)
-}
\ No newline at end of file
+	require.Equal(t, "indexed_at", cfg.Field)
+	require.Empty(t, cfg.ArchiveBucket)
+	require.Equal(t, "archive/", cfg.ArchivePrefix)
+	require.Equal(t, 100000, cfg.ArchiveObjectDocs)
+	require.False(t, cfg.ElasticsearchDailyIndices)
+
+	t.Setenv("RETENTION_FIELD", "Timestamp")
+	cfg, err = config.LoadRetention()
+	require.NoError(t, err)
+	require.Equal(t, "timestamp", cfg.Field)
+
+	t.Setenv("RETENTION_FIELD", "expires_at")
+	_, err = config.LoadRetention()
+	require.ErrorContains(t, err, "RETENTION_FIELD")
+
+	t.Setenv("RETENTION_FIELD", "timestamp")
+	t.Setenv("RETENTION_ARCHIVE_ENDPOINT", "minio:9000")
+	_, err = config.LoadRetention()
+	require.ErrorContains(t, err, "RETENTION_ARCHIVE_ENDPOINT")
+
+	t.Setenv("RETENTION_ARCHIVE_ENDPOINT", "")
+	t.Setenv("ELASTICSEARCH_DAILY_INDICES", "true")
+	_, err = config.LoadRetention()
+	require.ErrorContains(t, err, "RETENTION_FIELD must be indexed_at with ELASTICSEARCH_DAILY_INDICES")
+
+	t.Setenv("RETENTION_FIELD", "")
+	cfg, err = config.LoadRetention()
+	require.NoError(t, err)
+	require.True(t, cfg.ElasticsearchDailyIndices)
+	require.Equal(t, "indexed_at", cfg.Field)
+}
+
+func TestLoadAnalytics(t *testing.T) {
+	t.Setenv("ANALYTICS_STOPWORD_WINDOW", "168h")
+	t.Setenv("ANALYTICS_STOPWORD_THRESHOLD", "0.35")
+
+	cfg, err := config.LoadAnalytics()
+	require.NoError(t, err)
+
+	require.Equal(t, 24*time.Hour, cfg.StopwordInterval)
+	require.Equal(t, 168*time.Hour, cfg.StopwordWindow)
+	require.Equal(t, 0.35, cfg.StopwordThreshold)
+	require.Equal(t, 100, cfg.StopwordMinDocs)
+	require.Equal(t, 24*time.Hour, cfg.AggregatesInterval)
+	require.Equal(t, 2, cfg.AggregatesLookbackDays)
+	require.Equal(t, 180, cfg.AggregatesBackfillDays)
+	require.Equal(t, 1000, cfg.AggregatesTerms)
+
+	t.Setenv("ANALYTICS_STOPWORD_THRESHOLD", "1.5")
+	_, err = config.LoadAnalytics()
+	require.Error(t, err)
+
+	t.Setenv("ANALYTICS_STOPWORD_THRESHOLD", "0.35")
+	t.Setenv("ANALYTICS_AGGREGATES_BACKFILL_DAYS", "1")
+	_, err = config.LoadAnalytics()
+	require.ErrorContains(t, err, "ANALYTICS_AGGREGATES_BACKFILL_DAYS")
+}
+
+func TestLoadAlerter(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "")
+	_, err := config.LoadAlerter()
+	require.Error(t, err)
+
+	t.Setenv("TELEGRAM_BOT_TOKEN", "secret")
+	t.Setenv("ALERTER_REFRESH_INTERVAL", "30s")
+
+	cfg, err := config.LoadAlerter()
+	require.NoError(t, err)
+
+	require.Equal(t, "news_indexed", cfg.KafkaTopic)
+	require.Equal(t, "news-alerter", cfg.KafkaConsumer)
+	require.Equal(t, 30*time.Second, cfg.RefreshInterval)
+
+	t.Setenv("ALERTER_REFRESH_INTERVAL", "0s")
+	_, err = config.LoadAlerter()
+	require.Error(t, err)
+}
+
+func TestLoadBackfill(t *testing.T) {
+	cfg, err := config.LoadBackfill()
+	require.NoError(t, err)
+	require.Equal(t, []string{"kafka:9092"}, cfg.KafkaBrokers)
+	require.Equal(t, "news_raw", cfg.KafkaTopic)
+	require.Equal(t, 500, cfg.BatchSize)
+
+	t.Setenv("BACKFILL_BATCH_SIZE", "0")
+	_, err = config.LoadBackfill()
+	require.ErrorContains(t, err, "BACKFILL_BATCH_SIZE must be positive")
+}
+
+func TestLoadTelegramScraper(t *testing.T) {
+	_, err := config.LoadTelegramScraper()
+	require.ErrorContains(t, err, "SCRAPER_TELEGRAM_BOT_TOKEN")
+
+	t.Setenv("SCRAPER_TELEGRAM_BOT_TOKEN", "token")
+	t.Setenv("SCRAPER_TELEGRAM_CHANNELS", "@HotTours, turs_deshevo")
+	cfg, err := config.LoadTelegramScraper()
+	require.NoError(t, err)
+	require.Equal(t, []string{"hottours", "turs_deshevo"}, cfg.Channels)
+	require.Equal(t, "news_raw", cfg.KafkaTopic)
+	require.Equal(t, "/var/lib/scraper-telegram/state.json", cfg.StateFile)
+	require.Equal(t, 30*time.Second, cfg.PollTimeout)
+
+	t.Setenv("SCRAPER_TELEGRAM_POLL_TIMEOUT", "2m")
+	_, err = config.LoadTelegramScraper()
+	require.ErrorContains(t, err, "SCRAPER_TELEGRAM_POLL_TIMEOUT must be between 1s and 50s")
+}
+
+func TestLoadRSSScraper(t *testing.T) {
+	_, err := config.LoadRSSScraper()
+	require.ErrorContains(t, err, "SCRAPER_RSS_FEEDS is required")
+
+	t.Setenv("SCRAPER_RSS_FEEDS", "travelata=https://travelata.ru/rss, https://example.com/atom.xml")
+	cfg, err := config.LoadRSSScraper()
+	require.NoError(t, err)
+	require.Equal(t, []string{"travelata=https://travelata.ru/rss", "https://example.com/atom.xml"}, cfg.Feeds)
+	require.Equal(t, 10*time.Minute, cfg.Interval)
+	require.Equal(t, 72*time.Hour, cfg.MaxAge)
+	require.Equal(t, "/var/lib/scraper-rss/state.json", cfg.StateFile)
+
+	t.Setenv("SCRAPER_RSS_INTERVAL", "0s")
+	_, err = config.LoadRSSScraper()
+	require.ErrorContains(t, err, "SCRAPER_RSS_INTERVAL must be positive")
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	t.Setenv("WORKER_BATCH_SIZE", "ten")
+	t.Setenv("WORKER_DEDUPE_TTL", "1day")
+	_, err := config.LoadWorker()
+	require.ErrorContains(t, err, `WORKER_BATCH_SIZE: invalid integer "ten"`)
+	require.ErrorContains(t, err, `WORKER_DEDUPE_TTL: invalid duration "1day"`)
+
+	t.Setenv("WORKER_BATCH_SIZE", "0")
+	t.Setenv("WORKER_DEDUPE_TTL", "")
+	t.Setenv("WORKER_FANOUT_MODE", "broadcast")
+	_, err = config.LoadWorker()
+	require.ErrorContains(t, err, "WORKER_BATCH_SIZE must be positive")
+	require.ErrorContains(t, err, "WORKER_FANOUT_MODE must be one of off, topics, keyed")
+
+	t.Setenv("WORKER_BATCH_SIZE", "ten")
+	t.Setenv("WORKER_FANOUT_MODE", "")
+	t.Setenv("WORKER_CONCURRENCY", "0")
+	_, err = config.LoadWorker()
+	require.ErrorContains(t, err, `WORKER_BATCH_SIZE: invalid integer "ten"`)
+	require.ErrorContains(t, err, "WORKER_CONCURRENCY must be positive")
+	require.NotContains(t, err.Error(), "WORKER_BATCH_SIZE must be positive")
+}
+
+func TestLoadWorkerAudit(t *testing.T) {
+	cfg, err := config.LoadWorker()
+	require.NoError(t, err)
+	require.Equal(t, "off", cfg.AuditMode)
+	require.Equal(t, time.Hour, cfg.AuditInterval)
+	require.Equal(t, 200, cfg.AuditSample)
+
+	t.Setenv("WORKER_AUDIT_MODE", "Reemit")
+	t.Setenv("WORKER_AUDIT_SAMPLE", "50")
+	cfg, err = config.LoadWorker()
+	require.NoError(t, err)
+	require.Equal(t, "reemit", cfg.AuditMode)
+	require.Equal(t, 50, cfg.AuditSample)
+
+	t.Setenv("WORKER_AUDIT_SAMPLE", "0")
+	t.Setenv("WORKER_PIPELINE", "urls,clean,keywords")
+	_, err = config.LoadWorker()
+	require.ErrorContains(t, err, "WORKER_AUDIT_SAMPLE must be positive")
+	require.ErrorContains(t, err, "requires the id stage")
+
+	t.Setenv("WORKER_AUDIT_MODE", "fix")
+	_, err = config.LoadWorker()
+	require.ErrorContains(t, err, "WORKER_AUDIT_MODE must be one of off, report, reemit")
+}
+
+func TestLoadWorkerAlerts(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "")
+	t.Setenv("WORKER_ALERT_LAG", "1000")
+	_, err := config.LoadWorker()
+	require.ErrorContains(t, err, "worker alerts need WORKER_ALERT_TELEGRAM_CHATS or WORKER_ALERT_WEBHOOK_URL")
+
+	t.Setenv("WORKER_ALERT_TELEGRAM_CHATS", "-1001234,ops")
+	_, err = config.LoadWorker()
+	require.ErrorContains(t, err, "WORKER_ALERT_TELEGRAM_CHATS requires TELEGRAM_BOT_TOKEN")
+	require.ErrorContains(t, err, `invalid chat ID "ops"`)
+
+	t.Setenv("TELEGRAM_BOT_TOKEN", "token")
+	t.Setenv("WORKER_ALERT_TELEGRAM_CHATS", "-1001234")
+	t.Setenv("WORKER_ALERT_IDLE", "30m")
+	cfg, err := config.LoadWorker()
+	require.NoError(t, err)
+	require.Equal(t, 1000, cfg.AlertLag)
+	require.Equal(t, 30*time.Minute, cfg.AlertIdle)
+	require.Equal(t, time.Hour, cfg.AlertRepeat)
+}
+
+func TestLoadSecretFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0o600))
+
+	t.Setenv("TELEGRAM_BOT_TOKEN", "")
+	t.Setenv("TELEGRAM_BOT_TOKEN_FILE", path)
+	cfg, err := config.LoadBot()
+	require.NoError(t, err)
+	require.Equal(t, "from-file", cfg.TelegramToken)
+
+	t.Setenv("TELEGRAM_BOT_TOKEN", "inline")
+	_, err = config.LoadBot()
+	require.ErrorContains(t, err, "set only one of TELEGRAM_BOT_TOKEN and TELEGRAM_BOT_TOKEN_FILE")
+
+	t.Setenv("TELEGRAM_BOT_TOKEN", "")
+	t.Setenv("TELEGRAM_BOT_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+	_, err = config.LoadBot()
+	require.ErrorContains(t, err, "TELEGRAM_BOT_TOKEN_FILE")
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Struct fields are bound to environment variables with tags:
+//
+//	BatchSize int `env:"WORKER_BATCH_SIZE" default:"10"`
+//	Token     string `env:"TELEGRAM_BOT_TOKEN,required"`
+//
+// An empty variable counts as unset. Every variable can instead be read
+// from a file named by <NAME>_FILE, the convention of Docker and Kubernetes
+// secrets; a single trailing newline is dropped. Embedded structs are
+// loaded recursively. Supported field types are string, int, float64,
+// time.Duration and []string (comma-separated).
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// load fills the tagged fields of the struct dst points to and returns
+// every variable that is missing or malformed, so the caller can append
+// its range checks and report all problems at once. A malformed field
+// keeps its default, which stops it from also failing those checks.
+func load(dst any) checks {
+	var errs checks
+	loadStruct(reflect.ValueOf(dst).Elem(), &errs)
+	return errs
+}
+
+func loadStruct(v reflect.Value, errs *checks) {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			loadStruct(v.Field(i), errs)
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		required := opts == "required"
+
+		raw, err := lookup(name)
+		if err != nil {
+			*errs = append(*errs, err)
+			continue
+		}
+		if raw == "" {
+			if required {
+				*errs = append(*errs, fmt.Errorf("%s is required", name))
+				continue
+			}
+			raw = field.Tag.Get("default")
+		}
+
+		if err := setField(v.Field(i), raw); err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", name, err))
+			_ = setField(v.Field(i), field.Tag.Get("default"))
+		}
+	}
+}
+
+// lookup returns the value of name, or the contents of the file named by
+// name_FILE when that is set instead.
+func lookup(name string) (string, error) {
+	value := os.Getenv(name)
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("set only one of %s and %s_FILE", name, name)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE: %w", name, err)
+	}
+	content := strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(content, "\r"), nil
+}
+
+func setField(f reflect.Value, raw string) error {
+	if f.Type() == durationType {
+		if raw == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Int:
+		if raw == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		f.SetInt(int64(n))
+	case reflect.Bool:
+		if raw == "" {
+			return nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		f.SetBool(b)
+	case reflect.Float64:
+		if raw == "" {
+			return nil
+		}
+		x, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		f.SetFloat(x)
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported field type %s", f.Type())
+		}
+		f.Set(reflect.ValueOf(splitAndTrim(raw)))
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+	return nil
+}
+
+func splitAndTrim(raw string) []string {
+	parts := strings.Split(raw, ",")
+	out := make([]string, 0, len(parts))
+	for _, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		if trimmed != "" {
+			out = append(out, trimmed)
+		}
+	}
+	return out
+}
+
+// checks collects validation failures so a config reports all of them.
+type checks []error
+
+// require records the message when ok is false.
+func (c *checks) require(ok bool, msg string) {
+	if !ok {
+		*c = append(*c, errors.New(msg))
+	}
+}
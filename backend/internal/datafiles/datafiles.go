@@ -0,0 +1,146 @@
+// Package datafiles loads the data files the worker and the API share,
+// such as the destination taxonomy and the title rules, from S3 or MinIO.
+// Each object has a checksum object of the same name with a .sha256
+// suffix, holding the hex SHA-256 of its content as sha256sum prints it,
+// and is only handed on once it matches.
+package datafiles
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/archive"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/awsauth"
+)
+
+// The data objects, read under the configured prefix.
+const (
+	Destinations = "destinations.json"
+	TitleRules   = "title_rules.json"
+	Rules        = "rules.yaml"
+)
+
+// ErrInvalid marks data that was fetched but does not match its checksum
+// or does not parse.
+var ErrInvalid = errors.New("invalid data")
+
+// Getter is the part of archive.S3 a Loader reads with.
+type Getter interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// Object describes one data object of a load.
+type Object struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256,omitempty"`
+	Bytes  int    `json:"bytes"`
+	// Missing is set when the bucket has no such object; the built-in
+	// data, or none, apply instead.
+	Missing bool `json:"missing,omitempty"`
+}
+
+// Result is the outcome of a load.
+type Result struct {
+	// Changed is false when every object matched the loaded ones, so
+	// nothing was applied.
+	Changed  bool      `json:"changed"`
+	Objects  []Object  `json:"objects"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// NewStore opens bucket with the given credentials or, when accessKeyID is
+// empty, the standard AWS ones from the environment or the instance role.
+func NewStore(endpoint, region, bucket, accessKeyID, secretAccessKey string) (*archive.S3, error) {
+	return archive.NewS3(endpoint, region, bucket, awsauth.NewSource(accessKeyID, secretAccessKey))
+}
+
+// Loader fetches a fixed set of objects and applies them as a whole.
+// Loads are serialized, so the last applied objects are always known.
+type Loader struct {
+	store  Getter
+	prefix string
+	names  []string
+
+	mu       sync.Mutex
+	applied  bool
+	loaded   []Object
+	loadedAt time.Time
+}
+
+// NewLoader loads the objects names from store under prefix.
+func NewLoader(store Getter, prefix string, names ...string) *Loader {
+	return &Loader{store: store, prefix: prefix, names: names}
+}
+
+// Load fetches and verifies every object, then passes their bodies, nil
+// for a missing object, to apply unless they all match the last applied
+// ones. apply should wrap parse failures in ErrInvalid; when it fails,
+// the previous objects stay the loaded ones.
+func (l *Loader) Load(ctx context.Context, apply func(bodies map[string][]byte) error) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	objects := make([]Object, 0, len(l.names))
+	bodies := make(map[string][]byte, len(l.names))
+	for _, name := range l.names {
+		body, object, err := l.fetch(ctx, name)
+		if err != nil {
+			return Result{}, err
+		}
+		objects = append(objects, object)
+		bodies[name] = body
+	}
+	if l.applied && slices.Equal(objects, l.loaded) {
+		return Result{Objects: objects, LoadedAt: l.loadedAt}, nil
+	}
+
+	if err := apply(bodies); err != nil {
+		return Result{}, err
+	}
+	l.applied = true
+	l.loaded = objects
+	l.loadedAt = time.Now().UTC()
+	return Result{Changed: true, Objects: objects, LoadedAt: l.loadedAt}, nil
+}
+
+// fetch downloads the object name and checks it against its checksum. A
+// missing object yields a nil body.
+func (l *Loader) fetch(ctx context.Context, name string) ([]byte, Object, error) {
+	object := Object{Name: name}
+	body, err := l.store.Get(ctx, l.prefix+name)
+	if errors.Is(err, archive.ErrNotFound) {
+		object.Missing = true
+		return nil, object, nil
+	}
+	if err != nil {
+		return nil, object, err
+	}
+
+	checksum, err := l.store.Get(ctx, l.prefix+name+".sha256")
+	if errors.Is(err, archive.ErrNotFound) {
+		return nil, object, fmt.Errorf("%w: %s has no %s.sha256 checksum", ErrInvalid, name, name)
+	}
+	if err != nil {
+		return nil, object, err
+	}
+	// sha256sum prints the file name after the hash.
+	want, _, _ := strings.Cut(strings.TrimSpace(string(checksum)), " ")
+	sum := sha256.Sum256(body)
+	got := hex.EncodeToString(sum[:])
+	if !strings.EqualFold(want, got) {
+		// The object may have been replaced between the two downloads;
+		// another load picks up the pair once both are in place.
+		return nil, object, fmt.Errorf("%w: %s has SHA-256 %s, its checksum says %s", ErrInvalid, name, got, want)
+	}
+
+	object.SHA256 = got
+	object.Bytes = len(body)
+	return body, object, nil
+}
@@ -0,0 +1,71 @@
+package datafiles
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/archive"
+)
+
+type mapStore map[string]string
+
+func (m mapStore) Get(_ context.Context, key string) ([]byte, error) {
+	body, ok := m[key]
+	if !ok {
+		return nil, archive.ErrNotFound
+	}
+	return []byte(body), nil
+}
+
+func TestLoad(t *testing.T) {
+	store := mapStore{
+		"data/a.json":        "[]",
+		"data/a.json.sha256": "4F53CDA18C2BAA0C0354BB5F9A3ECBE5ED12AB4D8E11BA873C2F11161202B945  a.json\n",
+	}
+	loader := NewLoader(store, "data/", "a.json", "b.json")
+
+	var applied map[string][]byte
+	apply := func(bodies map[string][]byte) error {
+		applied = bodies
+		return nil
+	}
+	res, err := loader.Load(context.Background(), apply)
+	require.NoError(t, err)
+	require.True(t, res.Changed)
+	require.Equal(t, []Object{
+		{Name: "a.json", SHA256: "4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945", Bytes: 2},
+		{Name: "b.json", Missing: true},
+	}, res.Objects)
+	require.Equal(t, map[string][]byte{"a.json": []byte("[]"), "b.json": nil}, applied)
+
+	applied = nil
+	again, err := loader.Load(context.Background(), apply)
+	require.NoError(t, err)
+	require.False(t, again.Changed)
+	require.Equal(t, res.LoadedAt, again.LoadedAt)
+	require.Nil(t, applied, "unchanged objects are not applied again")
+
+	store["data/a.json"] = "{}"
+	_, err = loader.Load(context.Background(), apply)
+	require.ErrorIs(t, err, ErrInvalid)
+	require.ErrorContains(t, err, "checksum says")
+
+	delete(store, "data/a.json.sha256")
+	_, err = loader.Load(context.Background(), apply)
+	require.ErrorContains(t, err, "has no a.json.sha256 checksum")
+}
+
+func TestLoadKeepsObjectsWhenApplyFails(t *testing.T) {
+	store := mapStore{}
+	loader := NewLoader(store, "", "a.json")
+
+	_, err := loader.Load(context.Background(), func(map[string][]byte) error { return errors.New("boom") })
+	require.EqualError(t, err, "boom")
+
+	res, err := loader.Load(context.Background(), func(map[string][]byte) error { return nil })
+	require.NoError(t, err)
+	require.True(t, res.Changed, "a failed apply leaves nothing loaded")
+}
@@ -1,6 +1,7 @@
 package dedupe
 
 import (
+	"strings"
 	"sync"
 	"time"
 )
@@ -77,3 +78,52 @@ func (c *Cache) compact(now time.Time) {
 		}
 	}
 }
+
+// Flush forgets every key and reports how many were dropped.
+func (c *Cache) Flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := len(c.items)
+	c.items = make(map[string]time.Time, c.capacity)
+	c.order = make([]entry, 0, c.capacity)
+	return n
+}
+
+// Invalidate forgets the given keys, so matching messages are processed
+// again, and reports how many of them were present.
+func (c *Cache) Invalidate(keys ...string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for _, key := range keys {
+		if _, ok := c.items[key]; ok {
+			delete(c.items, key)
+			n++
+		}
+	}
+	return n
+}
+
+// InvalidatePrefix forgets every key starting with prefix.
+func (c *Cache) InvalidatePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for key := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.items, key)
+			n++
+		}
+	}
+	return n
+}
+
+// Len reports the number of remembered keys.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
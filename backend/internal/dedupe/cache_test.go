@@ -34,3 +34,26 @@ func TestCacheCapacityEvictsOldest(t *testing.T) {
 	require.False(t, cache.IsSeen("first"))
 	require.True(t, cache.IsSeen("second"))
 }
+
+func TestCacheFlushAndInvalidate(t *testing.T) {
+	cache := dedupe.NewCache(10, time.Minute)
+	cache.MarkSeen("doc-1")
+	cache.MarkSeen("fp|2024-06-01T00:00:00Z")
+	cache.MarkSeen("fp|2024-06-02T00:00:00Z")
+	cache.MarkSeen("other")
+
+	require.Equal(t, 1, cache.Invalidate("doc-1", "missing"))
+	require.False(t, cache.IsSeen("doc-1"))
+
+	require.Equal(t, 2, cache.InvalidatePrefix("fp|"))
+	require.False(t, cache.IsSeen("fp|2024-06-01T00:00:00Z"))
+	require.Equal(t, 1, cache.Len())
+
+	// A re-marked key survives compaction of its stale order entry.
+	cache.MarkSeen("doc-1")
+	require.True(t, cache.IsSeen("doc-1"))
+
+	require.Equal(t, 2, cache.Flush())
+	require.False(t, cache.IsSeen("other"))
+	require.Zero(t, cache.Len())
+}
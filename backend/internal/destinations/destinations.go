@@ -0,0 +1,244 @@
+// Package destinations models travel destinations as a hierarchy (resort →
+// country → region) and recognizes them in post text.
+package destinations
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+//go:embed taxonomy.json
+var defaultTaxonomy []byte
+
+// Node is a destination with the spellings it is recognized by and the
+// more specific destinations it contains.
+type Node struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+	// Location places the destination on the map; destinations without one,
+	// such as regions, are placed at their enclosing destination's.
+	Location *models.GeoPoint `json:"location,omitempty"`
+	Children []*Node          `json:"children,omitempty"`
+}
+
+// ID is the value stored in NewsDocument.Destinations and used by the
+// destination filter.
+func (n *Node) ID() string {
+	return normalize(n.Name)
+}
+
+// Taxonomy indexes a destination tree for matching and lookups.
+type Taxonomy struct {
+	roots   []*Node
+	aliases map[string]*Node
+	// stems maps stemmed aliases to their node, or to nil when aliases of
+	// different destinations share a stem.
+	stems   map[string]*Node
+	parents map[*Node]*Node
+	// maxWords is the longest alias in words, bounding phrase matching.
+	maxWords int
+}
+
+// Default returns the taxonomy shipped with the service. It is parsed once
+// and shared; a Taxonomy is never modified after Parse.
+func Default() *Taxonomy {
+	return parseDefault()
+}
+
+var parseDefault = sync.OnceValue(func() *Taxonomy {
+	t, err := Parse(defaultTaxonomy)
+	if err != nil {
+		panic(fmt.Sprintf("embedded destination taxonomy: %v", err))
+	}
+	return t
+})
+
+// Load reads a taxonomy from a JSON file, or returns Default when path is empty.
+func Load(path string) (*Taxonomy, error) {
+	if path == "" {
+		return Default(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read destination taxonomy: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse builds a taxonomy from its JSON form: a list of root nodes.
+func Parse(data []byte) (*Taxonomy, error) {
+	var roots []*Node
+	if err := json.Unmarshal(data, &roots); err != nil {
+		return nil, fmt.Errorf("decode destination taxonomy: %w", err)
+	}
+
+	t := &Taxonomy{
+		roots:   roots,
+		aliases: make(map[string]*Node),
+		stems:   make(map[string]*Node),
+		parents: make(map[*Node]*Node),
+	}
+	var walk func(node, parent *Node) error
+	walk = func(node, parent *Node) error {
+		if strings.TrimSpace(node.Name) == "" {
+			return fmt.Errorf("destination without a name")
+		}
+		if loc := node.Location; loc != nil && (loc.Lat < -90 || loc.Lat > 90 || loc.Lon < -180 || loc.Lon > 180) {
+			return fmt.Errorf("destination %q located outside the map", node.Name)
+		}
+		t.parents[node] = parent
+		for _, alias := range append([]string{node.Name}, node.Aliases...) {
+			key := normalize(alias)
+			if key == "" {
+				continue
+			}
+			if other, ok := t.aliases[key]; ok && other != node {
+				return fmt.Errorf("alias %q used by both %q and %q", alias, other.Name, node.Name)
+			}
+			t.aliases[key] = node
+			words := strings.Fields(key)
+			t.maxWords = max(t.maxWords, len(words))
+
+			// Nominatives that look inflected ("вьетнам") keep their raw
+			// form too, so "вьетнаму" still finds them once stemmed.
+			for _, form := range []string{key, stemPhrase(words)} {
+				if other, ok := t.stems[form]; ok && other != node {
+					t.stems[form] = nil
+				} else if !ok {
+					t.stems[form] = node
+				}
+			}
+		}
+		for _, child := range node.Children {
+			if err := walk(child, node); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, root := range roots {
+		if err := walk(root, nil); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Roots returns the top-level destinations.
+func (t *Taxonomy) Roots() []*Node {
+	return t.roots
+}
+
+// Lookup resolves a name or alias, in any grammatical case, to its destination.
+func (t *Taxonomy) Lookup(name string) (*Node, bool) {
+	key := normalize(name)
+	if node, ok := t.aliases[key]; ok {
+		return node, true
+	}
+	node := t.stems[stemPhrase(strings.Fields(key))]
+	return node, node != nil
+}
+
+// Canonical returns the destination ID for a name or alias, or the
+// normalized input when the taxonomy does not know it.
+func (t *Taxonomy) Canonical(name string) string {
+	if node, ok := t.Lookup(name); ok {
+		return node.ID()
+	}
+	return normalize(name)
+}
+
+// Match finds the destinations mentioned in text, in any grammatical case,
+// and returns their IDs together with the IDs of every enclosing
+// destination, most specific first, so a post about Кемер is also tagged
+// Анталья, Турция and Ближний Восток.
+func (t *Taxonomy) Match(text string) []string {
+	words := strings.Fields(normalize(text))
+	stems := make([]string, len(words))
+	for i, w := range words {
+		stems[i] = stem(w)
+	}
+
+	var out []string
+	seen := make(map[*Node]struct{})
+	for i := range words {
+		for n := min(t.maxWords, len(words)-i); n > 0; n-- {
+			node, ok := t.aliases[strings.Join(words[i:i+n], " ")]
+			if !ok {
+				node = t.stems[strings.Join(stems[i:i+n], " ")]
+			}
+			if node == nil {
+				continue
+			}
+			for ; node != nil; node = t.parents[node] {
+				if _, dup := seen[node]; dup {
+					break
+				}
+				seen[node] = struct{}{}
+				out = append(out, node.ID())
+			}
+			break
+		}
+	}
+	return out
+}
+
+// Locations returns the map positions of the most specific of the given
+// destination IDs, as Match returns them: a document about Кемер is placed
+// at Кемер rather than also at Анталья and Турция. Destinations without a
+// location take their closest enclosing one's; unknown IDs are skipped.
+func (t *Taxonomy) Locations(ids []string) []models.GeoPoint {
+	tagged := make(map[*Node]struct{}, len(ids))
+	for _, id := range ids {
+		if node, ok := t.aliases[id]; ok {
+			tagged[node] = struct{}{}
+		}
+	}
+	enclosing := make(map[*Node]struct{}, len(tagged))
+	for node := range tagged {
+		for parent := t.parents[node]; parent != nil; parent = t.parents[parent] {
+			enclosing[parent] = struct{}{}
+		}
+	}
+
+	var out []models.GeoPoint
+	for _, id := range ids {
+		node, ok := t.aliases[id]
+		if !ok {
+			continue
+		}
+		if _, ok := enclosing[node]; ok {
+			continue
+		}
+		for ; node != nil; node = t.parents[node] {
+			if node.Location != nil {
+				if !slices.Contains(out, *node.Location) {
+					out = append(out, *node.Location)
+				}
+				break
+			}
+		}
+	}
+	return out
+}
+
+// normalize lowercases s and reduces it to space-separated words, keeping
+// hyphens inside words ("шарм-эль-шейх").
+func normalize(s string) string {
+	s = strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+	for i, f := range fields {
+		fields[i] = strings.Trim(f, "-")
+	}
+	return strings.Join(strings.Fields(strings.Join(fields, " ")), " ")
+}
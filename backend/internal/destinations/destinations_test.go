@@ -0,0 +1,80 @@
+package destinations_test
+
+import (
+	"testing"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/destinations"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTagsAllLevels(t *testing.T) {
+	taxonomy := destinations.Default()
+
+	require.Equal(t,
+		[]string{"кемер", "анталья", "турция", "ближний восток"},
+		taxonomy.Match("Горящий тур в Кемере, вылет завтра"),
+	)
+	require.Equal(t,
+		[]string{"шарм-эль-шейх", "египет", "ближний восток", "пхукет", "таиланд", "юго-восточная азия"},
+		taxonomy.Match("Шарм-эль-Шейх или Пхукет? Сравниваем Египет и Таиланд"),
+	)
+	require.Empty(t, taxonomy.Match("Скидки на авиабилеты"))
+}
+
+func TestCanonical(t *testing.T) {
+	taxonomy := destinations.Default()
+	require.Equal(t, "турция", taxonomy.Canonical("Turkey"))
+	require.Equal(t, "оаэ", taxonomy.Canonical("Эмираты"))
+	require.Equal(t, "занзибар", taxonomy.Canonical(" Занзибар "))
+}
+
+func TestParseCustomTaxonomy(t *testing.T) {
+	taxonomy, err := destinations.Parse([]byte(`[
+		{"name": "Африка", "children": [{"name": "Танзания", "children": [{"name": "Занзибар", "aliases": ["занзибаре"]}]}]}
+	]`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"занзибар", "танзания", "африка"}, taxonomy.Match("Отдых на Занзибаре"))
+	require.Len(t, taxonomy.Roots(), 1)
+
+	_, err = destinations.Parse([]byte(`[{"name": "А", "aliases": ["x"]}, {"name": "Б", "aliases": ["X"]}]`))
+	require.ErrorContains(t, err, "used by both")
+}
+
+func TestMatchInflectedForms(t *testing.T) {
+	taxonomy := destinations.Default()
+
+	require.Equal(t, []string{"пхукет", "таиланд", "юго-восточная азия"}, taxonomy.Match("Летим за Пхукетом"))
+	require.Equal(t, []string{"вьетнам", "юго-восточная азия"}, taxonomy.Match("Туры по Вьетнаму"))
+	require.Equal(t, []string{"турция", "ближний восток"}, taxonomy.Match("Восхищаемся Турцией"))
+	require.Equal(t, []string{"египет", "ближний восток"}, taxonomy.Match("Отели рядом с Египтом"))
+	require.Equal(t, []string{"ближний восток"}, taxonomy.Match("Новости Ближнего Востока"))
+
+	// Short names are matched exactly, so ordinary words sharing their stem are not tagged.
+	require.Empty(t, taxonomy.Match("Сидя дома, мечтаем о море"))
+
+	node, ok := taxonomy.Lookup("Анталье")
+	require.True(t, ok)
+	require.Equal(t, "анталья", node.ID())
+}
+
+func TestLocations(t *testing.T) {
+	taxonomy := destinations.Default()
+
+	// Кемер stands for Анталья and Турция; Египет is placed on its own and
+	// the region has no location.
+	require.Equal(t,
+		[]models.GeoPoint{{Lat: 36.6, Lon: 30.56}, {Lat: 26.8, Lon: 30.8}},
+		taxonomy.Locations([]string{"кемер", "анталья", "турция", "ближний восток", "египет", "занзибар"}),
+	)
+	require.Empty(t, taxonomy.Locations([]string{"ближний восток"}))
+
+	custom, err := destinations.Parse([]byte(`[
+		{"name": "Танзания", "location": {"lat": -6.4, "lon": 34.9}, "children": [{"name": "Занзибар"}]}
+	]`))
+	require.NoError(t, err)
+	require.Equal(t, []models.GeoPoint{{Lat: -6.4, Lon: 34.9}}, custom.Locations([]string{"занзибар", "танзания"}))
+
+	_, err = destinations.Parse([]byte(`[{"name": "А", "location": {"lat": 91, "lon": 0}}]`))
+	require.ErrorContains(t, err, "outside the map")
+}
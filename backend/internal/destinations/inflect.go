@@ -0,0 +1,55 @@
+package destinations
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// caseEndings are Russian noun and adjective endings, longest first, that
+// stem strips so inflected forms ("в Турцией", "на Пхукете", "по Вьетнаму")
+// match without every form being listed as an alias.
+var caseEndings = []string{
+	"ами", "ями", "ией", "ьей", "ого", "его", "ому", "ему",
+	"ия", "ию", "ии", "ья", "ью", "ьи", "ье",
+	"ой", "ей", "ом", "ем", "ам", "ям", "ах", "ях", "ов", "ев",
+	"ий", "ый", "ая", "ое", "ые", "их", "ых", "им", "ым",
+	"а", "я", "ы", "и", "у", "ю", "е", "о", "ь", "й",
+}
+
+// minStemLength keeps short names such as "сиде" or "бали" from being
+// stemmed into fragments that ordinary words share.
+const minStemLength = 4
+
+// stem strips one case ending from a Cyrillic word. Latin words and words
+// whose stem would be too short are returned unchanged.
+func stem(word string) string {
+	if !hasCyrillic(word) {
+		return word
+	}
+	for _, ending := range caseEndings {
+		base, ok := strings.CutSuffix(word, ending)
+		if ok && utf8.RuneCountInString(base) >= minStemLength {
+			return base
+		}
+	}
+	return word
+}
+
+// stemPhrase stems every word of an already normalized phrase.
+func stemPhrase(words []string) string {
+	stems := make([]string, len(words))
+	for i, w := range words {
+		stems[i] = stem(w)
+	}
+	return strings.Join(stems, " ")
+}
+
+func hasCyrillic(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+)
+
+// MaxAggregationSize bounds the buckets returned per aggregated field.
+const MaxAggregationSize = 100
+
+// aggregationFields maps the names accepted by AggregateNews to the
+// keyword fields they bucket on.
+var aggregationFields = map[string]string{
+	"keywords":    "keywords",
+	"source":      "source",
+	"destination": "destinations",
+}
+
+// AggregationFields lists the field names AggregateNews accepts, in the
+// order they are reported when a request does not name any.
+var AggregationFields = []string{"keywords", "source", "destination"}
+
+// AggregationResult holds the most frequent values of each requested field
+// among the documents matching a filter.
+type AggregationResult struct {
+	Total   int64                  `json:"total"`
+	Buckets map[string][]TermCount `json:"buckets"`
+}
+
+// AggregateNews counts the most frequent values of fields among documents
+// matching params, up to size values per field. Sort and pagination of
+// params are ignored; fields must come from AggregationFields.
+func (c *Client) AggregateNews(ctx context.Context, params SearchParams, fields []string, size int) (*AggregationResult, error) {
+	if len(fields) == 0 {
+		fields = AggregationFields
+	}
+	size = min(max(size, 1), MaxAggregationSize)
+
+	aggs := make(map[string]any, len(fields))
+	for _, name := range fields {
+		field, ok := aggregationFields[name]
+		if !ok {
+			return nil, &StatusError{Op: "aggregate news", Kind: ErrBadRequest, Err: fmt.Errorf("unknown aggregation field %q", name)}
+		}
+		aggs[name] = map[string]any{
+			"terms": map[string]any{"field": field, "size": size},
+		}
+	}
+
+	body := map[string]any{
+		"size":             0,
+		"track_total_hits": true,
+		"query":            buildQuery(params).Source(),
+		"aggs":             aggs,
+	}
+
+	var parsed struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+		Aggregations map[string]termsAggregation `json:"aggregations"`
+	}
+	if err := c.searchInto(ctx, c.index, body, &parsed); err != nil {
+		return nil, err
+	}
+
+	result := &AggregationResult{
+		Total:   parsed.Hits.Total.Value,
+		Buckets: make(map[string][]TermCount, len(fields)),
+	}
+	for _, name := range fields {
+		result.Buckets[name] = parsed.Aggregations[name].counts()
+	}
+	return result, nil
+}
@@ -0,0 +1,60 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateNews(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/news/_search", r.URL.Path)
+		var body struct {
+			Size  int                        `json:"size"`
+			Query map[string]json.RawMessage `json:"query"`
+			Aggs  map[string]struct {
+				Terms struct {
+					Field string `json:"field"`
+					Size  int    `json:"size"`
+				} `json:"terms"`
+			} `json:"aggs"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Zero(t, body.Size)
+		require.Contains(t, string(body.Query["bool"]), `"range"`)
+		require.Len(t, body.Aggs, 2)
+		require.Equal(t, "source", body.Aggs["source"].Terms.Field)
+		require.Equal(t, MaxAggregationSize, body.Aggs["keywords"].Terms.Size)
+
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{
+			"hits": {"total": {"value": 42}},
+			"aggregations": {
+				"keywords": {"buckets": [{"key": "турция", "doc_count": 30}, {"key": "египет", "doc_count": 12}]},
+				"source": {"buckets": [{"key": "telegram", "doc_count": 42}]}
+			}
+		}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	start := time.Now().Add(-24 * time.Hour)
+	result, err := client.AggregateNews(context.Background(), SearchParams{Start: &start}, []string{"keywords", "source"}, 500)
+	require.NoError(t, err)
+	require.EqualValues(t, 42, result.Total)
+	require.Equal(t, []TermCount{{Term: "турция", Count: 30}, {Term: "египет", Count: 12}}, result.Buckets["keywords"])
+	require.Equal(t, []TermCount{{Term: "telegram", Count: 42}}, result.Buckets["source"])
+
+	_, err = client.AggregateNews(context.Background(), SearchParams{}, []string{"price"}, 10)
+	require.True(t, errors.Is(err, ErrBadRequest))
+}
@@ -0,0 +1,119 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// BulkItem is a single document written by BulkIndexNews.
+type BulkItem struct {
+	Doc models.NewsDocument
+	// Repost records another sighting of an existing document, like
+	// IndexRepost, instead of overwriting it.
+	Repost bool
+	// Index writes the item to another index than the client's.
+	Index string
+}
+
+// BulkIndexNews writes items with a single _bulk request. The returned
+// slice is aligned with items and holds a *StatusError for every item
+// Elasticsearch rejected; the error is set when the request as a whole
+// failed, in which case no item outcome is known.
+func (c *Client) BulkIndexNews(ctx context.Context, items []BulkItem, opts ...IndexOption) ([]error, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	o := applyIndexOptions(opts)
+
+	docs := make([]models.NewsDocument, len(items))
+	for i, item := range items {
+		docs[i] = item.Doc
+	}
+	indexes, err := c.writeIndexes(ctx, docs...)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i, item := range items {
+		var action, source any
+		meta := map[string]any{"_id": item.Doc.ID}
+		switch {
+		case item.Index != "":
+			meta["_index"] = item.Index
+		case indexes[i] != c.index:
+			meta["_index"] = indexes[i]
+		}
+		if item.Repost {
+			meta["retry_on_conflict"] = 3
+			action = map[string]any{"update": meta}
+			source = repostBody(item.Doc)
+		} else {
+			action = map[string]any{"index": meta}
+			source = item.Doc
+		}
+		if err := enc.Encode(action); err != nil {
+			return nil, fmt.Errorf("encode bulk action: %w", err)
+		}
+		if err := enc.Encode(source); err != nil {
+			return nil, fmt.Errorf("encode doc %s: %w", item.Doc.ID, err)
+		}
+	}
+
+	res, err := c.es.Bulk(&buf,
+		c.es.Bulk.WithContext(ctx),
+		c.es.Bulk.WithIndex(c.index),
+		c.es.Bulk.WithRefresh(o.refresh),
+	)
+	if err != nil {
+		return nil, transportError("bulk index", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, responseError("bulk index", res)
+	}
+
+	var parsed struct {
+		Errors bool                        `json:"errors"`
+		Items  []map[string]bulkItemResult `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode bulk response: %w", err)
+	}
+	if len(parsed.Items) != len(items) {
+		return nil, fmt.Errorf("bulk index: got %d results for %d items", len(parsed.Items), len(items))
+	}
+
+	errs := make([]error, len(items))
+	if !parsed.Errors {
+		return errs, nil
+	}
+	for i, result := range parsed.Items {
+		// Each result is keyed by its action type, "index" or "update".
+		for _, r := range result {
+			if r.Error != nil {
+				errs[i] = &StatusError{
+					Op:     "bulk index " + items[i].Doc.ID,
+					Status: r.Status,
+					Body:   r.Error.Type + ": " + r.Error.Reason,
+					Kind:   kindForStatus(r.Status),
+				}
+			}
+		}
+	}
+	return errs, nil
+}
+
+type bulkItemResult struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
@@ -0,0 +1,65 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func TestBulkIndexNews(t *testing.T) {
+	var actions []map[string]map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/news/_bulk", r.URL.Path)
+		require.Equal(t, "false", r.URL.Query().Get("refresh"))
+
+		scanner := bufio.NewScanner(r.Body)
+		for line := 0; scanner.Scan(); line++ {
+			if line%2 == 0 {
+				var action map[string]map[string]any
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &action))
+				actions = append(actions, action)
+			}
+		}
+
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"errors":true,"items":[
+			{"index":{"_id":"a","status":201}},
+			{"update":{"_id":"b","status":200}},
+			{"index":{"_id":"c","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}},
+			{"index":{"_id":"d","status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}}
+		]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	errs, err := client.BulkIndexNews(context.Background(), []BulkItem{
+		{Doc: models.NewsDocument{ID: "a"}, Index: "news-quarantine"},
+		{Doc: models.NewsDocument{ID: "b"}, Repost: true},
+		{Doc: models.NewsDocument{ID: "c"}},
+		{Doc: models.NewsDocument{ID: "d"}},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, actions, 4)
+	require.Equal(t, "a", actions[0]["index"]["_id"])
+	require.Equal(t, "news-quarantine", actions[0]["index"]["_index"])
+	require.Equal(t, "b", actions[1]["update"]["_id"])
+	require.NotContains(t, actions[1]["update"], "_index")
+
+	require.NoError(t, errs[0])
+	require.NoError(t, errs[1])
+	require.ErrorIs(t, errs[2], ErrBadRequest)
+	require.ErrorContains(t, errs[2], "mapper_parsing_exception")
+	require.True(t, IsRetryable(errs[3]))
+}
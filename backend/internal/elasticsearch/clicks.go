@@ -0,0 +1,78 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+const clickScript = `ctx._source.clicks = (ctx._source.clicks == null ? 0 : ctx._source.clicks) + 1;`
+
+// ClicksIndex is the index that stores raw click events.
+func (c *Client) ClicksIndex() string {
+	return c.index + "_clicks"
+}
+
+// RecordClick stores a click event for partner reporting and increments the
+// clicked document's counter, which ranking uses as a popularity signal.
+func (c *Client) RecordClick(ctx context.Context, click models.ClickEvent) error {
+	payload, err := json.Marshal(click)
+	if err != nil {
+		return fmt.Errorf("marshal click: %w", err)
+	}
+
+	res, err := c.es.Index(
+		c.ClicksIndex(),
+		bytes.NewReader(payload),
+		c.es.Index.WithContext(ctx),
+	)
+	if err != nil {
+		return transportError("index click", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError("index click", res)
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"script": map[string]any{"lang": "painless", "source": clickScript},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal click counter body: %w", err)
+	}
+
+	index := c.index
+	if c.daily {
+		found, err := c.locate(ctx, []string{click.DocumentID})
+		if err != nil {
+			return fmt.Errorf("locate clicked document: %w", err)
+		}
+		if found[click.DocumentID] == "" {
+			return &StatusError{Op: "increment clicks", Status: http.StatusNotFound, Kind: ErrNotFound}
+		}
+		index = found[click.DocumentID]
+	}
+
+	req := esapi.UpdateRequest{
+		Index:           index,
+		DocumentID:      click.DocumentID,
+		Body:            bytes.NewReader(body),
+		RetryOnConflict: esapi.IntPtr(5),
+	}
+	upd, err := req.Do(ctx, c.es)
+	if err != nil {
+		return transportError("increment clicks", err)
+	}
+	defer upd.Body.Close()
+	if upd.IsError() {
+		return responseError("increment clicks", upd)
+	}
+
+	return nil
+}
@@ -4,17 +4,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/querylang"
 )
 
 // Client wraps go-elasticsearch with helpers tailored to this project.
@@ -22,6 +26,13 @@ type Client struct {
 	es    *elasticsearch.Client
 	index string
 	log   *slog.Logger
+	// daily is set by EnableDailyIndices.
+	daily bool
+	// stemKeywords is set by EnableKeywordStemming.
+	stemKeywords bool
+	// templates is set once EnsureTemplates has registered the stored
+	// search templates.
+	templates atomic.Bool
 }
 
 // SearchParams narrow the search endpoint query.
@@ -29,24 +40,107 @@ type SearchParams struct {
 	Query    string
 	Keywords []string
 	Source   string
-	From     int
-	Size     int
-	Sort     string
-	Start    *time.Time
-	End      *time.Time
+	// Destination filters on a destination ID; documents are tagged with
+	// every enclosing destination, so a country also matches its resorts.
+	Destination string
+	// Mention filters on an @username, given without the @.
+	Mention string
+	From    int
+	Size    int
+	Sort    string
+	Start   *time.Time
+	End     *time.Time
+	// Popularity tunes the engagement boost applied when sorting by relevance.
+	Popularity PopularityBoost
+	// Fuzzy enables typo-tolerant matching of Query: words match within the
+	// edit distance Elasticsearch's AUTO fuzziness allows for their length,
+	// none up to two letters, one up to five and two beyond.
+	Fuzzy bool
+	// Relax retries a query that found nothing with fuzzy matching, unless
+	// Fuzzy is already set, and then without its filters, one at a time,
+	// reporting what was dropped.
+	Relax bool
+	// ActiveOnly hides documents whose expires_at has passed and those
+	// announced as sold out or cancelled.
+	ActiveOnly bool
+	// HasPrice, HasURL and HasTravelDates keep only documents carrying
+	// the corresponding extracted field.
+	HasPrice       bool
+	HasURL         bool
+	HasTravelDates bool
+	// MinPrice and MaxPrice bound the extracted price, in rubles, both
+	// inclusive; zero leaves a bound open. Documents without a price never
+	// match a bound.
+	MinPrice int
+	MaxPrice int
+	// MinQuality keeps documents whose quality score, from 0 to 100, is at
+	// least this; documents indexed before scoring never match.
+	MinQuality int
+	// BoostKeywords raise the relevance score of documents tagged with the
+	// given keywords without filtering on them.
+	BoostKeywords []KeywordBoost
+	// UnseenBy hides the documents and clusters this reader marked as seen.
+	UnseenBy string
+	// PIT searches a point-in-time snapshot instead of the live index.
+	PIT *PointInTime
+	// Cursor pages the live index with search_after instead of from.
+	Cursor *Cursor
+	// MaxPerSource caps the hits of one source on a page, filling the page
+	// with the next hits of other sources instead; zero leaves it uncapped.
+	// It is ignored by PIT and Cursor searches.
+	MaxPerSource int
+}
+
+// KeywordBoost weights a keyword in relevance ranking; Weight must be positive.
+type KeywordBoost struct {
+	Keyword string
+	Weight  float64
+}
+
+// PopularityBoost weights engagement signals in relevance ranking. Each
+// signal adds log10(1 + weight*value) to the text score, as Elasticsearch's
+// log1p modifier computes it, so the weight scales the signal inside the
+// logarithm; zero disables it.
+type PopularityBoost struct {
+	SeenWeight  float64
+	ClickWeight float64
 }
 
 // SearchResult bundles hits and total count.
 type SearchResult struct {
 	Total int64
 	Items []models.NewsDocument
+	// Relaxed is set when the original query matched nothing and the result
+	// comes from a relaxed version of it; Dropped names the constraints that
+	// were given up, in order ("exact_match", "keywords", "source", "time_range").
+	Relaxed bool     `json:",omitempty"`
+	Dropped []string `json:",omitempty"`
+	// PIT is set by point-in-time searches to the snapshot's ID, which
+	// Elasticsearch may renew. SearchAfter holds the sort values of the
+	// last hit, from which a PIT or Cursor search continues.
+	PIT         string          `json:"-"`
+	SearchAfter json.RawMessage `json:"-"`
+}
+
+// Option adjusts how New connects to the cluster.
+type Option func(*elasticsearch.Config)
+
+// WithTransport wraps the HTTP transport configured so far, e.g. to inject
+// faults in tests. Options apply in order, so later wrappers see requests first.
+func WithTransport(wrap func(http.RoundTripper) http.RoundTripper) Option {
+	return func(cfg *elasticsearch.Config) {
+		cfg.Transport = wrap(cfg.Transport)
+	}
 }
 
 // New instantiates the Elasticsearch client.
-func New(addr, index string, logger *slog.Logger) (*Client, error) {
+func New(addr, index string, logger *slog.Logger, opts ...Option) (*Client, error) {
 	cfg := elasticsearch.Config{
 		Addresses: []string{addr},
 	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 
 	es, err := elasticsearch.NewClient(cfg)
 	if err != nil {
@@ -64,120 +158,224 @@ func New(addr, index string, logger *slog.Logger) (*Client, error) {
 func (c *Client) Ping(ctx context.Context) error {
 	res, err := c.es.Ping(c.es.Ping.WithContext(ctx))
 	if err != nil {
-		return fmt.Errorf("ping elasticsearch: %w", err)
+		return transportError("ping", err)
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return fmt.Errorf("elasticsearch ping failed: %s", res.Status())
+		return responseError("ping", res)
 	}
 
 	return nil
 }
 
+// IndexOption adjusts a single write request.
+type IndexOption func(*indexOptions)
+
+type indexOptions struct {
+	refresh string
+}
+
+// WaitForRefresh makes the write return only once the document is visible
+// to search. It trades throughput for read-your-writes consistency and is
+// meant for tests and manual submissions, not bulk ingestion.
+func WaitForRefresh() IndexOption {
+	return func(o *indexOptions) {
+		o.refresh = "wait_for"
+	}
+}
+
+func applyIndexOptions(opts []IndexOption) indexOptions {
+	o := indexOptions{refresh: "false"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
 // IndexNews writes a document into Elasticsearch.
-func (c *Client) IndexNews(ctx context.Context, doc models.NewsDocument) error {
+func (c *Client) IndexNews(ctx context.Context, doc models.NewsDocument, opts ...IndexOption) error {
+	o := applyIndexOptions(opts)
 	payload, err := json.Marshal(doc)
 	if err != nil {
 		return fmt.Errorf("marshal doc: %w", err)
 	}
 
+	indexes, err := c.writeIndexes(ctx, doc)
+	if err != nil {
+		return err
+	}
+
 	req := esapi.IndexRequest{
-		Index:      c.index,
+		Index:      indexes[0],
 		DocumentID: doc.ID,
 		Body:       bytes.NewReader(payload),
-		Refresh:    "false",
+		Refresh:    o.refresh,
 	}
 
 	res, err := req.Do(ctx, c.es)
 	if err != nil {
-		return fmt.Errorf("index doc: %w", err)
+		return transportError("index doc", err)
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		body, _ := io.ReadAll(res.Body)
-		return fmt.Errorf("index doc failed: %s", strings.TrimSpace(string(body)))
+		return responseError("index doc", res)
 	}
 
 	return nil
 }
 
-// SearchNews executes a bool query with optional filters.
-func (c *Client) SearchNews(ctx context.Context, params SearchParams) (*SearchResult, error) {
-	if params.Size <= 0 {
-		params.Size = 20
+// repostScript bumps the sighting counter of an existing document and
+// moves last_seen forward. Timestamps are UTC RFC3339 strings, so they
+// compare chronologically as plain strings.
+const repostScript = `
+ctx._source.seen_count = (ctx._source.seen_count == null ? 1 : ctx._source.seen_count) + 1;
+if (ctx._source.last_seen == null || ctx._source.last_seen.compareTo(params.last_seen) < 0) {
+	ctx._source.last_seen = params.last_seen;
+}
+`
+
+// IndexRepost creates doc or, when a document with the same ID already
+// exists, records another sighting of it instead of overwriting it.
+func (c *Client) IndexRepost(ctx context.Context, doc models.NewsDocument, opts ...IndexOption) error {
+	o := applyIndexOptions(opts)
+	payload, err := json.Marshal(repostBody(doc))
+	if err != nil {
+		return fmt.Errorf("marshal repost body: %w", err)
 	}
-	if params.Size > 200 {
-		params.Size = 200
+	indexes, err := c.writeIndexes(ctx, doc)
+	if err != nil {
+		return err
 	}
-	if params.From < 0 {
-		params.From = 0
+
+	req := esapi.UpdateRequest{
+		Index:           indexes[0],
+		DocumentID:      doc.ID,
+		Body:            bytes.NewReader(payload),
+		RetryOnConflict: esapi.IntPtr(3),
+		Refresh:         o.refresh,
 	}
 
-	must := make([]map[string]any, 0, 2)
-	filters := make([]map[string]any, 0, 3)
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return transportError("upsert repost", err)
+	}
+	defer res.Body.Close()
 
-	if params.Query != "" {
-		must = append(must, map[string]any{
-			"multi_match": map[string]any{
-				"query":  params.Query,
-				"fields": []string{"title^2", "text"},
-			},
-		})
+	if res.IsError() {
+		return responseError("upsert repost", res)
 	}
 
-	if len(params.Keywords) > 0 {
-		filters = append(filters, map[string]any{
-			"terms": map[string]any{
-				"keywords": params.Keywords,
+	return nil
+}
+
+// repostBody is the scripted upsert shared by IndexRepost and BulkIndexNews.
+func repostBody(doc models.NewsDocument) map[string]any {
+	return map[string]any{
+		"script": map[string]any{
+			"lang":   "painless",
+			"source": repostScript,
+			"params": map[string]any{
+				"last_seen": doc.LastSeen.UTC().Format(time.RFC3339),
 			},
-		})
+		},
+		"upsert": doc,
 	}
+}
 
-	if params.Source != "" {
-		filters = append(filters, map[string]any{
-			"term": map[string]any{
-				"source": params.Source,
-			},
-		})
+// SearchNews executes a bool query with optional filters. With params.Relax
+// set, a query without hits is retried in progressively relaxed forms.
+func (c *Client) SearchNews(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	result, err := c.searchOnce(ctx, params)
+	if err != nil || result.Total > 0 || !params.Relax {
+		return result, err
 	}
 
-	if params.Start != nil || params.End != nil {
-		rangeQuery := map[string]any{}
-		if params.Start != nil {
-			rangeQuery["gte"] = params.Start.UTC().Format(time.RFC3339)
+	var dropped []string
+	for _, step := range relaxationSteps(params) {
+		step.apply(&params)
+		dropped = append(dropped, step.name)
+
+		relaxed, err := c.searchOnce(ctx, params)
+		if err != nil {
+			return nil, err
 		}
-		if params.End != nil {
-			rangeQuery["lte"] = params.End.UTC().Format(time.RFC3339)
+		if relaxed.Total > 0 {
+			relaxed.Relaxed = true
+			relaxed.Dropped = dropped
+			c.log.Debug("relaxed zero-result query", slog.Any("dropped", dropped))
+			return relaxed, nil
 		}
-		filters = append(filters, map[string]any{
-			"range": map[string]any{
-				"timestamp": rangeQuery,
-			},
-		})
 	}
 
-	boolQuery := map[string]any{}
-	if len(must) > 0 {
-		boolQuery["must"] = must
+	return result, nil
+}
+
+type relaxation struct {
+	name  string
+	apply func(*SearchParams)
+}
+
+// relaxationSteps lists the applicable relaxations of params, from the
+// least to the most invasive. Steps are cumulative.
+func relaxationSteps(params SearchParams) []relaxation {
+	var steps []relaxation
+	if params.Query != "" && !params.Fuzzy {
+		steps = append(steps, relaxation{"exact_match", func(p *SearchParams) { p.Fuzzy = true }})
+	}
+	if len(params.Keywords) > 0 {
+		steps = append(steps, relaxation{"keywords", func(p *SearchParams) { p.Keywords = nil }})
+	}
+	if params.Source != "" {
+		steps = append(steps, relaxation{"source", func(p *SearchParams) { p.Source = "" }})
+	}
+	if params.Start != nil || params.End != nil {
+		steps = append(steps, relaxation{"time_range", func(p *SearchParams) { p.Start, p.End = nil, nil }})
+	}
+	return steps
+}
+
+func (c *Client) searchOnce(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	if params.Size <= 0 {
+		params.Size = 20
+	}
+	if params.Size > 200 {
+		params.Size = 200
 	}
-	if len(filters) > 0 {
-		boolQuery["filter"] = filters
+	if params.From < 0 {
+		params.From = 0
+	}
+	if params.MaxPerSource > 0 && params.PIT == nil && params.Cursor == nil {
+		return c.searchDiverse(ctx, params)
 	}
-	if len(must) == 0 && len(filters) == 0 {
-		boolQuery["must"] = []map[string]any{
-			{"match_all": map[string]any{}},
+	return c.searchPage(ctx, params)
+}
+
+// searchPage runs one search for the page params select.
+func (c *Client) searchPage(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	if name, templateParams, ok := searchTemplate(params); ok && params.PIT == nil && params.Cursor == nil && c.templates.Load() {
+		result, err := c.runTemplate(ctx, name, templateParams)
+		if !errors.Is(err, ErrNotFound) {
+			return result, err
 		}
+		// The stored template was deleted behind our back; the inline
+		// query below is equivalent.
+		c.log.Warn("search template missing, using inline query", slog.String("template", c.templateID(name)))
 	}
 
+	query := buildQuery(params)
+	if params.UnseenBy != "" {
+		query.MustNot = append(query.MustNot, c.seenBy(params.UnseenBy)...)
+	}
 	body := map[string]any{
 		"from":             params.From,
 		"size":             params.Size,
 		"track_total_hits": true,
-		"query": map[string]any{
-			"bool": boolQuery,
-		},
+		"query":            query.Source(),
+	}
+	if params.PIT != nil {
+		withPointInTime(body, params.PIT)
 	}
 
 	sortField := params.Sort
@@ -185,51 +383,76 @@ func (c *Client) SearchNews(ctx context.Context, params SearchParams) (*SearchRe
 		sortField = "timestamp:desc"
 	}
 
-	parts := strings.Split(sortField, ":")
-	order := "desc"
-	field := parts[0]
-	if field == "" {
-		field = "timestamp"
-	}
-	if len(parts) > 1 && parts[1] != "" {
-		order = parts[1]
+	if isRelevanceSort(sortField) {
+		body["query"] = withPopularity(query, params.Popularity).Source()
+		body["sort"] = []map[string]any{
+			{"_score": map[string]any{"order": "desc"}},
+			{"timestamp": map[string]any{"order": "desc"}},
+		}
+	} else {
+		parts := strings.Split(sortField, ":")
+		order := "desc"
+		field := parts[0]
+		if field == "" {
+			field = "timestamp"
+		}
+		if len(parts) > 1 && parts[1] != "" {
+			order = parts[1]
+		}
+		body["sort"] = []map[string]any{
+			{field: map[string]any{"order": order}},
+		}
 	}
-	body["sort"] = []map[string]any{
-		{field: map[string]any{"order": order}},
+	if params.Cursor != nil {
+		withCursor(body, params.Cursor)
 	}
 
+	return c.runSearch(ctx, body)
+}
+
+// runSearch executes a search request body and decodes hits into documents.
+func (c *Client) runSearch(ctx context.Context, body map[string]any) (*SearchResult, error) {
 	payload, err := json.Marshal(body)
 	if err != nil {
 		return nil, fmt.Errorf("marshal search body: %w", err)
 	}
 
-	res, err := c.es.Search(
+	opts := []func(*esapi.SearchRequest){
 		c.es.Search.WithContext(ctx),
-		c.es.Search.WithIndex(c.index),
 		c.es.Search.WithBody(bytes.NewReader(payload)),
-	)
+	}
+	// A point in time names its index itself.
+	if _, ok := body["pit"]; !ok {
+		opts = append(opts, c.es.Search.WithIndex(c.index))
+	}
+	res, err := c.es.Search(opts...)
 	if err != nil {
-		return nil, fmt.Errorf("search: %w", err)
+		return nil, transportError("search", err)
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		data, _ := io.ReadAll(res.Body)
-		return nil, fmt.Errorf("search failed: %s", strings.TrimSpace(string(data)))
+		return nil, responseError("search", res)
 	}
+	return decodeSearchResult(res.Body)
+}
 
+// decodeSearchResult reads the total and documents of a search response.
+func decodeSearchResult(body io.Reader) (*SearchResult, error) {
 	var parsed struct {
-		Hits struct {
+		PITID string `json:"pit_id"`
+		Hits  struct {
 			Total struct {
 				Value int64 `json:"value"`
 			} `json:"total"`
 			Hits []struct {
 				Source models.NewsDocument `json:"_source"`
+				Sort   json.RawMessage     `json:"sort"`
 			} `json:"hits"`
 		} `json:"hits"`
 	}
 
-	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+	if err := json.NewDecoder(body).Decode(&parsed); err != nil {
 		return nil, fmt.Errorf("decode search response: %w", err)
 	}
 
@@ -238,85 +461,235 @@ func (c *Client) SearchNews(ctx context.Context, params SearchParams) (*SearchRe
 		items = append(items, hit.Source)
 	}
 
-	return &SearchResult{
+	result := &SearchResult{
 		Total: parsed.Hits.Total.Value,
 		Items: items,
-	}, nil
+		PIT:   parsed.PITID,
+	}
+	if hits := parsed.Hits.Hits; len(hits) > 0 {
+		result.SearchAfter = hits[len(hits)-1].Sort
+	}
+	return result, nil
+}
+
+// isRelevanceSort reports whether sort asks for score ordering rather than a field.
+func isRelevanceSort(sort string) bool {
+	field, _, _ := strings.Cut(sort, ":")
+	return field == "relevance" || field == "_score"
 }
 
-// DeleteOlderThan removes documents older than ttl using batched delete-by-query.
-// It loops until a batch returns fewer deleted documents than the requested batchSize.
-func (c *Client) DeleteOlderThan(ctx context.Context, maxAge time.Duration, batchSize int) (int64, error) {
-	if batchSize <= 0 {
-		batchSize = 1000
+// withPopularity wraps query in a function_score that adds engagement signals
+// (repost sightings and clicks) to the text relevance score.
+func withPopularity(query esquery.Query, boost PopularityBoost) esquery.Query {
+	functions := make([]esquery.ScoreFunction, 0, 2)
+	if boost.SeenWeight > 0 {
+		functions = append(functions, esquery.FieldValueFactor{
+			Field:    "seen_count",
+			Factor:   boost.SeenWeight,
+			Modifier: "log1p",
+			Missing:  1,
+		})
+	}
+	if boost.ClickWeight > 0 {
+		functions = append(functions, esquery.FieldValueFactor{
+			Field:    "clicks",
+			Factor:   boost.ClickWeight,
+			Modifier: "log1p",
+		})
+	}
+	if len(functions) == 0 {
+		return query
+	}
+
+	return esquery.FunctionScore{
+		Query:     query,
+		Functions: functions,
+		ScoreMode: "sum",
+		BoostMode: "sum",
 	}
+}
 
-	cutoff := time.Now().Add(-maxAge).UTC().Format(time.RFC3339)
-	totalDeleted := int64(0)
+// searchInto runs a search against index, or the index of the body's point
+// in time, and decodes the raw response into out.
+func (c *Client) searchInto(ctx context.Context, index string, body map[string]any, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal search body: %w", err)
+	}
 
-	for {
-		body := map[string]any{
-			"query": map[string]any{
-				"range": map[string]any{
-					"timestamp": map[string]any{
-						"lte": cutoff,
-					},
-				},
-			},
-		}
+	opts := []func(*esapi.SearchRequest){
+		c.es.Search.WithContext(ctx),
+		c.es.Search.WithBody(bytes.NewReader(payload)),
+	}
+	if _, ok := body["pit"]; !ok {
+		opts = append(opts, c.es.Search.WithIndex(index))
+	}
+	res, err := c.es.Search(opts...)
+	if err != nil {
+		return transportError("search", err)
+	}
+	defer res.Body.Close()
 
-		payload, err := json.Marshal(body)
-		if err != nil {
-			return totalDeleted, fmt.Errorf("marshal delete body: %w", err)
-		}
+	if res.IsError() {
+		return responseError("search", res)
+	}
 
-		res, err := c.es.DeleteByQuery(
-			[]string{c.index},
-			bytes.NewReader(payload),
-			c.es.DeleteByQuery.WithContext(ctx),
-			c.es.DeleteByQuery.WithWaitForCompletion(true),
-			c.es.DeleteByQuery.WithConflicts("proceed"),
-			c.es.DeleteByQuery.WithScrollSize(batchSize),
-		)
-		if err != nil {
-			return totalDeleted, fmt.Errorf("delete by query: %w", err)
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode search response: %w", err)
+	}
+	return nil
+}
+
+// buildQuery translates the filter part of SearchParams into a bool query.
+func buildQuery(params SearchParams) esquery.Bool {
+	var query esquery.Bool
+
+	if params.Query != "" {
+		addTextQuery(&query, params.Query, params.Fuzzy)
+	}
+
+	if len(params.Keywords) > 0 {
+		query.Filter = append(query.Filter, esquery.Terms{Field: "keywords", Values: params.Keywords})
+	}
+
+	if params.Source != "" {
+		query.Filter = append(query.Filter, esquery.Term{Field: "source", Value: params.Source})
+	}
+
+	if params.Destination != "" {
+		query.Filter = append(query.Filter, esquery.Term{Field: "destinations", Value: params.Destination})
+	}
+
+	if params.Mention != "" {
+		query.Filter = append(query.Filter, esquery.Term{Field: "mentions", Value: params.Mention})
+	}
+
+	for _, presence := range []struct {
+		field    string
+		required bool
+	}{
+		{"price", params.HasPrice},
+		{"urls", params.HasURL},
+		{"travel_start", params.HasTravelDates},
+	} {
+		if presence.required {
+			query.Filter = append(query.Filter, esquery.Exists{Field: presence.field})
 		}
+	}
 
-		if res.IsError() {
-			data, _ := io.ReadAll(res.Body)
-			res.Body.Close()
-			return totalDeleted, fmt.Errorf("delete by query failed: %s", strings.TrimSpace(string(data)))
+	if params.MinPrice > 0 || params.MaxPrice > 0 {
+		priceRange := esquery.Range{Field: "price"}
+		if params.MinPrice > 0 {
+			priceRange.GTE = params.MinPrice
 		}
+		if params.MaxPrice > 0 {
+			priceRange.LTE = params.MaxPrice
+		}
+		query.Filter = append(query.Filter, priceRange)
+	}
+	if params.MinQuality > 0 {
+		query.Filter = append(query.Filter, esquery.Range{Field: "quality", GTE: params.MinQuality})
+	}
 
-		var parsed struct {
-			Deleted int64 `json:"deleted"`
+	if params.Start != nil || params.End != nil {
+		timeRange := esquery.Range{Field: "timestamp"}
+		if params.Start != nil {
+			timeRange.GTE = params.Start.UTC().Format(time.RFC3339)
 		}
-		if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
-			res.Body.Close()
-			return totalDeleted, fmt.Errorf("decode delete response: %w", err)
+		if params.End != nil {
+			timeRange.LTE = params.End.UTC().Format(time.RFC3339)
 		}
-		res.Body.Close()
+		query.Filter = append(query.Filter, timeRange)
+	}
 
-		totalDeleted += parsed.Deleted
+	if len(params.BoostKeywords) > 0 {
+		for _, b := range params.BoostKeywords {
+			query.Should = append(query.Should, esquery.Term{Field: "keywords", Value: b.Keyword, Boost: b.Weight})
+		}
+		// Boosts only score; without this a filter-only query would require one of them.
+		none := 0
+		query.MinimumShouldMatch = &none
+	}
+	if params.ActiveOnly {
+		query.MustNot = append(query.MustNot,
+			esquery.Range{Field: "expires_at", LTE: "now"},
+			esquery.Term{Field: "status", Value: models.StatusExpired},
+		)
+	}
+	if len(query.Must) == 0 && len(query.Filter) == 0 {
+		query.Must = []esquery.Query{esquery.MatchAll{}}
+	}
+
+	return query
+}
 
-		if parsed.Deleted < int64(batchSize) {
-			break
+// textFields are searched by the full-text terms of SearchParams.Query.
+var textFields = []string{"title^2", "text", "text_clean", "keyword_text"}
+
+// queryFields maps the field:value names of the query syntax onto
+// document fields.
+var queryFields = map[string]string{
+	"source":      "source",
+	"destination": "destinations",
+	"keyword":     "keywords",
+	"mention":     "mentions",
+}
+
+// addTextQuery adds the clauses of q, written in the querylang syntax, to
+// query. Optional words are matched together, like a plain q always was;
+// a q that does not parse is searched as plain text.
+func addTextQuery(query *esquery.Bool, q string, fuzzy bool) {
+	parsed, err := querylang.Parse(q)
+	if err != nil {
+		parsed = querylang.Query{Terms: []querylang.Term{{Value: q}}}
+	}
+
+	var words []string
+	for _, term := range parsed.Terms {
+		if term.Field != "" {
+			clause := esquery.Term{Field: queryFields[term.Field], Value: term.Value}
+			if term.Op == querylang.Excluded {
+				query.MustNot = append(query.MustNot, clause)
+			} else {
+				query.Filter = append(query.Filter, clause)
+			}
+			continue
+		}
+
+		switch {
+		case term.Op == querylang.Excluded:
+			query.MustNot = append(query.MustNot, textMatch(term.Value, term.Phrase, false))
+		case term.Op == querylang.Required || term.Phrase:
+			query.Must = append(query.Must, textMatch(term.Value, term.Phrase, fuzzy))
+		default:
+			words = append(words, term.Value)
 		}
 	}
+	if len(words) > 0 {
+		query.Must = append(query.Must, textMatch(strings.Join(words, " "), false, fuzzy))
+	}
+}
 
-	return totalDeleted, nil
+func textMatch(text string, phrase, fuzzy bool) esquery.MultiMatch {
+	match := esquery.MultiMatch{Query: text, Fields: textFields}
+	switch {
+	case phrase:
+		match.Type = "phrase"
+	case fuzzy:
+		match.Fuzziness = "AUTO"
+	}
+	return match
 }
 
 // Health pings Elasticsearch to ensure connectivity.
 func (c *Client) Health(ctx context.Context) error {
 	res, err := c.es.Cluster.Health(c.es.Cluster.Health.WithContext(ctx))
 	if err != nil {
-		return err
+		return transportError("cluster health", err)
 	}
 	defer res.Body.Close()
 	if res.StatusCode >= http.StatusBadRequest {
-		data, _ := io.ReadAll(res.Body)
-		return fmt.Errorf("cluster health bad: %s", strings.TrimSpace(string(data)))
+		return responseError("cluster health", res)
 	}
 	return nil
 }
@@ -0,0 +1,442 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+)
+
+// day is the length of a daily aggregate.
+const day = 24 * time.Hour
+
+// dailyFields are the document fields counted per day. Aggregate documents
+// carry the counted term in the field of the same name, so the queries for
+// documents and for aggregates share their terms aggregations.
+var dailyFields = []string{"keywords", "destinations"}
+
+var dailyAggregatesMapping = map[string]any{
+	"dynamic": false,
+	"properties": map[string]any{
+		"timestamp":    map[string]any{"type": "date"},
+		"keywords":     map[string]any{"type": "keyword"},
+		"destinations": map[string]any{"type": "keyword"},
+		"count":        map[string]any{"type": "long"},
+	},
+}
+
+// DailyCount is the number of documents published on Day, a UTC midnight,
+// that carry Term in Field, "keywords" or "destinations".
+type DailyCount struct {
+	Day   time.Time
+	Field string
+	Term  string
+	Count int64
+}
+
+// DailyAggregatesIndex stores the per-day keyword and destination counts
+// materialized by the analytics service. Long trend windows read them
+// instead of aggregating documents, and they outlive retention.
+func (c *Client) DailyAggregatesIndex() string {
+	return c.index + "_daily"
+}
+
+// EnsureDailyAggregatesIndex creates the daily aggregates index when it does not exist.
+func (c *Client) EnsureDailyAggregatesIndex(ctx context.Context) error {
+	res, err := c.es.Indices.Exists([]string{c.DailyAggregatesIndex()}, c.es.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return transportError("check daily aggregates index", err)
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	payload, err := json.Marshal(map[string]any{"mappings": dailyAggregatesMapping})
+	if err != nil {
+		return fmt.Errorf("marshal daily aggregates index body: %w", err)
+	}
+	res, err = c.es.Indices.Create(c.DailyAggregatesIndex(), c.es.Indices.Create.WithContext(ctx), c.es.Indices.Create.WithBody(bytes.NewReader(payload)))
+	if err != nil {
+		return transportError("create daily aggregates index", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		err := responseError("create daily aggregates index", res)
+		if strings.Contains(err.Error(), "resource_already_exists_exception") {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// DailyTermCounts counts the documents of the UTC day starting at d that
+// carry each of the size most frequent keywords and destinations.
+func (c *Client) DailyTermCounts(ctx context.Context, d time.Time, size int) ([]DailyCount, error) {
+	d = d.UTC().Truncate(day)
+	aggs := make(map[string]any, len(dailyFields))
+	for _, field := range dailyFields {
+		aggs[field] = map[string]any{"terms": map[string]any{"field": field, "size": size}}
+	}
+	body := map[string]any{
+		"size": 0,
+		"query": esquery.Range{
+			Field: "timestamp",
+			GTE:   d.Format(time.RFC3339),
+			LT:    d.Add(day).Format(time.RFC3339),
+		}.Source(),
+		"aggs": aggs,
+	}
+
+	var parsed struct {
+		Aggregations map[string]termsAggregation `json:"aggregations"`
+	}
+	if err := c.searchInto(ctx, c.index, body, &parsed); err != nil {
+		return nil, err
+	}
+
+	var counts []DailyCount
+	for _, field := range dailyFields {
+		for _, tc := range parsed.Aggregations[field].counts() {
+			counts = append(counts, DailyCount{Day: d, Field: field, Term: tc.Term, Count: tc.Count})
+		}
+	}
+	return counts, nil
+}
+
+// ReplaceDailyCounts swaps the stored counts of the UTC day starting at d
+// for counts, so terms that dropped out of the day are removed. Every
+// count has a fixed ID per day, field and term, so the new counts are
+// written over the old ones first and only the terms missing from counts
+// are deleted afterwards: readers never see the day half empty, and a
+// failed write leaves the old counts in place.
+func (c *Client) ReplaceDailyCounts(ctx context.Context, d time.Time, counts []DailyCount) error {
+	d = d.UTC().Truncate(day)
+	index := c.DailyAggregatesIndex()
+
+	ids := make([]string, len(counts))
+	for i, dc := range counts {
+		ids[i] = dc.Field + "|" + d.Format(time.DateOnly) + "|" + dc.Term
+	}
+	if len(counts) > 0 {
+		if err := c.storeDailyCounts(ctx, d, counts, ids); err != nil {
+			return err
+		}
+	}
+
+	stale := esquery.Bool{
+		Filter: []esquery.Query{esquery.Term{Field: "timestamp", Value: d.Format(time.RFC3339)}},
+	}
+	if len(ids) > 0 {
+		stale.MustNot = []esquery.Query{esquery.Terms{Field: "_id", Values: ids}}
+	}
+	query, err := json.Marshal(map[string]any{"query": stale.Source()})
+	if err != nil {
+		return fmt.Errorf("marshal daily counts delete body: %w", err)
+	}
+	res, err := c.es.DeleteByQuery(
+		[]string{index},
+		bytes.NewReader(query),
+		c.es.DeleteByQuery.WithContext(ctx),
+		c.es.DeleteByQuery.WithConflicts("proceed"),
+		c.es.DeleteByQuery.WithRefresh(true),
+		c.es.DeleteByQuery.WithIgnoreUnavailable(true),
+		c.es.DeleteByQuery.WithAllowNoIndices(true),
+	)
+	if err != nil {
+		return transportError("delete stale daily counts", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return responseError("delete stale daily counts", res)
+	}
+	return nil
+}
+
+// storeDailyCounts indexes counts of the day d under ids.
+func (c *Client) storeDailyCounts(ctx context.Context, d time.Time, counts []DailyCount, ids []string) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i, dc := range counts {
+		if err := enc.Encode(map[string]any{"index": map[string]any{"_index": c.DailyAggregatesIndex(), "_id": ids[i]}}); err != nil {
+			return fmt.Errorf("encode bulk action: %w", err)
+		}
+		if err := enc.Encode(map[string]any{"timestamp": d, dc.Field: dc.Term, "count": dc.Count}); err != nil {
+			return fmt.Errorf("encode daily count: %w", err)
+		}
+	}
+
+	bulk, err := c.es.Bulk(&buf, c.es.Bulk.WithContext(ctx), c.es.Bulk.WithRefresh("true"))
+	if err != nil {
+		return transportError("store daily counts", err)
+	}
+	defer bulk.Body.Close()
+	if bulk.IsError() {
+		return responseError("store daily counts", bulk)
+	}
+
+	var parsed struct {
+		Errors bool `json:"errors"`
+	}
+	if err := json.NewDecoder(bulk.Body).Decode(&parsed); err != nil {
+		return fmt.Errorf("decode bulk response: %w", err)
+	}
+	if parsed.Errors {
+		return fmt.Errorf("store daily counts: bulk request reported item errors")
+	}
+	return nil
+}
+
+// LatestDailyCount returns the most recent day with stored counts, or the
+// zero time when there is none.
+func (c *Client) LatestDailyCount(ctx context.Context) (time.Time, error) {
+	body := map[string]any{
+		"size": 0,
+		"aggs": map[string]any{"latest": map[string]any{"max": map[string]any{"field": "timestamp"}}},
+	}
+
+	var parsed struct {
+		Aggregations struct {
+			Latest struct {
+				Value *float64 `json:"value"`
+			} `json:"latest"`
+		} `json:"aggregations"`
+	}
+	if err := c.searchInto(ctx, c.DailyAggregatesIndex(), body, &parsed); err != nil {
+		// The index only appears after the first analytics run.
+		if errors.Is(err, ErrNotFound) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	if parsed.Aggregations.Latest.Value == nil {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(int64(*parsed.Aggregations.Latest.Value)).UTC(), nil
+}
+
+// Filtered reports whether params narrow the documents by anything but
+// their time range. Daily aggregates only answer unfiltered questions.
+func (p SearchParams) Filtered() bool {
+	return p.Query != "" || len(p.Keywords) > 0 || p.Source != "" || p.Destination != "" || p.Mention != "" ||
+		p.ActiveOnly || p.HasPrice || p.HasURL || p.HasTravelDates || p.MinPrice > 0 || p.MaxPrice > 0 || p.MinQuality > 0 || p.UnseenBy != ""
+}
+
+// countSum counts a bucket's documents so that an aggregate document
+// counts as many as it stands for and a news document as one.
+var countSum = map[string]any{"sum": map[string]any{"field": "count", "missing": 1}}
+
+type sumValue struct {
+	Value float64 `json:"value"`
+}
+
+// withAggregates searches documents with news before cutoff replaced by
+// the aggregate documents matching daily. It spans the news and the daily
+// aggregates index, so the caller must search both.
+func (c *Client) withAggregates(news esquery.Query, cutoff time.Time, daily ...esquery.Query) esquery.Query {
+	// With daily indices news documents come from several indexes, so they
+	// are told apart from aggregates by what they are not.
+	fromNews := esquery.Bool{
+		Filter: []esquery.Query{
+			esquery.Range{Field: "timestamp", GTE: cutoff.UTC().Format(time.RFC3339)},
+			news,
+		},
+		MustNot: []esquery.Query{esquery.Term{Field: "_index", Value: c.DailyAggregatesIndex()}},
+	}
+	fromDaily := append([]esquery.Query{
+		esquery.Term{Field: "_index", Value: c.DailyAggregatesIndex()},
+		esquery.Range{Field: "timestamp", LT: cutoff.UTC().Format(time.RFC3339)},
+	}, daily...)
+	return esquery.Bool{Should: []esquery.Query{
+		fromNews,
+		esquery.Bool{Filter: fromDaily},
+	}}
+}
+
+// searchAggregatedInto searches the news and the daily aggregates index,
+// tolerating a daily aggregates index that does not exist yet.
+func (c *Client) searchAggregatedInto(ctx context.Context, body map[string]any, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal search body: %w", err)
+	}
+
+	res, err := c.es.Search(
+		c.es.Search.WithContext(ctx),
+		c.es.Search.WithIndex(c.index, c.DailyAggregatesIndex()),
+		c.es.Search.WithIgnoreUnavailable(true),
+		c.es.Search.WithBody(bytes.NewReader(payload)),
+	)
+	if err != nil {
+		return transportError("search", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return responseError("search", res)
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode search response: %w", err)
+	}
+	return nil
+}
+
+// aggregatedKeywordTrends is KeywordTrends for windows partly before
+// opts.AggregatedBefore: days before it are counted from the daily
+// aggregates, the rest from documents. Windows should be whole UTC days.
+func (c *Client) aggregatedKeywordTrends(ctx context.Context, opts TrendOptions, size int) (*TrendResult, error) {
+	start, end := opts.Start.UTC(), opts.End.UTC()
+	previousStart := start.Add(-end.Sub(start))
+	news := buildQuery(SearchParams{Start: &previousStart, End: &end})
+
+	window := func(from, to time.Time) map[string]any {
+		return esquery.Range{Field: "timestamp", GTE: from.Format(time.RFC3339), LT: to.Format(time.RFC3339)}.Source()
+	}
+	body := map[string]any{
+		"size": 0,
+		"query": c.withAggregates(news, opts.AggregatedBefore,
+			esquery.Range{Field: "timestamp", GTE: previousStart.Format(time.RFC3339)},
+			esquery.Exists{Field: "keywords"},
+		).Source(),
+		"aggs": map[string]any{
+			"keywords": map[string]any{
+				"terms": map[string]any{"field": "keywords", "size": size, "order": map[string]any{"current>n": "desc"}},
+				"aggs": map[string]any{
+					"current": map[string]any{
+						"filter": window(start, end),
+						"aggs": map[string]any{
+							"n": countSum,
+							"histogram": map[string]any{
+								"date_histogram": map[string]any{
+									"field":             "timestamp",
+									"calendar_interval": trendIntervals["day"],
+									"min_doc_count":     0,
+									"extended_bounds":   map[string]any{"min": start.UnixMilli(), "max": end.Add(-time.Millisecond).UnixMilli()},
+								},
+								"aggs": map[string]any{"n": countSum},
+							},
+						},
+					},
+					"previous": map[string]any{
+						"filter": window(previousStart, start),
+						"aggs":   map[string]any{"n": countSum},
+					},
+				},
+			},
+		},
+	}
+
+	var parsed struct {
+		Aggregations struct {
+			Keywords struct {
+				Buckets []struct {
+					Key     string `json:"key"`
+					Current struct {
+						N         sumValue `json:"n"`
+						Histogram struct {
+							Buckets []struct {
+								Key int64    `json:"key"`
+								N   sumValue `json:"n"`
+							} `json:"buckets"`
+						} `json:"histogram"`
+					} `json:"current"`
+					Previous struct {
+						N sumValue `json:"n"`
+					} `json:"previous"`
+				} `json:"buckets"`
+			} `json:"keywords"`
+		} `json:"aggregations"`
+	}
+	if err := c.searchAggregatedInto(ctx, body, &parsed); err != nil {
+		return nil, err
+	}
+
+	result := &TrendResult{
+		Interval:      opts.Interval,
+		Start:         start,
+		End:           end,
+		PreviousStart: previousStart,
+		Keywords:      []KeywordTrend{},
+	}
+	for _, b := range parsed.Aggregations.Keywords.Buckets {
+		count, previous := int64(b.Current.N.Value), int64(b.Previous.N.Value)
+		if count == 0 {
+			continue
+		}
+		trend := KeywordTrend{
+			Keyword:  b.Key,
+			Count:    count,
+			Previous: previous,
+			Rising:   RisingScore(count, previous),
+			Buckets:  make([]TrendBucket, 0, len(b.Current.Histogram.Buckets)),
+		}
+		for _, h := range b.Current.Histogram.Buckets {
+			trend.Buckets = append(trend.Buckets, TrendBucket{Time: time.UnixMilli(h.Key).UTC(), Count: int64(h.N.Value)})
+		}
+		result.Keywords = append(result.Keywords, trend)
+	}
+	return result, nil
+}
+
+// KeywordTimeline counts the documents carrying any of keywords per
+// interval ("hour" or "day") between opts.Start and opts.End. With
+// opts.AggregatedBefore set, days before it are counted from the daily
+// aggregates; a document carrying several of keywords then counts once
+// per keyword on those days. opts.Size is ignored.
+func (c *Client) KeywordTimeline(ctx context.Context, keywords []string, opts TrendOptions) ([]TrendBucket, error) {
+	start, end := opts.Start.UTC(), opts.End.UTC()
+	if opts.AggregatedBefore.IsZero() {
+		return c.NewsHistogram(ctx, SearchParams{Keywords: keywords, Start: &start, End: &end}, opts.Interval)
+	}
+	if opts.Interval != "day" {
+		return nil, &StatusError{Op: "keyword timeline", Kind: ErrBadRequest, Err: errors.New("daily aggregates need the day interval")}
+	}
+
+	news := buildQuery(SearchParams{Keywords: keywords, Start: &start, End: &end})
+	body := map[string]any{
+		"size": 0,
+		"query": c.withAggregates(news, opts.AggregatedBefore,
+			esquery.Range{Field: "timestamp", GTE: start.Format(time.RFC3339)},
+			esquery.Terms{Field: "keywords", Values: keywords},
+		).Source(),
+		"aggs": map[string]any{
+			"histogram": map[string]any{
+				"date_histogram": map[string]any{
+					"field":             "timestamp",
+					"calendar_interval": trendIntervals["day"],
+					"min_doc_count":     0,
+					"extended_bounds":   map[string]any{"min": start.UnixMilli(), "max": end.Add(-time.Millisecond).UnixMilli()},
+				},
+				"aggs": map[string]any{"n": countSum},
+			},
+		},
+	}
+
+	var parsed struct {
+		Aggregations struct {
+			Histogram struct {
+				Buckets []struct {
+					Key int64    `json:"key"`
+					N   sumValue `json:"n"`
+				} `json:"buckets"`
+			} `json:"histogram"`
+		} `json:"aggregations"`
+	}
+	if err := c.searchAggregatedInto(ctx, body, &parsed); err != nil {
+		return nil, err
+	}
+
+	buckets := make([]TrendBucket, 0, len(parsed.Aggregations.Histogram.Buckets))
+	for _, b := range parsed.Aggregations.Histogram.Buckets {
+		buckets = append(buckets, TrendBucket{Time: time.UnixMilli(b.Key).UTC(), Count: int64(b.N.Value)})
+	}
+	return buckets, nil
+}
@@ -0,0 +1,172 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDailyTermCounts(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/news/_search", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"aggregations": {
+			"keywords": {"buckets": [{"key": "турция", "doc_count": 12}]},
+			"destinations": {"buckets": [{"key": "Анталья", "doc_count": 5}]}
+		}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	d := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	counts, err := client.DailyTermCounts(context.Background(), d.Add(15*time.Hour), 100)
+	require.NoError(t, err)
+	require.Equal(t, []DailyCount{
+		{Day: d, Field: "keywords", Term: "турция", Count: 12},
+		{Day: d, Field: "destinations", Term: "Анталья", Count: 5},
+	}, counts)
+	require.Equal(t, map[string]any{"range": map[string]any{"timestamp": map[string]any{
+		"gte": "2024-06-01T00:00:00Z",
+		"lt":  "2024-06-02T00:00:00Z",
+	}}}, body["query"])
+}
+
+func TestReplaceDailyCounts(t *testing.T) {
+	var (
+		paths     []string
+		docs      []map[string]any
+		deleted   map[string]any
+		bulkError bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/_bulk" {
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				var line map[string]any
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+				docs = append(docs, line)
+			}
+			if bulkError {
+				_, _ = io.WriteString(w, `{"errors": true, "items": []}`)
+				return
+			}
+			_, _ = io.WriteString(w, `{"errors": false, "items": []}`)
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&deleted))
+		_, _ = io.WriteString(w, `{"deleted": 3}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	d := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	err = client.ReplaceDailyCounts(context.Background(), d, []DailyCount{
+		{Day: d, Field: "destinations", Term: "Анталья", Count: 5},
+		{Day: d, Field: "keywords", Term: "горящий", Count: 2},
+	})
+	require.NoError(t, err)
+
+	// The counts are written over the old ones before the terms that
+	// dropped out of the day are deleted.
+	require.Equal(t, []string{"/_bulk", "/news_daily/_delete_by_query"}, paths)
+	require.Equal(t, []map[string]any{
+		{"index": map[string]any{"_index": "news_daily", "_id": "destinations|2024-06-01|Анталья"}},
+		{"timestamp": "2024-06-01T00:00:00Z", "destinations": "Анталья", "count": float64(5)},
+		{"index": map[string]any{"_index": "news_daily", "_id": "keywords|2024-06-01|горящий"}},
+		{"timestamp": "2024-06-01T00:00:00Z", "keywords": "горящий", "count": float64(2)},
+	}, docs)
+	require.Equal(t, map[string]any{"query": map[string]any{"bool": map[string]any{
+		"filter":   []any{map[string]any{"term": map[string]any{"timestamp": "2024-06-01T00:00:00Z"}}},
+		"must_not": []any{map[string]any{"terms": map[string]any{"_id": []any{"destinations|2024-06-01|Анталья", "keywords|2024-06-01|горящий"}}}},
+	}}}, deleted)
+
+	// A day without counts is cleared.
+	paths, deleted = nil, nil
+	require.NoError(t, client.ReplaceDailyCounts(context.Background(), d, nil))
+	require.Equal(t, []string{"/news_daily/_delete_by_query"}, paths)
+	require.Equal(t, map[string]any{"query": map[string]any{"bool": map[string]any{
+		"filter": []any{map[string]any{"term": map[string]any{"timestamp": "2024-06-01T00:00:00Z"}}},
+	}}}, deleted)
+
+	// When the write fails the old counts are kept.
+	paths, bulkError = nil, true
+	err = client.ReplaceDailyCounts(context.Background(), d, []DailyCount{{Day: d, Field: "keywords", Term: "горящий", Count: 2}})
+	require.ErrorContains(t, err, "store daily counts")
+	require.Equal(t, []string{"/_bulk"}, paths)
+}
+
+func TestAggregatedKeywordTrends(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/news,news_daily/_search", r.URL.Path)
+		require.Equal(t, "true", r.URL.Query().Get("ignore_unavailable"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"aggregations": {"keywords": {"buckets": [
+			{"key": "турция", "doc_count": 4,
+				"current": {"doc_count": 3, "n": {"value": 40}, "histogram": {"buckets": [
+					{"key": 1717200000000, "doc_count": 1, "n": {"value": 30}},
+					{"key": 1717286400000, "doc_count": 2, "n": {"value": 10}}
+				]}},
+				"previous": {"doc_count": 1, "n": {"value": 20}}}
+		]}}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(2 * day)
+	result, err := client.KeywordTrends(context.Background(), SearchParams{}, TrendOptions{
+		Interval:         "day",
+		Start:            start,
+		End:              end,
+		Size:             10,
+		AggregatedBefore: start.Add(day),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []KeywordTrend{{
+		Keyword:  "турция",
+		Count:    40,
+		Previous: 20,
+		Rising:   RisingScore(40, 20),
+		Buckets: []TrendBucket{
+			{Time: start, Count: 30},
+			{Time: start.Add(day), Count: 10},
+		},
+	}}, result.Keywords)
+
+	// Documents count from the cutoff on, aggregates before it.
+	should := body["query"].(map[string]any)["bool"].(map[string]any)["should"].([]any)
+	require.Len(t, should, 2)
+	daily := should[1].(map[string]any)["bool"].(map[string]any)["filter"].([]any)
+	require.Contains(t, daily, map[string]any{"term": map[string]any{"_index": "news_daily"}})
+	require.Contains(t, daily, map[string]any{"range": map[string]any{"timestamp": map[string]any{"lt": "2024-06-02T00:00:00Z"}}})
+
+	_, err = client.KeywordTrends(context.Background(), SearchParams{Source: "telegram"}, TrendOptions{
+		Interval:         "day",
+		Start:            start,
+		End:              end,
+		AggregatedBefore: start.Add(day),
+	})
+	require.ErrorIs(t, err, ErrBadRequest)
+}
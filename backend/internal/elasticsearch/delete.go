@@ -0,0 +1,227 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+)
+
+// taskPollInterval is how often a running delete-by-query task is checked.
+const taskPollInterval = 2 * time.Second
+
+// DeleteProgress reports how far a delete-by-query task has got.
+type DeleteProgress struct {
+	Task    string
+	Total   int64
+	Deleted int64
+	Batches int64
+}
+
+// DeleteOption adjusts a delete-by-query run.
+type DeleteOption func(*deleteOptions)
+
+type deleteOptions struct {
+	progress func(DeleteProgress)
+}
+
+// ReportProgress calls fn after every poll of the running task. Deletions
+// are applied batch by batch, so a run that is cancelled midway keeps the
+// progress it made and the next run picks up the remaining documents.
+func ReportProgress(fn func(DeleteProgress)) DeleteOption {
+	return func(o *deleteOptions) {
+		o.progress = fn
+	}
+}
+
+// DeleteOlderThan removes documents older than maxAge with a delete-by-query
+// task. Age is measured on field: "timestamp" (when the post was published)
+// or "indexed_at" (when the worker ingested it).
+func (c *Client) DeleteOlderThan(ctx context.Context, field string, maxAge time.Duration, batchSize int, opts ...DeleteOption) (int64, error) {
+	return c.DeleteByQuery(ctx, OlderThan(field, time.Now().Add(-maxAge)), batchSize, opts...)
+}
+
+// DeleteExpired removes documents whose expires_at lies before cutoff, regardless of their age.
+func (c *Client) DeleteExpired(ctx context.Context, cutoff time.Time, batchSize int, opts ...DeleteOption) (int64, error) {
+	return c.DeleteByQuery(ctx, ExpiredBefore(cutoff), batchSize, opts...)
+}
+
+// OlderThan matches documents whose field lies before cutoff. Documents
+// indexed before indexed_at existed fall back to their timestamp.
+func OlderThan(field string, cutoff time.Time) esquery.Query {
+	bound := cutoff.UTC().Format(time.RFC3339)
+	var query esquery.Query = esquery.Range{Field: field, LTE: bound}
+	if field == "indexed_at" {
+		one := 1
+		query = esquery.Bool{
+			Should: []esquery.Query{
+				query,
+				esquery.Bool{
+					MustNot: []esquery.Query{esquery.Exists{Field: "indexed_at"}},
+					Filter:  []esquery.Query{esquery.Range{Field: "timestamp", LTE: bound}},
+				},
+			},
+			MinimumShouldMatch: &one,
+		}
+	}
+	return query
+}
+
+// ExpiredBefore matches documents whose expires_at lies before cutoff.
+func ExpiredBefore(cutoff time.Time) esquery.Query {
+	return esquery.Range{Field: "expires_at", LTE: cutoff.UTC().Format(time.RFC3339)}
+}
+
+// DeleteByQuery submits delete-by-query as a background task and polls it
+// until it finishes. If ctx ends first the task is cancelled server-side
+// instead of being left running.
+func (c *Client) DeleteByQuery(ctx context.Context, query esquery.Query, batchSize int, opts ...DeleteOption) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	var o deleteOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	payload, err := json.Marshal(map[string]any{"query": query.Source()})
+	if err != nil {
+		return 0, fmt.Errorf("marshal delete body: %w", err)
+	}
+
+	res, err := c.es.DeleteByQuery(
+		[]string{c.index},
+		bytes.NewReader(payload),
+		c.es.DeleteByQuery.WithContext(ctx),
+		c.es.DeleteByQuery.WithWaitForCompletion(false),
+		c.es.DeleteByQuery.WithConflicts("proceed"),
+		c.es.DeleteByQuery.WithScrollSize(batchSize),
+	)
+	if err != nil {
+		return 0, transportError("delete by query", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, responseError("delete by query", res)
+	}
+
+	var submitted struct {
+		Task string `json:"task"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&submitted); err != nil {
+		return 0, fmt.Errorf("decode delete response: %w", err)
+	}
+	if submitted.Task == "" {
+		return 0, fmt.Errorf("delete by query: response carries no task id")
+	}
+
+	ticker := time.NewTicker(taskPollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := c.deleteTaskStatus(ctx, submitted.Task)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.cancelTask(submitted.Task)
+			}
+			return 0, err
+		}
+		if o.progress != nil {
+			o.progress(status.progress)
+		}
+		if status.completed {
+			if status.failure != "" {
+				return status.progress.Deleted, fmt.Errorf("delete by query task %s: %s", submitted.Task, status.failure)
+			}
+			return status.progress.Deleted, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			c.cancelTask(submitted.Task)
+			return status.progress.Deleted, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+type deleteTaskStatus struct {
+	completed bool
+	progress  DeleteProgress
+	failure   string
+}
+
+func (c *Client) deleteTaskStatus(ctx context.Context, taskID string) (deleteTaskStatus, error) {
+	res, err := c.es.Tasks.Get(taskID, c.es.Tasks.Get.WithContext(ctx))
+	if err != nil {
+		return deleteTaskStatus{}, transportError("get task", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return deleteTaskStatus{}, responseError("get task", res)
+	}
+
+	var parsed struct {
+		Completed bool `json:"completed"`
+		Task      struct {
+			Status struct {
+				Total   int64 `json:"total"`
+				Deleted int64 `json:"deleted"`
+				Batches int64 `json:"batches"`
+			} `json:"status"`
+		} `json:"task"`
+		Response struct {
+			Failures []json.RawMessage `json:"failures"`
+		} `json:"response"`
+		Error *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return deleteTaskStatus{}, fmt.Errorf("decode task response: %w", err)
+	}
+
+	status := deleteTaskStatus{
+		completed: parsed.Completed,
+		progress: DeleteProgress{
+			Task:    taskID,
+			Total:   parsed.Task.Status.Total,
+			Deleted: parsed.Task.Status.Deleted,
+			Batches: parsed.Task.Status.Batches,
+		},
+	}
+	switch {
+	case parsed.Error != nil:
+		status.failure = parsed.Error.Type + ": " + parsed.Error.Reason
+	case len(parsed.Response.Failures) > 0:
+		status.failure = fmt.Sprintf("%d failures, first: %s", len(parsed.Response.Failures), parsed.Response.Failures[0])
+	}
+	return status, nil
+}
+
+// cancelTask stops a server-side task on a best-effort basis. It runs on its
+// own short deadline because the caller's context is usually already done.
+func (c *Client) cancelTask(taskID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := c.es.Tasks.Cancel(
+		c.es.Tasks.Cancel.WithContext(ctx),
+		c.es.Tasks.Cancel.WithTaskID(taskID),
+	)
+	if err != nil {
+		c.log.Warn("cancel elasticsearch task", slog.String("task", taskID), slog.Any("err", err))
+		return
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		c.log.Warn("cancel elasticsearch task", slog.String("task", taskID), slog.Int("status", res.StatusCode))
+	}
+}
@@ -0,0 +1,113 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTaskServer answers delete-by-query submissions with a task that
+// reports the given completion state.
+func fakeTaskServer(t *testing.T, taskBody string) (*Client, func() []string) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/news/_delete_by_query":
+			// FailNow must not be called outside the test goroutine.
+			assert.Equal(t, "false", r.URL.Query().Get("wait_for_completion"))
+			_, _ = io.WriteString(w, `{"task":"node:42"}`)
+		case "/_tasks/node:42":
+			_, _ = io.WriteString(w, taskBody)
+		case "/_tasks/node:42/_cancel":
+			_, _ = io.WriteString(w, `{"nodes":{}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+	return client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), calls...)
+	}
+}
+
+func TestDeleteOlderThanPollsTask(t *testing.T) {
+	client, _ := fakeTaskServer(t, `{"completed":true,"task":{"status":{"total":7,"deleted":7,"batches":1}},"response":{"deleted":7,"failures":[]}}`)
+
+	var progress []DeleteProgress
+	deleted, err := client.DeleteOlderThan(context.Background(), "indexed_at", time.Hour, 100, ReportProgress(func(p DeleteProgress) {
+		progress = append(progress, p)
+	}))
+	require.NoError(t, err)
+	require.Equal(t, int64(7), deleted)
+	require.Equal(t, []DeleteProgress{{Task: "node:42", Total: 7, Deleted: 7, Batches: 1}}, progress)
+}
+
+func TestDeleteOlderThanCancelsTaskOnContextEnd(t *testing.T) {
+	client, calls := fakeTaskServer(t, `{"completed":false,"task":{"status":{"total":1000,"deleted":200,"batches":2}}}`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	deleted, err := client.DeleteOlderThan(ctx, "timestamp", time.Hour, 100)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, int64(200), deleted)
+	require.Contains(t, calls(), "POST /_tasks/node:42/_cancel")
+}
+
+func TestDeleteOlderThanIndexedAtFallsBackToTimestamp(t *testing.T) {
+	var query map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/news/_delete_by_query" {
+			var body struct {
+				Query map[string]any `json:"query"`
+			}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			query = body.Query
+			_, _ = io.WriteString(w, `{"task":"node:1"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"completed":true,"response":{"deleted":0}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	_, err = client.DeleteOlderThan(context.Background(), "indexed_at", time.Hour, 100)
+	require.NoError(t, err)
+	should := query["bool"].(map[string]any)["should"].([]any)
+	require.Len(t, should, 2)
+	require.Contains(t, should[0].(map[string]any)["range"], "indexed_at")
+	legacy := should[1].(map[string]any)["bool"].(map[string]any)
+	require.Equal(t, []any{map[string]any{"exists": map[string]any{"field": "indexed_at"}}}, legacy["must_not"])
+	require.Contains(t, legacy["filter"].([]any)[0].(map[string]any)["range"], "timestamp")
+
+	_, err = client.DeleteOlderThan(context.Background(), "timestamp", time.Hour, 100)
+	require.NoError(t, err)
+	require.Contains(t, query["range"], "timestamp")
+}
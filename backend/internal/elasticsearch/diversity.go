@@ -0,0 +1,66 @@
+package elasticsearch
+
+import (
+	"context"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// maxDiversityWindow is the most hits searchDiverse fetches to lay out its
+// pages; pages past them come back empty.
+const maxDiversityWindow = 1000
+
+// searchDiverse serves a page in which no source has more than
+// params.MaxPerSource hits. Elasticsearch's field collapsing keeps a single
+// hit per source, so instead the leading hits are fetched in the requested
+// order and laid out in pages of params.Size by diversify, and the page at
+// params.From is cut from them. Total still counts every match.
+func (c *Client) searchDiverse(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	from, size, perSource := params.From, params.Size, params.MaxPerSource
+	// Capped sources push their surplus to later pages, so the pages up to
+	// the requested one need more hits than they hold.
+	params.From, params.Size = 0, min(max(5*(from+size), 100), maxDiversityWindow)
+
+	result, err := c.searchPage(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	items := diversify(result.Items, perSource, size, from+size)
+	result.Items = items[min(from, len(items)):]
+	result.SearchAfter = nil
+	return result, nil
+}
+
+// diversify lays hits out in pages of size, each holding at most perSource
+// hits of one source, and returns the first limit of them. Every page takes
+// the earliest hits that fit, so hits keep their order within a source and
+// a capped source's surplus moves to the next pages. A page that runs out
+// of other sources is filled up with the surplus, so pages stay full and no
+// hit is lost.
+func diversify(hits []models.NewsDocument, perSource, size, limit int) []models.NewsDocument {
+	laid := make([]models.NewsDocument, 0, min(limit, len(hits)))
+	for len(hits) > 0 && len(laid) < limit {
+		counts := make(map[string]int)
+		var deferred []models.NewsDocument
+		placed := 0
+		for i, hit := range hits {
+			if placed == size {
+				deferred = append(deferred, hits[i:]...)
+				break
+			}
+			if counts[hit.Source] >= perSource {
+				deferred = append(deferred, hit)
+				continue
+			}
+			counts[hit.Source]++
+			laid = append(laid, hit)
+			placed++
+		}
+		if fill := min(size-placed, len(deferred)); fill > 0 {
+			laid = append(laid, deferred[:fill]...)
+			deferred = deferred[fill:]
+		}
+		hits = deferred
+	}
+	return laid[:min(limit, len(laid))]
+}
@@ -0,0 +1,30 @@
+package elasticsearch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func TestDiversify(t *testing.T) {
+	var hits []models.NewsDocument
+	for _, id := range []string{"a1", "a2", "a3", "a4", "b1", "a5", "c1", "a6"} {
+		hits = append(hits, models.NewsDocument{ID: id, Source: id[:1]})
+	}
+	ids := func(docs []models.NewsDocument) []string {
+		out := make([]string, 0, len(docs))
+		for _, doc := range docs {
+			out = append(out, doc.ID)
+		}
+		return out
+	}
+
+	// The first page gets two of a and the next hits of other sources; the
+	// surplus of a opens the second page and fills it once b and c ran out.
+	require.Equal(t, []string{"a1", "a2", "b1", "c1", "a3", "a4", "a5", "a6"}, ids(diversify(hits, 2, 4, 10)))
+	require.Equal(t, []string{"a1", "a2", "b1", "c1", "a3"}, ids(diversify(hits, 2, 4, 5)))
+	require.Equal(t, []string{"a1", "b1", "c1", "a2", "a3", "a4"}, ids(diversify(hits, 1, 3, 6)))
+	require.Empty(t, diversify(nil, 1, 3, 6))
+}
@@ -0,0 +1,96 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// GetNewsByID fetches a single document. It returns an error wrapping
+// ErrNotFound when no document has that ID.
+func (c *Client) GetNewsByID(ctx context.Context, id string) (*models.NewsDocument, error) {
+	if c.daily {
+		docs, err := c.searchByIDs(ctx, []string{id})
+		if err != nil {
+			return nil, err
+		}
+		if docs[0] == nil {
+			return nil, &StatusError{Op: "get doc", Status: http.StatusNotFound, Kind: ErrNotFound}
+		}
+		return docs[0], nil
+	}
+
+	res, err := c.es.Get(c.index, id, c.es.Get.WithContext(ctx))
+	if err != nil {
+		return nil, transportError("get doc", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, responseError("get doc", res)
+	}
+
+	var parsed struct {
+		Found  bool                `json:"found"`
+		Source models.NewsDocument `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode get response: %w", err)
+	}
+	if !parsed.Found {
+		return nil, &StatusError{Op: "get doc", Status: res.StatusCode, Kind: ErrNotFound}
+	}
+
+	return &parsed.Source, nil
+}
+
+// MultiGetNews fetches several documents with one request. The result is
+// aligned with ids and holds nil for every ID without a document.
+func (c *Client) MultiGetNews(ctx context.Context, ids []string) ([]*models.NewsDocument, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if c.daily {
+		return c.searchByIDs(ctx, ids)
+	}
+
+	payload, err := json.Marshal(map[string]any{"ids": ids})
+	if err != nil {
+		return nil, fmt.Errorf("marshal mget body: %w", err)
+	}
+
+	res, err := c.es.Mget(bytes.NewReader(payload), c.es.Mget.WithContext(ctx), c.es.Mget.WithIndex(c.index))
+	if err != nil {
+		return nil, transportError("mget docs", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, responseError("mget docs", res)
+	}
+
+	var parsed struct {
+		Docs []struct {
+			Found  bool                `json:"found"`
+			Source models.NewsDocument `json:"_source"`
+		} `json:"docs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode mget response: %w", err)
+	}
+	if len(parsed.Docs) != len(ids) {
+		return nil, fmt.Errorf("mget docs: got %d results for %d ids", len(parsed.Docs), len(ids))
+	}
+
+	docs := make([]*models.NewsDocument, len(ids))
+	for i, d := range parsed.Docs {
+		if d.Found {
+			docs[i] = &d.Source
+		}
+	}
+	return docs, nil
+}
@@ -0,0 +1,42 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiGetNewsKeepsOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/news/_mget", r.URL.Path)
+		var body struct {
+			IDs []string `json:"ids"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, []string{"b", "missing", "a"}, body.IDs)
+
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"docs":[
+			{"_id":"b","found":true,"_source":{"id":"b","title":"Второй"}},
+			{"_id":"missing","found":false},
+			{"_id":"a","found":true,"_source":{"id":"a","title":"Первый"}}
+		]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	docs, err := client.MultiGetNews(context.Background(), []string{"b", "missing", "a"})
+	require.NoError(t, err)
+	require.Len(t, docs, 3)
+	require.Equal(t, "Второй", docs[0].Title)
+	require.Nil(t, docs[1])
+	require.Equal(t, "a", docs[2].ID)
+}
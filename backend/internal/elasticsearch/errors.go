@@ -0,0 +1,95 @@
+package elasticsearch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Error kinds returned by Client methods. Use errors.Is to branch on them;
+// the concrete error is a *StatusError carrying the HTTP status and an
+// excerpt of the response body.
+var (
+	ErrNotFound    = errors.New("elasticsearch: not found")
+	ErrConflict    = errors.New("elasticsearch: conflict")
+	ErrBadRequest  = errors.New("elasticsearch: bad request")
+	ErrUnavailable = errors.New("elasticsearch: unavailable")
+)
+
+const maxErrorBody = 512
+
+// StatusError describes a failed Elasticsearch call. Status is zero when the
+// request never got a response, in which case Err holds the transport error.
+type StatusError struct {
+	Op     string
+	Status int
+	Body   string
+	Kind   error
+	Err    error
+}
+
+func (e *StatusError) Error() string {
+	if e.Status == 0 {
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	}
+	if e.Body == "" {
+		return fmt.Sprintf("%s failed: %d %s", e.Op, e.Status, http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("%s failed: %d %s", e.Op, e.Status, e.Body)
+}
+
+func (e *StatusError) Unwrap() []error {
+	errs := make([]error, 0, 2)
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// IsRetryable reports whether err is worth retrying later, i.e. the cluster
+// was unreachable, overloaded or failed internally.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrUnavailable)
+}
+
+// responseError converts an error response into a *StatusError, consuming the body.
+func responseError(op string, res *esapi.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody+1))
+	body := strings.TrimSpace(string(data))
+	if len(body) > maxErrorBody {
+		body = strings.ToValidUTF8(body[:maxErrorBody], "") + "…"
+	}
+	return &StatusError{
+		Op:     op,
+		Status: res.StatusCode,
+		Body:   body,
+		Kind:   kindForStatus(res.StatusCode),
+	}
+}
+
+// transportError wraps a failure to talk to the cluster at all.
+func transportError(op string, err error) error {
+	return &StatusError{Op: op, Kind: ErrUnavailable, Err: err}
+}
+
+func kindForStatus(status int) error {
+	switch {
+	case status == http.StatusNotFound:
+		return ErrNotFound
+	case status == http.StatusConflict:
+		return ErrConflict
+	case status == http.StatusBadRequest || status == http.StatusRequestEntityTooLarge:
+		return ErrBadRequest
+	case status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError:
+		return ErrUnavailable
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,54 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseErrorKinds(t *testing.T) {
+	tests := []struct {
+		status int
+		kind   error
+	}{
+		{status: http.StatusNotFound, kind: ErrNotFound},
+		{status: http.StatusConflict, kind: ErrConflict},
+		{status: http.StatusBadRequest, kind: ErrBadRequest},
+		{status: http.StatusTooManyRequests, kind: ErrUnavailable},
+		{status: http.StatusServiceUnavailable, kind: ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			res := &esapi.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader(`{"error":"x"}`))}
+			err := responseError("search", res)
+			require.ErrorIs(t, err, tt.kind)
+
+			var statusErr *StatusError
+			require.True(t, errors.As(err, &statusErr))
+			require.Equal(t, tt.status, statusErr.Status)
+			require.Equal(t, `{"error":"x"}`, statusErr.Body)
+			require.Equal(t, tt.kind == ErrUnavailable, IsRetryable(err))
+		})
+	}
+}
+
+func TestResponseErrorTruncatesBody(t *testing.T) {
+	res := &esapi.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(strings.NewReader(strings.Repeat("a", 2000)))}
+	var statusErr *StatusError
+	require.True(t, errors.As(responseError("search", res), &statusErr))
+	require.Len(t, []rune(statusErr.Body), maxErrorBody+1)
+}
+
+func TestTransportErrorIsRetryable(t *testing.T) {
+	err := transportError("index doc", context.DeadlineExceeded)
+	require.True(t, IsRetryable(err))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.EqualError(t, err, "index doc: context deadline exceeded")
+}
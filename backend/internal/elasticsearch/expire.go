@@ -0,0 +1,117 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// maxRepliedOffers bounds how many documents a reply expires; copies of a
+// post share its link.
+const maxRepliedOffers = 10
+
+// OriginalQuery identifies the offer a sold-out notice refers to.
+type OriginalQuery struct {
+	// ReplyTo is the link of the post the notice replies to. Documents
+	// carrying it among their urls are the original.
+	ReplyTo string
+	// Without a reply, the most similar document of the notice's source
+	// published since Since is the original: it must contain most words of
+	// Text, and sharing one of Links ranks it higher.
+	Links []string
+	Text  string
+	Since time.Time
+}
+
+// ExpireOriginals marks the offers notice refers to as expired and returns
+// their IDs. Offers that are already expired are left alone.
+func (c *Client) ExpireOriginals(ctx context.Context, notice models.NewsDocument, original OriginalQuery) ([]string, error) {
+	query := esquery.Bool{
+		MustNot: []esquery.Query{
+			esquery.Term{Field: "id", Value: notice.ID},
+			esquery.Term{Field: "status", Value: models.StatusExpired},
+		},
+	}
+	size := maxRepliedOffers
+	switch {
+	case original.ReplyTo != "":
+		query.Filter = append(query.Filter, esquery.Term{Field: "urls", Value: original.ReplyTo})
+	case original.Text != "":
+		size = 1
+		query.Must = append(query.Must, esquery.MultiMatch{
+			Query:              original.Text,
+			Fields:             []string{"title", "text_clean"},
+			Type:               "cross_fields",
+			MinimumShouldMatch: "60%",
+		})
+		query.Filter = append(query.Filter,
+			esquery.Term{Field: "source", Value: notice.Source},
+			esquery.Range{Field: "timestamp", GTE: original.Since.UTC().Format(time.RFC3339), LTE: notice.Timestamp.UTC().Format(time.RFC3339)},
+		)
+		if len(original.Links) > 0 {
+			query.Should = append(query.Should, esquery.Terms{Field: "urls", Values: original.Links})
+		}
+	default:
+		return nil, nil
+	}
+
+	var parsed struct {
+		Hits struct {
+			Hits []struct {
+				ID    string `json:"_id"`
+				Index string `json:"_index"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	body := map[string]any{"size": size, "_source": false, "query": query.Source()}
+	if err := c.searchInto(ctx, c.index, body, &parsed); err != nil {
+		return nil, err
+	}
+	if len(parsed.Hits.Hits) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	ids := make([]string, 0, len(parsed.Hits.Hits))
+	for _, hit := range parsed.Hits.Hits {
+		ids = append(ids, hit.ID)
+		meta := map[string]any{"_id": hit.ID, "retry_on_conflict": 3}
+		if c.daily {
+			// The bulk request's index is the daily indices alias.
+			meta["_index"] = hit.Index
+		}
+		if err := enc.Encode(map[string]any{"update": meta}); err != nil {
+			return nil, fmt.Errorf("encode bulk action: %w", err)
+		}
+		if err := enc.Encode(map[string]any{"doc": map[string]any{"status": models.StatusExpired, "expired_by": notice.ID}}); err != nil {
+			return nil, fmt.Errorf("encode expiry of %s: %w", hit.ID, err)
+		}
+	}
+
+	res, err := c.es.Bulk(&buf, c.es.Bulk.WithContext(ctx), c.es.Bulk.WithIndex(c.index))
+	if err != nil {
+		return nil, transportError("expire offers", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, responseError("expire offers", res)
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode bulk response: %w", err)
+	}
+	if result.Errors {
+		return nil, errors.New("expire offers: bulk request reported item errors")
+	}
+	return ids, nil
+}
@@ -0,0 +1,94 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func TestExpireOriginals(t *testing.T) {
+	var (
+		searches  []map[string]any
+		bulkLines []map[string]any
+		hits      = `[{"_id":"offer-1"}]`
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/news/_search":
+			var body map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			searches = append(searches, body)
+			_, _ = io.WriteString(w, `{"hits":{"hits":`+hits+`}}`)
+		case "/news/_bulk":
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				var line map[string]any
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+				bulkLines = append(bulkLines, line)
+			}
+			_, _ = io.WriteString(w, `{"errors":false,"items":[]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+	ts := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	notice := models.NewsDocument{ID: "notice", Source: "telegram", Timestamp: ts}
+
+	ids, err := client.ExpireOriginals(ctx, notice, OriginalQuery{ReplyTo: "https://t.me/hottours/42", Text: "кемер"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"offer-1"}, ids)
+	// A reply identifies the original by its link alone.
+	query := searches[0]["query"].(map[string]any)["bool"].(map[string]any)
+	require.Equal(t, []any{map[string]any{"term": map[string]any{"urls": "https://t.me/hottours/42"}}}, query["filter"])
+	require.NotContains(t, query, "must")
+	require.Equal(t, []any{
+		map[string]any{"term": map[string]any{"id": "notice"}},
+		map[string]any{"term": map[string]any{"status": "expired"}},
+	}, query["must_not"])
+	require.Equal(t, []map[string]any{
+		{"update": map[string]any{"_id": "offer-1", "retry_on_conflict": float64(3)}},
+		{"doc": map[string]any{"status": "expired", "expired_by": "notice"}},
+	}, bulkLines)
+
+	// Without a reply the most similar recent post of the source is taken.
+	_, err = client.ExpireOriginals(ctx, notice, OriginalQuery{Text: "турция кемер", Links: []string{"https://example.com/t/1"}, Since: ts.Add(-72 * time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, float64(1), searches[1]["size"])
+	query = searches[1]["query"].(map[string]any)["bool"].(map[string]any)
+	require.Equal(t, []any{
+		map[string]any{"term": map[string]any{"source": "telegram"}},
+		map[string]any{"range": map[string]any{"timestamp": map[string]any{"gte": "2024-06-07T12:00:00Z", "lte": "2024-06-10T12:00:00Z"}}},
+	}, query["filter"])
+	match := query["must"].([]any)[0].(map[string]any)["multi_match"].(map[string]any)
+	require.Equal(t, "60%", match["minimum_should_match"])
+	require.Equal(t, []any{map[string]any{"terms": map[string]any{"urls": []any{"https://example.com/t/1"}}}}, query["should"])
+
+	// Nothing to go by, or nothing found: no update.
+	bulkLines = nil
+	ids, err = client.ExpireOriginals(ctx, notice, OriginalQuery{Links: []string{"https://example.com/t/1"}})
+	require.NoError(t, err)
+	require.Empty(t, ids)
+	require.Len(t, searches, 2)
+
+	hits = `[]`
+	ids, err = client.ExpireOriginals(ctx, notice, OriginalQuery{Text: "египет"})
+	require.NoError(t, err)
+	require.Empty(t, ids)
+	require.Empty(t, bulkLines)
+}
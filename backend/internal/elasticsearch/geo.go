@@ -0,0 +1,131 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// MaxGeohashPrecision is the finest geohash_grid precision, cells of
+// about 4 by 2 centimetres.
+const MaxGeohashPrecision = 12
+
+// maxMapCells bounds the cells one map request returns.
+const maxMapCells = 5000
+
+// BoundingBox is the area of a map view, in degrees. A box whose Left lies
+// east of its Right crosses the antimeridian.
+type BoundingBox struct {
+	Top, Left, Bottom, Right float64
+}
+
+// GeoCell counts the documents placed in one geohash cell.
+type GeoCell struct {
+	Geohash string `json:"geohash"`
+	Count   int64  `json:"count"`
+	// Center is the middle of the cell, where a map puts its marker.
+	Center models.GeoPoint `json:"center"`
+}
+
+// MapClusters is the geohash grid of the documents located in a box.
+type MapClusters struct {
+	// Total counts the documents with a location in the box; one placed at
+	// several destinations is counted once here and in each of their cells.
+	Total     int64     `json:"total"`
+	Precision int       `json:"precision"`
+	Cells     []GeoCell `json:"cells"`
+}
+
+// ClusterNewsOnMap counts the documents matching params that are located
+// inside box per geohash cell of the given precision, from 1 (continents)
+// to MaxGeohashPrecision. Documents are located at their most specific
+// destinations; those without a located destination are left out. Sort
+// and pagination of params are ignored.
+func (c *Client) ClusterNewsOnMap(ctx context.Context, params SearchParams, box BoundingBox, precision int) (*MapClusters, error) {
+	precision = min(max(precision, 1), MaxGeohashPrecision)
+
+	query := buildQuery(params)
+	query.Filter = append(query.Filter, esquery.GeoBoundingBox{Field: "locations", Top: box.Top, Left: box.Left, Bottom: box.Bottom, Right: box.Right})
+	body := map[string]any{
+		"size":             0,
+		"track_total_hits": true,
+		"query":            query.Source(),
+		"aggs": map[string]any{
+			"cells": map[string]any{
+				"geohash_grid": map[string]any{
+					"field":     "locations",
+					"precision": precision,
+					"size":      maxMapCells,
+					// A document placed both inside and outside the box
+					// would otherwise add cells outside it.
+					"bounds": map[string]any{
+						"top_left":     map[string]any{"lat": box.Top, "lon": box.Left},
+						"bottom_right": map[string]any{"lat": box.Bottom, "lon": box.Right},
+					},
+				},
+			},
+		},
+	}
+
+	var parsed struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+		Aggregations struct {
+			Cells termsAggregation `json:"cells"`
+		} `json:"aggregations"`
+	}
+	if err := c.searchInto(ctx, c.index, body, &parsed); err != nil {
+		return nil, err
+	}
+
+	result := &MapClusters{
+		Total:     parsed.Hits.Total.Value,
+		Precision: precision,
+		Cells:     make([]GeoCell, 0, len(parsed.Aggregations.Cells.Buckets)),
+	}
+	for _, bucket := range parsed.Aggregations.Cells.Buckets {
+		center, err := geohashCenter(bucket.Key)
+		if err != nil {
+			return nil, err
+		}
+		result.Cells = append(result.Cells, GeoCell{Geohash: bucket.Key, Count: bucket.DocCount, Center: center})
+	}
+	return result, nil
+}
+
+// geohashAlphabet is the base32 alphabet of geohashes.
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// geohashCenter returns the middle of a geohash cell. Each character holds
+// five bits that alternately halve the longitude and latitude ranges,
+// starting with the longitude.
+func geohashCenter(hash string) (models.GeoPoint, error) {
+	lat, lon := [2]float64{-90, 90}, [2]float64{-180, 180}
+	even := true
+	for _, r := range hash {
+		bits := strings.IndexRune(geohashAlphabet, r)
+		if bits < 0 {
+			return models.GeoPoint{}, fmt.Errorf("invalid geohash %q", hash)
+		}
+		for mask := 16; mask > 0; mask >>= 1 {
+			span := &lat
+			if even {
+				span = &lon
+			}
+			mid := (span[0] + span[1]) / 2
+			if bits&mask != 0 {
+				span[0] = mid
+			} else {
+				span[1] = mid
+			}
+			even = !even
+		}
+	}
+	return models.GeoPoint{Lat: (lat[0] + lat[1]) / 2, Lon: (lon[0] + lon[1]) / 2}, nil
+}
@@ -0,0 +1,68 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func TestClusterNewsOnMap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/news/_search", r.URL.Path)
+		var body struct {
+			Size  int                        `json:"size"`
+			Query map[string]json.RawMessage `json:"query"`
+			Aggs  struct {
+				Cells struct {
+					GeohashGrid struct {
+						Field     string         `json:"field"`
+						Precision int            `json:"precision"`
+						Bounds    map[string]any `json:"bounds"`
+					} `json:"geohash_grid"`
+				} `json:"cells"`
+			} `json:"aggs"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Zero(t, body.Size)
+		require.Contains(t, string(body.Query["bool"]), `"geo_bounding_box":{"locations":{"bottom_right":{"lat":20,"lon":40},"top_left":{"lat":45,"lon":25}}}`)
+		require.Contains(t, string(body.Query["bool"]), `"destinations"`)
+		require.Equal(t, "locations", body.Aggs.Cells.GeohashGrid.Field)
+		require.Equal(t, 3, body.Aggs.Cells.GeohashGrid.Precision)
+		require.Contains(t, body.Aggs.Cells.GeohashGrid.Bounds, "top_left")
+
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{
+			"hits": {"total": {"value": 9}},
+			"aggregations": {"cells": {"buckets": [{"key": "swt", "doc_count": 7}, {"key": "sub", "doc_count": 2}]}}
+		}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	box := BoundingBox{Top: 45, Left: 25, Bottom: 20, Right: 40}
+	result, err := client.ClusterNewsOnMap(context.Background(), SearchParams{Destination: "ближний восток"}, box, 3)
+	require.NoError(t, err)
+	require.Equal(t, &MapClusters{Total: 9, Precision: 3, Cells: []GeoCell{
+		{Geohash: "swt", Count: 7, Center: models.GeoPoint{Lat: 37.265625, Lon: 30.234375}},
+		{Geohash: "sub", Count: 2, Center: models.GeoPoint{Lat: 27.421875, Lon: 34.453125}},
+	}}, result)
+}
+
+func TestGeohashCenter(t *testing.T) {
+	center, err := geohashCenter("u")
+	require.NoError(t, err)
+	require.Equal(t, models.GeoPoint{Lat: 67.5, Lon: 22.5}, center)
+
+	_, err = geohashCenter("a")
+	require.Error(t, err)
+}
@@ -0,0 +1,197 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// ingestErrorsMapping keeps the excerpt in _source only; records are found
+// by their exact fields and the error message.
+var ingestErrorsMapping = map[string]any{
+	"dynamic": false,
+	"properties": map[string]any{
+		"topic":       map[string]any{"type": "keyword"},
+		"partition":   map[string]any{"type": "integer"},
+		"offset":      map[string]any{"type": "long"},
+		"source":      map[string]any{"type": "keyword"},
+		"error_class": map[string]any{"type": "keyword"},
+		"error":       map[string]any{"type": "text"},
+		"timestamp":   map[string]any{"type": "date"},
+	},
+}
+
+// IngestErrorsIndex stores a record of every message the worker failed to index.
+func (c *Client) IngestErrorsIndex() string {
+	return c.index + "_ingest_errors"
+}
+
+// EnsureIngestErrorsIndex creates the ingest errors index when it does not exist.
+func (c *Client) EnsureIngestErrorsIndex(ctx context.Context) error {
+	res, err := c.es.Indices.Exists([]string{c.IngestErrorsIndex()}, c.es.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return transportError("check ingest errors index", err)
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	payload, err := json.Marshal(map[string]any{"mappings": ingestErrorsMapping})
+	if err != nil {
+		return fmt.Errorf("marshal ingest errors index body: %w", err)
+	}
+	res, err = c.es.Indices.Create(c.IngestErrorsIndex(), c.es.Indices.Create.WithContext(ctx), c.es.Indices.Create.WithBody(bytes.NewReader(payload)))
+	if err != nil {
+		return transportError("create ingest errors index", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		err := responseError("create ingest errors index", res)
+		// Another worker may have created it in the meantime.
+		if strings.Contains(err.Error(), "resource_already_exists_exception") {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// RecordIngestErrors stores records with one bulk request.
+func (c *Client) RecordIngestErrors(ctx context.Context, records []models.IngestError) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(map[string]any{"index": map[string]any{"_index": c.IngestErrorsIndex()}}); err != nil {
+			return fmt.Errorf("encode bulk action: %w", err)
+		}
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("encode ingest error: %w", err)
+		}
+	}
+
+	res, err := c.es.Bulk(&buf, c.es.Bulk.WithContext(ctx))
+	if err != nil {
+		return transportError("record ingest errors", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError("record ingest errors", res)
+	}
+
+	var parsed struct {
+		Errors bool `json:"errors"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return fmt.Errorf("decode bulk response: %w", err)
+	}
+	if parsed.Errors {
+		return errors.New("record ingest errors: bulk request reported item errors")
+	}
+	return nil
+}
+
+// IngestErrorFilter selects ingest error records; empty fields match all.
+type IngestErrorFilter struct {
+	ErrorClass string
+	Source     string
+	Topic      string
+	// Query matches words of the error message.
+	Query string
+	Start *time.Time
+	End   *time.Time
+	Size  int
+}
+
+// IngestErrorResult holds the newest matching records and, over all of
+// them, counts per error class and per source.
+type IngestErrorResult struct {
+	Total        int64                `json:"total"`
+	Items        []models.IngestError `json:"items"`
+	ErrorClasses []TermCount          `json:"error_classes"`
+	Sources      []TermCount          `json:"sources"`
+}
+
+// IngestErrors searches the ingest error records, newest first.
+func (c *Client) IngestErrors(ctx context.Context, filter IngestErrorFilter) (*IngestErrorResult, error) {
+	var query esquery.Bool
+	for _, term := range []esquery.Term{
+		{Field: "error_class", Value: filter.ErrorClass},
+		{Field: "source", Value: filter.Source},
+		{Field: "topic", Value: filter.Topic},
+	} {
+		if term.Value != "" {
+			query.Filter = append(query.Filter, term)
+		}
+	}
+	if filter.Query != "" {
+		query.Must = append(query.Must, esquery.MultiMatch{Query: filter.Query, Fields: []string{"error"}})
+	}
+	if filter.Start != nil || filter.End != nil {
+		r := esquery.Range{Field: "timestamp"}
+		if filter.Start != nil {
+			r.GTE = filter.Start.UTC().Format(time.RFC3339)
+		}
+		if filter.End != nil {
+			r.LTE = filter.End.UTC().Format(time.RFC3339)
+		}
+		query.Filter = append(query.Filter, r)
+	}
+
+	body := map[string]any{
+		"size":             filter.Size,
+		"track_total_hits": true,
+		"query":            query.Source(),
+		"sort":             []map[string]any{{"timestamp": map[string]any{"order": "desc"}}},
+		"aggs": map[string]any{
+			"error_classes": map[string]any{"terms": map[string]any{"field": "error_class", "size": 50}},
+			"sources":       map[string]any{"terms": map[string]any{"field": "source", "size": 50}},
+		},
+	}
+
+	var parsed struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source models.IngestError `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations struct {
+			ErrorClasses termsAggregation `json:"error_classes"`
+			Sources      termsAggregation `json:"sources"`
+		} `json:"aggregations"`
+	}
+	if err := c.searchInto(ctx, c.IngestErrorsIndex(), body, &parsed); err != nil {
+		// The index only appears once a worker has started.
+		if errors.Is(err, ErrNotFound) {
+			return &IngestErrorResult{Items: []models.IngestError{}, ErrorClasses: []TermCount{}, Sources: []TermCount{}}, nil
+		}
+		return nil, err
+	}
+
+	result := &IngestErrorResult{
+		Total:        parsed.Hits.Total.Value,
+		Items:        make([]models.IngestError, 0, len(parsed.Hits.Hits)),
+		ErrorClasses: parsed.Aggregations.ErrorClasses.counts(),
+		Sources:      parsed.Aggregations.Sources.counts(),
+	}
+	for _, hit := range parsed.Hits.Hits {
+		result.Items = append(result.Items, hit.Source)
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func TestIngestErrors(t *testing.T) {
+	var (
+		bulkLines []map[string]any
+		search    map[string]any
+		missing   bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/_bulk":
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				var line map[string]any
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+				bulkLines = append(bulkLines, line)
+			}
+			_, _ = io.WriteString(w, `{"errors":false,"items":[]}`)
+		case "/news_ingest_errors/_search":
+			if missing {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = io.WriteString(w, `{"error":{"type":"index_not_found_exception"},"status":404}`)
+				return
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&search))
+			_, _ = io.WriteString(w, `{
+				"hits":{"total":{"value":7},"hits":[{"_source":{"topic":"news_raw","source":"rss","error_class":"decode","error":"truncated JSON","timestamp":"2024-06-01T10:00:00Z"}}]},
+				"aggregations":{
+					"error_classes":{"buckets":[{"key":"decode","doc_count":5},{"key":"es_bad_request","doc_count":2}]},
+					"sources":{"buckets":[{"key":"rss","doc_count":7}]}
+				}
+			}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, client.RecordIngestErrors(ctx, []models.IngestError{{Topic: "news_raw", ErrorClass: "decode", Error: "truncated JSON"}}))
+	require.Len(t, bulkLines, 2)
+	require.Equal(t, map[string]any{"index": map[string]any{"_index": "news_ingest_errors"}}, bulkLines[0])
+	require.Equal(t, "decode", bulkLines[1]["error_class"])
+
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	result, err := client.IngestErrors(ctx, IngestErrorFilter{ErrorClass: "decode", Query: "truncated", Start: &start, Size: 20})
+	require.NoError(t, err)
+	require.Equal(t, int64(7), result.Total)
+	require.Equal(t, "truncated JSON", result.Items[0].Error)
+	require.Equal(t, []TermCount{{Term: "decode", Count: 5}, {Term: "es_bad_request", Count: 2}}, result.ErrorClasses)
+	require.Equal(t, []TermCount{{Term: "rss", Count: 7}}, result.Sources)
+
+	query := search["query"].(map[string]any)["bool"].(map[string]any)
+	require.Equal(t, []any{
+		map[string]any{"term": map[string]any{"error_class": "decode"}},
+		map[string]any{"range": map[string]any{"timestamp": map[string]any{"gte": "2024-06-01T00:00:00Z"}}},
+	}, query["filter"])
+	require.Equal(t, float64(20), search["size"])
+
+	// Before any worker created the index there is simply nothing to show.
+	missing = true
+	result, err = client.IngestErrors(ctx, IngestErrorFilter{Size: 20})
+	require.NoError(t, err)
+	require.Zero(t, result.Total)
+	require.Empty(t, result.Items)
+}
@@ -0,0 +1,295 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// dailyLayout is the date suffix of daily indices, as in news-2024.06.01.
+const dailyLayout = "2006.01.02"
+
+// EnableDailyIndices keeps news documents in one index per UTC day,
+// <index>-YYYY.MM.DD, chosen by each document's indexed_at. The client's
+// index name becomes an alias over all of them, which every read goes
+// through, and retention drops whole days with DeleteIndex instead of
+// deleting documents one by one. It must be called before the client is
+// used.
+func (c *Client) EnableDailyIndices() {
+	c.daily = true
+}
+
+// DailyIndex is one day of news documents.
+type DailyIndex struct {
+	Name string
+	// Day is the UTC midnight the day starts at.
+	Day time.Time
+}
+
+// dailyIndex returns the daily index of documents indexed at t.
+func (c *Client) dailyIndex(t time.Time) string {
+	return c.index + "-" + t.UTC().Format(dailyLayout)
+}
+
+// ensureDailyIndices registers the index template that gives every daily
+// index the news settings, mapping and read alias, creates today's index
+// so the alias resolves before the first write, and adds fields introduced
+// since to the existing ones.
+func (c *Client) ensureDailyIndices(ctx context.Context) error {
+	payload, err := json.Marshal(map[string]any{
+		// Only date suffixes match: ingestion rules may route documents to
+		// indexes such as news-quarantine, which must stay out of the alias.
+		"index_patterns": []string{c.index + "-2*"},
+		"template": map[string]any{
+			"settings": indexSettings,
+			"mappings": newsMapping,
+			"aliases":  map[string]any{c.index: map[string]any{}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal index template: %w", err)
+	}
+	res, err := c.es.Indices.PutIndexTemplate(c.index, bytes.NewReader(payload), c.es.Indices.PutIndexTemplate.WithContext(ctx))
+	if err != nil {
+		return transportError("put index template", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError("put index template", res)
+	}
+
+	today := c.dailyIndex(time.Now())
+	created, err := c.es.Indices.Create(today, c.es.Indices.Create.WithContext(ctx))
+	if err != nil {
+		return transportError("create index", err)
+	}
+	defer created.Body.Close()
+	if created.IsError() {
+		// Another service may have created today's index in the meantime.
+		// Any other error, such as an index named like the alias, which
+		// keeps the alias from being added, is returned.
+		if err := responseError("create index "+today, created); !strings.Contains(err.Error(), "resource_already_exists_exception") {
+			return err
+		}
+	}
+	return c.putMapping(ctx, newsMapping)
+}
+
+// ListDailyIndices returns the daily indices behind the client's alias,
+// oldest first.
+func (c *Client) ListDailyIndices(ctx context.Context) ([]DailyIndex, error) {
+	res, err := c.es.Indices.GetAlias(c.es.Indices.GetAlias.WithContext(ctx), c.es.Indices.GetAlias.WithName(c.index))
+	if err != nil {
+		return nil, transportError("get alias", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, responseError("get alias", res)
+	}
+
+	var parsed map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode alias response: %w", err)
+	}
+	var indices []DailyIndex
+	for name := range parsed {
+		suffix, ok := strings.CutPrefix(name, c.index+"-")
+		if !ok {
+			continue
+		}
+		day, err := time.Parse(dailyLayout, suffix)
+		if err != nil {
+			continue
+		}
+		indices = append(indices, DailyIndex{Name: name, Day: day})
+	}
+	slices.SortFunc(indices, func(a, b DailyIndex) int { return a.Day.Compare(b.Day) })
+	return indices, nil
+}
+
+// DeleteIndex drops a whole index. Retention uses it on daily indices
+// whose every document is past the maximum age.
+func (c *Client) DeleteIndex(ctx context.Context, name string) error {
+	res, err := c.es.Indices.Delete([]string{name}, c.es.Indices.Delete.WithContext(ctx))
+	if err != nil {
+		return transportError("delete index", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError("delete index "+name, res)
+	}
+	return nil
+}
+
+// locate returns the index holding each of ids that exists. With daily
+// indices a document stays in the index it was first written to, so
+// writes and lookups by ID have to find it there; the alias cannot take
+// them as it spans several indices.
+//
+// The search only sees documents refreshed since they were written, so the
+// IDs it misses are looked up again with a realtime multi-get in the
+// indices recent writes went to: the worker stamps indexed_at when it
+// indexes, which puts those in today's or yesterday's index, plus any
+// candidates given, such as the day of a document's own indexed_at.
+// Otherwise a document sent twice within a refresh interval across
+// midnight would be written to two days.
+func (c *Client) locate(ctx context.Context, ids []string, candidates ...string) (map[string]string, error) {
+	var parsed struct {
+		Hits struct {
+			Hits []struct {
+				ID    string `json:"_id"`
+				Index string `json:"_index"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	body := map[string]any{
+		"size":    len(ids),
+		"_source": false,
+		"query":   esquery.Terms{Field: "_id", Values: ids}.Source(),
+	}
+	if err := c.searchInto(ctx, c.index, body, &parsed); err != nil {
+		return nil, err
+	}
+	found := make(map[string]string, len(parsed.Hits.Hits))
+	for _, hit := range parsed.Hits.Hits {
+		found[hit.ID] = hit.Index
+	}
+
+	var missing []string
+	for _, id := range ids {
+		if found[id] == "" && !slices.Contains(missing, id) {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+	now := time.Now()
+	recent := append([]string{c.dailyIndex(now), c.dailyIndex(now.Add(-24 * time.Hour))}, candidates...)
+	slices.Sort(recent)
+	if err := c.locateRealtime(ctx, missing, slices.Compact(recent), found); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// locateRealtime adds to found the index among indices holding each of ids.
+// Gets, unlike searches, are realtime. Indices that do not exist yet are
+// reported per document and count as not holding it.
+func (c *Client) locateRealtime(ctx context.Context, ids, indices []string, found map[string]string) error {
+	type docRef struct {
+		Index  string `json:"_index"`
+		ID     string `json:"_id"`
+		Source bool   `json:"_source"`
+	}
+	refs := make([]docRef, 0, len(ids)*len(indices))
+	for _, index := range indices {
+		for _, id := range ids {
+			refs = append(refs, docRef{Index: index, ID: id})
+		}
+	}
+	payload, err := json.Marshal(map[string]any{"docs": refs})
+	if err != nil {
+		return fmt.Errorf("marshal mget body: %w", err)
+	}
+	res, err := c.es.Mget(bytes.NewReader(payload), c.es.Mget.WithContext(ctx), c.es.Mget.WithRealtime(true))
+	if err != nil {
+		return transportError("locate documents", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError("locate documents", res)
+	}
+
+	var parsed struct {
+		Docs []struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+			Found bool   `json:"found"`
+		} `json:"docs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return fmt.Errorf("decode mget response: %w", err)
+	}
+	for _, doc := range parsed.Docs {
+		if doc.Found && found[doc.ID] == "" {
+			found[doc.ID] = doc.Index
+		}
+	}
+	return nil
+}
+
+// writeIndexes returns the index each of docs is written to: the client's
+// index, or with daily indices the one already holding the document and
+// otherwise the day of its indexed_at.
+func (c *Client) writeIndexes(ctx context.Context, docs ...models.NewsDocument) ([]string, error) {
+	indexes := make([]string, len(docs))
+	if !c.daily {
+		for i := range docs {
+			indexes[i] = c.index
+		}
+		return indexes, nil
+	}
+
+	ids := make([]string, len(docs))
+	var days []string
+	for i, doc := range docs {
+		ids[i] = doc.ID
+		if !doc.IndexedAt.IsZero() {
+			days = append(days, c.dailyIndex(doc.IndexedAt))
+		}
+	}
+	found, err := c.locate(ctx, ids, days...)
+	if err != nil {
+		return nil, fmt.Errorf("locate documents: %w", err)
+	}
+	now := time.Now()
+	for i, doc := range docs {
+		switch {
+		case found[doc.ID] != "":
+			indexes[i] = found[doc.ID]
+		case !doc.IndexedAt.IsZero():
+			indexes[i] = c.dailyIndex(doc.IndexedAt)
+		default:
+			indexes[i] = c.dailyIndex(now)
+		}
+	}
+	return indexes, nil
+}
+
+// searchByIDs fetches documents by ID through the daily indices alias. The
+// result is aligned with ids and holds nil for every ID without a document.
+func (c *Client) searchByIDs(ctx context.Context, ids []string) ([]*models.NewsDocument, error) {
+	var parsed struct {
+		Hits struct {
+			Hits []struct {
+				ID     string              `json:"_id"`
+				Source models.NewsDocument `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	body := map[string]any{"size": len(ids), "query": esquery.Terms{Field: "_id", Values: ids}.Source()}
+	if err := c.searchInto(ctx, c.index, body, &parsed); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.NewsDocument, len(parsed.Hits.Hits))
+	for i := range parsed.Hits.Hits {
+		byID[parsed.Hits.Hits[i].ID] = &parsed.Hits.Hits[i].Source
+	}
+	docs := make([]*models.NewsDocument, len(ids))
+	for i, id := range ids {
+		docs[i] = byID[id]
+	}
+	return docs, nil
+}
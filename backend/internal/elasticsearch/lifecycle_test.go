@@ -0,0 +1,147 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func TestEnsureIndexDaily(t *testing.T) {
+	today := "/news-" + time.Now().UTC().Format(dailyLayout)
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/_index_template/news":
+			var body struct {
+				IndexPatterns []string `json:"index_patterns"`
+				Template      struct {
+					Aliases map[string]any `json:"aliases"`
+				} `json:"template"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.Equal(t, []string{"news-2*"}, body.IndexPatterns)
+			require.Contains(t, body.Template.Aliases, "news")
+			_, _ = io.WriteString(w, `{"acknowledged":true}`)
+		case today:
+			// Another service created it first.
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":{"type":"resource_already_exists_exception"},"status":400}`)
+		default:
+			_, _ = io.WriteString(w, `{"acknowledged":true}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+	client.EnableDailyIndices()
+
+	require.NoError(t, client.EnsureIndex(context.Background()))
+	require.Equal(t, []string{"PUT /_index_template/news", "PUT " + today, "PUT /news/_mapping"}, calls)
+}
+
+func TestBulkIndexNewsDaily(t *testing.T) {
+	now := time.Now().UTC()
+	yesterday := "news-" + now.Add(-24*time.Hour).Format(dailyLayout)
+	var actions []map[string]map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/news/_search":
+			_, _ = io.WriteString(w, `{"hits":{"hits":[{"_id":"b","_index":"news-2024.06.01"}]}}`)
+		case "/_mget":
+			// Documents the search missed are looked up in the recent
+			// indices and the days of their indexed_at.
+			var body struct {
+				Docs []struct {
+					Index string `json:"_index"`
+					ID    string `json:"_id"`
+				} `json:"docs"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.Equal(t, "true", r.URL.Query().Get("realtime"))
+			indices := map[string][]string{}
+			for _, doc := range body.Docs {
+				indices[doc.ID] = append(indices[doc.ID], doc.Index)
+			}
+			want := []string{"news-2024.06.03", "news-" + now.Format(dailyLayout), yesterday}
+			require.ElementsMatch(t, want, indices["a"])
+			require.ElementsMatch(t, want, indices["d"])
+			require.NotContains(t, indices, "b")
+			// d was written moments ago and is not searchable yet.
+			_, _ = io.WriteString(w, `{"docs":[{"_index":"`+yesterday+`","_id":"d","found":true},{"_index":"news-2024.06.03","_id":"a","error":{"type":"index_not_found_exception"}}]}`)
+		case "/news/_bulk":
+			scanner := bufio.NewScanner(r.Body)
+			for line := 0; scanner.Scan(); line++ {
+				if line%2 == 0 {
+					var action map[string]map[string]any
+					require.NoError(t, json.Unmarshal(scanner.Bytes(), &action))
+					actions = append(actions, action)
+				}
+			}
+			_, _ = io.WriteString(w, `{"errors":false,"items":[{"index":{"status":201}},{"update":{"status":200}},{"index":{"status":201}},{"index":{"status":200}}]}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+	client.EnableDailyIndices()
+
+	indexedAt := time.Date(2024, 6, 3, 23, 30, 0, 0, time.FixedZone("MSK", 3*60*60))
+	errs, err := client.BulkIndexNews(context.Background(), []BulkItem{
+		{Doc: models.NewsDocument{ID: "a", IndexedAt: indexedAt}},
+		{Doc: models.NewsDocument{ID: "b", IndexedAt: indexedAt}, Repost: true},
+		{Doc: models.NewsDocument{ID: "c", IndexedAt: indexedAt}, Index: "news-quarantine"},
+		{Doc: models.NewsDocument{ID: "d", IndexedAt: indexedAt}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []error{nil, nil, nil, nil}, errs)
+
+	require.Len(t, actions, 4)
+	// Days are UTC.
+	require.Equal(t, "news-2024.06.03", actions[0]["index"]["_index"])
+	// A known document stays where it was first written.
+	require.Equal(t, "news-2024.06.01", actions[1]["update"]["_index"])
+	require.Equal(t, "news-quarantine", actions[2]["index"]["_index"])
+	require.Equal(t, yesterday, actions[3]["index"]["_index"])
+}
+
+func TestListDailyIndices(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/_alias/news", r.URL.Path)
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{
+			"news-2024.06.02": {"aliases": {"news": {}}},
+			"news-2024.06.01": {"aliases": {"news": {}}},
+			"news-backup": {"aliases": {"news": {}}}
+		}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	indices, err := client.ListDailyIndices(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []DailyIndex{
+		{Name: "news-2024.06.01", Day: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "news-2024.06.02", Day: time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)},
+	}, indices)
+}
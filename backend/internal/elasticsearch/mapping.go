@@ -0,0 +1,195 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// textAnalyzer stems Russian and English and folds ё into е, so "Турцию",
+// "турция" and "Turkey's" match their base forms.
+const textAnalyzer = "ru_en"
+
+var indexSettings = map[string]any{
+	"analysis": map[string]any{
+		"char_filter": map[string]any{
+			"yo": map[string]any{"type": "mapping", "mappings": []string{"ё => е", "Ё => Е"}},
+		},
+		"filter": map[string]any{
+			"russian_stop":    map[string]any{"type": "stop", "stopwords": "_russian_"},
+			"russian_stemmer": map[string]any{"type": "stemmer", "language": "russian"},
+			"english_stop":    map[string]any{"type": "stop", "stopwords": "_english_"},
+			"english_stemmer": map[string]any{"type": "stemmer", "language": "english"},
+		},
+		"analyzer": map[string]any{
+			textAnalyzer: map[string]any{
+				"type":        "custom",
+				"char_filter": []string{"yo"},
+				"tokenizer":   "standard",
+				"filter":      []string{"lowercase", "russian_stop", "english_stop", "russian_stemmer", "english_stemmer"},
+			},
+		},
+	},
+}
+
+// newsMapping gives every NewsDocument field an explicit type: analyzed
+// text for what q searches, keyword for exact filters and aggregations.
+var newsMapping = map[string]any{
+	"properties": map[string]any{
+		"id":           map[string]any{"type": "keyword"},
+		"title":        map[string]any{"type": "text", "analyzer": textAnalyzer},
+		"text":         map[string]any{"type": "text", "analyzer": textAnalyzer},
+		"text_clean":   map[string]any{"type": "text", "analyzer": textAnalyzer},
+		"keyword_text": map[string]any{"type": "text", "analyzer": textAnalyzer},
+		"timestamp":    map[string]any{"type": "date"},
+		"keywords":     map[string]any{"type": "keyword"},
+		// Stored for display only; enabled false keeps its keys, the
+		// keyword stems, out of the mapping.
+		"keyword_forms": map[string]any{"type": "object", "enabled": false},
+		"source":        map[string]any{"type": "keyword"},
+		"urls":          map[string]any{"type": "keyword"},
+		"destinations":  map[string]any{"type": "keyword"},
+		"locations":     map[string]any{"type": "geo_point"},
+		"mentions":      map[string]any{"type": "keyword"},
+		"seen_count":    map[string]any{"type": "integer"},
+		"last_seen":     map[string]any{"type": "date"},
+		"clicks":        map[string]any{"type": "integer"},
+		"travel_start":  map[string]any{"type": "date"},
+		"travel_end":    map[string]any{"type": "date"},
+		"expires_at":    map[string]any{"type": "date"},
+		"status":        map[string]any{"type": "keyword"},
+		"expired_by":    map[string]any{"type": "keyword"},
+		"price":         map[string]any{"type": "integer"},
+		"quality":       map[string]any{"type": "byte"},
+		"cluster_id":    map[string]any{"type": "keyword"},
+		"indexed_at":    map[string]any{"type": "date"},
+	},
+}
+
+// EnsureIndex creates the news index with its analyzer and explicit mapping
+// when it does not exist, and otherwise adds fields introduced since it was
+// created. It is idempotent and safe to call from every service on start.
+//
+// An index created by dynamic mapping cannot take the analyzer and field
+// types; the resulting error wraps ErrBadRequest and the index has to be
+// reindexed into a fresh one.
+//
+// With daily indices it registers their index template instead and
+// updates the mapping of the indices behind the alias.
+func (c *Client) EnsureIndex(ctx context.Context) error {
+	if c.daily {
+		return c.ensureDailyIndices(ctx)
+	}
+	res, err := c.es.Indices.Exists([]string{c.index}, c.es.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return transportError("check index", err)
+	}
+	res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return c.putMapping(ctx, newsMapping)
+	case http.StatusNotFound:
+		return c.createIndex(ctx)
+	default:
+		return &StatusError{Op: "check index", Status: res.StatusCode, Kind: kindForStatus(res.StatusCode)}
+	}
+}
+
+// WaitForIndex calls EnsureIndex until it succeeds, retrying with backoff
+// while Elasticsearch is unavailable, so services neither search nor index
+// into a dynamically mapped index. A mapping that conflicts with the
+// current one is returned as an error wrapping ErrBadRequest: filters on
+// keywords and source only work on the explicit mapping, so the index has
+// to be reindexed before the services can start.
+func (c *Client) WaitForIndex(ctx context.Context) error {
+	return c.waitForIndex(ctx, 2*time.Second)
+}
+
+func (c *Client) waitForIndex(ctx context.Context, retryDelay time.Duration) error {
+	for attempt := 1; ; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := c.EnsureIndex(callCtx)
+		cancel()
+		if errors.Is(err, ErrBadRequest) {
+			return fmt.Errorf("mapping of %s conflicts with the current one, reindex it into a new index: %w", c.index, err)
+		}
+		if err == nil || !errors.Is(err, ErrUnavailable) || attempt == 10 {
+			return err
+		}
+
+		c.log.Warn("elasticsearch not ready, retrying",
+			slog.Any("err", err),
+			slog.Int("attempt", attempt),
+			slog.Duration("retry_in", retryDelay),
+		)
+		select {
+		case <-time.After(retryDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		retryDelay = min(retryDelay*2, 30*time.Second)
+	}
+}
+
+// IndexReady reports whether searches can be served: the cluster answers
+// and the news index exists. A missing index wraps ErrNotFound.
+func (c *Client) IndexReady(ctx context.Context) error {
+	if err := c.Health(ctx); err != nil {
+		return err
+	}
+	res, err := c.es.Indices.Exists([]string{c.index}, c.es.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return transportError("check index", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return &StatusError{Op: "check index " + c.index, Status: res.StatusCode, Kind: kindForStatus(res.StatusCode)}
+	}
+	return nil
+}
+
+func (c *Client) createIndex(ctx context.Context) error {
+	payload, err := json.Marshal(map[string]any{"settings": indexSettings, "mappings": newsMapping})
+	if err != nil {
+		return fmt.Errorf("marshal index body: %w", err)
+	}
+
+	res, err := c.es.Indices.Create(c.index, c.es.Indices.Create.WithContext(ctx), c.es.Indices.Create.WithBody(bytes.NewReader(payload)))
+	if err != nil {
+		return transportError("create index", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		// Another service may have created the index in the meantime.
+		if retryErr := c.putMapping(ctx, newsMapping); retryErr == nil {
+			return nil
+		}
+		return responseError("create index", res)
+	}
+	return nil
+}
+
+func (c *Client) putMapping(ctx context.Context, mapping map[string]any) error {
+	payload, err := json.Marshal(mapping)
+	if err != nil {
+		return fmt.Errorf("marshal mapping: %w", err)
+	}
+
+	res, err := c.es.Indices.PutMapping([]string{c.index}, bytes.NewReader(payload), c.es.Indices.PutMapping.WithContext(ctx))
+	if err != nil {
+		return transportError("put mapping", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return responseError("put mapping", res)
+	}
+	return nil
+}
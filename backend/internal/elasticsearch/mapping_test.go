@@ -0,0 +1,151 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fieldMapping struct {
+	Type     string `json:"type"`
+	Analyzer string `json:"analyzer"`
+}
+
+func TestEnsureIndexCreatesMissingIndex(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method {
+		case http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case http.MethodPut:
+			var body struct {
+				Settings struct {
+					Analysis struct {
+						Analyzer map[string]json.RawMessage `json:"analyzer"`
+					} `json:"analysis"`
+				} `json:"settings"`
+				Mappings struct {
+					Properties map[string]fieldMapping `json:"properties"`
+				} `json:"mappings"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.Contains(t, body.Settings.Analysis.Analyzer, textAnalyzer)
+			require.Equal(t, fieldMapping{Type: "text", Analyzer: textAnalyzer}, body.Mappings.Properties["title"])
+			require.Equal(t, fieldMapping{Type: "keyword"}, body.Mappings.Properties["keywords"])
+			require.Equal(t, fieldMapping{Type: "date"}, body.Mappings.Properties["timestamp"])
+			_, _ = io.WriteString(w, `{"acknowledged":true}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, client.EnsureIndex(context.Background()))
+	require.Equal(t, []string{"HEAD /news", "PUT /news"}, calls)
+}
+
+func TestEnsureIndexReportsDynamicMapping(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodHead {
+			return
+		}
+		require.Equal(t, "/news/_mapping", r.URL.Path)
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":{"type":"mapper_parsing_exception","reason":"analyzer [ru_en] has not been configured in mappings"},"status":400}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	err = client.EnsureIndex(context.Background())
+	require.True(t, errors.Is(err, ErrBadRequest))
+	require.ErrorContains(t, err, "ru_en")
+}
+
+func TestWaitForIndexRetriesUnavailableCluster(t *testing.T) {
+	var heads int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodHead {
+			heads++
+			if heads <= 4 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		_, _ = io.WriteString(w, `{"acknowledged":true}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, client.waitForIndex(context.Background(), time.Millisecond))
+	require.Greater(t, heads, 4)
+}
+
+func TestWaitForIndexFailsOnMappingConflict(t *testing.T) {
+	var puts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodHead {
+			return
+		}
+		puts++
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":{"type":"illegal_argument_exception","reason":"mapper [keywords] cannot be changed from type [text] to [keyword]"},"status":400}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	err = client.waitForIndex(context.Background(), time.Millisecond)
+	require.ErrorIs(t, err, ErrBadRequest)
+	require.ErrorContains(t, err, "mapping of news conflicts with the current one")
+	require.Equal(t, 1, puts)
+}
+
+func TestIndexReady(t *testing.T) {
+	indexStatus := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/_cluster/health":
+			_, _ = io.WriteString(w, `{"status":"yellow"}`)
+		case "/news":
+			w.WriteHeader(indexStatus)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+	require.NoError(t, client.IndexReady(context.Background()))
+
+	indexStatus = http.StatusNotFound
+	err = client.IndexReady(context.Background())
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorContains(t, err, "check index news")
+
+	srv.Close()
+	require.ErrorIs(t, client.IndexReady(context.Background()), ErrUnavailable)
+}
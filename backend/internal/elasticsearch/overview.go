@@ -0,0 +1,68 @@
+package elasticsearch
+
+import (
+	"context"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+)
+
+// Overview summarizes the index for landing-page counters.
+type Overview struct {
+	TotalDocuments int64 `json:"total_documents"`
+	// RecentDocuments, ActiveSources and TrendingDestinations cover the
+	// documents published since the requested time.
+	RecentDocuments      int64       `json:"recent_documents"`
+	ActiveSources        int64       `json:"active_sources"`
+	TrendingDestinations []TermCount `json:"trending_destinations"`
+}
+
+// NewsOverview counts all documents and, among those published since the
+// given time, the documents, distinct sources and the top destinations.
+func (c *Client) NewsOverview(ctx context.Context, since time.Time, topDestinations int) (*Overview, error) {
+	body := map[string]any{
+		"size":             0,
+		"track_total_hits": true,
+		"aggs": map[string]any{
+			"recent": map[string]any{
+				"filter": esquery.Range{Field: "timestamp", GTE: since.UTC().Format(time.RFC3339)}.Source(),
+				"aggs": map[string]any{
+					"sources": map[string]any{
+						"cardinality": map[string]any{"field": "source"},
+					},
+					"destinations": map[string]any{
+						"terms": map[string]any{"field": "destinations", "size": topDestinations},
+					},
+				},
+			},
+		},
+	}
+
+	var parsed struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+		Aggregations struct {
+			Recent struct {
+				DocCount int64 `json:"doc_count"`
+				Sources  struct {
+					Value int64 `json:"value"`
+				} `json:"sources"`
+				Destinations termsAggregation `json:"destinations"`
+			} `json:"recent"`
+		} `json:"aggregations"`
+	}
+	if err := c.searchInto(ctx, c.index, body, &parsed); err != nil {
+		return nil, err
+	}
+
+	recent := parsed.Aggregations.Recent
+	return &Overview{
+		TotalDocuments:       parsed.Hits.Total.Value,
+		RecentDocuments:      recent.DocCount,
+		ActiveSources:        recent.Sources.Value,
+		TrendingDestinations: recent.Destinations.counts(),
+	}, nil
+}
@@ -0,0 +1,58 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewsOverview(t *testing.T) {
+	since := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Aggs struct {
+				Recent struct {
+					Filter struct {
+						Range struct {
+							Timestamp struct {
+								GTE string `json:"gte"`
+							} `json:"timestamp"`
+						} `json:"range"`
+					} `json:"filter"`
+				} `json:"recent"`
+			} `json:"aggs"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, "2024-06-01T12:00:00Z", body.Aggs.Recent.Filter.Range.Timestamp.GTE)
+
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{
+			"hits": {"total": {"value": 5120}},
+			"aggregations": {"recent": {
+				"doc_count": 240,
+				"sources": {"value": 7},
+				"destinations": {"buckets": [{"key": "турция", "doc_count": 80}, {"key": "египет", "doc_count": 41}]}
+			}}
+		}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	overview, err := client.NewsOverview(context.Background(), since, 3)
+	require.NoError(t, err)
+	require.Equal(t, &Overview{
+		TotalDocuments:       5120,
+		RecentDocuments:      240,
+		ActiveSources:        7,
+		TrendingDestinations: []TermCount{{Term: "турция", Count: 80}, {Term: "египет", Count: 41}},
+	}, overview)
+}
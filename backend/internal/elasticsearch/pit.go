@@ -0,0 +1,101 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PointInTime pins a search to a snapshot of the index opened with
+// OpenPointInTime, so paging through it is unaffected by documents indexed
+// or deleted meanwhile.
+type PointInTime struct {
+	ID string
+	// KeepAlive extends the snapshot's life by this much from the search.
+	KeepAlive time.Duration
+	// SearchAfter holds the sort values of the previous page's last hit,
+	// as returned in SearchResult.SearchAfter; empty starts at the top.
+	SearchAfter json.RawMessage
+}
+
+// OpenPointInTime opens a snapshot of the news index that lives for
+// keepAlive unless a search extends it.
+func (c *Client) OpenPointInTime(ctx context.Context, keepAlive time.Duration) (string, error) {
+	return c.openPointInTime(ctx, c.index, keepAlive)
+}
+
+func (c *Client) openPointInTime(ctx context.Context, index string, keepAlive time.Duration) (string, error) {
+	res, err := c.es.OpenPointInTime([]string{index}, keepAliveParam(keepAlive), c.es.OpenPointInTime.WithContext(ctx))
+	if err != nil {
+		return "", transportError("open point in time", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return "", responseError("open point in time", res)
+	}
+
+	var parsed struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return "", fmt.Errorf("decode point in time response: %w", err)
+	}
+	return parsed.ID, nil
+}
+
+// ClosePointInTime frees a snapshot before its keep-alive runs out. Closing
+// one that already expired returns ErrNotFound.
+func (c *Client) ClosePointInTime(ctx context.Context, id string) error {
+	payload, err := json.Marshal(map[string]string{"id": id})
+	if err != nil {
+		return fmt.Errorf("marshal point in time body: %w", err)
+	}
+	res, err := c.es.ClosePointInTime(c.es.ClosePointInTime.WithContext(ctx), c.es.ClosePointInTime.WithBody(bytes.NewReader(payload)))
+	if err != nil {
+		return transportError("close point in time", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError("close point in time", res)
+	}
+	return nil
+}
+
+// withPointInTime points a search body at pit. Elasticsearch breaks sort
+// ties by shard and document on its own, so the sort values of the last hit
+// always continue where the page ended.
+func withPointInTime(body map[string]any, pit *PointInTime) {
+	body["pit"] = map[string]any{"id": pit.ID, "keep_alive": keepAliveParam(pit.KeepAlive)}
+	if len(pit.SearchAfter) > 0 {
+		body["search_after"] = pit.SearchAfter
+		body["from"] = 0
+	}
+}
+
+// Cursor pages the live index with search_after. Unlike from, the position
+// is a set of sort values rather than a count of hits, so pages do not shift
+// when earlier documents are deleted, and the position stays valid when the
+// document it was taken from is deleted too. Without a snapshot, documents
+// indexed meanwhile may still appear on later pages.
+type Cursor struct {
+	// After holds SearchResult.SearchAfter of the previous page; empty
+	// starts at the top.
+	After json.RawMessage
+}
+
+// withCursor adds the document ID as a final sort key, so hits with equal
+// sort values have a stable order to continue from, and the position.
+func withCursor(body map[string]any, cursor *Cursor) {
+	sort, _ := body["sort"].([]map[string]any)
+	body["sort"] = append(sort, map[string]any{"id": map[string]any{"order": "asc"}})
+	if len(cursor.After) > 0 {
+		body["search_after"] = cursor.After
+		body["from"] = 0
+	}
+}
+
+func keepAliveParam(d time.Duration) string {
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
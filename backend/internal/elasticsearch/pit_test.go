@@ -0,0 +1,115 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPointInTimeSearch(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.URL.Path == "/news/_pit":
+			require.Equal(t, "60000ms", r.URL.Query().Get("keep_alive"))
+			_, _ = io.WriteString(w, `{"id":"pit-1"}`)
+		case r.URL.Path == "/_search":
+			var body struct {
+				From        int             `json:"from"`
+				PIT         map[string]any  `json:"pit"`
+				SearchAfter json.RawMessage `json:"search_after"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.Equal(t, map[string]any{"id": "pit-1", "keep_alive": "60000ms"}, body.PIT)
+			require.JSONEq(t, `[1717200000000,12345678901234567]`, string(body.SearchAfter))
+			require.Zero(t, body.From)
+			_, _ = io.WriteString(w, `{"pit_id":"pit-2","hits":{"total":{"value":3},"hits":[
+				{"_source":{"id":"b"},"sort":[1717100000000,12345678901234568]},
+				{"_source":{"id":"c"},"sort":[1717000000000,12345678901234569]}]}}`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/_pit":
+			var body struct {
+				ID string `json:"id"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			if body.ID != "pit-2" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = io.WriteString(w, `{"succeeded":true,"num_freed":0}`)
+				return
+			}
+			_, _ = io.WriteString(w, `{"succeeded":true,"num_freed":1}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	id, err := client.OpenPointInTime(ctx, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "pit-1", id)
+
+	result, err := client.SearchNews(ctx, SearchParams{From: 40, Size: 2, PIT: &PointInTime{
+		ID:          id,
+		KeepAlive:   time.Minute,
+		SearchAfter: json.RawMessage(`[1717200000000,12345678901234567]`),
+	}})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 2)
+	require.Equal(t, "pit-2", result.PIT)
+	// Sort values pass through untouched, so large tiebreakers keep their precision.
+	require.JSONEq(t, `[1717000000000,12345678901234569]`, string(result.SearchAfter))
+
+	require.NoError(t, client.ClosePointInTime(ctx, result.PIT))
+	require.ErrorIs(t, client.ClosePointInTime(ctx, "pit-1"), ErrNotFound)
+	require.Equal(t, []string{"POST /news/_pit", "POST /_search", "DELETE /_pit", "DELETE /_pit"}, calls)
+}
+
+func TestCursorSearch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/news/_search", r.URL.Path)
+		var body struct {
+			From        int              `json:"from"`
+			Sort        []map[string]any `json:"sort"`
+			SearchAfter json.RawMessage  `json:"search_after"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, []map[string]any{
+			{"timestamp": map[string]any{"order": "asc"}},
+			{"id": map[string]any{"order": "asc"}},
+		}, body.Sort)
+		require.JSONEq(t, `[1717000000000,"a"]`, string(body.SearchAfter))
+		require.Zero(t, body.From)
+
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"hits":{"total":{"value":3},"hits":[
+			{"_source":{"id":"b"},"sort":[1717100000000,"b"]}]}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	result, err := client.SearchNews(context.Background(), SearchParams{
+		From:   20,
+		Size:   1,
+		Sort:   "timestamp:asc",
+		Cursor: &Cursor{After: json.RawMessage(`[1717000000000,"a"]`)},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	require.JSONEq(t, `[1717100000000,"b"]`, string(result.SearchAfter))
+}
@@ -0,0 +1,38 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// QueriesIndex is the index that stores query events of ranking
+// experiments.
+func (c *Client) QueriesIndex() string {
+	return c.index + "_queries"
+}
+
+// RecordQuery stores a query event.
+func (c *Client) RecordQuery(ctx context.Context, event models.QueryEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal query event: %w", err)
+	}
+
+	res, err := c.es.Index(
+		c.QueriesIndex(),
+		bytes.NewReader(payload),
+		c.es.Index.WithContext(ctx),
+	)
+	if err != nil {
+		return transportError("index query event", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError("index query event", res)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package elasticsearch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRelevanceSort(t *testing.T) {
+	require.True(t, isRelevanceSort("relevance"))
+	require.True(t, isRelevanceSort("_score:desc"))
+	require.False(t, isRelevanceSort("timestamp:desc"))
+	require.False(t, isRelevanceSort(""))
+}
+
+func TestWithPopularity(t *testing.T) {
+	query := buildQuery(SearchParams{Query: "турция"})
+
+	require.Equal(t, query, withPopularity(query, PopularityBoost{}))
+
+	wrapped := withPopularity(query, PopularityBoost{SeenWeight: 2, ClickWeight: 0.5}).Source()
+	fs := wrapped["function_score"].(map[string]any)
+	require.Equal(t, query.Source(), fs["query"])
+	require.Equal(t, "sum", fs["boost_mode"])
+
+	functions := fs["functions"].([]map[string]any)
+	require.Len(t, functions, 2)
+	seen := functions[0]["field_value_factor"].(map[string]any)
+	require.Equal(t, "seen_count", seen["field"])
+	require.Equal(t, 2.0, seen["factor"])
+	clicks := functions[1]["field_value_factor"].(map[string]any)
+	require.Equal(t, "clicks", clicks["field"])
+
+	onlyClicks := withPopularity(query, PopularityBoost{ClickWeight: 1}).Source()
+	require.Len(t, onlyClicks["function_score"].(map[string]any)["functions"], 1)
+}
+
+func TestRelaxationSteps(t *testing.T) {
+	require.Empty(t, relaxationSteps(SearchParams{}))
+
+	start := time.Now()
+	params := SearchParams{Query: "еипет", Keywords: []string{"пляж"}, Source: "telegram", Start: &start}
+	steps := relaxationSteps(params)
+
+	names := make([]string, 0, len(steps))
+	for _, step := range steps {
+		names = append(names, step.name)
+		step.apply(&params)
+	}
+	require.Equal(t, []string{"exact_match", "keywords", "source", "time_range"}, names)
+	require.True(t, params.Fuzzy)
+	require.Nil(t, params.Keywords)
+	require.Empty(t, params.Source)
+	require.Nil(t, params.Start)
+
+	fuzzy := buildQuery(SearchParams{Query: "еипет", Fuzzy: true})
+	match := fuzzy.Source()["bool"].(map[string]any)["must"].([]map[string]any)[0]["multi_match"].(map[string]any)
+	require.Equal(t, "AUTO", match["fuzziness"])
+
+	// A query that is fuzzy already has no exact match to give up.
+	require.Empty(t, relaxationSteps(SearchParams{Query: "еипет", Fuzzy: true}))
+}
+
+func TestBuildQueryActiveOnly(t *testing.T) {
+	require.NotContains(t, buildQuery(SearchParams{}).Source()["bool"], "must_not")
+
+	query := buildQuery(SearchParams{ActiveOnly: true}).Source()["bool"].(map[string]any)
+	mustNot := query["must_not"].([]map[string]any)
+	require.Equal(t, map[string]any{"expires_at": map[string]any{"lte": "now"}}, mustNot[0]["range"])
+	require.Equal(t, map[string]any{"status": "expired"}, mustNot[1]["term"])
+	require.NotEmpty(t, query["must"])
+}
+
+func TestBuildQueryBoostKeywords(t *testing.T) {
+	require.NotContains(t, buildQuery(SearchParams{}).Source()["bool"], "should")
+
+	query := buildQuery(SearchParams{
+		Source:        "telegram",
+		BoostKeywords: []KeywordBoost{{Keyword: "турция", Weight: 2}, {Keyword: "египет", Weight: 0.5}},
+	}).Source()["bool"].(map[string]any)
+
+	should := query["should"].([]map[string]any)
+	require.Len(t, should, 2)
+	require.Equal(t, map[string]any{"keywords": map[string]any{"value": "египет", "boost": 0.5}}, should[1]["term"])
+	require.Equal(t, 0, query["minimum_should_match"])
+	require.NotEmpty(t, query["filter"])
+}
+
+func TestBuildQueryPresenceFilters(t *testing.T) {
+	require.NotContains(t, buildQuery(SearchParams{HasURL: false}).Source()["bool"], "filter")
+
+	query := buildQuery(SearchParams{HasPrice: true, HasTravelDates: true}).Source()["bool"].(map[string]any)
+	require.Equal(t, []map[string]any{
+		{"exists": map[string]any{"field": "price"}},
+		{"exists": map[string]any{"field": "travel_start"}},
+	}, query["filter"])
+}
+
+func TestBuildQueryPriceRange(t *testing.T) {
+	query := buildQuery(SearchParams{MaxPrice: 50000}).Source()["bool"].(map[string]any)
+	require.Equal(t, []map[string]any{
+		{"range": map[string]any{"price": map[string]any{"lte": 50000}}},
+	}, query["filter"])
+
+	query = buildQuery(SearchParams{MinPrice: 30000, MaxPrice: 50000}).Source()["bool"].(map[string]any)
+	require.Equal(t, []map[string]any{
+		{"range": map[string]any{"price": map[string]any{"gte": 30000, "lte": 50000}}},
+	}, query["filter"])
+
+	_, _, ok := searchTemplate(SearchParams{MaxPrice: 50000})
+	require.False(t, ok, "a price bound needs the inline query")
+}
+
+func TestBuildQueryMinQuality(t *testing.T) {
+	query := buildQuery(SearchParams{MinQuality: 60, HasPrice: true}).Source()["bool"].(map[string]any)
+	require.Equal(t, []map[string]any{
+		{"exists": map[string]any{"field": "price"}},
+		{"range": map[string]any{"quality": map[string]any{"gte": 60}}},
+	}, query["filter"])
+
+	_, _, ok := searchTemplate(SearchParams{MinQuality: 60})
+	require.False(t, ok, "a quality bound needs the inline query")
+	require.True(t, SearchParams{MinQuality: 60}.Filtered())
+}
+
+func TestBuildQueryMention(t *testing.T) {
+	query := buildQuery(SearchParams{Mention: "hottours", Query: "mention:tourbot"}).Source()["bool"].(map[string]any)
+	require.Equal(t, []map[string]any{
+		{"term": map[string]any{"mentions": "tourbot"}},
+		{"term": map[string]any{"mentions": "hottours"}},
+	}, query["filter"])
+}
+
+func TestBuildQueryStructuredText(t *testing.T) {
+	query := buildQuery(SearchParams{
+		Query:      `турция "всё включено" +перелёт анталья -автобус source:telegram -destination:египет`,
+		Fuzzy:      true,
+		ActiveOnly: true,
+	})
+
+	match := func(text, kind, fuzziness string) map[string]any {
+		body := map[string]any{"query": text, "fields": textFields}
+		if kind != "" {
+			body["type"] = kind
+		}
+		if fuzziness != "" {
+			body["fuzziness"] = fuzziness
+		}
+		return map[string]any{"multi_match": body}
+	}
+	clauses := query.Source()["bool"].(map[string]any)
+	require.Equal(t, []map[string]any{
+		match("всё включено", "phrase", ""),
+		match("перелёт", "", "AUTO"),
+		match("турция анталья", "", "AUTO"),
+	}, clauses["must"])
+	require.Equal(t, []map[string]any{
+		{"term": map[string]any{"source": "telegram"}},
+	}, clauses["filter"])
+	require.Equal(t, []map[string]any{
+		match("автобус", "", ""),
+		{"term": map[string]any{"destinations": "египет"}},
+		{"range": map[string]any{"expires_at": map[string]any{"lte": "now"}}},
+		{"term": map[string]any{"status": "expired"}},
+	}, clauses["must_not"])
+}
+
+func TestBuildQueryUnparsableText(t *testing.T) {
+	query := buildQuery(SearchParams{Query: `турция "всё включено`})
+	must := query.Source()["bool"].(map[string]any)["must"].([]map[string]any)
+	require.Equal(t, `турция "всё включено`, must[0]["multi_match"].(map[string]any)["query"])
+}
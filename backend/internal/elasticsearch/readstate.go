@@ -0,0 +1,151 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+)
+
+// MaxSeenPerReader bounds the documents and the clusters remembered per
+// reader; the oldest markers are dropped first. It stays well below the
+// 65536 terms a terms lookup accepts.
+const MaxSeenPerReader = 10_000
+
+// seenScript merges new markers into a reader's state, keeping the most
+// recent MaxSeenPerReader of each kind.
+const seenScript = `
+for (String field : ['doc_ids', 'cluster_ids']) {
+  List seen = ctx._source.containsKey(field) ? ctx._source[field] : new ArrayList();
+  for (def value : params[field]) {
+    if (!seen.contains(value)) {
+      seen.add(value);
+    }
+  }
+  if (seen.size() > params.max) {
+    seen = new ArrayList(seen.subList(seen.size() - params.max, seen.size()));
+  }
+  ctx._source[field] = seen;
+}
+ctx._source.updated_at = params.now;
+`
+
+// readStateMapping keeps the marker lists in _source only: terms lookups
+// read them from there, so indexing them would be wasted work.
+var readStateMapping = map[string]any{
+	"dynamic": false,
+	"properties": map[string]any{
+		"updated_at": map[string]any{"type": "date"},
+	},
+}
+
+// ReadStateIndex stores, per reader, the documents and clusters they have seen.
+func (c *Client) ReadStateIndex() string {
+	return c.index + "_read_state"
+}
+
+// EnsureReadStateIndex creates the read state index when it does not exist.
+// Searches with SearchParams.UnseenBy fail until it does.
+func (c *Client) EnsureReadStateIndex(ctx context.Context) error {
+	res, err := c.es.Indices.Exists([]string{c.ReadStateIndex()}, c.es.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return transportError("check read state index", err)
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	payload, err := json.Marshal(map[string]any{"mappings": readStateMapping})
+	if err != nil {
+		return fmt.Errorf("marshal read state index body: %w", err)
+	}
+	res, err = c.es.Indices.Create(c.ReadStateIndex(), c.es.Indices.Create.WithContext(ctx), c.es.Indices.Create.WithBody(bytes.NewReader(payload)))
+	if err != nil {
+		return transportError("create read state index", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		err := responseError("create read state index", res)
+		// Another API instance may have created it in the meantime.
+		if strings.Contains(err.Error(), "resource_already_exists_exception") {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// MarkSeen adds documents and clusters to reader's seen markers. Searches
+// read the markers in real time, so they apply to the next search.
+func (c *Client) MarkSeen(ctx context.Context, reader string, docIDs, clusterIDs []string) error {
+	if docIDs == nil {
+		docIDs = []string{}
+	}
+	if clusterIDs == nil {
+		clusterIDs = []string{}
+	}
+	body, err := json.Marshal(map[string]any{
+		"scripted_upsert": true,
+		"upsert":          map[string]any{},
+		"script": map[string]any{
+			"lang":   "painless",
+			"source": seenScript,
+			"params": map[string]any{
+				"doc_ids":     docIDs,
+				"cluster_ids": clusterIDs,
+				"max":         MaxSeenPerReader,
+				"now":         time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal seen markers: %w", err)
+	}
+
+	req := esapi.UpdateRequest{
+		Index:           c.ReadStateIndex(),
+		DocumentID:      reader,
+		Body:            bytes.NewReader(body),
+		RetryOnConflict: esapi.IntPtr(5),
+	}
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return transportError("mark seen", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError("mark seen", res)
+	}
+	return nil
+}
+
+// ClearSeen forgets every marker of reader.
+func (c *Client) ClearSeen(ctx context.Context, reader string) error {
+	res, err := c.es.Delete(c.ReadStateIndex(), reader, c.es.Delete.WithContext(ctx))
+	if err != nil {
+		return transportError("clear seen", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return responseError("clear seen", res)
+	}
+	return nil
+}
+
+// seenBy excludes what reader marked as seen: the documents themselves and
+// every document of a seen cluster, which hides reposts of a known offer.
+func (c *Client) seenBy(reader string) []esquery.Query {
+	return []esquery.Query{
+		esquery.TermsLookup{Field: "id", Index: c.ReadStateIndex(), ID: reader, Path: "doc_ids"},
+		esquery.TermsLookup{Field: "cluster_id", Index: c.ReadStateIndex(), ID: reader, Path: "cluster_ids"},
+	}
+}
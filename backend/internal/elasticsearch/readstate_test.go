@@ -0,0 +1,60 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadState(t *testing.T) {
+	var (
+		update map[string]any
+		search map[string]any
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.URL.Path == "/news_read_state/_update/reader":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&update))
+			_, _ = io.WriteString(w, `{"result":"created"}`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/news_read_state/_doc/reader":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"result":"not_found"}`)
+		case r.URL.Path == "/news/_search":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&search))
+			_, _ = io.WriteString(w, `{"hits":{"total":{"value":0},"hits":[]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, client.MarkSeen(ctx, "reader", []string{"a", "b"}, nil))
+	require.Equal(t, true, update["scripted_upsert"])
+	params := update["script"].(map[string]any)["params"].(map[string]any)
+	require.Equal(t, []any{"a", "b"}, params["doc_ids"])
+	require.Equal(t, []any{}, params["cluster_ids"])
+	require.Equal(t, float64(MaxSeenPerReader), params["max"])
+
+	// Clearing a reader without markers is not an error.
+	require.NoError(t, client.ClearSeen(ctx, "reader"))
+
+	_, err = client.SearchNews(ctx, SearchParams{UnseenBy: "reader"})
+	require.NoError(t, err)
+	mustNot := search["query"].(map[string]any)["bool"].(map[string]any)["must_not"].([]any)
+	require.Equal(t, []any{
+		map[string]any{"terms": map[string]any{"id": map[string]any{"index": "news_read_state", "id": "reader", "path": "doc_ids"}}},
+		map[string]any{"terms": map[string]any{"cluster_id": map[string]any{"index": "news_read_state", "id": "reader", "path": "cluster_ids"}}},
+	}, mustNot)
+}
@@ -0,0 +1,161 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/stemmer"
+)
+
+// retagScript adds and removes keywords in place, keeping the list free of
+// duplicates. Added keywords with a spelling in params.forms get it as
+// their display form, and keyword_text is rebuilt from the display forms
+// as the worker builds it.
+const retagScript = `
+if (ctx._source.keywords == null) {
+	ctx._source.keywords = new ArrayList();
+}
+boolean changed = false;
+for (String k : params.remove) {
+	changed = ctx._source.keywords.removeIf(x -> x == k) || changed;
+	if (ctx._source.keyword_forms != null && ctx._source.keyword_forms.remove(k) != null) {
+		changed = true;
+	}
+}
+for (String k : params.add) {
+	if (!ctx._source.keywords.contains(k)) {
+		ctx._source.keywords.add(k);
+		if (params.forms.containsKey(k)) {
+			if (ctx._source.keyword_forms == null) {
+				ctx._source.keyword_forms = new HashMap();
+			}
+			ctx._source.keyword_forms[k] = params.forms[k];
+		}
+		changed = true;
+	}
+}
+if (!changed) {
+	ctx.op = 'noop';
+} else {
+	List words = new ArrayList();
+	for (String k : ctx._source.keywords) {
+		boolean spelled = ctx._source.keyword_forms != null && ctx._source.keyword_forms.containsKey(k);
+		words.add(spelled ? ctx._source.keyword_forms[k] : k);
+	}
+	ctx._source.keyword_text = String.join(' ', words);
+}
+`
+
+// RetagResult reports how many documents a re-tagging run touched.
+type RetagResult struct {
+	Matched int64 `json:"matched"`
+	Updated int64 `json:"updated"`
+	Noops   int64 `json:"noops"`
+}
+
+// EnableKeywordStemming makes RetagNews store keywords the way the worker
+// does with KEYWORD_STEMMING: added keywords become their Russian stems,
+// keeping the given spelling for display, and removed keywords are removed
+// under their stems too. It must be called before the client is used.
+func (c *Client) EnableKeywordStemming() {
+	c.stemKeywords = true
+}
+
+// stemRetag returns the stems of add with the spelling of each that
+// differs from its stem, and remove together with its stems.
+func stemRetag(add, remove []string) (stems []string, forms map[string]string, removeAll []string) {
+	forms = map[string]string{}
+	for _, keyword := range add {
+		stem := stemmer.Russian(keyword)
+		if slices.Contains(stems, stem) {
+			continue
+		}
+		stems = append(stems, stem)
+		if stem != keyword {
+			forms[stem] = keyword
+		}
+	}
+	removeAll = slices.Clone(remove)
+	for _, keyword := range remove {
+		if stem := stemmer.Russian(keyword); !slices.Contains(removeAll, stem) {
+			removeAll = append(removeAll, stem)
+		}
+	}
+	return stems, forms, removeAll
+}
+
+// RetagNews adds and removes keywords on every document matching filter
+// using update_by_query with a painless script.
+func (c *Client) RetagNews(ctx context.Context, filter SearchParams, add, remove []string) (*RetagResult, error) {
+	forms := map[string]string{}
+	if c.stemKeywords {
+		add, forms, remove = stemRetag(add, remove)
+	}
+	if add == nil {
+		add = []string{}
+	}
+	if remove == nil {
+		remove = []string{}
+	}
+
+	body := map[string]any{
+		"query": buildQuery(filter).Source(),
+		"script": map[string]any{
+			"lang":   "painless",
+			"source": retagScript,
+			"params": map[string]any{
+				"add":    add,
+				"remove": remove,
+				"forms":  forms,
+			},
+		},
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal retag body: %w", err)
+	}
+
+	res, err := c.es.UpdateByQuery(
+		[]string{c.index},
+		c.es.UpdateByQuery.WithContext(ctx),
+		c.es.UpdateByQuery.WithBody(bytes.NewReader(payload)),
+		c.es.UpdateByQuery.WithConflicts("proceed"),
+		c.es.UpdateByQuery.WithWaitForCompletion(true),
+		c.es.UpdateByQuery.WithRefresh(true),
+	)
+	if err != nil {
+		return nil, transportError("update by query", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, responseError("update by query", res)
+	}
+
+	var parsed struct {
+		Total   int64 `json:"total"`
+		Updated int64 `json:"updated"`
+		Noops   int64 `json:"noops"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode update by query response: %w", err)
+	}
+
+	c.log.Info("retag completed",
+		slog.Int64("matched", parsed.Total),
+		slog.Int64("updated", parsed.Updated),
+		slog.Any("add", add),
+		slog.Any("remove", remove),
+	)
+
+	return &RetagResult{
+		Matched: parsed.Total,
+		Updated: parsed.Updated,
+		Noops:   parsed.Noops,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// retagServer records the script params of update_by_query requests.
+func retagServer(t *testing.T, params *map[string]any) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/news/_update_by_query", r.URL.Path)
+		var body struct {
+			Script struct {
+				Params map[string]any `json:"params"`
+			} `json:"script"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		*params = body.Script.Params
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"total":3,"updated":2,"noops":1}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+	return client
+}
+
+func TestRetagNews(t *testing.T) {
+	var params map[string]any
+	client := retagServer(t, &params)
+
+	result, err := client.RetagNews(context.Background(), SearchParams{Source: "rss"}, []string{"туры"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, &RetagResult{Matched: 3, Updated: 2, Noops: 1}, result)
+	require.Equal(t, map[string]any{
+		"add":    []any{"туры"},
+		"remove": []any{},
+		"forms":  map[string]any{},
+	}, params)
+}
+
+func TestRetagNewsStemsKeywords(t *testing.T) {
+	var params map[string]any
+	client := retagServer(t, &params)
+	client.EnableKeywordStemming()
+
+	_, err := client.RetagNews(context.Background(), SearchParams{Source: "rss"},
+		[]string{"туры", "тур", "горящие", "hotel"}, []string{"отели", "египет"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		// Keywords are stored as the worker stores them, with the given
+		// spelling kept for display.
+		"add":   []any{"тур", "горя", "hotel"},
+		"forms": map[string]any{"тур": "туры", "горя": "горящие"},
+		// Documents tagged before stemming was enabled keep the spelling.
+		"remove": []any{"отели", "египет", "отел"},
+	}, params)
+}
@@ -0,0 +1,34 @@
+package elasticsearch
+
+import (
+	"context"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+)
+
+// MaxSampleSize mirrors the default index.max_result_window of Elasticsearch.
+const MaxSampleSize = 10_000
+
+// SampleNews returns up to n documents matching params in a pseudo-random
+// order that is stable for a given seed, so labeled datasets can be rebuilt.
+// Sort and pagination fields of params are ignored.
+func (c *Client) SampleNews(ctx context.Context, params SearchParams, n int, seed int64) (*SearchResult, error) {
+	if n <= 0 {
+		n = 100
+	}
+	if n > MaxSampleSize {
+		n = MaxSampleSize
+	}
+
+	body := map[string]any{
+		"size":             n,
+		"track_total_hits": true,
+		"query": esquery.FunctionScore{
+			Query:     buildQuery(params),
+			Functions: []esquery.ScoreFunction{esquery.RandomScore{Seed: seed, Field: "_seq_no"}},
+			BoostMode: "replace",
+		}.Source(),
+	}
+
+	return c.runSearch(ctx, body)
+}
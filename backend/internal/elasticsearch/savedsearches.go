@@ -0,0 +1,155 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// SavedSearches reads the saved searches in pages of savedSearchesPage from
+// a point in time kept alive for savedSearchesKeepAlive between pages.
+const (
+	savedSearchesPage      = 1000
+	savedSearchesKeepAlive = time.Minute
+)
+
+// SavedSearchesIndex is the index that stores users' saved searches.
+func (c *Client) SavedSearchesIndex() string {
+	return c.index + "_saved_searches"
+}
+
+// CreateSavedSearch stores s under s.ID. The write is visible to
+// SavedSearches as soon as the call returns.
+func (c *Client) CreateSavedSearch(ctx context.Context, s models.SavedSearch) error {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshal saved search: %w", err)
+	}
+
+	res, err := c.es.Index(
+		c.SavedSearchesIndex(),
+		bytes.NewReader(payload),
+		c.es.Index.WithContext(ctx),
+		c.es.Index.WithDocumentID(s.ID),
+		c.es.Index.WithRefresh("wait_for"),
+	)
+	if err != nil {
+		return transportError("index saved search", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError("index saved search", res)
+	}
+	return nil
+}
+
+// SavedSearch returns the saved search with the given ID, or an error
+// wrapping ErrNotFound when there is none.
+func (c *Client) SavedSearch(ctx context.Context, id string) (*models.SavedSearch, error) {
+	res, err := c.es.Get(c.SavedSearchesIndex(), id, c.es.Get.WithContext(ctx))
+	if err != nil {
+		return nil, transportError("get saved search", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, responseError("get saved search", res)
+	}
+
+	var parsed struct {
+		Source models.SavedSearch `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode saved search: %w", err)
+	}
+	return &parsed.Source, nil
+}
+
+// SavedSearches lists the saved searches of a chat, oldest first, or of
+// every chat when chatID is zero. It pages through a point in time of the
+// index, so however many searches there are, each is returned once.
+func (c *Client) SavedSearches(ctx context.Context, chatID int64) ([]models.SavedSearch, error) {
+	var query esquery.Query = esquery.MatchAll{}
+	if chatID != 0 {
+		query = esquery.Term{Field: "chat_id", Value: chatID}
+	}
+
+	id, err := c.openPointInTime(ctx, c.SavedSearchesIndex(), savedSearchesKeepAlive)
+	if err != nil {
+		// The index only appears with the first saved search.
+		if errors.Is(err, ErrNotFound) {
+			return []models.SavedSearch{}, nil
+		}
+		return nil, err
+	}
+	pit := &PointInTime{ID: id, KeepAlive: savedSearchesKeepAlive}
+	defer func() {
+		// The snapshot is freed even when ctx has ended.
+		closeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := c.ClosePointInTime(closeCtx, pit.ID); err != nil {
+			c.log.Warn("close saved searches point in time", slog.Any("err", err))
+		}
+	}()
+
+	out := []models.SavedSearch{}
+	for {
+		body := map[string]any{
+			"size":             savedSearchesPage,
+			"query":            query.Source(),
+			"sort":             []map[string]any{{"created_at": map[string]any{"order": "asc"}}},
+			"track_total_hits": false,
+		}
+		withPointInTime(body, pit)
+
+		var parsed struct {
+			PITID string `json:"pit_id"`
+			Hits  struct {
+				Hits []struct {
+					Source models.SavedSearch `json:"_source"`
+					Sort   json.RawMessage    `json:"sort"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		if err := c.searchInto(ctx, "", body, &parsed); err != nil {
+			return nil, err
+		}
+		if parsed.PITID != "" {
+			pit.ID = parsed.PITID
+		}
+
+		hits := parsed.Hits.Hits
+		for _, hit := range hits {
+			out = append(out, hit.Source)
+		}
+		if len(hits) < savedSearchesPage {
+			return out, nil
+		}
+		pit.SearchAfter = hits[len(hits)-1].Sort
+	}
+}
+
+// DeleteSavedSearch removes a saved search. It returns an error wrapping
+// ErrNotFound when there is none with that ID.
+func (c *Client) DeleteSavedSearch(ctx context.Context, id string) error {
+	res, err := c.es.Delete(
+		c.SavedSearchesIndex(),
+		id,
+		c.es.Delete.WithContext(ctx),
+		c.es.Delete.WithRefresh("wait_for"),
+	)
+	if err != nil {
+		return transportError("delete saved search", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError("delete saved search", res)
+	}
+	return nil
+}
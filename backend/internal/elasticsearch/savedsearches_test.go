@@ -0,0 +1,118 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavedSearchesPagesThroughEverySearch(t *testing.T) {
+	total := savedSearchesPage + 1
+	var (
+		mu     sync.Mutex
+		calls  []string
+		afters []string
+		closed string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.URL.Path == "/news_saved_searches/_pit":
+			_, _ = io.WriteString(w, `{"id":"pit-1"}`)
+		case r.URL.Path == "/_search":
+			var body struct {
+				SearchAfter []int `json:"search_after"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			from := 0
+			if len(body.SearchAfter) > 0 {
+				from = body.SearchAfter[0] + 1
+			}
+			afters = append(afters, fmt.Sprint(body.SearchAfter))
+			hits := make([]string, 0, savedSearchesPage)
+			for i := from; i < total && len(hits) < savedSearchesPage; i++ {
+				hits = append(hits, fmt.Sprintf(`{"_source":{"id":"s%d","chat_id":%d},"sort":[%d]}`, i, i%7+1, i))
+			}
+			fmt.Fprintf(w, `{"pit_id":"pit-2","hits":{"hits":[%s]}}`, strings.Join(hits, ","))
+		case r.Method == http.MethodDelete && r.URL.Path == "/_pit":
+			var body struct {
+				ID string `json:"id"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			closed = body.ID
+			_, _ = io.WriteString(w, `{"succeeded":true,"num_freed":1}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	searches, err := client.SavedSearches(context.Background(), 0)
+	require.NoError(t, err)
+	require.Len(t, searches, total)
+	require.Equal(t, "s0", searches[0].ID)
+	require.Equal(t, fmt.Sprintf("s%d", total-1), searches[total-1].ID)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"POST /news_saved_searches/_pit", "POST /_search", "POST /_search", "DELETE /_pit"}, calls)
+	require.Equal(t, []string{"[]", fmt.Sprintf("[%d]", savedSearchesPage-1)}, afters)
+	require.Equal(t, "pit-2", closed)
+}
+
+func TestSavedSearchesWithoutIndex(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":{"type":"index_not_found_exception"},"status":404}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+	searches, err := client.SavedSearches(context.Background(), 42)
+	require.NoError(t, err)
+	require.Empty(t, searches)
+}
+
+func TestSavedSearch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/news_saved_searches/_doc/s1" {
+			_, _ = io.WriteString(w, `{"found":true,"_source":{"id":"s1","chat_id":7,"destination":"турция","max_price":60000}}`)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"found":false}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	search, err := client.SavedSearch(context.Background(), "s1")
+	require.NoError(t, err)
+	require.Equal(t, "турция", search.Destination)
+	require.Equal(t, 60000, search.MaxPrice)
+
+	_, err = client.SavedSearch(context.Background(), "s2")
+	require.ErrorIs(t, err, ErrNotFound)
+}
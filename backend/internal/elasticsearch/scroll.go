@@ -0,0 +1,149 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// scrollKeepAlive is how long the scroll context survives between pages;
+// it only has to cover the time fn takes for one page.
+const scrollKeepAlive = 5 * time.Minute
+
+// ScrollNews walks every document matching query in pages of batchSize and
+// passes the raw _source of each page to fn, stopping at the first error.
+// Documents are returned in index order, without scoring.
+func (c *Client) ScrollNews(ctx context.Context, query esquery.Query, batchSize int, fn func([]json.RawMessage) error) error {
+	return c.ScrollIndex(ctx, c.index, query, batchSize, fn)
+}
+
+// ScrollIndex is ScrollNews over another index, such as one daily index.
+func (c *Client) ScrollIndex(ctx context.Context, index string, query esquery.Query, batchSize int, fn func([]json.RawMessage) error) error {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	payload, err := json.Marshal(map[string]any{
+		"query": query.Source(),
+		"sort":  []string{"_doc"},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal scroll body: %w", err)
+	}
+
+	res, err := c.es.Search(
+		c.es.Search.WithContext(ctx),
+		c.es.Search.WithIndex(index),
+		c.es.Search.WithBody(bytes.NewReader(payload)),
+		c.es.Search.WithSize(batchSize),
+		c.es.Search.WithScroll(scrollKeepAlive),
+	)
+	if err != nil {
+		return transportError("scroll", err)
+	}
+	page, err := decodeScrollPage(res)
+	if err != nil {
+		return err
+	}
+	defer func() { c.clearScroll(page.ScrollID) }()
+
+	for len(page.Hits.Hits) > 0 {
+		docs := make([]json.RawMessage, 0, len(page.Hits.Hits))
+		for _, hit := range page.Hits.Hits {
+			docs = append(docs, hit.Source)
+		}
+		if err := fn(docs); err != nil {
+			return err
+		}
+
+		// Scroll IDs can outgrow URL limits, so they travel in the body.
+		body, err := json.Marshal(map[string]any{"scroll_id": page.ScrollID, "scroll": fmt.Sprintf("%dms", scrollKeepAlive.Milliseconds())})
+		if err != nil {
+			return fmt.Errorf("marshal scroll body: %w", err)
+		}
+		res, err := c.es.Scroll(c.es.Scroll.WithContext(ctx), c.es.Scroll.WithBody(bytes.NewReader(body)))
+		if err != nil {
+			return transportError("scroll", err)
+		}
+		nextPage, err := decodeScrollPage(res)
+		if err != nil {
+			return err
+		}
+		if nextPage.ScrollID != "" {
+			page.ScrollID = nextPage.ScrollID
+		}
+		page.Hits = nextPage.Hits
+	}
+	return nil
+}
+
+// ExportNews walks every document matching the filters of params in pages
+// of batchSize, like ScrollNews, and passes the decoded page to fn. Unlike
+// SearchNews it is not bounded by the from/size window; sort, pagination,
+// boosts and relaxation are ignored.
+func (c *Client) ExportNews(ctx context.Context, params SearchParams, batchSize int, fn func([]models.NewsDocument) error) error {
+	query := buildQuery(params)
+	if params.UnseenBy != "" {
+		query.MustNot = append(query.MustNot, c.seenBy(params.UnseenBy)...)
+	}
+	return c.ScrollNews(ctx, query, batchSize, func(raw []json.RawMessage) error {
+		docs := make([]models.NewsDocument, len(raw))
+		for i, source := range raw {
+			if err := json.Unmarshal(source, &docs[i]); err != nil {
+				return fmt.Errorf("decode exported document: %w", err)
+			}
+		}
+		return fn(docs)
+	})
+}
+
+type scrollPage struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Hits []struct {
+			Source json.RawMessage `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+func decodeScrollPage(res *esapi.Response) (*scrollPage, error) {
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, responseError("scroll", res)
+	}
+	var page scrollPage
+	if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("decode scroll response: %w", err)
+	}
+	return &page, nil
+}
+
+// clearScroll frees the scroll context early instead of leaving it to
+// expire. It runs after ctx may have ended, so it has its own timeout.
+func (c *Client) clearScroll(id string) {
+	if id == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	payload, err := json.Marshal(map[string]any{"scroll_id": id})
+	if err != nil {
+		return
+	}
+	res, err := c.es.ClearScroll(c.es.ClearScroll.WithContext(ctx), c.es.ClearScroll.WithBody(bytes.NewReader(payload)))
+	if err != nil {
+		c.log.Warn("clear scroll", slog.Any("err", err))
+		return
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		c.log.Warn("clear scroll", slog.Int("status", res.StatusCode))
+	}
+}
@@ -0,0 +1,120 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func TestScrollNews(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.URL.Path == "/news/_search":
+			require.Equal(t, "2", r.URL.Query().Get("size"))
+			require.NotEmpty(t, r.URL.Query().Get("scroll"))
+			var body struct {
+				Query map[string]any `json:"query"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.Contains(t, body.Query, "range")
+			_, _ = io.WriteString(w, `{"_scroll_id":"s1","hits":{"hits":[{"_source":{"id":"a"}},{"_source":{"id":"b"}}]}}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/_search/scroll":
+			var body struct {
+				ScrollID string `json:"scroll_id"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			if body.ScrollID == "s1" {
+				_, _ = io.WriteString(w, `{"_scroll_id":"s2","hits":{"hits":[{"_source":{"id":"c"}}]}}`)
+				return
+			}
+			_, _ = io.WriteString(w, `{"_scroll_id":"s2","hits":{"hits":[]}}`)
+		case r.Method == http.MethodDelete:
+			_, _ = io.WriteString(w, `{"succeeded":true}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	var pages [][]string
+	err = client.ScrollNews(context.Background(), ExpiredBefore(time.Now()), 2, func(docs []json.RawMessage) error {
+		var ids []string
+		for _, doc := range docs {
+			var parsed struct {
+				ID string `json:"id"`
+			}
+			require.NoError(t, json.Unmarshal(doc, &parsed))
+			ids = append(ids, parsed.ID)
+		}
+		pages = append(pages, ids)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"a", "b"}, {"c"}}, pages)
+	require.Equal(t, "DELETE /_search/scroll", calls[len(calls)-1])
+
+	// An error from fn stops the walk and still frees the scroll.
+	calls = nil
+	stop := errors.New("upload failed")
+	err = client.ScrollNews(context.Background(), ExpiredBefore(time.Now()), 2, func([]json.RawMessage) error { return stop })
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, []string{"POST /news/_search", "DELETE /_search/scroll"}, calls)
+}
+
+func TestExportNews(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.URL.Path == "/news/_search":
+			var body struct {
+				Query struct {
+					Bool struct {
+						Filter []map[string]any `json:"filter"`
+					} `json:"bool"`
+				} `json:"query"`
+				Sort []string `json:"sort"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.Equal(t, []string{"_doc"}, body.Sort)
+			require.Contains(t, body.Query.Bool.Filter, map[string]any{"term": map[string]any{"source": "telegram"}})
+			_, _ = io.WriteString(w, `{"_scroll_id":"s1","hits":{"hits":[{"_source":{"id":"a","title":"Тур","source":"telegram"}}]}}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/_search/scroll":
+			_, _ = io.WriteString(w, `{"_scroll_id":"s1","hits":{"hits":[]}}`)
+		default:
+			_, _ = io.WriteString(w, `{"succeeded":true}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	var titles []string
+	err = client.ExportNews(context.Background(), SearchParams{Source: "telegram", From: 20, Size: 5}, 100, func(docs []models.NewsDocument) error {
+		for _, doc := range docs {
+			titles = append(titles, doc.ID+":"+doc.Title)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a:Тур"}, titles)
+}
@@ -0,0 +1,63 @@
+package elasticsearch
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/awsauth"
+)
+
+func TestSignAWSUsesInstanceCredentials(t *testing.T) {
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			_, _ = io.WriteString(w, "imds-token")
+		case r.Header.Get("X-aws-ec2-metadata-token") != "imds-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			_, _ = io.WriteString(w, "search-role\n")
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/search-role":
+			_, _ = io.WriteString(w, `{"AccessKeyId":"ASIAEXAMPLE","SecretAccessKey":"secret","Token":"session","Expiration":"`+
+				time.Now().Add(time.Hour).UTC().Format(time.RFC3339)+`"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(imds.Close)
+
+	var gotAuth, gotToken, gotBody string
+	search := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotToken = r.Header.Get("X-Amz-Security-Token")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		// OpenSearch does not identify itself as Elasticsearch.
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"hits":{"total":{"value":0},"hits":[]}}`)
+	}))
+	t.Cleanup(search.Close)
+
+	env := map[string]string{"AWS_EC2_METADATA_SERVICE_ENDPOINT": imds.URL}
+	client, err := New(search.URL, "news", nil, func(cfg *elasticsearch.Config) {
+		SignAWS("eu-central-1", "es")(cfg)
+		cfg.Transport.(*sigV4Transport).creds = awsauth.NewProvider(func(k string) string { return env[k] })
+	})
+	require.NoError(t, err)
+
+	result, err := client.SearchNews(context.Background(), SearchParams{Query: "турция"})
+	require.NoError(t, err)
+	require.Zero(t, result.Total)
+
+	require.True(t, strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=ASIAEXAMPLE/"), gotAuth)
+	require.Contains(t, gotAuth, "/eu-central-1/es/aws4_request")
+	require.Equal(t, "session", gotToken)
+	require.Contains(t, gotBody, "турция")
+}
@@ -0,0 +1,65 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// similarFields are compared by SimilarNews; text_clean rather than text
+// keeps hashtags and contact lines from making every post of a channel
+// look alike.
+var similarFields = []string{"title", "text_clean", "keyword_text"}
+
+// SimilarNews returns up to size documents about the same deal or
+// destination as the document with the given ID, most similar first, such
+// as other agencies' offers for the same tour. Documents sharing a
+// destination with it rank higher. With activeOnly, expired offers are
+// left out. It returns an error wrapping ErrNotFound when no document has
+// that ID.
+func (c *Client) SimilarNews(ctx context.Context, id string, size int, activeOnly bool) (*SearchResult, error) {
+	doc, err := c.GetNewsByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// more_like_this fetches the document itself, which takes a concrete
+	// index rather than the daily indices alias.
+	index := c.index
+	if c.daily {
+		found, err := c.locate(ctx, []string{id})
+		if err != nil {
+			return nil, fmt.Errorf("locate document: %w", err)
+		}
+		index = found[id]
+	}
+
+	query := esquery.Bool{
+		Must: []esquery.Query{esquery.MoreLikeThis{
+			Fields: similarFields,
+			Index:  index,
+			IDs:    []string{id},
+			// Posts are short: a term seen once is already significant.
+			MinTermFreq:        1,
+			MinDocFreq:         2,
+			MaxQueryTerms:      25,
+			MinimumShouldMatch: "30%",
+		}},
+	}
+	for _, destination := range doc.Destinations {
+		query.Should = append(query.Should, esquery.Term{Field: "destinations", Value: destination, Boost: 2})
+	}
+	if activeOnly {
+		query.MustNot = append(query.MustNot,
+			esquery.Range{Field: "expires_at", LTE: "now"},
+			esquery.Term{Field: "status", Value: models.StatusExpired},
+		)
+	}
+
+	return c.runSearch(ctx, map[string]any{
+		"size":             size,
+		"track_total_hits": true,
+		"query":            query.Source(),
+	})
+}
@@ -0,0 +1,77 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimilarNews(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/news/_doc/a1":
+			_, _ = io.WriteString(w, `{"_id":"a1","found":true,"_source":{"id":"a1","destinations":["турция"]}}`)
+		case "/news/_search":
+			var body struct {
+				Size  int `json:"size"`
+				Query struct {
+					Bool struct {
+						Must []struct {
+							MoreLikeThis struct {
+								Fields []string            `json:"fields"`
+								Like   []map[string]string `json:"like"`
+							} `json:"more_like_this"`
+						} `json:"must"`
+						Should  []map[string]any `json:"should"`
+						MustNot []map[string]any `json:"must_not"`
+					} `json:"bool"`
+				} `json:"query"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.Equal(t, 5, body.Size)
+			require.Len(t, body.Query.Bool.Must, 1)
+			mlt := body.Query.Bool.Must[0].MoreLikeThis
+			require.Equal(t, similarFields, mlt.Fields)
+			require.Equal(t, []map[string]string{{"_index": "news", "_id": "a1"}}, mlt.Like)
+			require.Len(t, body.Query.Bool.Should, 1)
+			require.Len(t, body.Query.Bool.MustNot, 2)
+
+			_, _ = io.WriteString(w, `{"hits":{"total":{"value":1},"hits":[{"_source":{"id":"b2","title":"Кемер от 40 000"}}]}}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	result, err := client.SimilarNews(context.Background(), "a1", 5, true)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, result.Total)
+	require.Equal(t, "b2", result.Items[0].ID)
+}
+
+func TestSimilarNewsMissingDocument(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"_id":"nope","found":false}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	_, err = client.SimilarNews(context.Background(), "nope", 5, false)
+	require.ErrorIs(t, err, ErrNotFound)
+}
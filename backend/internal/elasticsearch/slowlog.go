@@ -0,0 +1,148 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"expvar"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// slowQueries counts requests LogSlowQueries reported, per operation.
+var slowQueries = expvar.NewMap("elasticsearch_slow_queries")
+
+const (
+	// slowQueryBytes is how much of a slow request's body is logged.
+	slowQueryBytes = 2048
+	// slowResponsePeek is how much of a slow response is read ahead for
+	// took and the hit count, which Elasticsearch writes first.
+	slowResponsePeek = 1024
+)
+
+var (
+	tookRe = regexp.MustCompile(`"took":(\d+)`)
+	hitsRe = regexp.MustCompile(`"hits":\{"total":\{"value":(\d+)`)
+)
+
+// LogSlowQueries logs every request to the cluster that takes threshold or
+// longer as a warning with the operation, the start of the request body,
+// the time Elasticsearch reports it took and, for searches, the hit count,
+// and counts it under elasticsearch_slow_queries at /debug/vars. Slow
+// queries thus show without enabling the cluster's slow logs. A threshold
+// of zero disables it.
+func LogSlowQueries(threshold time.Duration, log *slog.Logger) Option {
+	return func(cfg *elasticsearch.Config) {
+		if threshold <= 0 || log == nil {
+			return
+		}
+		base := cfg.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		cfg.Transport = &slowLogTransport{base: base, threshold: threshold, log: log}
+	}
+}
+
+type slowLogTransport struct {
+	base      http.RoundTripper
+	threshold time.Duration
+	log       *slog.Logger
+}
+
+func (t *slowLogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body *headRecorder
+	if req.Body != nil && req.Body != http.NoBody {
+		// RoundTrippers must not modify the caller's request.
+		req = req.Clone(req.Context())
+		body = &headRecorder{ReadCloser: req.Body}
+		req.Body = body
+	}
+
+	start := time.Now()
+	res, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start)
+	if elapsed < t.threshold {
+		return res, err
+	}
+
+	op := operation(req.URL.Path)
+	slowQueries.Add(op, 1)
+	attrs := []any{
+		slog.String("op", op),
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Duration("elapsed", elapsed),
+	}
+	if body != nil {
+		attrs = append(attrs, slog.String("query", body.String()))
+	}
+	if err != nil {
+		t.log.WarnContext(req.Context(), "slow elasticsearch request", append(attrs, slog.Any("err", err))...)
+		return res, err
+	}
+
+	attrs = append(attrs, slog.Int("status", res.StatusCode))
+	reader := bufio.NewReaderSize(res.Body, slowResponsePeek)
+	head, _ := reader.Peek(slowResponsePeek)
+	if m := tookRe.FindSubmatch(head); m != nil {
+		took, _ := strconv.Atoi(string(m[1]))
+		attrs = append(attrs, slog.Int("took_ms", took))
+	}
+	if m := hitsRe.FindSubmatch(head); m != nil {
+		hits, _ := strconv.ParseInt(string(m[1]), 10, 64)
+		attrs = append(attrs, slog.Int64("hits", hits))
+	}
+	res.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, res.Body}
+	t.log.WarnContext(req.Context(), "slow elasticsearch request", attrs...)
+	return res, nil
+}
+
+// operation names a request by its last API endpoint in path, such as
+// search for /news/_search or update_by_query, index for requests on an
+// index itself and cluster for the root.
+func operation(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "cluster"
+	}
+	segments := strings.Split(path, "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if name, ok := strings.CutPrefix(segments[i], "_"); ok && name != "" {
+			return name
+		}
+	}
+	return "index"
+}
+
+// headRecorder keeps the first slowQueryBytes read from a request body.
+type headRecorder struct {
+	io.ReadCloser
+	head      []byte
+	truncated bool
+}
+
+func (r *headRecorder) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	room := slowQueryBytes - len(r.head)
+	r.head = append(r.head, p[:min(n, room)]...)
+	r.truncated = r.truncated || n > room
+	return n, err
+}
+
+// String returns the recorded head; a character cut in half is dropped.
+func (r *headRecorder) String() string {
+	head := strings.ToValidUTF8(string(r.head), "")
+	if r.truncated {
+		return head + "…"
+	}
+	return head
+}
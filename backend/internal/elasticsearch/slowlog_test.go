@@ -0,0 +1,83 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogSlowQueries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if r.URL.Path == "/news/_search" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"took":17,"timed_out":false,"hits":{"total":{"value":3},"hits":[`+
+			`{"_source":{"id":"a"}},{"_source":{"id":"b"}},{"_source":{"id":"c"}}]}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	var logs bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&logs, nil))
+	client, err := New(srv.URL, "news", log, LogSlowQueries(10*time.Millisecond, log))
+	require.NoError(t, err)
+
+	searches := func() int64 {
+		if counter, ok := slowQueries.Get("search").(*expvar.Int); ok {
+			return counter.Value()
+		}
+		return 0
+	}
+	before := searches()
+	result, err := client.SearchNews(context.Background(), SearchParams{Query: strings.Repeat("турция ", 400)})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 3, "the peeked response is still read in full")
+
+	var entry struct {
+		Msg    string `json:"msg"`
+		Level  string `json:"level"`
+		Op     string `json:"op"`
+		Query  string `json:"query"`
+		TookMS int    `json:"took_ms"`
+		Hits   int64  `json:"hits"`
+		Status int    `json:"status"`
+	}
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	require.Equal(t, "slow elasticsearch request", entry.Msg)
+	require.Equal(t, "WARN", entry.Level)
+	require.Equal(t, "search", entry.Op)
+	require.Equal(t, 17, entry.TookMS)
+	require.EqualValues(t, 3, entry.Hits)
+	require.Equal(t, http.StatusOK, entry.Status)
+	require.True(t, strings.HasPrefix(entry.Query, `{"from":0`))
+	require.True(t, strings.HasSuffix(entry.Query, "…"))
+	require.LessOrEqual(t, len(entry.Query), slowQueryBytes+len("…"))
+
+	require.Equal(t, before+1, searches())
+
+	// Fast requests are not logged.
+	logs.Reset()
+	_, _ = client.GetNewsByID(context.Background(), "a")
+	require.Empty(t, logs.String())
+}
+
+func TestOperation(t *testing.T) {
+	require.Equal(t, "search", operation("/news/_search"))
+	require.Equal(t, "search", operation("/news/_search/template"))
+	require.Equal(t, "update_by_query", operation("/news/_update_by_query"))
+	require.Equal(t, "doc", operation("/news/_doc/abc"))
+	require.Equal(t, "index", operation("/news"))
+	require.Equal(t, "cluster", operation("/"))
+}
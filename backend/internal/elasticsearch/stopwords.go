@@ -0,0 +1,157 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// TermCount is a single terms-aggregation bucket.
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int64  `json:"count"`
+}
+
+// StopwordSuggestionsIndex stores the output of the stopword learning job.
+func (c *Client) StopwordSuggestionsIndex() string {
+	return c.index + "_stopword_suggestions"
+}
+
+// KeywordDocFrequencies counts, for the most common keywords, how many
+// documents since the given time carry them, along with the total number
+// of documents in that window.
+func (c *Client) KeywordDocFrequencies(ctx context.Context, since time.Time, size int) (int64, []TermCount, error) {
+	body := map[string]any{
+		"size":             0,
+		"track_total_hits": true,
+		"query":            esquery.Range{Field: "timestamp", GTE: since.UTC().Format(time.RFC3339)}.Source(),
+		"aggs": map[string]any{
+			"keywords": map[string]any{
+				"terms": map[string]any{"field": "keywords", "size": size},
+			},
+		},
+	}
+
+	var parsed struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+		Aggregations struct {
+			Keywords termsAggregation `json:"keywords"`
+		} `json:"aggregations"`
+	}
+	if err := c.searchInto(ctx, c.index, body, &parsed); err != nil {
+		return 0, nil, err
+	}
+
+	return parsed.Hits.Total.Value, parsed.Aggregations.Keywords.counts(), nil
+}
+
+// ReplaceStopwordSuggestions swaps the stored suggestions for a new set.
+func (c *Client) ReplaceStopwordSuggestions(ctx context.Context, suggestions []models.StopwordSuggestion) error {
+	index := c.StopwordSuggestionsIndex()
+
+	res, err := c.es.DeleteByQuery(
+		[]string{index},
+		bytes.NewReader([]byte(`{"query":{"match_all":{}}}`)),
+		c.es.DeleteByQuery.WithContext(ctx),
+		c.es.DeleteByQuery.WithConflicts("proceed"),
+		c.es.DeleteByQuery.WithRefresh(true),
+		c.es.DeleteByQuery.WithIgnoreUnavailable(true),
+		c.es.DeleteByQuery.WithAllowNoIndices(true),
+	)
+	if err != nil {
+		return transportError("clear stopword suggestions", err)
+	}
+	res.Body.Close()
+	if res.IsError() && res.StatusCode != 404 {
+		return responseError("clear stopword suggestions", res)
+	}
+
+	if len(suggestions) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, s := range suggestions {
+		if err := enc.Encode(map[string]any{"index": map[string]any{"_index": index, "_id": s.Token}}); err != nil {
+			return fmt.Errorf("encode bulk action: %w", err)
+		}
+		if err := enc.Encode(s); err != nil {
+			return fmt.Errorf("encode suggestion: %w", err)
+		}
+	}
+
+	bulk, err := c.es.Bulk(&buf, c.es.Bulk.WithContext(ctx), c.es.Bulk.WithRefresh("true"))
+	if err != nil {
+		return transportError("store stopword suggestions", err)
+	}
+	defer bulk.Body.Close()
+	if bulk.IsError() {
+		return responseError("store stopword suggestions", bulk)
+	}
+
+	var parsed struct {
+		Errors bool `json:"errors"`
+	}
+	if err := json.NewDecoder(bulk.Body).Decode(&parsed); err != nil {
+		return fmt.Errorf("decode bulk response: %w", err)
+	}
+	if parsed.Errors {
+		return fmt.Errorf("store stopword suggestions: bulk request reported item errors")
+	}
+	return nil
+}
+
+// StopwordSuggestions lists stored suggestions, most frequent first.
+func (c *Client) StopwordSuggestions(ctx context.Context, limit int) ([]models.StopwordSuggestion, error) {
+	body := map[string]any{
+		"size": limit,
+		"sort": []map[string]any{{"ratio": map[string]any{"order": "desc"}}},
+	}
+
+	var parsed struct {
+		Hits struct {
+			Hits []struct {
+				Source models.StopwordSuggestion `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := c.searchInto(ctx, c.StopwordSuggestionsIndex(), body, &parsed); err != nil {
+		// The index only appears after the first analytics run.
+		if errors.Is(err, ErrNotFound) {
+			return []models.StopwordSuggestion{}, nil
+		}
+		return nil, err
+	}
+
+	out := make([]models.StopwordSuggestion, 0, len(parsed.Hits.Hits))
+	for _, hit := range parsed.Hits.Hits {
+		out = append(out, hit.Source)
+	}
+	return out, nil
+}
+
+type termsAggregation struct {
+	Buckets []struct {
+		Key      string `json:"key"`
+		DocCount int64  `json:"doc_count"`
+	} `json:"buckets"`
+}
+
+func (a termsAggregation) counts() []TermCount {
+	out := make([]TermCount, 0, len(a.Buckets))
+	for _, b := range a.Buckets {
+		out = append(out, TermCount{Term: b.Key, Count: b.DocCount})
+	}
+	return out
+}
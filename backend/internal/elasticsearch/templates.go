@@ -0,0 +1,157 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Stored search templates for the hottest query shapes. The service only
+// sends parameters, and a template edited in the cluster (PUT _scripts/<id>)
+// changes relevance without a new build: EnsureTemplates never overwrites
+// a template that already exists. The ID carries a hash of the built-in
+// source, so a build that changes a template stores and uses a new one
+// instead of running the stale version left by an earlier build.
+const (
+	// latestTemplate is the default /news list: everything, newest first.
+	latestTemplate = "latest"
+	// trendingTemplate ranks documents since a start time by popularity.
+	trendingTemplate = "trending"
+)
+
+var templateSources = map[string]string{
+	latestTemplate: `{
+  "from": {{from}},
+  "size": {{size}},
+  "track_total_hits": true,
+  "query": {"bool": {"must": [{"match_all": {}}]}},
+  "sort": [{"timestamp": {"order": "desc"}}]
+}`,
+	trendingTemplate: `{
+  "from": {{from}},
+  "size": {{size}},
+  "track_total_hits": true,
+  "query": {
+    "function_score": {
+      "query": {"bool": {"filter": [{"range": {"timestamp": {"gte": "{{start}}"}}}]}},
+      "functions": [
+        {"field_value_factor": {"field": "seen_count", "factor": {{seen_weight}}, "modifier": "log1p", "missing": 1}},
+        {"field_value_factor": {"field": "clicks", "factor": {{click_weight}}, "modifier": "log1p", "missing": 0}}
+      ],
+      "score_mode": "sum",
+      "boost_mode": "sum"
+    }
+  },
+  "sort": [{"_score": {"order": "desc"}}, {"timestamp": {"order": "desc"}}]
+}`,
+}
+
+// templateID namespaces template names by index, so deployments sharing
+// a cluster keep their own templates, and versions them by their source.
+func (c *Client) templateID(name string) string {
+	return c.index + "-" + name + "-" + templateVersion(templateSources[name])
+}
+
+// templateVersion is the first 8 hex digits of the SHA-256 of source.
+func templateVersion(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:4])
+}
+
+// EnsureTemplates stores the search templates that are missing from the
+// cluster. Until it succeeds, searches use inline queries.
+func (c *Client) EnsureTemplates(ctx context.Context) error {
+	for name, source := range templateSources {
+		id := c.templateID(name)
+		res, err := c.es.GetScript(id, c.es.GetScript.WithContext(ctx))
+		if err != nil {
+			return transportError("get search template "+id, err)
+		}
+		res.Body.Close()
+
+		switch res.StatusCode {
+		case http.StatusOK:
+			continue
+		case http.StatusNotFound:
+			if err := c.putTemplate(ctx, id, source); err != nil {
+				return err
+			}
+			c.log.Info("stored search template", slog.String("template", id))
+		default:
+			return &StatusError{Op: "get search template " + id, Status: res.StatusCode, Kind: kindForStatus(res.StatusCode)}
+		}
+	}
+	c.templates.Store(true)
+	return nil
+}
+
+func (c *Client) putTemplate(ctx context.Context, id, source string) error {
+	payload, err := json.Marshal(map[string]any{
+		"script": map[string]any{"lang": "mustache", "source": source},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal search template: %w", err)
+	}
+
+	res, err := c.es.PutScript(id, bytes.NewReader(payload), c.es.PutScript.WithContext(ctx))
+	if err != nil {
+		return transportError("put search template "+id, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return responseError("put search template "+id, res)
+	}
+	return nil
+}
+
+// searchTemplate picks the stored template that answers params, if any,
+// together with its parameters. params must already be normalized by
+// searchOnce.
+func searchTemplate(params SearchParams) (string, map[string]any, bool) {
+	// The templates take a start but no end, and rank without keyword boosts.
+	if params.Filtered() || params.End != nil || len(params.BoostKeywords) > 0 {
+		return "", nil, false
+	}
+
+	values := map[string]any{"from": params.From, "size": params.Size}
+	switch {
+	case params.Start == nil && (params.Sort == "" || params.Sort == "timestamp:desc"):
+		return latestTemplate, values, true
+	case params.Start != nil && isRelevanceSort(params.Sort):
+		values["start"] = params.Start.UTC().Format(time.RFC3339)
+		values["seen_weight"] = params.Popularity.SeenWeight
+		values["click_weight"] = params.Popularity.ClickWeight
+		return trendingTemplate, values, true
+	}
+	return "", nil, false
+}
+
+// runTemplate executes a stored search template.
+func (c *Client) runTemplate(ctx context.Context, name string, params map[string]any) (*SearchResult, error) {
+	id := c.templateID(name)
+	payload, err := json.Marshal(map[string]any{"id": id, "params": params})
+	if err != nil {
+		return nil, fmt.Errorf("marshal search template params: %w", err)
+	}
+
+	res, err := c.es.SearchTemplate(bytes.NewReader(payload),
+		c.es.SearchTemplate.WithContext(ctx),
+		c.es.SearchTemplate.WithIndex(c.index),
+	)
+	if err != nil {
+		return nil, transportError("search template "+id, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, responseError("search template "+id, res)
+	}
+	return decodeSearchResult(res.Body)
+}
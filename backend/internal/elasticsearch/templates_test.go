@@ -0,0 +1,129 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchTemplate(t *testing.T) {
+	name, values, ok := searchTemplate(SearchParams{From: 20, Size: 10})
+	require.True(t, ok)
+	require.Equal(t, latestTemplate, name)
+	require.Equal(t, map[string]any{"from": 20, "size": 10}, values)
+
+	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.FixedZone("MSK", 3*3600))
+	name, values, ok = searchTemplate(SearchParams{Size: 20, Sort: "relevance", Start: &start, Popularity: PopularityBoost{SeenWeight: 1}})
+	require.True(t, ok)
+	require.Equal(t, trendingTemplate, name)
+	require.Equal(t, "2024-06-01T07:00:00Z", values["start"])
+	require.Equal(t, 0.0, values["click_weight"])
+
+	for _, params := range []SearchParams{
+		{Query: "турция"},
+		{Source: "telegram"},
+		{Sort: "price:asc"},
+		{Sort: "relevance"},
+		{Start: &start},
+		{Start: &start, End: &start, Sort: "relevance"},
+		{MaxPrice: 50000},
+		{UnseenBy: "reader"},
+		{BoostKeywords: []KeywordBoost{{Keyword: "горящие", Weight: 2}}},
+	} {
+		_, _, ok := searchTemplate(params)
+		require.False(t, ok, "%+v", params)
+	}
+}
+
+func TestTemplateIDIsVersioned(t *testing.T) {
+	client := &Client{index: "news"}
+	id := client.templateID(latestTemplate)
+	require.Regexp(t, `^news-latest-[0-9a-f]{8}$`, id)
+	require.NotEqual(t, id, client.templateID(trendingTemplate))
+
+	saved := templateSources[latestTemplate]
+	t.Cleanup(func() { templateSources[latestTemplate] = saved })
+	templateSources[latestTemplate] = saved + " "
+	require.NotEqual(t, id, client.templateID(latestTemplate), "a changed source gets a new ID")
+}
+
+func TestSearchNewsUsesStoredTemplates(t *testing.T) {
+	latestID := "news-latest-" + templateVersion(templateSources[latestTemplate])
+	trendingID := "news-trending-" + templateVersion(templateSources[trendingTemplate])
+	var calls []string
+	deleted := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/_scripts/"+latestID:
+			_, _ = io.WriteString(w, `{"_id":"`+latestID+`","found":true}`)
+		case r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"found":false}`)
+		case r.Method == http.MethodPut:
+			require.Equal(t, "/_scripts/"+trendingID, r.URL.Path)
+			var body struct {
+				Script struct {
+					Lang   string `json:"lang"`
+					Source string `json:"source"`
+				} `json:"script"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.Equal(t, "mustache", body.Script.Lang)
+			require.Contains(t, body.Script.Source, "{{seen_weight}}")
+			_, _ = io.WriteString(w, `{"acknowledged":true}`)
+		case r.URL.Path == "/news/_search/template":
+			if deleted {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = io.WriteString(w, `{"error":{"type":"resource_not_found_exception","reason":"unable to find script [`+latestID+`] in cluster state"},"status":404}`)
+				return
+			}
+			var body struct {
+				ID     string         `json:"id"`
+				Params map[string]any `json:"params"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.Equal(t, latestID, body.ID)
+			require.Equal(t, map[string]any{"from": 0.0, "size": 20.0}, body.Params)
+			_, _ = io.WriteString(w, `{"hits":{"total":{"value":1},"hits":[{"_source":{"id":"a"}}]}}`)
+		default:
+			_, _ = io.WriteString(w, `{"hits":{"total":{"value":1},"hits":[{"_source":{"id":"b"}}]}}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Without stored templates every search is inline.
+	result, err := client.SearchNews(ctx, SearchParams{})
+	require.NoError(t, err)
+	require.Equal(t, "b", result.Items[0].ID)
+
+	calls = nil
+	require.NoError(t, client.EnsureTemplates(ctx))
+	require.ElementsMatch(t, []string{"GET /_scripts/" + latestID, "GET /_scripts/" + trendingID, "PUT /_scripts/" + trendingID}, calls)
+
+	result, err = client.SearchNews(ctx, SearchParams{})
+	require.NoError(t, err)
+	require.Equal(t, "a", result.Items[0].ID)
+
+	result, err = client.SearchNews(ctx, SearchParams{Query: "турция"})
+	require.NoError(t, err)
+	require.Equal(t, "b", result.Items[0].ID)
+
+	deleted = true
+	result, err = client.SearchNews(ctx, SearchParams{})
+	require.NoError(t, err)
+	require.Equal(t, "b", result.Items[0].ID)
+}
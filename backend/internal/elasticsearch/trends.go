@@ -0,0 +1,214 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+)
+
+// trendIntervals maps the bucket sizes accepted by KeywordTrends to
+// Elasticsearch calendar intervals.
+var trendIntervals = map[string]string{
+	"hour": "1h",
+	"day":  "1d",
+}
+
+// TrendOptions selects the window KeywordTrends reports on. The previous
+// window has the same length and ends where the current one starts.
+type TrendOptions struct {
+	// Interval is the histogram bucket size, "hour" or "day".
+	Interval string
+	Start    time.Time
+	End      time.Time
+	// Size is the number of keywords, the most frequent in the window first.
+	Size int
+	// AggregatedBefore, when set, counts the days before it from the daily
+	// aggregates instead of documents. Aggregates hold no other fields, so
+	// the search parameters must not be Filtered, and only the day interval
+	// is supported.
+	AggregatedBefore time.Time
+}
+
+// TrendBucket counts a keyword's documents in one histogram interval.
+type TrendBucket struct {
+	Time  time.Time `json:"time"`
+	Count int64     `json:"count"`
+}
+
+// KeywordTrend is a keyword's frequency in the current window, bucketed by
+// interval, compared with the previous window.
+type KeywordTrend struct {
+	Keyword  string        `json:"keyword"`
+	Count    int64         `json:"count"`
+	Previous int64         `json:"previous"`
+	Rising   float64       `json:"rising"`
+	Buckets  []TrendBucket `json:"buckets"`
+}
+
+// TrendResult holds the keyword trends of a window.
+type TrendResult struct {
+	Interval      string         `json:"interval"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	PreviousStart time.Time      `json:"previous_start"`
+	Keywords      []KeywordTrend `json:"keywords"`
+}
+
+// RisingScore compares a keyword's count with its count in the previous
+// window: 0 is unchanged, 1 is twice as frequent, -0.5 half as frequent.
+// Adding one to the previous count keeps new rare keywords from scoring
+// higher than established ones that grew.
+func RisingScore(count, previous int64) float64 {
+	return float64(count-previous) / float64(previous+1)
+}
+
+// KeywordTrends counts the most frequent keywords among documents matching
+// params between opts.Start and opts.End, per interval, together with their
+// count in the previous window. The time range, sort and pagination of
+// params are ignored.
+func (c *Client) KeywordTrends(ctx context.Context, params SearchParams, opts TrendOptions) (*TrendResult, error) {
+	interval, ok := trendIntervals[opts.Interval]
+	if !ok {
+		return nil, &StatusError{Op: "keyword trends", Kind: ErrBadRequest, Err: fmt.Errorf("unknown trend interval %q", opts.Interval)}
+	}
+	if !opts.End.After(opts.Start) {
+		return nil, &StatusError{Op: "keyword trends", Kind: ErrBadRequest, Err: errors.New("trend window must end after it starts")}
+	}
+	size := min(max(opts.Size, 1), MaxAggregationSize)
+	if !opts.AggregatedBefore.IsZero() {
+		if params.Filtered() || opts.Interval != "day" {
+			return nil, &StatusError{Op: "keyword trends", Kind: ErrBadRequest, Err: errors.New("daily aggregates need unfiltered day trends")}
+		}
+		return c.aggregatedKeywordTrends(ctx, opts, size)
+	}
+
+	start, end := opts.Start.UTC(), opts.End.UTC()
+	previousStart := start.Add(-end.Sub(start))
+	params.Start, params.End = &previousStart, &end
+
+	window := func(from, to time.Time) map[string]any {
+		return esquery.Range{Field: "timestamp", GTE: from.Format(time.RFC3339), LT: to.Format(time.RFC3339)}.Source()
+	}
+	body := map[string]any{
+		"size":  0,
+		"query": buildQuery(params).Source(),
+		"aggs": map[string]any{
+			"keywords": map[string]any{
+				"terms": map[string]any{"field": "keywords", "size": size, "order": map[string]any{"current": "desc"}},
+				"aggs": map[string]any{
+					"current": map[string]any{
+						"filter": window(start, end),
+						"aggs": map[string]any{
+							"histogram": map[string]any{"date_histogram": map[string]any{
+								"field":             "timestamp",
+								"calendar_interval": interval,
+								"min_doc_count":     0,
+								"extended_bounds":   map[string]any{"min": start.UnixMilli(), "max": end.UnixMilli()},
+							}},
+						},
+					},
+					"previous": map[string]any{"filter": window(previousStart, start)},
+				},
+			},
+		},
+	}
+
+	var parsed struct {
+		Aggregations struct {
+			Keywords struct {
+				Buckets []struct {
+					Key     string `json:"key"`
+					Current struct {
+						DocCount  int64 `json:"doc_count"`
+						Histogram struct {
+							Buckets []struct {
+								Key      int64 `json:"key"`
+								DocCount int64 `json:"doc_count"`
+							} `json:"buckets"`
+						} `json:"histogram"`
+					} `json:"current"`
+					Previous struct {
+						DocCount int64 `json:"doc_count"`
+					} `json:"previous"`
+				} `json:"buckets"`
+			} `json:"keywords"`
+		} `json:"aggregations"`
+	}
+	if err := c.searchInto(ctx, c.index, body, &parsed); err != nil {
+		return nil, err
+	}
+
+	result := &TrendResult{
+		Interval:      opts.Interval,
+		Start:         start,
+		End:           end,
+		PreviousStart: previousStart,
+		Keywords:      []KeywordTrend{},
+	}
+	for _, b := range parsed.Aggregations.Keywords.Buckets {
+		// Keywords seen only in the previous window sort last.
+		if b.Current.DocCount == 0 {
+			continue
+		}
+		trend := KeywordTrend{
+			Keyword:  b.Key,
+			Count:    b.Current.DocCount,
+			Previous: b.Previous.DocCount,
+			Rising:   RisingScore(b.Current.DocCount, b.Previous.DocCount),
+			Buckets:  make([]TrendBucket, 0, len(b.Current.Histogram.Buckets)),
+		}
+		for _, h := range b.Current.Histogram.Buckets {
+			trend.Buckets = append(trend.Buckets, TrendBucket{Time: time.UnixMilli(h.Key).UTC(), Count: h.DocCount})
+		}
+		result.Keywords = append(result.Keywords, trend)
+	}
+	return result, nil
+}
+
+// NewsHistogram counts the documents matching params per hour or day
+// ("hour" or "day", like TrendOptions.Interval). Empty intervals between
+// the first and last document, or between params.Start and params.End when
+// set, are included. Sort and pagination of params are ignored.
+func (c *Client) NewsHistogram(ctx context.Context, params SearchParams, interval string) ([]TrendBucket, error) {
+	calendar, ok := trendIntervals[interval]
+	if !ok {
+		return nil, &StatusError{Op: "news histogram", Kind: ErrBadRequest, Err: fmt.Errorf("unknown histogram interval %q", interval)}
+	}
+
+	histogram := map[string]any{
+		"field":             "timestamp",
+		"calendar_interval": calendar,
+		"min_doc_count":     0,
+	}
+	if params.Start != nil && params.End != nil {
+		histogram["extended_bounds"] = map[string]any{"min": params.Start.UnixMilli(), "max": params.End.UnixMilli()}
+	}
+	body := map[string]any{
+		"size":  0,
+		"query": buildQuery(params).Source(),
+		"aggs":  map[string]any{"histogram": map[string]any{"date_histogram": histogram}},
+	}
+
+	var parsed struct {
+		Aggregations struct {
+			Histogram struct {
+				Buckets []struct {
+					Key      int64 `json:"key"`
+					DocCount int64 `json:"doc_count"`
+				} `json:"buckets"`
+			} `json:"histogram"`
+		} `json:"aggregations"`
+	}
+	if err := c.searchInto(ctx, c.index, body, &parsed); err != nil {
+		return nil, err
+	}
+
+	buckets := make([]TrendBucket, 0, len(parsed.Aggregations.Histogram.Buckets))
+	for _, b := range parsed.Aggregations.Histogram.Buckets {
+		buckets = append(buckets, TrendBucket{Time: time.UnixMilli(b.Key).UTC(), Count: b.DocCount})
+	}
+	return buckets, nil
+}
@@ -0,0 +1,128 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeywordTrends(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/news/_search", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{
+			"hits": {"total": {"value": 9}},
+			"aggregations": {"keywords": {"buckets": [
+				{"key": "турция", "doc_count": 7,
+					"current": {"doc_count": 5, "histogram": {"buckets": [
+						{"key": 1717214400000, "doc_count": 2},
+						{"key": 1717218000000, "doc_count": 3}
+					]}},
+					"previous": {"doc_count": 2}},
+				{"key": "египет", "doc_count": 2,
+					"current": {"doc_count": 0, "histogram": {"buckets": []}},
+					"previous": {"doc_count": 2}}
+			]}}
+		}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	end := time.Date(2024, 6, 1, 6, 0, 0, 0, time.UTC)
+	result, err := client.KeywordTrends(context.Background(), SearchParams{Source: "telegram"}, TrendOptions{
+		Interval: "hour",
+		Start:    end.Add(-2 * time.Hour),
+		End:      end,
+		Size:     10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, end.Add(-4*time.Hour), result.PreviousStart)
+	// Keywords only found in the previous window are left out.
+	require.Equal(t, []KeywordTrend{{
+		Keyword:  "турция",
+		Count:    5,
+		Previous: 2,
+		Rising:   1,
+		Buckets: []TrendBucket{
+			{Time: time.Date(2024, 6, 1, 4, 0, 0, 0, time.UTC), Count: 2},
+			{Time: time.Date(2024, 6, 1, 5, 0, 0, 0, time.UTC), Count: 3},
+		},
+	}}, result.Keywords)
+
+	// The query spans both windows; each keyword splits its count between them.
+	filter := body["query"].(map[string]any)["bool"].(map[string]any)["filter"].([]any)
+	require.Contains(t, filter, map[string]any{"range": map[string]any{"timestamp": map[string]any{
+		"gte": "2024-06-01T02:00:00Z",
+		"lte": "2024-06-01T06:00:00Z",
+	}}})
+	keywords := body["aggs"].(map[string]any)["keywords"].(map[string]any)
+	require.Equal(t, map[string]any{"current": "desc"}, keywords["terms"].(map[string]any)["order"])
+	current := keywords["aggs"].(map[string]any)["current"].(map[string]any)
+	require.Equal(t, map[string]any{"range": map[string]any{"timestamp": map[string]any{
+		"gte": "2024-06-01T04:00:00Z",
+		"lt":  "2024-06-01T06:00:00Z",
+	}}}, current["filter"])
+	histogram := current["aggs"].(map[string]any)["histogram"].(map[string]any)["date_histogram"].(map[string]any)
+	require.Equal(t, "1h", histogram["calendar_interval"])
+
+	_, err = client.KeywordTrends(context.Background(), SearchParams{}, TrendOptions{Interval: "week", Start: end.Add(-time.Hour), End: end})
+	require.True(t, errors.Is(err, ErrBadRequest))
+}
+
+func TestRisingScore(t *testing.T) {
+	require.Zero(t, RisingScore(3, 3))
+	require.Equal(t, 0.25, RisingScore(4, 3))
+	require.Equal(t, -0.5, RisingScore(1, 3))
+	// A keyword new in the window scores its count.
+	require.Equal(t, 5.0, RisingScore(5, 0))
+}
+
+func TestNewsHistogram(t *testing.T) {
+	var body struct {
+		Aggs struct {
+			Histogram struct {
+				DateHistogram map[string]any `json:"date_histogram"`
+			} `json:"histogram"`
+		} `json:"aggs"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"aggregations": {"histogram": {"buckets": [
+			{"key": 1717200000000, "doc_count": 4},
+			{"key": 1717286400000, "doc_count": 0}
+		]}}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(srv.URL, "news", nil)
+	require.NoError(t, err)
+
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(48 * time.Hour)
+	buckets, err := client.NewsHistogram(context.Background(), SearchParams{Start: &start, End: &end}, "day")
+	require.NoError(t, err)
+	require.Equal(t, []TrendBucket{
+		{Time: start, Count: 4},
+		{Time: start.Add(24 * time.Hour), Count: 0},
+	}, buckets)
+	require.Equal(t, "1d", body.Aggs.Histogram.DateHistogram["calendar_interval"])
+	require.NotNil(t, body.Aggs.Histogram.DateHistogram["extended_bounds"])
+
+	_, err = client.NewsHistogram(context.Background(), SearchParams{}, "week")
+	require.ErrorIs(t, err, ErrBadRequest)
+}
@@ -0,0 +1,281 @@
+// Package esquery builds Elasticsearch query DSL clauses from typed values,
+// so search, aggregations, retention and alerting compose the same shapes
+// instead of each nesting map[string]any by hand.
+package esquery
+
+// Query is a query DSL clause.
+type Query interface {
+	// Source returns the clause as it is sent to Elasticsearch, for example
+	// {"term": {"source": "telegram"}}.
+	Source() map[string]any
+}
+
+// Bool combines clauses. Empty clause lists are left out of the request.
+type Bool struct {
+	Must    []Query
+	Filter  []Query
+	Should  []Query
+	MustNot []Query
+	// MinimumShouldMatch is sent only when set. Elasticsearch defaults it to
+	// 1 when there is no must or filter clause, and to 0 otherwise.
+	MinimumShouldMatch *int
+}
+
+func (q Bool) Source() map[string]any {
+	body := map[string]any{}
+	for name, clauses := range map[string][]Query{
+		"must":     q.Must,
+		"filter":   q.Filter,
+		"should":   q.Should,
+		"must_not": q.MustNot,
+	} {
+		if len(clauses) > 0 {
+			body[name] = sources(clauses)
+		}
+	}
+	if q.MinimumShouldMatch != nil {
+		body["minimum_should_match"] = *q.MinimumShouldMatch
+	}
+	return map[string]any{"bool": body}
+}
+
+// MatchAll matches every document.
+type MatchAll struct{}
+
+func (MatchAll) Source() map[string]any {
+	return map[string]any{"match_all": map[string]any{}}
+}
+
+// Term matches documents whose Field equals Value exactly. A non-zero Boost
+// weighs the clause when it is used for scoring.
+type Term struct {
+	Field string
+	Value any
+	Boost float64
+}
+
+func (q Term) Source() map[string]any {
+	if q.Boost == 0 {
+		return map[string]any{"term": map[string]any{q.Field: q.Value}}
+	}
+	return map[string]any{"term": map[string]any{
+		q.Field: map[string]any{"value": q.Value, "boost": q.Boost},
+	}}
+}
+
+// Terms matches documents whose Field equals any of Values.
+type Terms struct {
+	Field  string
+	Values []string
+}
+
+func (q Terms) Source() map[string]any {
+	return map[string]any{"terms": map[string]any{q.Field: q.Values}}
+}
+
+// TermsLookup matches documents whose Field equals any of the values
+// stored under Path in document ID of Index, so a long list is kept in
+// Elasticsearch instead of being sent with every query. A missing document
+// matches nothing.
+type TermsLookup struct {
+	Field string
+	Index string
+	ID    string
+	Path  string
+}
+
+func (q TermsLookup) Source() map[string]any {
+	return map[string]any{"terms": map[string]any{
+		q.Field: map[string]any{"index": q.Index, "id": q.ID, "path": q.Path},
+	}}
+}
+
+// Range matches documents whose Field lies within the bounds. Nil bounds are
+// open; dates are usually RFC 3339 strings or date math such as "now". LT
+// excludes its bound.
+type Range struct {
+	Field string
+	GTE   any
+	LTE   any
+	LT    any
+}
+
+func (q Range) Source() map[string]any {
+	bounds := map[string]any{}
+	if q.GTE != nil {
+		bounds["gte"] = q.GTE
+	}
+	if q.LTE != nil {
+		bounds["lte"] = q.LTE
+	}
+	if q.LT != nil {
+		bounds["lt"] = q.LT
+	}
+	return map[string]any{"range": map[string]any{q.Field: bounds}}
+}
+
+// Exists matches documents that have a value for Field.
+type Exists struct {
+	Field string
+}
+
+func (q Exists) Source() map[string]any {
+	return map[string]any{"exists": map[string]any{"field": q.Field}}
+}
+
+// GeoBoundingBox matches documents with a geo_point in Field inside the
+// box, given in degrees. A box whose Left lies east of its Right crosses
+// the antimeridian.
+type GeoBoundingBox struct {
+	Field  string
+	Top    float64
+	Left   float64
+	Bottom float64
+	Right  float64
+}
+
+func (q GeoBoundingBox) Source() map[string]any {
+	return map[string]any{"geo_bounding_box": map[string]any{q.Field: map[string]any{
+		"top_left":     map[string]any{"lat": q.Top, "lon": q.Left},
+		"bottom_right": map[string]any{"lat": q.Bottom, "lon": q.Right},
+	}}}
+}
+
+// MultiMatch runs a full-text query against several fields; a field may
+// carry a boost suffix such as "title^2". Type "phrase" matches the words
+// in order; empty keeps "best_fields". Fuzziness, when set, tolerates typos
+// ("AUTO" scales the edit distance with the term length); Elasticsearch
+// rejects it for phrases. MinimumShouldMatch, such as "60%", sets how many
+// of the words must match.
+type MultiMatch struct {
+	Query              string
+	Fields             []string
+	Type               string
+	Fuzziness          string
+	MinimumShouldMatch string
+}
+
+func (q MultiMatch) Source() map[string]any {
+	match := map[string]any{
+		"query":  q.Query,
+		"fields": q.Fields,
+	}
+	if q.Type != "" {
+		match["type"] = q.Type
+	}
+	if q.Fuzziness != "" {
+		match["fuzziness"] = q.Fuzziness
+	}
+	if q.MinimumShouldMatch != "" {
+		match["minimum_should_match"] = q.MinimumShouldMatch
+	}
+	return map[string]any{"multi_match": match}
+}
+
+// MoreLikeThis finds documents whose Fields share the most significant
+// terms with the documents with IDs in Index. Zero MinTermFreq, MinDocFreq
+// and MaxQueryTerms keep the Elasticsearch defaults; the liked documents
+// themselves are not returned.
+type MoreLikeThis struct {
+	Fields             []string
+	Index              string
+	IDs                []string
+	MinTermFreq        int
+	MinDocFreq         int
+	MaxQueryTerms      int
+	MinimumShouldMatch string
+}
+
+func (q MoreLikeThis) Source() map[string]any {
+	like := make([]map[string]any, 0, len(q.IDs))
+	for _, id := range q.IDs {
+		like = append(like, map[string]any{"_index": q.Index, "_id": id})
+	}
+	mlt := map[string]any{
+		"fields": q.Fields,
+		"like":   like,
+	}
+	if q.MinTermFreq > 0 {
+		mlt["min_term_freq"] = q.MinTermFreq
+	}
+	if q.MinDocFreq > 0 {
+		mlt["min_doc_freq"] = q.MinDocFreq
+	}
+	if q.MaxQueryTerms > 0 {
+		mlt["max_query_terms"] = q.MaxQueryTerms
+	}
+	if q.MinimumShouldMatch != "" {
+		mlt["minimum_should_match"] = q.MinimumShouldMatch
+	}
+	return map[string]any{"more_like_this": mlt}
+}
+
+// FunctionScore rescores the documents matched by Query with Functions.
+// ScoreMode combines the function scores, BoostMode combines the result
+// with the query score; empty modes keep the Elasticsearch defaults.
+type FunctionScore struct {
+	Query     Query
+	Functions []ScoreFunction
+	ScoreMode string
+	BoostMode string
+}
+
+func (q FunctionScore) Source() map[string]any {
+	body := map[string]any{
+		"query":     q.Query.Source(),
+		"functions": sources(q.Functions),
+	}
+	if q.ScoreMode != "" {
+		body["score_mode"] = q.ScoreMode
+	}
+	if q.BoostMode != "" {
+		body["boost_mode"] = q.BoostMode
+	}
+	return map[string]any{"function_score": body}
+}
+
+// ScoreFunction is one of the functions of a FunctionScore.
+type ScoreFunction interface {
+	Source() map[string]any
+}
+
+// FieldValueFactor scores documents by a numeric field: Modifier (such as
+// "log1p") applied to the field value times Factor. Documents without the
+// field count as Missing.
+type FieldValueFactor struct {
+	Field    string
+	Factor   float64
+	Modifier string
+	Missing  float64
+}
+
+func (f FieldValueFactor) Source() map[string]any {
+	body := map[string]any{
+		"field":   f.Field,
+		"factor":  f.Factor,
+		"missing": f.Missing,
+	}
+	if f.Modifier != "" {
+		body["modifier"] = f.Modifier
+	}
+	return map[string]any{"field_value_factor": body}
+}
+
+// RandomScore scores documents pseudo-randomly; the order is stable for a
+// given Seed and Field.
+type RandomScore struct {
+	Seed  int64
+	Field string
+}
+
+func (f RandomScore) Source() map[string]any {
+	return map[string]any{"random_score": map[string]any{"seed": f.Seed, "field": f.Field}}
+}
+
+func sources[T interface{ Source() map[string]any }](clauses []T) []map[string]any {
+	out := make([]map[string]any, 0, len(clauses))
+	for _, clause := range clauses {
+		out = append(out, clause.Source())
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package esquery_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
+	"github.com/stretchr/testify/require"
+)
+
+func requireJSON(t *testing.T, want string, q esquery.Query) {
+	t.Helper()
+	got, err := json.Marshal(q.Source())
+	require.NoError(t, err)
+	require.JSONEq(t, want, string(got))
+}
+
+func TestLeafQueries(t *testing.T) {
+	requireJSON(t, `{"match_all":{}}`, esquery.MatchAll{})
+	requireJSON(t, `{"term":{"source":"telegram"}}`, esquery.Term{Field: "source", Value: "telegram"})
+	requireJSON(t, `{"term":{"chat_id":42}}`, esquery.Term{Field: "chat_id", Value: int64(42)})
+	requireJSON(t, `{"term":{"keywords":{"value":"турция","boost":2}}}`,
+		esquery.Term{Field: "keywords", Value: "турция", Boost: 2})
+	requireJSON(t, `{"terms":{"keywords":["пляж","отель"]}}`,
+		esquery.Terms{Field: "keywords", Values: []string{"пляж", "отель"}})
+	requireJSON(t, `{"exists":{"field":"price"}}`, esquery.Exists{Field: "price"})
+	requireJSON(t, `{"terms":{"id":{"index":"news_read_state","id":"reader","path":"doc_ids"}}}`,
+		esquery.TermsLookup{Field: "id", Index: "news_read_state", ID: "reader", Path: "doc_ids"})
+}
+
+func TestGeoBoundingBox(t *testing.T) {
+	requireJSON(t, `{"geo_bounding_box":{"locations":{"bottom_right":{"lat":20,"lon":40},"top_left":{"lat":45,"lon":25}}}}`,
+		esquery.GeoBoundingBox{Field: "locations", Top: 45, Left: 25, Bottom: 20, Right: 40})
+}
+
+func TestRange(t *testing.T) {
+	requireJSON(t, `{"range":{"timestamp":{"gte":"2024-06-01T00:00:00Z","lte":"now"}}}`,
+		esquery.Range{Field: "timestamp", GTE: "2024-06-01T00:00:00Z", LTE: "now"})
+	requireJSON(t, `{"range":{"expires_at":{"lte":"now"}}}`, esquery.Range{Field: "expires_at", LTE: "now"})
+	requireJSON(t, `{"range":{"price":{"gte":10000}}}`, esquery.Range{Field: "price", GTE: 10000})
+	requireJSON(t, `{"range":{"timestamp":{"gte":"2024-06-01T00:00:00Z","lt":"2024-06-02T00:00:00Z"}}}`,
+		esquery.Range{Field: "timestamp", GTE: "2024-06-01T00:00:00Z", LT: "2024-06-02T00:00:00Z"})
+}
+
+func TestMultiMatch(t *testing.T) {
+	fields := []string{"title^2", "text"}
+	requireJSON(t, `{"multi_match":{"query":"египет","fields":["title^2","text"]}}`,
+		esquery.MultiMatch{Query: "египет", Fields: fields})
+	requireJSON(t, `{"multi_match":{"query":"еипет","fields":["title^2","text"],"fuzziness":"AUTO"}}`,
+		esquery.MultiMatch{Query: "еипет", Fields: fields, Fuzziness: "AUTO"})
+	requireJSON(t, `{"multi_match":{"query":"турция кемер","fields":["title^2","text"],"type":"cross_fields","minimum_should_match":"60%"}}`,
+		esquery.MultiMatch{Query: "турция кемер", Fields: fields, Type: "cross_fields", MinimumShouldMatch: "60%"})
+}
+
+func TestMoreLikeThis(t *testing.T) {
+	requireJSON(t, `{"more_like_this":{"fields":["title","text"],"like":[{"_index":"news","_id":"a1"}]}}`,
+		esquery.MoreLikeThis{Fields: []string{"title", "text"}, Index: "news", IDs: []string{"a1"}})
+	requireJSON(t, `{"more_like_this":{
+		"fields":["text"],
+		"like":[{"_index":"news","_id":"a1"}],
+		"min_term_freq":1,
+		"min_doc_freq":2,
+		"max_query_terms":25,
+		"minimum_should_match":"30%"
+	}}`, esquery.MoreLikeThis{
+		Fields: []string{"text"}, Index: "news", IDs: []string{"a1"},
+		MinTermFreq: 1, MinDocFreq: 2, MaxQueryTerms: 25, MinimumShouldMatch: "30%",
+	})
+}
+
+func TestBool(t *testing.T) {
+	requireJSON(t, `{"bool":{}}`, esquery.Bool{})
+
+	zero := 0
+	requireJSON(t, `{"bool":{
+		"must":[{"match_all":{}}],
+		"filter":[{"term":{"source":"rss"}},{"exists":{"field":"urls"}}],
+		"should":[{"term":{"keywords":{"value":"море","boost":0.5}}}],
+		"must_not":[{"range":{"expires_at":{"lte":"now"}}}],
+		"minimum_should_match":0
+	}}`, esquery.Bool{
+		Must:               []esquery.Query{esquery.MatchAll{}},
+		Filter:             []esquery.Query{esquery.Term{Field: "source", Value: "rss"}, esquery.Exists{Field: "urls"}},
+		Should:             []esquery.Query{esquery.Term{Field: "keywords", Value: "море", Boost: 0.5}},
+		MustNot:            []esquery.Query{esquery.Range{Field: "expires_at", LTE: "now"}},
+		MinimumShouldMatch: &zero,
+	})
+}
+
+func TestFunctionScore(t *testing.T) {
+	requireJSON(t, `{"function_score":{
+		"query":{"match_all":{}},
+		"functions":[
+			{"field_value_factor":{"field":"seen_count","factor":2,"modifier":"log1p","missing":1}},
+			{"random_score":{"seed":7,"field":"_seq_no"}}
+		],
+		"score_mode":"sum",
+		"boost_mode":"sum"
+	}}`, esquery.FunctionScore{
+		Query: esquery.MatchAll{},
+		Functions: []esquery.ScoreFunction{
+			esquery.FieldValueFactor{Field: "seen_count", Factor: 2, Modifier: "log1p", Missing: 1},
+			esquery.RandomScore{Seed: 7, Field: "_seq_no"},
+		},
+		ScoreMode: "sum",
+		BoostMode: "sum",
+	})
+
+	requireJSON(t, `{"function_score":{"query":{"match_all":{}},"functions":[]}}`,
+		esquery.FunctionScore{Query: esquery.MatchAll{}})
+}
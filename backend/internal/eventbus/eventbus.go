@@ -0,0 +1,236 @@
+// Package eventbus carries typed events between the services over Kafka,
+// so a new consumer subscribes to events instead of wiring its own readers,
+// writers and payload formats.
+//
+// An event travels as the JSON value of a Kafka message whose event_type
+// header names its type. Messages without the header, written before the
+// bus existed, are read as DocumentIndexed.
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// TypeHeader is the message header holding the event type.
+const TypeHeader = "event_type"
+
+// Event types.
+const (
+	TypeDocumentIndexed = "document_indexed"
+	TypeDocumentFlagged = "document_flagged"
+	TypeAlertMatched    = "alert_matched"
+)
+
+// Event is a message on the bus.
+type Event interface {
+	// EventType names the event in the TypeHeader header.
+	EventType() string
+	// EventKey is the message key, which keeps the events of one document
+	// in order on one partition.
+	EventKey() string
+}
+
+// DocumentIndexed announces a document stored in Elasticsearch. It is
+// encoded as the document itself, the payload the fan-out topics have
+// always carried.
+type DocumentIndexed struct {
+	Document models.NewsDocument
+}
+
+func (DocumentIndexed) EventType() string  { return TypeDocumentIndexed }
+func (e DocumentIndexed) EventKey() string { return e.Document.ID }
+
+func (e DocumentIndexed) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Document)
+}
+
+func (e *DocumentIndexed) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &e.Document)
+}
+
+// ReasonSoldOut flags an offer a later post announced as sold out or
+// cancelled; Detail is the ID of that post.
+const ReasonSoldOut = "sold_out"
+
+// DocumentFlagged reports a stored document that needs attention, such as
+// an offer announced as sold out or one a moderator marked.
+type DocumentFlagged struct {
+	DocumentID string    `json:"doc_id"`
+	Reason     string    `json:"reason"`
+	Detail     string    `json:"detail,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+func (DocumentFlagged) EventType() string  { return TypeDocumentFlagged }
+func (e DocumentFlagged) EventKey() string { return e.DocumentID }
+
+// AlertMatched records that a document matched a chat's saved search and
+// an alert was sent.
+type AlertMatched struct {
+	DocumentID string    `json:"doc_id"`
+	ChatID     int64     `json:"chat_id"`
+	At         time.Time `json:"at"`
+}
+
+func (AlertMatched) EventType() string  { return TypeAlertMatched }
+func (e AlertMatched) EventKey() string { return e.DocumentID }
+
+// Encode builds the message carrying e to topic. An empty key uses
+// e.EventKey().
+func Encode(topic, key string, e Event) (kafka.Message, error) {
+	value, err := json.Marshal(e)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("marshal %s event: %w", e.EventType(), err)
+	}
+	if key == "" {
+		key = e.EventKey()
+	}
+	return kafka.Message{
+		Topic:   topic,
+		Key:     []byte(key),
+		Value:   value,
+		Headers: []kafka.Header{{Key: TypeHeader, Value: []byte(e.EventType())}},
+	}, nil
+}
+
+// ErrUnknownType is returned by Decode for event types this build does not
+// know; consumers skip such messages.
+var ErrUnknownType = errors.New("unknown event type")
+
+// Decode reads the event carried by msg.
+func Decode(msg kafka.Message) (Event, error) {
+	eventType := TypeDocumentIndexed
+	for _, h := range msg.Headers {
+		if h.Key == TypeHeader {
+			eventType = string(h.Value)
+		}
+	}
+
+	var e Event
+	switch eventType {
+	case TypeDocumentIndexed:
+		var indexed DocumentIndexed
+		if err := json.Unmarshal(msg.Value, &indexed); err != nil {
+			return nil, fmt.Errorf("decode %s event: %w", eventType, err)
+		}
+		e = indexed
+	case TypeDocumentFlagged:
+		var flagged DocumentFlagged
+		if err := json.Unmarshal(msg.Value, &flagged); err != nil {
+			return nil, fmt.Errorf("decode %s event: %w", eventType, err)
+		}
+		e = flagged
+	case TypeAlertMatched:
+		var matched AlertMatched
+		if err := json.Unmarshal(msg.Value, &matched); err != nil {
+			return nil, fmt.Errorf("decode %s event: %w", eventType, err)
+		}
+		e = matched
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownType, eventType)
+	}
+	return e, nil
+}
+
+// NewWriter builds the writer events are published with. It has no topic
+// of its own, so one writer serves every Publisher; messages are spread
+// over partitions by key and acknowledged by every in-sync replica.
+func NewWriter(brokers []string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+	}
+}
+
+// NewReader builds the consumer group reader Subscribe reads topic with.
+// Offsets are only committed by Subscribe.
+func NewReader(brokers []string, group, topic string) *kafka.Reader {
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        brokers,
+		Topic:          topic,
+		GroupID:        group,
+		MinBytes:       1e3,
+		MaxBytes:       10e6,
+		CommitInterval: 0, // Disable auto-commit; manual commit only
+	})
+}
+
+// Writer is the subset of kafka.Writer a Publisher needs.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Publisher writes events to one topic.
+type Publisher struct {
+	writer Writer
+	topic  string
+}
+
+// NewPublisher publishes to topic through w. The writer must not have a
+// topic of its own.
+func NewPublisher(w Writer, topic string) *Publisher {
+	return &Publisher{writer: w, topic: topic}
+}
+
+// Publish writes events in one batch, each keyed by its EventKey.
+func (p *Publisher) Publish(ctx context.Context, events ...Event) error {
+	msgs := make([]kafka.Message, 0, len(events))
+	for _, e := range events {
+		msg, err := Encode(p.topic, "", e)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+	}
+	return p.writer.WriteMessages(ctx, msgs...)
+}
+
+// Reader is the subset of kafka.Reader, configured with a consumer group,
+// that Subscribe needs.
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Handler processes one event. msg is the message that carried it, for
+// logging offsets or reading extra headers.
+type Handler func(ctx context.Context, e Event, msg kafka.Message) error
+
+// Subscribe hands every event read from r to handle, one at a time, and
+// commits each message after it was handled. Undecodable messages and
+// handler errors are logged and committed as well: subscribers are
+// expected to be best effort, so one bad event never blocks the stream.
+// It returns when ctx ends.
+func Subscribe(ctx context.Context, log *slog.Logger, r Reader, handle Handler) {
+	for {
+		msg, err := r.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Error("fetch event", slog.Any("err", err))
+			continue
+		}
+
+		if e, err := Decode(msg); err != nil {
+			log.Warn("decode event", slog.Any("err", err), slog.String("topic", msg.Topic), slog.Int64("offset", msg.Offset))
+		} else if err := handle(ctx, e, msg); err != nil {
+			log.Warn("handle event", slog.String("type", e.EventType()), slog.Any("err", err), slog.Int64("offset", msg.Offset))
+		}
+
+		if err := r.CommitMessages(ctx, msg); err != nil && ctx.Err() == nil {
+			log.Error("commit event", slog.Any("err", err), slog.Int64("offset", msg.Offset))
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package eventbus_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/eventbus"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, e := range []eventbus.Event{
+		eventbus.DocumentIndexed{Document: models.NewsDocument{ID: "doc-1", Title: "Турция", Keywords: []string{"пляж"}}},
+		eventbus.DocumentFlagged{DocumentID: "doc-1", Reason: "sold_out", At: at},
+		eventbus.AlertMatched{DocumentID: "doc-1", ChatID: 42, At: at},
+	} {
+		msg, err := eventbus.Encode("events", "", e)
+		require.NoError(t, err)
+		require.Equal(t, "events", msg.Topic)
+		require.Equal(t, []byte("doc-1"), msg.Key)
+
+		decoded, err := eventbus.Decode(msg)
+		require.NoError(t, err)
+		require.Equal(t, e, decoded)
+	}
+
+	// DocumentIndexed carries the bare document, as fan-out always has, and
+	// messages without a type header are read as one.
+	msg, err := eventbus.Encode("news_indexed", "турция", eventbus.DocumentIndexed{Document: models.NewsDocument{ID: "doc-2"}})
+	require.NoError(t, err)
+	require.Equal(t, []byte("турция"), msg.Key)
+	require.JSONEq(t, `{"id":"doc-2","title":"","text":"","timestamp":"0001-01-01T00:00:00Z","keywords":null,"source":"","urls":null}`, string(msg.Value))
+	legacy, err := eventbus.Decode(kafka.Message{Value: msg.Value})
+	require.NoError(t, err)
+	require.Equal(t, "doc-2", legacy.(eventbus.DocumentIndexed).Document.ID)
+
+	_, err = eventbus.Decode(kafka.Message{Headers: []kafka.Header{{Key: eventbus.TypeHeader, Value: []byte("price_dropped")}}})
+	require.ErrorIs(t, err, eventbus.ErrUnknownType)
+}
+
+type stubWriter struct {
+	msgs []kafka.Message
+}
+
+func (s *stubWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	s.msgs = append(s.msgs, msgs...)
+	return nil
+}
+
+func TestPublisher(t *testing.T) {
+	w := &stubWriter{}
+	p := eventbus.NewPublisher(w, "events")
+	require.NoError(t, p.Publish(context.Background(),
+		eventbus.AlertMatched{DocumentID: "doc-1", ChatID: 1},
+		eventbus.DocumentFlagged{DocumentID: "doc-2", Reason: "spam"},
+	))
+
+	require.Len(t, w.msgs, 2)
+	require.Equal(t, "events", w.msgs[1].Topic)
+	require.Equal(t, []byte("doc-2"), w.msgs[1].Key)
+	require.Equal(t, []kafka.Header{{Key: eventbus.TypeHeader, Value: []byte(eventbus.TypeDocumentFlagged)}}, w.msgs[1].Headers)
+}
+
+type stubReader struct {
+	msgs      []kafka.Message
+	committed []int64
+	cancel    context.CancelFunc
+}
+
+func (s *stubReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(s.msgs) == 0 {
+		s.cancel()
+		return kafka.Message{}, ctx.Err()
+	}
+	msg := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return msg, nil
+}
+
+func (s *stubReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	for _, msg := range msgs {
+		s.committed = append(s.committed, msg.Offset)
+	}
+	return nil
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	matched, err := eventbus.Encode("events", "", eventbus.AlertMatched{DocumentID: "doc-1", ChatID: 7})
+	require.NoError(t, err)
+	matched.Offset = 1
+	failing, err := eventbus.Encode("events", "", eventbus.DocumentFlagged{DocumentID: "doc-2"})
+	require.NoError(t, err)
+	failing.Offset = 3
+	reader := &stubReader{
+		msgs: []kafka.Message{
+			matched,
+			{Offset: 2, Value: []byte("not json")},
+			failing,
+		},
+		cancel: cancel,
+	}
+
+	var handled []eventbus.Event
+	eventbus.Subscribe(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), reader, func(_ context.Context, e eventbus.Event, _ kafka.Message) error {
+		handled = append(handled, e)
+		if _, ok := e.(eventbus.DocumentFlagged); ok {
+			return errors.New("sink down")
+		}
+		return nil
+	})
+
+	require.Len(t, handled, 2)
+	require.Equal(t, int64(7), handled[0].(eventbus.AlertMatched).ChatID)
+	// Undecodable messages and failed events are committed too.
+	require.Equal(t, []int64{1, 2, 3}, reader.committed)
+}
@@ -0,0 +1,123 @@
+// Package fieldmask hides the news document fields an API caller may not
+// see. Partner keys get documents as stored; everyone else gets them
+// through the public mask.
+package fieldmask
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+)
+
+// internalFields are document fields used for ranking and search only:
+// engagement counters and the analyzed copies of the text.
+var internalFields = []string{"seen_count", "clicks", "text_clean", "keyword_text"}
+
+// Mask rewrites documents for one access level. A nil *Mask grants full
+// access and leaves documents untouched.
+type Mask struct {
+	phones       processing.PIIStage
+	redirectBase string
+}
+
+// Public is the mask for callers without a partner key. It replaces phone
+// numbers in the title and text with "[phone]", drops the internal fields
+// and replaces every URL with its click-tracking redirect
+// <redirectBase>/r/<id>/<index>, so partner links are only reached
+// through the API.
+func Public(redirectBase string) *Mask {
+	return &Mask{phones: processing.PIIStage{Phone: true}, redirectBase: redirectBase}
+}
+
+// Document returns doc with the mask applied.
+func (m *Mask) Document(doc models.NewsDocument) models.NewsDocument {
+	if m == nil {
+		return doc
+	}
+	doc.Title = m.phones.Mask(doc.Title)
+	doc.Text = m.phones.Mask(doc.Text)
+	doc.SeenCount, doc.Clicks = 0, 0
+	doc.TextClean, doc.KeywordText = "", ""
+	if len(doc.URLs) > 0 {
+		urls := make([]string, len(doc.URLs))
+		for i := range doc.URLs {
+			urls[i] = m.redirect(doc.ID, i)
+		}
+		doc.URLs = urls
+	}
+	return doc
+}
+
+// JSON applies the mask to every news document in the encoded value data,
+// at any depth, so response types need not know about access levels. An
+// object counts as a news document when it has id, text and urls keys,
+// which models.NewsDocument always encodes.
+func (m *Mask) JSON(data []byte) ([]byte, error) {
+	if m == nil {
+		return data, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	m.walk(value)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if err := enc.Encode(value); err != nil {
+		return nil, fmt.Errorf("encode masked response: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func (m *Mask) walk(value any) {
+	switch v := value.(type) {
+	case []any:
+		for _, item := range v {
+			m.walk(item)
+		}
+	case map[string]any:
+		if isDocument(v) {
+			m.maskObject(v)
+			return
+		}
+		for _, item := range v {
+			m.walk(item)
+		}
+	}
+}
+
+func isDocument(obj map[string]any) bool {
+	_, hasID := obj["id"].(string)
+	_, hasText := obj["text"]
+	_, hasURLs := obj["urls"]
+	return hasID && hasText && hasURLs
+}
+
+func (m *Mask) maskObject(doc map[string]any) {
+	for _, field := range []string{"title", "text"} {
+		if s, ok := doc[field].(string); ok {
+			doc[field] = m.phones.Mask(s)
+		}
+	}
+	for _, field := range internalFields {
+		delete(doc, field)
+	}
+	if urls, ok := doc["urls"].([]any); ok {
+		id := doc["id"].(string)
+		for i := range urls {
+			urls[i] = m.redirect(id, i)
+		}
+	}
+}
+
+func (m *Mask) redirect(id string, index int) string {
+	return m.redirectBase + "/r/" + url.PathEscape(id) + "/" + strconv.Itoa(index)
+}
@@ -0,0 +1,83 @@
+package fieldmask_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/fieldmask"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func sampleDocument() models.NewsDocument {
+	return models.NewsDocument{
+		ID:          "doc 1",
+		Title:       "Турция, звоните +7 (999) 123-45-67",
+		Text:        "Бронь по телефону 8 800 555 35 35, цена 45000",
+		Source:      "telegram",
+		URLs:        []string{"https://partner.example/tour?aff=42", "https://t.me/hot/5"},
+		SeenCount:   3,
+		Clicks:      12,
+		Price:       45000,
+		TextClean:   "Бронь по телефону 8 800 555 35 35",
+		KeywordText: "турция бронь",
+	}
+}
+
+func TestPublicDocument(t *testing.T) {
+	doc := fieldmask.Public("https://radar.example/api").Document(sampleDocument())
+
+	require.Equal(t, "Турция, звоните [phone]", doc.Title)
+	require.Equal(t, "Бронь по телефону [phone], цена 45000", doc.Text)
+	require.Equal(t, []string{"https://radar.example/api/r/doc%201/0", "https://radar.example/api/r/doc%201/1"}, doc.URLs)
+	require.Zero(t, doc.SeenCount)
+	require.Zero(t, doc.Clicks)
+	require.Empty(t, doc.TextClean)
+	require.Empty(t, doc.KeywordText)
+	require.Equal(t, 45000, doc.Price)
+}
+
+func TestFullAccessLeavesDocumentsUntouched(t *testing.T) {
+	var full *fieldmask.Mask
+	require.Equal(t, sampleDocument(), full.Document(sampleDocument()))
+
+	data, err := json.Marshal(sampleDocument())
+	require.NoError(t, err)
+	masked, err := full.JSON(data)
+	require.NoError(t, err)
+	require.Equal(t, data, masked)
+}
+
+func TestPublicJSONMasksNestedDocuments(t *testing.T) {
+	// Shapes of GET /news and GET /news?ids=.
+	search, err := json.Marshal(map[string]any{
+		"Total": 1,
+		"Items": []models.NewsDocument{sampleDocument()},
+	})
+	require.NoError(t, err)
+	mget, err := json.Marshal(map[string]any{"items": []any{
+		map[string]any{"id": "doc 1", "found": true, "document": sampleDocument()},
+		map[string]any{"id": "gone", "found": false},
+	}})
+	require.NoError(t, err)
+
+	mask := fieldmask.Public("")
+	for _, data := range [][]byte{search, mget} {
+		masked, err := mask.JSON(data)
+		require.NoError(t, err)
+		require.NotContains(t, string(masked), "partner.example")
+		require.NotContains(t, string(masked), "555 35 35")
+		require.NotContains(t, string(masked), "seen_count")
+		require.NotContains(t, string(masked), "clicks")
+		require.NotContains(t, string(masked), "text_clean")
+		require.Contains(t, string(masked), `"/r/doc%201/0"`)
+		require.Contains(t, string(masked), `"price":45000`)
+	}
+
+	// Objects that merely have an id, such as saved searches, are left alone.
+	other := []byte(`{"id":"s1","chat_id":42,"keywords":["пляж"]}`)
+	masked, err := mask.JSON(other)
+	require.NoError(t, err)
+	require.JSONEq(t, string(other), string(masked))
+}
@@ -1,16 +1,47 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
 )
 
-// New constructs a text logger with the desired log level.
+// New constructs a logger with the desired log level and format, writing
+// to the outputs configured by LOG_OUTPUT. An invalid format or output
+// configuration falls back to text on stdout and is reported through the
+// returned logger.
 func New(service string) *slog.Logger {
 	level := parseLevel(os.Getenv("LOG_LEVEL"))
-	h := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level})
-	return slog.New(h).With("service", service)
+
+	var out io.Writer = os.Stdout
+	cfg, outErr := parseOutput(os.Getenv)
+	if outErr == nil {
+		out, outErr = cfg.open()
+		if outErr != nil {
+			out = os.Stdout
+		}
+	}
+	format, formatErr := parseFormat(os.Getenv("LOG_FORMAT"))
+
+	opts := &slog.HandlerOptions{Level: level, AddSource: os.Getenv("LOG_SOURCE") == "true"}
+	var h slog.Handler
+	if format == "json" {
+		h = slog.NewJSONHandler(out, opts)
+	} else {
+		h = slog.NewTextHandler(out, opts)
+	}
+	log := slog.New(newTraceHandler(h)).With("service", service)
+	if outErr != nil {
+		log.Error("invalid log output, falling back to stdout", slog.Any("err", outErr))
+	}
+	if formatErr != nil {
+		log.Error("invalid log format, falling back to text", slog.Any("err", formatErr))
+	}
+	return log
 }
 
 func parseLevel(raw string) slog.Level {
@@ -25,3 +56,96 @@ func parseLevel(raw string) slog.Level {
 		return slog.LevelInfo
 	}
 }
+
+// parseFormat reads LOG_FORMAT: text, the default, or json for log
+// collectors such as Loki or Elasticsearch.
+func parseFormat(raw string) (string, error) {
+	switch format := strings.ToLower(strings.TrimSpace(raw)); format {
+	case "", "text":
+		return "text", nil
+	case "json":
+		return format, nil
+	default:
+		return "text", fmt.Errorf("LOG_FORMAT %q must be text or json", raw)
+	}
+}
+
+type traceKey struct{}
+
+// WithTraceID returns a context whose records, logged with the *Context
+// methods, carry id as trace_id, so the lines of one request can be found
+// together.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceKey{}, id)
+}
+
+// TraceID returns the trace ID of ctx, or "" when it has none.
+func TraceID(ctx context.Context) string {
+	id, _ := ctx.Value(traceKey{}).(string)
+	return id
+}
+
+// traceHandler adds the trace ID of a record's context at the top level,
+// even to loggers that opened groups: it keeps the handler as it was
+// before the first group and replays the groups, with the attributes added
+// inside them, on top of the trace ID.
+type traceHandler struct {
+	slog.Handler
+	root   slog.Handler
+	groups []traceGroup
+}
+
+type traceGroup struct {
+	name  string
+	attrs []slog.Attr
+}
+
+func newTraceHandler(h slog.Handler) traceHandler {
+	return traceHandler{Handler: h, root: h}
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	id := TraceID(ctx)
+	switch {
+	case id == "":
+		return h.Handler.Handle(ctx, r)
+	case len(h.groups) == 0:
+		r = r.Clone()
+		r.AddAttrs(slog.String("trace_id", id))
+		return h.Handler.Handle(ctx, r)
+	}
+	grouped := h.root.WithAttrs([]slog.Attr{slog.String("trace_id", id)})
+	for _, g := range h.groups {
+		grouped = grouped.WithGroup(g.name)
+		if len(g.attrs) > 0 {
+			grouped = grouped.WithAttrs(g.attrs)
+		}
+	}
+	return grouped.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	next := traceHandler{Handler: h.Handler.WithAttrs(attrs), root: h.root}
+	if len(h.groups) == 0 {
+		next.root = next.Handler
+		return next
+	}
+	next.groups = slices.Clone(h.groups)
+	last := &next.groups[len(next.groups)-1]
+	last.attrs = append(slices.Clip(last.attrs), attrs...)
+	return next
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return traceHandler{
+		Handler: h.Handler.WithGroup(name),
+		root:    h.root,
+		groups:  append(slices.Clip(h.groups), traceGroup{name: name}),
+	}
+}
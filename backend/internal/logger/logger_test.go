@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	for raw, want := range map[string]string{"": "text", "text": "text", " JSON ": "json"} {
+		format, err := parseFormat(raw)
+		require.NoError(t, err, raw)
+		require.Equal(t, want, format, raw)
+	}
+
+	format, err := parseFormat("logfmt")
+	require.ErrorContains(t, err, `LOG_FORMAT "logfmt"`)
+	require.Equal(t, "text", format)
+}
+
+func TestTraceID(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(newTraceHandler(slog.NewJSONHandler(&buf, nil))).With("service", "api")
+
+	ctx := WithTraceID(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	log.InfoContext(ctx, "search", slog.Int("hits", 3))
+	log.Info("no request")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	var first, second map[string]any
+	require.NoError(t, json.Unmarshal(lines[0], &first))
+	require.NoError(t, json.Unmarshal(lines[1], &second))
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", first["trace_id"])
+	require.Equal(t, "api", first["service"])
+	require.Equal(t, float64(3), first["hits"])
+	require.NotContains(t, second, "trace_id")
+}
+
+func TestTraceIDStaysOutOfGroups(t *testing.T) {
+	var buf bytes.Buffer
+	base := slog.New(newTraceHandler(slog.NewJSONHandler(&buf, nil))).With("service", "api")
+	log := base.WithGroup("req").With("path", "/news").WithGroup("es").With("index", "news")
+	sibling := base.WithGroup("req").With("path", "/stats")
+
+	ctx := WithTraceID(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	log.InfoContext(ctx, "search", slog.Int("hits", 3))
+	sibling.InfoContext(ctx, "overview")
+	log.Info("no request")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 3)
+	var first, second, third map[string]any
+	require.NoError(t, json.Unmarshal(lines[0], &first))
+	require.NoError(t, json.Unmarshal(lines[1], &second))
+	require.NoError(t, json.Unmarshal(lines[2], &third))
+
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", first["trace_id"])
+	require.Equal(t, "api", first["service"])
+	require.Equal(t, map[string]any{
+		"path": "/news",
+		"es":   map[string]any{"index": "news", "hits": float64(3)},
+	}, first["req"])
+
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", second["trace_id"])
+	require.Equal(t, map[string]any{"path": "/stats"}, second["req"], "loggers sharing a parent do not share attributes")
+
+	require.NotContains(t, third, "trace_id")
+	require.Equal(t, first["req"].(map[string]any)["path"], third["req"].(map[string]any)["path"])
+}
@@ -0,0 +1,226 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// outputConfig describes where log lines go, as read from the environment.
+type outputConfig struct {
+	stdout     bool
+	files      []string
+	maxBytes   int64
+	interval   time.Duration
+	maxBackups int
+}
+
+// parseOutput reads LOG_OUTPUT ("stdout", "file:/path" or both, comma
+// separated) together with the rotation settings.
+func parseOutput(getenv func(string) string) (outputConfig, error) {
+	cfg := outputConfig{maxBytes: 100 << 20, maxBackups: 5}
+
+	raw := strings.TrimSpace(getenv("LOG_OUTPUT"))
+	if raw == "" {
+		raw = "stdout"
+	}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "stdout":
+			cfg.stdout = true
+		case strings.HasPrefix(part, "file:") && len(part) > len("file:"):
+			cfg.files = append(cfg.files, strings.TrimPrefix(part, "file:"))
+		case part == "":
+		default:
+			return cfg, fmt.Errorf("LOG_OUTPUT entry %q must be stdout or file:/path", part)
+		}
+	}
+
+	if v := strings.TrimSpace(getenv("LOG_MAX_SIZE_MB")); v != "" {
+		mb, err := strconv.Atoi(v)
+		if err != nil || mb < 0 {
+			return cfg, fmt.Errorf("LOG_MAX_SIZE_MB must be a non-negative integer")
+		}
+		cfg.maxBytes = int64(mb) << 20
+	}
+	if v := strings.TrimSpace(getenv("LOG_ROTATE_INTERVAL")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("LOG_ROTATE_INTERVAL must be a non-negative duration")
+		}
+		cfg.interval = d
+	}
+	if v := strings.TrimSpace(getenv("LOG_MAX_BACKUPS")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("LOG_MAX_BACKUPS must be a non-negative integer")
+		}
+		cfg.maxBackups = n
+	}
+	return cfg, nil
+}
+
+// open builds the writer for cfg.
+func (cfg outputConfig) open() (io.Writer, error) {
+	var writers []io.Writer
+	if cfg.stdout {
+		writers = append(writers, os.Stdout)
+	}
+	for _, path := range cfg.files {
+		f, err := newRotatingFile(path, cfg.maxBytes, cfg.interval, cfg.maxBackups)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, f)
+	}
+	if len(writers) == 1 {
+		return writers[0], nil
+	}
+	return io.MultiWriter(writers...), nil
+}
+
+// rotateRetryDelay is how long a rotatingFile keeps writing to the current
+// file after a failed rotation before trying again.
+const rotateRetryDelay = time.Minute
+
+// rotatingFile is an append-only log file that is renamed aside once it
+// grows past maxBytes or gets older than interval. Zero disables either
+// trigger. Only the newest maxBackups rotated files are kept, or all of them
+// when maxBackups is zero.
+//
+// Rotation never costs a line: when it fails the file in use is kept and
+// the failure reported on stderr, since the log itself may be what broke.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64
+	interval   time.Duration
+	maxBackups int
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	retryAt  time.Time
+	now      func() time.Time
+	openFile func(path string) (*os.File, error)
+	stderr   io.Writer
+}
+
+func newRotatingFile(path string, maxBytes int64, interval time.Duration, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       path,
+		maxBytes:   maxBytes,
+		interval:   interval,
+		maxBackups: maxBackups,
+		now:        time.Now,
+		openFile:   openAppend,
+		stderr:     os.Stderr,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create log dir: %w", err)
+	}
+	if err := r.openLocked(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.shouldRotateLocked(int64(len(p))) {
+		if err := r.rotateLocked(); err != nil {
+			r.retryAt = r.now().Add(rotateRetryDelay)
+			fmt.Fprintf(r.stderr, "logger: %v\n", err)
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) shouldRotateLocked(next int64) bool {
+	if r.size == 0 || r.now().Before(r.retryAt) {
+		return false
+	}
+	if r.maxBytes > 0 && r.size+next > r.maxBytes {
+		return true
+	}
+	return r.interval > 0 && r.now().Sub(r.openedAt) >= r.interval
+}
+
+func openAppend(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+func (r *rotatingFile) openLocked() error {
+	f, err := r.openFile(r.path)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	r.file = f
+	r.size = info.Size()
+	r.openedAt = r.now()
+	return nil
+}
+
+// rotateLocked renames the file aside and opens a fresh one. The old handle
+// is only closed once its successor is open, so a failure leaves the file
+// in use as it was; pruning failures are returned but leave the new file
+// in place.
+func (r *rotatingFile) rotateLocked() error {
+	backup := r.path + "." + r.now().UTC().Format("20060102T150405.000")
+	if err := os.Rename(r.path, backup); err != nil {
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	old := r.file
+	if err := r.openLocked(); err != nil {
+		if undo := os.Rename(backup, r.path); undo != nil {
+			err = errors.Join(err, fmt.Errorf("restore log file: %w", undo))
+		}
+		return err
+	}
+	var errs []error
+	if err := old.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close log file: %w", err))
+	}
+	if err := r.pruneLocked(); err != nil {
+		errs = append(errs, fmt.Errorf("prune log backups: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// pruneLocked deletes the oldest backups beyond maxBackups. Backup suffixes
+// are timestamps, so lexical order is chronological.
+func (r *rotatingFile) pruneLocked() error {
+	if r.maxBackups == 0 {
+		return nil
+	}
+	backups, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return err
+	}
+	slices.Sort(backups)
+	var errs []error
+	for len(backups) > r.maxBackups {
+		if err := os.Remove(backups[0]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		backups = backups[1:]
+	}
+	return errors.Join(errs...)
+}
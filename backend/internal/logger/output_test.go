@@ -0,0 +1,148 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutput(t *testing.T) {
+	env := map[string]string{
+		"LOG_OUTPUT":          "stdout, file:/var/log/api.log",
+		"LOG_MAX_SIZE_MB":     "10",
+		"LOG_ROTATE_INTERVAL": "24h",
+	}
+	cfg, err := parseOutput(func(k string) string { return env[k] })
+	require.NoError(t, err)
+	require.True(t, cfg.stdout)
+	require.Equal(t, []string{"/var/log/api.log"}, cfg.files)
+	require.Equal(t, int64(10<<20), cfg.maxBytes)
+	require.Equal(t, 24*time.Hour, cfg.interval)
+	require.Equal(t, 5, cfg.maxBackups)
+
+	cfg, err = parseOutput(func(string) string { return "" })
+	require.NoError(t, err)
+	require.True(t, cfg.stdout)
+	require.Empty(t, cfg.files)
+
+	_, err = parseOutput(func(k string) string {
+		if k == "LOG_OUTPUT" {
+			return "syslog"
+		}
+		return ""
+	})
+	require.Error(t, err)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "api.log")
+	r, err := newRotatingFile(path, 10, time.Hour, 2)
+	require.NoError(t, err)
+
+	now := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+		now = now.Add(time.Second)
+		_, err := r.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "dddddd\n", string(current))
+
+	backups, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	oldest, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	require.Equal(t, "bbbbbb\n", string(oldest))
+
+	// Time-based rotation kicks in even for small files.
+	now = now.Add(2 * time.Hour)
+	_, err = r.Write([]byte("e\n"))
+	require.NoError(t, err)
+	current, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "e\n", string(current))
+}
+
+// newFailingRotation returns a rotatingFile at path that rotates on every
+// write, the clock frozen at now, and what it reports on stderr.
+func newFailingRotation(t *testing.T, path string, maxBackups int, now time.Time) (*rotatingFile, *strings.Builder) {
+	r, err := newRotatingFile(path, 1, 0, maxBackups)
+	require.NoError(t, err)
+	t.Cleanup(func() { r.file.Close() })
+	r.now = func() time.Time { return now }
+	stderr := &strings.Builder{}
+	r.stderr = stderr
+	_, err = r.Write([]byte("first\n"))
+	require.NoError(t, err)
+	return r, stderr
+}
+
+func TestRotatingFileKeepsWritingWhenRotationFails(t *testing.T) {
+	now := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("rename", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "api.log")
+		r, stderr := newFailingRotation(t, path, 0, now)
+		// A non-empty directory where the backup should go makes the
+		// rename fail.
+		backup := path + "." + now.Format("20060102T150405.000")
+		require.NoError(t, os.MkdirAll(filepath.Join(backup, "taken"), 0o755))
+
+		n, err := r.Write([]byte("second\n"))
+		require.NoError(t, err)
+		require.Equal(t, 7, n)
+		require.Contains(t, stderr.String(), "rotate log file")
+
+		// Until the retry delay passes the file is not rotated again.
+		_, err = r.Write([]byte("third\n"))
+		require.NoError(t, err)
+		require.Equal(t, 1, strings.Count(stderr.String(), "\n"))
+
+		current, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "first\nsecond\nthird\n", string(current))
+	})
+
+	t.Run("open", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "api.log")
+		r, stderr := newFailingRotation(t, path, 0, now)
+		r.openFile = func(string) (*os.File, error) { return nil, errors.New("disk on fire") }
+
+		_, err := r.Write([]byte("second\n"))
+		require.NoError(t, err)
+		require.Contains(t, stderr.String(), "disk on fire")
+
+		// The file was moved back and is still written to.
+		current, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "first\nsecond\n", string(current))
+		backups, err := filepath.Glob(path + ".*")
+		require.NoError(t, err)
+		require.Empty(t, backups)
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "api.log")
+		r, stderr := newFailingRotation(t, path, 1, now)
+		// The oldest backup cannot be removed.
+		require.NoError(t, os.MkdirAll(filepath.Join(path+".00000000T000000.000", "taken"), 0o755))
+
+		_, err := r.Write([]byte("second\n"))
+		require.NoError(t, err)
+		require.Contains(t, stderr.String(), "prune log backups")
+
+		current, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "second\n", string(current))
+	})
+}
@@ -9,6 +9,102 @@ type NewsDocument struct {
 	Text      string    `json:"text"`
 	Timestamp time.Time `json:"timestamp"`
 	Keywords  []string  `json:"keywords"`
-	Source    string    `json:"source"`
-	URLs      []string  `json:"urls"`
+	// KeywordForms maps stemmed keywords to the spelling to display, when
+	// the worker stems keywords; keywords missing from it display as is.
+	KeywordForms map[string]string `json:"keyword_forms,omitempty"`
+	Source       string            `json:"source"`
+	URLs         []string          `json:"urls"`
+	Destinations []string          `json:"destinations,omitempty"`
+	Locations    []GeoPoint        `json:"locations,omitempty"` // of the most specific destinations
+	Mentions     []string          `json:"mentions,omitempty"`  // @usernames without the @, lower case
+	SeenCount    int               `json:"seen_count,omitempty"`
+	LastSeen     time.Time         `json:"last_seen,omitzero"`
+	Clicks       int               `json:"clicks,omitempty"`
+	TravelStart  time.Time         `json:"travel_start,omitzero"`
+	TravelEnd    time.Time         `json:"travel_end,omitzero"`
+	ExpiresAt    time.Time         `json:"expires_at,omitzero"`
+	Status       string            `json:"status,omitempty"`     // StatusExpired once sold out or cancelled
+	ExpiredBy    string            `json:"expired_by,omitempty"` // ID of the notice that expired the offer
+	Price        int               `json:"price,omitempty"`      // lowest price mentioned, in rubles
+	Quality      int               `json:"quality,omitempty"`    // 0-100, how well-formed the offer is
+	ClusterID    string            `json:"cluster_id,omitempty"`
+	TextClean    string            `json:"text_clean,omitempty"`   // text without markup and links, for search
+	KeywordText  string            `json:"keyword_text,omitempty"` // keywords joined by spaces, for search
+	IndexedAt    time.Time         `json:"indexed_at,omitzero"`    // when the worker ingested the post
+}
+
+// GeoPoint is a position in degrees, as Elasticsearch's geo_point reads it.
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// StatusExpired marks offers announced as sold out or cancelled, and the
+// announcements themselves.
+const StatusExpired = "expired"
+
+// ClickEvent records a redirect through one of a document's URLs.
+type ClickEvent struct {
+	DocumentID string    `json:"doc_id"`
+	URL        string    `json:"url"`
+	URLIndex   int       `json:"url_index"`
+	Source     string    `json:"source"`
+	Referer    string    `json:"referer,omitempty"`
+	Experiment string    `json:"experiment,omitempty"` // ranking experiment of the client
+	Arm        string    `json:"arm,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// QueryEvent records a /news search while a ranking experiment runs, so
+// the arms can be compared by what their searches returned and, joined
+// with click events, by what users opened.
+type QueryEvent struct {
+	Query       string   `json:"query,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	Destination string   `json:"destination,omitempty"`
+	Sort        string   `json:"sort,omitempty"`
+	From        int      `json:"from"`
+	Total       int64    `json:"total"`
+	Relaxed     bool     `json:"relaxed,omitempty"`
+	// DocumentIDs lists the returned documents in rank order.
+	DocumentIDs []string  `json:"doc_ids"`
+	Experiment  string    `json:"experiment"`
+	Arm         string    `json:"arm"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// StopwordSuggestion is a keyword so common that it carries no signal.
+type StopwordSuggestion struct {
+	Token       string    `json:"token"`
+	DocCount    int64     `json:"doc_count"`
+	Ratio       float64   `json:"ratio"`
+	WindowStart time.Time `json:"window_start"`
+	ComputedAt  time.Time `json:"computed_at"`
+}
+
+// SavedSearch is a user's standing query; the alerter pushes every newly
+// indexed document matching it to the user's Telegram chat.
+type SavedSearch struct {
+	ID     string `json:"id"`
+	ChatID int64  `json:"chat_id"`
+	// Keywords match when the document carries any of them.
+	Keywords    []string  `json:"keywords,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	MaxPrice    int       `json:"max_price,omitempty"` // rubles
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// IngestError records a message the worker could not index, alongside its
+// copy in the dead-letter topic, so failures can be searched and counted.
+type IngestError struct {
+	Topic      string `json:"topic"`
+	Partition  int    `json:"partition"`
+	Offset     int64  `json:"offset"`
+	Source     string `json:"source"`
+	ErrorClass string `json:"error_class"`
+	Error      string `json:"error"`
+	// PayloadExcerpt is the start of the raw message value.
+	PayloadExcerpt string    `json:"payload_excerpt"`
+	Timestamp      time.Time `json:"timestamp"`
 }
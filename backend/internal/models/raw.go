@@ -0,0 +1,13 @@
+package models
+
+// RawNews is the canonical news payload: the scrapers, the importer and
+// the ingest API publish it to the raw topic and the worker reads it.
+// Timestamp is RFC 3339, or empty when the producer does not know it.
+type RawNews struct {
+	Title     string `json:"title,omitempty"`
+	Text      string `json:"text"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Source    string `json:"source"`
+	// ReplyTo is the link of the post this one replies to.
+	ReplyTo string `json:"reply_to,omitempty"`
+}
@@ -0,0 +1,5 @@
+// Package newspb holds the protobuf and gRPC definitions of the search API
+// served next to the HTTP API.
+package newspb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative news.proto
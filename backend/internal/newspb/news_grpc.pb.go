@@ -0,0 +1,166 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: news.proto
+
+// Search API for internal services. It mirrors GET /news and
+// GET /news/{id} of the HTTP API.
+
+package newspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	NewsSearch_Search_FullMethodName  = "/hottourradar.news.v1.NewsSearch/Search"
+	NewsSearch_GetNews_FullMethodName = "/hottourradar.news.v1.NewsSearch/GetNews"
+)
+
+// NewsSearchClient is the client API for NewsSearch service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NewsSearchClient interface {
+	// Search runs a /news search. Unset fields leave the filter out.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// GetNews returns one document, or NOT_FOUND.
+	GetNews(ctx context.Context, in *GetNewsRequest, opts ...grpc.CallOption) (*NewsDocument, error)
+}
+
+type newsSearchClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNewsSearchClient(cc grpc.ClientConnInterface) NewsSearchClient {
+	return &newsSearchClient{cc}
+}
+
+func (c *newsSearchClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, NewsSearch_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsSearchClient) GetNews(ctx context.Context, in *GetNewsRequest, opts ...grpc.CallOption) (*NewsDocument, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NewsDocument)
+	err := c.cc.Invoke(ctx, NewsSearch_GetNews_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NewsSearchServer is the server API for NewsSearch service.
+// All implementations must embed UnimplementedNewsSearchServer
+// for forward compatibility.
+type NewsSearchServer interface {
+	// Search runs a /news search. Unset fields leave the filter out.
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// GetNews returns one document, or NOT_FOUND.
+	GetNews(context.Context, *GetNewsRequest) (*NewsDocument, error)
+	mustEmbedUnimplementedNewsSearchServer()
+}
+
+// UnimplementedNewsSearchServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedNewsSearchServer struct{}
+
+func (UnimplementedNewsSearchServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedNewsSearchServer) GetNews(context.Context, *GetNewsRequest) (*NewsDocument, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNews not implemented")
+}
+func (UnimplementedNewsSearchServer) mustEmbedUnimplementedNewsSearchServer() {}
+func (UnimplementedNewsSearchServer) testEmbeddedByValue()                    {}
+
+// UnsafeNewsSearchServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NewsSearchServer will
+// result in compilation errors.
+type UnsafeNewsSearchServer interface {
+	mustEmbedUnimplementedNewsSearchServer()
+}
+
+func RegisterNewsSearchServer(s grpc.ServiceRegistrar, srv NewsSearchServer) {
+	// If the following call pancis, it indicates UnimplementedNewsSearchServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&NewsSearch_ServiceDesc, srv)
+}
+
+func _NewsSearch_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsSearchServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsSearch_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsSearchServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NewsSearch_GetNews_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNewsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsSearchServer).GetNews(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NewsSearch_GetNews_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsSearchServer).GetNews(ctx, req.(*GetNewsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// NewsSearch_ServiceDesc is the grpc.ServiceDesc for NewsSearch service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NewsSearch_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "hottourradar.news.v1.NewsSearch",
+	HandlerType: (*NewsSearchServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Search",
+			Handler:    _NewsSearch_Search_Handler,
+		},
+		{
+			MethodName: "GetNews",
+			Handler:    _NewsSearch_GetNews_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "news.proto",
+}
@@ -0,0 +1,88 @@
+// Package notify delivers operator notifications, such as worker alerts,
+// to Telegram chats and webhooks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"time"
+)
+
+// Notifier delivers a plain-text notification.
+type Notifier interface {
+	Notify(ctx context.Context, text string) error
+}
+
+// Multi delivers to every notifier, so one broken channel does not silence
+// the others. The result joins their errors.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, text string) error {
+	var errs []error
+	for _, n := range m {
+		errs = append(errs, n.Notify(ctx, text))
+	}
+	return errors.Join(errs...)
+}
+
+// messenger is the subset of the Telegram client used for notifications.
+type messenger interface {
+	SendMessage(ctx context.Context, chatID int64, text string) error
+}
+
+// Telegram sends notifications to chats through a bot.
+type Telegram struct {
+	Client  messenger
+	ChatIDs []int64
+}
+
+func (t Telegram) Notify(ctx context.Context, text string) error {
+	// SendMessage parses HTML, so the plain text is escaped.
+	text = html.EscapeString(text)
+	var errs []error
+	for _, chatID := range t.ChatIDs {
+		if err := t.Client.SendMessage(ctx, chatID, text); err != nil {
+			errs = append(errs, fmt.Errorf("telegram chat %d: %w", chatID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Webhook posts notifications as {"text": "..."} JSON, the payload Slack
+// and Mattermost incoming webhooks accept.
+type Webhook struct {
+	URL  string
+	HTTP *http.Client
+}
+
+// NewWebhook creates a webhook notifier with a bounded request timeout.
+func NewWebhook(url string) Webhook {
+	return Webhook{URL: url, HTTP: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w Webhook) Notify(ctx context.Context, text string) error {
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("marshal webhook payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := w.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook: unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/notify"
+)
+
+type stubMessenger struct {
+	sent map[int64]string
+	fail int64
+}
+
+func (s *stubMessenger) SendMessage(_ context.Context, chatID int64, text string) error {
+	if chatID == s.fail {
+		return errors.New("bot was blocked")
+	}
+	s.sent[chatID] = text
+	return nil
+}
+
+func TestTelegramEscapesAndReachesEveryChat(t *testing.T) {
+	tg := &stubMessenger{sent: map[int64]string{}, fail: 2}
+	err := notify.Telegram{Client: tg, ChatIDs: []int64{1, 2, 3}}.Notify(context.Background(), "lag > 100 <topic>")
+
+	require.ErrorContains(t, err, "telegram chat 2")
+	require.Equal(t, map[int64]string{1: "lag &gt; 100 &lt;topic&gt;", 3: "lag &gt; 100 &lt;topic&gt;"}, tg.sent)
+}
+
+func TestWebhook(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		if got["text"] == "fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	hook := notify.NewWebhook(srv.URL)
+	require.NoError(t, hook.Notify(context.Background(), "worker idle"))
+	require.Equal(t, map[string]string{"text": "worker idle"}, got)
+
+	err := notify.Multi{hook, hook}.Notify(context.Background(), "fail")
+	require.ErrorContains(t, err, "unexpected status 502")
+}
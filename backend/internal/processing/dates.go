@@ -0,0 +1,92 @@
+package processing
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// russianMonths maps genitive month names ("15 июня") to months.
+var russianMonths = map[string]time.Month{
+	"января":   time.January,
+	"февраля":  time.February,
+	"марта":    time.March,
+	"апреля":   time.April,
+	"мая":      time.May,
+	"июня":     time.June,
+	"июля":     time.July,
+	"августа":  time.August,
+	"сентября": time.September,
+	"октября":  time.October,
+	"ноября":   time.November,
+	"декабря":  time.December,
+}
+
+var (
+	expiryNamedRe   = regexp.MustCompile(`(?i)(?:^|[^\p{L}])до\s+(\d{1,2})(?:-?го)?\s+(\p{L}+)(?:\s+(\d{4}))?`)
+	expiryNumericRe = regexp.MustCompile(`(?i)(?:^|[^\p{L}])до\s+(\d{1,2})\.(\d{1,2})(?:\.(\d{4}|\d{2}))?\.?(?:\D|$)`)
+)
+
+// ExtractExpiry finds an explicit offer deadline such as "до 15 июня" or
+// "до 15.06" and returns the moment the offer expires: the start of the day
+// after the deadline, in the location of posted. Dates without a year are
+// resolved to the nearest such date not long before the post. It returns the
+// zero time when the text states no deadline.
+func ExtractExpiry(text string, posted time.Time) time.Time {
+	if posted.IsZero() {
+		return time.Time{}
+	}
+
+	for _, m := range expiryNamedRe.FindAllStringSubmatch(text, -1) {
+		month, ok := russianMonths[strings.ToLower(m[2])]
+		if !ok {
+			continue
+		}
+		day, _ := strconv.Atoi(m[1])
+		if date, ok := resolveDate(day, month, m[3], posted); ok {
+			return date.AddDate(0, 0, 1)
+		}
+	}
+
+	for _, m := range expiryNumericRe.FindAllStringSubmatch(text, -1) {
+		day, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		if month < 1 || month > 12 {
+			continue
+		}
+		if date, ok := resolveDate(day, time.Month(month), m[3], posted); ok {
+			return date.AddDate(0, 0, 1)
+		}
+	}
+
+	return time.Time{}
+}
+
+// resolveDate builds a calendar date at midnight in posted's location. An
+// empty year picks posted's year, or the next one when the date would
+// otherwise lie more than a month before the post ("до 10 января" posted in
+// December).
+func resolveDate(day int, month time.Month, rawYear string, posted time.Time) (time.Time, bool) {
+	year := posted.Year()
+	if rawYear != "" {
+		parsed, err := strconv.Atoi(rawYear)
+		if err != nil {
+			return time.Time{}, false
+		}
+		if parsed < 100 {
+			parsed += 2000
+		}
+		year = parsed
+	}
+
+	date := time.Date(year, month, day, 0, 0, 0, 0, posted.Location())
+	// time.Date normalises overflow, so "31.02" becomes March 3rd.
+	if date.Day() != day || date.Month() != month {
+		return time.Time{}, false
+	}
+	if rawYear == "" && date.Before(posted.AddDate(0, -1, 0)) {
+		date = date.AddDate(1, 0, 0)
+	}
+	return date, true
+}
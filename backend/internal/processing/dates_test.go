@@ -0,0 +1,34 @@
+package processing_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractExpiry(t *testing.T) {
+	posted := time.Date(2024, 6, 1, 9, 30, 0, 0, time.UTC)
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	cases := []struct {
+		text string
+		want time.Time
+	}{
+		{"Акция действует до 15 июня!", day(2024, 6, 16)},
+		{"Бронирование ДО 5-го Июля 2025 года", day(2025, 7, 6)},
+		{"Скидка до 20.06, успейте", day(2024, 6, 21)},
+		{"Раннее бронирование до 31.05.25.", day(2025, 6, 1)},
+		{"Отели до 5 звёзд, цены до 30 000 руб, продажи до 10 июня", day(2024, 6, 11)},
+		{"Цены до 15.000 руб", time.Time{}},
+		{"Вылет 15 июня", time.Time{}},
+		{"Предложение до 31.02", time.Time{}},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.want, processing.ExtractExpiry(tc.text, posted), tc.text)
+	}
+
+	december := time.Date(2024, 12, 20, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, day(2025, 1, 11), processing.ExtractExpiry("туры до 10 января", december))
+}
@@ -0,0 +1,103 @@
+package processing
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PIIKinds lists the personal data categories PIIStage can mask.
+var PIIKinds = []string{"email", "phone", "card"}
+
+var (
+	emailRe = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// phoneRe matches Russian numbers such as "+7 (999) 123-45-67" or "8 800 555 35 35".
+	phoneRe = regexp.MustCompile(`(?:\+7|\b8)[\s\-]*\(?\d{3}\)?[\s\-]*\d{3}[\s\-]*\d{2}[\s\-]*\d{2}\b`)
+	// cardRe matches 13–19 digit runs, optionally grouped by spaces or dashes.
+	cardRe = regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`)
+)
+
+// PIIStage masks emails, phone numbers and payment card numbers in the
+// stored title and text so posts can be republished. It should run first,
+// before other stages derive keywords or IDs from the raw text.
+type PIIStage struct {
+	Email bool
+	Phone bool
+	Card  bool
+}
+
+// NewPIIStage enables masking for the given kinds; an empty list enables all of them.
+func NewPIIStage(kinds []string) (PIIStage, error) {
+	if len(kinds) == 0 {
+		kinds = PIIKinds
+	}
+	var stage PIIStage
+	for _, kind := range kinds {
+		switch strings.ToLower(strings.TrimSpace(kind)) {
+		case "email":
+			stage.Email = true
+		case "phone":
+			stage.Phone = true
+		case "card":
+			stage.Card = true
+		default:
+			return PIIStage{}, fmt.Errorf("unknown pii kind %q", kind)
+		}
+	}
+	return stage, nil
+}
+
+func (PIIStage) Name() string { return "pii" }
+
+func (s PIIStage) Process(item *Item) error {
+	item.Doc.Title = s.Mask(item.Doc.Title)
+	item.Doc.Text = s.Mask(item.Doc.Text)
+	if item.CleanText != "" {
+		item.CleanText = s.Mask(item.CleanText)
+		item.Doc.TextClean = s.Mask(item.Doc.TextClean)
+	}
+	return nil
+}
+
+// Mask replaces every enabled kind of personal data in text with a placeholder.
+func (s PIIStage) Mask(text string) string {
+	if s.Email {
+		text = emailRe.ReplaceAllString(text, "[email]")
+	}
+	if s.Card {
+		// Cards go before phones: a grouped card number contains phone-like runs.
+		text = cardRe.ReplaceAllStringFunc(text, func(match string) string {
+			if luhnValid(match) {
+				return "[card]"
+			}
+			return match
+		})
+	}
+	if s.Phone {
+		text = phoneRe.ReplaceAllString(text, "[phone]")
+	}
+	return text
+}
+
+// luhnValid checks the card number checksum, which rules out most prices,
+// order numbers and other long digit runs.
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
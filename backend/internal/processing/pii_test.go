@@ -0,0 +1,46 @@
+package processing_test
+
+import (
+	"testing"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPIIStageMasks(t *testing.T) {
+	stage, err := processing.NewPIIStage(nil)
+	require.NoError(t, err)
+
+	cases := map[string]string{
+		"Пишите на tours@example.ru или звоните +7 (999) 123-45-67": "Пишите на [email] или звоните [phone]",
+		"Горячая линия 8 800 555-35-35, оплата картой":              "Горячая линия [phone], оплата картой",
+		"Перевод на 4111 1111 1111 1111, тур от 45 000 руб":         "Перевод на [card], тур от 45 000 руб",
+		"Заявка 1234567890123 принята":                              "Заявка 1234567890123 принята",
+	}
+	for in, want := range cases {
+		require.Equal(t, want, stage.Mask(in), in)
+	}
+}
+
+func TestPIIStageKinds(t *testing.T) {
+	stage, err := processing.NewPIIStage([]string{"email"})
+	require.NoError(t, err)
+	require.Equal(t, "[email], +7 999 123 45 67", stage.Mask("a@b.ru, +7 999 123 45 67"))
+
+	_, err = processing.NewPIIStage([]string{"passport"})
+	require.Error(t, err)
+
+	_, err = processing.BuildPipeline([]string{"pii"}, processing.Options{PIIKinds: []string{"passport"}})
+	require.Error(t, err)
+}
+
+func TestPIIStageRunsBeforeKeywords(t *testing.T) {
+	pipeline, err := processing.BuildPipeline([]string{"pii", "clean", "keywords"}, processing.Options{KeywordLimit: 10, KeywordMinLength: 3})
+	require.NoError(t, err)
+
+	item := &processing.Item{Doc: models.NewsDocument{Text: "Бронь через manager@tourfirm.ru, Турция"}}
+	require.NoError(t, pipeline.Run(item))
+	require.Equal(t, "Бронь через [email], Турция", item.Doc.Text)
+	require.NotContains(t, item.Doc.Keywords, "manager")
+}
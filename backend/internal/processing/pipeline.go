@@ -0,0 +1,484 @@
+package processing
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/destinations"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// DefaultStages is the stage order used when a deployment does not configure one.
+var DefaultStages = []string{"urls", "clean", "title", "keywords", "tags", "destinations", "expiry", "tourdates", "soldout", "price", "quality", "rules", "id", "cluster", "repost", "sourcegroups"}
+
+// Item carries a document through the pipeline along with intermediate
+// values that stages share but that are not stored in Elasticsearch.
+type Item struct {
+	Doc       models.NewsDocument
+	CleanText string
+	// GeneratedTitle is set by the title stage when the post had no title
+	// of its own and one was made from the text.
+	GeneratedTitle bool
+	// Hashtags are set by the tags stage and kept as curated keywords.
+	Hashtags []string
+	// ReplyTo links to the post this one replies to, when the source says so.
+	ReplyTo string
+	// SoldOut is set by the soldout stage on notices that an earlier offer is gone.
+	SoldOut *SoldOutNotice
+	// DedupeKey overrides Doc.ID as the key for the worker's dedupe cache.
+	DedupeKey string
+	// Repost marks documents that should be merged into an earlier copy
+	// instead of being indexed as new documents.
+	Repost bool
+	// Drop is set by the rules stage on documents that must not be
+	// indexed; the pipeline stops there.
+	Drop bool
+	// Index routes the document to another index than the news index.
+	Index string
+	// Rules names the operator rules that matched.
+	Rules []string
+}
+
+// Stage is a single enrichment step of the pipeline.
+type Stage interface {
+	Name() string
+	Process(item *Item) error
+}
+
+// Pipeline runs its stages in order against an Item.
+type Pipeline struct {
+	stages []Stage
+}
+
+// Options parametrises the stages created by BuildPipeline.
+type Options struct {
+	KeywordLimit     int
+	KeywordMinLength int
+	// KeywordTokensPerKeyword scales the keyword limit with the length of
+	// the text, one keyword per that many tokens, between KeywordMinLimit
+	// and KeywordMaxLimit; 0 keeps KeywordLimit for every document.
+	KeywordTokensPerKeyword int
+	KeywordMinLimit         int
+	KeywordMaxLimit         int
+	// KeywordStemming makes the keywords stage store stems.
+	KeywordStemming bool
+	TitleMaxWords   int
+	// RepostSources lists sources whose posts are deduplicated by content
+	// only; "*" enables repost detection for every source.
+	RepostSources []string
+	RepostWindow  time.Duration
+	// SoldOutWindow is how far back the offer a sold-out notice refers to
+	// is looked for.
+	SoldOutWindow time.Duration
+	// PIIKinds selects what the pii stage masks; empty means all PIIKinds.
+	PIIKinds []string
+	// Destinations is the taxonomy used by the destinations stage;
+	// nil means destinations.Default().
+	Destinations *destinations.Taxonomy
+	// TitleRules strip per-source boilerplate in the title stage.
+	TitleRules *TitleRules
+	// Rules are the operator rules applied by the rules stage.
+	Rules *Rules
+	// SourceGroups are the sources the sourcegroups stage treats as one.
+	SourceGroups *SourceGroups
+}
+
+// NewPipeline creates a pipeline from already constructed stages.
+func NewPipeline(stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// BuildPipeline resolves stage names into a pipeline, preserving their order.
+// An empty list yields DefaultStages.
+func BuildPipeline(names []string, opts Options) (*Pipeline, error) {
+	if len(names) == 0 {
+		names = DefaultStages
+	}
+	if opts.TitleMaxWords <= 0 {
+		opts.TitleMaxWords = 10
+	}
+	if opts.RepostWindow <= 0 {
+		opts.RepostWindow = 24 * time.Hour
+	}
+	if opts.SoldOutWindow <= 0 {
+		opts.SoldOutWindow = 7 * 24 * time.Hour
+	}
+	taxonomy := opts.Destinations
+	if taxonomy == nil {
+		taxonomy = destinations.Default()
+	}
+
+	seen := make(map[string]struct{}, len(names))
+	stages := make([]Stage, 0, len(names))
+	for _, raw := range names {
+		name := strings.ToLower(strings.TrimSpace(raw))
+		if _, dup := seen[name]; dup {
+			return nil, fmt.Errorf("stage %q listed more than once", name)
+		}
+		seen[name] = struct{}{}
+
+		var stage Stage
+		switch name {
+		case "pii":
+			pii, err := NewPIIStage(opts.PIIKinds)
+			if err != nil {
+				return nil, err
+			}
+			stage = pii
+		case "urls":
+			stage = URLStage{}
+		case "clean":
+			stage = CleanStage{}
+		case "title":
+			stage = TitleStage{MaxWords: opts.TitleMaxWords, Rules: opts.TitleRules}
+		case "keywords":
+			stage = KeywordStage{
+				Limit:            opts.KeywordLimit,
+				MinLength:        opts.KeywordMinLength,
+				TokensPerKeyword: opts.KeywordTokensPerKeyword,
+				MinLimit:         opts.KeywordMinLimit,
+				MaxLimit:         opts.KeywordMaxLimit,
+				Stem:             opts.KeywordStemming,
+			}
+		case "tags":
+			stage = TagStage{}
+		case "destinations":
+			stage = DestinationStage{Taxonomy: taxonomy}
+		case "expiry":
+			stage = ExpiryStage{}
+		case "tourdates":
+			stage = TourDateStage{}
+		case "soldout":
+			stage = SoldOutStage{Window: opts.SoldOutWindow}
+		case "price":
+			stage = PriceStage{}
+		case "quality":
+			stage = QualityStage{}
+		case "rules":
+			stage = RuleStage{Rules: opts.Rules, Taxonomy: taxonomy}
+		case "id":
+			stage = IDStage{}
+		case "cluster":
+			stage = ClusterStage{}
+		case "repost":
+			stage = NewRepostStage(opts.RepostSources, opts.RepostWindow)
+		case "sourcegroups":
+			stage = SourceGroupStage{Groups: opts.SourceGroups}
+		default:
+			return nil, fmt.Errorf("unknown stage %q", name)
+		}
+		stages = append(stages, stage)
+	}
+
+	return NewPipeline(stages...), nil
+}
+
+// identityStages are the stages that decide a document's ID and cluster_id.
+var identityStages = []string{"pii", "clean", "title", "id", "cluster", "repost", "sourcegroups"}
+
+// BuildIdentityPipeline is BuildPipeline keeping only the stages of names
+// that decide a document's ID and cluster_id, to learn the ID a document
+// would get without enriching it. Operator rules are not applied.
+func BuildIdentityPipeline(names []string, opts Options) (*Pipeline, error) {
+	if len(names) == 0 {
+		names = DefaultStages
+	}
+	kept := slices.DeleteFunc(slices.Clone(names), func(name string) bool {
+		return !slices.Contains(identityStages, strings.ToLower(strings.TrimSpace(name)))
+	})
+	if len(kept) == 0 {
+		return NewPipeline(), nil
+	}
+	return BuildPipeline(kept, opts)
+}
+
+// Run applies every stage in order and stops at the first error or once
+// a stage drops the item.
+func (p *Pipeline) Run(item *Item) error {
+	for _, stage := range p.stages {
+		if err := stage.Process(item); err != nil {
+			return fmt.Errorf("stage %s: %w", stage.Name(), err)
+		}
+		if item.Drop {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Stages returns the names of the configured stages in execution order.
+func (p *Pipeline) Stages() []string {
+	names := make([]string, 0, len(p.stages))
+	for _, stage := range p.stages {
+		names = append(names, stage.Name())
+	}
+	return names
+}
+
+// URLStage extracts links from the original text.
+type URLStage struct{}
+
+func (URLStage) Name() string { return "urls" }
+
+func (URLStage) Process(item *Item) error {
+	item.Doc.URLs = ExtractURLs(item.Doc.Text)
+	return nil
+}
+
+// CleanStage prepares the text used for keyword extraction and fingerprinting
+// and stores it as text_clean for search.
+type CleanStage struct{}
+
+func (CleanStage) Name() string { return "clean" }
+
+func (CleanStage) Process(item *Item) error {
+	item.CleanText = CleanText(item.Doc.Text)
+	item.Doc.TextClean = item.CleanText
+	return nil
+}
+
+// TitleStage strips source boilerplate from the title, so titles render
+// clean and IDs and fingerprints do not depend on it, and generates a
+// title from the text when none is left.
+type TitleStage struct {
+	MaxWords int
+	Rules    *TitleRules
+}
+
+func (TitleStage) Name() string { return "title" }
+
+func (s TitleStage) Process(item *Item) error {
+	item.Doc.Title = s.Rules.Clean(item.Doc.Source, item.Doc.Title)
+	if item.Doc.Title == "" && item.Doc.Text != "" {
+		generated := GenerateTitleFromText(item.Doc.Text, s.MaxWords)
+		// A first sentence that is nothing but boilerplate is kept as is.
+		if cleaned := s.Rules.Clean(item.Doc.Source, generated); cleaned != "" {
+			generated = cleaned
+		}
+		item.Doc.Title = generated
+		item.GeneratedTitle = true
+	}
+	return nil
+}
+
+// KeywordStage extracts the most frequent keywords from title and text.
+type KeywordStage struct {
+	Limit     int
+	MinLength int
+	// With TokensPerKeyword set, the limit is one keyword per that many
+	// tokens of title and text, clamped to [MinLimit, MaxLimit], instead of
+	// Limit: a short post is not padded with noise and a long article gets
+	// more than a handful. A zero MaxLimit leaves the limit unbounded.
+	TokensPerKeyword int
+	MinLimit         int
+	MaxLimit         int
+	// Stem stores Russian keywords as their stems, so inflections are
+	// counted and aggregated together, and keeps the most frequent
+	// spelling of each in KeywordForms.
+	Stem bool
+}
+
+func (KeywordStage) Name() string { return "keywords" }
+
+func (s KeywordStage) Process(item *Item) error {
+	text := item.Doc.Title + " " + item.cleanText()
+	if !s.Stem {
+		item.Doc.Keywords = ExtractKeywords(text, s.limit(text), s.MinLength)
+		item.setKeywords(item.Doc.Keywords)
+		return nil
+	}
+
+	keywords, forms := ExtractStemmedKeywords(text, s.limit(text), s.MinLength)
+	item.Doc.KeywordForms = nil
+	for i, keyword := range keywords {
+		if forms[i] != keyword {
+			if item.Doc.KeywordForms == nil {
+				item.Doc.KeywordForms = make(map[string]string)
+			}
+			item.Doc.KeywordForms[keyword] = forms[i]
+		}
+	}
+	item.setKeywords(keywords)
+	return nil
+}
+
+func (s KeywordStage) limit(text string) int {
+	if s.TokensPerKeyword <= 0 {
+		return s.Limit
+	}
+	limit := max(len(strings.Fields(text))/s.TokensPerKeyword, s.MinLimit, 1)
+	if s.MaxLimit > 0 {
+		limit = min(limit, s.MaxLimit)
+	}
+	return limit
+}
+
+// TagStage keeps the hashtags of the title and text as keywords, which
+// punctuation stripping would otherwise reduce to ordinary words, and
+// collects the @mentioned channels and users. It may run before or after
+// the keywords stage.
+type TagStage struct{}
+
+func (TagStage) Name() string { return "tags" }
+
+func (TagStage) Process(item *Item) error {
+	raw := item.Doc.Title + " " + item.Doc.Text
+	item.Hashtags = ExtractHashtags(raw)
+	item.Doc.Mentions = ExtractMentions(raw)
+	item.setKeywords(item.Doc.Keywords)
+	return nil
+}
+
+// setKeywords stores keywords with the hashtags in front, as the author's
+// own choice of keywords outranks the extracted ones.
+func (i *Item) setKeywords(extracted []string) {
+	keywords := make([]string, 0, len(i.Hashtags)+len(extracted))
+	seen := make(map[string]struct{}, cap(keywords))
+	for _, keyword := range append(slices.Clip(i.Hashtags), extracted...) {
+		if _, dup := seen[keyword]; !dup {
+			seen[keyword] = struct{}{}
+			keywords = append(keywords, keyword)
+		}
+	}
+	if len(keywords) == 0 {
+		keywords = nil
+	}
+	i.Doc.Keywords = keywords
+	// Full-text search analyzes the words as written, not their stems.
+	words := make([]string, len(keywords))
+	for n, keyword := range keywords {
+		words[n] = keyword
+		if form, ok := i.Doc.KeywordForms[keyword]; ok {
+			words[n] = form
+		}
+	}
+	i.Doc.KeywordText = strings.Join(words, " ")
+}
+
+// DestinationStage tags the document with every destination it mentions,
+// including the enclosing countries and regions, and places it on the map
+// at the most specific ones.
+type DestinationStage struct {
+	Taxonomy *destinations.Taxonomy
+}
+
+func (DestinationStage) Name() string { return "destinations" }
+
+func (s DestinationStage) Process(item *Item) error {
+	taxonomy := s.Taxonomy
+	if taxonomy == nil {
+		taxonomy = destinations.Default()
+	}
+	item.Doc.Destinations = ExtractDestinations(item.Doc.Title+" "+item.Doc.Text, taxonomy)
+	item.Doc.Locations = taxonomy.Locations(item.Doc.Destinations)
+	return nil
+}
+
+// ExpiryStage sets ExpiresAt from a deadline stated in the text.
+type ExpiryStage struct{}
+
+func (ExpiryStage) Name() string { return "expiry" }
+
+func (ExpiryStage) Process(item *Item) error {
+	item.Doc.ExpiresAt = ExtractExpiry(item.Doc.Text, item.Doc.Timestamp)
+	return nil
+}
+
+// TourDateStage sets TravelStart and TravelEnd from the trip dates named in
+// the title or text. Dates already set by the scraper are kept.
+type TourDateStage struct{}
+
+func (TourDateStage) Name() string { return "tourdates" }
+
+func (TourDateStage) Process(item *Item) error {
+	if !item.Doc.TravelStart.IsZero() {
+		return nil
+	}
+	item.Doc.TravelStart, item.Doc.TravelEnd = ExtractTourDates(item.Doc.Title+"\n"+item.Doc.Text, item.Doc.Timestamp)
+	return nil
+}
+
+// PriceStage sets Price to the lowest ruble amount mentioned in the text.
+type PriceStage struct{}
+
+func (PriceStage) Name() string { return "price" }
+
+func (PriceStage) Process(item *Item) error {
+	item.Doc.Price = ExtractPrice(item.Doc.Text)
+	return nil
+}
+
+// IDStage derives a deterministic document ID from title, text and timestamp.
+type IDStage struct{}
+
+func (IDStage) Name() string { return "id" }
+
+func (IDStage) Process(item *Item) error {
+	item.Doc.ID = BuildDocumentID(item.Doc.Title, item.cleanText(), item.Doc.Timestamp)
+	return nil
+}
+
+// ClusterStage groups copies of the same offer posted by different sources
+// under a shared ClusterID, the timestamp-free content fingerprint.
+type ClusterStage struct{}
+
+func (ClusterStage) Name() string { return "cluster" }
+
+func (ClusterStage) Process(item *Item) error {
+	item.Doc.ClusterID = BuildFingerprint(item.Doc.Title, item.cleanText())
+	return nil
+}
+
+// RepostStage replaces the document ID with a timestamp-free content
+// fingerprint for selected sources, so channels that repost the same offer
+// every day update one document instead of creating a new one each time.
+// Sightings are bucketed by Window: only the first one per bucket counts.
+type RepostStage struct {
+	Sources map[string]struct{}
+	All     bool
+	Window  time.Duration
+}
+
+// NewRepostStage builds a RepostStage from a list of sources, where "*" matches any source.
+func NewRepostStage(sources []string, window time.Duration) RepostStage {
+	stage := RepostStage{Sources: make(map[string]struct{}, len(sources)), Window: window}
+	for _, source := range sources {
+		source = strings.TrimSpace(source)
+		if source == "*" {
+			stage.All = true
+			continue
+		}
+		if source != "" {
+			stage.Sources[source] = struct{}{}
+		}
+	}
+	return stage
+}
+
+func (RepostStage) Name() string { return "repost" }
+
+func (s RepostStage) Process(item *Item) error {
+	if !s.All {
+		if _, ok := s.Sources[item.Doc.Source]; !ok {
+			return nil
+		}
+	}
+
+	seen := item.Doc.Timestamp.UTC().Truncate(time.Second)
+	item.Doc.ID = BuildFingerprint(item.Doc.Title, item.cleanText())
+	item.Doc.SeenCount = 1
+	item.Doc.LastSeen = seen
+	item.DedupeKey = item.Doc.ID + "|" + seen.Truncate(s.Window).Format(time.RFC3339)
+	item.Repost = true
+	return nil
+}
+
+// cleanText returns the cleaned text, computing it when the clean stage is disabled.
+func (i *Item) cleanText() string {
+	if i.CleanText == "" && i.Doc.Text != "" {
+		i.CleanText = CleanText(i.Doc.Text)
+	}
+	return i.CleanText
+}
@@ -0,0 +1,190 @@
+package processing_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildPipelineDefaults(t *testing.T) {
+	pipeline, err := processing.BuildPipeline(nil, processing.Options{KeywordLimit: 5, KeywordMinLength: 3})
+	require.NoError(t, err)
+	require.Equal(t, processing.DefaultStages, pipeline.Stages())
+
+	item := &processing.Item{Doc: models.NewsDocument{
+		Text:      "Горящий тур в Турцию! Подробности https://example.com/tur",
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}}
+	require.NoError(t, pipeline.Run(item))
+	require.Equal(t, "Горящий тур в Турцию", item.Doc.Title)
+	require.Equal(t, []string{"https://example.com/tur"}, item.Doc.URLs)
+	require.Equal(t, "Горящий тур в Турцию Подробности", item.CleanText)
+	require.Equal(t, item.CleanText, item.Doc.TextClean)
+	require.Contains(t, item.Doc.Keywords, "турцию")
+	require.Equal(t, []string{"турция", "ближний восток"}, item.Doc.Destinations)
+	require.Equal(t, []models.GeoPoint{{Lat: 39.0, Lon: 35.0}}, item.Doc.Locations)
+	require.Equal(t, strings.Join(item.Doc.Keywords, " "), item.Doc.KeywordText)
+	require.Equal(t, processing.BuildDocumentID(item.Doc.Title, item.CleanText, item.Doc.Timestamp), item.Doc.ID)
+}
+
+func TestBuildPipelineCustomOrder(t *testing.T) {
+	pipeline, err := processing.BuildPipeline([]string{"Title", " id "}, processing.Options{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"title", "id"}, pipeline.Stages())
+}
+
+func TestBuildPipelineRejectsInvalidStages(t *testing.T) {
+	_, err := processing.BuildPipeline([]string{"clean", "translate"}, processing.Options{})
+	require.ErrorContains(t, err, `unknown stage "translate"`)
+
+	_, err = processing.BuildPipeline([]string{"clean", "clean"}, processing.Options{})
+	require.ErrorContains(t, err, "more than once")
+}
+
+func TestKeywordStageWithoutCleanStage(t *testing.T) {
+	item := &processing.Item{Doc: models.NewsDocument{Title: "Тур", Text: "поездка, поездка и море"}}
+	require.NoError(t, processing.KeywordStage{Limit: 2, MinLength: 3}.Process(item))
+	require.Equal(t, []string{"поездка", "море"}, item.Doc.Keywords)
+}
+
+func TestKeywordStageStems(t *testing.T) {
+	item := &processing.Item{Doc: models.NewsDocument{Title: "Туры", Text: "туров на море, туры"}}
+	require.NoError(t, processing.KeywordStage{Limit: 2, MinLength: 3, Stem: true}.Process(item))
+	require.Equal(t, []string{"тур", "мор"}, item.Doc.Keywords)
+	require.Equal(t, map[string]string{"тур": "туры", "мор": "море"}, item.Doc.KeywordForms)
+	require.Equal(t, "туры море", item.Doc.KeywordText)
+}
+
+func TestKeywordStageScalesLimit(t *testing.T) {
+	stage := processing.KeywordStage{Limit: 8, MinLength: 3, TokensPerKeyword: 4, MinLimit: 2, MaxLimit: 5}
+	var words []string
+	for i := range 40 {
+		words = append(words, fmt.Sprintf("слово%02d", i))
+	}
+
+	for _, tc := range []struct {
+		words, want int
+	}{
+		{words: 6, want: 2},  // short posts get MinLimit
+		{words: 12, want: 3}, // one keyword per 4 tokens, title included
+		{words: 40, want: 5}, // long texts are capped at MaxLimit
+	} {
+		item := &processing.Item{Doc: models.NewsDocument{Title: "Тур", Text: strings.Join(words[:tc.words], " ")}}
+		require.NoError(t, stage.Process(item))
+		require.Len(t, item.Doc.Keywords, tc.want, "%d words", tc.words)
+	}
+
+	// Without a ratio every document gets Limit.
+	item := &processing.Item{Doc: models.NewsDocument{Title: "Тур", Text: strings.Join(words, " ")}}
+	require.NoError(t, processing.KeywordStage{Limit: 8, MinLength: 3}.Process(item))
+	require.Len(t, item.Doc.Keywords, 8)
+}
+
+func TestTagStage(t *testing.T) {
+	doc := models.NewsDocument{
+		Title: "Горящий тур",
+		Text:  "Море, море и пляж #Турция #горящиетуры. Бронь у @HotTours",
+	}
+	keywords := processing.KeywordStage{Limit: 1, MinLength: 3}
+
+	// Hashtags lead the keywords whichever of the two stages runs first.
+	for _, stages := range [][]processing.Stage{
+		{keywords, processing.TagStage{}},
+		{processing.TagStage{}, keywords},
+	} {
+		item := &processing.Item{Doc: doc}
+		require.NoError(t, processing.NewPipeline(stages...).Run(item))
+		require.Equal(t, []string{"турция", "горящиетуры", "море"}, item.Doc.Keywords)
+		require.Equal(t, "турция горящиетуры море", item.Doc.KeywordText)
+		require.Equal(t, []string{"hottours"}, item.Doc.Mentions)
+	}
+}
+
+func TestTitleStageKeepsExistingTitle(t *testing.T) {
+	item := &processing.Item{Doc: models.NewsDocument{Title: "Есть", Text: "Другой текст."}}
+	require.NoError(t, processing.TitleStage{MaxWords: 10}.Process(item))
+	require.Equal(t, "Есть", item.Doc.Title)
+}
+
+func TestTourDateStage(t *testing.T) {
+	posted := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	item := &processing.Item{Doc: models.NewsDocument{Title: "Кипр с 12 по 19 июня", Text: "Всё включено.", Timestamp: posted}}
+	require.NoError(t, processing.TourDateStage{}.Process(item))
+	require.Equal(t, time.Date(2024, 6, 12, 0, 0, 0, 0, time.UTC), item.Doc.TravelStart)
+	require.Equal(t, time.Date(2024, 6, 19, 0, 0, 0, 0, time.UTC), item.Doc.TravelEnd)
+
+	// Dates set upstream win.
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	item = &processing.Item{Doc: models.NewsDocument{Text: "Вылет 15 июня", Timestamp: posted, TravelStart: start}}
+	require.NoError(t, processing.TourDateStage{}.Process(item))
+	require.Equal(t, start, item.Doc.TravelStart)
+	require.True(t, item.Doc.TravelEnd.IsZero())
+}
+
+func TestRepostStage(t *testing.T) {
+	stage := processing.NewRepostStage([]string{"daily"}, 24*time.Hour)
+	first := &processing.Item{Doc: models.NewsDocument{
+		Title:     "Египет",
+		Text:      "Египет от 30000 руб!",
+		Source:    "daily",
+		Timestamp: time.Date(2024, 6, 1, 7, 30, 0, 0, time.UTC),
+	}}
+	second := &processing.Item{Doc: first.Doc}
+	second.Doc.Timestamp = first.Doc.Timestamp.Add(24 * time.Hour)
+
+	require.NoError(t, stage.Process(first))
+	require.NoError(t, stage.Process(second))
+	require.True(t, first.Repost)
+	require.Equal(t, first.Doc.ID, second.Doc.ID)
+	require.NotEqual(t, first.DedupeKey, second.DedupeKey)
+	require.Equal(t, 1, first.Doc.SeenCount)
+	require.Equal(t, first.Doc.Timestamp, first.Doc.LastSeen)
+
+	other := &processing.Item{Doc: models.NewsDocument{ID: "keep", Text: "text", Source: "other"}}
+	require.NoError(t, stage.Process(other))
+	require.False(t, other.Repost)
+	require.Equal(t, "keep", other.Doc.ID)
+	require.Empty(t, other.DedupeKey)
+}
+
+type failingStage struct{}
+
+func (failingStage) Name() string { return "fail" }
+
+func (failingStage) Process(*processing.Item) error { return errors.New("boom") }
+
+func TestPipelineStopsOnError(t *testing.T) {
+	pipeline := processing.NewPipeline(failingStage{}, processing.IDStage{})
+	item := &processing.Item{Doc: models.NewsDocument{Text: "text"}}
+	require.EqualError(t, pipeline.Run(item), "stage fail: boom")
+	require.Empty(t, item.Doc.ID)
+}
+
+func TestBuildIdentityPipeline(t *testing.T) {
+	pipeline, err := processing.BuildIdentityPipeline(nil, processing.Options{RepostSources: []string{"reposter"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"clean", "title", "id", "cluster", "repost", "sourcegroups"}, pipeline.Stages())
+
+	ts := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	full, err := processing.BuildPipeline(nil, processing.Options{KeywordLimit: 5, RepostSources: []string{"reposter"}})
+	require.NoError(t, err)
+	for _, source := range []string{"telegram", "reposter"} {
+		want := &processing.Item{Doc: models.NewsDocument{Text: "Турция на 7 ночей от 45 000 ₽! Вылет 15 июня", Timestamp: ts, Source: source}}
+		require.NoError(t, full.Run(want))
+		got := &processing.Item{Doc: models.NewsDocument{Text: want.Doc.Text, Timestamp: ts, Source: source}}
+		require.NoError(t, pipeline.Run(got))
+		require.Equal(t, want.Doc.ID, got.Doc.ID)
+		require.Equal(t, want.Doc.ClusterID, got.Doc.ClusterID)
+		require.Empty(t, got.Doc.Keywords)
+	}
+
+	empty, err := processing.BuildIdentityPipeline([]string{"urls", "keywords"}, processing.Options{})
+	require.NoError(t, err)
+	require.Empty(t, empty.Stages())
+}
@@ -0,0 +1,47 @@
+package processing
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// priceRe matches ruble amounts such as "45 000 ₽", "от 39900руб." or "52 тыс. рублей".
+var priceRe = regexp.MustCompile(`(?i)(\d{1,3}(?:[ \x{00a0}]\d{3})+|\d+)(?:[.,](\d+))?[\s\x{00a0}]*(тыс\.?|к)?[\s\x{00a0}]*(?:₽|руб|р\.|rub)`)
+
+var thousandsSeparators = strings.NewReplacer(" ", "", "\u00a0", "")
+
+// minPrice filters out amounts too small to be a tour price, like "0 руб за визу".
+const minPrice = 1000
+
+// ExtractPrice returns the lowest ruble price mentioned in text, or 0 when
+// there is none. Offers usually list several prices and the lowest one is
+// the "from" price users compare.
+func ExtractPrice(text string) int {
+	best := 0
+	for _, m := range priceRe.FindAllStringSubmatch(text, -1) {
+		whole := thousandsSeparators.Replace(m[1])
+		value, err := strconv.ParseFloat(whole+"."+fractionOrZero(m[2]), 64)
+		if err != nil {
+			continue
+		}
+		if m[3] != "" {
+			value *= 1000
+		}
+		price := int(value)
+		if price < minPrice {
+			continue
+		}
+		if best == 0 || price < best {
+			best = price
+		}
+	}
+	return best
+}
+
+func fractionOrZero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,24 @@
+package processing_test
+
+import (
+	"testing"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractPrice(t *testing.T) {
+	cases := map[string]int{
+		"Турция от 45 000 ₽ на двоих":                  45000,
+		"Египет 39900руб., Турция 52 тыс. рублей":      39900,
+		"Цена 61 500 р. за тур":                        61500,
+		"Всего 52,5 тыс руб":                           52500,
+		"Куба 120\u00a0000\u00a0₽":                     120000,
+		"Виза 0 руб, тур 78 000 RUB":                   78000,
+		"Вылет 15 июня, 7 ночей":                       0,
+		"Скидка 15% на туры в Турцию, звоните 8 800 2": 0,
+	}
+	for text, want := range cases {
+		require.Equal(t, want, processing.ExtractPrice(text), text)
+	}
+}
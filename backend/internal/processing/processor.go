@@ -9,6 +9,9 @@ import (
 	"strings"
 	"time"
 	"unicode"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/destinations"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/stemmer"
 )
 
 var urlRegex = regexp.MustCompile(`https?://[^\s]+`)
@@ -18,6 +21,14 @@ var (
 	punctuation = regexp.MustCompile(`[^\p{L}\p{N}\s]+`)
 )
 
+// hashtagRegex and mentionRegex only match at the start of a word, so
+// e-mail addresses and "№5#2" are not taken for tags. Mentions follow the
+// Telegram username rules: 5-32 Latin letters, digits and underscores.
+var (
+	hashtagRegex = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+	mentionRegex = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@])@([A-Za-z][A-Za-z0-9_]{4,31})\b`)
+)
+
 var stopwords = map[string]struct{}{
 	"и": {}, "в": {}, "на": {}, "с": {}, "по": {}, "к": {},
 	"a": {}, "an": {}, "the": {}, "to": {}, "in": {}, "for": {},
@@ -63,14 +74,64 @@ func CleanText(input string) string {
 	return decoded
 }
 
+// ExtractHashtags returns the hashtags of the text in lower case without
+// the leading #, in order of appearance. Tags made of digits only, such as
+// "#1", are skipped.
+func ExtractHashtags(text string) []string {
+	var tags []string
+	for _, tag := range extractTokens(hashtagRegex, text) {
+		if strings.IndexFunc(tag, unicode.IsLetter) >= 0 {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ExtractMentions returns the @usernames of the text in lower case without
+// the leading @, in order of appearance.
+func ExtractMentions(text string) []string {
+	return extractTokens(mentionRegex, text)
+}
+
+func extractTokens(re *regexp.Regexp, text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = RemoveURLs(html.UnescapeString(text))
+	seen := make(map[string]struct{})
+	var tokens []string
+	for _, match := range re.FindAllStringSubmatch(text, -1) {
+		token := strings.ToLower(match[1])
+		if _, ok := seen[token]; !ok {
+			seen[token] = struct{}{}
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
 // ExtractKeywords returns the most frequent words that are not stop-words.
 func ExtractKeywords(text string, limit, minLen int) []string {
+	keywords, _ := extractKeywords(text, limit, minLen, nil)
+	return keywords
+}
+
+// ExtractStemmedKeywords is ExtractKeywords counting the inflections of a
+// Russian word as one keyword, its stem: "туры", "тура" and "туров" all
+// count toward "тур". forms holds the most frequent spelling of each
+// keyword in the text, for display.
+func ExtractStemmedKeywords(text string, limit, minLen int) (keywords, forms []string) {
+	return extractKeywords(text, limit, minLen, stemmer.Russian)
+}
+
+func extractKeywords(text string, limit, minLen int, stem func(string) string) (keywords, forms []string) {
 	clean := strings.ToLower(CleanText(text))
 	if clean == "" {
-		return nil
+		return nil, nil
 	}
 
 	freq := make(map[string]int)
+	spellings := make(map[string]map[string]int)
 	for _, token := range strings.Fields(clean) {
 		token = strings.TrimFunc(token, func(r rune) bool {
 			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
@@ -81,41 +142,59 @@ func ExtractKeywords(text string, limit, minLen int) []string {
 		if _, skip := stopwords[token]; skip {
 			continue
 		}
-		freq[token]++
+		key := token
+		if stem != nil {
+			key = stem(token)
+			if spellings[key] == nil {
+				spellings[key] = make(map[string]int)
+			}
+			spellings[key][token]++
+		}
+		freq[key]++
 	}
 
 	if len(freq) == 0 {
-		return nil
+		return nil, nil
+	}
+
+	pairs := rankByCount(freq)
+	max := limit
+	if max <= 0 || max > len(pairs) {
+		max = len(pairs)
 	}
 
-	type kv struct {
-		word  string
-		count int
+	keywords = make([]string, 0, max)
+	for i := 0; i < max; i++ {
+		keywords = append(keywords, pairs[i].word)
+	}
+	if stem == nil {
+		return keywords, nil
+	}
+	forms = make([]string, 0, max)
+	for _, keyword := range keywords {
+		forms = append(forms, rankByCount(spellings[keyword])[0].word)
 	}
+	return keywords, forms
+}
+
+type wordCount struct {
+	word  string
+	count int
+}
 
-	pairs := make([]kv, 0, len(freq))
+// rankByCount orders words by descending count, then alphabetically.
+func rankByCount(freq map[string]int) []wordCount {
+	pairs := make([]wordCount, 0, len(freq))
 	for word, count := range freq {
-		pairs = append(pairs, kv{word: word, count: count})
+		pairs = append(pairs, wordCount{word: word, count: count})
 	}
-
 	sort.Slice(pairs, func(i, j int) bool {
 		if pairs[i].count == pairs[j].count {
 			return pairs[i].word < pairs[j].word
 		}
 		return pairs[i].count > pairs[j].count
 	})
-
-	max := limit
-	if max <= 0 || max > len(pairs) {
-		max = len(pairs)
-	}
-
-	keywords := make([]string, 0, max)
-	for i := 0; i < max; i++ {
-		keywords = append(keywords, pairs[i].word)
-	}
-
-	return keywords
+	return pairs
 }
 
 // BuildDocumentID hashes the most stable fields to form deterministic IDs.
@@ -124,6 +203,14 @@ func BuildDocumentID(title, text string, ts time.Time) string {
 	return hex.EncodeToString(s[:])
 }
 
+// BuildFingerprint hashes normalized title and text while ignoring the timestamp,
+// so the same offer reposted on another day maps to the same value.
+func BuildFingerprint(title, text string) string {
+	normalized := strings.ToLower(CleanText(title + " " + text))
+	s := sha1.Sum([]byte(normalized))
+	return hex.EncodeToString(s[:])
+}
+
 // GenerateTitleFromText creates a title from the first sentence or first N words of text.
 // Returns empty string if text is empty.
 func GenerateTitleFromText(text string, maxWords int) string {
@@ -157,3 +244,13 @@ func GenerateTitleFromText(text string, maxWords int) string {
 
 	return strings.Join(words, " ")
 }
+
+// ExtractDestinations returns the IDs of the destinations mentioned in text,
+// in any grammatical case, together with every enclosing destination. A nil
+// taxonomy uses the built-in gazetteer.
+func ExtractDestinations(text string, taxonomy *destinations.Taxonomy) []string {
+	if taxonomy == nil {
+		taxonomy = destinations.Default()
+	}
+	return taxonomy.Match(text)
+}
@@ -38,6 +38,13 @@ func TestExtractKeywords(t *testing.T) {
 	require.Nil(t, processing.ExtractKeywords("", 5, 3))
 }
 
+func TestExtractStemmedKeywords(t *testing.T) {
+	text := "Туры в Турцию: тура, туров, туры. Отели у моря"
+	keywords, forms := processing.ExtractStemmedKeywords(text, 3, 3)
+	require.Equal(t, []string{"тур", "мор", "отел"}, keywords)
+	require.Equal(t, []string{"туры", "моря", "отели"}, forms)
+}
+
 func TestExtractKeywordsIgnoresURLWords(t *testing.T) {
 	// Text with URL should not include URL domain/path words in keywords
 	text := "Тур поездка поездка https://example.com/tour-deals море"
@@ -55,6 +62,13 @@ func TestBuildDocumentID(t *testing.T) {
 	require.Equal(t, id1, id2)
 }
 
+func TestBuildFingerprintIgnoresFormatting(t *testing.T) {
+	a := processing.BuildFingerprint("Тур", "Турция — 7 ночей!")
+	b := processing.BuildFingerprint("тур", "турция 7   ночей")
+	require.Equal(t, a, b)
+	require.NotEqual(t, a, processing.BuildFingerprint("Тур", "Египет 7 ночей"))
+}
+
 func TestExtractURLs(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -77,6 +91,20 @@ func TestExtractURLs(t *testing.T) {
 	}
 }
 
+func TestExtractHashtags(t *testing.T) {
+	text := "#ГорящиеТуры в Турцию! #all_inclusive,#турция и снова #горящиетуры. " +
+		"Номер #1, ссылка https://example.com/#anchor и почта a#b"
+	require.Equal(t, []string{"горящиетуры", "all_inclusive", "турция"}, processing.ExtractHashtags(text))
+	require.Nil(t, processing.ExtractHashtags("без тегов"))
+}
+
+func TestExtractMentions(t *testing.T) {
+	text := "Бронь через @HotTours_bot или @hottours_bot, пишите sales@agency.ru. " +
+		"Канал @travel_deals. Не ники: @abc, @_under"
+	require.Equal(t, []string{"hottours_bot", "travel_deals"}, processing.ExtractMentions(text))
+	require.Nil(t, processing.ExtractMentions(""))
+}
+
 func TestRemoveURLs(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -121,3 +149,15 @@ func TestGenerateTitleFromText(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractDestinations(t *testing.T) {
+	require.Equal(t,
+		[]string{"анталья", "турция", "ближний восток"},
+		processing.ExtractDestinations("Горящие туры в Анталию и на курорты Анталии", nil),
+	)
+	require.Equal(t,
+		[]string{"пхукет", "таиланд", "юго-восточная азия"},
+		processing.ExtractDestinations("Неделя на Пхукете — 65 000 ₽", nil),
+	)
+	require.Empty(t, processing.ExtractDestinations("Распродажа авиабилетов", nil))
+}
@@ -0,0 +1,92 @@
+package processing
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The parts of the quality score, which add up to 100. A post earns the
+// text part in proportion to its length, up to qualityFullText runes of
+// clean text.
+const (
+	qualityTitle    = 20
+	qualityPrice    = 25
+	qualityLink     = 20
+	qualityNotSpam  = 20
+	qualityText     = 15
+	qualityFullText = 300
+)
+
+// Spam signals: more links than spamMaxLinks, capitals making up
+// spamCapsShare of at least spamMinLetters letters, or one word making up
+// spamRepeatShare of at least spamMinWords words.
+const (
+	spamMaxLinks    = 5
+	spamMinLetters  = 20
+	spamCapsShare   = 0.6
+	spamMinWords    = 12
+	spamRepeatShare = 1.0 / 3
+)
+
+// LooksLikeSpam reports whether a post with text and the given number of
+// links is stuffed with links, written mostly in capitals or repeats one
+// word over and over.
+func LooksLikeSpam(text string, links int) bool {
+	if links > spamMaxLinks {
+		return true
+	}
+
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters >= spamMinLetters && float64(upper) >= spamCapsShare*float64(letters) {
+		return true
+	}
+
+	words := strings.Fields(strings.ToLower(CleanText(text)))
+	if len(words) < spamMinWords {
+		return false
+	}
+	counts := make(map[string]int, len(words))
+	for _, word := range words {
+		counts[word]++
+		if float64(counts[word]) >= spamRepeatShare*float64(len(words)) {
+			return true
+		}
+	}
+	return false
+}
+
+// QualityStage scores how well-formed an offer is, from 0 to 100: whether
+// it came with its own title, a price and a link, does not look like spam,
+// and how much text it has. It runs after the title, urls and price stages,
+// whose results it scores.
+type QualityStage struct{}
+
+func (QualityStage) Name() string { return "quality" }
+
+func (QualityStage) Process(item *Item) error {
+	score := 0
+	if item.Doc.Title != "" && !item.GeneratedTitle {
+		score += qualityTitle
+	}
+	if item.Doc.Price > 0 {
+		score += qualityPrice
+	}
+	if len(item.Doc.URLs) > 0 {
+		score += qualityLink
+	}
+	if !LooksLikeSpam(item.Doc.Text, len(item.Doc.URLs)) {
+		score += qualityNotSpam
+	}
+	score += qualityText * min(utf8.RuneCountInString(item.cleanText()), qualityFullText) / qualityFullText
+	item.Doc.Quality = score
+	return nil
+}
@@ -0,0 +1,41 @@
+package processing_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+)
+
+func TestLooksLikeSpam(t *testing.T) {
+	require.False(t, processing.LooksLikeSpam("Турция, Анталья: вылет 15 июня, 7 ночей, всё включено, от 45 000 ₽ на двоих", 1))
+	require.True(t, processing.LooksLikeSpam("Горящие туры", 6), "too many links")
+	require.True(t, processing.LooksLikeSpam("СРОЧНО!!! ТУРЦИЯ ЗА КОПЕЙКИ, ПИШИТЕ В ЛИЧКУ", 0), "capitals")
+	require.False(t, processing.LooksLikeSpam("ОАЭ и США", 0), "too few letters to judge")
+	require.True(t, processing.LooksLikeSpam(strings.Repeat("скидки туры скидки ", 5), 0), "repeated word")
+}
+
+func TestQualityStage(t *testing.T) {
+	pipeline, err := processing.BuildPipeline([]string{"urls", "clean", "title", "price", "quality"}, processing.Options{})
+	require.NoError(t, err)
+
+	full := &processing.Item{Doc: models.NewsDocument{
+		Title: "Турция на майские",
+		Text:  strings.Repeat("Анталья, 7 ночей, всё включено, вылет из Москвы. ", 7) + "От 45 000 ₽, бронь https://example.com/tour",
+	}}
+	require.NoError(t, pipeline.Run(full))
+	require.Equal(t, 100, full.Doc.Quality)
+
+	// A generated title and a short text without price or link.
+	bare := &processing.Item{Doc: models.NewsDocument{Text: "Турция, горящий тур на выходные"}}
+	require.NoError(t, pipeline.Run(bare))
+	require.NotEmpty(t, bare.Doc.Title)
+	require.Equal(t, 21, bare.Doc.Quality, "not spam, and a little for the text")
+
+	spam := &processing.Item{Doc: models.NewsDocument{Title: "ТУРЫ", Text: "ТУРЫ ДЕШЕВО ТОЛЬКО СЕГОДНЯ ЗВОНИТЕ ПРЯМО СЕЙЧАС"}}
+	require.NoError(t, pipeline.Run(spam))
+	require.Equal(t, 22, spam.Doc.Quality, "its own title, and a little for the text")
+}
@@ -0,0 +1,204 @@
+package processing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/destinations"
+)
+
+// Rule is an operator-defined transformation: when every condition in When
+// holds, the actions in Then are applied. Rules let operators fix
+// extraction for a source without a deploy.
+type Rule struct {
+	// Name identifies the rule in errors and logs.
+	Name string        `yaml:"name"`
+	When RuleCondition `yaml:"when"`
+	Then RuleAction    `yaml:"then"`
+}
+
+// RuleCondition selects documents. Empty fields match everything; set
+// fields must all match.
+type RuleCondition struct {
+	// Source is compared with the document source exactly.
+	Source string `yaml:"source"`
+	// TextContains matches when the title or text contains any of the
+	// phrases, ignoring case and ё.
+	TextContains []string `yaml:"text_contains"`
+	// TextMatches is a regular expression matched against the title and
+	// text, joined by a newline.
+	TextMatches string `yaml:"text_matches"`
+	// Destination matches documents already tagged with the destination.
+	Destination string `yaml:"destination"`
+}
+
+// RuleAction says what happens to a matching document.
+type RuleAction struct {
+	// SetDestinations replaces the destinations found by the destinations
+	// stage; use taxonomy node IDs.
+	SetDestinations []string `yaml:"set_destinations"`
+	// AddKeywords appends keywords the document does not have yet.
+	AddKeywords []string `yaml:"add_keywords"`
+	// Drop discards the document; no later stage or rule runs.
+	Drop bool `yaml:"drop"`
+	// Index writes the document to this index instead of the news index.
+	Index string `yaml:"index"`
+}
+
+type rule struct {
+	Rule
+	contains []string
+	matches  *regexp.Regexp
+}
+
+// Rules is a list of rules applied in file order. A nil *Rules does
+// nothing.
+type Rules struct {
+	rules []rule
+}
+
+// indexName accepts the index names Elasticsearch allows, minus the
+// reserved leading characters.
+var indexName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// LoadRules reads ingestion rules from a YAML file; an empty path yields no
+// rules.
+func LoadRules(path string) (*Rules, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rules: %w", err)
+	}
+	return ParseRules(data)
+}
+
+// ParseRules builds rules from their YAML form: a list of Rule.
+func ParseRules(data []byte) (*Rules, error) {
+	var list []Rule
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&list); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decode rules: %w", err)
+	}
+
+	rules := &Rules{rules: make([]rule, 0, len(list))}
+	for i, r := range list {
+		name := strings.TrimSpace(r.Name)
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		when, then := r.When, r.Then
+		if when.Source == "" && len(when.TextContains) == 0 && when.TextMatches == "" && when.Destination == "" {
+			return nil, fmt.Errorf("rule %s: when needs at least one condition", name)
+		}
+		if len(then.SetDestinations) == 0 && len(then.AddKeywords) == 0 && !then.Drop && then.Index == "" {
+			return nil, fmt.Errorf("rule %s: then needs at least one action", name)
+		}
+		if then.Drop && (len(then.SetDestinations) > 0 || len(then.AddKeywords) > 0 || then.Index != "") {
+			return nil, fmt.Errorf("rule %s: drop cannot be combined with other actions", name)
+		}
+		if then.Index != "" && !indexName.MatchString(then.Index) {
+			return nil, fmt.Errorf("rule %s: %q is not a valid index name", name, then.Index)
+		}
+
+		compiled := rule{Rule: r}
+		compiled.Name = name
+		for _, phrase := range when.TextContains {
+			if phrase = foldYo(strings.ToLower(strings.TrimSpace(phrase))); phrase != "" {
+				compiled.contains = append(compiled.contains, phrase)
+			}
+		}
+		if when.TextMatches != "" {
+			re, err := regexp.Compile(when.TextMatches)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: text_matches: %w", name, err)
+			}
+			compiled.matches = re
+		}
+		rules.rules = append(rules.rules, compiled)
+	}
+	return rules, nil
+}
+
+// Apply runs every matching rule against item in order and returns the
+// names of those that matched. It stops at the first rule that drops the
+// item.
+func (r *Rules) Apply(item *Item) []string {
+	if r == nil {
+		return nil
+	}
+	var matched []string
+	text := strings.TrimSpace(item.Doc.Title + "\n" + item.Doc.Text)
+	folded := foldYo(strings.ToLower(text))
+	for _, rule := range r.rules {
+		if !rule.match(item, text, folded) {
+			continue
+		}
+		matched = append(matched, rule.Name)
+		then := rule.Then
+		if then.Drop {
+			item.Drop = true
+			return matched
+		}
+		if len(then.SetDestinations) > 0 {
+			item.Doc.Destinations = slices.Clone(then.SetDestinations)
+		}
+		for _, keyword := range then.AddKeywords {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" && !slices.Contains(item.Doc.Keywords, keyword) {
+				item.Doc.Keywords = append(item.Doc.Keywords, keyword)
+			}
+		}
+		if then.Index != "" {
+			item.Index = then.Index
+		}
+	}
+	return matched
+}
+
+func (r rule) match(item *Item, text, folded string) bool {
+	when := r.When
+	if when.Source != "" && when.Source != item.Doc.Source {
+		return false
+	}
+	if when.Destination != "" && !slices.Contains(item.Doc.Destinations, when.Destination) {
+		return false
+	}
+	if len(r.contains) > 0 && !slices.ContainsFunc(r.contains, func(phrase string) bool {
+		return strings.Contains(folded, phrase)
+	}) {
+		return false
+	}
+	if r.matches != nil && !r.matches.MatchString(text) {
+		return false
+	}
+	return true
+}
+
+// RuleStage applies operator rules from WORKER_RULES_FILE. Listed after
+// the enrichment stages, its actions override what they extracted.
+// Destinations set by a rule are placed on the map with Taxonomy, when set.
+type RuleStage struct {
+	Rules    *Rules
+	Taxonomy *destinations.Taxonomy
+}
+
+func (RuleStage) Name() string { return "rules" }
+
+func (s RuleStage) Process(item *Item) error {
+	before := item.Doc.Destinations
+	item.Rules = s.Rules.Apply(item)
+	if s.Taxonomy != nil && !slices.Equal(before, item.Doc.Destinations) {
+		item.Doc.Locations = s.Taxonomy.Locations(item.Doc.Destinations)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package processing_test
+
+import (
+	"testing"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/destinations"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+	"github.com/stretchr/testify/require"
+)
+
+const rulesYAML = `
+- name: antalya-fix
+  when:
+    source: hot_spb
+    text_contains: ["анталия", "Анталья"]
+  then:
+    set_destinations: [turkey/antalya]
+    add_keywords: [анталья]
+- name: drop-ads
+  when:
+    text_matches: '(?i)^реклама'
+  then:
+    drop: true
+- when:
+    destination: egypt
+  then:
+    index: news-egypt
+`
+
+func TestRulesApply(t *testing.T) {
+	rules, err := processing.ParseRules([]byte(rulesYAML))
+	require.NoError(t, err)
+
+	item := &processing.Item{Doc: models.NewsDocument{
+		Source:       "hot_spb",
+		Text:         "Отели Анталии: Анталия, вылет завтра",
+		Destinations: []string{"turkey"},
+		Keywords:     []string{"тур"},
+	}}
+	require.Equal(t, []string{"antalya-fix"}, rules.Apply(item))
+	require.Equal(t, []string{"turkey/antalya"}, item.Doc.Destinations)
+	require.Equal(t, []string{"тур", "анталья"}, item.Doc.Keywords)
+	require.False(t, item.Drop)
+
+	// Conditions are combined: the source does not match here.
+	item = &processing.Item{Doc: models.NewsDocument{Source: "vk", Text: "Анталья"}}
+	require.Empty(t, rules.Apply(item))
+
+	item = &processing.Item{Doc: models.NewsDocument{Source: "vk", Title: "Реклама", Destinations: []string{"egypt"}}}
+	require.Equal(t, []string{"drop-ads"}, rules.Apply(item))
+	require.True(t, item.Drop)
+	require.Empty(t, item.Index, "no rule runs after a drop")
+
+	item = &processing.Item{Doc: models.NewsDocument{Source: "vk", Text: "Хургада", Destinations: []string{"egypt"}}}
+	require.Equal(t, []string{"#3"}, rules.Apply(item))
+	require.Equal(t, "news-egypt", item.Index)
+
+	var none *processing.Rules
+	require.Empty(t, none.Apply(item))
+}
+
+func TestParseRulesRejectsInvalidRules(t *testing.T) {
+	for _, data := range []string{
+		`- {name: empty, then: {drop: true}}`,
+		`- {name: idle, when: {source: vk}}`,
+		`- {name: mixed, when: {source: vk}, then: {drop: true, index: other}}`,
+		`- {name: bad-index, when: {source: vk}, then: {index: "News Q"}}`,
+		`- {name: bad-regexp, when: {text_matches: "("}, then: {drop: true}}`,
+		`- {name: typo, when: {sorce: vk}, then: {drop: true}}`,
+		`{not: a list}`,
+	} {
+		_, err := processing.ParseRules([]byte(data))
+		require.Error(t, err, data)
+	}
+}
+
+func TestRuleStageStopsPipelineOnDrop(t *testing.T) {
+	rules, err := processing.ParseRules([]byte(rulesYAML))
+	require.NoError(t, err)
+	pipeline, err := processing.BuildPipeline([]string{"urls", "rules", "id"}, processing.Options{Rules: rules})
+	require.NoError(t, err)
+
+	item := &processing.Item{Doc: models.NewsDocument{Source: "vk", Text: "Реклама https://example.com"}}
+	require.NoError(t, pipeline.Run(item))
+	require.True(t, item.Drop)
+	require.Equal(t, []string{"drop-ads"}, item.Rules)
+	require.Empty(t, item.Doc.ID, "stages after a drop do not run")
+}
+
+func TestRuleStageRelocates(t *testing.T) {
+	rules, err := processing.ParseRules([]byte(`
+- when:
+    text_contains: ["кемер"]
+  then:
+    set_destinations: [кемер, анталья, турция]
+`))
+	require.NoError(t, err)
+	stage := processing.RuleStage{Rules: rules, Taxonomy: destinations.Default()}
+
+	item := &processing.Item{Doc: models.NewsDocument{
+		Text:         "Кемер",
+		Destinations: []string{"турция"},
+		Locations:    []models.GeoPoint{{Lat: 39.0, Lon: 35.0}},
+	}}
+	require.NoError(t, stage.Process(item))
+	require.Equal(t, []models.GeoPoint{{Lat: 36.6, Lon: 30.56}}, item.Doc.Locations)
+}
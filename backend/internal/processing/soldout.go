@@ -0,0 +1,77 @@
+package processing
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// soldOutRegex matches wording announcing that an offer is gone: sold out,
+// closed or cancelled. ё is folded to е before matching.
+var soldOutRegex = regexp.MustCompile(`(?i)(?:^|[^\p{L}])(?:` +
+	`мест(?:а)? (?:больше )?нет|места (?:закончились|распроданы)|все места (?:проданы|выкуплены)|` +
+	`распродан[аоы]?|(?:все|уже) продан[аоы]?|` +
+	`(?:предложение|акция|тур|вылет|рейс|подборка) (?:закрыт[аоы]?|отмен[еи]н[аоы]?|неактуальн[аоы]?|больше не актуальн[аоы]?)|` +
+	`(?:не|уже не|больше не)\s?актуальн[аоы]?|стоп[- ]?продаж[аи]|` +
+	`sold out|stop sale|no longer available` +
+	`)(?:$|[^\p{L}])`)
+
+// soldOutMaxWords bounds the length of a post taken for a sold-out notice
+// without a reply link, so that offers mentioning that some other dates
+// are sold out are not mistaken for one.
+const soldOutMaxWords = 40
+
+// SoldOutNotice describes a post announcing that an earlier offer is gone,
+// so the worker can find that offer and mark it expired.
+type SoldOutNotice struct {
+	// ReplyTo links to the post the notice replies to, which identifies
+	// the original offer.
+	ReplyTo string
+	// Links are the links of the notice, which the original may share.
+	Links []string
+	// Text is the notice without its sold-out wording, for finding the
+	// original by similarity when no reference matches.
+	Text string
+	// Since bounds how far back the original is looked for.
+	Since time.Time
+}
+
+// IsSoldOut reports whether text announces that an offer is sold out,
+// closed or cancelled.
+func IsSoldOut(text string) bool {
+	return soldOutRegex.MatchString(foldYo(text))
+}
+
+// SoldOutStage recognizes sold-out and cancellation notices. The notice
+// itself is stored as expired and Item.SoldOut tells the worker to expire
+// the offer it refers to, published at most Window earlier.
+type SoldOutStage struct {
+	Window time.Duration
+}
+
+func (SoldOutStage) Name() string { return "soldout" }
+
+func (s SoldOutStage) Process(item *Item) error {
+	text := item.Doc.Title + " " + item.cleanText()
+	if !IsSoldOut(text) {
+		return nil
+	}
+	if item.ReplyTo == "" && len(strings.Fields(text)) > soldOutMaxWords {
+		return nil
+	}
+
+	item.Doc.Status = models.StatusExpired
+	item.SoldOut = &SoldOutNotice{
+		ReplyTo: item.ReplyTo,
+		Links:   ExtractURLs(item.Doc.Text),
+		Text:    strings.Join(strings.Fields(soldOutRegex.ReplaceAllString(foldYo(text), " ")), " "),
+		Since:   item.Doc.Timestamp.Add(-s.Window),
+	}
+	return nil
+}
+
+func foldYo(text string) string {
+	return strings.NewReplacer("ё", "е", "Ё", "Е").Replace(text)
+}
@@ -0,0 +1,62 @@
+package processing_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSoldOut(t *testing.T) {
+	for _, text := range []string{
+		"Мест нет!",
+		"Турция, Кемер 12.06 — места закончились",
+		"Предложение закрыто, спасибо всем",
+		"Тур отменён из-за погоды",
+		"UPD: уже не актуально",
+		"Стоп-продажа на Анталью",
+		"Sold out, sorry",
+	} {
+		require.True(t, processing.IsSoldOut(text), text)
+	}
+	for _, text := range []string{
+		"Горящий тур в Турцию, вылет завтра",
+		"Распродажа туров в Египет",
+		"Успейте, пока не продано!",
+		"Местные экскурсии включены",
+	} {
+		require.False(t, processing.IsSoldOut(text), text)
+	}
+}
+
+func TestSoldOutStage(t *testing.T) {
+	ts := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	stage := processing.SoldOutStage{Window: 72 * time.Hour}
+
+	item := &processing.Item{Doc: models.NewsDocument{
+		Text:      "Турция Кемер 7 ночей — мест нет! https://example.com/tour/1",
+		Timestamp: ts,
+	}}
+	require.NoError(t, stage.Process(item))
+	require.Equal(t, models.StatusExpired, item.Doc.Status)
+	require.Equal(t, &processing.SoldOutNotice{
+		Links: []string{"https://example.com/tour/1"},
+		Text:  "Турция Кемер 7 ночей",
+		Since: ts.Add(-72 * time.Hour),
+	}, item.SoldOut)
+
+	reply := &processing.Item{Doc: models.NewsDocument{Text: "Мест нет", Timestamp: ts}, ReplyTo: "https://t.me/hottours/42"}
+	require.NoError(t, stage.Process(reply))
+	require.Equal(t, "https://t.me/hottours/42", reply.SoldOut.ReplyTo)
+
+	// A full offer that mentions sold-out dates in passing is not a notice.
+	offer := &processing.Item{Doc: models.NewsDocument{Timestamp: ts, Text: "Горящий тур в Турцию! На 12 июня мест нет, " +
+		"но есть вылеты 14 и 16 июня из Москвы и Петербурга. Отель 5 звёзд на первой линии, всё включено, " +
+		"трансфер, страховка и детские цены. Бронируйте у менеджера, количество мест ограничено, " +
+		"цены указаны на двоих взрослых при вылете из Москвы"}}
+	require.NoError(t, stage.Process(offer))
+	require.Nil(t, offer.SoldOut)
+	require.Empty(t, offer.Doc.Status)
+}
@@ -0,0 +1,107 @@
+package processing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SourceGroup is a set of sources treated as one, such as a channel and
+// the backup channel it cross-posts to.
+type SourceGroup struct {
+	// Name replaces the source of documents from any member.
+	Name    string
+	Sources []string
+	// Window is how close in time copies must be to collapse.
+	Window time.Duration
+}
+
+// SourceGroups maps sources to their group. A nil *SourceGroups groups
+// nothing.
+type SourceGroups struct {
+	bySource map[string]*SourceGroup
+}
+
+// ParseSourceGroups reads groups written as name=source|source, optionally
+// followed by @window, e.g. "hot_spb=hot_spb|hot_spb_backup@30m". Groups
+// without a window use window. A source may belong to one group only.
+func ParseSourceGroups(specs []string, window time.Duration) (*SourceGroups, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	groups := &SourceGroups{bySource: make(map[string]*SourceGroup)}
+	names := make(map[string]struct{}, len(specs))
+	for _, spec := range specs {
+		name, members, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid source group %q, want name=source|source[@window]", spec)
+		}
+		if _, dup := names[name]; dup {
+			return nil, fmt.Errorf("source group %q listed more than once", name)
+		}
+		names[name] = struct{}{}
+
+		group := &SourceGroup{Name: name, Window: window}
+		members, raw, hasWindow := strings.Cut(members, "@")
+		if hasWindow {
+			d, err := time.ParseDuration(strings.TrimSpace(raw))
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("source group %q: invalid window %q", name, raw)
+			}
+			group.Window = d
+		}
+		for _, source := range strings.Split(members, "|") {
+			source = strings.TrimSpace(source)
+			if source == "" {
+				continue
+			}
+			if other, taken := groups.bySource[source]; taken {
+				return nil, fmt.Errorf("source %q is in both groups %q and %q", source, other.Name, name)
+			}
+			group.Sources = append(group.Sources, source)
+			groups.bySource[source] = group
+		}
+		if len(group.Sources) == 0 {
+			return nil, fmt.Errorf("source group %q has no sources", name)
+		}
+	}
+	return groups, nil
+}
+
+// Lookup returns the group of source.
+func (g *SourceGroups) Lookup(source string) (*SourceGroup, bool) {
+	if g == nil {
+		return nil, false
+	}
+	group, ok := g.bySource[source]
+	return group, ok
+}
+
+// SourceGroupStage attributes documents from grouped sources to their
+// group and gives copies posted to several members within the group's
+// window the same ID, so the worker indexes one of them. Copies are
+// matched by title and text in windows aligned to the epoch, so two copies
+// straddling a window boundary stay apart. Sources outside any group keep
+// their timestamp-based IDs and are never collapsed with a group.
+type SourceGroupStage struct {
+	Groups *SourceGroups
+}
+
+func (SourceGroupStage) Name() string { return "sourcegroups" }
+
+func (s SourceGroupStage) Process(item *Item) error {
+	group, ok := s.Groups.Lookup(item.Doc.Source)
+	if !ok {
+		return nil
+	}
+	item.Doc.Source = group.Name
+	// Repost IDs ignore time and source already.
+	if item.Repost {
+		return nil
+	}
+	bucket := item.Doc.Timestamp.UTC().Truncate(group.Window)
+	item.Doc.ID = BuildDocumentID(group.Name+"|"+item.Doc.Title, item.cleanText(), bucket)
+	item.DedupeKey = ""
+	return nil
+}
@@ -0,0 +1,69 @@
+package processing_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSourceGroups(t *testing.T) {
+	groups, err := processing.ParseSourceGroups([]string{"hot_spb=hot_spb|hot_spb_backup", " tury = tury_main | tury_reserve @30m"}, time.Hour)
+	require.NoError(t, err)
+
+	group, ok := groups.Lookup("hot_spb_backup")
+	require.True(t, ok)
+	require.Equal(t, processing.SourceGroup{Name: "hot_spb", Sources: []string{"hot_spb", "hot_spb_backup"}, Window: time.Hour}, *group)
+	group, ok = groups.Lookup("tury_reserve")
+	require.True(t, ok)
+	require.Equal(t, 30*time.Minute, group.Window)
+	_, ok = groups.Lookup("rss")
+	require.False(t, ok)
+
+	none, err := processing.ParseSourceGroups(nil, time.Hour)
+	require.NoError(t, err)
+	_, ok = none.Lookup("hot_spb")
+	require.False(t, ok)
+
+	for _, specs := range [][]string{
+		{"hot_spb"},
+		{"=a|b"},
+		{"g=|"},
+		{"g=a|b@soon"},
+		{"g=a|b@-1m"},
+		{"g=a|b", "g=c"},
+		{"g=a|b", "h=b|c"},
+	} {
+		_, err := processing.ParseSourceGroups(specs, time.Hour)
+		require.Error(t, err, specs)
+	}
+}
+
+func TestSourceGroupStageCollapsesCrossPosts(t *testing.T) {
+	groups, err := processing.ParseSourceGroups([]string{"hot_spb=hot_spb|hot_spb_backup"}, time.Hour)
+	require.NoError(t, err)
+	pipeline, err := processing.BuildPipeline([]string{"clean", "id", "sourcegroups"}, processing.Options{SourceGroups: groups})
+	require.NoError(t, err)
+
+	posted := time.Date(2024, 6, 1, 10, 5, 0, 0, time.UTC)
+	run := func(source string, ts time.Time) models.NewsDocument {
+		item := &processing.Item{Doc: models.NewsDocument{Title: "Турция", Text: "Анталия 7 ночей от 50 000", Source: source, Timestamp: ts}}
+		require.NoError(t, pipeline.Run(item))
+		return item.Doc
+	}
+
+	main := run("hot_spb", posted)
+	backup := run("hot_spb_backup", posted.Add(3*time.Minute))
+	require.Equal(t, main.ID, backup.ID)
+	require.Equal(t, "hot_spb", backup.Source)
+	require.Equal(t, posted.Add(3*time.Minute), backup.Timestamp, "the post keeps its own time")
+
+	require.NotEqual(t, main.ID, run("hot_spb_backup", posted.Add(2*time.Hour)).ID, "a later repeat is a new offer")
+
+	independent := run("tury", posted)
+	require.NotEqual(t, main.ID, independent.ID)
+	require.NotEqual(t, independent.ID, run("rss", posted.Add(3*time.Minute)).ID)
+	require.Equal(t, "tury", independent.Source)
+}
@@ -0,0 +1,123 @@
+package processing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// TitleRule strips the boilerplate a source adds to its titles, such as a
+// channel signature after "|" or emoji around the title.
+type TitleRule struct {
+	// Source is the source the rule applies to, or "*" for every source.
+	Source string `json:"source"`
+	// Prefixes and Suffixes are removed when the title starts or ends with
+	// them, ignoring case; separators left behind are trimmed too.
+	Prefixes []string `json:"prefixes,omitempty"`
+	Suffixes []string `json:"suffixes,omitempty"`
+	// Patterns are regular expressions whose matches are removed.
+	Patterns []string `json:"patterns,omitempty"`
+	// EmojiFrames removes emoji and other pictographs at both ends, as in
+	// "🔥🔥 Горящий тур 🔥🔥".
+	EmojiFrames bool `json:"emoji_frames,omitempty"`
+}
+
+type titleRule struct {
+	TitleRule
+	patterns []*regexp.Regexp
+}
+
+// TitleRules holds title cleanup rules by source. A nil *TitleRules leaves
+// titles untouched.
+type TitleRules struct {
+	bySource map[string][]titleRule
+}
+
+// LoadTitleRules reads title cleanup rules from a JSON file; an empty path
+// yields no rules.
+func LoadTitleRules(path string) (*TitleRules, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read title rules: %w", err)
+	}
+	return ParseTitleRules(data)
+}
+
+// ParseTitleRules builds rules from their JSON form: a list of TitleRule.
+func ParseTitleRules(data []byte) (*TitleRules, error) {
+	var list []TitleRule
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("decode title rules: %w", err)
+	}
+
+	rules := &TitleRules{bySource: make(map[string][]titleRule)}
+	for i, r := range list {
+		source := strings.TrimSpace(r.Source)
+		if source == "" {
+			return nil, fmt.Errorf("title rule %d: source is required", i)
+		}
+		compiled := titleRule{TitleRule: r}
+		for _, pattern := range r.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("title rule %d: pattern %q: %w", i, pattern, err)
+			}
+			compiled.patterns = append(compiled.patterns, re)
+		}
+		rules.bySource[source] = append(rules.bySource[source], compiled)
+	}
+	return rules, nil
+}
+
+// Clean applies the rules of source, then those for every source, to title.
+func (r *TitleRules) Clean(source, title string) string {
+	if r == nil {
+		return title
+	}
+	for _, key := range []string{source, "*"} {
+		for _, rule := range r.bySource[key] {
+			title = rule.apply(title)
+		}
+	}
+	return title
+}
+
+func (r titleRule) apply(title string) string {
+	if r.EmojiFrames {
+		title = strings.TrimFunc(title, isFrameRune)
+	}
+	for _, re := range r.patterns {
+		title = strings.TrimSpace(whitespace.ReplaceAllString(re.ReplaceAllString(title, " "), " "))
+	}
+	for _, suffix := range r.Suffixes {
+		if n := len(title) - len(suffix); n >= 0 && strings.EqualFold(title[n:], suffix) {
+			title = strings.TrimRightFunc(title[:n], isTitleSeparator)
+		}
+	}
+	for _, prefix := range r.Prefixes {
+		if len(title) >= len(prefix) && strings.EqualFold(title[:len(prefix)], prefix) {
+			title = strings.TrimLeftFunc(title[len(prefix):], isTitleSeparator)
+		}
+	}
+	if r.EmojiFrames {
+		// A signature may have hidden a closing frame.
+		title = strings.TrimFunc(title, isFrameRune)
+	}
+	return title
+}
+
+// isFrameRune matches emoji with their modifiers and joiners, and spaces.
+func isFrameRune(r rune) bool {
+	return unicode.IsSpace(r) || unicode.In(r, unicode.So, unicode.Sk, unicode.Me, unicode.Variation_Selector) || r == '\u200d'
+}
+
+// isTitleSeparator matches what channels put between a title and their name.
+func isTitleSeparator(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("|-–—•·:/\\", r)
+}
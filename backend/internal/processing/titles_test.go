@@ -0,0 +1,87 @@
+package processing_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+	"github.com/stretchr/testify/require"
+)
+
+const titleRulesJSON = `[
+	{"source": "hot_spb", "suffixes": ["Горящие туры СПб"], "emoji_frames": true},
+	{"source": "vk", "prefixes": ["Реклама"], "patterns": ["\\s*#\\S+"]},
+	{"source": "*", "suffixes": ["(подписывайтесь)"]}
+]`
+
+func TestTitleRulesClean(t *testing.T) {
+	rules, err := processing.ParseTitleRules([]byte(titleRulesJSON))
+	require.NoError(t, err)
+
+	tests := []struct {
+		source, title, want string
+	}{
+		{"hot_spb", "Турция 7 ночей | Горящие туры СПб", "Турция 7 ночей"},
+		{"hot_spb", "🔥🔥 Турция 7 ночей — горящие туры спб 🔥🔥", "Турция 7 ночей"},
+		{"hot_spb", "✈️ Египет от 30 000 ₽ ✈️", "Египет от 30 000 ₽"},
+		{"vk", "Реклама: Турция #горящиетуры #спб", "Турция"},
+		// Rules of other sources do not apply; the wildcard rule does.
+		{"rss", "Турция | Горящие туры СПб (подписывайтесь)", "Турция | Горящие туры СПб"},
+		// Separators are only trimmed where boilerplate was removed.
+		{"hot_spb", "-50% на Турцию", "-50% на Турцию"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, rules.Clean(tt.source, tt.title), tt.title)
+	}
+
+	var none *processing.TitleRules
+	require.Equal(t, "🔥 Тур | Канал", none.Clean("hot_spb", "🔥 Тур | Канал"))
+}
+
+func TestParseTitleRulesRejectsInvalidRules(t *testing.T) {
+	_, err := processing.ParseTitleRules([]byte(`[{"suffixes": ["| Канал"]}]`))
+	require.ErrorContains(t, err, "source is required")
+
+	_, err = processing.ParseTitleRules([]byte(`[{"source": "*", "patterns": ["("]}]`))
+	require.ErrorContains(t, err, `pattern "("`)
+}
+
+func TestLoadTitleRules(t *testing.T) {
+	rules, err := processing.LoadTitleRules("")
+	require.NoError(t, err)
+	require.Nil(t, rules)
+
+	path := filepath.Join(t.TempDir(), "titles.json")
+	require.NoError(t, os.WriteFile(path, []byte(titleRulesJSON), 0o600))
+	rules, err = processing.LoadTitleRules(path)
+	require.NoError(t, err)
+	require.Equal(t, "Тур", rules.Clean("hot_spb", "Тур | Горящие туры СПб"))
+}
+
+func TestTitleStageDedupesAcrossBoilerplate(t *testing.T) {
+	rules, err := processing.ParseTitleRules([]byte(titleRulesJSON))
+	require.NoError(t, err)
+	pipeline, err := processing.BuildPipeline([]string{"clean", "title", "id", "cluster"}, processing.Options{TitleRules: rules})
+	require.NoError(t, err)
+
+	ts := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	var items []*processing.Item
+	for _, title := range []string{"Турция 7 ночей | Горящие туры СПб", "🔥 Турция 7 ночей 🔥", "Турция 7 ночей"} {
+		item := &processing.Item{Doc: models.NewsDocument{Title: title, Text: "Вылет из Пулково", Source: "hot_spb", Timestamp: ts}}
+		require.NoError(t, pipeline.Run(item))
+		items = append(items, item)
+	}
+	for _, item := range items[1:] {
+		require.Equal(t, "Турция 7 ночей", item.Doc.Title)
+		require.Equal(t, items[0].Doc.ID, item.Doc.ID)
+		require.Equal(t, items[0].Doc.ClusterID, item.Doc.ClusterID)
+	}
+
+	// A title that is only boilerplate is replaced by one from the text.
+	item := &processing.Item{Doc: models.NewsDocument{Title: "🔥🔥🔥", Text: "Вылет из Пулково. Подробности ниже", Source: "hot_spb"}}
+	require.NoError(t, processing.TitleStage{MaxWords: 10, Rules: rules}.Process(item))
+	require.Equal(t, "Вылет из Пулково", item.Doc.Title)
+}
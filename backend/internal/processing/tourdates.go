@@ -0,0 +1,163 @@
+package processing
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxTourSpan bounds a date range taken for a trip; longer ranges are
+	// sales periods or seasons ("с 1 мая по 30 сентября").
+	maxTourSpan = 60 * 24 * time.Hour
+	// maxTourNights bounds a trip length taken from "N ночей".
+	maxTourNights = 30
+)
+
+// Date building blocks: "15 июня [2024]" and "15.06[.2024]". Numeric dates
+// need a two-digit month so prices like "1.5-2 тыс" are not read as dates.
+const (
+	namedDate   = `(\d{1,2})\s+(\p{L}+)(?:\s+(\d{4}))?`
+	numericDate = `(\d{1,2})\.(\d{2})(?:\.(\d{4}|\d{2}))?`
+	departWords = `вылет\p{L}*|выезд\p{L}*|заезд\p{L}*|отправлени\p{L}*|отъезд\p{L}*|дата вылета`
+)
+
+var (
+	// "с 1 по 10 июня", "1-10 июня"
+	tourRangeSameMonthRe = regexp.MustCompile(`(?i)(?:^|[^\p{L}\d.])(?:с\s+(\d{1,2})\s+(?:по|до)\s+|(\d{1,2})\s*[-–—]\s*)` + namedDate)
+	// "с 28 мая по 5 июня", "28 мая - 5 июня"
+	tourRangeNamedRe = regexp.MustCompile(`(?i)(?:^|[^\p{L}\d.])(?:с\s+)?` + namedDate + `\s*(?:[-–—]|по|до)\s*` + namedDate)
+	// "01.06-10.06", "с 01.06.2024 по 10.06.2024"
+	tourRangeNumericRe = regexp.MustCompile(`(?i)(?:^|[^\d.])(?:с\s+)?` + numericDate + `\s*(?:[-–—]|по|до)\s*` + numericDate + `(?:[^\d.]|\.?$)`)
+	// "вылет 15 марта", "заезд: 15.03"
+	tourDepartNamedRe   = regexp.MustCompile(`(?i)(?:` + departWords + `)\s*[:—–-]?\s*(?:с\s+|в\s+)?` + namedDate)
+	tourDepartNumericRe = regexp.MustCompile(`(?i)(?:` + departWords + `)\s*[:—–-]?\s*(?:с\s+|в\s+)?` + numericDate + `(?:[^\d.]|\.?$)`)
+	// "вылет завтра", "тур на послезавтра", "завтра вылет"
+	tourRelativeRe = regexp.MustCompile(`(?i)(?:^|[^\p{L}])((?:` + departWords + `)\s+(?:уже\s+)?|на\s+)?(сегодня|завтра|послезавтра)(?:\s+(` + departWords + `))?(?:$|[^\p{L}])`)
+	tourNightsRe   = regexp.MustCompile(`(?i)(?:^|[^\d])(\d{1,2})\s*(?:ноч(?:ь|и|ей)|нч)(?:$|[^\p{L}])`)
+)
+
+var relativeDays = map[string]int{"сегодня": 0, "завтра": 1, "послезавтра": 2}
+
+// ExtractTourDates finds when the offered trip starts and ends: a range
+// such as "с 1 по 10 июня" or "01.06-10.06", or a departure date such as
+// "вылет 15 марта" or "вылет завтра", ended by a stated number of nights
+// when there is one. Dates are midnights in the location of posted; dates
+// without a year and relative ones are resolved against posted. Both
+// results are zero when the text names no trip date, and departure alone
+// is set when the trip length is unknown.
+func ExtractTourDates(text string, posted time.Time) (departure, ret time.Time) {
+	if posted.IsZero() {
+		return time.Time{}, time.Time{}
+	}
+	text = foldYo(html.UnescapeString(text))
+
+	if start, end, ok := tourRange(text, posted); ok {
+		return start, end
+	}
+
+	departure, ok := tourDeparture(text, posted)
+	if !ok {
+		return time.Time{}, time.Time{}
+	}
+	if m := tourNightsRe.FindStringSubmatch(text); m != nil {
+		if nights, _ := strconv.Atoi(m[1]); nights > 0 && nights <= maxTourNights {
+			ret = departure.AddDate(0, 0, nights)
+		}
+	}
+	return departure, ret
+}
+
+func tourRange(text string, posted time.Time) (start, end time.Time, ok bool) {
+	for _, m := range tourRangeNamedRe.FindAllStringSubmatch(text, -1) {
+		startMonth, ok1 := russianMonths[strings.ToLower(m[2])]
+		endMonth, ok2 := russianMonths[strings.ToLower(m[5])]
+		if !ok1 || !ok2 {
+			continue
+		}
+		if start, end, ok := tourSpan(m[1], startMonth, m[3], m[4], endMonth, m[6], posted); ok {
+			return start, end, true
+		}
+	}
+	for _, m := range tourRangeSameMonthRe.FindAllStringSubmatch(text, -1) {
+		month, ok := russianMonths[strings.ToLower(m[4])]
+		if !ok {
+			continue
+		}
+		startDay := m[1]
+		if startDay == "" {
+			startDay = m[2]
+		}
+		if start, end, ok := tourSpan(startDay, month, m[5], m[3], month, m[5], posted); ok {
+			return start, end, true
+		}
+	}
+	for _, m := range tourRangeNumericRe.FindAllStringSubmatch(text, -1) {
+		startMonth, _ := strconv.Atoi(m[2])
+		endMonth, _ := strconv.Atoi(m[5])
+		if startMonth < 1 || startMonth > 12 || endMonth < 1 || endMonth > 12 {
+			continue
+		}
+		if start, end, ok := tourSpan(m[1], time.Month(startMonth), m[3], m[4], time.Month(endMonth), m[6], posted); ok {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// tourSpan resolves both ends of a range. A range crossing New Year
+// ("с 28 декабря по 5 января") ends in the following year.
+func tourSpan(startDay string, startMonth time.Month, startYear, endDay string, endMonth time.Month, endYear string, posted time.Time) (start, end time.Time, ok bool) {
+	if startYear == "" {
+		startYear = endYear
+	}
+	sd, _ := strconv.Atoi(startDay)
+	ed, _ := strconv.Atoi(endDay)
+	start, ok1 := resolveDate(sd, startMonth, startYear, posted)
+	end, ok2 := resolveDate(ed, endMonth, endYear, posted)
+	if !ok1 || !ok2 {
+		return time.Time{}, time.Time{}, false
+	}
+	if end.Before(start) && endYear == "" {
+		end = end.AddDate(1, 0, 0)
+	}
+	if end.Before(start) || end.Sub(start) > maxTourSpan {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end, true
+}
+
+func tourDeparture(text string, posted time.Time) (time.Time, bool) {
+	for _, m := range tourDepartNamedRe.FindAllStringSubmatch(text, -1) {
+		month, ok := russianMonths[strings.ToLower(m[2])]
+		if !ok {
+			continue
+		}
+		day, _ := strconv.Atoi(m[1])
+		if date, ok := resolveDate(day, month, m[3], posted); ok {
+			return date, true
+		}
+	}
+	for _, m := range tourDepartNumericRe.FindAllStringSubmatch(text, -1) {
+		day, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		if month < 1 || month > 12 {
+			continue
+		}
+		if date, ok := resolveDate(day, time.Month(month), m[3], posted); ok {
+			return date, true
+		}
+	}
+	for _, m := range tourRelativeRe.FindAllStringSubmatch(text, -1) {
+		// A bare "завтра" is too common to mean the trip: it needs a
+		// departure word or "на" next to it.
+		if m[1] == "" && m[3] == "" {
+			continue
+		}
+		offset := relativeDays[strings.ToLower(m[2])]
+		return time.Date(posted.Year(), posted.Month(), posted.Day()+offset, 0, 0, 0, 0, posted.Location()), true
+	}
+	return time.Time{}, false
+}
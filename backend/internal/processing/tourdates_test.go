@@ -0,0 +1,45 @@
+package processing_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractTourDates(t *testing.T) {
+	posted := time.Date(2024, 6, 1, 9, 30, 0, 0, time.UTC)
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	cases := []struct {
+		text      string
+		departure time.Time
+		ret       time.Time
+	}{
+		{"Турция, вылет 15 марта, 7 ночей", day(2025, 3, 15), day(2025, 3, 22)},
+		{"Египет с 1 по 10 июня, всё включено", day(2024, 6, 1), day(2024, 6, 10)},
+		{"Кипр 12-19 июня от 45 000 руб", day(2024, 6, 12), day(2024, 6, 19)},
+		{"Сочи с 28 июня по 5 июля", day(2024, 6, 28), day(2024, 7, 5)},
+		{"Даты: 01.07-10.07", day(2024, 7, 1), day(2024, 7, 10)},
+		{"ВЫЛЕТ ЗАВТРА! Анталья, 5 нч", day(2024, 6, 2), day(2024, 6, 7)},
+		{"Горящий тур на послезавтра", day(2024, 6, 3), time.Time{}},
+		{"Сегодня вылет в Дубай", day(2024, 6, 1), time.Time{}},
+		{"Заезд: 20.06.2024", day(2024, 6, 20), time.Time{}},
+		{"Вылёт 15 июня", day(2024, 6, 15), time.Time{}},
+		{"Завтра расскажем о новых турах", time.Time{}, time.Time{}},
+		{"Раннее бронирование с 1 мая по 30 сентября", time.Time{}, time.Time{}},
+		{"Отели 4-5 звёзд, цены от 1.5 тыс", time.Time{}, time.Time{}},
+		{"Вылет 31 февраля", time.Time{}, time.Time{}},
+	}
+	for _, tc := range cases {
+		departure, ret := processing.ExtractTourDates(tc.text, posted)
+		require.Equal(t, tc.departure, departure, tc.text)
+		require.Equal(t, tc.ret, ret, tc.text)
+	}
+
+	december := time.Date(2024, 12, 20, 12, 0, 0, 0, time.UTC)
+	departure, ret := processing.ExtractTourDates("Новый год в Таиланде: с 28 декабря по 8 января", december)
+	require.Equal(t, day(2024, 12, 28), departure)
+	require.Equal(t, day(2025, 1, 8), ret)
+}
@@ -0,0 +1,143 @@
+// Package querylang parses the search syntax of the q parameter:
+//
+//	турция "всё включено" +перелёт -автобус source:telegram destination:"шри-ланка"
+//
+// Plain words are optional, but at least one of them must match. Quoted
+// phrases and words prefixed with + are required, words and phrases
+// prefixed with - are excluded. field:value restricts an exact field, or
+// excludes it when prefixed with -. Anything that does not parse as a
+// known field is searched as text, so links and times such as "10:30"
+// keep working.
+package querylang
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Fields are the field names accepted in field:value terms.
+var Fields = []string{"source", "destination", "keyword", "mention"}
+
+// Op says how a term constrains the result.
+type Op int
+
+const (
+	// Optional words score documents; at least one of them must match.
+	Optional Op = iota
+	// Required terms must match.
+	Required
+	// Excluded terms must not match.
+	Excluded
+)
+
+// Term is one word, phrase or field:value of a query.
+type Term struct {
+	Op Op
+	// Field is one of Fields, or empty for full-text terms.
+	Field string
+	Value string
+	// Phrase is set for quoted values; full-text phrases match their words
+	// in order.
+	Phrase bool
+}
+
+// Query is a parsed q, its terms in input order.
+type Query struct {
+	Terms []Term
+}
+
+// ErrUnterminatedQuote reports a phrase without its closing quote.
+var ErrUnterminatedQuote = errors.New(`unterminated quote, close the phrase with "`)
+
+// Parse reads a query. A lone + or - and empty phrases are ignored.
+func Parse(input string) (Query, error) {
+	var q Query
+	s := input
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return q, nil
+		}
+
+		var term Term
+		switch s[0] {
+		case '+':
+			term.Op, s = Required, s[1:]
+		case '-':
+			term.Op, s = Excluded, s[1:]
+		}
+
+		if field, rest, ok := cutField(s); ok {
+			term.Field, s = field, rest
+		}
+
+		var err error
+		if strings.HasPrefix(s, `"`) {
+			term.Phrase = true
+			term.Value, s, err = cutPhrase(s[1:])
+			if err != nil {
+				return Query{}, err
+			}
+			term.Value = strings.Join(strings.Fields(term.Value), " ")
+		} else {
+			end := strings.IndexAny(s, " \t\r\n")
+			if end < 0 {
+				end = len(s)
+			}
+			term.Value, s = s[:end], s[end:]
+		}
+
+		if term.Value != "" {
+			q.Terms = append(q.Terms, term)
+		}
+	}
+}
+
+// cutField splits a known field name and its colon off s when a value
+// follows them.
+func cutField(s string) (field, rest string, ok bool) {
+	name, rest, found := strings.Cut(s, ":")
+	if !found || rest == "" || strings.ContainsAny(rest[:1], " \t\r\n") {
+		return "", s, false
+	}
+	name = strings.ToLower(name)
+	if !slices.Contains(Fields, name) {
+		return "", s, false
+	}
+	return name, rest, true
+}
+
+func cutPhrase(s string) (phrase, rest string, err error) {
+	phrase, rest, found := strings.Cut(s, `"`)
+	if !found {
+		return "", "", ErrUnterminatedQuote
+	}
+	return phrase, rest, nil
+}
+
+// String renders q in the syntax Parse reads.
+func (q Query) String() string {
+	parts := make([]string, 0, len(q.Terms))
+	for _, term := range q.Terms {
+		var b strings.Builder
+		switch term.Op {
+		case Required:
+			b.WriteByte('+')
+		case Excluded:
+			b.WriteByte('-')
+		}
+		if term.Field != "" {
+			b.WriteString(term.Field + ":")
+		}
+		// Field values are quoted whenever they need it, which does not
+		// change their meaning the way quoting words does.
+		if term.Phrase || term.Field != "" && strings.ContainsAny(term.Value, " \t\r\n") {
+			b.WriteString(`"` + term.Value + `"`)
+		} else {
+			b.WriteString(term.Value)
+		}
+		parts = append(parts, b.String())
+	}
+	return strings.Join(parts, " ")
+}
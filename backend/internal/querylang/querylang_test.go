@@ -0,0 +1,62 @@
+package querylang_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/querylang"
+)
+
+func TestParse(t *testing.T) {
+	q, err := querylang.Parse(`турция "всё  включено" +перелёт -автобус Source:telegram -destination:"шарм эль шейх" -"без визы"`)
+	require.NoError(t, err)
+	require.Equal(t, []querylang.Term{
+		{Value: "турция"},
+		{Value: "всё включено", Phrase: true},
+		{Op: querylang.Required, Value: "перелёт"},
+		{Op: querylang.Excluded, Value: "автобус"},
+		{Field: "source", Value: "telegram"},
+		{Op: querylang.Excluded, Field: "destination", Value: "шарм эль шейх", Phrase: true},
+		{Op: querylang.Excluded, Value: "без визы", Phrase: true},
+	}, q.Terms)
+}
+
+func TestParseKeepsUnknownFieldsAsText(t *testing.T) {
+	q, err := querylang.Parse(`https://t.me/hot/5 вылет 10:30 price:50000 source: - + ""`)
+	require.NoError(t, err)
+	require.Equal(t, []querylang.Term{
+		{Value: "https://t.me/hot/5"},
+		{Value: "вылет"},
+		{Value: "10:30"},
+		{Value: "price:50000"},
+		{Value: "source:"},
+	}, q.Terms)
+}
+
+func TestParseRejectsUnterminatedQuote(t *testing.T) {
+	_, err := querylang.Parse(`турция "всё включено`)
+	require.ErrorIs(t, err, querylang.ErrUnterminatedQuote)
+}
+
+func TestStringRoundTrips(t *testing.T) {
+	for _, input := range []string{
+		`турция "всё включено" +перелёт -автобус`,
+		`source:telegram -destination:"шарм эль шейх" +keyword:пляж`,
+		`--минус`,
+		``,
+	} {
+		q, err := querylang.Parse(input)
+		require.NoError(t, err)
+		require.Equal(t, input, q.String())
+
+		again, err := querylang.Parse(q.String())
+		require.NoError(t, err)
+		require.Equal(t, q, again)
+	}
+}
+
+func TestStringQuotesFieldValues(t *testing.T) {
+	q := querylang.Query{Terms: []querylang.Term{{Field: "destination", Value: "шарм эль шейх"}}}
+	require.Equal(t, `destination:"шарм эль шейх"`, q.String())
+}
@@ -0,0 +1,150 @@
+// Package reltime formats document ages for display ("2 ч назад") and adds
+// them to API responses, so thin clients such as bots and server-rendered
+// pages need no date logic of their own.
+package reltime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Locales lists the supported locale codes.
+var Locales = []string{"ru", "en"}
+
+// Supported reports whether locale is one of Locales.
+func Supported(locale string) bool {
+	return slices.Contains(Locales, locale)
+}
+
+const (
+	day   = 24 * time.Hour
+	month = 30 * day
+	year  = 365 * day
+)
+
+// Ago describes an age in locale: "только что", "5 мин назад", "2 ч
+// назад", "3 дня назад", "2 мес. назад", "1 год назад", or their English
+// counterparts. Negative ages, from clocks running ahead, read as just now.
+// Unsupported locales fall back to ru.
+func Ago(age time.Duration, locale string) string {
+	en := locale == "en"
+	switch {
+	case age < time.Minute:
+		if en {
+			return "just now"
+		}
+		return "только что"
+	case age < time.Hour:
+		n := int(age / time.Minute)
+		if en {
+			return fmt.Sprintf("%d min ago", n)
+		}
+		return fmt.Sprintf("%d мин назад", n)
+	case age < day:
+		n := int(age / time.Hour)
+		if en {
+			return fmt.Sprintf("%d h ago", n)
+		}
+		return fmt.Sprintf("%d ч назад", n)
+	case age < month:
+		n := int(age / day)
+		if en {
+			return fmt.Sprintf("%d %s ago", n, englishPlural(n, "day", "days"))
+		}
+		return fmt.Sprintf("%d %s назад", n, russianPlural(n, "день", "дня", "дней"))
+	case age < year:
+		n := int(age / month)
+		if en {
+			return fmt.Sprintf("%d mo ago", n)
+		}
+		return fmt.Sprintf("%d мес. назад", n)
+	default:
+		n := int(age / year)
+		if en {
+			return fmt.Sprintf("%d %s ago", n, englishPlural(n, "year", "years"))
+		}
+		return fmt.Sprintf("%d %s назад", n, russianPlural(n, "год", "года", "лет"))
+	}
+}
+
+// russianPlural picks the form for n: one (1, 21), few (2–4, 22–24) or
+// many (5–20, 25–30 and the teens).
+func russianPlural(n int, one, few, many string) string {
+	if n%100 >= 11 && n%100 <= 14 {
+		return many
+	}
+	switch n % 10 {
+	case 1:
+		return one
+	case 2, 3, 4:
+		return few
+	default:
+		return many
+	}
+}
+
+func englishPlural(n int, one, other string) string {
+	if n == 1 {
+		return one
+	}
+	return other
+}
+
+// AddAges sets age_seconds and human_age on every news document in the
+// encoded value data, at any depth, measured from its timestamp to now.
+// Objects count as news documents as they do for fieldmask: by their id,
+// text and urls keys. Documents without a readable timestamp are left
+// alone.
+func AddAges(data []byte, now time.Time, locale string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	walk(value, now, locale)
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, fmt.Errorf("encode response with ages: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func walk(value any, now time.Time, locale string) {
+	switch v := value.(type) {
+	case []any:
+		for _, item := range v {
+			walk(item, now, locale)
+		}
+	case map[string]any:
+		if isDocument(v) {
+			addAge(v, now, locale)
+			return
+		}
+		for _, item := range v {
+			walk(item, now, locale)
+		}
+	}
+}
+
+func isDocument(obj map[string]any) bool {
+	_, hasID := obj["id"].(string)
+	_, hasText := obj["text"]
+	_, hasURLs := obj["urls"]
+	return hasID && hasText && hasURLs
+}
+
+func addAge(doc map[string]any, now time.Time, locale string) {
+	raw, _ := doc["timestamp"].(string)
+	ts, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil || ts.IsZero() {
+		return
+	}
+	age := max(now.Sub(ts), 0)
+	doc["age_seconds"] = int64(age / time.Second)
+	doc["human_age"] = Ago(age, locale)
+}
@@ -0,0 +1,48 @@
+package reltime_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/reltime"
+)
+
+func TestAgo(t *testing.T) {
+	cases := []struct {
+		age    time.Duration
+		ru, en string
+	}{
+		{-time.Minute, "только что", "just now"},
+		{30 * time.Second, "только что", "just now"},
+		{5 * time.Minute, "5 мин назад", "5 min ago"},
+		{2*time.Hour + 59*time.Minute, "2 ч назад", "2 h ago"},
+		{24 * time.Hour, "1 день назад", "1 day ago"},
+		{3 * 24 * time.Hour, "3 дня назад", "3 days ago"},
+		{11 * 24 * time.Hour, "11 дней назад", "11 days ago"},
+		{21 * 24 * time.Hour, "21 день назад", "21 days ago"},
+		{65 * 24 * time.Hour, "2 мес. назад", "2 mo ago"},
+		{2 * 365 * 24 * time.Hour, "2 года назад", "2 years ago"},
+		{5 * 365 * 24 * time.Hour, "5 лет назад", "5 years ago"},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.ru, reltime.Ago(tc.age, "ru"), tc.age)
+		require.Equal(t, tc.en, reltime.Ago(tc.age, "en"), tc.age)
+	}
+}
+
+func TestAddAges(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	data := []byte(`{"Total":2,"Items":[` +
+		`{"id":"a","text":"","urls":null,"timestamp":"2024-06-01T10:00:00+03:00"},` +
+		`{"id":"b","text":"","urls":null,"timestamp":"0001-01-01T00:00:00Z"}],` +
+		`"PIT":"x","price":12345678901234567}`)
+
+	out, err := reltime.AddAges(data, now, "ru")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Total":2,"Items":[`+
+		`{"id":"a","text":"","urls":null,"timestamp":"2024-06-01T10:00:00+03:00","age_seconds":18000,"human_age":"5 ч назад"},`+
+		`{"id":"b","text":"","urls":null,"timestamp":"0001-01-01T00:00:00Z"}],`+
+		`"PIT":"x","price":12345678901234567}`, string(out))
+}
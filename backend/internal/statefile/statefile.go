@@ -0,0 +1,31 @@
+// Package statefile persists the small JSON state files services keep
+// between restarts, such as scraper offsets and bot subscriptions.
+package statefile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Save writes v as JSON to path atomically: it is written to a temporary
+// file next to path and renamed over it, so a crash leaves either the old
+// state or the new one, never a truncated file.
+func Save(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create state dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replace state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package statefile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "scraper.json")
+
+	require.NoError(t, Save(path, map[string]int{"offset": 1}))
+	require.NoError(t, Save(path, map[string]int{"offset": 2}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"offset":2}`, string(data))
+	_, err = os.Stat(path + ".tmp")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	require.ErrorContains(t, Save(path, func() {}), "encode state")
+}
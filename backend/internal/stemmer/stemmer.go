@@ -0,0 +1,187 @@
+// Package stemmer reduces Russian words to their stems with the Snowball
+// Russian algorithm, so inflected forms such as "туры", "тура" and "туров"
+// count as one keyword.
+package stemmer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Endings of the Snowball Russian stemmer. Those in the first groups
+// are only removed after а or я, which stays.
+var (
+	perfectiveGerund1 = []string{"в", "вши", "вшись"}
+	perfectiveGerund2 = []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}
+	adjective         = []string{
+		"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	}
+	participle1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	participle2 = []string{"ивш", "ывш", "ующ"}
+	reflexive   = []string{"ся", "сь"}
+	verb1       = []string{
+		"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно",
+	}
+	verb2 = []string{
+		"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+		"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю",
+	}
+	noun = []string{
+		"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й",
+		"иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я",
+	}
+	derivational = []string{"ост", "ость"}
+	superlative  = []string{"ейш", "ейше"}
+)
+
+// Russian returns the stem of a lower-case word. Words without Cyrillic
+// letters are returned unchanged; ё is read as е.
+func Russian(word string) string {
+	if !hasCyrillic(word) {
+		return word
+	}
+	w := []rune(strings.ReplaceAll(word, "ё", "е"))
+	rv, r2 := regions(w)
+	// Every step works on the part of the word from rv on.
+	stem, tail := w[:rv], w[rv:]
+
+	if t, ok := removeGrouped(tail, perfectiveGerund1, perfectiveGerund2); ok {
+		tail = t
+	} else {
+		if t, ok := remove(tail, reflexive); ok {
+			tail = t
+		}
+		if t, ok := removeAdjectival(tail); ok {
+			tail = t
+		} else if t, ok := removeGrouped(tail, verb1, verb2); ok {
+			tail = t
+		} else if t, ok := remove(tail, noun); ok {
+			tail = t
+		}
+	}
+
+	if t, ok := remove(tail, []string{"и"}); ok {
+		tail = t
+	}
+
+	if n := longestSuffix(tail, derivational); n > 0 && len(stem)+len(tail)-n >= r2 {
+		tail = tail[:len(tail)-n]
+	}
+
+	switch {
+	case longestSuffix(tail, superlative) > 0:
+		tail, _ = remove(tail, superlative)
+		tail = undoubleN(tail)
+	case hasSuffix(tail, "нн"):
+		tail = undoubleN(tail)
+	case hasSuffix(tail, "ь"):
+		tail = tail[:len(tail)-1]
+	}
+	return string(stem) + string(tail)
+}
+
+// regions returns the start of RV, the part after the first vowel, and of
+// R2: past a non-vowel following a vowel, twice.
+func regions(w []rune) (rv, r2 int) {
+	rv, r2 = len(w), len(w)
+	i := pastFirst(w, 0, isVowel)
+	if i < 0 {
+		return rv, r2
+	}
+	rv = i
+	for _, vowel := range []bool{false, true, false} {
+		if i = pastFirst(w, i, func(r rune) bool { return isVowel(r) == vowel }); i < 0 {
+			return rv, r2
+		}
+	}
+	return rv, i
+}
+
+// pastFirst returns the index after the first rune from from on that
+// satisfies match, or -1.
+func pastFirst(w []rune, from int, match func(rune) bool) int {
+	for i := from; i < len(w); i++ {
+		if match(w[i]) {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func isVowel(r rune) bool {
+	return strings.ContainsRune("аеиоуыэюя", r)
+}
+
+func hasCyrillic(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// longestSuffix returns the length in runes of the longest of endings that
+// tail ends with, or 0.
+func longestSuffix(tail []rune, endings []string) int {
+	best := 0
+	s := string(tail)
+	for _, ending := range endings {
+		if n := len([]rune(ending)); n > best && strings.HasSuffix(s, ending) {
+			best = n
+		}
+	}
+	return best
+}
+
+func hasSuffix(tail []rune, ending string) bool {
+	return strings.HasSuffix(string(tail), ending)
+}
+
+func remove(tail []rune, endings []string) ([]rune, bool) {
+	if n := longestSuffix(tail, endings); n > 0 {
+		return tail[:len(tail)-n], true
+	}
+	return tail, false
+}
+
+// removeGrouped removes the longest ending of either group, those of
+// group1 only when preceded by а or я. As in Snowball, a longest match
+// that fails this condition is not retried with shorter endings.
+func removeGrouped(tail []rune, group1, group2 []string) ([]rune, bool) {
+	n1, n2 := longestSuffix(tail, group1), longestSuffix(tail, group2)
+	switch {
+	case n2 > 0 && n2 >= n1:
+		return tail[:len(tail)-n2], true
+	case n1 > 0:
+		rest := tail[:len(tail)-n1]
+		if len(rest) == 0 {
+			return tail, false
+		}
+		if last := rest[len(rest)-1]; last == 'а' || last == 'я' {
+			return rest, true
+		}
+	}
+	return tail, false
+}
+
+// removeAdjectival removes an adjective ending and the participle ending
+// before it, if any.
+func removeAdjectival(tail []rune) ([]rune, bool) {
+	rest, ok := remove(tail, adjective)
+	if !ok {
+		return tail, false
+	}
+	if t, ok := removeGrouped(rest, participle1, participle2); ok {
+		rest = t
+	}
+	return rest, true
+}
+
+func undoubleN(tail []rune) []rune {
+	if hasSuffix(tail, "нн") {
+		return tail[:len(tail)-1]
+	}
+	return tail
+}
@@ -0,0 +1,28 @@
+package stemmer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRussian(t *testing.T) {
+	for word, want := range map[string]string{
+		"туры":        "тур",
+		"тура":        "тур",
+		"туров":       "тур",
+		"турах":       "тур",
+		"отель":       "отел",
+		"отелей":      "отел",
+		"красивая":    "красив",
+		"горящие":     "горя",
+		"горящих":     "горя",
+		"путешествия": "путешеств",
+		"ёлка":        "елк",
+		"бедность":    "бедност",
+		"paris":       "paris",
+		"2024":        "2024",
+	} {
+		require.Equal(t, want, Russian(word), word)
+	}
+}
@@ -0,0 +1,149 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const defaultBaseURL = "https://api.telegram.org"
+
+// Client is a minimal Telegram Bot API client covering long polling,
+// channel posts and messaging.
+type Client struct {
+	token   string
+	baseURL string
+	http    *http.Client
+}
+
+// Update is an incoming event from getUpdates.
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message"`
+	// ChannelPost and EditedChannelPost are posts of channels the bot is an
+	// administrator of.
+	ChannelPost       *Message `json:"channel_post"`
+	EditedChannelPost *Message `json:"edited_channel_post"`
+}
+
+// Message is a chat message as delivered by the Bot API.
+type Message struct {
+	MessageID int64  `json:"message_id"`
+	Chat      Chat   `json:"chat"`
+	From      *User  `json:"from"`
+	Date      int64  `json:"date"`
+	Text      string `json:"text"`
+	// Caption is the text of photo, video and document messages.
+	Caption        string   `json:"caption"`
+	ReplyToMessage *Message `json:"reply_to_message"`
+}
+
+// Chat identifies the conversation a message belongs to. Username is set
+// for public chats and channels.
+type Chat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Username string `json:"username"`
+	Title    string `json:"title"`
+}
+
+// User is the sender of a message.
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+}
+
+// New creates a client for the bot identified by token.
+func New(token string) *Client {
+	return &Client{
+		token:   token,
+		baseURL: defaultBaseURL,
+		http:    &http.Client{Timeout: 90 * time.Second},
+	}
+}
+
+// WithBaseURL points the client at a different Bot API server, e.g. a local
+// telegram-bot-api instance or a test server.
+func (c *Client) WithBaseURL(baseURL string) *Client {
+	c.baseURL = baseURL
+	return c
+}
+
+// GetUpdates long-polls for updates with IDs greater than or equal to offset.
+func (c *Client) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]Update, error) {
+	return c.getUpdates(ctx, offset, timeout, `["message"]`)
+}
+
+// GetChannelPosts is GetUpdates for new and edited channel posts.
+func (c *Client) GetChannelPosts(ctx context.Context, offset int64, timeout time.Duration) ([]Update, error) {
+	return c.getUpdates(ctx, offset, timeout, `["channel_post","edited_channel_post"]`)
+}
+
+func (c *Client) getUpdates(ctx context.Context, offset int64, timeout time.Duration, allowed string) ([]Update, error) {
+	params := url.Values{}
+	params.Set("offset", strconv.FormatInt(offset, 10))
+	params.Set("timeout", strconv.Itoa(int(timeout.Seconds())))
+	params.Set("allowed_updates", allowed)
+
+	var updates []Update
+	if err := c.call(ctx, "getUpdates", params, &updates); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// SendMessage posts an HTML-formatted message to a chat.
+func (c *Client) SendMessage(ctx context.Context, chatID int64, text string) error {
+	params := url.Values{}
+	params.Set("chat_id", strconv.FormatInt(chatID, 10))
+	params.Set("text", text)
+	params.Set("parse_mode", "HTML")
+	params.Set("disable_web_page_preview", "true")
+	return c.call(ctx, "sendMessage", params, nil)
+}
+
+func (c *Client) call(ctx context.Context, method string, params url.Values, out any) error {
+	endpoint := fmt.Sprintf("%s/bot%s/%s", c.baseURL, c.token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBufferString(params.Encode()))
+	if err != nil {
+		return fmt.Errorf("build %s request: %w", method, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		// Strip the URL from the error, it contains the bot token.
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer res.Body.Close()
+
+	var parsed apiResponse
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return fmt.Errorf("decode %s response: %w", method, err)
+	}
+	if !parsed.OK {
+		return fmt.Errorf("%s failed: %d %s", method, parsed.ErrorCode, parsed.Description)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(parsed.Result, out); err != nil {
+			return fmt.Errorf("decode %s result: %w", method, err)
+		}
+	}
+	return nil
+}
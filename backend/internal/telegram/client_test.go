@@ -0,0 +1,79 @@
+package telegram_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/telegram"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUpdatesAndSendMessage(t *testing.T) {
+	var sent url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		switch r.URL.Path {
+		case "/bottoken/getUpdates":
+			require.Equal(t, "7", values.Get("offset"))
+			_, _ = io.WriteString(w, `{"ok":true,"result":[{"update_id":7,"message":{"message_id":1,"chat":{"id":42},"text":"/search турция"}}]}`)
+		case "/bottoken/sendMessage":
+			sent = values
+			_, _ = io.WriteString(w, `{"ok":true,"result":{}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	client := telegram.New("token").WithBaseURL(srv.URL)
+
+	updates, err := client.GetUpdates(context.Background(), 7, time.Second)
+	require.NoError(t, err)
+	require.Len(t, updates, 1)
+	require.Equal(t, int64(42), updates[0].Message.Chat.ID)
+	require.Equal(t, "/search турция", updates[0].Message.Text)
+
+	require.NoError(t, client.SendMessage(context.Background(), 42, "<b>hi</b>"))
+	require.Equal(t, "42", sent.Get("chat_id"))
+	require.Equal(t, "HTML", sent.Get("parse_mode"))
+}
+
+func TestGetChannelPosts(t *testing.T) {
+	var allowed string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		allowed = values.Get("allowed_updates")
+		_, _ = io.WriteString(w, `{"ok":true,"result":[{"update_id":3,"channel_post":{"message_id":12,"date":1717232400,
+			"chat":{"id":-1001,"type":"channel","username":"hottours"},"caption":"Турция 45 000 ₽",
+			"reply_to_message":{"message_id":10,"chat":{"id":-1001}}}}]}`)
+	}))
+	defer srv.Close()
+
+	updates, err := telegram.New("token").WithBaseURL(srv.URL).GetChannelPosts(context.Background(), 3, time.Second)
+	require.NoError(t, err)
+	require.Equal(t, `["channel_post","edited_channel_post"]`, allowed)
+	require.Len(t, updates, 1)
+	post := updates[0].ChannelPost
+	require.NotNil(t, post)
+	require.Equal(t, "hottours", post.Chat.Username)
+	require.Equal(t, "Турция 45 000 ₽", post.Caption)
+	require.Equal(t, int64(10), post.ReplyToMessage.MessageID)
+}
+
+func TestCallReportsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`)
+	}))
+	defer srv.Close()
+
+	err := telegram.New("token").WithBaseURL(srv.URL).SendMessage(context.Background(), 1, "x")
+	require.EqualError(t, err, "sendMessage failed: 403 Forbidden: bot was blocked by the user")
+}
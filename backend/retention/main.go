@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"path"
 	"syscall"
 	"time"
 
+	"github.com/DeafMist/hot-tour-radar/backend/internal/archive"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/awsauth"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/esquery"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/logger"
 )
 
@@ -29,7 +35,9 @@ func main() {
 	defer stop()
 
 	for i := 0; i < maxRetries; i++ {
-		esClient, err = elasticsearch.New(cfg.ElasticsearchAddr, cfg.ElasticsearchIndex, log)
+		esClient, err = elasticsearch.New(cfg.ElasticsearchAddr, cfg.ElasticsearchIndex, log,
+			elasticsearch.SignAWS(cfg.ElasticsearchAWSRegion, cfg.ElasticsearchAWSService),
+			elasticsearch.LogSlowQueries(cfg.ElasticsearchSlowThreshold, log))
 		if err != nil {
 			log.Warn("failed to create elasticsearch client, retrying",
 				slog.Any("err", err),
@@ -76,16 +84,30 @@ func main() {
 
 	log.Info("connected to elasticsearch")
 
+	var archiver *archive.Archive
+	if cfg.ArchiveBucket != "" {
+		store, err := archive.NewS3(cfg.ArchiveEndpoint, cfg.ArchiveRegion, cfg.ArchiveBucket,
+			awsauth.NewSource(cfg.ArchiveAccessKeyID, cfg.ArchiveSecretAccessKey))
+		if err != nil {
+			log.Error("init archive store", slog.Any("err", err))
+			os.Exit(1)
+		}
+		archiver = archive.New(store, path.Join(cfg.ArchivePrefix, cfg.ElasticsearchIndex)+"/", cfg.ArchiveObjectDocs)
+		log.Info("archiving documents before deletion", slog.String("bucket", cfg.ArchiveBucket))
+	}
+
 	ticker := time.NewTicker(cfg.Interval)
 	defer ticker.Stop()
 
 	log.Info("retention job running",
 		slog.Duration("interval", cfg.Interval),
 		slog.Duration("max_age", cfg.MaxAge),
+		slog.String("field", cfg.Field),
+		slog.Bool("daily_indices", cfg.ElasticsearchDailyIndices),
 	)
 
 	// Run immediately on start, but don't fail if ES is temporarily unavailable
-	runOnce(ctx, log, esClient, cfg)
+	runOnce(ctx, log, esClient, archiver, cfg)
 
 	for {
 		select {
@@ -93,24 +115,122 @@ func main() {
 			log.Info("shutdown signal received")
 			return
 		case <-ticker.C:
-			runOnce(ctx, log, esClient, cfg)
+			runOnce(ctx, log, esClient, archiver, cfg)
 		}
 	}
 }
 
-func runOnce(ctx context.Context, log *slog.Logger, esClient *elasticsearch.Client, cfg *config.Retention) {
-	subCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+func runOnce(ctx context.Context, log *slog.Logger, esClient *elasticsearch.Client, archiver *archive.Archive, cfg *config.Retention) {
+	subCtx, cancel := context.WithTimeout(ctx, cfg.RunTimeout)
 	defer cancel()
 
-	deleted, err := esClient.DeleteOlderThan(subCtx, cfg.MaxAge, cfg.BatchSize)
+	// Deletions that are cut short by the timeout keep their progress, so
+	// the next run only has to remove what is left.
+	progress := elasticsearch.ReportProgress(func(p elasticsearch.DeleteProgress) {
+		log.Debug("retention progress",
+			slog.String("task", p.Task),
+			slog.Int64("deleted", p.Deleted),
+			slog.Int64("total", p.Total),
+		)
+	})
+
+	now := time.Now()
+	var deleted, dropped int64
+	if cfg.ElasticsearchDailyIndices {
+		var err error
+		dropped, err = dropAgedIndices(subCtx, log, esClient, archiver, now.Add(-cfg.MaxAge), cfg.BatchSize)
+		if err != nil {
+			log.Warn("retention run failed (will retry on next interval)", slog.Any("err", err))
+			return
+		}
+	} else {
+		// Each query is built once, so the archive and the deletion cover the
+		// same documents.
+		aged := elasticsearch.OlderThan(cfg.Field, now.Add(-cfg.MaxAge))
+		if err := archiveMatching(subCtx, log, esClient, archiver, cfg.ElasticsearchIndex, "aged", aged, cfg.BatchSize); err != nil {
+			log.Warn("archive failed, keeping documents (will retry on next interval)", slog.Any("err", err))
+			return
+		}
+		var err error
+		deleted, err = esClient.DeleteByQuery(subCtx, aged, cfg.BatchSize, progress)
+		if err != nil {
+			log.Warn("retention run failed (will retry on next interval)", slog.Any("err", err))
+			return
+		}
+	}
+
+	expiredBefore := elasticsearch.ExpiredBefore(now.Add(-cfg.ExpiredGrace))
+	if err := archiveMatching(subCtx, log, esClient, archiver, cfg.ElasticsearchIndex, "expired", expiredBefore, cfg.BatchSize); err != nil {
+		log.Warn("archive failed, keeping expired offers (will retry on next interval)", slog.Any("err", err))
+		return
+	}
+	expired, err := esClient.DeleteByQuery(subCtx, expiredBefore, cfg.BatchSize, progress)
 	if err != nil {
-		log.Warn("retention run failed (will retry on next interval)", slog.Any("err", err))
+		log.Warn("expired offer cleanup failed (will retry on next interval)", slog.Any("err", err))
 		return
 	}
 
-	if deleted > 0 {
-		log.Info("retention run completed", slog.Int64("deleted", deleted))
+	if deleted > 0 || dropped > 0 || expired > 0 {
+		log.Info("retention run completed", slog.Int64("deleted", deleted), slog.Int64("dropped_indices", dropped), slog.Int64("expired", expired))
 	} else {
 		log.Debug("retention run completed, no old documents found")
 	}
 }
+
+// dropAgedIndices drops the daily indices whose whole day lies before
+// cutoff, archiving each one first, and returns how many it dropped.
+// Documents thus outlive RETENTION_MAX_AGE by up to a day, in exchange
+// for no delete-by-query merges. Indices are named by the indexed_at day,
+// so config.LoadRetention rejects any other RETENTION_FIELD in this mode.
+func dropAgedIndices(ctx context.Context, log *slog.Logger, esClient *elasticsearch.Client, archiver *archive.Archive, cutoff time.Time, batchSize int) (int64, error) {
+	indices, err := esClient.ListDailyIndices(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var dropped int64
+	for _, index := range indices {
+		if index.Day.Add(24 * time.Hour).After(cutoff) {
+			// Oldest first, so every later index is too recent as well.
+			break
+		}
+		if err := archiveMatching(ctx, log, esClient, archiver, index.Name, index.Name, esquery.MatchAll{}, batchSize); err != nil {
+			return dropped, fmt.Errorf("archive %s, keeping it: %w", index.Name, err)
+		}
+		if err := esClient.DeleteIndex(ctx, index.Name); err != nil {
+			return dropped, err
+		}
+		dropped++
+		log.Info("dropped daily index", slog.String("index", index.Name))
+	}
+	return dropped, nil
+}
+
+// archiveMatching uploads every document of index matching query before
+// it is deleted. It does nothing when archiving is disabled.
+func archiveMatching(ctx context.Context, log *slog.Logger, esClient *elasticsearch.Client, archiver *archive.Archive, index, name string, query esquery.Query, batchSize int) error {
+	if archiver == nil {
+		return nil
+	}
+
+	w := archiver.NewWriter(name)
+	err := esClient.ScrollIndex(ctx, index, query, batchSize, func(docs []json.RawMessage) error {
+		for _, doc := range docs {
+			if err := w.Write(ctx, doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = w.Close(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	if w.Written() > 0 {
+		log.Info("archived documents", slog.String("export", name), slog.Int64("documents", w.Written()), slog.Any("objects", w.Keys()))
+	}
+	return nil
+}
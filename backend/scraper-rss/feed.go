@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// feed is one SCRAPER_RSS_FEEDS entry. Its items are published with Source
+// as their source.
+type feed struct {
+	Source string
+	URL    string
+}
+
+// parseFeeds reads [source=]url entries. The source defaults to the host
+// of the feed without "www.".
+func parseFeeds(entries []string) ([]feed, error) {
+	feeds := make([]feed, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		source, raw, ok := strings.Cut(entry, "=")
+		// A query string may contain "=" too.
+		if !ok || strings.Contains(source, "/") {
+			source, raw = "", entry
+		}
+		source, raw = strings.TrimSpace(source), strings.TrimSpace(raw)
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid feed %q, want [source=]http(s)://...", entry)
+		}
+		if source == "" {
+			source = strings.TrimPrefix(u.Hostname(), "www.")
+		}
+		if seen[raw] {
+			return nil, fmt.Errorf("feed %q listed more than once", raw)
+		}
+		seen[raw] = true
+		feeds = append(feeds, feed{Source: source, URL: raw})
+	}
+	return feeds, nil
+}
+
+// feedItem is an RSS item or Atom entry reduced to what is published.
+type feedItem struct {
+	GUID        string
+	Title       string
+	Description string
+	Link        string
+	Published   time.Time
+}
+
+// feedDocument decodes RSS 2.0 (rss > channel > item) and Atom (feed >
+// entry) alike; the root element decides which fields are filled.
+type feedDocument struct {
+	XMLName xml.Name
+	Channel struct {
+		Items []struct {
+			GUID        string `xml:"guid"`
+			Title       string `xml:"title"`
+			Description string `xml:"description"`
+			// Full texts of content:encoded win over descriptions.
+			Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+			Link    string `xml:"link"`
+			PubDate string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+	Entries []struct {
+		ID      string `xml:"id"`
+		Title   string `xml:"title"`
+		Summary string `xml:"summary"`
+		Content string `xml:"content"`
+		Links   []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+// parseFeed reads an RSS or Atom document. Items without a GUID are
+// identified by their link; items with neither are skipped.
+func parseFeed(r io.Reader) ([]feedItem, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read feed: %w", err)
+	}
+	var doc feedDocument
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	// Documents declaring another charset are read as UTF-8 all the same.
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode feed: %w", err)
+	}
+
+	var items []feedItem
+	switch doc.XMLName.Local {
+	case "rss":
+		for _, it := range doc.Channel.Items {
+			text := it.Content
+			if strings.TrimSpace(text) == "" {
+				text = it.Description
+			}
+			items = append(items, feedItem{
+				GUID:        firstNonEmpty(it.GUID, it.Link),
+				Title:       plainText(it.Title),
+				Description: plainText(text),
+				Link:        strings.TrimSpace(it.Link),
+				Published:   parseFeedTime(it.PubDate),
+			})
+		}
+	case "feed":
+		for _, e := range doc.Entries {
+			var link string
+			for _, l := range e.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					link = strings.TrimSpace(l.Href)
+					break
+				}
+			}
+			text := e.Content
+			if strings.TrimSpace(text) == "" {
+				text = e.Summary
+			}
+			items = append(items, feedItem{
+				GUID:        firstNonEmpty(e.ID, link),
+				Title:       plainText(e.Title),
+				Description: plainText(text),
+				Link:        link,
+				Published:   parseFeedTime(firstNonEmpty(e.Published, e.Updated)),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("decode feed: unsupported root element <%s>, want <rss> or <feed>", doc.XMLName.Local)
+	}
+
+	kept := items[:0]
+	for _, item := range items {
+		if item.GUID != "" {
+			kept = append(kept, item)
+		}
+	}
+	return kept, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+var (
+	htmlTag    = regexp.MustCompile(`<[^>]*>`)
+	blockBreak = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/h[1-6])\b[^>]*>`)
+	blankLines = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// plainText turns the HTML of descriptions into text, keeping paragraph
+// breaks, so the worker sees the words and not the markup.
+func plainText(raw string) string {
+	text := blockBreak.ReplaceAllString(raw, "\n")
+	text = html.UnescapeString(htmlTag.ReplaceAllString(text, ""))
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n"))
+}
+
+// parseFeedTime reads RFC 822 pubDates and RFC 3339 Atom dates, returning
+// the zero time for anything else.
+func parseFeedTime(raw string) time.Time {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, time.RFC822Z, time.RFC822, time.RFC3339, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST"} {
+		if ts, err := time.Parse(layout, raw); err == nil {
+			return ts.UTC()
+		}
+	}
+	return time.Time{}
+}
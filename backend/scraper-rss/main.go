@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/logger"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+const (
+	// maxFeedBytes bounds a downloaded feed document.
+	maxFeedBytes = 10 << 20
+	// maxIdempotencyKeyBytes is the longest key the worker accepts.
+	maxIdempotencyKeyBytes = 512
+	userAgent              = "hot-tour-radar-scraper/1.0 (+https://github.com/DeafMist/hot-tour-radar)"
+)
+
+// messageWriter is the subset of kafka.Writer used by the scraper.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type scraper struct {
+	log    *slog.Logger
+	http   *http.Client
+	writer messageWriter
+	state  *stateStore
+	maxAge time.Duration
+}
+
+func main() {
+	log := logger.New("scraper-rss")
+	cfg, err := config.LoadRSSScraper()
+	if err != nil {
+		log.Error("load config", slog.Any("err", err))
+		os.Exit(1)
+	}
+	feeds, err := parseFeeds(cfg.Feeds)
+	if err != nil {
+		log.Error("parse SCRAPER_RSS_FEEDS", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	state, err := loadState(cfg.StateFile)
+	if err != nil {
+		log.Error("load state", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.KafkaBrokers...),
+		Topic:        cfg.KafkaTopic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}
+	defer writer.Close()
+
+	s := &scraper{
+		log:    log,
+		http:   &http.Client{Timeout: cfg.Timeout},
+		writer: writer,
+		state:  state,
+		maxAge: cfg.MaxAge,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	log.Info("rss scraper started",
+		slog.Int("feeds", len(feeds)),
+		slog.String("topic", cfg.KafkaTopic),
+		slog.Duration("interval", cfg.Interval),
+	)
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		for _, f := range feeds {
+			published, err := s.pollFeed(ctx, f, time.Now())
+			if err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				log.Warn("poll feed", slog.String("feed", f.URL), slog.Any("err", err))
+				continue
+			}
+			if published > 0 {
+				log.Info("published feed items", slog.String("feed", f.URL), slog.String("source", f.Source), slog.Int("published", published))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			log.Info("context canceled, stopping")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollFeed publishes the items of f that were not published before and
+// returns how many it published. Items are only remembered once Kafka has
+// accepted them, so a failed poll is retried in full on the next one.
+func (s *scraper) pollFeed(ctx context.Context, f feed, now time.Time) (int, error) {
+	prev := s.state.feed(f.URL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if prev.ETag != "" {
+		req.Header.Set("If-None-Match", prev.ETag)
+	}
+	if prev.LastModified != "" {
+		req.Header.Set("If-Modified-Since", prev.LastModified)
+	}
+	res, err := s.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("fetch: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotModified {
+		return 0, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("fetch: status %d", res.StatusCode)
+	}
+
+	items, err := parseFeed(io.LimitReader(res.Body, maxFeedBytes))
+	if err != nil {
+		return 0, err
+	}
+
+	seen := make(map[string]bool, len(prev.Seen)+len(items))
+	for _, guid := range prev.Seen {
+		seen[guid] = true
+	}
+	var (
+		msgs  []kafka.Message
+		guids []string
+	)
+	// Feeds list the newest item first; publish in publication order.
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if seen[item.GUID] {
+			continue
+		}
+		if s.maxAge > 0 && !item.Published.IsZero() && now.Sub(item.Published) > s.maxAge {
+			continue
+		}
+		msg, err := message(f, item)
+		if err != nil {
+			return 0, err
+		}
+		seen[item.GUID] = true
+		msgs = append(msgs, msg)
+		guids = append(guids, item.GUID)
+	}
+
+	if len(msgs) > 0 {
+		if err := s.writer.WriteMessages(ctx, msgs...); err != nil {
+			return 0, fmt.Errorf("publish items: %w", err)
+		}
+	}
+	if err := s.state.update(f.URL, guids, res.Header.Get("ETag"), res.Header.Get("Last-Modified")); err != nil {
+		return len(msgs), fmt.Errorf("save state: %w", err)
+	}
+	return len(msgs), nil
+}
+
+// message converts a feed item into a Kafka message keyed
+// rss:<source>:<guid>, with the GUID hashed when the key would be too long
+// for the worker.
+func message(f feed, item feedItem) (kafka.Message, error) {
+	news := models.RawNews{Title: item.Title, Text: item.Description, Source: f.Source}
+	if item.Link != "" {
+		news.Text += "\n" + item.Link
+	}
+	if !item.Published.IsZero() {
+		news.Timestamp = item.Published.Format(time.RFC3339)
+	}
+	value, err := json.Marshal(news)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("marshal item %s: %w", item.GUID, err)
+	}
+
+	key := "rss:" + f.Source + ":" + item.GUID
+	if len(key) > maxIdempotencyKeyBytes {
+		sum := sha1.Sum([]byte(item.GUID))
+		key = "rss:" + f.Source + ":" + hex.EncodeToString(sum[:])
+	}
+	return kafka.Message{
+		Key:     []byte(key),
+		Value:   value,
+		Headers: []kafka.Header{{Key: "idempotency_key", Value: []byte(key)}},
+	}, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel>
+	<title>Горящие туры</title>
+	<item>
+		<guid isPermaLink="false">offer-2</guid>
+		<title>Египет &amp; Красное море</title>
+		<description>&lt;p&gt;Хургада, 5*&lt;/p&gt;&lt;p&gt;от 60 000 ₽&lt;/p&gt;</description>
+		<link>https://example.com/offers/2</link>
+		<pubDate>Sat, 01 Jun 2024 12:00:00 +0300</pubDate>
+	</item>
+	<item>
+		<title>Турция на 7 ночей</title>
+		<description>Анталья от 45 000 ₽</description>
+		<content:encoded><![CDATA[<p>Анталья, <b>всё включено</b>, от 45 000 ₽</p>]]></content:encoded>
+		<link>https://example.com/offers/1</link>
+		<pubDate>Sat, 01 Jun 2024 08:00:00 GMT</pubDate>
+	</item>
+	<item>
+		<guid>offer-0</guid>
+		<title>Старое предложение</title>
+		<pubDate>Mon, 01 Jan 2024 08:00:00 GMT</pubDate>
+	</item>
+</channel>
+</rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<entry>
+		<id>urn:uuid:1225c695</id>
+		<title>Сочи на выходные</title>
+		<link rel="alternate" href="https://example.org/sochi"/>
+		<summary>Отель у моря от 12 000 ₽</summary>
+		<updated>2024-06-01T09:30:00Z</updated>
+	</entry>
+</feed>`
+
+func TestParseFeeds(t *testing.T) {
+	feeds, err := parseFeeds([]string{"travelata=https://travelata.ru/rss", "https://www.example.com/feed?a=b"})
+	require.NoError(t, err)
+	require.Equal(t, []feed{
+		{Source: "travelata", URL: "https://travelata.ru/rss"},
+		{Source: "example.com", URL: "https://www.example.com/feed?a=b"},
+	}, feeds)
+
+	_, err = parseFeeds([]string{"ftp://example.com/feed"})
+	require.ErrorContains(t, err, "invalid feed")
+	_, err = parseFeeds([]string{"https://a.ru/rss", "x=https://a.ru/rss"})
+	require.ErrorContains(t, err, "listed more than once")
+}
+
+func TestParseFeed(t *testing.T) {
+	items, err := parseFeed(strings.NewReader(rssFeed))
+	require.NoError(t, err)
+	require.Len(t, items, 3)
+	require.Equal(t, feedItem{
+		GUID:        "offer-2",
+		Title:       "Египет & Красное море",
+		Description: "Хургада, 5*\nот 60 000 ₽",
+		Link:        "https://example.com/offers/2",
+		Published:   time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC),
+	}, items[0])
+	// Items without a GUID are identified by their link; full texts win.
+	require.Equal(t, "https://example.com/offers/1", items[1].GUID)
+	require.Equal(t, "Анталья, всё включено, от 45 000 ₽", items[1].Description)
+
+	items, err = parseFeed(strings.NewReader(atomFeed))
+	require.NoError(t, err)
+	require.Equal(t, []feedItem{{
+		GUID:        "urn:uuid:1225c695",
+		Title:       "Сочи на выходные",
+		Description: "Отель у моря от 12 000 ₽",
+		Link:        "https://example.org/sochi",
+		Published:   time.Date(2024, 6, 1, 9, 30, 0, 0, time.UTC),
+	}}, items)
+
+	_, err = parseFeed(strings.NewReader(`<html><body>moved</body></html>`))
+	require.ErrorContains(t, err, "unsupported root element <html>")
+}
+
+type recordingWriter struct {
+	msgs []kafka.Message
+	err  error
+}
+
+func (w *recordingWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func TestPollFeed(t *testing.T) {
+	var conditional string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = r.Header.Get("If-None-Match")
+		if conditional == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = io.WriteString(w, rssFeed)
+	}))
+	t.Cleanup(srv.Close)
+
+	state, err := loadState(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	failing := &recordingWriter{err: errors.New("kafka down")}
+	s := &scraper{
+		log:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		http:   srv.Client(),
+		writer: failing,
+		state:  state,
+		maxAge: 72 * time.Hour,
+	}
+	f := feed{Source: "deals", URL: srv.URL}
+	now := time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)
+
+	// Nothing is remembered while Kafka is down.
+	_, err = s.pollFeed(context.Background(), f, now)
+	require.ErrorContains(t, err, "kafka down")
+	require.Empty(t, state.feed(f.URL).Seen)
+
+	writer := &recordingWriter{}
+	s.writer = writer
+	published, err := s.pollFeed(context.Background(), f, now)
+	require.NoError(t, err)
+	// The item past SCRAPER_RSS_MAX_AGE is skipped; the rest are published oldest first.
+	require.Equal(t, 2, published)
+	require.Equal(t, "rss:deals:https://example.com/offers/1", string(writer.msgs[0].Key))
+	require.Equal(t, "rss:deals:offer-2", string(writer.msgs[1].Headers[0].Value))
+
+	var news models.RawNews
+	require.NoError(t, json.Unmarshal(writer.msgs[1].Value, &news))
+	require.Equal(t, models.RawNews{
+		Title:     "Египет & Красное море",
+		Text:      "Хургада, 5*\nот 60 000 ₽\nhttps://example.com/offers/2",
+		Timestamp: "2024-06-01T09:00:00Z",
+		Source:    "deals",
+	}, news)
+
+	// The next poll is conditional and a restart keeps the published GUIDs.
+	published, err = s.pollFeed(context.Background(), f, now)
+	require.NoError(t, err)
+	require.Zero(t, published)
+	require.Equal(t, `"v1"`, conditional)
+	reloaded, err := loadState(state.path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://example.com/offers/1", "offer-2"}, reloaded.feed(f.URL).Seen)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/statefile"
+)
+
+// maxRememberedGUIDs bounds the per-feed memory of published items. It
+// must exceed the number of items a feed lists at once.
+const maxRememberedGUIDs = 1000
+
+// feedState is what the scraper remembers of a feed between polls.
+type feedState struct {
+	// Seen holds the GUIDs of published items, oldest first.
+	Seen []string `json:"seen"`
+	// ETag and LastModified make the next request conditional.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// stateStore persists the feed states as JSON, keyed by feed URL, so a
+// restart does not publish items again.
+type stateStore struct {
+	mu    sync.Mutex
+	path  string
+	feeds map[string]*feedState
+}
+
+func loadState(path string) (*stateStore, error) {
+	s := &stateStore{path: path, feeds: map[string]*feedState{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.feeds); err != nil {
+		return nil, fmt.Errorf("decode state: %w", err)
+	}
+	if s.feeds == nil {
+		s.feeds = map[string]*feedState{}
+	}
+	return s, nil
+}
+
+// feed returns a copy of the state of the feed at url.
+func (s *stateStore) feed(url string) feedState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.feeds[url]
+	if !ok {
+		return feedState{}
+	}
+	return feedState{Seen: slices.Clone(st.Seen), ETag: st.ETag, LastModified: st.LastModified}
+}
+
+// update records the GUIDs published from the feed at url and the
+// validators of its last response.
+func (s *stateStore) update(url string, published []string, etag, lastModified string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.feeds[url]
+	if !ok {
+		st = &feedState{}
+		s.feeds[url] = st
+	}
+	st.Seen = append(st.Seen, published...)
+	if len(st.Seen) > maxRememberedGUIDs {
+		st.Seen = st.Seen[len(st.Seen)-maxRememberedGUIDs:]
+	}
+	st.ETag, st.LastModified = etag, lastModified
+	return s.saveLocked()
+}
+
+// saveLocked writes the state atomically.
+func (s *stateStore) saveLocked() error {
+	return statefile.Save(s.path, s.feeds)
+}
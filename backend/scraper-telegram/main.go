@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/logger"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/telegram"
+)
+
+// postSource is the subset of the Telegram client used by the scraper.
+type postSource interface {
+	GetChannelPosts(ctx context.Context, offset int64, timeout time.Duration) ([]telegram.Update, error)
+}
+
+// messageWriter is the subset of kafka.Writer used by the scraper.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// scraper publishes the posts of the configured channels. The Bot API only
+// delivers posts of channels the bot administers, so the bot has to be
+// added to each channel; posts of other channels it sits in are dropped.
+type scraper struct {
+	log      *slog.Logger
+	source   postSource
+	writer   messageWriter
+	state    *stateStore
+	channels map[string]bool
+	timeout  time.Duration
+}
+
+func main() {
+	log := logger.New("scraper-telegram")
+	cfg, err := config.LoadTelegramScraper()
+	if err != nil {
+		log.Error("load config", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	state, err := loadState(cfg.StateFile)
+	if err != nil {
+		log.Error("load state", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.KafkaBrokers...),
+		Topic:        cfg.KafkaTopic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}
+	defer writer.Close()
+
+	s := &scraper{
+		log:      log,
+		source:   telegram.New(cfg.TelegramToken),
+		writer:   writer,
+		state:    state,
+		channels: make(map[string]bool, len(cfg.Channels)),
+		timeout:  cfg.PollTimeout,
+	}
+	for _, channel := range cfg.Channels {
+		s.channels[channel] = true
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	log.Info("telegram scraper started",
+		slog.Any("channels", cfg.Channels),
+		slog.String("topic", cfg.KafkaTopic),
+		slog.Int64("offset", state.offset()),
+	)
+
+	for {
+		if err := s.poll(ctx); err != nil {
+			if ctx.Err() != nil {
+				log.Info("context canceled, stopping")
+				return
+			}
+			log.Warn("poll channel posts", slog.Any("err", err))
+			select {
+			case <-time.After(5 * time.Second):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// poll publishes one batch of updates and moves the stored offset past it.
+// When publishing fails the offset stays, so the batch is fetched and
+// published again; the idempotency keys make that harmless.
+func (s *scraper) poll(ctx context.Context) error {
+	updates, err := s.source.GetChannelPosts(ctx, s.state.offset(), s.timeout)
+	if err != nil {
+		return err
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	msgs := make([]kafka.Message, 0, len(updates))
+	for _, update := range updates {
+		post := update.ChannelPost
+		if post == nil {
+			post = update.EditedChannelPost
+		}
+		msg, ok, err := s.message(post)
+		if err != nil {
+			return err
+		}
+		if ok {
+			msgs = append(msgs, msg)
+		}
+	}
+	if len(msgs) > 0 {
+		if err := s.writer.WriteMessages(ctx, msgs...); err != nil {
+			return fmt.Errorf("publish posts: %w", err)
+		}
+	}
+	if err := s.state.setOffset(updates[len(updates)-1].UpdateID + 1); err != nil {
+		return fmt.Errorf("save offset: %w", err)
+	}
+	s.log.Info("published channel posts", slog.Int("updates", len(updates)), slog.Int("published", len(msgs)))
+	return nil
+}
+
+// message converts a post into a Kafka message, reporting false for posts
+// of unconfigured channels and posts without text. Posts are keyed
+// telegram:<channel>:<message id> like backfilled ones, so an edited post
+// updates its document and a post both imported and scraped is stored
+// once.
+func (s *scraper) message(post *telegram.Message) (kafka.Message, bool, error) {
+	if post == nil {
+		return kafka.Message{}, false, nil
+	}
+	channel := strings.ToLower(post.Chat.Username)
+	if !s.channels[channel] {
+		s.log.Debug("skip post of unconfigured chat", slog.Int64("chat_id", post.Chat.ID), slog.String("username", post.Chat.Username))
+		return kafka.Message{}, false, nil
+	}
+	// Media posts keep their text in the caption.
+	text := post.Text
+	if text == "" {
+		text = post.Caption
+	}
+	if strings.TrimSpace(text) == "" {
+		return kafka.Message{}, false, nil
+	}
+
+	news := models.RawNews{
+		Text:      fmt.Sprintf("%s\nhttps://t.me/%s/%d", text, channel, post.MessageID),
+		Timestamp: time.Unix(post.Date, 0).UTC().Format(time.RFC3339),
+		Source:    "telegram",
+	}
+	// Channel posts reply within their own channel.
+	if post.ReplyToMessage != nil && post.ReplyToMessage.MessageID > 0 {
+		news.ReplyTo = fmt.Sprintf("https://t.me/%s/%d", channel, post.ReplyToMessage.MessageID)
+	}
+	value, err := json.Marshal(news)
+	if err != nil {
+		return kafka.Message{}, false, fmt.Errorf("marshal post %d: %w", post.MessageID, err)
+	}
+	key := fmt.Sprintf("telegram:%s:%d", channel, post.MessageID)
+	return kafka.Message{
+		Key:     []byte(key),
+		Value:   value,
+		Headers: []kafka.Header{{Key: "idempotency_key", Value: []byte(key)}},
+	}, true, nil
+}
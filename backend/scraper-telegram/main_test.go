@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/telegram"
+)
+
+type stubSource struct {
+	updates []telegram.Update
+	offset  int64
+}
+
+func (s *stubSource) GetChannelPosts(_ context.Context, offset int64, _ time.Duration) ([]telegram.Update, error) {
+	s.offset = offset
+	return s.updates, nil
+}
+
+type recordingWriter struct {
+	msgs []kafka.Message
+	err  error
+}
+
+func (w *recordingWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func newTestScraper(t *testing.T, source postSource, writer messageWriter) *scraper {
+	t.Helper()
+	state, err := loadState(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	return &scraper{
+		log:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		source:   source,
+		writer:   writer,
+		state:    state,
+		channels: map[string]bool{"hottours": true},
+		timeout:  time.Second,
+	}
+}
+
+func TestPollPublishesConfiguredChannels(t *testing.T) {
+	channel := telegram.Chat{ID: -1001, Type: "channel", Username: "HotTours"}
+	source := &stubSource{updates: []telegram.Update{
+		{UpdateID: 5, ChannelPost: &telegram.Message{MessageID: 12, Chat: channel, Date: 1717232400, Caption: "Турция 45 000 ₽"}},
+		{UpdateID: 6, ChannelPost: &telegram.Message{MessageID: 1, Chat: telegram.Chat{ID: -1002, Username: "other"}, Text: "Египет"}},
+		{UpdateID: 7, EditedChannelPost: &telegram.Message{MessageID: 13, Chat: channel, Date: 1717236000, Text: "Распродано",
+			ReplyToMessage: &telegram.Message{MessageID: 12}}},
+		{UpdateID: 8, ChannelPost: &telegram.Message{MessageID: 14, Chat: channel, Date: 1717236000}},
+	}}
+	writer := &recordingWriter{}
+	s := newTestScraper(t, source, writer)
+
+	require.NoError(t, s.poll(context.Background()))
+	require.Len(t, writer.msgs, 2)
+	require.Equal(t, "telegram:hottours:12", string(writer.msgs[0].Key))
+	require.Equal(t, []kafka.Header{{Key: "idempotency_key", Value: []byte("telegram:hottours:12")}}, writer.msgs[0].Headers)
+
+	var news models.RawNews
+	require.NoError(t, json.Unmarshal(writer.msgs[0].Value, &news))
+	require.Equal(t, models.RawNews{
+		Text:      "Турция 45 000 ₽\nhttps://t.me/hottours/12",
+		Timestamp: "2024-06-01T09:00:00Z",
+		Source:    "telegram",
+	}, news)
+	require.NoError(t, json.Unmarshal(writer.msgs[1].Value, &news))
+	require.Equal(t, "https://t.me/hottours/12", news.ReplyTo)
+
+	// The offset moves past the batch and survives a restart.
+	require.Equal(t, int64(9), s.state.offset())
+	reloaded, err := loadState(s.state.path)
+	require.NoError(t, err)
+	require.Equal(t, int64(9), reloaded.offset())
+}
+
+func TestPollKeepsOffsetWhenPublishingFails(t *testing.T) {
+	source := &stubSource{updates: []telegram.Update{
+		{UpdateID: 5, ChannelPost: &telegram.Message{MessageID: 12, Chat: telegram.Chat{Username: "hottours"}, Text: "Турция"}},
+	}}
+	s := newTestScraper(t, source, &recordingWriter{err: errors.New("kafka down")})
+
+	require.ErrorContains(t, s.poll(context.Background()), "kafka down")
+	require.Zero(t, s.state.offset())
+
+	// The next poll asks for the same updates again.
+	s.writer = &recordingWriter{}
+	require.NoError(t, s.poll(context.Background()))
+	require.Zero(t, source.offset)
+	require.Equal(t, int64(6), s.state.offset())
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/statefile"
+)
+
+// scraperState is persisted as JSON so a restart continues after the last
+// published update instead of losing or republishing posts.
+type scraperState struct {
+	Offset int64 `json:"offset"`
+}
+
+type stateStore struct {
+	mu    sync.Mutex
+	path  string
+	state scraperState
+}
+
+func loadState(path string) (*stateStore, error) {
+	s := &stateStore{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, fmt.Errorf("decode state: %w", err)
+	}
+	return s, nil
+}
+
+func (s *stateStore) offset() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Offset
+}
+
+func (s *stateStore) setOffset(offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Offset = offset
+	return s.saveLocked()
+}
+
+// saveLocked writes the state atomically.
+func (s *stateStore) saveLocked() error {
+	return statefile.Save(s.path, s.state)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/notify"
+)
+
+// Counters behind the alert rules, also served on the control server's
+// /debug/vars.
+var (
+	indexedDocuments = expvar.NewInt("indexed_documents")
+	deadLettered     = expvar.NewInt("dead_lettered_messages")
+	droppedByRules   = expvar.NewInt("dropped_by_rules")
+)
+
+// alertRule is one condition operators are paged for.
+type alertRule struct {
+	name string
+	// check returns why the rule fires, or "" while it is healthy.
+	check func(ctx context.Context, now time.Time) (string, error)
+}
+
+// alertManager evaluates the rules and notifies when one starts firing,
+// every repeat while it keeps firing and once when it resolves.
+type alertManager struct {
+	log      *slog.Logger
+	notifier notify.Notifier
+	rules    []alertRule
+	repeat   time.Duration
+	// instance names the worker in notifications.
+	instance string
+
+	// notified holds when each firing rule was last notified about.
+	notified map[string]time.Time
+}
+
+// run evaluates the rules every interval until ctx is cancelled.
+func (m *alertManager) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.evaluate(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *alertManager) evaluate(ctx context.Context, now time.Time) {
+	if m.notified == nil {
+		m.notified = make(map[string]time.Time)
+	}
+	for _, rule := range m.rules {
+		reason, err := rule.check(ctx, now)
+		if err != nil {
+			// An unknown state neither fires nor resolves the rule.
+			m.log.Warn("evaluate alert rule", slog.String("rule", rule.name), slog.Any("err", err))
+			continue
+		}
+
+		last, firing := m.notified[rule.name]
+		switch {
+		case reason != "" && (!firing || now.Sub(last) >= m.repeat):
+			m.log.Warn("alert firing", slog.String("rule", rule.name), slog.String("reason", reason))
+			// A failed notification is retried on the next evaluation.
+			if m.send(ctx, fmt.Sprintf("[FIRING] worker %s, %s: %s", m.instance, rule.name, reason)) {
+				m.notified[rule.name] = now
+			}
+		case reason == "" && firing:
+			m.log.Info("alert resolved", slog.String("rule", rule.name))
+			delete(m.notified, rule.name)
+			m.send(ctx, fmt.Sprintf("[RESOLVED] worker %s, %s", m.instance, rule.name))
+		}
+	}
+}
+
+func (m *alertManager) send(ctx context.Context, text string) bool {
+	if err := m.notifier.Notify(ctx, text); err != nil {
+		m.log.Error("send alert notification", slog.Any("err", err))
+		return false
+	}
+	return true
+}
+
+type counterSample struct {
+	at    time.Time
+	value int64
+}
+
+// dlqRateRule fires while more than limit messages per minute were
+// dead-lettered over window. It needs one evaluation to get a baseline.
+func dlqRateRule(counter *expvar.Int, limit float64, window time.Duration) alertRule {
+	var samples []counterSample
+	return alertRule{
+		name: "dlq_rate",
+		check: func(_ context.Context, now time.Time) (string, error) {
+			samples = append(samples, counterSample{at: now, value: counter.Value()})
+			// Keep the newest sample from before the window as the baseline.
+			for len(samples) > 1 && !samples[1].at.After(now.Add(-window)) {
+				samples = samples[1:]
+			}
+			base, last := samples[0], samples[len(samples)-1]
+			elapsed := last.at.Sub(base.at)
+			if elapsed <= 0 {
+				return "", nil
+			}
+			rate := float64(last.value-base.value) / elapsed.Minutes()
+			if rate <= limit {
+				return "", nil
+			}
+			return fmt.Sprintf("%.1f messages/min dead-lettered over the last %s (limit %g)", rate, elapsed.Round(time.Second), limit), nil
+		},
+	}
+}
+
+// idleRule fires once no document was indexed for idle.
+func idleRule(counter *expvar.Int, idle time.Duration) alertRule {
+	var (
+		value   int64
+		changed time.Time
+	)
+	return alertRule{
+		name: "no_documents_indexed",
+		check: func(_ context.Context, now time.Time) (string, error) {
+			if v := counter.Value(); changed.IsZero() || v != value {
+				value, changed = v, now
+			}
+			if now.Sub(changed) < idle {
+				return "", nil
+			}
+			return fmt.Sprintf("no documents indexed for %s", now.Sub(changed).Round(time.Second)), nil
+		},
+	}
+}
+
+// lagRule fires while the consumer group is more than limit messages
+// behind on the topics in total.
+func lagRule(offsets groupOffsets, topics []string, limit int) alertRule {
+	return alertRule{
+		name: "consumer_lag",
+		check: func(ctx context.Context, _ time.Time) (string, error) {
+			var (
+				total int64
+				parts []string
+			)
+			for _, topic := range topics {
+				lag, err := topicLag(ctx, offsets, topic)
+				if err != nil {
+					return "", err
+				}
+				total += lag
+				parts = append(parts, fmt.Sprintf("%s %d", topic, lag))
+			}
+			if total <= int64(limit) {
+				return "", nil
+			}
+			return fmt.Sprintf("consumer lag %d messages (%s, limit %d)", total, strings.Join(parts, ", "), limit), nil
+		},
+	}
+}
+
+// topicLag counts the messages of topic the consumer group has not
+// committed yet.
+func topicLag(ctx context.Context, offsets groupOffsets, topic string) (int64, error) {
+	ranges, err := offsets.committed(ctx, topic)
+	if err != nil {
+		return 0, fmt.Errorf("read offsets of %s: %w", topic, err)
+	}
+	var lag int64
+	for _, r := range ranges {
+		lag += max(r.End-r.Committed, 0)
+	}
+	return lag, nil
+}
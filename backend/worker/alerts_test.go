@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stubNotifier struct {
+	texts []string
+	err   error
+}
+
+func (s *stubNotifier) Notify(_ context.Context, text string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.texts = append(s.texts, text)
+	return nil
+}
+
+type stubOffsets map[string][]partitionRange
+
+func (s stubOffsets) committed(_ context.Context, topic string) ([]partitionRange, error) {
+	return s[topic], nil
+}
+
+func TestAlertManagerFiresRepeatsAndResolves(t *testing.T) {
+	firing := true
+	notifier := &stubNotifier{}
+	m := &alertManager{
+		log:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		notifier: notifier,
+		repeat:   time.Hour,
+		instance: "worker-1",
+		rules: []alertRule{{name: "test", check: func(context.Context, time.Time) (string, error) {
+			if firing {
+				return "broken", nil
+			}
+			return "", nil
+		}}},
+	}
+
+	start := time.Now()
+	m.evaluate(context.Background(), start)
+	m.evaluate(context.Background(), start.Add(time.Minute))
+	require.Equal(t, []string{"[FIRING] worker worker-1, test: broken"}, notifier.texts)
+
+	m.evaluate(context.Background(), start.Add(time.Hour))
+	require.Len(t, notifier.texts, 2)
+
+	firing = false
+	m.evaluate(context.Background(), start.Add(61*time.Minute))
+	m.evaluate(context.Background(), start.Add(62*time.Minute))
+	require.Equal(t, "[RESOLVED] worker worker-1, test", notifier.texts[2])
+	require.Len(t, notifier.texts, 3)
+
+	// An undelivered notification is retried on the next evaluation.
+	firing = true
+	notifier.err = errors.New("telegram down")
+	m.evaluate(context.Background(), start.Add(63*time.Minute))
+	notifier.err = nil
+	m.evaluate(context.Background(), start.Add(64*time.Minute))
+	require.Len(t, notifier.texts, 4)
+}
+
+func TestDLQRateRule(t *testing.T) {
+	counter := new(expvar.Int)
+	rule := dlqRateRule(counter, 2, 5*time.Minute)
+	start := time.Now()
+
+	reason, err := rule.check(context.Background(), start)
+	require.NoError(t, err)
+	require.Empty(t, reason)
+
+	counter.Add(5)
+	reason, _ = rule.check(context.Background(), start.Add(5*time.Minute))
+	require.Empty(t, reason, "1 message/min is within the limit")
+
+	counter.Add(30)
+	reason, _ = rule.check(context.Background(), start.Add(10*time.Minute))
+	require.Contains(t, reason, "6.0 messages/min")
+
+	reason, _ = rule.check(context.Background(), start.Add(15*time.Minute))
+	require.Empty(t, reason)
+}
+
+func TestIdleRule(t *testing.T) {
+	counter := new(expvar.Int)
+	rule := idleRule(counter, 10*time.Minute)
+	start := time.Now()
+
+	reason, _ := rule.check(context.Background(), start)
+	require.Empty(t, reason)
+	reason, _ = rule.check(context.Background(), start.Add(10*time.Minute))
+	require.Equal(t, "no documents indexed for 10m0s", reason)
+
+	counter.Add(1)
+	reason, _ = rule.check(context.Background(), start.Add(11*time.Minute))
+	require.Empty(t, reason)
+}
+
+func TestLagRule(t *testing.T) {
+	offsets := stubOffsets{
+		"news_raw":  {{Partition: 0, Committed: 90, End: 100}, {Partition: 1, Committed: 40, End: 100}},
+		"rss_items": {{Partition: 0, Committed: 5, End: 5}},
+	}
+	reason, err := lagRule(offsets, []string{"news_raw", "rss_items"}, 50).check(context.Background(), time.Now())
+	require.NoError(t, err)
+	require.Equal(t, "consumer lag 70 messages (news_raw 70, rss_items 0, limit 50)", reason)
+
+	reason, err = lagRule(offsets, []string{"news_raw", "rss_items"}, 70).check(context.Background(), time.Now())
+	require.NoError(t, err)
+	require.Empty(t, reason)
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/dedupe"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+)
+
+// auditReadTimeout bounds reading one partition's sample.
+const auditReadTimeout = time.Minute
+
+// partitionRange locates the consumer group on a partition: the group is
+// done with offsets from First up to, excluding, Committed, and End is the
+// offset of the next message to be written.
+type partitionRange struct {
+	Partition int
+	First     int64
+	Committed int64
+	End       int64
+}
+
+// groupOffsets reports where the consumer group is on each partition of a
+// topic. Partitions it has not committed on yet count as committed at
+// First, where it starts reading them.
+type groupOffsets interface {
+	committed(ctx context.Context, topic string) ([]partitionRange, error)
+}
+
+// auditLog reads back messages the worker has already consumed.
+type auditLog interface {
+	groupOffsets
+	// read returns the messages of a partition with offsets in [from, to).
+	read(ctx context.Context, topic string, partition int, from, to int64) ([]kafka.Message, error)
+}
+
+// docLookup is the subset of the Elasticsearch client used by the audit.
+type docLookup interface {
+	MultiGetNews(ctx context.Context, ids []string) ([]*models.NewsDocument, error)
+}
+
+// auditResult is the outcome of auditing one topic.
+type auditResult struct {
+	Checked      int       `json:"checked"`
+	Missing      int       `json:"missing"`
+	MissingRatio float64   `json:"missing_ratio"`
+	Reemitted    int       `json:"reemitted"`
+	At           time.Time `json:"at"`
+}
+
+// auditor periodically checks that recently committed Kafka messages made
+// it into Elasticsearch. It recomputes each message's document ID with the
+// worker's own pipeline, so it only holds while the pipeline is unchanged
+// since the messages were processed.
+type auditor struct {
+	log      *slog.Logger
+	source   auditLog
+	docs     docLookup
+	decoder  messageDecoder
+	pipeline *atomic.Pointer[processing.Pipeline]
+	cache    *dedupe.Cache
+	// reemit, when set, receives the missing messages for another attempt.
+	reemit messageWriter
+	topics []string
+	sample int
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	results map[string]auditResult
+	errors  int
+}
+
+// run audits every interval until ctx is cancelled.
+func (a *auditor) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.audit(ctx)
+		}
+	}
+}
+
+// audit checks every topic once. A failing topic is logged and counted;
+// the others are still checked.
+func (a *auditor) audit(ctx context.Context) {
+	for _, topic := range a.topics {
+		result, err := a.auditTopic(ctx, topic)
+		a.mu.Lock()
+		if err != nil {
+			a.errors++
+		} else {
+			if a.results == nil {
+				a.results = make(map[string]auditResult)
+			}
+			a.results[topic] = result
+		}
+		a.mu.Unlock()
+
+		if err != nil {
+			if ctx.Err() == nil {
+				a.log.Error("consistency audit failed", slog.String("topic", topic), slog.Any("err", err))
+			}
+			continue
+		}
+		level := slog.LevelInfo
+		if result.Missing > 0 {
+			level = slog.LevelWarn
+		}
+		a.log.Log(ctx, level, "consistency audit finished",
+			slog.String("topic", topic),
+			slog.Int("checked", result.Checked),
+			slog.Int("missing", result.Missing),
+			slog.Float64("missing_ratio", result.MissingRatio),
+			slog.Int("reemitted", result.Reemitted),
+		)
+	}
+}
+
+func (a *auditor) auditTopic(ctx context.Context, topic string) (auditResult, error) {
+	ranges, err := a.source.committed(ctx, topic)
+	if err != nil {
+		return auditResult{}, fmt.Errorf("read committed offsets: %w", err)
+	}
+
+	result := auditResult{At: time.Now().UTC()}
+	for _, r := range ranges {
+		from := max(r.First, r.Committed-int64(a.sample))
+		if from >= r.Committed {
+			continue
+		}
+		msgs, err := a.source.read(ctx, topic, r.Partition, from, r.Committed)
+		if err != nil {
+			return auditResult{}, fmt.Errorf("read partition %d: %w", r.Partition, err)
+		}
+		checked, missing, err := a.check(ctx, msgs)
+		if err != nil {
+			return auditResult{}, fmt.Errorf("check partition %d: %w", r.Partition, err)
+		}
+		result.Checked += checked
+		result.Missing += len(missing)
+
+		if a.reemit != nil && len(missing) > 0 {
+			if err := a.resend(ctx, missing); err != nil {
+				return auditResult{}, fmt.Errorf("re-emit partition %d: %w", r.Partition, err)
+			}
+			result.Reemitted += len(missing)
+		}
+	}
+	if result.Checked > 0 {
+		result.MissingRatio = float64(result.Missing) / float64(result.Checked)
+	}
+	return result, nil
+}
+
+// auditedMessage is a sampled message with what the worker derived from it.
+type auditedMessage struct {
+	msg       kafka.Message
+	id        string
+	dedupeKey string
+}
+
+// check looks up the documents of msgs and returns how many messages were
+// checked and which of them have no document. Messages the worker would
+// dead-letter, older than maxAge or already expired are not expected in
+// the index and are skipped.
+func (a *auditor) check(ctx context.Context, msgs []kafka.Message) (int, []auditedMessage, error) {
+	cutoff := time.Now().Add(-a.maxAge)
+	var (
+		audited []auditedMessage
+		ids     []string
+	)
+	for _, msg := range msgs {
+		if msg.Time.Before(cutoff) {
+			continue
+		}
+		prepared, err := prepareMessage(msg, a.decoder, a.pipeline.Load())
+		if err != nil {
+			continue
+		}
+		if prepared.dropped || prepared.index != "" {
+			continue
+		}
+		if !prepared.doc.ExpiresAt.IsZero() && prepared.doc.ExpiresAt.Before(time.Now()) {
+			continue
+		}
+		audited = append(audited, auditedMessage{msg: msg, id: prepared.doc.ID, dedupeKey: prepared.dedupeKey})
+		ids = append(ids, prepared.doc.ID)
+	}
+	if len(ids) == 0 {
+		return 0, nil, nil
+	}
+
+	docs, err := a.docs.MultiGetNews(ctx, ids)
+	if err != nil {
+		return 0, nil, err
+	}
+	var missing []auditedMessage
+	for i, doc := range docs {
+		if doc == nil {
+			missing = append(missing, audited[i])
+		}
+	}
+	return len(audited), missing, nil
+}
+
+// resend writes missing messages back to their topic unchanged apart from
+// an audit_reemit header. Their dedupe entries are dropped first, or the
+// worker would skip the second delivery as a duplicate.
+func (a *auditor) resend(ctx context.Context, missing []auditedMessage) error {
+	out := make([]kafka.Message, 0, len(missing))
+	for _, m := range missing {
+		a.cache.Invalidate(m.dedupeKey)
+		headers := append(append([]kafka.Header(nil), m.msg.Headers...), kafka.Header{Key: "audit_reemit", Value: []byte("true")})
+		out = append(out, kafka.Message{Topic: m.msg.Topic, Key: m.msg.Key, Value: m.msg.Value, Headers: headers})
+		a.log.Info("re-emitting message missing from index",
+			slog.String("topic", m.msg.Topic),
+			slog.Int("partition", m.msg.Partition),
+			slog.Int64("offset", m.msg.Offset),
+			slog.String("id", m.id),
+		)
+	}
+	return a.reemit.WriteMessages(ctx, out...)
+}
+
+// vars is the expvar.Func behind consistency_audit.
+func (a *auditor) vars() any {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	topics := make(map[string]auditResult, len(a.results))
+	for topic, result := range a.results {
+		topics[topic] = result
+	}
+	return map[string]any{"topics": topics, "errors": a.errors}
+}
+
+// kafkaGroupLog reads the consumer group's offsets and committed messages
+// from the brokers without joining the group.
+type kafkaGroupLog struct {
+	client  *kafka.Client
+	brokers []string
+	group   string
+}
+
+func (l *kafkaGroupLog) committed(ctx context.Context, topic string) ([]partitionRange, error) {
+	meta, err := l.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, err
+	}
+	if len(meta.Topics) == 0 {
+		return nil, fmt.Errorf("topic %q not found", topic)
+	}
+	if err := meta.Topics[0].Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(meta.Topics[0].Partitions))
+	requests := make([]kafka.OffsetRequest, 0, 2*len(meta.Topics[0].Partitions))
+	for _, p := range meta.Topics[0].Partitions {
+		ids = append(ids, p.ID)
+		requests = append(requests, kafka.FirstOffsetOf(p.ID), kafka.LastOffsetOf(p.ID))
+	}
+
+	commits, err := l.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: l.group, Topics: map[string][]int{topic: ids}})
+	if err != nil {
+		return nil, err
+	}
+	if commits.Error != nil {
+		return nil, commits.Error
+	}
+	offsets, err := l.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: requests}})
+	if err != nil {
+		return nil, err
+	}
+	bounds := make(map[int]kafka.PartitionOffsets, len(ids))
+	for _, p := range offsets.Topics[topic] {
+		if p.Error == nil {
+			bounds[p.Partition] = p
+		}
+	}
+
+	var ranges []partitionRange
+	for _, p := range commits.Topics[topic] {
+		b, ok := bounds[p.Partition]
+		if p.Error != nil || !ok {
+			continue
+		}
+		r := partitionRange{Partition: p.Partition, First: b.FirstOffset, Committed: p.CommittedOffset, End: b.LastOffset}
+		// A negative offset means the group has not committed on the partition yet.
+		if r.Committed < 0 {
+			r.Committed = r.First
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func (l *kafkaGroupLog) read(ctx context.Context, topic string, partition int, from, to int64) ([]kafka.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, auditReadTimeout)
+	defer cancel()
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   l.brokers,
+		Topic:     topic,
+		Partition: partition,
+		MaxBytes:  10e6,
+		MaxWait:   time.Second,
+	})
+	defer reader.Close()
+	if err := reader.SetOffset(from); err != nil {
+		return nil, err
+	}
+
+	msgs := make([]kafka.Message, 0, to-from)
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && len(msgs) > 0 {
+				// Compacted or transactional topics may have gaps up to the end.
+				return msgs, nil
+			}
+			return nil, err
+		}
+		if msg.Offset >= to {
+			return msgs, nil
+		}
+		msgs = append(msgs, msg)
+		if msg.Offset == to-1 {
+			return msgs, nil
+		}
+	}
+}
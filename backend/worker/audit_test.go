@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/dedupe"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+)
+
+type stubAuditLog struct {
+	ranges []partitionRange
+	msgs   []kafka.Message
+	reads  [][2]int64
+}
+
+func (s *stubAuditLog) committed(context.Context, string) ([]partitionRange, error) {
+	return s.ranges, nil
+}
+
+func (s *stubAuditLog) read(_ context.Context, _ string, _ int, from, to int64) ([]kafka.Message, error) {
+	s.reads = append(s.reads, [2]int64{from, to})
+	var out []kafka.Message
+	for _, msg := range s.msgs {
+		if msg.Offset >= from && msg.Offset < to {
+			out = append(out, msg)
+		}
+	}
+	return out, nil
+}
+
+type stubLookup map[string]models.NewsDocument
+
+func (s stubLookup) MultiGetNews(_ context.Context, ids []string) ([]*models.NewsDocument, error) {
+	docs := make([]*models.NewsDocument, len(ids))
+	for i, id := range ids {
+		if doc, ok := s[id]; ok {
+			docs[i] = &doc
+		}
+	}
+	return docs, nil
+}
+
+func auditMessage(t *testing.T, offset int64, title string, at time.Time) kafka.Message {
+	t.Helper()
+	data, err := json.Marshal(models.RawNews{Title: title, Text: "Море и солнце", Timestamp: "2024-01-02T15:04:05Z", Source: "rss"})
+	require.NoError(t, err)
+	return kafka.Message{Topic: "news_raw", Offset: offset, Value: data, Key: []byte(title), Time: at}
+}
+
+func TestAuditFindsAndReemitsMissingDocuments(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cache := dedupe.NewCache(100, time.Hour)
+	pipeline := newTestPipeline(t, &config.Worker{Pipeline: []string{"clean", "id"}})
+
+	now := time.Now()
+	var msgs []kafka.Message
+	for i := range 6 {
+		msgs = append(msgs, auditMessage(t, int64(i), fmt.Sprintf("Тур %d", i), now))
+	}
+	msgs[0].Time = now.Add(-48 * time.Hour)                                              // too old to check
+	msgs[4] = kafka.Message{Topic: "news_raw", Offset: 4, Value: []byte("{"), Time: now} // dead-lettered
+	// Index everything but "Тур 2" and "Тур 3", which went missing.
+	idx := &stubIndexer{}
+	processBatch(context.Background(), log, idx, time.Millisecond, cache, testDecoder, pipeline, []kafka.Message{msgs[0], msgs[1], msgs[5]})
+	cache.MarkSeen(mustPrepare(t, msgs[2], pipeline).dedupeKey)
+	indexed := stubLookup{}
+	for _, doc := range idx.docs {
+		indexed[doc.ID] = doc
+	}
+
+	source := &stubAuditLog{ranges: []partitionRange{{Partition: 0, First: 0, Committed: 6}}, msgs: msgs}
+	writer := &stubWriter{}
+	var current atomic.Pointer[processing.Pipeline]
+	current.Store(pipeline)
+	a := &auditor{
+		log:      log,
+		source:   source,
+		docs:     indexed,
+		decoder:  testDecoder,
+		pipeline: &current,
+		cache:    cache,
+		reemit:   writer,
+		topics:   []string{"news_raw"},
+		sample:   100,
+		maxAge:   24 * time.Hour,
+	}
+	a.audit(context.Background())
+
+	result := a.vars().(map[string]any)["topics"].(map[string]auditResult)["news_raw"]
+	require.Equal(t, 4, result.Checked)
+	require.Equal(t, 2, result.Missing)
+	require.Equal(t, 0.5, result.MissingRatio)
+	require.Equal(t, 2, result.Reemitted)
+
+	require.Len(t, writer.msgs, 2)
+	require.Equal(t, "news_raw", writer.msgs[0].Topic)
+	require.Equal(t, msgs[2].Value, writer.msgs[0].Value)
+	require.Equal(t, []byte("Тур 2"), writer.msgs[0].Key)
+	require.Equal(t, "true", headerValue(writer.msgs[0], "audit_reemit"))
+	// The re-emitted copy must not be dropped as a duplicate.
+	require.False(t, cache.IsSeen(mustPrepare(t, msgs[2], pipeline).dedupeKey))
+}
+
+func TestAuditSamplesTheCommittedTail(t *testing.T) {
+	source := &stubAuditLog{ranges: []partitionRange{
+		{Partition: 0, First: 0, Committed: 1000},
+		{Partition: 1, First: 990, Committed: 1000},
+		{Partition: 2, First: 50, Committed: 50},
+	}}
+	a := &auditor{
+		log:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		source: source,
+		docs:   stubLookup{},
+		topics: []string{"news_raw"},
+		sample: 100,
+		maxAge: time.Hour,
+	}
+	a.audit(context.Background())
+
+	// Only already committed offsets are read, and never below the log start.
+	require.Equal(t, [][2]int64{{900, 1000}, {990, 1000}}, source.reads)
+	result := a.vars().(map[string]any)["topics"].(map[string]auditResult)["news_raw"]
+	require.Zero(t, result.Checked)
+	require.Zero(t, result.MissingRatio)
+}
+
+func mustPrepare(t *testing.T, msg kafka.Message, pipeline *processing.Pipeline) preparedMessage {
+	t.Helper()
+	prepared, err := prepareMessage(msg, testDecoder, pipeline)
+	require.NoError(t, err)
+	return prepared
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/dedupe"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+)
+
+// maxIndexAttempts bounds how often a batch is retried while Elasticsearch
+// is unavailable; indexBackoff is the wait before the first retry, doubled
+// for every further one.
+const (
+	maxIndexAttempts = 4
+	indexBackoff     = time.Second
+)
+
+// processBatch runs msgs through the pipeline and indexes the resulting
+// documents with a single bulk request. The returned errors are aligned
+// with msgs; a non-nil entry means the message belongs in the DLQ. backoff
+// is the wait before retrying items Elasticsearch could not take.
+func processBatch(ctx context.Context, log *slog.Logger, esClient newsIndexer, backoff time.Duration, cache *dedupe.Cache, decoder messageDecoder, pipeline *processing.Pipeline, msgs []kafka.Message) []error {
+	errs := make([]error, len(msgs))
+
+	var (
+		items   []elasticsearch.BulkItem
+		owners  []int
+		keys    []string
+		notices []*processing.SoldOutNotice
+		refresh bool
+	)
+	inBatch := make(map[string]struct{}, len(msgs))
+	for i, msg := range msgs {
+		prepared, err := prepareMessage(msg, decoder, pipeline)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if prepared.dropped {
+			droppedByRules.Add(1)
+			log.Info("news dropped by rule", slog.Any("rules", prepared.rules), slog.String("source", prepared.doc.Source))
+			continue
+		}
+
+		if _, dup := inBatch[prepared.dedupeKey]; dup || cache.IsSeen(prepared.dedupeKey) {
+			log.Debug("duplicate news", slog.String("id", prepared.doc.ID))
+			continue
+		}
+		inBatch[prepared.dedupeKey] = struct{}{}
+
+		items = append(items, elasticsearch.BulkItem{Doc: prepared.doc, Repost: prepared.repost, Index: prepared.index})
+		owners = append(owners, i)
+		keys = append(keys, prepared.dedupeKey)
+		notices = append(notices, prepared.soldOut)
+		// Refresh is per request, so one waiting producer makes the whole batch wait.
+		if headerValue(msg, "wait_for_refresh") == "true" {
+			refresh = true
+		}
+	}
+	if len(items) == 0 {
+		return errs
+	}
+
+	var opts []elasticsearch.IndexOption
+	if refresh {
+		opts = append(opts, elasticsearch.WaitForRefresh())
+	}
+
+	for j, err := range bulkIndexWithRetry(ctx, log, esClient, backoff, items, opts) {
+		if err != nil {
+			errs[owners[j]] = err
+			continue
+		}
+		cache.MarkSeen(keys[j])
+		indexedDocuments.Add(1)
+		log.Info("indexed news",
+			slog.String("id", items[j].Doc.ID),
+			slog.String("title", items[j].Doc.Title),
+			slog.Bool("repost_tracking", items[j].Repost),
+		)
+		if notices[j] != nil {
+			expireOriginals(ctx, log, esClient, items[j].Doc, notices[j])
+		}
+	}
+	return errs
+}
+
+// bulkIndexWithRetry retries the items that failed because Elasticsearch
+// was temporarily unavailable, either as a whole request or individually;
+// permanent failures are returned immediately so they can go to the DLQ.
+func bulkIndexWithRetry(ctx context.Context, log *slog.Logger, esClient newsIndexer, backoff time.Duration, items []elasticsearch.BulkItem, opts []elasticsearch.IndexOption) []error {
+	errs := make([]error, len(items))
+	pending := make([]int, len(items))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	for attempt := 1; ; attempt++ {
+		batch := make([]elasticsearch.BulkItem, len(pending))
+		for j, i := range pending {
+			batch[j] = items[i]
+		}
+
+		itemErrs, err := esClient.BulkIndexNews(ctx, batch, opts...)
+		var retry []int
+		for j, i := range pending {
+			errs[i] = err
+			if err == nil {
+				errs[i] = itemErrs[j]
+			}
+			if errs[i] != nil && elasticsearch.IsRetryable(errs[i]) {
+				retry = append(retry, i)
+			}
+		}
+		if len(retry) == 0 || attempt == maxIndexAttempts {
+			return errs
+		}
+
+		log.Warn("elasticsearch unavailable, retrying batch",
+			slog.Any("err", errs[retry[0]]),
+			slog.Int("items", len(retry)),
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", backoff),
+		)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return errs
+		}
+		backoff *= 2
+		pending = retry
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// messageCommitter is the part of kafka.Reader that commits offsets.
+type messageCommitter interface {
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+// failedMessage is a message that could be neither processed nor
+// dead-lettered.
+type failedMessage struct {
+	msg kafka.Message
+	err error
+}
+
+// partitionOffsets tracks one partition between commits. Offsets are those
+// Kafka commits: the offset of the next message to read.
+type partitionOffsets struct {
+	// handled is the offset after the newest message that was processed or
+	// dead-lettered.
+	handled   int64
+	committed int64
+	// failed holds the messages not handled yet, oldest first; the
+	// partition is committed up to the first of them.
+	failed []failedMessage
+}
+
+// offsetCommitter commits the offsets of handled messages every
+// WORKER_COMMIT_INTERVAL instead of after every batch, one commit for all
+// lanes. Kafka commits a partition as a whole up to an offset, so it never
+// moves past a message that was neither processed nor dead-lettered: that
+// message and everything after it are read again after a crash or a
+// rebalance, and the dead-letter write is retried on every commit until it
+// succeeds. Everything committed has been handled; what is redelivered may
+// have been handled already, which deterministic IDs and the dedupe cache
+// absorb.
+type offsetCommitter struct {
+	log    *slog.Logger
+	reader messageCommitter
+	dlq    messageWriter
+
+	// commitMu serializes commits; mu guards partitions and is never held
+	// across a network call, so lanes are not blocked by a slow commit.
+	commitMu   sync.Mutex
+	mu         sync.Mutex
+	partitions map[topicPartition]*partitionOffsets
+}
+
+func newOffsetCommitter(log *slog.Logger, reader messageCommitter, dlq messageWriter) *offsetCommitter {
+	return &offsetCommitter{log: log, reader: reader, dlq: dlq, partitions: make(map[topicPartition]*partitionOffsets)}
+}
+
+// handle sends the failed messages of a batch to the DLQ and records every
+// message of it for the next commit. The batch comes from one lane, so the
+// messages of each partition are in order.
+func (c *offsetCommitter) handle(ctx context.Context, msgs []kafka.Message, errs []error) {
+	var handled int64
+	var failed []failedMessage
+	for i, msg := range msgs {
+		if errs[i] != nil {
+			c.log.Warn("process message failed, sending to DLQ",
+				slog.Any("err", errs[i]),
+				slog.Int("partition", msg.Partition),
+				slog.Int64("offset", msg.Offset),
+			)
+			if !sendToDLQ(ctx, c.log, c.dlq, msg, errs[i]) {
+				if ctx.Err() != nil {
+					c.log.Info("context canceled during DLQ retry")
+				} else {
+					c.log.Error("DLQ write exhausted retries, holding back the partition's commits until it succeeds",
+						slog.Int("partition", msg.Partition),
+						slog.Int64("offset", msg.Offset),
+					)
+				}
+				failed = append(failed, failedMessage{msg: msg, err: errs[i]})
+				continue
+			}
+		}
+		handled++
+	}
+	processedMessages.Add(handled)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, msg := range msgs {
+		c.partition(msg).handled = msg.Offset + 1
+	}
+	for _, f := range failed {
+		p := c.partition(f.msg)
+		p.failed = append(p.failed, f)
+	}
+}
+
+// partition returns the offsets of msg's partition; c.mu must be held.
+func (c *offsetCommitter) partition(msg kafka.Message) *partitionOffsets {
+	key := topicPartition{topic: msg.Topic, partition: msg.Partition}
+	p, ok := c.partitions[key]
+	if !ok {
+		p = &partitionOffsets{handled: -1, committed: -1}
+		c.partitions[key] = p
+	}
+	return p
+}
+
+// run commits every interval until ctx is cancelled. The final commit
+// after the lanes are drained is up to the caller.
+func (c *offsetCommitter) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.commit(ctx)
+		}
+	}
+}
+
+// commit retries the dead-letter writes that failed, then commits every
+// partition up to its first message still not handled.
+func (c *offsetCommitter) commit(ctx context.Context) {
+	c.commitMu.Lock()
+	defer c.commitMu.Unlock()
+
+	c.retryFailed(ctx)
+
+	c.mu.Lock()
+	var commit []kafka.Message
+	for key, p := range c.partitions {
+		next := p.handled
+		if len(p.failed) > 0 {
+			next = p.failed[0].msg.Offset
+		}
+		if next > p.committed {
+			// CommitMessages commits the offset after the message.
+			commit = append(commit, kafka.Message{Topic: key.topic, Partition: key.partition, Offset: next - 1})
+		}
+	}
+	c.mu.Unlock()
+	if len(commit) == 0 {
+		return
+	}
+
+	if err := c.reader.CommitMessages(ctx, commit...); err != nil {
+		// The offsets are kept and committed with the next attempt.
+		c.log.Error("commit messages", slog.Any("err", err), slog.Int("partitions", len(commit)))
+		return
+	}
+	c.mu.Lock()
+	for _, msg := range commit {
+		c.partitions[topicPartition{topic: msg.Topic, partition: msg.Partition}].committed = msg.Offset + 1
+	}
+	c.mu.Unlock()
+}
+
+// retryFailed tries the dead-letter write of the messages holding back
+// their partitions once each, oldest first, stopping at the first failure
+// of a partition. c.commitMu must be held, which makes it the only one
+// removing failed messages.
+func (c *offsetCommitter) retryFailed(ctx context.Context) {
+	c.mu.Lock()
+	pending := make(map[topicPartition][]failedMessage)
+	for key, p := range c.partitions {
+		if len(p.failed) > 0 {
+			pending[key] = append([]failedMessage(nil), p.failed...)
+		}
+	}
+	c.mu.Unlock()
+
+	for key, failed := range pending {
+		written := 0
+		for _, f := range failed {
+			if err := c.dlq.WriteMessages(ctx, dlqMessage(f.msg, f.err)); err != nil {
+				c.log.Warn("DLQ write failed, partition commits still held back",
+					slog.Any("err", err),
+					slog.String("topic", f.msg.Topic),
+					slog.Int("partition", f.msg.Partition),
+					slog.Int64("offset", f.msg.Offset),
+				)
+				break
+			}
+			deadLettered.Add(1)
+			processedMessages.Add(1)
+			written++
+		}
+		if written == 0 {
+			continue
+		}
+		c.mu.Lock()
+		p := c.partitions[key]
+		p.failed = p.failed[written:]
+		c.mu.Unlock()
+	}
+}
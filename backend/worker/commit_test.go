@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+// stubGroup keeps the committed offsets of a consumer group.
+type stubGroup struct {
+	committed map[topicPartition]int64
+	calls     int
+	err       error
+}
+
+func (g *stubGroup) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	g.calls++
+	if g.err != nil {
+		return g.err
+	}
+	for _, msg := range msgs {
+		g.committed[topicPartition{topic: msg.Topic, partition: msg.Partition}] = msg.Offset + 1
+	}
+	return nil
+}
+
+// redeliver returns the messages of log a restarted worker reads again.
+func (g *stubGroup) redeliver(log []kafka.Message) []kafka.Message {
+	var msgs []kafka.Message
+	for _, msg := range log {
+		if msg.Offset >= g.committed[topicPartition{topic: msg.Topic, partition: msg.Partition}] {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// flakyWriter fails every write while down is set.
+type flakyWriter struct {
+	stubWriter
+	down bool
+}
+
+func (w *flakyWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.down {
+		return errors.New("dlq unavailable")
+	}
+	return w.stubWriter.WriteMessages(ctx, msgs...)
+}
+
+func partitionLog(partition, n int) []kafka.Message {
+	msgs := make([]kafka.Message, n)
+	for i := range msgs {
+		msgs[i] = kafka.Message{Topic: "news_raw", Partition: partition, Offset: int64(i)}
+	}
+	return msgs
+}
+
+func TestOffsetCommitterBatchesCommits(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	group := &stubGroup{committed: map[topicPartition]int64{}}
+	c := newOffsetCommitter(log, group, &stubWriter{})
+
+	p0, p1 := partitionLog(0, 5), partitionLog(1, 2)
+	c.handle(context.Background(), p0[:3], make([]error, 3))
+	c.handle(context.Background(), p1, make([]error, 2))
+	c.handle(context.Background(), p0[3:], make([]error, 2))
+	require.Zero(t, group.calls)
+
+	c.commit(context.Background())
+	require.Equal(t, 1, group.calls)
+	require.Equal(t, map[topicPartition]int64{{"news_raw", 0}: 5, {"news_raw", 1}: 2}, group.committed)
+
+	// Nothing new, nothing to commit.
+	c.commit(context.Background())
+	require.Equal(t, 1, group.calls)
+}
+
+func TestOffsetCommitterKeepsOffsetsOfFailedCommit(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	group := &stubGroup{committed: map[topicPartition]int64{}, err: errors.New("rebalance in progress")}
+	c := newOffsetCommitter(log, group, &stubWriter{})
+
+	msgs := partitionLog(0, 4)
+	c.handle(context.Background(), msgs[:2], make([]error, 2))
+	c.commit(context.Background())
+	require.Empty(t, group.committed)
+
+	group.err = nil
+	c.handle(context.Background(), msgs[2:], make([]error, 2))
+	c.commit(context.Background())
+	require.Equal(t, int64(4), group.committed[topicPartition{"news_raw", 0}])
+}
+
+func TestOffsetCommitterCrashRecovery(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	group := &stubGroup{committed: map[topicPartition]int64{}}
+	dlq := &flakyWriter{down: true}
+	c := newOffsetCommitter(log, group, dlq)
+
+	p0, p1 := partitionLog(0, 6), partitionLog(1, 3)
+	all := append(append([]kafka.Message(nil), p0...), p1...)
+
+	// A crash before the first commit redelivers everything.
+	c.handle(context.Background(), p1, make([]error, 3))
+	require.Equal(t, all, group.redeliver(all))
+
+	// Offset 2 of partition 0 fails and cannot be dead-lettered; the
+	// context is cancelled as in a drain that ran out of time.
+	errs := make([]error, 6)
+	errs[2] = errors.New("mapping conflict")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.handle(cancelled, p0, errs)
+
+	c.commit(context.Background())
+	require.Equal(t, int64(2), group.committed[topicPartition{"news_raw", 0}])
+	require.Equal(t, int64(3), group.committed[topicPartition{"news_raw", 1}])
+	require.Empty(t, dlq.msgs)
+
+	// After a crash the failed message and everything after it come back,
+	// nothing the worker handled before it is read again.
+	restarted := newOffsetCommitter(log, group, dlq)
+	redelivered := group.redeliver(all)
+	require.Equal(t, p0[2:], redelivered)
+
+	dlq.down = false
+	errs = make([]error, len(redelivered))
+	errs[0] = errors.New("mapping conflict")
+	restarted.handle(context.Background(), redelivered, errs)
+	restarted.commit(context.Background())
+	require.Empty(t, group.redeliver(all))
+	require.Len(t, dlq.msgs, 1)
+}
+
+func TestOffsetCommitterRetriesDeadLetters(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	group := &stubGroup{committed: map[topicPartition]int64{}}
+	dlq := &flakyWriter{down: true}
+	c := newOffsetCommitter(log, group, dlq)
+
+	msgs := partitionLog(0, 5)
+	errs := make([]error, 5)
+	errs[1] = errors.New("bad payload")
+	errs[3] = errors.New("bad payload")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.handle(cancelled, msgs, errs)
+
+	c.commit(context.Background())
+	require.Equal(t, int64(1), group.committed[topicPartition{"news_raw", 0}])
+
+	// Once the DLQ is back the held-back messages are dead-lettered in
+	// order and the partition catches up.
+	dlq.down = false
+	c.commit(context.Background())
+	require.Equal(t, int64(5), group.committed[topicPartition{"news_raw", 0}])
+	require.Len(t, dlq.msgs, 2)
+	require.Equal(t, "news_raw_dlq", dlq.msgs[0].Topic)
+	require.Contains(t, dlq.msgs[0].Headers, kafka.Header{Key: "original_offset", Value: []byte("1")})
+	require.Contains(t, dlq.msgs[1].Headers, kafka.Header{Key: "original_offset", Value: []byte("3")})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/datafiles"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/dedupe"
+)
+
+// controlServer exposes operational endpoints of a running worker.
+type controlServer struct {
+	log   *slog.Logger
+	token string
+	cache *dedupe.Cache
+	// data, when set, serves POST /admin/reload-data.
+	data *dataReloader
+}
+
+type invalidateRequest struct {
+	// Keys are document IDs; repost fingerprints match every time bucket.
+	Keys []string `json:"keys"`
+}
+
+type dedupeResponse struct {
+	Removed   int `json:"removed"`
+	Remaining int `json:"remaining"`
+}
+
+type controlError struct {
+	Error string `json:"error"`
+}
+
+func (s *controlServer) routes() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+	r.Use(s.requireToken)
+	r.Post("/dedupe/flush", s.handleFlush)
+	r.Post("/dedupe/invalidate", s.handleInvalidate)
+	if s.data != nil {
+		r.Post("/admin/reload-data", s.handleReloadData)
+	}
+	r.Handle("/debug/vars", expvar.Handler())
+	return r
+}
+
+// serve runs the control server until ctx is cancelled.
+func (s *controlServer) serve(ctx context.Context, addr string) {
+	serveHTTP(ctx, s.log, "control server", addr, s.routes())
+}
+
+// serveHTTP runs an HTTP server on addr until ctx is cancelled.
+func serveHTTP(ctx context.Context, log *slog.Logger, name, addr string, handler http.Handler) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Info(name+" starting", slog.String("addr", addr))
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error(name+" stopped", slog.Any("err", err))
+	}
+}
+
+func (s *controlServer) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeControlJSON(w, http.StatusUnauthorized, controlError{Error: "control token required"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *controlServer) handleFlush(w http.ResponseWriter, r *http.Request) {
+	removed := s.cache.Flush()
+	s.log.Warn("dedupe cache flushed", slog.Int("removed", removed))
+	writeControlJSON(w, http.StatusOK, dedupeResponse{Removed: removed, Remaining: 0})
+}
+
+func (s *controlServer) handleInvalidate(w http.ResponseWriter, r *http.Request) {
+	var req invalidateRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeControlJSON(w, http.StatusBadRequest, controlError{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	removed := 0
+	for _, key := range req.Keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		removed += s.cache.Invalidate(key)
+		// Repost sightings are remembered per fingerprint and time bucket.
+		removed += s.cache.InvalidatePrefix(key + "|")
+	}
+
+	s.log.Info("dedupe keys invalidated", slog.Int("requested", len(req.Keys)), slog.Int("removed", removed))
+	writeControlJSON(w, http.StatusOK, dedupeResponse{Removed: removed, Remaining: s.cache.Len()})
+}
+
+// handleReloadData re-reads the data objects and swaps in a pipeline built
+// from them. The current pipeline stays when an object cannot be fetched
+// (502) or fails its checksum or parsing (422).
+func (s *controlServer) handleReloadData(w http.ResponseWriter, r *http.Request) {
+	res, err := s.data.reload(r.Context())
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, datafiles.ErrInvalid) {
+			status = http.StatusUnprocessableEntity
+		}
+		s.log.Warn("data reload failed, keeping the current data", slog.Any("err", err))
+		writeControlJSON(w, status, controlError{Error: err.Error()})
+		return
+	}
+	writeControlJSON(w, http.StatusOK, res)
+}
+
+func writeControlJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
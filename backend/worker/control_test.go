@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/dedupe"
+)
+
+func TestControlDedupeEndpoints(t *testing.T) {
+	cache := dedupe.NewCache(10, time.Hour)
+	cache.MarkSeen("doc-1")
+	cache.MarkSeen("doc-2")
+	cache.MarkSeen("fp|2024-05-01T00:00:00Z")
+	cache.MarkSeen("fp|2024-05-02T00:00:00Z")
+
+	srv := &controlServer{
+		log:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		token: "secret",
+		cache: cache,
+	}
+	handler := srv.routes()
+
+	call := func(path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusUnauthorized, call("/dedupe/flush", "", "").Code)
+	require.Equal(t, http.StatusUnauthorized, call("/dedupe/flush", "wrong", "").Code)
+	require.Equal(t, http.StatusBadRequest, call("/dedupe/invalidate", "secret", `{"ids":["doc-1"]}`).Code)
+
+	rec := call("/dedupe/invalidate", "secret", `{"keys":["doc-1","fp","missing"]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"removed":3,"remaining":1}`, rec.Body.String())
+	require.False(t, cache.IsSeen("doc-1"))
+	require.True(t, cache.IsSeen("doc-2"))
+
+	rec = call("/dedupe/flush", "secret", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"removed":1,"remaining":0}`, rec.Body.String())
+	require.Zero(t, cache.Len())
+}
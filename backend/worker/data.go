@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/datafiles"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/destinations"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+)
+
+// pipelineBuilder builds the processing pipeline around the data files.
+type pipelineBuilder func(taxonomy *destinations.Taxonomy, titleRules *processing.TitleRules, rules *processing.Rules) (*processing.Pipeline, error)
+
+// dataReloader reads the worker's data files from object storage and
+// swaps in a pipeline built from them. Batches in flight finish with the
+// pipeline they started with; a failed load keeps the current one.
+type dataReloader struct {
+	log      *slog.Logger
+	loader   *datafiles.Loader
+	build    pipelineBuilder
+	pipeline *atomic.Pointer[processing.Pipeline]
+}
+
+// newDataReloader reads from WORKER_DATA_BUCKET with the WORKER_DATA_*
+// credentials, or the standard AWS ones when those are unset.
+func newDataReloader(log *slog.Logger, cfg *config.Worker, build pipelineBuilder, pipeline *atomic.Pointer[processing.Pipeline]) (*dataReloader, error) {
+	store, err := datafiles.NewStore(cfg.DataEndpoint, cfg.DataRegion, cfg.DataBucket, cfg.DataAccessKeyID, cfg.DataSecretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	return &dataReloader{log: log, loader: newWorkerLoader(store, cfg.DataPrefix), build: build, pipeline: pipeline}, nil
+}
+
+func newWorkerLoader(store datafiles.Getter, prefix string) *datafiles.Loader {
+	return datafiles.NewLoader(store, prefix, datafiles.Destinations, datafiles.TitleRules, datafiles.Rules)
+}
+
+// reload fetches and verifies every data object, then builds and swaps in
+// a new pipeline unless nothing changed.
+func (d *dataReloader) reload(ctx context.Context) (datafiles.Result, error) {
+	res, err := d.loader.Load(ctx, func(bodies map[string][]byte) error {
+		var (
+			taxonomy   *destinations.Taxonomy
+			titleRules *processing.TitleRules
+			rules      *processing.Rules
+			err        error
+		)
+		if body := bodies[datafiles.Destinations]; body != nil {
+			if taxonomy, err = destinations.Parse(body); err != nil {
+				return fmt.Errorf("%w: %s: %v", datafiles.ErrInvalid, datafiles.Destinations, err)
+			}
+		}
+		if body := bodies[datafiles.TitleRules]; body != nil {
+			if titleRules, err = processing.ParseTitleRules(body); err != nil {
+				return fmt.Errorf("%w: %s: %v", datafiles.ErrInvalid, datafiles.TitleRules, err)
+			}
+		}
+		if body := bodies[datafiles.Rules]; body != nil {
+			if rules, err = processing.ParseRules(body); err != nil {
+				return fmt.Errorf("%w: %s: %v", datafiles.ErrInvalid, datafiles.Rules, err)
+			}
+		}
+		pipeline, err := d.build(taxonomy, titleRules, rules)
+		if err != nil {
+			return fmt.Errorf("%w: build pipeline: %v", datafiles.ErrInvalid, err)
+		}
+		d.pipeline.Store(pipeline)
+		return nil
+	})
+	if err == nil && res.Changed {
+		d.log.Info("data loaded", slog.Any("objects", res.Objects))
+	}
+	return res, err
+}
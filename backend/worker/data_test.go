@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/archive"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/datafiles"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/dedupe"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/destinations"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+)
+
+// stubBucket serves objects from a map; err, when set, fails every Get.
+type stubBucket struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (b *stubBucket) Get(_ context.Context, key string) ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	body, ok := b.objects[key]
+	if !ok {
+		return nil, archive.ErrNotFound
+	}
+	return body, nil
+}
+
+// put stores body under key together with its checksum object.
+func (b *stubBucket) put(key, body string) {
+	sum := sha256.Sum256([]byte(body))
+	b.objects[key] = []byte(body)
+	b.objects[key+".sha256"] = []byte(hex.EncodeToString(sum[:]) + "  " + key + "\n")
+}
+
+func newTestReloader(bucket *stubBucket) *dataReloader {
+	return &dataReloader{
+		log:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		loader: newWorkerLoader(bucket, "data/"),
+		build: func(taxonomy *destinations.Taxonomy, titleRules *processing.TitleRules, rules *processing.Rules) (*processing.Pipeline, error) {
+			return processing.BuildPipeline([]string{"rules"}, processing.Options{Destinations: taxonomy, TitleRules: titleRules, Rules: rules})
+		},
+		pipeline: &atomic.Pointer[processing.Pipeline]{},
+	}
+}
+
+func dropsAds(t *testing.T, pipeline *processing.Pipeline) bool {
+	t.Helper()
+	item := &processing.Item{Doc: models.NewsDocument{Source: "ads", Text: "Скидки"}}
+	require.NoError(t, pipeline.Run(item))
+	return item.Drop
+}
+
+func TestDataReload(t *testing.T) {
+	bucket := &stubBucket{objects: map[string][]byte{}}
+	bucket.put("data/rules.yaml", "- name: ads\n  when: {source: ads}\n  then: {drop: true}\n")
+	data := newTestReloader(bucket)
+
+	res, err := data.reload(context.Background())
+	require.NoError(t, err)
+	require.True(t, res.Changed)
+	require.Equal(t, []datafiles.Object{
+		{Name: "destinations.json", Missing: true},
+		{Name: "title_rules.json", Missing: true},
+		{Name: "rules.yaml", SHA256: "0503fee5d7c96b57436836b638e5408131e20ebdabc6b4c4a83948e266f98bd6", Bytes: 55},
+	}, res.Objects)
+	loaded := data.pipeline.Load()
+	require.True(t, dropsAds(t, loaded))
+
+	res, err = data.reload(context.Background())
+	require.NoError(t, err)
+	require.False(t, res.Changed)
+	require.Same(t, loaded, data.pipeline.Load())
+
+	// A rules file uploaded without its new checksum is rejected.
+	bucket.objects["data/rules.yaml"] = []byte("[]\n")
+	_, err = data.reload(context.Background())
+	require.ErrorIs(t, err, datafiles.ErrInvalid)
+	require.ErrorContains(t, err, "checksum says")
+	require.Same(t, loaded, data.pipeline.Load())
+
+	bucket.put("data/rules.yaml", "- name: broken\n")
+	_, err = data.reload(context.Background())
+	require.ErrorIs(t, err, datafiles.ErrInvalid)
+	require.ErrorContains(t, err, "rules.yaml")
+	require.Same(t, loaded, data.pipeline.Load())
+
+	delete(bucket.objects, "data/rules.yaml.sha256")
+	_, err = data.reload(context.Background())
+	require.ErrorContains(t, err, "has no rules.yaml.sha256 checksum")
+
+	bucket.put("data/rules.yaml", "[]\n")
+	res, err = data.reload(context.Background())
+	require.NoError(t, err)
+	require.True(t, res.Changed)
+	require.False(t, dropsAds(t, data.pipeline.Load()))
+}
+
+func TestControlReloadData(t *testing.T) {
+	bucket := &stubBucket{objects: map[string][]byte{}}
+	srv := &controlServer{
+		log:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		token: "secret",
+		cache: dedupe.NewCache(10, 0),
+		data:  newTestReloader(bucket),
+	}
+	handler := srv.routes()
+
+	call := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/reload-data", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call()
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"changed":true`)
+
+	bucket.put("data/destinations.json", "{")
+	rec = call()
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	require.Contains(t, rec.Body.String(), "destinations.json")
+
+	bucket.err = errors.New("connection refused")
+	require.Equal(t, http.StatusBadGateway, call().Code)
+}
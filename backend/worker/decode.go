@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// pooledMessageBytes is the largest payload whose buffers go back to a
+// pool. A pooled buffer stays as large as the biggest payload it held, so
+// the rare large message gets buffers of its own instead of pinning up to
+// WORKER_MAX_MESSAGE_BYTES in every pooled one.
+const pooledMessageBytes = 64 << 10
+
+// errInvalidPayload marks payloads rejected before or around JSON decoding.
+var errInvalidPayload = errors.New("invalid payload")
+
+// decodePayload decodes a single JSON object into v, rejecting payloads
+// larger than limit before any decoding work happens.
+func decodePayload(data []byte, limit int, v any) error {
+	if len(data) > limit {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d", errInvalidPayload, len(data), limit)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		// Truncated JSON and trailing data after the object are syntax errors.
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return fmt.Errorf("%w: %v", errInvalidPayload, err)
+		}
+		return err
+	}
+	return nil
+}
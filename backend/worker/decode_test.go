@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func TestDecodeRawNews(t *testing.T) {
+	payload, err := decodeRawNews([]byte(`{"title":"Турция","text":"Анталья","source":"telegram","channel_id":1}`+"\n"), 1024)
+	require.NoError(t, err)
+	require.Equal(t, "Турция", payload.Title)
+	require.Equal(t, "telegram", payload.Source)
+
+	// Fields of one message must not carry over into the next.
+	payload, err = decodeRawNews([]byte(`{"text":"Египет"}`), 1024)
+	require.NoError(t, err)
+	require.Empty(t, payload.Title)
+	require.Equal(t, "Египет", payload.Text)
+
+	_, err = decodeRawNews([]byte(`{"text":"a"} {"text":"b"}`), 1024)
+	require.ErrorIs(t, err, errInvalidPayload)
+	require.Equal(t, "decode", errorClass(err))
+
+	_, err = decodeRawNews([]byte(`{"text":"`+strings.Repeat("x", 64)+`"}`), 32)
+	require.ErrorIs(t, err, errInvalidPayload)
+	require.Equal(t, "decode", errorClass(err))
+}
+
+func TestDecodeRawNewsSchemaVersions(t *testing.T) {
+	payload, err := decodeRawNews([]byte(`{"schema_version":1,"text":"Египет"}`), 1024)
+	require.NoError(t, err)
+	require.Equal(t, "Египет", payload.Text)
+
+	payload, err = decodeRawNews([]byte(`{"schema_version":2,"payload":{"title":"Турция","text":"Анталья","timestamp":"2024-06-01T10:00:00Z","source":"rss","reply_to":"https://t.me/c/1"}}`), 1024)
+	require.NoError(t, err)
+	require.Equal(t, models.RawNews{Title: "Турция", Text: "Анталья", Timestamp: "2024-06-01T10:00:00Z", Source: "rss", ReplyTo: "https://t.me/c/1"}, payload)
+
+	// Reused envelopes do not carry the payload over to the next message.
+	payload, err = decodeRawNews([]byte(`{"text":"Египет"}`), 1024)
+	require.NoError(t, err)
+	require.Equal(t, models.RawNews{Text: "Египет"}, payload)
+
+	for data, reason := range map[string]string{
+		`{"schema_version":3,"payload":{}}`:                                              "unknown schema_version 3, supported: 1, 2",
+		`{"title":"  "}`:                                                                 "version 1 requires title or text",
+		`{"payload":{"text":"Анталья"}}`:                                                 "version 1 has no payload field",
+		`{"schema_version":2,"text":"Анталья"}`:                                          "version 2 keeps news fields in payload",
+		`{"schema_version":2,"payload":{"text":"Анталья"}}`:                              "version 2 requires source, timestamp",
+		`{"schema_version":2,"payload":{"text":"a","source":"rss","timestamp":"вчера"}}`: `version 2 timestamp "вчера" is not RFC 3339`,
+	} {
+		_, err := decodeRawNews([]byte(data), 1024)
+		require.ErrorIs(t, err, errSchema, data)
+		require.ErrorContains(t, err, reason)
+		require.Equal(t, "schema", errorClass(err))
+	}
+
+	// The size limit applies to the whole envelope.
+	_, err = decodeRawNews([]byte(`{"schema_version":2,"payload":{"text":"`+strings.Repeat("x", 64)+`"}}`), 32)
+	require.ErrorIs(t, err, errInvalidPayload)
+}
+
+func benchmarkPayload(b *testing.B, textBytes int) []byte {
+	b.Helper()
+	data, err := json.Marshal(models.RawNews{
+		Title:     "Горящий тур",
+		Text:      strings.Repeat("Анталья, вылет завтра\n", textBytes/40+1),
+		Timestamp: "2024-06-01T10:00:00Z",
+		Source:    "telegram",
+	})
+	require.NoError(b, err)
+	return data
+}
+
+// BenchmarkDecodePayload compares decodePayload with plain json.Unmarshal,
+// on a typical payload and on one over the size limit, which decodePayload
+// rejects without decoding it.
+func BenchmarkDecodePayload(b *testing.B) {
+	for name, textBytes := range map[string]int{"typical": 4 << 10, "oversized": 8 << 20} {
+		data := benchmarkPayload(b, textBytes)
+		b.Run(name+"/decodePayload", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				var payload models.RawNews
+				_ = decodePayload(data, testDecoder.maxBytes, &payload)
+			}
+		})
+		b.Run(name+"/unmarshal", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				var payload models.RawNews
+				_ = json.Unmarshal(data, &payload)
+			}
+		})
+	}
+}
+
+// BenchmarkDecodeRawNews compares decodeRawNews on a version 2 envelope
+// with decoding the envelope and its payload by plain json.Unmarshal. The
+// pooled envelope takes the copy of the payload into a reused buffer.
+func BenchmarkDecodeRawNews(b *testing.B) {
+	payload := benchmarkPayload(b, 4<<10)
+	data, err := json.Marshal(map[string]any{"schema_version": 2, "payload": json.RawMessage(payload)})
+	require.NoError(b, err)
+
+	// Pooling must not leak one message's payload into the next.
+	news, err := decodeRawNews(data, testDecoder.maxBytes)
+	require.NoError(b, err)
+	require.Equal(b, "Горящий тур", news.Title)
+
+	b.Run("decodeRawNews", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_, _ = decodeRawNews(data, testDecoder.maxBytes)
+		}
+	})
+	b.Run("unmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var env newsEnvelope
+			_ = json.Unmarshal(data, &env)
+			var news models.RawNews
+			_ = json.Unmarshal(env.Payload, &news)
+		}
+	})
+}
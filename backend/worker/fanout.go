@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/eventbus"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+const (
+	// fanoutTopics publishes to one topic per destination: <topic>.<destination>.
+	fanoutTopics = "topics"
+	// fanoutKeyed publishes to a single topic using the destination as the message key.
+	fanoutKeyed = "keyed"
+
+	unknownDestination = "unknown"
+)
+
+// messageWriter is the subset of kafka.Writer used by the fan-out publisher.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// fanoutIndexer republishes every successfully indexed document to
+// per-destination streams so downstream consumers can subscribe narrowly.
+// Publishing failures are logged but never fail the message, because the
+// document is already stored at that point.
+type fanoutIndexer struct {
+	newsIndexer
+	writer messageWriter
+	mode   string
+	topic  string
+	log    *slog.Logger
+}
+
+func (f *fanoutIndexer) BulkIndexNews(ctx context.Context, items []elasticsearch.BulkItem, opts ...elasticsearch.IndexOption) ([]error, error) {
+	errs, err := f.newsIndexer.BulkIndexNews(ctx, items, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		// Documents routed to another index are not news subscribers see.
+		if errs[i] == nil && item.Index == "" {
+			f.publish(ctx, item.Doc)
+		}
+	}
+	return errs, nil
+}
+
+func (f *fanoutIndexer) publish(ctx context.Context, doc models.NewsDocument) {
+	msgs, err := fanoutMessages(f.mode, f.topic, doc)
+	if err != nil {
+		f.log.Warn("build fan-out messages", slog.String("id", doc.ID), slog.Any("err", err))
+		return
+	}
+	if err := f.writer.WriteMessages(ctx, msgs...); err != nil {
+		f.log.Warn("fan-out publish failed", slog.String("id", doc.ID), slog.Any("err", err))
+	}
+}
+
+// fanoutMessages builds one DocumentIndexed event message per destination
+// of doc.
+func fanoutMessages(mode, topic string, doc models.NewsDocument) ([]kafka.Message, error) {
+	destinations := doc.Destinations
+	if len(destinations) == 0 {
+		destinations = []string{unknownDestination}
+	}
+
+	msgs := make([]kafka.Message, 0, len(destinations))
+	for _, dest := range destinations {
+		var msgTopic string
+		switch mode {
+		case fanoutTopics:
+			msgTopic = topic + "." + topicSlug(dest)
+		case fanoutKeyed:
+			msgTopic = topic
+		default:
+			return nil, fmt.Errorf("unknown fan-out mode %q", mode)
+		}
+		msg, err := eventbus.Encode(msgTopic, dest, eventbus.DocumentIndexed{Document: doc})
+		if err != nil {
+			return nil, err
+		}
+		msg.Headers = append(msg.Headers,
+			kafka.Header{Key: "destination", Value: []byte(dest)},
+			kafka.Header{Key: "document_id", Value: []byte(doc.ID)},
+		)
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "",
+	'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// topicSlug transliterates a destination into the character set Kafka
+// accepts in topic names ([a-z0-9._-]).
+func topicSlug(dest string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(dest)) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+		case r == '-' || r == '_':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune('_')
+		default:
+			b.WriteString(cyrillicToLatin[r])
+		}
+	}
+	if b.Len() == 0 {
+		return unknownDestination
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/eventbus"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+type stubWriter struct {
+	msgs []kafka.Message
+}
+
+func (s *stubWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	s.msgs = append(s.msgs, msgs...)
+	return nil
+}
+
+func TestFanoutMessagesPerTopic(t *testing.T) {
+	doc := models.NewsDocument{ID: "doc-1", Destinations: []string{"Турция", "египет"}}
+
+	msgs, err := fanoutMessages(fanoutTopics, "news_indexed", doc)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, "news_indexed.turtsiya", msgs[0].Topic)
+	require.Equal(t, "news_indexed.egipet", msgs[1].Topic)
+	require.Equal(t, []byte("Турция"), msgs[0].Key)
+}
+
+func TestFanoutMessagesKeyed(t *testing.T) {
+	msgs, err := fanoutMessages(fanoutKeyed, "news_indexed", models.NewsDocument{ID: "doc-1"})
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "news_indexed", msgs[0].Topic)
+	require.Equal(t, []byte(unknownDestination), msgs[0].Key)
+	require.Contains(t, msgs[0].Headers, kafka.Header{Key: eventbus.TypeHeader, Value: []byte(eventbus.TypeDocumentIndexed)})
+
+	_, err = fanoutMessages("broadcast", "news_indexed", models.NewsDocument{})
+	require.Error(t, err)
+}
+
+func TestTopicSlug(t *testing.T) {
+	require.Equal(t, "oae", topicSlug("ОАЭ"))
+	require.Equal(t, "shri_lanka", topicSlug("Шри Ланка"))
+	require.Equal(t, "bali-2024", topicSlug("bali-2024"))
+	require.Equal(t, unknownDestination, topicSlug("!!!"))
+}
+
+func TestFanoutIndexerPublishesAfterIndexing(t *testing.T) {
+	idx := &stubIndexer{}
+	writer := &stubWriter{}
+	f := &fanoutIndexer{
+		newsIndexer: idx,
+		writer:      writer,
+		mode:        fanoutKeyed,
+		topic:       "news_indexed",
+		log:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	doc := models.NewsDocument{ID: "doc-1", Destinations: []string{"турция"}}
+	rejected := models.NewsDocument{ID: "doc-2", Title: "rejected", Destinations: []string{"египет"}}
+	idx.rejected = map[string][]error{"rejected": {elasticsearch.ErrBadRequest}}
+
+	errs, err := f.BulkIndexNews(context.Background(), []elasticsearch.BulkItem{{Doc: doc}, {Doc: rejected}})
+	require.NoError(t, err)
+	require.NoError(t, errs[0])
+	require.ErrorIs(t, errs[1], elasticsearch.ErrBadRequest)
+	require.Len(t, idx.docs, 1)
+	require.Len(t, writer.msgs, 1)
+	require.Equal(t, []byte("турция"), writer.msgs[0].Key)
+}
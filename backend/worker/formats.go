@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// payloadFormat normalizes one message shape into models.RawNews before the
+// shared processing path.
+type payloadFormat func(data []byte, limit int) (models.RawNews, error)
+
+// payloadFormats are the shapes selectable per topic with KAFKA_TOPIC_FORMATS.
+var payloadFormats = map[string]payloadFormat{
+	"news":     decodeRawNews,
+	"telegram": decodeTelegramPost,
+	"rss":      decodeRSSItem,
+	"vk":       decodeVKPost,
+}
+
+// parseTopicFormats resolves topic=format pairs against the consumed topics.
+func parseTopicFormats(pairs, topics []string) (map[string]payloadFormat, error) {
+	formats := make(map[string]payloadFormat, len(pairs))
+	for _, pair := range pairs {
+		topic, name, ok := strings.Cut(pair, "=")
+		topic, name = strings.TrimSpace(topic), strings.ToLower(strings.TrimSpace(name))
+		if !ok || topic == "" {
+			return nil, fmt.Errorf("invalid topic format %q, want topic=format", pair)
+		}
+		format, known := payloadFormats[name]
+		if !known {
+			return nil, fmt.Errorf("unknown format %q for topic %q (available: %s)",
+				name, topic, strings.Join(slices.Sorted(maps.Keys(payloadFormats)), ", "))
+		}
+		if !slices.Contains(topics, topic) {
+			return nil, fmt.Errorf("topic %q has a format but is not consumed, add it to KAFKA_TOPICS", topic)
+		}
+		formats[topic] = format
+	}
+	return formats, nil
+}
+
+// messageDecoder decodes messages with the format formats registers for
+// their topic, from KAFKA_TOPIC_FORMATS, rejecting payloads larger than
+// maxBytes. Topics without an entry carry canonical news payloads.
+type messageDecoder struct {
+	maxBytes int
+	formats  map[string]payloadFormat
+}
+
+func (d messageDecoder) decode(msg kafka.Message) (models.RawNews, error) {
+	if format, ok := d.formats[msg.Topic]; ok {
+		return format(msg.Value, d.maxBytes)
+	}
+	return decodeRawNews(msg.Value, d.maxBytes)
+}
+
+// telegramPost is a channel post as returned by the Telegram Bot API.
+type telegramPost struct {
+	MessageID int64  `json:"message_id"`
+	Date      int64  `json:"date"`
+	Text      string `json:"text"`
+	Caption   string `json:"caption"`
+	Chat      struct {
+		Username string `json:"username"`
+	} `json:"chat"`
+	ReplyToMessage *struct {
+		MessageID int64 `json:"message_id"`
+	} `json:"reply_to_message"`
+}
+
+func decodeTelegramPost(data []byte, limit int) (models.RawNews, error) {
+	var post telegramPost
+	if err := decodePayload(data, limit, &post); err != nil {
+		return models.RawNews{}, err
+	}
+
+	// Media posts keep their text in the caption.
+	text := post.Text
+	if text == "" {
+		text = post.Caption
+	}
+	if post.Chat.Username != "" && post.MessageID > 0 {
+		text += fmt.Sprintf("\nhttps://t.me/%s/%d", post.Chat.Username, post.MessageID)
+	}
+	// Channel posts reply within their own channel.
+	var replyTo string
+	if post.Chat.Username != "" && post.ReplyToMessage != nil && post.ReplyToMessage.MessageID > 0 {
+		replyTo = fmt.Sprintf("https://t.me/%s/%d", post.Chat.Username, post.ReplyToMessage.MessageID)
+	}
+	return models.RawNews{Text: text, Timestamp: unixTimestamp(post.Date), Source: "telegram", ReplyTo: replyTo}, nil
+}
+
+// rssItem is a feed entry as published by the RSS scraper.
+type rssItem struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Link        string `json:"link"`
+	PubDate     string `json:"pub_date"`
+}
+
+func decodeRSSItem(data []byte, limit int) (models.RawNews, error) {
+	var item rssItem
+	if err := decodePayload(data, limit, &item); err != nil {
+		return models.RawNews{}, err
+	}
+
+	text := item.Description
+	if item.Link != "" {
+		text += "\n" + item.Link
+	}
+	return models.RawNews{Title: item.Title, Text: text, Timestamp: rssTimestamp(item.PubDate), Source: "rss"}, nil
+}
+
+// rssTimestamp converts an RFC 822 pubDate to RFC 3339, passing through
+// values that are not in RFC 822 form.
+func rssTimestamp(raw string) string {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, time.RFC822Z, time.RFC822} {
+		if ts, err := time.Parse(layout, raw); err == nil {
+			return ts.UTC().Format(time.RFC3339)
+		}
+	}
+	return raw
+}
+
+// vkPost is a wall post as returned by the VK API wall.get method.
+type vkPost struct {
+	ID      int64  `json:"id"`
+	OwnerID int64  `json:"owner_id"`
+	Date    int64  `json:"date"`
+	Text    string `json:"text"`
+}
+
+func decodeVKPost(data []byte, limit int) (models.RawNews, error) {
+	var post vkPost
+	if err := decodePayload(data, limit, &post); err != nil {
+		return models.RawNews{}, err
+	}
+
+	text := post.Text
+	if post.ID > 0 && post.OwnerID != 0 {
+		text += fmt.Sprintf("\nhttps://vk.com/wall%d_%d", post.OwnerID, post.ID)
+	}
+	return models.RawNews{Text: text, Timestamp: unixTimestamp(post.Date), Source: "vk"}, nil
+}
+
+// unixTimestamp formats seconds since the epoch as RFC 3339, or returns ""
+// for a missing date so the worker falls back to the time of processing.
+func unixTimestamp(sec int64) string {
+	if sec <= 0 {
+		return ""
+	}
+	return time.Unix(sec, 0).UTC().Format(time.RFC3339)
+}
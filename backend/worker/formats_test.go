@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func TestParseTopicFormats(t *testing.T) {
+	topics := []string{"news_raw", "rss_items", "vk_posts"}
+
+	formats, err := parseTopicFormats([]string{"rss_items=RSS", " vk_posts = vk "}, topics)
+	require.NoError(t, err)
+	require.Len(t, formats, 2)
+	require.Contains(t, formats, "rss_items")
+	require.Contains(t, formats, "vk_posts")
+
+	_, err = parseTopicFormats([]string{"rss_items"}, topics)
+	require.ErrorContains(t, err, "want topic=format")
+
+	_, err = parseTopicFormats([]string{"rss_items=atom"}, topics)
+	require.ErrorContains(t, err, "available: news, rss, telegram, vk")
+
+	_, err = parseTopicFormats([]string{"ok_posts=vk"}, topics)
+	require.ErrorContains(t, err, "KAFKA_TOPICS")
+}
+
+func TestDecodeFormats(t *testing.T) {
+	post, err := decodeTelegramPost([]byte(`{"message_id":42,"date":1717236000,"caption":"Анталья от 30 000 ₽","chat":{"username":"hottours"}}`), 1024)
+	require.NoError(t, err)
+	require.Equal(t, models.RawNews{
+		Text:      "Анталья от 30 000 ₽\nhttps://t.me/hottours/42",
+		Timestamp: "2024-06-01T10:00:00Z",
+		Source:    "telegram",
+	}, post)
+
+	reply, err := decodeTelegramPost([]byte(`{"message_id":43,"date":1717236000,"text":"Мест нет","chat":{"username":"hottours"},"reply_to_message":{"message_id":42}}`), 1024)
+	require.NoError(t, err)
+	require.Equal(t, "https://t.me/hottours/42", reply.ReplyTo)
+
+	item, err := decodeRSSItem([]byte(`{"title":"Горящий тур","description":"Вылет завтра","link":"https://example.com/t/1","pub_date":"Sat, 01 Jun 2024 13:00:00 +0300"}`), 1024)
+	require.NoError(t, err)
+	require.Equal(t, models.RawNews{
+		Title:     "Горящий тур",
+		Text:      "Вылет завтра\nhttps://example.com/t/1",
+		Timestamp: "2024-06-01T10:00:00Z",
+		Source:    "rss",
+	}, item)
+
+	wall, err := decodeVKPost([]byte(`{"id":7,"owner_id":-123,"date":0,"text":"Египет"}`), 1024)
+	require.NoError(t, err)
+	require.Equal(t, "Египет\nhttps://vk.com/wall-123_7", wall.Text)
+	require.Empty(t, wall.Timestamp)
+	require.Equal(t, "vk", wall.Source)
+
+	_, err = decodeVKPost([]byte(`{"text":"a"} {}`), 1024)
+	require.ErrorIs(t, err, errInvalidPayload)
+}
+
+func TestPrepareMessageUsesTopicFormat(t *testing.T) {
+	decoder := messageDecoder{maxBytes: 1024, formats: map[string]payloadFormat{"vk_posts": decodeVKPost}}
+
+	pipeline := newTestPipeline(t, &config.Worker{Pipeline: []string{"urls", "clean"}, KeywordLimit: 5})
+	prepared, err := prepareMessage(kafka.Message{Topic: "vk_posts", Value: []byte(`{"id":1,"owner_id":5,"date":1717236000,"text":"Тур в Турцию"}`)}, decoder, pipeline)
+	require.NoError(t, err)
+	require.Equal(t, "vk", prepared.doc.Source)
+	require.Equal(t, []string{"https://vk.com/wall5_1"}, prepared.doc.URLs)
+
+	// Topics without a format carry canonical payloads.
+	prepared, err = prepareMessage(kafka.Message{Topic: "news_raw", Value: []byte(`{"text":"Тур в Турцию","source":"telegram"}`)}, decoder, pipeline)
+	require.NoError(t, err)
+	require.Equal(t, "telegram", prepared.doc.Source)
+}
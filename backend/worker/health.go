@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/segmentio/kafka-go"
+)
+
+// readyTimeout bounds the checks behind /readyz, which run on every probe.
+const readyTimeout = 3 * time.Second
+
+// indexChecker is the subset of the Elasticsearch client used by /readyz.
+type indexChecker interface {
+	IndexReady(ctx context.Context) error
+}
+
+// healthServer answers the orchestrator's probes on WORKER_HEALTH_ADDR.
+// Unlike the control server it needs no token.
+type healthServer struct {
+	log     *slog.Logger
+	index   indexChecker
+	kafka   kafkaAdmin
+	offsets groupOffsets
+	topics  []string
+}
+
+// readiness is the /readyz body. Each check reads "ok" or why it failed.
+type readiness struct {
+	Status        string           `json:"status"`
+	Elasticsearch string           `json:"elasticsearch"`
+	Kafka         string           `json:"kafka"`
+	Lag           map[string]int64 `json:"lag,omitempty"`
+}
+
+func (s *healthServer) routes() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+	r.Get("/livez", s.handleLive)
+	r.Get("/readyz", s.handleReady)
+	return r
+}
+
+func (s *healthServer) serve(ctx context.Context, addr string) {
+	serveHTTP(ctx, s.log, "health server", addr, s.routes())
+}
+
+// handleLive answers as long as the process serves HTTP, so a broker or
+// cluster outage does not get the worker restarted.
+func (s *healthServer) handleLive(w http.ResponseWriter, _ *http.Request) {
+	writeControlJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReady checks that the news index is reachable and that the brokers
+// answer for every topic, and reports the consumer group's lag per topic.
+// Lag does not make the worker unready: it is drained by consuming, which
+// a restart would only interrupt.
+func (s *healthServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	resp := readiness{Status: "ok", Elasticsearch: "ok", Kafka: "ok"}
+	if err := s.index.IndexReady(ctx); err != nil {
+		resp.Status, resp.Elasticsearch = "unavailable", err.Error()
+	}
+	if err := s.checkKafka(ctx); err != nil {
+		resp.Status, resp.Kafka = "unavailable", err.Error()
+	} else {
+		resp.Lag = make(map[string]int64, len(s.topics))
+		for _, topic := range s.topics {
+			lag, err := topicLag(ctx, s.offsets, topic)
+			if err != nil {
+				resp.Status, resp.Kafka, resp.Lag = "unavailable", err.Error(), nil
+				break
+			}
+			resp.Lag[topic] = lag
+		}
+	}
+
+	status := http.StatusOK
+	if resp.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeControlJSON(w, status, resp)
+}
+
+// checkKafka verifies that the brokers answer with metadata for every
+// topic.
+func (s *healthServer) checkKafka(ctx context.Context) error {
+	meta, err := s.kafka.Metadata(ctx, &kafka.MetadataRequest{Topics: s.topics})
+	if err != nil {
+		return err
+	}
+	for _, topic := range meta.Topics {
+		if topic.Error != nil {
+			return fmt.Errorf("topic %s: %w", topic.Name, topic.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+type stubIndex struct{ err error }
+
+func (s stubIndex) IndexReady(context.Context) error { return s.err }
+
+func TestHealthProbes(t *testing.T) {
+	index := &stubIndex{}
+	admin := &stubAdmin{topics: []kafka.Topic{{Name: "news_raw"}}}
+	srv := &healthServer{
+		log:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		index:   index,
+		kafka:   admin,
+		offsets: stubOffsets{"news_raw": {{Partition: 0, Committed: 90, End: 100}}},
+		topics:  []string{"news_raw"},
+	}
+	handler := srv.routes()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/readyz")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"status":"ok","elasticsearch":"ok","kafka":"ok","lag":{"news_raw":10}}`, rec.Body.String())
+
+	index.err = errors.New("check index news failed: 404 Not Found")
+	admin.metadataErr = errors.New("dial tcp: connection refused")
+	rec = get("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.JSONEq(t, `{"status":"unavailable","elasticsearch":"check index news failed: 404 Not Found","kafka":"dial tcp: connection refused"}`, rec.Body.String())
+
+	require.Equal(t, http.StatusOK, get("/livez").Code)
+}
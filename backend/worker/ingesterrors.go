@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// ingestErrorLog is the subset of the Elasticsearch client that stores
+// ingest error records.
+type ingestErrorLog interface {
+	RecordIngestErrors(ctx context.Context, records []models.IngestError) error
+}
+
+// recordIngestErrors stores a searchable record of every failed message of
+// a batch. The dead-letter copy stays authoritative, so a failure to store
+// the records is only logged.
+func recordIngestErrors(ctx context.Context, log *slog.Logger, store ingestErrorLog, decoder messageDecoder, msgs []kafka.Message, errs []error, excerptBytes int) {
+	var records []models.IngestError
+	now := time.Now().UTC()
+	for i, msg := range msgs {
+		if errs[i] != nil {
+			records = append(records, ingestErrorRecord(msg, errs[i], decoder, excerptBytes, now))
+		}
+	}
+	if len(records) == 0 {
+		return
+	}
+	if err := store.RecordIngestErrors(ctx, records); err != nil {
+		log.Warn("record ingest errors", slog.Any("err", err), slog.Int("count", len(records)))
+	}
+}
+
+func ingestErrorRecord(msg kafka.Message, err error, decoder messageDecoder, excerptBytes int, now time.Time) models.IngestError {
+	// The source is only known when the payload decodes.
+	source := "unknown"
+	if payload, decodeErr := decoder.decode(msg); decodeErr == nil && strings.TrimSpace(payload.Source) != "" {
+		source = strings.TrimSpace(payload.Source)
+	}
+
+	excerpt := msg.Value
+	if len(excerpt) > excerptBytes {
+		excerpt = excerpt[:excerptBytes]
+	}
+	return models.IngestError{
+		Topic:      msg.Topic,
+		Partition:  msg.Partition,
+		Offset:     msg.Offset,
+		Source:     source,
+		ErrorClass: errorClass(err),
+		Error:      err.Error(),
+		// A cut through a multi-byte character is dropped.
+		PayloadExcerpt: strings.ToValidUTF8(string(excerpt), ""),
+		Timestamp:      now,
+	}
+}
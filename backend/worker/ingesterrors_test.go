@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+type stubErrorLog struct {
+	records []models.IngestError
+}
+
+func (s *stubErrorLog) RecordIngestErrors(_ context.Context, records []models.IngestError) error {
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func TestRecordIngestErrors(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	msgs := []kafka.Message{
+		{Topic: "news_raw", Partition: 1, Offset: 10, Value: []byte(`{"title":"ok"}`)},
+		{Topic: "news_raw", Partition: 1, Offset: 11, Value: []byte(`{"title":"Тур","source":"rss"`)},
+		{Topic: "news_raw", Partition: 1, Offset: 12, Value: []byte(`{"title":"Горящий тур в Анталью","source":"telegram"}`)},
+	}
+	errs := []error{
+		nil,
+		errInvalidPayload,
+		&elasticsearch.StatusError{Op: "bulk index", Status: 400, Kind: elasticsearch.ErrBadRequest},
+	}
+
+	store := &stubErrorLog{}
+	recordIngestErrors(context.Background(), log, store, testDecoder, msgs, errs, 20)
+
+	require.Len(t, store.records, 2)
+	decode := store.records[0]
+	require.Equal(t, "news_raw", decode.Topic)
+	require.Equal(t, int64(11), decode.Offset)
+	require.Equal(t, "decode", decode.ErrorClass)
+	require.Equal(t, "unknown", decode.Source)
+	require.False(t, decode.Timestamp.IsZero())
+
+	rejected := store.records[1]
+	require.Equal(t, "es_bad_request", rejected.ErrorClass)
+	require.Equal(t, "telegram", rejected.Source)
+	// The excerpt is cut to the limit without splitting a character.
+	require.LessOrEqual(t, len(rejected.PayloadExcerpt), 20)
+	require.True(t, strings.HasPrefix(`{"title":"Горящий`, rejected.PayloadExcerpt))
+
+	store.records = nil
+	recordIngestErrors(context.Background(), log, store, testDecoder, msgs[:1], []error{nil}, 20)
+	require.Empty(t, store.records)
+}
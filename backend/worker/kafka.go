@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaCheckTimeout bounds the startup checks against the Kafka cluster;
+// coordinatorRetry is the wait between lookups of a group coordinator that
+// is still being elected.
+const (
+	kafkaCheckTimeout = 30 * time.Second
+	coordinatorRetry  = time.Second
+)
+
+// kafkaAdmin is the subset of kafka.Client used by the startup checks.
+type kafkaAdmin interface {
+	Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error)
+	FindCoordinator(ctx context.Context, req *kafka.FindCoordinatorRequest) (*kafka.FindCoordinatorResponse, error)
+}
+
+// checkKafka verifies that the brokers are reachable, every topic exists
+// and the consumer group has a coordinator. A misconfigured worker fails at
+// startup with an error naming the setting to fix, instead of retrying
+// FetchMessage forever.
+func checkKafka(ctx context.Context, log *slog.Logger, admin kafkaAdmin, brokers, topics []string, group string, retry time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, kafkaCheckTimeout)
+	defer cancel()
+
+	for _, topic := range topics {
+		if err := checkTopic(ctx, log, admin, brokers, topic); err != nil {
+			return err
+		}
+	}
+
+	coordinator, err := findGroupCoordinator(ctx, admin, group, retry)
+	if err != nil {
+		return fmt.Errorf("no coordinator for consumer group %q, check KAFKA_CONSUMER_GROUP and broker health: %w", group, err)
+	}
+	log.Info("kafka group coordinator found",
+		slog.String("group", group),
+		slog.String("coordinator", fmt.Sprintf("%s:%d", coordinator.Host, coordinator.Port)),
+	)
+	return nil
+}
+
+// checkTopic verifies that topic exists and logs its partition count.
+func checkTopic(ctx context.Context, log *slog.Logger, admin kafkaAdmin, brokers []string, topic string) error {
+	// Metadata requests from kafka.Client never auto-create topics, so a
+	// typo in KAFKA_TOPICS is reported rather than silently created.
+	meta, err := admin.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return fmt.Errorf("cannot reach Kafka brokers %s, check KAFKA_BROKERS: %w", strings.Join(brokers, ","), err)
+	}
+	if len(meta.Topics) == 0 || errors.Is(meta.Topics[0].Error, kafka.UnknownTopicOrPartition) {
+		return fmt.Errorf("topic %q does not exist, check KAFKA_TOPIC and KAFKA_TOPICS or create it (existing topics: %s)", topic, existingTopics(ctx, admin))
+	}
+	if err := meta.Topics[0].Error; err != nil {
+		return fmt.Errorf("read metadata of topic %q: %w", topic, err)
+	}
+
+	partitions := meta.Topics[0].Partitions
+	leaderless := 0
+	for _, p := range partitions {
+		if p.Error != nil || p.Leader.Host == "" {
+			leaderless++
+		}
+	}
+	log.Info("kafka topic found",
+		slog.String("topic", topic),
+		slog.Int("partitions", len(partitions)),
+		slog.Int("brokers", len(meta.Brokers)),
+	)
+	if leaderless > 0 {
+		log.Warn("kafka partitions without a leader, consumption will stall on them",
+			slog.String("topic", topic),
+			slog.Int("partitions", leaderless),
+		)
+	}
+	return nil
+}
+
+// findGroupCoordinator retries temporary errors, which a fresh cluster
+// returns while it creates its offsets topic on the first group lookup.
+func findGroupCoordinator(ctx context.Context, admin kafkaAdmin, group string, retry time.Duration) (*kafka.FindCoordinatorResponseCoordinator, error) {
+	for {
+		res, err := admin.FindCoordinator(ctx, &kafka.FindCoordinatorRequest{
+			Key:     group,
+			KeyType: kafka.CoordinatorKeyTypeConsumer,
+		})
+		if err == nil {
+			err = res.Error
+		}
+		if err == nil {
+			if res.Coordinator == nil {
+				return nil, errors.New("empty coordinator response")
+			}
+			return res.Coordinator, nil
+		}
+
+		var kerr kafka.Error
+		if !errors.As(err, &kerr) || !kerr.Temporary() {
+			return nil, err
+		}
+		select {
+		case <-time.After(retry):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		}
+	}
+}
+
+// existingTopics lists the cluster's non-internal topics for error messages.
+func existingTopics(ctx context.Context, admin kafkaAdmin) string {
+	meta, err := admin.Metadata(ctx, &kafka.MetadataRequest{})
+	if err != nil {
+		return "unknown"
+	}
+	names := make([]string, 0, len(meta.Topics))
+	for _, t := range meta.Topics {
+		if !t.Internal {
+			names = append(names, t.Name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+type stubAdmin struct {
+	topics       []kafka.Topic
+	metadataErr  error
+	coordinators []*kafka.FindCoordinatorResponse
+}
+
+func (s *stubAdmin) Metadata(_ context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error) {
+	if s.metadataErr != nil {
+		return nil, s.metadataErr
+	}
+	if len(req.Topics) == 0 {
+		return &kafka.MetadataResponse{Topics: s.topics}, nil
+	}
+	for _, t := range s.topics {
+		if t.Name == req.Topics[0] {
+			return &kafka.MetadataResponse{Topics: []kafka.Topic{t}}, nil
+		}
+	}
+	return &kafka.MetadataResponse{Topics: []kafka.Topic{{Name: req.Topics[0], Error: kafka.UnknownTopicOrPartition}}}, nil
+}
+
+func (s *stubAdmin) FindCoordinator(context.Context, *kafka.FindCoordinatorRequest) (*kafka.FindCoordinatorResponse, error) {
+	res := s.coordinators[0]
+	if len(s.coordinators) > 1 {
+		s.coordinators = s.coordinators[1:]
+	}
+	return res, nil
+}
+
+func TestCheckKafka(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	brokers := []string{"kafka:9092"}
+	admin := &stubAdmin{
+		topics: []kafka.Topic{
+			{Name: "news_raw", Partitions: []kafka.Partition{{ID: 0, Leader: kafka.Broker{Host: "kafka", Port: 9092}}}},
+			{Name: "__consumer_offsets", Internal: true},
+			{Name: "news_raw_dlq"},
+		},
+		coordinators: []*kafka.FindCoordinatorResponse{
+			{Error: kafka.GroupCoordinatorNotAvailable},
+			{Coordinator: &kafka.FindCoordinatorResponseCoordinator{Host: "kafka", Port: 9092}},
+		},
+	}
+	require.NoError(t, checkKafka(context.Background(), log, admin, brokers, []string{"news_raw"}, "news-worker", time.Millisecond))
+
+	// Every topic is checked, not only the first.
+	err := checkKafka(context.Background(), log, admin, brokers, []string{"news_raw", "news_rwa"}, "news-worker", time.Millisecond)
+	require.ErrorContains(t, err, "KAFKA_TOPIC")
+	require.ErrorContains(t, err, "existing topics: news_raw, news_raw_dlq")
+
+	admin.metadataErr = errors.New("dial tcp: connection refused")
+	err = checkKafka(context.Background(), log, admin, brokers, []string{"news_raw"}, "news-worker", time.Millisecond)
+	require.ErrorContains(t, err, "KAFKA_BROKERS")
+
+	admin.metadataErr = nil
+	admin.coordinators = []*kafka.FindCoordinatorResponse{{Error: kafka.InvalidGroupId}}
+	err = checkKafka(context.Background(), log, admin, brokers, []string{"news_raw"}, "", time.Millisecond)
+	require.ErrorContains(t, err, "KAFKA_CONSUMER_GROUP")
+}
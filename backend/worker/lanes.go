@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// lanes processes messages concurrently while keeping each partition in
+// order. Every partition is hashed to one lane, which batches, processes
+// and commits its messages sequentially, so offsets of a partition are
+// only ever committed by one goroutine, in order, after everything before
+// them was handled. A slow lane never has its offsets passed by a fast one.
+type lanes struct {
+	inputs  []chan kafka.Message
+	wg      sync.WaitGroup
+	pending atomic.Int64
+}
+
+// startLanes starts n lanes. Each one calls flush with up to batchSize
+// messages, or with fewer once commitInterval has passed since the first
+// of them arrived, so quiet partitions are not held back. flush must not
+// keep the slice.
+func startLanes(n, batchSize int, commitInterval time.Duration, flush func([]kafka.Message)) *lanes {
+	l := &lanes{inputs: make([]chan kafka.Message, n)}
+	for i := range l.inputs {
+		// The buffer lets the fetch loop run a batch ahead of a busy lane.
+		in := make(chan kafka.Message, batchSize)
+		l.inputs[i] = in
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			l.run(in, batchSize, commitInterval, flush)
+		}()
+	}
+	return l
+}
+
+func (l *lanes) run(in <-chan kafka.Message, batchSize int, commitInterval time.Duration, flush func([]kafka.Message)) {
+	batch := make([]kafka.Message, 0, batchSize)
+	var deadline <-chan time.Time
+	process := func() {
+		flush(batch)
+		l.pending.Add(-int64(len(batch)))
+		batch = batch[:0]
+		deadline = nil
+	}
+
+	for {
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				if len(batch) > 0 {
+					process()
+				}
+				return
+			}
+			if len(batch) == 0 {
+				deadline = time.After(commitInterval)
+			}
+			batch = append(batch, msg)
+			if len(batch) >= batchSize {
+				process()
+			}
+		case <-deadline:
+			process()
+		}
+	}
+}
+
+// dispatch hands msg to the lane of its partition, waiting while that lane
+// is full. It reports false when ctx ends first.
+func (l *lanes) dispatch(ctx context.Context, msg kafka.Message) bool {
+	in := l.inputs[laneOf(msg, len(l.inputs))]
+	l.pending.Add(1)
+	select {
+	case in <- msg:
+		return true
+	case <-ctx.Done():
+		l.pending.Add(-1)
+		return false
+	}
+}
+
+// close stops accepting messages and waits until every lane has flushed
+// what it holds.
+func (l *lanes) close() {
+	for _, in := range l.inputs {
+		close(in)
+	}
+	l.wg.Wait()
+}
+
+func laneOf(msg kafka.Message, n int) int {
+	if n == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(msg.Topic + "/" + strconv.Itoa(msg.Partition)))
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLanesKeepPartitionOrder(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		processed = map[int][]int64{}
+		batches   = map[int]int{}
+	)
+	workers := startLanes(4, 5, time.Hour, func(batch []kafka.Message) {
+		// Uneven processing times let lanes overtake each other.
+		time.Sleep(time.Duration(rand.IntN(3)) * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, msg := range batch {
+			processed[msg.Partition] = append(processed[msg.Partition], msg.Offset)
+		}
+		batches[batch[0].Partition]++
+	})
+
+	for offset := range int64(50) {
+		for partition := range 8 {
+			require.True(t, workers.dispatch(context.Background(), kafka.Message{Topic: "news_raw", Partition: partition, Offset: offset}))
+		}
+	}
+	workers.close()
+
+	require.Len(t, processed, 8)
+	for partition, offsets := range processed {
+		require.Len(t, offsets, 50, "partition %d", partition)
+		for i, offset := range offsets {
+			require.Equal(t, int64(i), offset, "partition %d out of order", partition)
+		}
+	}
+	require.Zero(t, workers.pending.Load())
+}
+
+func TestLanesFlushPartialBatches(t *testing.T) {
+	flushed := make(chan int, 2)
+	workers := startLanes(1, 10, 20*time.Millisecond, func(batch []kafka.Message) {
+		flushed <- len(batch)
+	})
+
+	workers.dispatch(context.Background(), kafka.Message{Offset: 1})
+	workers.dispatch(context.Background(), kafka.Message{Offset: 2})
+	select {
+	case n := <-flushed:
+		require.Equal(t, 2, n)
+	case <-time.After(time.Second):
+		t.Fatal("partial batch not flushed after the commit interval")
+	}
+
+	// Closing flushes what is left without waiting for the interval.
+	workers.dispatch(context.Background(), kafka.Message{Offset: 3})
+	closeWithin(t, workers)
+	require.Equal(t, 1, <-flushed)
+}
+
+func TestLanesDispatchGivesUpWhenCancelled(t *testing.T) {
+	release := make(chan struct{})
+	workers := startLanes(1, 1, time.Hour, func([]kafka.Message) { <-release })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// The lane blocks on the first message and the buffer takes one more.
+	require.True(t, workers.dispatch(context.Background(), kafka.Message{Offset: 1}))
+	require.True(t, workers.dispatch(context.Background(), kafka.Message{Offset: 2}))
+	require.Eventually(t, func() bool {
+		return !workers.dispatch(ctx, kafka.Message{Offset: 3})
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	workers.close()
+}
+
+func closeWithin(t *testing.T, workers *lanes) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		workers.close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lanes did not close")
+	}
+}
@@ -4,34 +4,36 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
 
+	"github.com/DeafMist/hot-tour-radar/backend/internal/chaos"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/dedupe"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/destinations"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/eventbus"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/logger"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/notify"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/telegram"
 )
 
-type rawNews struct {
-	Title     string `json:"title"`
-	Text      string `json:"text"`
-	Timestamp string `json:"timestamp"`
-	Source    string `json:"source"`
-}
-
 type newsIndexer interface {
-	IndexNews(ctx context.Context, doc models.NewsDocument) error
+	BulkIndexNews(ctx context.Context, items []elasticsearch.BulkItem, opts ...elasticsearch.IndexOption) ([]error, error)
+	ExpireOriginals(ctx context.Context, notice models.NewsDocument, original elasticsearch.OriginalQuery) ([]string, error)
 }
 
 func main() {
@@ -42,20 +44,150 @@ func main() {
 		os.Exit(1)
 	}
 
-	esClient, err := elasticsearch.New(cfg.ElasticsearchAddr, cfg.ElasticsearchIndex, log)
+	faults, err := chaos.FromEnv()
+	if err != nil {
+		log.Error("load chaos config", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if faults.Active() {
+		log.Warn("chaos mode active, injecting faults", slog.Any("config", faults))
+	}
+
+	// A shadow worker indexes into its own index, or only reads the live
+	// one for readiness when it merely logs its writes.
+	shadow := cfg.Mode == "shadow"
+	dryRun := shadow && cfg.ShadowIndex == ""
+	index := cfg.ElasticsearchIndex
+	if shadow && !dryRun {
+		index = cfg.ShadowIndex
+	}
+	if shadow {
+		// Fan-out, audits and alerts reach shared topics and people; they
+		// stay with the live worker.
+		cfg.FanoutMode, cfg.AuditMode = "off", "off"
+		cfg.AlertDLQRate, cfg.AlertIdle, cfg.AlertLag = 0, 0, 0
+	}
+
+	esClient, err := elasticsearch.New(cfg.ElasticsearchAddr, index, log,
+		elasticsearch.SignAWS(cfg.ElasticsearchAWSRegion, cfg.ElasticsearchAWSService),
+		elasticsearch.LogSlowQueries(cfg.ElasticsearchSlowThreshold, log),
+		elasticsearch.WithTransport(faults.WrapTransport))
 	if err != nil {
 		log.Error("init elasticsearch", slog.Any("err", err))
 		os.Exit(1)
 	}
+	if cfg.ElasticsearchDailyIndices {
+		esClient.EnableDailyIndices()
+	}
 
 	cache := dedupe.NewCache(cfg.DedupeCapacity, cfg.DedupeTTL)
 
+	sourceGroups, err := processing.ParseSourceGroups(cfg.SourceGroups, cfg.SourceGroupWindow)
+	if err != nil {
+		log.Error("parse WORKER_SOURCE_GROUPS", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	build := func(taxonomy *destinations.Taxonomy, titleRules *processing.TitleRules, rules *processing.Rules) (*processing.Pipeline, error) {
+		return processing.BuildPipeline(cfg.Pipeline, processing.Options{
+			KeywordLimit:            cfg.KeywordLimit,
+			KeywordMinLength:        cfg.KeywordMinLength,
+			KeywordTokensPerKeyword: cfg.KeywordTokensPerKeyword,
+			KeywordMinLimit:         cfg.KeywordMinLimit,
+			KeywordMaxLimit:         cfg.KeywordMaxLimit,
+			KeywordStemming:         cfg.KeywordStemming,
+			RepostSources:           cfg.RepostSources,
+			RepostWindow:            cfg.RepostWindow,
+			SoldOutWindow:           cfg.SoldOutWindow,
+			PIIKinds:                cfg.PIIKinds,
+			Destinations:            taxonomy,
+			TitleRules:              titleRules,
+			Rules:                   rules,
+			SourceGroups:            sourceGroups,
+		})
+	}
+
+	// The pipeline is swapped by data reloads while batches are processed.
+	var pipeline atomic.Pointer[processing.Pipeline]
+	var data *dataReloader
+	if cfg.DataBucket != "" {
+		data, err = newDataReloader(log, cfg, build, &pipeline)
+		if err != nil {
+			log.Error("init data store", slog.Any("err", err))
+			os.Exit(1)
+		}
+		loadCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		_, err = data.reload(loadCtx)
+		cancel()
+		if err != nil {
+			log.Error("load data from object storage", slog.String("bucket", cfg.DataBucket), slog.Any("err", err))
+			os.Exit(1)
+		}
+	} else {
+		taxonomy, err := destinations.Load(cfg.DestinationsFile)
+		if err != nil {
+			log.Error("load destination taxonomy", slog.Any("err", err))
+			os.Exit(1)
+		}
+
+		titleRules, err := processing.LoadTitleRules(cfg.TitleRulesFile)
+		if err != nil {
+			log.Error("load title rules", slog.Any("err", err))
+			os.Exit(1)
+		}
+
+		rules, err := processing.LoadRules(cfg.RulesFile)
+		if err != nil {
+			log.Error("load ingestion rules", slog.Any("err", err))
+			os.Exit(1)
+		}
+
+		built, err := build(taxonomy, titleRules, rules)
+		if err != nil {
+			log.Error("build processing pipeline", slog.Any("err", err))
+			os.Exit(1)
+		}
+		pipeline.Store(built)
+	}
+	formats, err := parseTopicFormats(cfg.TopicFormats, cfg.KafkaTopics)
+	if err != nil {
+		log.Error("parse KAFKA_TOPIC_FORMATS", slog.Any("err", err))
+		os.Exit(1)
+	}
+	decoder := messageDecoder{maxBytes: cfg.MaxMessageBytes, formats: formats}
+
+	admin := &kafka.Client{Addr: kafka.TCP(cfg.KafkaBrokers...), Timeout: kafkaCheckTimeout}
+	if err := checkKafka(context.Background(), log, admin, cfg.KafkaBrokers, cfg.KafkaTopics, cfg.KafkaConsumer, coordinatorRetry); err != nil {
+		log.Error("kafka startup check failed", slog.Any("err", err))
+		os.Exit(1)
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
 
+	groupLog := &kafkaGroupLog{client: admin, brokers: cfg.KafkaBrokers, group: cfg.KafkaConsumer}
+	// Probes are served while the index is still being waited for, so a
+	// slow cluster start does not fail liveness.
+	if cfg.HealthAddr != "" {
+		health := &healthServer{log: log, index: esClient, kafka: admin, offsets: groupLog, topics: cfg.KafkaTopics}
+		go health.serve(ctx, cfg.HealthAddr)
+	}
+
+	if !dryRun {
+		if err := esClient.WaitForIndex(ctx); err != nil {
+			log.Error("ensure elasticsearch index", slog.Any("err", err))
+			os.Exit(1)
+		}
+	}
+	if !shadow {
+		if err := esClient.EnsureIngestErrorsIndex(ctx); err != nil {
+			log.Warn("ensure ingest errors index, error records may be unsearchable", slog.Any("err", err))
+		}
+	}
+
 	reader := kafka.NewReader(kafka.ReaderConfig{
 		Brokers:        cfg.KafkaBrokers,
-		Topic:          cfg.KafkaTopic,
+		GroupTopics:    cfg.KafkaTopics,
 		GroupID:        cfg.KafkaConsumer,
 		QueueCapacity:  cfg.BatchSize,
 		MinBytes:       1e3,
@@ -64,112 +196,301 @@ func main() {
 	})
 	defer reader.Close()
 
-	dlqWriter := kafka.NewWriter(kafka.WriterConfig{
-		Brokers:     cfg.KafkaBrokers,
-		Topic:       cfg.KafkaTopic + "_dlq",
-		MaxAttempts: 3,
-	})
+	// Failed messages go to <topic>_dlq of the topic they were read from,
+	// so replays keep their payload format.
+	dlqWriter := &kafka.Writer{
+		Addr:                   kafka.TCP(cfg.KafkaBrokers...),
+		MaxAttempts:            3,
+		AllowAutoTopicCreation: true,
+	}
 	defer dlqWriter.Close()
+	dlq := faults.WrapWriter(dlqWriter)
+	if shadow {
+		dlq = shadowDLQ{log: log}
+	}
+
+	var indexer newsIndexer = esClient
+	if cfg.FanoutMode != "off" || cfg.EventsTopic != "" {
+		busWriter := eventbus.NewWriter(cfg.KafkaBrokers)
+		defer busWriter.Close()
+		events := faults.WrapWriter(busWriter)
+
+		if cfg.FanoutMode != "off" {
+			indexer = &fanoutIndexer{
+				newsIndexer: indexer,
+				writer:      events,
+				mode:        cfg.FanoutMode,
+				topic:       cfg.FanoutTopic,
+				log:         log,
+			}
+		}
+		if cfg.EventsTopic != "" {
+			indexer = &flaggingIndexer{
+				newsIndexer: indexer,
+				events:      eventbus.NewPublisher(events, cfg.EventsTopic),
+				log:         log,
+			}
+		}
+	}
+
+	switch {
+	case dryRun:
+		indexer = dryRunIndexer{log: log}
+	case shadow:
+		indexer = shadowIndexer{newsIndexer: esClient}
+	}
+
+	if cfg.AuditMode != "off" {
+		audit := &auditor{
+			log:      log,
+			source:   groupLog,
+			docs:     esClient,
+			decoder:  decoder,
+			pipeline: &pipeline,
+			cache:    cache,
+			topics:   cfg.KafkaTopics,
+			sample:   cfg.AuditSample,
+			maxAge:   cfg.AuditMaxAge,
+		}
+		if cfg.AuditMode == "reemit" {
+			// The DLQ writer has no fixed topic, so it can write back to the source topics.
+			audit.reemit = dlq
+		}
+		expvar.Publish("consistency_audit", expvar.Func(audit.vars))
+		go audit.run(ctx, cfg.AuditInterval)
+	}
+
+	if notifier, rules := alertSetup(cfg, groupLog); len(rules) > 0 {
+		instance, _ := os.Hostname()
+		alerts := &alertManager{log: log, notifier: notifier, rules: rules, repeat: cfg.AlertRepeat, instance: instance}
+		go alerts.run(ctx, cfg.AlertInterval)
+	}
+
+	if cfg.ProgressInterval > 0 {
+		progress := &progressReporter{log: log, offsets: groupLog, topics: cfg.KafkaTopics, counter: processedMessages}
+		expvar.Publish("consumer_progress", expvar.Func(progress.vars))
+		go progress.run(ctx, cfg.ProgressInterval)
+	}
+
+	if cfg.ControlToken != "" {
+		control := &controlServer{log: log, token: cfg.ControlToken, cache: cache, data: data}
+		go control.serve(ctx, cfg.ControlAddr)
+	} else {
+		log.Info("control endpoints disabled, set WORKER_CONTROL_TOKEN to enable")
+	}
 
 	log.Info("worker started",
-		slog.String("topic", cfg.KafkaTopic),
+		slog.String("mode", cfg.Mode),
+		slog.String("index", index),
+		slog.Bool("dry_run", dryRun),
+		slog.Any("topics", cfg.KafkaTopics),
 		slog.String("group", cfg.KafkaConsumer),
-		slog.String("dlq_topic", cfg.KafkaTopic+"_dlq"),
+		slog.Any("pipeline", pipeline.Load().Stages()),
+		slog.String("fanout_mode", cfg.FanoutMode),
+		slog.String("audit_mode", cfg.AuditMode),
+		slog.Int("concurrency", cfg.Concurrency),
 	)
 
+	workCtx, cancelWork := drainContext(ctx, cfg.DrainTimeout)
+	defer cancelWork()
+
+	committer := newOffsetCommitter(log, reader, dlq)
+	go committer.run(workCtx, cfg.CommitInterval)
+
+	workers := startLanes(cfg.Concurrency, cfg.BatchSize, cfg.CommitInterval, func(batch []kafka.Message) {
+		errs := processBatch(workCtx, log, indexer, indexBackoff, cache, decoder, pipeline.Load(), batch)
+		if !shadow {
+			recordIngestErrors(workCtx, log, esClient, decoder, batch, errs, cfg.ErrorExcerptBytes)
+		}
+		committer.handle(workCtx, batch, errs)
+	})
+
+	consume(ctx, workCtx, log, reader, workers, committer)
+}
+
+// drainContext returns the context fetched messages are processed under.
+// A signal only stops fetching: messages already fetched are still
+// indexed, dead-lettered and committed under the returned context, which
+// outlives ctx by at most timeout.
+func drainContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	context.AfterFunc(ctx, func() {
+		time.AfterFunc(timeout, cancelWork)
+	})
+	return workCtx, cancelWork
+}
+
+type messageFetcher interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+}
+
+// consume hands fetched messages to the lanes until ctx ends, then drains.
+func consume(ctx, workCtx context.Context, log *slog.Logger, reader messageFetcher, workers *lanes, committer *offsetCommitter) {
 	for {
 		msg, err := reader.FetchMessage(ctx)
 		if err != nil {
-			if errors.Is(err, context.Canceled) {
-				log.Info("context canceled, stopping")
+			if ctx.Err() != nil {
+				drain(workCtx, log, workers, committer)
 				return
 			}
 			log.Error("fetch message", slog.Any("err", err))
 			continue
 		}
+		// Fetched messages are still handed over during the drain.
+		if !workers.dispatch(workCtx, msg) {
+			drain(workCtx, log, workers, committer)
+			return
+		}
+	}
+}
+
+// alertSetup builds the configured alert rules and their notification
+// channels; no rules means alerting is off.
+func alertSetup(cfg *config.Worker, offsets groupOffsets) (notify.Notifier, []alertRule) {
+	var rules []alertRule
+	if cfg.AlertDLQRate > 0 {
+		rules = append(rules, dlqRateRule(deadLettered, cfg.AlertDLQRate, cfg.AlertWindow))
+	}
+	if cfg.AlertIdle > 0 {
+		rules = append(rules, idleRule(indexedDocuments, cfg.AlertIdle))
+	}
+	if cfg.AlertLag > 0 {
+		rules = append(rules, lagRule(offsets, cfg.KafkaTopics, cfg.AlertLag))
+	}
+
+	var notifiers notify.Multi
+	if len(cfg.AlertTelegramChats) > 0 {
+		chats := make([]int64, 0, len(cfg.AlertTelegramChats))
+		for _, raw := range cfg.AlertTelegramChats {
+			// LoadWorker has validated the chat IDs.
+			id, _ := strconv.ParseInt(raw, 10, 64)
+			chats = append(chats, id)
+		}
+		notifiers = append(notifiers, notify.Telegram{Client: telegram.New(cfg.TelegramToken), ChatIDs: chats})
+	}
+	if cfg.AlertWebhookURL != "" {
+		notifiers = append(notifiers, notify.NewWebhook(cfg.AlertWebhookURL))
+	}
+	return notifiers, rules
+}
 
-		if err := processMessage(ctx, log, esClient, cache, cfg, msg); err != nil {
-			log.Warn("process message failed, sending to DLQ",
-				slog.Any("err", err),
+// drain waits for the lanes to flush their open batches on shutdown and
+// commits what they handled. The reader and writers are closed by main's
+// deferred calls once it returns.
+func drain(workCtx context.Context, log *slog.Logger, workers *lanes, committer *offsetCommitter) {
+	log.Info("shutting down, draining in-flight messages", slog.Int64("pending", workers.pending.Load()))
+	workers.close()
+	committer.commit(workCtx)
+	if workCtx.Err() != nil {
+		log.Warn("drain timeout exceeded, uncommitted messages will be redelivered")
+		return
+	}
+	log.Info("drained, stopping")
+}
+
+// sendToDLQ writes msg to the dead-letter topic with error context,
+// retrying with exponential backoff. It reports whether the write succeeded.
+func sendToDLQ(ctx context.Context, log *slog.Logger, dlqWriter messageWriter, msg kafka.Message, err error) bool {
+	dlqMsg := dlqMessage(msg, err)
+
+	for attempt := range 5 {
+		dlqErr := dlqWriter.WriteMessages(ctx, dlqMsg)
+		if dlqErr == nil {
+			deadLettered.Add(1)
+			log.Info("message sent to DLQ",
+				slog.String("topic", dlqMsg.Topic),
 				slog.Int("partition", msg.Partition),
 				slog.Int64("offset", msg.Offset),
+				slog.Int("attempt", attempt+1),
 			)
+			return true
+		}
 
-			// Send to DLQ with error context, retry with backoff
-			dlqMsg := kafka.Message{
-				Value: msg.Value,
-				Headers: append(msg.Headers,
-					kafka.Header{Key: "original_partition", Value: []byte(fmt.Sprintf("%d", msg.Partition))},
-					kafka.Header{Key: "original_offset", Value: []byte(fmt.Sprintf("%d", msg.Offset))},
-					kafka.Header{Key: "error", Value: []byte(err.Error())},
-					kafka.Header{Key: "timestamp", Value: []byte(time.Now().UTC().Format(time.RFC3339))},
-				),
-			}
-
-			// Retry DLQ write with exponential backoff
-			dlqSuccess := false
-			for attempt := range 5 {
-				if dlqErr := dlqWriter.WriteMessages(ctx, dlqMsg); dlqErr == nil {
-					dlqSuccess = true
-					log.Info("message sent to DLQ",
-						slog.Int("partition", msg.Partition),
-						slog.Int64("offset", msg.Offset),
-						slog.Int("attempt", attempt+1),
-					)
-					break
-				} else {
-					backoff := time.Duration(1<<uint(attempt)) * time.Second
-					log.Warn("DLQ write failed, retrying",
-						slog.Any("err", dlqErr),
-						slog.Int("attempt", attempt+1),
-						slog.Duration("backoff", backoff),
-					)
-					select {
-					case <-time.After(backoff):
-						// Continue to next attempt
-					case <-ctx.Done():
-						log.Info("context canceled during DLQ retry")
-						return
-					}
-				}
-			}
-
-			// Only commit if DLQ write succeeded; otherwise skip commit and reprocess on restart
-			if dlqSuccess {
-				if err := reader.CommitMessages(ctx, msg); err != nil {
-					log.Error("commit failed message to dlq", slog.Any("err", err))
-				}
-			} else {
-				log.Error("DLQ write exhausted retries, message may be lost if later messages commit",
-					slog.Int("partition", msg.Partition),
-					slog.Int64("offset", msg.Offset),
-				)
-			}
-			continue
+		backoff := time.Duration(1<<uint(attempt)) * time.Second
+		log.Warn("DLQ write failed, retrying",
+			slog.Any("err", dlqErr),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("backoff", backoff),
+		)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return false
 		}
+	}
+	return false
+}
+
+// dlqMessage is the dead-letter copy of msg, which failed with err.
+func dlqMessage(msg kafka.Message, err error) kafka.Message {
+	return kafka.Message{
+		Topic: msg.Topic + "_dlq",
+		Value: msg.Value,
+		Headers: append(msg.Headers,
+			kafka.Header{Key: "original_partition", Value: []byte(fmt.Sprintf("%d", msg.Partition))},
+			kafka.Header{Key: "original_offset", Value: []byte(fmt.Sprintf("%d", msg.Offset))},
+			kafka.Header{Key: "error", Value: []byte(err.Error())},
+			kafka.Header{Key: "error_class", Value: []byte(errorClass(err))},
+			kafka.Header{Key: "timestamp", Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+		),
+	}
+}
 
-		if err := reader.CommitMessages(ctx, msg); err != nil {
-			log.Error("commit message", slog.Any("err", err))
+// errorClass summarizes why a message failed, for the DLQ error_class header.
+func errorClass(err error) string {
+	switch {
+	case errors.Is(err, elasticsearch.ErrBadRequest):
+		return "es_bad_request"
+	case errors.Is(err, elasticsearch.ErrConflict):
+		return "es_conflict"
+	case errors.Is(err, elasticsearch.ErrNotFound):
+		return "es_not_found"
+	case errors.Is(err, elasticsearch.ErrUnavailable):
+		return "es_unavailable"
+	case errors.Is(err, errSchema):
+		return "schema"
+	default:
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, errInvalidPayload) {
+			return "decode"
 		}
+		return "processing"
 	}
 }
 
-func processMessage(ctx context.Context, log *slog.Logger, esClient newsIndexer, cache *dedupe.Cache, cfg *config.Worker, msg kafka.Message) error {
-	var payload rawNews
-	if err := json.Unmarshal(msg.Value, &payload); err != nil {
-		return err
+// preparedMessage is a decoded message after the processing pipeline.
+type preparedMessage struct {
+	doc       models.NewsDocument
+	repost    bool
+	dedupeKey string
+	soldOut   *processing.SoldOutNotice
+	// dropped is set when an operator rule discarded the message; doc
+	// holds what the pipeline had made of it by then.
+	dropped bool
+	// index overrides the news index, as routed by an operator rule.
+	index string
+	rules []string
+}
+
+// maxIdempotencyKeyBytes is the longest document ID Elasticsearch accepts.
+const maxIdempotencyKeyBytes = 512
+
+func prepareMessage(msg kafka.Message, decoder messageDecoder, pipeline *processing.Pipeline) (preparedMessage, error) {
+	idempotencyKey := strings.TrimSpace(headerValue(msg, "idempotency_key"))
+	if len(idempotencyKey) > maxIdempotencyKeyBytes {
+		return preparedMessage{}, fmt.Errorf("%w: idempotency_key exceeds %d bytes", errInvalidPayload, maxIdempotencyKeyBytes)
+	}
+
+	payload, err := decoder.decode(msg)
+	if err != nil {
+		return preparedMessage{}, err
 	}
 
 	title := strings.TrimSpace(payload.Title)
 	text := strings.TrimSpace(payload.Text)
-	urls := processing.ExtractURLs(text)
 	if title == "" && text == "" {
-		return errors.New("empty payload")
-	}
-
-	// Generate title from text if missing
-	if title == "" && text != "" {
-		title = processing.GenerateTitleFromText(text, 10)
+		return preparedMessage{}, errors.New("empty payload")
 	}
 
 	ts := parseTimestamp(payload.Timestamp)
@@ -177,40 +498,57 @@ func processMessage(ctx context.Context, log *slog.Logger, esClient newsIndexer,
 		ts = time.Now().UTC()
 	}
 
-	// Clean text for keyword extraction (remove URLs, punctuation, etc.)
-	cleanedText := processing.CleanText(text)
-	keywords := processing.ExtractKeywords(title+" "+cleanedText, cfg.KeywordLimit, cfg.KeywordMinLength)
 	source := strings.TrimSpace(payload.Source)
 	if source == "" {
 		source = "unknown"
 	}
 
-	doc := models.NewsDocument{
-		ID:        processing.BuildDocumentID(title, cleanedText, ts),
-		Title:     title,
-		Text:      text, // Original text with all punctuation and URLs
-		Timestamp: ts,
-		Keywords:  keywords,
-		Source:    source,
-		URLs:      urls,
+	item := &processing.Item{
+		Doc: models.NewsDocument{
+			Title:     title,
+			Text:      text, // Original text with all punctuation and URLs
+			Timestamp: ts,
+			Source:    source,
+		},
+		ReplyTo: strings.TrimSpace(payload.ReplyTo),
+	}
+	if err := pipeline.Run(item); err != nil {
+		return preparedMessage{}, err
+	}
+	if item.Drop {
+		return preparedMessage{doc: item.Doc, dropped: true, rules: item.Rules}, nil
+	}
+	doc := item.Doc
+	doc.IndexedAt = time.Now().UTC()
+
+	// A producer-supplied key identifies the message exactly: redeliveries
+	// and edited copies map to one document and are never counted as reposts.
+	if idempotencyKey != "" {
+		doc.ID = idempotencyKey
+		return preparedMessage{doc: doc, dedupeKey: idempotencyKey, soldOut: item.SoldOut, index: item.Index, rules: item.Rules}, nil
 	}
 
 	if doc.ID == "" {
 		doc.ID = uuid.NewString()
 	}
 
-	if cache.IsSeen(doc.ID) {
-		log.Debug("duplicate news", slog.String("id", doc.ID))
-		return nil
+	dedupeKey := item.DedupeKey
+	if dedupeKey == "" {
+		dedupeKey = doc.ID
 	}
 
-	if err := esClient.IndexNews(ctx, doc); err != nil {
-		return err
-	}
+	return preparedMessage{doc: doc, repost: item.Repost, dedupeKey: dedupeKey, soldOut: item.SoldOut, index: item.Index, rules: item.Rules}, nil
+}
 
-	cache.MarkSeen(doc.ID)
-	log.Info("indexed news", slog.String("id", doc.ID), slog.String("title", doc.Title))
-	return nil
+// headerValue returns the value of the last header with the given key.
+func headerValue(msg kafka.Message, key string) string {
+	value := ""
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			value = string(h.Value)
+		}
+	}
+	return value
 }
 
 func parseTimestamp(raw string) time.Time {
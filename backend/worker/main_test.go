@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,16 +15,89 @@ import (
 
 	"github.com/DeafMist/hot-tour-radar/backend/internal/config"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/dedupe"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
 	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
 )
 
 type stubIndexer struct {
-	docs []models.NewsDocument
+	docs    []models.NewsDocument
+	reposts []models.NewsDocument
+	// failures are returned, in order, by the next BulkIndexNews calls.
+	failures []error
+	// rejected holds per-item errors, in order, for documents with the given title.
+	rejected map[string][]error
+	// optCounts records how many options each BulkIndexNews call received.
+	optCounts []int
+	// calls records how many items each BulkIndexNews call carried.
+	calls []int
+	// routed records the index of items routed away from the news index, by title.
+	routed map[string]string
+	// expired records the sold-out notices passed to ExpireOriginals.
+	expired []elasticsearch.OriginalQuery
+	// expiredIDs is returned by ExpireOriginals as the offers it expired.
+	expiredIDs []string
 }
 
-func (s *stubIndexer) IndexNews(_ context.Context, doc models.NewsDocument) error {
-	s.docs = append(s.docs, doc)
-	return nil
+func (s *stubIndexer) BulkIndexNews(_ context.Context, items []elasticsearch.BulkItem, opts ...elasticsearch.IndexOption) ([]error, error) {
+	s.optCounts = append(s.optCounts, len(opts))
+	s.calls = append(s.calls, len(items))
+	if len(s.failures) > 0 {
+		err := s.failures[0]
+		s.failures = s.failures[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	errs := make([]error, len(items))
+	for i, item := range items {
+		if pending := s.rejected[item.Doc.Title]; len(pending) > 0 {
+			errs[i] = pending[0]
+			s.rejected[item.Doc.Title] = pending[1:]
+			continue
+		}
+		if item.Index != "" {
+			if s.routed == nil {
+				s.routed = map[string]string{}
+			}
+			s.routed[item.Doc.Title] = item.Index
+		}
+		if item.Repost {
+			s.reposts = append(s.reposts, item.Doc)
+		} else {
+			s.docs = append(s.docs, item.Doc)
+		}
+	}
+	return errs, nil
+}
+
+func (s *stubIndexer) ExpireOriginals(_ context.Context, _ models.NewsDocument, original elasticsearch.OriginalQuery) ([]string, error) {
+	s.expired = append(s.expired, original)
+	return s.expiredIDs, nil
+}
+
+// testDecoder decodes canonical news payloads up to the size limit of the
+// default configuration.
+var testDecoder = messageDecoder{maxBytes: defaultConfig().MaxMessageBytes}
+
+// defaultConfig is the worker configuration with nothing set.
+func defaultConfig() *config.Worker {
+	cfg, err := config.LoadWorker()
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+func newTestPipeline(t *testing.T, cfg *config.Worker) *processing.Pipeline {
+	t.Helper()
+	pipeline, err := processing.BuildPipeline(cfg.Pipeline, processing.Options{
+		KeywordLimit:     cfg.KeywordLimit,
+		KeywordMinLength: cfg.KeywordMinLength,
+	})
+	require.NoError(t, err)
+	return pipeline
 }
 
 func TestProcessMessageIndexesDocument(t *testing.T) {
@@ -38,7 +113,7 @@ func TestProcessMessageIndexesDocument(t *testing.T) {
 		KeywordMinLength: 3,
 	}
 
-	payload := rawNews{
+	payload := models.RawNews{
 		Title:     "Горящий тур",
 		Text:      "<b>Море и солнце</b> ждут",
 		Timestamp: "2024-01-02T15:04:05Z",
@@ -48,8 +123,9 @@ func TestProcessMessageIndexesDocument(t *testing.T) {
 	require.NoError(t, err)
 
 	msg := kafka.Message{Value: data}
+	pipeline := newTestPipeline(t, cfg)
 
-	require.NoError(t, processMessage(context.Background(), log, idx, cache, cfg, msg))
+	require.NoError(t, processBatch(context.Background(), log, idx, time.Millisecond, cache, testDecoder, pipeline, []kafka.Message{msg})[0])
 
 	require.Equal(t, 1, len(idx.docs))
 
@@ -57,8 +133,10 @@ func TestProcessMessageIndexesDocument(t *testing.T) {
 	require.Equal(t, "Горящий тур", doc.Title)
 	require.Equal(t, "rss", doc.Source)
 	require.NotEmpty(t, doc.Keywords)
+	// Retention measures age from ingestion, not from the post's own timestamp.
+	require.WithinDuration(t, time.Now(), doc.IndexedAt, time.Minute)
 
-	require.NoError(t, processMessage(context.Background(), log, idx, cache, cfg, msg))
+	require.NoError(t, processBatch(context.Background(), log, idx, time.Millisecond, cache, testDecoder, pipeline, []kafka.Message{msg})[0])
 	require.Equal(t, 1, len(idx.docs))
 }
 
@@ -98,7 +176,7 @@ func TestProcessMessageGeneratesTitleWhenMissing(t *testing.T) {
 		KeywordMinLength: 3,
 	}
 
-	payload := rawNews{
+	payload := models.RawNews{
 		Title:     "", // Empty title
 		Text:      "Горящий тур в Турцию! Всего 30000 рублей. Вылет завтра.",
 		Timestamp: "2024-01-02T15:04:05Z",
@@ -108,8 +186,9 @@ func TestProcessMessageGeneratesTitleWhenMissing(t *testing.T) {
 	require.NoError(t, err)
 
 	msg := kafka.Message{Value: data}
+	pipeline := newTestPipeline(t, cfg)
 
-	require.NoError(t, processMessage(context.Background(), log, idx, cache, cfg, msg))
+	require.NoError(t, processBatch(context.Background(), log, idx, time.Millisecond, cache, testDecoder, pipeline, []kafka.Message{msg})[0])
 
 	require.Equal(t, 1, len(idx.docs))
 
@@ -119,3 +198,301 @@ func TestProcessMessageGeneratesTitleWhenMissing(t *testing.T) {
 	require.Equal(t, "telegram", doc.Source)
 	require.NotEmpty(t, doc.Keywords)
 }
+
+func TestProcessMessageRespectsPipelineStages(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cache := dedupe.NewCache(100, time.Hour)
+	idx := &stubIndexer{}
+	cfg := &config.Worker{
+		KeywordLimit:     5,
+		KeywordMinLength: 3,
+		Pipeline:         []string{"clean", "id"},
+	}
+
+	payload := models.RawNews{
+		Text:      "Горящий тур в Турцию! https://example.com",
+		Timestamp: "2024-01-02T15:04:05Z",
+	}
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	msg := kafka.Message{Value: data}
+	require.NoError(t, processBatch(context.Background(), log, idx, time.Millisecond, cache, testDecoder, newTestPipeline(t, cfg), []kafka.Message{msg})[0])
+
+	require.Equal(t, 1, len(idx.docs))
+	doc := idx.docs[0]
+	require.Empty(t, doc.Title)
+	require.Empty(t, doc.Keywords)
+	require.Empty(t, doc.URLs)
+	require.NotEmpty(t, doc.ID)
+	require.Equal(t, "unknown", doc.Source)
+}
+
+func TestProcessMessageTracksReposts(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cache := dedupe.NewCache(100, 72*time.Hour)
+	idx := &stubIndexer{}
+	cfg := &config.Worker{
+		KeywordLimit:     5,
+		KeywordMinLength: 3,
+		RepostSources:    []string{"daily-channel"},
+		RepostWindow:     24 * time.Hour,
+	}
+	pipeline, err := processing.BuildPipeline(nil, processing.Options{
+		KeywordLimit:     cfg.KeywordLimit,
+		KeywordMinLength: cfg.KeywordMinLength,
+		RepostSources:    cfg.RepostSources,
+		RepostWindow:     cfg.RepostWindow,
+	})
+	require.NoError(t, err)
+
+	send := func(source, ts string) {
+		data, err := json.Marshal(models.RawNews{
+			Text:      "Турция, 7 ночей, всё включено — 45000 руб.",
+			Timestamp: ts,
+			Source:    source,
+		})
+		require.NoError(t, err)
+		require.NoError(t, processBatch(context.Background(), log, idx, time.Millisecond, cache, testDecoder, pipeline, []kafka.Message{{Value: data}})[0])
+	}
+
+	send("daily-channel", "2024-06-01T08:00:00Z")
+	send("daily-channel", "2024-06-01T09:30:00Z") // same day, dropped by the cache
+	send("daily-channel", "2024-06-02T08:00:00Z")
+	send("other-channel", "2024-06-02T08:00:00Z")
+
+	require.Len(t, idx.reposts, 2)
+	require.Equal(t, idx.reposts[0].ID, idx.reposts[1].ID)
+	require.Equal(t, time.Date(2024, 6, 2, 8, 0, 0, 0, time.UTC), idx.reposts[1].LastSeen)
+	require.Equal(t, 1, idx.reposts[1].SeenCount)
+
+	require.Len(t, idx.docs, 1)
+	require.NotEqual(t, idx.reposts[0].ID, idx.docs[0].ID)
+}
+
+func TestProcessMessageIdempotencyKey(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cache := dedupe.NewCache(100, time.Hour)
+	idx := &stubIndexer{}
+	pipeline, err := processing.BuildPipeline(nil, processing.Options{
+		KeywordLimit:  5,
+		RepostSources: []string{"*"},
+		RepostWindow:  24 * time.Hour,
+	})
+	require.NoError(t, err)
+
+	send := func(key, text string) error {
+		data, err := json.Marshal(models.RawNews{Text: text, Timestamp: "2024-06-01T08:00:00Z", Source: "telegram"})
+		require.NoError(t, err)
+		msg := kafka.Message{Value: data, Headers: []kafka.Header{{Key: "idempotency_key", Value: []byte(key)}}}
+		return processBatch(context.Background(), log, idx, time.Millisecond, cache, testDecoder, pipeline, []kafka.Message{msg})[0]
+	}
+
+	require.NoError(t, send("tg:hottours:42", "Турция, 7 ночей — 45000 руб."))
+	// An edit of the same post is a duplicate, however much the text changed.
+	require.NoError(t, send("tg:hottours:42", "Турция, 7 ночей — 39000 руб. Цена снижена!"))
+	require.Len(t, idx.docs, 1)
+	require.Empty(t, idx.reposts)
+	require.Equal(t, "tg:hottours:42", idx.docs[0].ID)
+
+	err = send(strings.Repeat("k", maxIdempotencyKeyBytes+1), "Египет")
+	require.ErrorIs(t, err, errInvalidPayload)
+}
+
+func TestProcessBatchRetriesUnavailable(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cache := dedupe.NewCache(100, time.Hour)
+	cfg := &config.Worker{KeywordLimit: 5, KeywordMinLength: 3}
+	data, err := json.Marshal(models.RawNews{Title: "Тур", Text: "Море", Timestamp: "2024-01-02T15:04:05Z"})
+	require.NoError(t, err)
+	msg := kafka.Message{Value: data}
+
+	idx := &stubIndexer{failures: []error{&elasticsearch.StatusError{Op: "bulk index", Status: 503, Kind: elasticsearch.ErrUnavailable}}}
+	require.NoError(t, processBatch(context.Background(), log, idx, time.Millisecond, cache, testDecoder, newTestPipeline(t, cfg), []kafka.Message{msg})[0])
+	require.Len(t, idx.docs, 1)
+
+	badRequest := &elasticsearch.StatusError{Op: "bulk index", Status: 400, Kind: elasticsearch.ErrBadRequest}
+	idx = &stubIndexer{failures: []error{badRequest, nil}}
+	err = processBatch(context.Background(), log, idx, time.Millisecond, dedupe.NewCache(100, time.Hour), testDecoder, newTestPipeline(t, cfg), []kafka.Message{msg})[0]
+	require.ErrorIs(t, err, elasticsearch.ErrBadRequest)
+	require.Equal(t, "es_bad_request", errorClass(err))
+	require.Empty(t, idx.docs)
+}
+
+func TestProcessBatchIndexesInOneRequest(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cache := dedupe.NewCache(100, time.Hour)
+	cfg := &config.Worker{KeywordLimit: 5, KeywordMinLength: 3}
+
+	message := func(title string) kafka.Message {
+		data, err := json.Marshal(models.RawNews{Title: title, Text: "Море и солнце", Timestamp: "2024-01-02T15:04:05Z"})
+		require.NoError(t, err)
+		return kafka.Message{Value: data}
+	}
+	msgs := []kafka.Message{
+		message("Турция"),
+		{Value: []byte("{")},
+		message("Египет"),
+		message("Турция"), // duplicate inside the batch
+		message("Кипр"),
+		message("Таиланд"),
+	}
+
+	idx := &stubIndexer{rejected: map[string][]error{
+		"Кипр":    {&elasticsearch.StatusError{Op: "bulk index", Status: 400, Kind: elasticsearch.ErrBadRequest}},
+		"Таиланд": {&elasticsearch.StatusError{Op: "bulk index", Status: 429, Kind: elasticsearch.ErrUnavailable}},
+	}}
+	errs := processBatch(context.Background(), log, idx, time.Millisecond, cache, testDecoder, newTestPipeline(t, cfg), msgs)
+
+	require.NoError(t, errs[0])
+	require.Equal(t, "decode", errorClass(errs[1]))
+	require.NoError(t, errs[2])
+	require.NoError(t, errs[3])
+	require.ErrorIs(t, errs[4], elasticsearch.ErrBadRequest)
+	require.NoError(t, errs[5])
+
+	// One request for the batch and a retry carrying only the throttled item.
+	require.Equal(t, []int{4, 1}, idx.calls)
+	require.Len(t, idx.docs, 3)
+
+	errs = processBatch(context.Background(), log, idx, time.Millisecond, cache, testDecoder, newTestPipeline(t, cfg), msgs[:1])
+	require.NoError(t, errs[0])
+	require.Len(t, idx.calls, 2, "already indexed documents are not sent again")
+}
+
+func TestProcessBatchAppliesRules(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	rules, err := processing.ParseRules([]byte(`
+- {name: drop-ads, when: {text_contains: [реклама]}, then: {drop: true}}
+- {name: quarantine, when: {source: vk}, then: {index: news-quarantine}}
+`))
+	require.NoError(t, err)
+	pipeline, err := processing.BuildPipeline(nil, processing.Options{KeywordLimit: 5, KeywordMinLength: 3, Rules: rules})
+	require.NoError(t, err)
+
+	message := func(title, source string) kafka.Message {
+		data, err := json.Marshal(models.RawNews{Title: title, Text: "Море и солнце", Source: source, Timestamp: "2024-01-02T15:04:05Z"})
+		require.NoError(t, err)
+		return kafka.Message{Value: data}
+	}
+	dropped := droppedByRules.Value()
+	idx := &stubIndexer{}
+	errs := processBatch(context.Background(), log, idx, time.Millisecond, dedupe.NewCache(100, time.Hour), testDecoder, pipeline, []kafka.Message{
+		message("Реклама отеля", "rss"),
+		message("Турция", "vk"),
+		message("Египет", "rss"),
+	})
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, []int{2}, idx.calls)
+	require.Equal(t, map[string]string{"Турция": "news-quarantine"}, idx.routed)
+	require.Equal(t, dropped+1, droppedByRules.Value())
+}
+
+func TestErrorClassDecode(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Worker{KeywordLimit: 5, KeywordMinLength: 3}
+	err := processBatch(context.Background(), log, &stubIndexer{}, time.Millisecond, dedupe.NewCache(1, time.Hour), testDecoder, newTestPipeline(t, cfg), []kafka.Message{{Value: []byte("{")}})[0]
+	require.Equal(t, "decode", errorClass(err))
+	require.Equal(t, "processing", errorClass(errors.New("empty payload")))
+}
+
+func TestProcessMessageWaitForRefreshHeader(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Worker{KeywordLimit: 5, KeywordMinLength: 3}
+	idx := &stubIndexer{}
+	data, err := json.Marshal(models.RawNews{Title: "Тур", Text: "Море", Timestamp: "2024-01-02T15:04:05Z"})
+	require.NoError(t, err)
+
+	msg := kafka.Message{
+		Value:   data,
+		Headers: []kafka.Header{{Key: "wait_for_refresh", Value: []byte("true")}},
+	}
+	require.NoError(t, processBatch(context.Background(), log, idx, time.Millisecond, dedupe.NewCache(10, time.Hour), testDecoder, newTestPipeline(t, cfg), []kafka.Message{msg})[0])
+	require.Equal(t, []int{1}, idx.optCounts)
+}
+
+// stubFetcher hands out msgs, then blocks until ctx ends like a reader
+// with nothing new to fetch.
+type stubFetcher struct {
+	msgs []kafka.Message
+}
+
+func (f *stubFetcher) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(f.msgs) > 0 {
+		msg := f.msgs[0]
+		f.msgs = f.msgs[1:]
+		return msg, nil
+	}
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+// ctxGroup fails commits once their context is done, as the Kafka reader does.
+type ctxGroup struct {
+	*stubGroup
+}
+
+func (g ctxGroup) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return g.stubGroup.CommitMessages(ctx, msgs...)
+}
+
+func TestConsumeDrainsInFlightBatchOnShutdown(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	workCtx, cancelWork := drainContext(ctx, time.Minute)
+	defer cancelWork()
+
+	group := &stubGroup{committed: map[topicPartition]int64{}}
+	committer := newOffsetCommitter(log, ctxGroup{group}, &stubWriter{})
+	started := make(chan struct{}, 1)
+	workers := startLanes(1, 3, time.Hour, func(batch []kafka.Message) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		// The signal arrives while the first batch is being processed.
+		<-ctx.Done()
+		committer.handle(workCtx, batch, make([]error, len(batch)))
+	})
+	go func() {
+		<-started
+		stop()
+	}()
+
+	consume(ctx, workCtx, log, &stubFetcher{msgs: partitionLog(0, 5)}, workers, committer)
+
+	require.NoError(t, workCtx.Err(), "the drain finished within its timeout")
+	// The in-flight batch and the messages queued behind it are committed.
+	require.Equal(t, map[topicPartition]int64{{"news_raw", 0}: 5}, group.committed)
+}
+
+func TestConsumeGivesUpAfterDrainTimeout(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	workCtx, cancelWork := drainContext(ctx, 10*time.Millisecond)
+	defer cancelWork()
+
+	group := &stubGroup{committed: map[topicPartition]int64{}}
+	committer := newOffsetCommitter(log, ctxGroup{group}, &stubWriter{})
+	workers := startLanes(1, 3, time.Hour, func(batch []kafka.Message) {
+		stop()
+		// Processing hangs until the drain timeout cancels it.
+		<-workCtx.Done()
+		committer.handle(workCtx, batch, make([]error, len(batch)))
+	})
+
+	msgs := partitionLog(0, 5)
+	consume(ctx, workCtx, log, &stubFetcher{msgs: msgs}, workers, committer)
+
+	require.ErrorIs(t, workCtx.Err(), context.Canceled)
+	require.Empty(t, group.committed)
+	require.Equal(t, msgs, group.redeliver(msgs), "a restarted worker reads every message again")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// processedMessages counts messages handled, whether indexed, dropped or
+// dead-lettered; their offsets are committed with the next commit.
+var processedMessages = expvar.NewInt("processed_messages")
+
+// progressReport is one consumer_progress snapshot.
+type progressReport struct {
+	// Lag holds, per topic and partition, the messages the consumer group
+	// has not committed yet.
+	Lag      map[string]map[int]int64 `json:"lag"`
+	TotalLag int64                    `json:"total_lag"`
+	// ProcessedPerSec is the rate since the previous report; it is 0 in the
+	// first one.
+	ProcessedPerSec float64   `json:"processed_per_sec"`
+	Processed       int64     `json:"processed"`
+	At              time.Time `json:"at"`
+	// Error is why the lag of some topic is missing.
+	Error string `json:"error,omitempty"`
+}
+
+// progressReporter tells whether the worker keeps up: every interval it
+// reads the high-water marks and committed offsets of every partition from
+// the brokers, logs the lag and the processing rate, and keeps the latest
+// report for /debug/vars.
+type progressReporter struct {
+	log     *slog.Logger
+	offsets groupOffsets
+	topics  []string
+	counter *expvar.Int
+
+	mu   sync.Mutex
+	prev counterSample
+	last progressReport
+}
+
+// run reports every interval until ctx is cancelled.
+func (p *progressReporter) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.report(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *progressReporter) report(ctx context.Context, now time.Time) progressReport {
+	rep := progressReport{Lag: make(map[string]map[int]int64, len(p.topics)), Processed: p.counter.Value(), At: now}
+
+	var errs []error
+	for _, topic := range p.topics {
+		ranges, err := p.offsets.committed(ctx, topic)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("read offsets of %s: %w", topic, err))
+			continue
+		}
+		partitions := make(map[int]int64, len(ranges))
+		for _, r := range ranges {
+			lag := max(r.End-r.Committed, 0)
+			partitions[r.Partition] = lag
+			rep.TotalLag += lag
+		}
+		rep.Lag[topic] = partitions
+	}
+	if err := errors.Join(errs...); err != nil {
+		rep.Error = err.Error()
+	}
+
+	p.mu.Lock()
+	if !p.prev.at.IsZero() {
+		if elapsed := now.Sub(p.prev.at).Seconds(); elapsed > 0 {
+			rep.ProcessedPerSec = float64(rep.Processed-p.prev.value) / elapsed
+		}
+	}
+	p.prev = counterSample{at: now, value: rep.Processed}
+	p.last = rep
+	p.mu.Unlock()
+
+	attrs := []any{
+		slog.Float64("processed_per_sec", rep.ProcessedPerSec),
+		slog.Int64("processed", rep.Processed),
+		slog.Int64("total_lag", rep.TotalLag),
+		slog.Any("lag", rep.Lag),
+	}
+	if rep.Error != "" {
+		p.log.Warn("consumer progress, lag incomplete", append(attrs, slog.String("err", rep.Error))...)
+	} else {
+		p.log.Info("consumer progress", attrs...)
+	}
+	return rep
+}
+
+// vars is the expvar.Func behind consumer_progress.
+func (p *progressReporter) vars() any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.last
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type failingOffsets struct {
+	stubOffsets
+	topic string
+}
+
+func (f failingOffsets) committed(ctx context.Context, topic string) ([]partitionRange, error) {
+	if topic == f.topic {
+		return nil, errors.New("broker unavailable")
+	}
+	return f.stubOffsets.committed(ctx, topic)
+}
+
+func TestProgressReporter(t *testing.T) {
+	counter := new(expvar.Int)
+	p := &progressReporter{
+		log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		offsets: stubOffsets{
+			"news_raw":  {{Partition: 0, Committed: 90, End: 100}, {Partition: 1, Committed: 40, End: 100}},
+			"rss_items": {{Partition: 0, Committed: 5, End: 5}},
+		},
+		topics:  []string{"news_raw", "rss_items"},
+		counter: counter,
+	}
+
+	start := time.Now()
+	counter.Add(100)
+	first := p.report(context.Background(), start)
+	require.Equal(t, map[string]map[int]int64{"news_raw": {0: 10, 1: 60}, "rss_items": {0: 0}}, first.Lag)
+	require.EqualValues(t, 70, first.TotalLag)
+	require.Zero(t, first.ProcessedPerSec)
+
+	counter.Add(300)
+	second := p.report(context.Background(), start.Add(time.Minute))
+	require.EqualValues(t, 400, second.Processed)
+	require.Equal(t, 5.0, second.ProcessedPerSec)
+	require.Equal(t, second, p.vars())
+}
+
+func TestProgressReporterKeepsOtherTopicsOnError(t *testing.T) {
+	p := &progressReporter{
+		log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		offsets: failingOffsets{
+			stubOffsets: stubOffsets{"news_raw": {{Partition: 0, Committed: 1, End: 4}}},
+			topic:       "rss_items",
+		},
+		topics:  []string{"news_raw", "rss_items"},
+		counter: new(expvar.Int),
+	}
+
+	rep := p.report(context.Background(), time.Now())
+	require.Equal(t, map[string]map[int]int64{"news_raw": {0: 3}}, rep.Lag)
+	require.EqualValues(t, 3, rep.TotalLag)
+	require.Equal(t, "read offsets of rss_items: broker unavailable", rep.Error)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// errSchema marks canonical news payloads that do not match their declared
+// schema version. They are dead-lettered with error class schema.
+var errSchema = errors.New("schema")
+
+// newsEnvelope is a canonical news payload as written by any producer
+// version. Version 1 payloads are the bare news fields and may omit
+// schema_version; later versions wrap the news in payload.
+type newsEnvelope struct {
+	SchemaVersion *int            `json:"schema_version"`
+	Payload       json.RawMessage `json:"payload"`
+	models.RawNews
+}
+
+// newsSchema turns an envelope of one version into models.RawNews, rejecting it
+// when required fields are missing.
+type newsSchema func(env newsEnvelope, limit int) (models.RawNews, error)
+
+// newsSchemas is the registry of supported schema versions. A producer
+// changing the payload shape adds a version here instead of changing an
+// existing one, so old messages still in the topic keep decoding.
+var newsSchemas = map[int]newsSchema{
+	1: decodeNewsV1,
+	2: decodeNewsV2,
+}
+
+// decodeNewsV1 reads the original flat shape: title, text, timestamp,
+// source and reply_to. Either a title or a text is required.
+func decodeNewsV1(env newsEnvelope, _ int) (models.RawNews, error) {
+	if len(env.Payload) > 0 {
+		return models.RawNews{}, fmt.Errorf("%w: version 1 has no payload field", errSchema)
+	}
+	if strings.TrimSpace(env.Title) == "" && strings.TrimSpace(env.Text) == "" {
+		return models.RawNews{}, fmt.Errorf("%w: version 1 requires title or text", errSchema)
+	}
+	return env.RawNews, nil
+}
+
+// decodeNewsV2 reads the news fields from payload. Text, source and an
+// RFC 3339 timestamp are required, so the worker no longer has to guess
+// the source or the publication time.
+func decodeNewsV2(env newsEnvelope, limit int) (models.RawNews, error) {
+	if env.RawNews != (models.RawNews{}) {
+		return models.RawNews{}, fmt.Errorf("%w: version 2 keeps news fields in payload", errSchema)
+	}
+	if len(env.Payload) == 0 || string(env.Payload) == "null" {
+		return models.RawNews{}, fmt.Errorf("%w: version 2 requires payload", errSchema)
+	}
+	var news models.RawNews
+	if err := decodePayload(env.Payload, limit, &news); err != nil {
+		return models.RawNews{}, err
+	}
+
+	var missing []string
+	if strings.TrimSpace(news.Text) == "" {
+		missing = append(missing, "text")
+	}
+	if strings.TrimSpace(news.Source) == "" {
+		missing = append(missing, "source")
+	}
+	if strings.TrimSpace(news.Timestamp) == "" {
+		missing = append(missing, "timestamp")
+	}
+	if len(missing) > 0 {
+		return models.RawNews{}, fmt.Errorf("%w: version 2 requires %s", errSchema, strings.Join(missing, ", "))
+	}
+	if parseTimestamp(news.Timestamp).IsZero() {
+		return models.RawNews{}, fmt.Errorf("%w: version 2 timestamp %q is not RFC 3339", errSchema, news.Timestamp)
+	}
+	return news, nil
+}
+
+// envelopePool reuses envelopes for their Payload buffer: decoding into a
+// json.RawMessage copies the wrapped news, which a pooled envelope does
+// into the buffer of an earlier message instead of a new one.
+var envelopePool = sync.Pool{New: func() any { return new(newsEnvelope) }}
+
+// decodeRawNews decodes a canonical news payload with the schema its
+// schema_version names.
+func decodeRawNews(data []byte, limit int) (models.RawNews, error) {
+	env := envelopePool.Get().(*newsEnvelope)
+	*env = newsEnvelope{Payload: env.Payload[:0]}
+	defer func() {
+		if cap(env.Payload) <= pooledMessageBytes {
+			envelopePool.Put(env)
+		}
+	}()
+	if err := decodePayload(data, limit, env); err != nil {
+		return models.RawNews{}, err
+	}
+
+	version := 1
+	if env.SchemaVersion != nil {
+		version = *env.SchemaVersion
+	}
+	schema, ok := newsSchemas[version]
+	if !ok {
+		supported := slices.Sorted(maps.Keys(newsSchemas))
+		names := make([]string, len(supported))
+		for i, v := range supported {
+			names[i] = strconv.Itoa(v)
+		}
+		return models.RawNews{}, fmt.Errorf("%w: unknown schema_version %d, supported: %s", errSchema, version, strings.Join(names, ", "))
+	}
+	return schema(*env, limit)
+}
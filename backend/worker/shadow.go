@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+// shadowIndexer writes the documents of a WORKER_MODE=shadow worker to the
+// shadow index. Documents that ingestion rules route to another index are
+// kept in the shadow index too, as those indexes are live.
+type shadowIndexer struct {
+	newsIndexer
+}
+
+func (s shadowIndexer) BulkIndexNews(ctx context.Context, items []elasticsearch.BulkItem, opts ...elasticsearch.IndexOption) ([]error, error) {
+	items = slices.Clone(items)
+	for i := range items {
+		items[i].Index = ""
+	}
+	return s.newsIndexer.BulkIndexNews(ctx, items, opts...)
+}
+
+// dryRunIndexer stands in for Elasticsearch in a shadow worker without
+// WORKER_SHADOW_INDEX: it logs the writes the worker would make and
+// reports them as successful.
+type dryRunIndexer struct {
+	log *slog.Logger
+}
+
+func (d dryRunIndexer) BulkIndexNews(_ context.Context, items []elasticsearch.BulkItem, _ ...elasticsearch.IndexOption) ([]error, error) {
+	for _, item := range items {
+		d.log.Info("shadow: would index news",
+			slog.String("id", item.Doc.ID),
+			slog.String("index", item.Index),
+			slog.String("source", item.Doc.Source),
+			slog.String("title", item.Doc.Title),
+			slog.Any("keywords", item.Doc.Keywords),
+			slog.Any("destinations", item.Doc.Destinations),
+			slog.Int("price", item.Doc.Price),
+			slog.Bool("repost", item.Repost),
+		)
+	}
+	return make([]error, len(items)), nil
+}
+
+func (d dryRunIndexer) ExpireOriginals(_ context.Context, notice models.NewsDocument, original elasticsearch.OriginalQuery) ([]string, error) {
+	d.log.Info("shadow: would expire sold out offers",
+		slog.String("notice", notice.ID),
+		slog.String("reply_to", original.ReplyTo),
+		slog.Any("links", original.Links),
+	)
+	return nil, nil
+}
+
+// shadowDLQ keeps a shadow worker off the dead-letter topics, which the
+// live worker owns; messages that would be dead-lettered are logged.
+type shadowDLQ struct {
+	log *slog.Logger
+}
+
+func (d shadowDLQ) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	for _, msg := range msgs {
+		d.log.Info("shadow: would dead-letter message", slog.String("topic", msg.Topic), slog.Int("bytes", len(msg.Value)))
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+)
+
+func TestShadowIndexerKeepsRoutedDocuments(t *testing.T) {
+	idx := &stubIndexer{}
+	items := []elasticsearch.BulkItem{
+		{Doc: models.NewsDocument{ID: "a", Title: "Турция"}, Index: "news-quarantine"},
+		{Doc: models.NewsDocument{ID: "b", Title: "Египет"}},
+	}
+	errs, err := shadowIndexer{newsIndexer: idx}.BulkIndexNews(context.Background(), items)
+	require.NoError(t, err)
+	require.Len(t, errs, 2)
+	require.Empty(t, idx.routed)
+	require.Len(t, idx.docs, 2)
+	// The caller's items are left as they were.
+	require.Equal(t, "news-quarantine", items[0].Index)
+}
+
+func TestDryRunIndexerReportsSuccess(t *testing.T) {
+	idx := dryRunIndexer{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	errs, err := idx.BulkIndexNews(context.Background(), []elasticsearch.BulkItem{{Doc: models.NewsDocument{ID: "a"}}, {Doc: models.NewsDocument{ID: "b"}}})
+	require.NoError(t, err)
+	require.Equal(t, []error{nil, nil}, errs)
+
+	ids, err := idx.ExpireOriginals(context.Background(), models.NewsDocument{ID: "n"}, elasticsearch.OriginalQuery{ReplyTo: "https://t.me/x/1"})
+	require.NoError(t, err)
+	require.Empty(t, ids)
+}
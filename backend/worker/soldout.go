@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/eventbus"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+)
+
+// expireOriginals marks the offers an indexed sold-out notice refers to as
+// expired. The notice itself is already stored, so a failure is only
+// logged and the offers stay visible until their own deadline.
+func expireOriginals(ctx context.Context, log *slog.Logger, esClient newsIndexer, doc models.NewsDocument, notice *processing.SoldOutNotice) {
+	ids, err := esClient.ExpireOriginals(ctx, doc, elasticsearch.OriginalQuery{
+		ReplyTo: notice.ReplyTo,
+		Links:   notice.Links,
+		Text:    notice.Text,
+		Since:   notice.Since,
+	})
+	if err != nil {
+		log.Warn("expire sold out offers", slog.Any("err", err), slog.String("notice", doc.ID))
+		return
+	}
+	if len(ids) == 0 {
+		log.Info("sold out notice without a matching offer", slog.String("notice", doc.ID))
+		return
+	}
+	log.Info("expired sold out offers", slog.String("notice", doc.ID), slog.Any("offers", ids))
+}
+
+// flaggingIndexer publishes a DocumentFlagged event for every offer a
+// sold-out notice expired. Like fan-out, a publishing failure is only
+// logged: the offers are already marked expired.
+type flaggingIndexer struct {
+	newsIndexer
+	events *eventbus.Publisher
+	log    *slog.Logger
+}
+
+func (f *flaggingIndexer) ExpireOriginals(ctx context.Context, notice models.NewsDocument, original elasticsearch.OriginalQuery) ([]string, error) {
+	ids, err := f.newsIndexer.ExpireOriginals(ctx, notice, original)
+	if err != nil || len(ids) == 0 {
+		return ids, err
+	}
+	at := time.Now().UTC()
+	events := make([]eventbus.Event, 0, len(ids))
+	for _, id := range ids {
+		events = append(events, eventbus.DocumentFlagged{DocumentID: id, Reason: eventbus.ReasonSoldOut, Detail: notice.ID, At: at})
+	}
+	if err := f.events.Publish(ctx, events...); err != nil {
+		f.log.Warn("publish flagged offers", slog.String("notice", notice.ID), slog.Any("err", err))
+	}
+	return ids, nil
+}
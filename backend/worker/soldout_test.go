@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DeafMist/hot-tour-radar/backend/internal/dedupe"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/elasticsearch"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/eventbus"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/models"
+	"github.com/DeafMist/hot-tour-radar/backend/internal/processing"
+)
+
+func TestProcessBatchExpiresSoldOutOffers(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	pipeline, err := processing.BuildPipeline(nil, processing.Options{SoldOutWindow: 48 * time.Hour})
+	require.NoError(t, err)
+	idx := &stubIndexer{}
+
+	msgs := []kafka.Message{
+		{Value: []byte(`{"text":"Турция, Кемер 7 ночей от 45 000 ₽","timestamp":"2024-06-10T09:00:00Z","source":"telegram"}`)},
+		{Value: []byte(`{"text":"Мест нет!","timestamp":"2024-06-10T12:00:00Z","source":"telegram","reply_to":"https://t.me/hottours/42"}`)},
+	}
+	errs := processBatch(context.Background(), log, idx, time.Millisecond, dedupe.NewCache(10, time.Hour), testDecoder, pipeline, msgs)
+	require.Equal(t, []error{nil, nil}, errs)
+
+	require.Len(t, idx.docs, 2)
+	require.Empty(t, idx.docs[0].Status)
+	// The notice is indexed as expired itself, so active_only hides it too.
+	require.Equal(t, models.StatusExpired, idx.docs[1].Status)
+	require.Len(t, idx.expired, 1)
+	require.Equal(t, "https://t.me/hottours/42", idx.expired[0].ReplyTo)
+	require.Equal(t, time.Date(2024, 6, 8, 12, 0, 0, 0, time.UTC), idx.expired[0].Since)
+}
+
+func TestFlaggingIndexerPublishesExpiredOffers(t *testing.T) {
+	idx := &stubIndexer{expiredIDs: []string{"offer-1", "offer-2"}}
+	writer := &stubWriter{}
+	f := &flaggingIndexer{
+		newsIndexer: idx,
+		events:      eventbus.NewPublisher(writer, "news_events"),
+		log:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	ids, err := f.ExpireOriginals(context.Background(), models.NewsDocument{ID: "notice"}, elasticsearch.OriginalQuery{ReplyTo: "https://t.me/hottours/42"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"offer-1", "offer-2"}, ids)
+
+	require.Len(t, writer.msgs, 2)
+	for i, msg := range writer.msgs {
+		require.Equal(t, "news_events", msg.Topic)
+		e, err := eventbus.Decode(msg)
+		require.NoError(t, err)
+		flagged := e.(eventbus.DocumentFlagged)
+		require.Equal(t, ids[i], flagged.DocumentID)
+		require.Equal(t, eventbus.ReasonSoldOut, flagged.Reason)
+		require.Equal(t, "notice", flagged.Detail)
+	}
+
+	// Nothing expired, nothing flagged.
+	idx.expiredIDs = nil
+	_, err = f.ExpireOriginals(context.Background(), models.NewsDocument{ID: "notice-2"}, elasticsearch.OriginalQuery{})
+	require.NoError(t, err)
+	require.Len(t, writer.msgs, 2)
+}